@@ -0,0 +1,59 @@
+package packer
+
+import "sort"
+
+// applyAliases renames attributes packed under legacy names to their current names, so that items
+// remain readable through the current schema.  Aliases map old names to new names, and may be chained
+// across successive renames.  If an item holds both an old and a current name, the current name is kept;
+// if several old names resolve to the same current name, the first in name order is kept.
+func (e *EncryptedItem[T]) applyAliases(aliases map[string]string) {
+	if len(aliases) == 0 {
+		return
+	}
+	e.attributes = renameAliased(aliases, e.attributes)
+	if e.streamed != nil {
+		e.streamed = renameAliased(aliases, e.streamed)
+	}
+}
+
+// renameAliased returns the values keyed by their current names
+func renameAliased[V any](aliases map[string]string, values map[string]V) map[string]V {
+
+	renamed := make(map[string]V, len(values))
+	for k, v := range values {
+		if _, ok := aliases[k]; !ok {
+			renamed[k] = v
+		}
+	}
+
+	// Legacy names are resolved in order, so that the outcome is deterministic if several share a current name
+	legacy := []string{}
+	for k := range values {
+		if _, ok := aliases[k]; ok {
+			legacy = append(legacy, k)
+		}
+	}
+	sort.Strings(legacy)
+
+	for _, k := range legacy {
+		name := resolveAlias(aliases, k)
+		if _, ok := renamed[name]; !ok {
+			renamed[name] = values[k]
+		}
+	}
+
+	return renamed
+}
+
+// resolveAlias follows the chain of renames from the name, stopping if a cycle is detected
+func resolveAlias(aliases map[string]string, name string) string {
+	seen := map[string]bool{name: true}
+	for {
+		next, ok := aliases[name]
+		if !ok || seen[next] {
+			return name
+		}
+		seen[next] = true
+		name = next
+	}
+}
@@ -0,0 +1,61 @@
+package packer
+
+import (
+	"context"
+	"testing"
+)
+
+func TestUnpackAliases(t *testing.T) {
+
+	testPack, _, provider := testCreateEnv(t)
+
+	item := &Item[Key]{
+		Key: Key{X: "A", Y: "B"},
+		Attributes: map[string]any{
+			"surname":  "Smith",
+			"forename": "Bob",
+			"given":    "Robert",
+			"age":      int64(42),
+		},
+	}
+
+	b, l, err := testPack(item)
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+
+	e, err := Unpack(context.TODO(), b, &UnpackParams[Key]{
+		IDRetriever: func(string) (IDSerialiser[Key], error) { return NewKeySerialiser() },
+		Provider:    provider,
+		DataLoader:  l,
+		Aliases: map[string]string{
+			"surname":    "familyName",
+			"forename":   "firstName",
+			"firstName":  "givenName",
+			"given":      "givenName",
+			"familyName": "surname",
+		},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+
+	m, err := e.GetValues(context.TODO(), []string{"familyName", "givenName", "forename", "given", "age"}, provider)
+	if err != nil {
+		t.Fatalf("Unexpected error getting values: %v", err)
+	}
+
+	expected := map[string]any{
+		"familyName": "Smith",
+		"givenName":  "Bob",
+		"age":        int64(42),
+	}
+	if len(m) != len(expected) {
+		t.Fatalf("Unexpected values: %v", m)
+	}
+	for k, v := range expected {
+		if m[k] != v {
+			t.Fatalf("Unexpected value for %s: expected: %v, got: %v", k, v, m[k])
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package packer
+
+import "sync"
+
+// Allocator provides the large temporary buffers used during Pack and GetValues, such as compression
+// and erasure coding scratch space, so that latency-sensitive services can supply arenas or off-heap
+// pools.  Buffers are never retained in the packed output or returned values.  Implementations must
+// be safe for concurrent use.
+type Allocator interface {
+	// Alloc returns a buffer of length n
+	Alloc(n int) []byte
+	// Free is called with each buffer returned by Alloc, once it is no longer used
+	Free(b []byte)
+}
+
+// HeapAllocator allocates buffers from the heap, leaving them to the garbage collector.
+// It is used if no Allocator is specified.
+type HeapAllocator struct{}
+
+func (HeapAllocator) Alloc(n int) []byte { return make([]byte, n) }
+func (HeapAllocator) Free([]byte)        {}
+
+// allocatorOrDefault ensures an Allocator is always available
+func allocatorOrDefault(a Allocator) Allocator {
+	if a == nil {
+		return HeapAllocator{}
+	}
+	return a
+}
+
+// WithAllocator sets the Allocator used for temporary buffers during Pack
+func WithAllocator(a Allocator) func(o *Options) {
+	return func(o *Options) {
+		o.allocator = a
+	}
+}
+
+// ArenaAllocator allocates buffers from large blocks, all of which are released at once by Reset,
+// rather than individually.  Blocks are retained and reused after Reset, so that a service can
+// allocate the scratch memory of each request from the same blocks.
+type ArenaAllocator struct {
+	mu        sync.Mutex
+	blockSize int
+	blocks    [][]byte
+	current   int
+	offset    int
+}
+
+// NewArenaAllocator creates an ArenaAllocator with blocks of the specified size.  Requests larger
+// than the block size are allocated their own block.
+func NewArenaAllocator(blockSize int) *ArenaAllocator {
+	return &ArenaAllocator{blockSize: max(blockSize, 1)}
+}
+
+// Alloc returns a zeroed buffer of length n from the arena
+func (a *ArenaAllocator) Alloc(n int) []byte {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if n > a.blockSize {
+		b := make([]byte, n)
+		a.blocks = append(a.blocks, b)
+		return b
+	}
+
+	for a.current < len(a.blocks) {
+		block := a.blocks[a.current]
+		if len(block) == a.blockSize && a.offset+n <= len(block) {
+			b := block[a.offset : a.offset+n : a.offset+n]
+			a.offset += n
+			clear(b)
+			return b
+		}
+		a.current++
+		a.offset = 0
+	}
+
+	block := make([]byte, a.blockSize)
+	a.blocks = append(a.blocks, block)
+	a.offset = n
+	return block[:n:n]
+}
+
+// Free is a no-op, as buffers are released by Reset
+func (a *ArenaAllocator) Free([]byte) {}
+
+// Reset releases all buffers allocated from the arena, which must no longer be in use
+func (a *ArenaAllocator) Reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// Blocks allocated for oversized requests are not reused
+	blocks := a.blocks[:0]
+	for _, b := range a.blocks {
+		if len(b) == a.blockSize {
+			blocks = append(blocks, b)
+		}
+	}
+	clear(a.blocks[len(blocks):])
+	a.blocks = blocks
+	a.current = 0
+	a.offset = 0
+}
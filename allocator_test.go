@@ -0,0 +1,96 @@
+package packer
+
+import (
+	"context"
+	"maps"
+	"reflect"
+	"slices"
+	"sync"
+	"testing"
+)
+
+type countingAllocator struct {
+	mu          sync.Mutex
+	allocs      int
+	outstanding map[*byte]bool
+}
+
+func (c *countingAllocator) Alloc(n int) []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Spare capacity allows zero length buffers to be identified
+	b := make([]byte, n+1)[:n]
+	c.allocs++
+	c.outstanding[&b[:1][0]] = true
+	return b
+}
+
+func (c *countingAllocator) Free(b []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.outstanding, &b[:1][0])
+}
+
+func TestWithAllocator(t *testing.T) {
+
+	testPack, testUnpack, provider := testCreateEnv(t)
+
+	item := &Item[Key]{
+		Key:        Key{X: "A", Y: "B"},
+		Attributes: map[string]any{"a": "hello world", "b": int64(42), "c": []byte("some bytes")},
+	}
+
+	alloc := &countingAllocator{outstanding: map[*byte]bool{}}
+
+	b, l, err := testPack(item, WithAllocator(alloc), WithCompression(FlateCompression), WithErasureCoding(1))
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+	if alloc.allocs == 0 || len(alloc.outstanding) != 0 {
+		t.Fatalf("Expected all %d allocations to be freed, %d outstanding", alloc.allocs, len(alloc.outstanding))
+	}
+
+	e, err := testUnpack(b, l)
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+	values, err := e.GetValues(context.TODO(), slices.Collect(maps.Keys(item.Attributes)), provider)
+	if err != nil {
+		t.Fatalf("Unexpected error getting values: %v", err)
+	}
+	if !reflect.DeepEqual(values, item.Attributes) {
+		t.Fatalf("Mismatch: expected %v, got %v", item.Attributes, values)
+	}
+}
+
+func TestArenaAllocator(t *testing.T) {
+
+	a := NewArenaAllocator(100)
+
+	x := a.Alloc(40)
+	y := a.Alloc(40)
+	if len(x) != 40 || cap(x) != 40 || len(y) != 40 {
+		t.Fatalf("Unexpected buffers: %d/%d, %d", len(x), cap(x), len(y))
+	}
+	if &x[0] == &y[0] {
+		t.Fatal("Expected distinct buffers")
+	}
+	if len(a.blocks) != 1 {
+		t.Fatalf("Expected buffers from one block, got %d", len(a.blocks))
+	}
+
+	a.Alloc(40)
+	a.Alloc(500)
+	if len(a.blocks) != 3 {
+		t.Fatalf("Expected a new block and an oversized block, got %d", len(a.blocks))
+	}
+
+	x[0] = 1
+	a.Reset()
+	if len(a.blocks) != 2 {
+		t.Fatalf("Expected oversized blocks to be released, got %d", len(a.blocks))
+	}
+	if z := a.Alloc(10); &z[0] != &x[0] || z[0] != 0 {
+		t.Fatal("Expected blocks to be reused, and zeroed, after Reset")
+	}
+}
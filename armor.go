@@ -0,0 +1,56 @@
+package packer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+)
+
+// armorPrefix begins armored packed data, identifying the encoding that follows
+var armorPrefix = []byte("PACKER1:")
+
+// WithArmoredOutput returns the packed data as printable ASCII, encoded with URL-safe base64 and a short prefix,
+// so that it can be passed through text-only channels such as environment variables, JSON or email.
+// Unpack, and the other functions accepting packed data, detect and decode armored data, so it need not be
+// decoded first (see Dearmor).  The maximum size applies to the packed data before it is armored, which
+// increases its size by a third.  Elements are unaffected.
+func WithArmoredOutput() func(o *Options) {
+	return func(o *Options) {
+		o.armored = true
+	}
+}
+
+// ErrInvalidArmoredData raised if armored packed data cannot be decoded
+var ErrInvalidArmoredData = errors.New("invalid armored data, cannot decode")
+
+// Armor returns the packed data as printable ASCII, as if packed using WithArmoredOutput.  Data that is
+// already armored is returned unchanged.
+func Armor(data []byte) []byte {
+	if isArmored(data) {
+		return data
+	}
+	b := make([]byte, len(armorPrefix)+base64.RawURLEncoding.EncodedLen(len(data)))
+	copy(b, armorPrefix)
+	base64.RawURLEncoding.Encode(b[len(armorPrefix):], data)
+	return b
+}
+
+// Dearmor returns the packed data from armored data (see WithArmoredOutput).  Data that is not armored is
+// returned unchanged.
+func Dearmor(data []byte) ([]byte, error) {
+	if !isArmored(data) {
+		return data, nil
+	}
+	data = bytes.TrimRight(data[len(armorPrefix):], "\r\n")
+	b := make([]byte, base64.RawURLEncoding.DecodedLen(len(data)))
+	n, err := base64.RawURLEncoding.Decode(b, data)
+	if err != nil {
+		return nil, ErrInvalidArmoredData
+	}
+	return b[:n], nil
+}
+
+// isArmored returns true if the packed data is armored
+func isArmored(data []byte) bool {
+	return bytes.HasPrefix(data, armorPrefix)
+}
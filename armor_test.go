@@ -0,0 +1,84 @@
+package packer
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"maps"
+	"testing"
+	"unicode"
+)
+
+func TestWithArmoredOutput(t *testing.T) {
+
+	testPack, testUnpack, provider := testCreateEnv(t)
+
+	small := &Item[Key]{
+		Key:        Key{X: "A", Y: "B"},
+		Attributes: map[string]any{"a": "x", "b": int64(2)},
+	}
+	wide := &Item[Key]{
+		Key:        Key{X: "A", Y: "B"},
+		Attributes: map[string]any{},
+	}
+	for i := range 600 {
+		b := make([]byte, 20)
+		if _, err := rand.Read(b); err != nil {
+			t.Fatalf("Unexpected error creating name: %v", err)
+		}
+		wide.Attributes[hex.EncodeToString(b)] = int64(i)
+	}
+
+	tests := []struct {
+		item *Item[Key]
+		opts []func(*Options)
+	}{
+		{item: small, opts: []func(*Options){WithArmoredOutput()}},
+		{item: small, opts: []func(*Options){WithArmoredOutput(), WithPackingVersion(V2)}},
+		{item: wide, opts: []func(*Options){WithArmoredOutput(), WithMaximumKBSize(10)}},
+	}
+
+	for i, test := range tests {
+		b, l, err := testPack(test.item, test.opts...)
+		if err != nil {
+			t.Fatalf("(%d) Unexpected error packing: %v", i, err)
+		}
+		if !isArmored(b) {
+			t.Fatalf("(%d) Expected armored output", i)
+		}
+		for _, r := range string(b) {
+			if r > unicode.MaxASCII || !unicode.IsPrint(r) {
+				t.Fatalf("(%d) Unexpected character in armored output: %q", i, r)
+			}
+		}
+
+		e, err := testUnpack(b, l)
+		if err != nil {
+			t.Fatalf("(%d) Unexpected error unpacking: %v", i, err)
+		}
+		values, err := e.GetAllValues(context.TODO(), provider)
+		if err != nil {
+			t.Fatalf("(%d) Unexpected error getting values: %v", i, err)
+		}
+		if !maps.Equal(values, test.item.Attributes) {
+			t.Fatalf("(%d) Mismatch in values", i)
+		}
+
+		// Dearmored data is equally valid
+		d, err := Dearmor(b)
+		if err != nil {
+			t.Fatalf("(%d) Unexpected error dearmoring: %v", i, err)
+		}
+		if _, err := testUnpack(d, l); err != nil {
+			t.Fatalf("(%d) Unexpected error unpacking dearmored data: %v", i, err)
+		}
+		if string(Armor(d)) != string(b) || string(Armor(b)) != string(b) {
+			t.Fatalf("(%d) Expected Armor to be the inverse of Dearmor", i)
+		}
+	}
+
+	if _, err := Dearmor([]byte("PACKER1:not*base64")); !errors.Is(err, ErrInvalidArmoredData) {
+		t.Fatalf("Expected ErrInvalidArmoredData, got: %v", err)
+	}
+}
@@ -0,0 +1,273 @@
+package packer
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/aes"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+
+	"github.com/gford1000-go/serialise"
+)
+
+// AsymmetricKeyProviderInfo associates an identifier to a public key, and optionally its private key
+type AsymmetricKeyProviderInfo struct {
+	ID EnvelopeKeyID
+	// PublicKey is either an *rsa.PublicKey, or an *ecdh.PublicKey of the X25519 curve.  It may be omitted
+	// if the PrivateKey is provided.
+	PublicKey crypto.PublicKey
+	// PrivateKey is the *rsa.PrivateKey or *ecdh.PrivateKey of the PublicKey.  If nil, the provider can
+	// create keys but not decrypt them.
+	PrivateKey crypto.PrivateKey
+}
+
+const (
+	asymmetricRSAOAEP = "RSA-OAEP-256"
+	asymmetricX25519  = "X25519"
+
+	minRSAKeyBits = 2048
+)
+
+// asymmetricLabel is the RSA-OAEP label, and X25519 key derivation info, binding wrapped keys to this usage
+var asymmetricLabel = []byte("packer envelope key")
+
+// ErrUnsupportedAsymmetricKey raised if the keys of an AsymmetricKeyProviderInfo are not RSA or X25519 keys,
+// or the private key does not match the public key
+var ErrUnsupportedAsymmetricKey = errors.New("asymmetric keys must be a matching RSA or X25519 key pair")
+
+// ErrRSAKeyTooSmall raised if an RSA key of fewer than 2048 bits is provided to NewAsymmetricEnvelopeKeyProvider
+var ErrRSAKeyTooSmall = errors.New("RSA keys must be at least 2048 bits")
+
+// NewAsymmetricEnvelopeKeyProvider creates an EnvelopeKeyProvider that wraps each data encryption key with the
+// public key of the keyInfo, using RSA-OAEP (SHA-256) or X25519 (ephemeral-static ECDH, HKDF-SHA256, AES-GCM).
+// Only providers created with the private key can decrypt, so services that are not trusted to read packed data
+// can be given a provider holding just the public key, allowing them to Pack data that only a backend can Unpack.
+func NewAsymmetricEnvelopeKeyProvider(keyInfo *AsymmetricKeyProviderInfo, finder EnveloperKeyProviderFinder) (EnvelopeKeyProvider, error) {
+
+	if keyInfo == nil {
+		return nil, ErrMissingEnvelopeKeyProviderInfo
+	}
+	if len(keyInfo.ID) == 0 {
+		return nil, ErrProviderMustHaveAnID
+	}
+	if finder == nil {
+		return nil, ErrMissingFinder
+	}
+
+	p := &asymmetricKeyProvider{
+		finder: finder,
+		id:     keyInfo.ID,
+	}
+
+	public := keyInfo.PublicKey
+	if public == nil {
+		if signer, ok := keyInfo.PrivateKey.(interface{ Public() crypto.PublicKey }); ok {
+			public = signer.Public()
+		}
+	}
+
+	switch pub := public.(type) {
+	case *rsa.PublicKey:
+		if pub.N.BitLen() < minRSAKeyBits {
+			return nil, ErrRSAKeyTooSmall
+		}
+		p.algorithm, p.rsaPublic = asymmetricRSAOAEP, pub
+		if keyInfo.PrivateKey != nil {
+			priv, ok := keyInfo.PrivateKey.(*rsa.PrivateKey)
+			if !ok || !priv.PublicKey.Equal(pub) {
+				return nil, ErrUnsupportedAsymmetricKey
+			}
+			p.rsaPrivate = priv
+		}
+	case *ecdh.PublicKey:
+		if pub.Curve() != ecdh.X25519() {
+			return nil, ErrUnsupportedAsymmetricKey
+		}
+		p.algorithm, p.x25519Public = asymmetricX25519, pub
+		if keyInfo.PrivateKey != nil {
+			priv, ok := keyInfo.PrivateKey.(*ecdh.PrivateKey)
+			if !ok || !priv.PublicKey().Equal(pub) {
+				return nil, ErrUnsupportedAsymmetricKey
+			}
+			p.x25519Private = priv
+		}
+	default:
+		return nil, ErrUnsupportedAsymmetricKey
+	}
+
+	return p, nil
+}
+
+type asymmetricKeyProvider struct {
+	algorithm     string
+	rsaPublic     *rsa.PublicKey
+	rsaPrivate    *rsa.PrivateKey
+	x25519Public  *ecdh.PublicKey
+	x25519Private *ecdh.PrivateKey
+	finder        EnveloperKeyProviderFinder
+	id            EnvelopeKeyID
+}
+
+func (a *asymmetricKeyProvider) ID() EnvelopeKeyID {
+	return a.id
+}
+
+func (a *asymmetricKeyProvider) New() ([]byte, []byte, error) {
+
+	newKey := make([]byte, 2*aes.BlockSize)
+	if _, err := rand.Read(newKey); err != nil {
+		return nil, nil, err
+	}
+
+	b, err := a.Wrap(context.Background(), newKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return b, newKey, nil
+}
+
+func (a *asymmetricKeyProvider) Wrap(ctx context.Context, key []byte) ([]byte, error) {
+
+	var wrapped []byte
+	var err error
+
+	switch a.algorithm {
+	case asymmetricRSAOAEP:
+		wrapped, err = rsa.EncryptOAEP(sha256.New(), rand.Reader, a.rsaPublic, key, asymmetricLabel)
+	default:
+		wrapped, err = a.wrapX25519(key)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The third element identifies the algorithm, so that keys cannot be decrypted as another form
+	b, _, err := serialise.ToBytesMany(
+		[]any{
+			string(a.id),
+			wrapped,
+			a.algorithm,
+		}, serialise.WithSerialisationApproach(serialise.NewMinDataApproachWithVersion(serialise.V1)))
+	if err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// wrapX25519 encrypts the key under a key agreed between an ephemeral key and the public key, returning
+// the ephemeral public key followed by the nonce and sealed key
+func (a *asymmetricKeyProvider) wrapX25519(key []byte) ([]byte, error) {
+
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := ephemeral.ECDH(a.x25519Public)
+	if err != nil {
+		return nil, err
+	}
+
+	ephemeralPublic := ephemeral.PublicKey().Bytes()
+	kek := hkdfSHA256(shared, append(bytes.Clone(ephemeralPublic), a.x25519Public.Bytes()...), asymmetricLabel, 2*aes.BlockSize)
+
+	aead, err := newAESGCM(kek)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(append(ephemeralPublic, nonce...), nonce, key, asymmetricLabel), nil
+}
+
+func (a *asymmetricKeyProvider) Decrypt(ctx context.Context, encryptedKey []byte) ([]byte, error) {
+
+	v, err := serialise.FromBytesMany(encryptedKey, serialise.NewMinDataApproachWithVersion(serialise.V1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(v) != 2 && len(v) != 3 {
+		return nil, ErrKeyDeserialisationError
+	}
+
+	id, ok := v[0].(string)
+	if !ok {
+		return nil, ErrKeyDeserialisationError
+	}
+
+	if EnvelopeKeyID(id) != a.id {
+		other, err := a.finder(EnvelopeKeyID(id))
+		if err != nil {
+			return nil, err
+		}
+		return other.Decrypt(ctx, encryptedKey)
+	}
+
+	if len(v) != 3 {
+		return nil, ErrKeyDeserialisationError
+	}
+	wrapped, ok := v[1].([]byte)
+	if !ok {
+		return nil, ErrKeyDeserialisationError
+	}
+	if algorithm, ok := v[2].(string); !ok || algorithm != a.algorithm {
+		return nil, ErrKeyDeserialisationError
+	}
+
+	switch a.algorithm {
+	case asymmetricRSAOAEP:
+		if a.rsaPrivate == nil {
+			return nil, ErrProviderIsEncryptOnly
+		}
+		key, err := rsa.DecryptOAEP(sha256.New(), nil, a.rsaPrivate, wrapped, asymmetricLabel)
+		if err != nil {
+			return nil, ErrKeyProviderDecryptError
+		}
+		return key, nil
+	default:
+		if a.x25519Private == nil {
+			return nil, ErrProviderIsEncryptOnly
+		}
+		return a.unwrapX25519(wrapped)
+	}
+}
+
+// unwrapX25519 reverses wrapX25519, using the private key
+func (a *asymmetricKeyProvider) unwrapX25519(wrapped []byte) ([]byte, error) {
+
+	const publicKeySize, nonceSize = 32, 12
+	if len(wrapped) < publicKeySize+nonceSize {
+		return nil, ErrKeyDeserialisationError
+	}
+
+	ephemeral, err := ecdh.X25519().NewPublicKey(wrapped[:publicKeySize])
+	if err != nil {
+		return nil, ErrKeyDeserialisationError
+	}
+	shared, err := a.x25519Private.ECDH(ephemeral)
+	if err != nil {
+		return nil, ErrKeyProviderDecryptError
+	}
+
+	kek := hkdfSHA256(shared, append(bytes.Clone(wrapped[:publicKeySize]), a.x25519Public.Bytes()...), asymmetricLabel, 2*aes.BlockSize)
+
+	aead, err := newAESGCM(kek)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := aead.Open(nil, wrapped[publicKeySize:publicKeySize+nonceSize], wrapped[publicKeySize+nonceSize:], asymmetricLabel)
+	if err != nil {
+		return nil, ErrKeyProviderDecryptError
+	}
+	return key, nil
+}
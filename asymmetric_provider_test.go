@@ -0,0 +1,150 @@
+package packer
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+
+	"github.com/gford1000-go/serialise"
+)
+
+func TestAsymmetricEnvelopeKeyProvider(t *testing.T) {
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Unexpected error generating RSA key: %v", err)
+	}
+	x25519Key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Unexpected error generating X25519 key: %v", err)
+	}
+
+	serialiser, _ := NewKeySerialiser()
+
+	tests := []struct {
+		name    string
+		public  any
+		private any
+	}{
+		{name: "rsa", public: &rsaKey.PublicKey, private: rsaKey},
+		{name: "x25519", public: x25519Key.PublicKey(), private: x25519Key},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			m := map[EnvelopeKeyID]EnvelopeKeyProvider{}
+			finder := func(id EnvelopeKeyID) (EnvelopeKeyProvider, error) {
+				if p, ok := m[id]; ok {
+					return p, nil
+				}
+				return nil, errors.New("unknown provider id")
+			}
+
+			edge, err := NewAsymmetricEnvelopeKeyProvider(&AsymmetricKeyProviderInfo{ID: "Edge", PublicKey: test.public}, finder)
+			if err != nil {
+				t.Fatalf("Unexpected error creating edge provider: %v", err)
+			}
+			backend, err := NewAsymmetricEnvelopeKeyProvider(&AsymmetricKeyProviderInfo{ID: "Edge", PrivateKey: test.private}, finder)
+			if err != nil {
+				t.Fatalf("Unexpected error creating backend provider: %v", err)
+			}
+
+			item := &Item[Key]{
+				Key:        Key{X: "A", Y: "B"},
+				Attributes: map[string]any{"aaa": "Hello World", "bbb": int64(42)},
+			}
+
+			info, data, err := Pack(item, &PackParams[Key]{
+				Provider: edge,
+				Creator:  NewKeyCreator(defaultLen),
+				Packer:   serialiser,
+				Approach: serialise.NewMinDataApproachWithVersion(serialise.V1),
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error packing: %v", err)
+			}
+
+			loader := func(ctx context.Context, keys []Key) (map[string][]byte, error) {
+				attrs := map[string][]byte{}
+				for _, key := range keys {
+					for k, v := range data[key] {
+						attrs[k] = v
+					}
+				}
+				return attrs, nil
+			}
+
+			unpack := func(provider EnvelopeKeyProvider) (*EncryptedItem[Key], error) {
+				return Unpack(context.TODO(), info, &UnpackParams[Key]{
+					DataLoader:  loader,
+					IDRetriever: func(string) (IDSerialiser[Key], error) { return serialiser, nil },
+					Provider:    provider,
+				})
+			}
+
+			if _, err := unpack(edge); !errors.Is(err, ErrProviderIsEncryptOnly) {
+				t.Fatalf("Expected ErrProviderIsEncryptOnly, got: %v", err)
+			}
+
+			e, err := unpack(backend)
+			if err != nil {
+				t.Fatalf("Unexpected error unpacking: %v", err)
+			}
+			values, err := e.GetValues(context.TODO(), []string{"aaa", "bbb"}, backend)
+			if err != nil {
+				t.Fatalf("Unexpected error getting values: %v", err)
+			}
+			if values["aaa"] != "Hello World" || values["bbb"] != int64(42) {
+				t.Fatalf("Unexpected values: %v", values)
+			}
+
+			// Keys wrapped for another provider are decrypted by the provider found for their ID
+			other, err := NewEnvelopeKeyProvider(&EnvelopeKeyProviderInfo{ID: "Other", Key: []byte("01234567890123456789012345678912")}, finder)
+			if err != nil {
+				t.Fatalf("Unexpected error creating provider: %v", err)
+			}
+			m[backend.ID()] = backend
+			if _, err := unpack(other); err != nil {
+				t.Fatalf("Unexpected error unpacking with another provider: %v", err)
+			}
+		})
+	}
+}
+
+func TestNewAsymmetricEnvelopeKeyProviderErrors(t *testing.T) {
+
+	finder := func(EnvelopeKeyID) (EnvelopeKeyProvider, error) { return nil, errors.New("unknown provider id") }
+
+	small, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("Unexpected error generating RSA key: %v", err)
+	}
+	p256, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Unexpected error generating P256 key: %v", err)
+	}
+	x25519Key, _ := ecdh.X25519().GenerateKey(rand.Reader)
+	otherKey, _ := ecdh.X25519().GenerateKey(rand.Reader)
+
+	tests := []struct {
+		info *AsymmetricKeyProviderInfo
+		err  error
+	}{
+		{info: nil, err: ErrMissingEnvelopeKeyProviderInfo},
+		{info: &AsymmetricKeyProviderInfo{PublicKey: x25519Key.PublicKey()}, err: ErrProviderMustHaveAnID},
+		{info: &AsymmetricKeyProviderInfo{ID: "A"}, err: ErrUnsupportedAsymmetricKey},
+		{info: &AsymmetricKeyProviderInfo{ID: "A", PublicKey: &small.PublicKey}, err: ErrRSAKeyTooSmall},
+		{info: &AsymmetricKeyProviderInfo{ID: "A", PublicKey: p256.PublicKey()}, err: ErrUnsupportedAsymmetricKey},
+		{info: &AsymmetricKeyProviderInfo{ID: "A", PublicKey: x25519Key.PublicKey(), PrivateKey: otherKey}, err: ErrUnsupportedAsymmetricKey},
+	}
+
+	for i, test := range tests {
+		if _, err := NewAsymmetricEnvelopeKeyProvider(test.info, finder); !errors.Is(err, test.err) {
+			t.Fatalf("(%d) Expected %v, got: %v", i, test.err, err)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package packer
+
+import (
+	"encoding/binary"
+	"errors"
+	"sort"
+
+	"github.com/gford1000-go/serialise"
+)
+
+// WithAttributeNameDictionary records the attribute map of the envelope as a dictionary holding each
+// attribute and chunk name once, with the chunks of each attribute referenced by their index within the
+// dictionary.  Names in the dictionary are stored as the suffix following the prefix shared with the
+// preceding name, which reduces the size of the envelope for items with hundreds of similarly named attributes.  Items packed with the dictionary cannot be
+// unpacked by earlier releases.
+func WithAttributeNameDictionary() func(o *Options) {
+	return func(o *Options) {
+		o.attrDictionary = true
+	}
+}
+
+// ErrInvalidDataToDeserialiseAttrDictionary raised if the attribute name dictionary cannot be deserialised
+var ErrInvalidDataToDeserialiseAttrDictionary = errors.New("invalid data, cannot deserialise attribute name dictionary")
+
+// packAttrDictionary serialises the attribute map as the dictionary of names, followed by the index
+// of each attribute: the dictionary index of its name, the number of chunks, and the index of each chunk
+func packAttrDictionary(attrMap map[string][]string, approach serialise.Approach) ([]byte, error) {
+
+	names := make([]string, 0, len(attrMap))
+	for k := range attrMap {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	dictionary := make([]string, 0, 2*len(names))
+	indices := map[string]uint64{}
+	lookup := func(name string) uint64 {
+		i, ok := indices[name]
+		if !ok {
+			i = uint64(len(dictionary))
+			indices[name] = i
+			dictionary = append(dictionary, name)
+		}
+		return i
+	}
+
+	// Attribute names are added first, so that the dictionary is ordered identically for identical items
+	for _, name := range names {
+		lookup(name)
+	}
+
+	var index []byte
+	for _, name := range names {
+		index = binary.AppendUvarint(index, lookup(name))
+		index = binary.AppendUvarint(index, uint64(len(attrMap[name])))
+		for _, chunk := range attrMap[name] {
+			index = binary.AppendUvarint(index, lookup(chunk))
+		}
+	}
+
+	b, _, err := serialise.ToBytesMany([]any{encodeDictionary(dictionary), index}, serialise.WithSerialisationApproach(approach))
+	return b, err
+}
+
+// encodeDictionary front codes the names, as the length of the prefix shared with the preceding name,
+// followed by the length and bytes of the remaining suffix
+func encodeDictionary(names []string) []byte {
+	var b []byte
+	prev := ""
+	for _, name := range names {
+		shared := 0
+		for shared < min(len(prev), len(name)) && prev[shared] == name[shared] {
+			shared++
+		}
+		b = binary.AppendUvarint(b, uint64(shared))
+		b = binary.AppendUvarint(b, uint64(len(name)-shared))
+		b = append(b, name[shared:]...)
+		prev = name
+	}
+	return b
+}
+
+// decodeDictionary returns the names encoded by encodeDictionary
+func decodeDictionary(b []byte) ([]string, error) {
+	var names []string
+	prev := ""
+	for len(b) > 0 {
+		shared, n := binary.Uvarint(b)
+		if n <= 0 || shared > uint64(len(prev)) {
+			return nil, ErrInvalidDataToDeserialiseAttrDictionary
+		}
+		b = b[n:]
+		size, n := binary.Uvarint(b)
+		if n <= 0 || size > uint64(len(b)-n) {
+			return nil, ErrInvalidDataToDeserialiseAttrDictionary
+		}
+		b = b[n:]
+		prev = prev[:shared] + string(b[:size])
+		b = b[size:]
+		names = append(names, prev)
+	}
+	return names, nil
+}
+
+// unpackAttrDictionary deserialises an attribute map created by packAttrDictionary
+func unpackAttrDictionary(data []byte, approach serialise.Approach, maxAttributes uint32) (map[string][]string, error) {
+
+	v, err := serialise.FromBytesMany(data, approach)
+	if err != nil {
+		return nil, err
+	}
+	if len(v) != 2 {
+		return nil, ErrInvalidDataToDeserialiseAttrDictionary
+	}
+	bDictionary, ok := v[0].([]byte)
+	if !ok {
+		return nil, ErrInvalidDataToDeserialiseAttrDictionary
+	}
+	dictionary, err := decodeDictionary(bDictionary)
+	if err != nil {
+		return nil, err
+	}
+	index, ok := v[1].([]byte)
+	if !ok {
+		return nil, ErrInvalidDataToDeserialiseAttrDictionary
+	}
+
+	next := func() (uint64, error) {
+		n, size := binary.Uvarint(index)
+		if size <= 0 {
+			return 0, ErrInvalidDataToDeserialiseAttrDictionary
+		}
+		index = index[size:]
+		return n, nil
+	}
+	name := func() (string, error) {
+		i, err := next()
+		if err != nil {
+			return "", err
+		}
+		if i >= uint64(len(dictionary)) {
+			return "", ErrInvalidDataToDeserialiseAttrDictionary
+		}
+		return dictionary[i], nil
+	}
+
+	attrMap := map[string][]string{}
+	for len(index) > 0 {
+		// Check before allocating, to defend against pathologically wide items
+		if maxAttributes > 0 && len(attrMap) >= int(maxAttributes) {
+			return nil, ErrTooManyAttributes
+		}
+
+		attr, err := name()
+		if err != nil {
+			return nil, err
+		}
+		n, err := next()
+		if err != nil {
+			return nil, err
+		}
+		// Every chunk requires at least one byte of the index
+		if n == 0 || n > uint64(len(index)) {
+			return nil, ErrInvalidDataToDeserialiseAttrDictionary
+		}
+		chunks := make([]string, n)
+		for i := range chunks {
+			if chunks[i], err = name(); err != nil {
+				return nil, err
+			}
+		}
+		attrMap[attr] = chunks
+	}
+
+	return attrMap, nil
+}
+
+// attributeDictionary returns true if the attribute map was recorded as a dictionary during packing
+func (e envelopeExtensions) attributeDictionary() bool {
+	_, ok := e[extAttrDictionary]
+	return ok
+}
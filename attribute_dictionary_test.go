@@ -0,0 +1,102 @@
+package packer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/gford1000-go/serialise"
+)
+
+func TestWithAttributeNameDictionary(t *testing.T) {
+
+	testPack, testUnpack, provider := testCreateEnv(t)
+
+	item := &Item[Key]{
+		Key:        Key{X: "A", Y: "B"},
+		Attributes: map[string]any{},
+	}
+	for i := range 300 {
+		item.Attributes[fmt.Sprintf("customer.address.line%d", i)] = int64(i)
+	}
+
+	b, l, err := testPack(item, WithAttributeNameDictionary())
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+
+	e, err := testUnpack(b, l)
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+	values, err := e.GetValues(context.TODO(), slices.Collect(maps.Keys(item.Attributes)), provider)
+	if err != nil {
+		t.Fatalf("Unexpected error getting values: %v", err)
+	}
+	if !reflect.DeepEqual(values, item.Attributes) {
+		t.Fatal("Mismatch between packed and unpacked values")
+	}
+}
+
+func TestUnpackAttrDictionary(t *testing.T) {
+
+	approach := serialise.NewMinDataApproachWithVersion(serialise.V1)
+
+	attrMap := map[string][]string{"a": {"x", "y"}, "b": {"z"}, "c": {"a"}}
+
+	b, err := packAttrDictionary(attrMap, approach)
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+	m, err := unpackAttrDictionary(b, approach, 0)
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+	if !reflect.DeepEqual(m, attrMap) {
+		t.Fatalf("Mismatch: expected %v, got %v", attrMap, m)
+	}
+
+	if _, err := unpackAttrDictionary(b, approach, 2); !errors.Is(err, ErrTooManyAttributes) {
+		t.Fatalf("Expected ErrTooManyAttributes, got: %v", err)
+	}
+
+	names := []string{"customer.name", "customer.email", "x"}
+	if d, err := decodeDictionary(encodeDictionary(names)); err != nil || !slices.Equal(d, names) {
+		t.Fatalf("Mismatch: expected %v, got %v (%v)", names, d, err)
+	}
+
+	// Shared prefixes are stored once
+	names, size := nil, 0
+	for i := range 300 {
+		names = append(names, fmt.Sprintf("customer.address.line%03d", i))
+		size += len(names[i])
+	}
+	if n := len(encodeDictionary(names)); n > size/3 {
+		t.Fatalf("Expected front coding to reduce %d bytes of names, got %d bytes", size, n)
+	}
+
+	dictionary := encodeDictionary([]string{"a", "x"})
+
+	tests := []struct {
+		dictionary []byte
+		index      []byte
+	}{
+		{dictionary: dictionary, index: []byte{0, 1, 2}},
+		{dictionary: dictionary, index: []byte{0, 2, 1}},
+		{dictionary: dictionary, index: []byte{0, 0}},
+		{dictionary: dictionary, index: []byte{0x80}},
+		{dictionary: []byte{1, 1, 'a'}, index: []byte{0, 1, 0}},
+		{dictionary: []byte{0, 5, 'a'}, index: []byte{0, 1, 0}},
+	}
+
+	for i, test := range tests {
+		b, _, _ := serialise.ToBytesMany([]any{test.dictionary, test.index}, serialise.WithSerialisationApproach(approach))
+		if _, err := unpackAttrDictionary(b, approach, 0); !errors.Is(err, ErrInvalidDataToDeserialiseAttrDictionary) {
+			t.Fatalf("(%d) Expected ErrInvalidDataToDeserialiseAttrDictionary, got: %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package packer
+
+import "maps"
+
+// WithAttributeGroups places all the chunks of the attributes in each group in the same element, so that attributes
+// that are always read together are held under a single storage key.  The groups map attribute names, after any
+// normalisation (see WithAttributeNameNormaliser), to the name of their group; attributes not in the map are placed
+// wherever they fit.  Pack fails with ErrAttributeGroupTooLarge if the attributes of a group exceed the maximum size.
+func WithAttributeGroups(groups map[string]string) func(o *Options) {
+	groups = maps.Clone(groups)
+	return func(o *Options) {
+		o.attrGroups = groups
+	}
+}
+
+// chunkGroups returns the group of each chunk of the attributes that belong to a group
+func chunkGroups(attrMap map[string][]string, groups map[string]string) map[string]string {
+	if len(groups) == 0 {
+		return nil
+	}
+	m := map[string]string{}
+	for attr, chunks := range attrMap {
+		g, ok := groups[attr]
+		if !ok {
+			continue
+		}
+		for _, chunk := range chunks {
+			m[chunk] = g
+		}
+	}
+	return m
+}
@@ -0,0 +1,79 @@
+package packer
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestWithAttributeGroups(t *testing.T) {
+
+	testPack, testUnpack, provider := testCreateEnv(t)
+
+	item := &Item[Key]{
+		Key:        Key{X: "A", Y: "B"},
+		Attributes: map[string]any{},
+	}
+	for i := range 12 {
+		b := make([]byte, 4*1024)
+		if _, err := rand.Read(b); err != nil {
+			t.Fatalf("Unexpected error creating data: %v", err)
+		}
+		item.Attributes[fmt.Sprintf("attr%02d", i)] = b
+	}
+
+	groups := map[string]string{"attr00": "g", "attr05": "g", "attr11": "g"}
+
+	info, l, err := testPack(item, WithMaximumKBSize(16), WithAttributeGroups(groups))
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+
+	e, err := testUnpack(info, l)
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+
+	// The chunks of the group are all held by one element
+	serialiser, _ := NewKeySerialiser()
+	env, err := openEnvelope(context.TODO(), info, provider, func(string) (IDSerialiser[Key], error) { return serialiser, nil })
+	if err != nil {
+		t.Fatalf("Unexpected error opening envelope: %v", err)
+	}
+	attrMap, err := (&itemPackingDetailsV1[Key]{}).unpackAttrMap(env.bAttrMap, env.approach, env.ext)
+	if err != nil {
+		t.Fatalf("Unexpected error reading attribute map: %v", err)
+	}
+	if len(env.elements) < 4 {
+		t.Fatalf("Expected several elements, got %d", len(env.elements))
+	}
+	holding := map[Key]bool{}
+	for _, k := range env.elements {
+		m, _ := l(context.TODO(), []Key{k})
+		for attr := range groups {
+			if _, ok := m[attrMap[attr][0]]; ok {
+				holding[k] = true
+			}
+		}
+	}
+	if len(holding) != 1 {
+		t.Fatalf("Expected the group to be held by one element, held by %d", len(holding))
+	}
+
+	values, err := e.GetValues(context.TODO(), []string{"attr00", "attr05", "attr11"}, provider)
+	if err != nil {
+		t.Fatalf("Unexpected error getting values: %v", err)
+	}
+	if len(values) != 3 {
+		t.Fatalf("Unexpected values: %d", len(values))
+	}
+
+	for i := range 12 {
+		groups[fmt.Sprintf("attr%02d", i)] = "g"
+	}
+	if _, _, err := testPack(item, WithMaximumKBSize(16), WithAttributeGroups(groups)); !errors.Is(err, ErrAttributeGroupTooLarge) {
+		t.Fatalf("Expected ErrAttributeGroupTooLarge, got: %v", err)
+	}
+}
@@ -0,0 +1,177 @@
+package packer
+
+import (
+	"crypto/hmac"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"sync/atomic"
+)
+
+// AttributeNamer creates the names under which the chunks of attribute values are stored in elements.
+// Names need only be unique within an item; a name already used by the item is rejected, and NewName
+// is called again up to the number of attribute name retries (see WithAttributeNameRetries).
+// Implementations must be safe for concurrent use.
+type AttributeNamer interface {
+	// NewName returns a candidate name for the chunk with the index of the attribute's value.  Chunks
+	// that do not hold attribute values, such as parity data, are requested with an empty attribute.
+	NewName(attr string, chunk int) (string, error)
+}
+
+// WithAttributeNamer sets the AttributeNamer used to name chunks.  If not set, names are random strings
+// created from the attribute name alphabet (see WithAttributeNameSize and WithAttributeNameAlphabet).
+func WithAttributeNamer(namer AttributeNamer) func(o *Options) {
+	return func(o *Options) {
+		o.attrNamer = namer
+	}
+}
+
+// randomNamer is the default AttributeNamer, creating random names from the alphabets of the options
+type randomNamer struct {
+	opts *Options
+}
+
+func (r *randomNamer) NewName(string, int) (string, error) {
+	return r.opts.attributeName(), nil
+}
+
+// NewSequenceAttributeNamer returns an AttributeNamer that names chunks with the prefix followed by
+// a sequence number, which suits stores where short, ordered names are preferred.  The sequence is
+// shared by all items packed with the AttributeNamer.
+func NewSequenceAttributeNamer(prefix string) AttributeNamer {
+	return &sequenceNamer{prefix: prefix}
+}
+
+type sequenceNamer struct {
+	prefix string
+	next   atomic.Uint64
+}
+
+func (s *sequenceNamer) NewName(string, int) (string, error) {
+	return fmt.Sprintf("%s%d", s.prefix, s.next.Add(1)), nil
+}
+
+// ErrHMACNamerKeyIsEmpty raised if NewHMACAttributeNamer is called without a key
+var ErrHMACNamerKeyIsEmpty = errors.New("key must be provided to derive attribute names")
+
+// NewHMACAttributeNamer returns an AttributeNamer deriving names of the specified size from an HMAC-SHA256 of
+// the attribute and chunk index, so that the same attribute is always stored under the same names.
+// As a consequence, the names reveal which items share attributes to anyone comparing stored elements.
+// The key should be unique to the application, and kept secret.
+func NewHMACAttributeNamer(key []byte, size uint8) (AttributeNamer, error) {
+	return NewHMACAttributeNamerWithHash(key, size, SHA256)
+}
+
+// NewHMACAttributeNamerWithHash returns an AttributeNamer as NewHMACAttributeNamer, using the specified hash
+// for the HMAC.  Changing the hash changes every derived name.
+func NewHMACAttributeNamerWithHash(key []byte, size uint8, h HashAlgorithm) (AttributeNamer, error) {
+	if err := h.Available(); err != nil {
+		return nil, err
+	}
+	if len(key) == 0 {
+		return nil, ErrHMACNamerKeyIsEmpty
+	}
+	if size < 2 {
+		return nil, ErrAttributeNameSizeTooSmall
+	}
+	return &hmacNamer{key: append([]byte{}, key...), size: size, hash: h.new()}, nil
+}
+
+type hmacNamer struct {
+	key  []byte
+	size uint8
+	hash func() hash.Hash
+}
+
+func (h *hmacNamer) NewName(attr string, chunk int) (string, error) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], uint64(chunk))
+
+	name := make([]byte, 0, h.size)
+	for counter := byte(0); len(name) < int(h.size); counter++ {
+		m := hmac.New(h.hash, h.key)
+		m.Write([]byte{counter})
+		m.Write(b[:])
+		m.Write([]byte(attr))
+		for _, c := range m.Sum(nil) {
+			if len(name) == int(h.size) {
+				break
+			}
+			name = append(name, defaultAttributeNameAlphabet[int(c)%len(defaultAttributeNameAlphabet)])
+		}
+	}
+	return string(name), nil
+}
+
+// defaultAttributeNameAlphabet is a reduced selection of characters, so that attribute names are readable
+const defaultAttributeNameAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+
+// ErrInvalidAttributeNameAlphabet raised if an attribute name alphabet has fewer than two characters,
+// repeats a character, or includes non-ASCII characters
+var ErrInvalidAttributeNameAlphabet = errors.New("attribute name alphabet must have at least two distinct ASCII characters")
+
+// WithAttributeNameAlphabet sets the characters from which attribute names are created, so that names
+// conform to the naming rules of the store (e.g. lowercase only).  Smaller alphabets make collisions
+// more likely, so the attribute name size may need to be increased (see WithAttributeNameSize).
+func WithAttributeNameAlphabet(alphabet string) func(o *Options) {
+	if err := validateAlphabet(alphabet); err != nil {
+		panic(err)
+	}
+	return func(o *Options) {
+		o.attrNameAlphabet = alphabet
+	}
+}
+
+// WithAdaptiveAttributeNameSize allows the size of random attribute names to grow, one character at a time
+// up to maxSize, whenever a unique name cannot be created within the attribute name retries, rather than
+// Pack failing with ErrUnableToCreateUniqueName.  Once increased, the larger size is used for the remainder
+// of the Pack.  Collisions and increases are reported to the MetricsSink (see WithMetrics).
+// It has no effect if an AttributeNamer is specified.
+func WithAdaptiveAttributeNameSize(maxSize uint8) func(o *Options) {
+	return func(o *Options) {
+		o.attrNameMaxSize = maxSize
+	}
+}
+
+// WithAttributeNameLeadingAlphabet sets the characters from which the first character of attribute names
+// is chosen, for stores that restrict how names may begin (e.g. no leading digits).  The remaining
+// characters are chosen from the attribute name alphabet.
+func WithAttributeNameLeadingAlphabet(alphabet string) func(o *Options) {
+	if err := validateAlphabet(alphabet); err != nil {
+		panic(err)
+	}
+	return func(o *Options) {
+		o.attrNameLeadingAlphabet = alphabet
+	}
+}
+
+// validateAlphabet checks that the alphabet can be used to create attribute names; an empty alphabet selects the default
+func validateAlphabet(alphabet string) error {
+	if len(alphabet) == 0 {
+		return nil
+	}
+	if len(alphabet) < 2 {
+		return ErrInvalidAttributeNameAlphabet
+	}
+	seen := map[byte]bool{}
+	for i := 0; i < len(alphabet); i++ {
+		if alphabet[i] > 127 || seen[alphabet[i]] {
+			return ErrInvalidAttributeNameAlphabet
+		}
+		seen[alphabet[i]] = true
+	}
+	return nil
+}
+
+// attributeName creates a random attribute name using the alphabets of the options
+func (o *Options) attributeName() string {
+	alphabet := o.attrNameAlphabet
+	if len(alphabet) == 0 {
+		alphabet = defaultAttributeNameAlphabet
+	}
+	if len(o.attrNameLeadingAlphabet) == 0 {
+		return createStringFromRange(alphabet, o.attrNameSize)
+	}
+	return createStringFromRange(o.attrNameLeadingAlphabet, 1) + createStringFromRange(alphabet, o.attrNameSize-1)
+}
@@ -0,0 +1,187 @@
+package packer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestWithAttributeNameAlphabet(t *testing.T) {
+
+	testPack, testUnpack, _ := testCreateEnv(t)
+
+	item := &Item[Key]{
+		Key:        Key{X: "A", Y: "B"},
+		Attributes: map[string]any{},
+	}
+	for i := range 50 {
+		item.Attributes[fmt.Sprintf("attr%d", i)] = int64(i)
+	}
+
+	const alphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
+	const leading = "abcdefghijklmnopqrstuvwxyz"
+
+	b, l, err := testPack(item, WithAttributeNameAlphabet(alphabet), WithAttributeNameLeadingAlphabet(leading), WithAttributeNameRetries(5))
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+
+	e, err := testUnpack(b, l)
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+
+	m, err := l(context.TODO(), []Key{e.GetKey()})
+	if err != nil {
+		t.Fatalf("Unexpected error loading: %v", err)
+	}
+	if len(m) != len(item.Attributes) {
+		t.Fatalf("Expected %d chunks, got %d", len(item.Attributes), len(m))
+	}
+	for name := range m {
+		if len(name) != int(defaultAttributeNameSize) {
+			t.Fatalf("Unexpected name length: %s", name)
+		}
+		if !strings.ContainsRune(leading, rune(name[0])) {
+			t.Fatalf("Unexpected leading character: %s", name)
+		}
+		for _, r := range name {
+			if !strings.ContainsRune(alphabet, r) {
+				t.Fatalf("Unexpected character in name: %s", name)
+			}
+		}
+	}
+}
+
+func TestValidateAlphabet(t *testing.T) {
+
+	tests := []struct {
+		alphabet string
+		err      error
+	}{
+		{alphabet: "", err: nil},
+		{alphabet: "ab", err: nil},
+		{alphabet: "a", err: ErrInvalidAttributeNameAlphabet},
+		{alphabet: "aba", err: ErrInvalidAttributeNameAlphabet},
+		{alphabet: "aé", err: ErrInvalidAttributeNameAlphabet},
+	}
+
+	for i, test := range tests {
+		if err := validateAlphabet(test.alphabet); !errors.Is(err, test.err) {
+			t.Fatalf("(%d) Expected %v, got: %v", i, test.err, err)
+		}
+	}
+
+	if err := (&Config{AttributeNameAlphabet: "aa"}).Validate(); !errors.Is(err, ErrInvalidAttributeNameAlphabet) {
+		t.Fatalf("Expected ErrInvalidAttributeNameAlphabet from Config, got: %v", err)
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatal("Expected panic for an invalid alphabet")
+		}
+	}()
+	WithAttributeNameAlphabet("x")
+}
+
+type constantNamer string
+
+func (c constantNamer) NewName(string, int) (string, error) {
+	return string(c), nil
+}
+
+func TestWithAttributeNamer(t *testing.T) {
+
+	testPack, testUnpack, provider := testCreateEnv(t)
+
+	item := &Item[Key]{
+		Key:        Key{X: "A", Y: "B"},
+		Attributes: map[string]any{"a": "x", "b": int64(2), "c": 3.5},
+	}
+
+	loadNames := func(opts ...func(*Options)) []string {
+		b, l, err := testPack(item, opts...)
+		if err != nil {
+			t.Fatalf("Unexpected error packing: %v", err)
+		}
+		e, err := testUnpack(b, l)
+		if err != nil {
+			t.Fatalf("Unexpected error unpacking: %v", err)
+		}
+		values, err := e.GetValues(context.TODO(), []string{"a", "b", "c"}, provider)
+		if err != nil {
+			t.Fatalf("Unexpected error getting values: %v", err)
+		}
+		if !maps.Equal(values, item.Attributes) {
+			t.Fatalf("Mismatch: expected %v, got %v", item.Attributes, values)
+		}
+		m, _ := l(context.TODO(), []Key{e.GetKey()})
+		return slices.Sorted(maps.Keys(m))
+	}
+
+	if names := loadNames(WithAttributeNamer(NewSequenceAttributeNamer("c"))); !slices.Equal(names, []string{"c1", "c2", "c3"}) {
+		t.Fatalf("Unexpected sequence names: %v", names)
+	}
+
+	namer, err := NewHMACAttributeNamer([]byte("secret"), 10)
+	if err != nil {
+		t.Fatalf("Unexpected error creating namer: %v", err)
+	}
+	first := loadNames(WithAttributeNamer(namer))
+	if len(first[0]) != 10 || !slices.Equal(first, loadNames(WithAttributeNamer(namer))) {
+		t.Fatalf("Expected the same HMAC derived names on each Pack: %v", first)
+	}
+
+	if _, _, err := testPack(item, WithAttributeNamer(constantNamer("x")), WithAttributeNameRetries(3)); !errors.Is(err, ErrUnableToCreateUniqueName) {
+		t.Fatalf("Expected ErrUnableToCreateUniqueName, got: %v", err)
+	}
+
+	if _, err := NewHMACAttributeNamer(nil, 10); !errors.Is(err, ErrHMACNamerKeyIsEmpty) {
+		t.Fatalf("Expected ErrHMACNamerKeyIsEmpty, got: %v", err)
+	}
+}
+
+func TestWithAdaptiveAttributeNameSize(t *testing.T) {
+
+	testPack, testUnpack, provider := testCreateEnv(t)
+
+	item := &Item[Key]{
+		Key:        Key{X: "A", Y: "B"},
+		Attributes: map[string]any{},
+	}
+	for i := range 20 {
+		item.Attributes[fmt.Sprintf("attr%d", i)] = int64(i)
+	}
+
+	// Only four names of two characters can be created from the alphabet
+	opts := []func(*Options){WithAttributeNameAlphabet("ab"), WithAttributeNameSize(2), WithAttributeNameRetries(4)}
+
+	if _, _, err := testPack(item, opts...); !errors.Is(err, ErrUnableToCreateUniqueName) {
+		t.Fatalf("Expected ErrUnableToCreateUniqueName, got: %v", err)
+	}
+
+	sink := newTestMetricsSink()
+	b, l, err := testPack(item, append(opts, WithAdaptiveAttributeNameSize(16), WithMetrics(sink))...)
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+	if sink.counters[MetricAttributeNameGrowths] == 0 || sink.counters[MetricAttributeNameCollisions] == 0 {
+		t.Fatalf("Expected collisions and growths to be reported: %v", sink.counters)
+	}
+
+	e, err := testUnpack(b, l)
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+	values, err := e.GetValues(context.TODO(), slices.Collect(maps.Keys(item.Attributes)), provider)
+	if err != nil {
+		t.Fatalf("Unexpected error getting values: %v", err)
+	}
+	if !maps.Equal(values, item.Attributes) {
+		t.Fatalf("Mismatch: expected %v, got %v", item.Attributes, values)
+	}
+}
@@ -0,0 +1,75 @@
+package packer
+
+import (
+	"context"
+	"time"
+)
+
+// KeyExpiryProvider is implemented by EnvelopeKeyProviders that can report when the key that wrapped
+// an encrypted key, as returned by New(), is scheduled to expire or be retired
+type KeyExpiryProvider interface {
+	// WrappingKeyExpiry returns when the wrapping key of the encrypted key expires, or the zero time if it does not
+	WrappingKeyExpiry(ctx context.Context, encryptedKey []byte) (time.Time, error)
+}
+
+// RewrapWriter persists the packed data of an item whose data encryption key has been rewrapped,
+// replacing the packed data previously stored for the item
+type RewrapWriter[T comparable] func(ctx context.Context, key T, info []byte) error
+
+// AutoRewrap allows datasets to self-heal ahead of the decommissioning of wrapping keys, by rewrapping
+// the data encryption key of an item when GetValues finds that its wrapping key expires soon.
+// Rewrapping happens in the background at most once for each EncryptedItem, and does not delay
+// or affect the result of GetValues.
+type AutoRewrap[T comparable] struct {
+	// Within is the period before the expiry of the wrapping key, as reported by the provider passed
+	// to GetValues, during which the key is rewrapped.  The provider must implement KeyExpiryProvider.
+	Within time.Duration
+	// Wrapper wraps the data encryption key with the current wrapping key
+	Wrapper KeyWrapper
+	// Writer persists the rewrapped packed data
+	Writer RewrapWriter[T]
+	// OnError, if not nil, is called with any error encountered during rewrapping
+	OnError func(key T, err error)
+}
+
+// checkRewrap starts a background rewrap of the data encryption key, if requested and the wrapping key expires soon
+func (e *EncryptedItem[T]) checkRewrap(ctx context.Context, provider EnvelopeKeyProvider, key []byte) {
+
+	r := e.autoRewrap
+	if r == nil || r.Wrapper == nil || r.Writer == nil || e.envelope == nil {
+		return
+	}
+	p, ok := provider.(KeyExpiryProvider)
+	if !ok {
+		return
+	}
+
+	expiry, err := p.WrappingKeyExpiry(ctx, e.encryptedKey)
+	if err != nil {
+		r.failed(e.key, err)
+		return
+	}
+	if expiry.IsZero() || time.Until(expiry) > r.Within {
+		return
+	}
+
+	e.rewrapOnce.Do(func() {
+		// The rewrap should complete even if the caller's request has finished
+		ctx := context.WithoutCancel(ctx)
+		go func() {
+			info, err := rewrapEnvelope(ctx, e.version, e.wire, e.envelope, key, r.Wrapper)
+			if err == nil {
+				err = r.Writer(ctx, e.key, info)
+			}
+			if err != nil {
+				r.failed(e.key, err)
+			}
+		}()
+	})
+}
+
+func (r *AutoRewrap[T]) failed(key T, err error) {
+	if r.OnError != nil {
+		r.OnError(key, err)
+	}
+}
@@ -0,0 +1,124 @@
+package packer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gford1000-go/serialise"
+)
+
+// expiringProvider reports that its wrapping key expires at the specified time
+type expiringProvider struct {
+	EnvelopeKeyProvider
+	expiry time.Time
+}
+
+func (p *expiringProvider) WrappingKeyExpiry(ctx context.Context, encryptedKey []byte) (time.Time, error) {
+	return p.expiry, nil
+}
+
+func TestAutoRewrap(t *testing.T) {
+
+	_, _, oldProvider := testCreateEnv(t)
+
+	newProvider, err := NewEnvelopeKeyProvider(&EnvelopeKeyProviderInfo{ID: "new", Key: []byte("21987654321098765432109876543210")},
+		func(EnvelopeKeyID) (EnvelopeKeyProvider, error) { return nil, errors.New("unknown provider id") })
+	if err != nil {
+		t.Fatalf("Unexpected error creating provider: %v", err)
+	}
+
+	serialiser, err := NewKeySerialiser()
+	if err != nil {
+		t.Fatalf("Unexpected error preparing Key serialiser: %v", err)
+	}
+
+	info, data, err := Pack(&Item[Key]{Key: Key{X: "A", Y: "B"}, Attributes: map[string]any{"aaa": "Hello World"}}, &PackParams[Key]{
+		Provider: oldProvider,
+		Creator:  NewKeyCreator(defaultLen),
+		Packer:   serialiser,
+		Approach: serialise.NewMinDataApproachWithVersion(serialise.V1),
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+
+	written := make(chan []byte, 1)
+	rewrap := &AutoRewrap[Key]{
+		Within:  24 * time.Hour,
+		Wrapper: newProvider.(KeyWrapper),
+		Writer: func(ctx context.Context, key Key, info []byte) error {
+			written <- info
+			return nil
+		},
+		OnError: func(key Key, err error) { t.Errorf("Unexpected rewrap error: %v", err) },
+	}
+
+	unpack := func(info []byte, provider EnvelopeKeyProvider) (*EncryptedItem[Key], error) {
+		return Unpack(context.TODO(), info, &UnpackParams[Key]{
+			IDRetriever: func(string) (IDSerialiser[Key], error) { return serialiser, nil },
+			Provider:    provider,
+			AutoRewrap:  rewrap,
+			DataLoader: func(ctx context.Context, keys []Key) (map[string][]byte, error) {
+				m := map[string][]byte{}
+				for _, k := range keys {
+					for n, v := range data[k] {
+						m[n] = v
+					}
+				}
+				return m, nil
+			},
+		})
+	}
+
+	// Wrapping key is not close to expiry
+	e, err := unpack(info, oldProvider)
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+	if _, err := e.GetValues(context.TODO(), []string{"aaa"}, &expiringProvider{oldProvider, time.Now().Add(30 * 24 * time.Hour)}); err != nil {
+		t.Fatalf("Unexpected error getting values: %v", err)
+	}
+	select {
+	case <-written:
+		t.Fatal("Unexpected rewrap")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Wrapping key expires soon, so the key is rewrapped once
+	expiring := &expiringProvider{oldProvider, time.Now().Add(time.Hour)}
+	for range 3 {
+		m, err := e.GetValues(context.TODO(), []string{"aaa"}, expiring)
+		if err != nil {
+			t.Fatalf("Unexpected error getting values: %v", err)
+		}
+		if m["aaa"] != "Hello World" {
+			t.Fatalf("Unexpected value: %v", m["aaa"])
+		}
+	}
+
+	var rewrapped []byte
+	select {
+	case rewrapped = <-written:
+	case <-time.After(time.Second):
+		t.Fatal("Expected rewrap")
+	}
+	select {
+	case <-written:
+		t.Fatal("Unexpected second rewrap")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	e, err = unpack(rewrapped, newProvider)
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking rewrapped data: %v", err)
+	}
+	m, err := e.GetValues(context.TODO(), []string{"aaa"}, newProvider)
+	if err != nil {
+		t.Fatalf("Unexpected error getting values: %v", err)
+	}
+	if m["aaa"] != "Hello World" {
+		t.Fatalf("Unexpected value: %v", m["aaa"])
+	}
+}
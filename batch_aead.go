@@ -0,0 +1,114 @@
+package packer
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"math"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gford1000-go/serialise"
+)
+
+// WithBatchEncryption encrypts the attribute values of each Pack using a pool of cipher instances
+// created once for the data encryption key, with nonces pre-derived from a random prefix and a counter,
+// rather than creating a cipher and drawing a random nonce for every attribute.  This reduces the
+// per-attribute overhead that dominates Pack for items with many small attributes.
+// The packed data is unchanged, so can be unpacked by any release.
+func WithBatchEncryption() func(o *Options) {
+	return func(o *Options) {
+		o.batchEncryption = true
+	}
+}
+
+// ErrBatchNoncesExhausted raised if more values are encrypted with a single data encryption key than
+// the batch nonce counter allows
+var ErrBatchNoncesExhausted = errors.New("batch encryption nonces exhausted")
+
+// batchAEAD seals values with AES-GCM, in the layout of serialise.WithAESGCMEncryption (nonce || ciphertext),
+// using pooled cipher instances and nonces formed from a random 8 byte prefix and a 4 byte counter
+type batchAEAD struct {
+	pool    sync.Pool
+	prefix  [8]byte
+	counter atomic.Uint64
+}
+
+func newBatchAEAD(key []byte) (*batchAEAD, error) {
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	g, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &batchAEAD{}
+	if _, err := rand.Read(b.prefix[:]); err != nil {
+		return nil, err
+	}
+
+	b.pool.New = func() any {
+		g, _ := cipher.NewGCM(block)
+		return g
+	}
+	b.pool.Put(g)
+
+	return b, nil
+}
+
+// nonce returns the next nonce of the sequence
+func (b *batchAEAD) nonce() ([]byte, error) {
+	n := b.counter.Add(1)
+	if n > math.MaxUint32 {
+		return nil, ErrBatchNoncesExhausted
+	}
+	nonce := make([]byte, 12)
+	copy(nonce, b.prefix[:])
+	binary.BigEndian.PutUint32(nonce[8:], uint32(n))
+	return nonce, nil
+}
+
+func (b *batchAEAD) seal(plaintext []byte) ([]byte, error) {
+	nonce, err := b.nonce()
+	if err != nil {
+		return nil, err
+	}
+	g := b.pool.Get().(cipher.AEAD)
+	defer b.pool.Put(g)
+
+	out := make([]byte, len(nonce), len(nonce)+len(plaintext)+g.Overhead())
+	copy(out, nonce)
+	return g.Seal(out, nonce, plaintext, nil), nil
+}
+
+func (b *batchAEAD) open(data []byte) ([]byte, error) {
+	g := b.pool.Get().(cipher.AEAD)
+	defer b.pool.Put(g)
+
+	if len(data) < g.NonceSize() {
+		return nil, ErrCipherAuthenticationFailed
+	}
+	return g.Open(nil, data[:g.NonceSize()], data[g.NonceSize():], nil)
+}
+
+// batchEncryptionOption returns the serialisation option that applies the algorithm with the key,
+// reusing cipher instances across all the values encrypted with the option
+func (c CipherAlgorithm) batchEncryptionOption(key []byte) (func(*serialise.Options), error) {
+	if c != AES256GCM {
+		// Other algorithms already create their cipher once per option
+		return c.encryptionOption(key)
+	}
+	b, err := newBatchAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	return func(o *serialise.Options) {
+		o.Encryptor = b.seal
+		o.Decryptor = b.open
+	}, nil
+}
@@ -0,0 +1,112 @@
+package packer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/gford1000-go/serialise"
+)
+
+func TestBatchAEAD(t *testing.T) {
+
+	key := []byte("01234567890123456789012345678912")
+
+	b, err := newBatchAEAD(key)
+	if err != nil {
+		t.Fatalf("Unexpected error creating batch AEAD: %v", err)
+	}
+
+	// Sealed values are readable by the standard AES-GCM decryptor
+	o := &serialise.Options{}
+	serialise.WithAESGCMEncryption(key)(o)
+
+	nonces := map[string]bool{}
+	for i := range 100 {
+		plaintext := []byte(fmt.Sprintf("value %d", i))
+		sealed, err := b.seal(plaintext)
+		if err != nil {
+			t.Fatalf("Unexpected error sealing: %v", err)
+		}
+		nonce := string(sealed[:12])
+		if nonces[nonce] {
+			t.Fatalf("Nonce reused at %d", i)
+		}
+		nonces[nonce] = true
+
+		opened, err := o.Decryptor(sealed)
+		if err != nil {
+			t.Fatalf("Unexpected error opening: %v", err)
+		}
+		if !bytes.Equal(opened, plaintext) {
+			t.Fatalf("Mismatch: expected %s, got %s", plaintext, opened)
+		}
+	}
+
+	b.counter.Store(1<<32 - 1)
+	if _, err := b.seal([]byte("x")); err != ErrBatchNoncesExhausted {
+		t.Fatalf("Expected ErrBatchNoncesExhausted, got: %v", err)
+	}
+}
+
+func TestWithBatchEncryption(t *testing.T) {
+
+	testPack, testUnpack, provider := testCreateEnv(t)
+
+	item := &Item[Key]{
+		Key:        Key{X: "A", Y: "B"},
+		Attributes: map[string]any{},
+	}
+	for i := range 50 {
+		item.Attributes[fmt.Sprintf("attr%d", i)] = fmt.Sprintf("value %d", i)
+	}
+
+	for _, alg := range []CipherAlgorithm{AES256GCM, AES256CTRHMACSHA256} {
+		b, l, err := testPack(item, WithBatchEncryption(), WithCipherAlgorithm(alg), WithConcurrency(4))
+		if err != nil {
+			t.Fatalf("Unexpected error packing with %v: %v", alg, err)
+		}
+
+		e, err := testUnpack(b, l)
+		if err != nil {
+			t.Fatalf("Unexpected error unpacking with %v: %v", alg, err)
+		}
+
+		values, err := e.GetValues(context.TODO(), slices.Collect(maps.Keys(item.Attributes)), provider)
+		if err != nil {
+			t.Fatalf("Unexpected error getting values with %v: %v", alg, err)
+		}
+		if !maps.Equal(values, item.Attributes) {
+			t.Fatalf("Mismatch with %v: expected %v, got %v", alg, item.Attributes, values)
+		}
+	}
+}
+
+func BenchmarkPack_BatchEncryption(b *testing.B) {
+	packer, _, _ := testCreateEnv(b)
+
+	item := &Item[Key]{
+		Key:        Key{X: "A", Y: "B"},
+		Attributes: map[string]any{},
+	}
+	for i := range 500 {
+		item.Attributes[fmt.Sprintf("attr%d", i)] = int64(i)
+	}
+
+	for _, batch := range []bool{false, true} {
+		opts := []func(*Options){WithConcurrency(4)}
+		if batch {
+			opts = append(opts, WithBatchEncryption())
+		}
+		b.Run(fmt.Sprintf("batch=%v", batch), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, _, err := packer(item, opts...); err != nil {
+					b.Fatalf("Unexpected error: %v", err)
+				}
+			}
+		})
+	}
+}
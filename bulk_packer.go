@@ -0,0 +1,222 @@
+package packer
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gford1000-go/serialise"
+)
+
+// BulkPackerStats records the use of data encryption keys by a BulkPacker
+type BulkPackerStats struct {
+	// Items is the number of items packed
+	Items uint64
+	// Keys is the number of data encryption keys created
+	Keys uint64
+}
+
+// BulkPacker packs many items, reusing each data encryption key for a bounded number of items or
+// period before creating a new one, reducing the cost of key creation (such as KMS GenerateDataKey
+// requests) during bulk ingestion.  Each packed item records the key used to encrypt it, so items
+// remain independently unpackable, and its use of the key within the envelope (see GetKeyReuse).
+// A BulkPacker is safe for concurrent use.
+type BulkPacker[T comparable] struct {
+	params   PackParams[T]
+	opts     []func(*Options)
+	maxItems uint64
+	maxAge   time.Duration
+	now      func() time.Time
+
+	lck          sync.Mutex
+	encryptedKey []byte
+	key          []byte
+	created      time.Time
+	uses         uint64
+	stats        BulkPackerStats
+}
+
+// KeyReuse describes the use of a data encryption key that was shared by items packed by a BulkPacker
+type KeyReuse struct {
+	// Use is the position of the item amongst the items packed with the key, starting from 1
+	Use uint64
+	// Created is when the BulkPacker created the key
+	Created time.Time
+}
+
+// ErrBulkPackerLimitsRequired raised if neither the item nor duration limit is set for a BulkPacker
+var ErrBulkPackerLimitsRequired = errors.New("bulk packer must limit the reuse of each key by number of items or duration")
+
+// NewBulkPacker creates a BulkPacker, which uses each data encryption key created by the params Provider
+// for at most maxItems items, and for no longer than maxAge.  A zero value disables the respective limit,
+// but at least one must be set.  The options are applied to every call to Pack, and any EncryptionContext
+// of the params is bound to every key, which the params Provider must then support.
+func NewBulkPacker[T comparable](params *PackParams[T], maxItems uint64, maxAge time.Duration, opts ...func(*Options)) (*BulkPacker[T], error) {
+	if params == nil {
+		return nil, ErrPackNoParams
+	}
+	if err := params.validate(); err != nil {
+		return nil, err
+	}
+	if maxItems == 0 && maxAge <= 0 {
+		return nil, ErrBulkPackerLimitsRequired
+	}
+
+	return &BulkPacker[T]{
+		params:   *params,
+		opts:     opts,
+		maxItems: maxItems,
+		maxAge:   maxAge,
+		now:      time.Now,
+	}, nil
+}
+
+// Pack serialises the item as Pack, using the current data encryption key
+func (b *BulkPacker[T]) Pack(item *Item[T]) ([]byte, map[T]map[string][]byte, error) {
+	return b.PackWithContext(context.Background(), item)
+}
+
+// PackWithContext serialises the item as PackWithContext, using the current data encryption key
+func (b *BulkPacker[T]) PackWithContext(ctx context.Context, item *Item[T]) ([]byte, map[T]map[string][]byte, error) {
+
+	if item == nil || len(item.Attributes) == 0 {
+		return nil, nil, ErrPackNoAttributes
+	}
+
+	encryptedKey, key, reuse, err := b.next(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	params := b.params
+	params.Provider = &bulkKeyProvider[T]{b: b, encryptedKey: encryptedKey, key: key}
+
+	return packItem(ctx, item, &params, append(slices.Clone(b.opts), withKeyReuse(reuse))...)
+}
+
+// withKeyReuse records the reuse of the data encryption key in the envelope
+func withKeyReuse(reuse KeyReuse) func(o *Options) {
+	return func(o *Options) {
+		o.keyReuse = &reuse
+	}
+}
+
+// Stats returns the number of items packed and keys created so far
+func (b *BulkPacker[T]) Stats() BulkPackerStats {
+	b.lck.Lock()
+	defer b.lck.Unlock()
+	return b.stats
+}
+
+// next returns the key to use for the next item, creating a new key if the limits of the current key are reached
+func (b *BulkPacker[T]) next(ctx context.Context) ([]byte, []byte, KeyReuse, error) {
+
+	b.lck.Lock()
+	defer b.lck.Unlock()
+
+	expired := b.key == nil ||
+		(b.maxItems > 0 && b.uses >= b.maxItems) ||
+		(b.maxAge > 0 && b.now().Sub(b.created) >= b.maxAge)
+
+	if expired {
+		encryptedKey, key, err := newDataKey(ctx, &b.params)
+		if err != nil {
+			return nil, nil, KeyReuse{}, err
+		}
+		b.encryptedKey, b.key, b.created, b.uses = encryptedKey, key, b.now(), 0
+		b.stats.Keys++
+	}
+
+	b.uses++
+	b.stats.Items++
+
+	return b.encryptedKey, b.key, KeyReuse{Use: b.uses, Created: b.created}, nil
+}
+
+// bulkKeyProvider vends the key of the BulkPacker selected for an item, which is already bound to any
+// encryption context of the params
+type bulkKeyProvider[T comparable] struct {
+	b            *BulkPacker[T]
+	encryptedKey []byte
+	key          []byte
+}
+
+func (p *bulkKeyProvider[T]) ID() EnvelopeKeyID {
+	return p.b.params.Provider.ID()
+}
+
+func (p *bulkKeyProvider[T]) New() ([]byte, []byte, error) {
+	return p.encryptedKey, p.key, nil
+}
+
+func (p *bulkKeyProvider[T]) NewWithContext(context.Context) ([]byte, []byte, error) {
+	return p.encryptedKey, p.key, nil
+}
+
+func (p *bulkKeyProvider[T]) NewWithEncryptionContext(map[string]string) ([]byte, []byte, error) {
+	return p.encryptedKey, p.key, nil
+}
+
+func (p *bulkKeyProvider[T]) Decrypt(ctx context.Context, encryptedKey []byte) ([]byte, error) {
+	return p.b.params.Provider.Decrypt(ctx, encryptedKey)
+}
+
+// ErrNoKeyReuse raised if the envelope does not record the reuse of its data encryption key
+var ErrNoKeyReuse = errors.New("packed data was not packed by a bulk packer")
+
+// GetKeyReuse returns the use of the data encryption key recorded in the envelope of data packed by a BulkPacker,
+// so that the items sharing a key can be identified, for example if the key is compromised.  An envelope split
+// across continuation elements must first be joined (see JoinEnvelope).
+func GetKeyReuse[T comparable](ctx context.Context, data []byte, provider EnvelopeKeyProvider, idRetriever GetIDSerialiser[T]) (KeyReuse, error) {
+
+	if len(data) == 0 {
+		return KeyReuse{}, ErrUnpackNoData
+	}
+	if provider == nil {
+		return KeyReuse{}, ErrProviderIsNil
+	}
+	if idRetriever == nil {
+		return KeyReuse{}, ErrIDRetrieverIsNil
+	}
+
+	env, err := openEnvelope(ctx, data, provider, idRetriever)
+	if err != nil {
+		return KeyReuse{}, err
+	}
+
+	b, ok := env.ext[extKeyReuse]
+	if !ok {
+		return KeyReuse{}, ErrNoKeyReuse
+	}
+	return unpackKeyReuse(b, env.approach)
+}
+
+func packKeyReuse(reuse *KeyReuse, approach serialise.Approach) ([]byte, error) {
+	b, _, err := serialise.ToBytesMany([]any{int64(reuse.Use), reuse.Created.UnixNano()}, serialise.WithSerialisationApproach(approach))
+	return b, err
+}
+
+// ErrInvalidDataToDeserialiseKeyReuse raised if the recorded key reuse cannot be deserialised
+var ErrInvalidDataToDeserialiseKeyReuse = errors.New("invalid data, cannot deserialise key reuse")
+
+func unpackKeyReuse(data []byte, approach serialise.Approach) (KeyReuse, error) {
+
+	v, err := serialise.FromBytesMany(data, approach)
+	if err != nil {
+		return KeyReuse{}, err
+	}
+	if len(v) != 2 {
+		return KeyReuse{}, ErrInvalidDataToDeserialiseKeyReuse
+	}
+	use, ok := v[0].(int64)
+	if !ok {
+		return KeyReuse{}, ErrInvalidDataToDeserialiseKeyReuse
+	}
+	created, ok := v[1].(int64)
+	if !ok {
+		return KeyReuse{}, ErrInvalidDataToDeserialiseKeyReuse
+	}
+	return KeyReuse{Use: uint64(use), Created: time.Unix(0, created)}, nil
+}
@@ -0,0 +1,193 @@
+package packer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gford1000-go/serialise"
+)
+
+func TestBulkPacker(t *testing.T) {
+
+	_, unpacker, provider := testCreateEnv(t)
+
+	serialiser, err := NewKeySerialiser()
+	if err != nil {
+		t.Fatalf("Unexpected error preparing Key serialiser: %v", err)
+	}
+
+	params := &PackParams[Key]{
+		Provider: provider,
+		Creator:  NewKeyCreator(defaultLen),
+		Packer:   serialiser,
+		Approach: serialise.NewMinDataApproachWithVersion(serialise.V1),
+	}
+
+	if _, err := NewBulkPacker(params, 0, 0); !errors.Is(err, ErrBulkPackerLimitsRequired) {
+		t.Fatalf("Unexpected error: expected: %v, got: %v", ErrBulkPackerLimitsRequired, err)
+	}
+
+	b, err := NewBulkPacker(params, 2, time.Hour)
+	if err != nil {
+		t.Fatalf("Unexpected error creating BulkPacker: %v", err)
+	}
+
+	keys := [][]byte{}
+	for i := range 5 {
+		info, data, err := b.Pack(&Item[Key]{
+			Key:        Key{X: fmt.Sprintf("%d", i), Y: "B"},
+			Attributes: map[string]any{"aaa": fmt.Sprintf("Value %d", i)},
+		})
+		if err != nil {
+			t.Fatalf("(%d) Unexpected error packing: %v", i, err)
+		}
+
+		e, err := unpacker(info, func(ctx context.Context, keys []Key) (map[string][]byte, error) {
+			m := map[string][]byte{}
+			for _, k := range keys {
+				for n, v := range data[k] {
+					m[n] = v
+				}
+			}
+			return m, nil
+		})
+		if err != nil {
+			t.Fatalf("(%d) Unexpected error unpacking: %v", i, err)
+		}
+		m, err := e.GetValues(context.TODO(), []string{"aaa"}, provider)
+		if err != nil {
+			t.Fatalf("(%d) Unexpected error getting values: %v", i, err)
+		}
+		if m["aaa"] != fmt.Sprintf("Value %d", i) {
+			t.Fatalf("(%d) Unexpected value: %v", i, m["aaa"])
+		}
+		keys = append(keys, e.encryptedKey)
+
+		reuse, err := GetKeyReuse(context.TODO(), info, provider, func(string) (IDSerialiser[Key], error) { return serialiser, nil })
+		if err != nil {
+			t.Fatalf("(%d) Unexpected error getting key reuse: %v", i, err)
+		}
+		if reuse.Use != uint64(i%2+1) || reuse.Created.IsZero() {
+			t.Fatalf("(%d) Unexpected key reuse: %+v", i, reuse)
+		}
+	}
+
+	if !bytes.Equal(keys[0], keys[1]) || bytes.Equal(keys[1], keys[2]) || !bytes.Equal(keys[2], keys[3]) || bytes.Equal(keys[3], keys[4]) {
+		t.Fatal("Unexpected reuse of data encryption keys")
+	}
+	if s := b.Stats(); s.Items != 5 || s.Keys != 3 {
+		t.Fatalf("Unexpected stats: %+v", s)
+	}
+
+	now := time.Now()
+	b, err = NewBulkPacker(params, 0, time.Minute)
+	if err != nil {
+		t.Fatalf("Unexpected error creating BulkPacker: %v", err)
+	}
+	b.now = func() time.Time { return now }
+
+	for i := range 4 {
+		if i == 2 {
+			now = now.Add(time.Minute)
+		}
+		if _, _, err := b.Pack(&Item[Key]{Key: Key{X: "A", Y: "B"}, Attributes: map[string]any{"aaa": int64(i)}}); err != nil {
+			t.Fatalf("(%d) Unexpected error packing: %v", i, err)
+		}
+	}
+	if s := b.Stats(); s.Items != 4 || s.Keys != 2 {
+		t.Fatalf("Unexpected stats: %+v", s)
+	}
+
+	info, _, err := Pack(&Item[Key]{Key: Key{X: "A", Y: "B"}, Attributes: map[string]any{"aaa": "x"}}, params)
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+	if _, err := GetKeyReuse(context.TODO(), info, provider, func(string) (IDSerialiser[Key], error) { return serialiser, nil }); !errors.Is(err, ErrNoKeyReuse) {
+		t.Fatalf("Unexpected error: expected: %v, got: %v", ErrNoKeyReuse, err)
+	}
+}
+
+func TestBulkPacker_1(t *testing.T) {
+
+	_, _, provider := testCreateEnv(t)
+
+	serialiser, err := NewKeySerialiser()
+	if err != nil {
+		t.Fatalf("Unexpected error preparing Key serialiser: %v", err)
+	}
+
+	ec := map[string]string{"tenant": "acme"}
+
+	params := &PackParams[Key]{
+		Provider:          provider,
+		Creator:           NewKeyCreator(defaultLen),
+		Packer:            serialiser,
+		Approach:          serialise.NewMinDataApproachWithVersion(serialise.V1),
+		EncryptionContext: ec,
+	}
+
+	b, err := NewBulkPacker(params, 2, 0)
+	if err != nil {
+		t.Fatalf("Unexpected error creating BulkPacker: %v", err)
+	}
+
+	// Every key is bound to the encryption context by the wrapped provider
+	for i := range 3 {
+		info, data, err := b.Pack(&Item[Key]{
+			Key:        Key{X: fmt.Sprintf("%d", i), Y: "B"},
+			Attributes: map[string]any{"aaa": fmt.Sprintf("Value %d", i)},
+		})
+		if err != nil {
+			t.Fatalf("(%d) Unexpected error packing: %v", i, err)
+		}
+
+		unpack := func(ec map[string]string) (*EncryptedItem[Key], error) {
+			return Unpack(context.TODO(), info, &UnpackParams[Key]{
+				IDRetriever:       func(string) (IDSerialiser[Key], error) { return serialiser, nil },
+				Provider:          provider,
+				EncryptionContext: ec,
+				DataLoader: func(ctx context.Context, keys []Key) (map[string][]byte, error) {
+					m := map[string][]byte{}
+					for _, k := range keys {
+						for n, v := range data[k] {
+							m[n] = v
+						}
+					}
+					return m, nil
+				},
+			})
+		}
+
+		e, err := unpack(ec)
+		if err != nil {
+			t.Fatalf("(%d) Unexpected error unpacking: %v", i, err)
+		}
+		m, err := e.GetValues(context.TODO(), []string{"aaa"}, provider)
+		if err != nil {
+			t.Fatalf("(%d) Unexpected error getting values: %v", i, err)
+		}
+		if m["aaa"] != fmt.Sprintf("Value %d", i) {
+			t.Fatalf("(%d) Unexpected value: %v", i, m["aaa"])
+		}
+
+		if _, err := unpack(nil); !errors.Is(err, ErrKeyProviderDecryptError) {
+			t.Fatalf("(%d) Unexpected error: expected: %v, got: %v", i, ErrKeyProviderDecryptError, err)
+		}
+	}
+	if s := b.Stats(); s.Items != 3 || s.Keys != 2 {
+		t.Fatalf("Unexpected stats: %+v", s)
+	}
+
+	params.Provider = &countingProvider{EnvelopeKeyProvider: provider}
+	b, err = NewBulkPacker(params, 2, 0)
+	if err != nil {
+		t.Fatalf("Unexpected error creating BulkPacker: %v", err)
+	}
+	if _, _, err := b.Pack(&Item[Key]{Key: Key{X: "A", Y: "B"}, Attributes: map[string]any{"aaa": "x"}}); !errors.Is(err, ErrProviderDoesNotSupportEncryptionContext) {
+		t.Fatalf("Unexpected error: expected: %v, got: %v", ErrProviderDoesNotSupportEncryptionContext, err)
+	}
+}
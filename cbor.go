@@ -0,0 +1,222 @@
+package packer
+
+import (
+	"encoding/binary"
+	"errors"
+	"sort"
+)
+
+// CBORWireFormat encodes the envelope as a CBOR (RFC 8949) array of its fields, byte strings for binary
+// fields and text strings for names, so that it is compact and readily parsed by constrained consumers.
+// The attribute map is also encoded in CBOR, as a map of each attribute name to the array of its chunk
+// names, unless an attribute dictionary is used (see WithAttributeNameDictionary).
+const CBORWireFormat WireFormat = 2
+
+// ErrInvalidCBOR raised if CBOR encoded data is malformed, or not of the expected structure
+var ErrInvalidCBOR = errors.New("invalid CBOR data")
+
+// CBOR major types used by the envelope and attribute map
+const (
+	cborBytes byte = 2
+	cborText  byte = 3
+	cborArray byte = 4
+	cborMap   byte = 5
+)
+
+// cborAppendHead appends the head of a CBOR data item of the major type, with the argument n
+func cborAppendHead(b []byte, major byte, n uint64) []byte {
+	m := major << 5
+	switch {
+	case n < 24:
+		return append(b, m|byte(n))
+	case n <= 0xff:
+		return append(b, m|24, byte(n))
+	case n <= 0xffff:
+		return binary.BigEndian.AppendUint16(append(b, m|25), uint16(n))
+	case n <= 0xffffffff:
+		return binary.BigEndian.AppendUint32(append(b, m|26), uint32(n))
+	default:
+		return binary.BigEndian.AppendUint64(append(b, m|27), n)
+	}
+}
+
+// cborAppendString appends the data as a CBOR byte or text string
+func cborAppendString(b []byte, major byte, data []byte) []byte {
+	return append(cborAppendHead(b, major, uint64(len(data))), data...)
+}
+
+// cborDecoder reads successive CBOR data items of definite length
+type cborDecoder struct {
+	data []byte
+}
+
+// head reads the head of the next data item, which must be of the major type, returning its argument
+func (c *cborDecoder) head(major byte) (uint64, error) {
+	if len(c.data) == 0 || c.data[0]>>5 != major {
+		return 0, ErrInvalidCBOR
+	}
+	info := c.data[0] & 0x1f
+	c.data = c.data[1:]
+
+	var size int
+	switch {
+	case info < 24:
+		return uint64(info), nil
+	case info == 24:
+		size = 1
+	case info == 25:
+		size = 2
+	case info == 26:
+		size = 4
+	case info == 27:
+		size = 8
+	default:
+		// Indefinite lengths are never produced, so are not accepted
+		return 0, ErrInvalidCBOR
+	}
+	if len(c.data) < size {
+		return 0, ErrInvalidCBOR
+	}
+	var b [8]byte
+	copy(b[8-size:], c.data[:size])
+	c.data = c.data[size:]
+	return binary.BigEndian.Uint64(b[:]), nil
+}
+
+// string reads the next data item, which must be a byte or text string of the major type
+func (c *cborDecoder) string(major byte) ([]byte, error) {
+	n, err := c.head(major)
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(c.data)) {
+		return nil, ErrInvalidCBOR
+	}
+	s := c.data[:n]
+	c.data = c.data[n:]
+	return s, nil
+}
+
+// length reads the head of the next array or map, returning its number of entries, which is checked against
+// the remaining data so that hostile lengths cannot cause large allocations
+func (c *cborDecoder) length(major byte) (int, error) {
+	n, err := c.head(major)
+	if err != nil {
+		return 0, err
+	}
+	if n > uint64(len(c.data)) {
+		return 0, ErrInvalidCBOR
+	}
+	return int(n), nil
+}
+
+// encodeCBOREnvelope appends the finalised data to b as a CBOR array
+func encodeCBOREnvelope(b []byte, finalisedData []any) ([]byte, error) {
+	b = cborAppendHead(b, cborArray, uint64(len(finalisedData)))
+	for _, v := range finalisedData {
+		switch v := v.(type) {
+		case []byte:
+			b = cborAppendString(b, cborBytes, v)
+		case string:
+			b = cborAppendString(b, cborText, []byte(v))
+		default:
+			return nil, ErrInvalidDataToUnpack
+		}
+	}
+	return b, nil
+}
+
+// decodeCBOREnvelope is the inverse of encodeCBOREnvelope
+func decodeCBOREnvelope(data []byte) ([]any, error) {
+	c := &cborDecoder{data: data}
+	n, err := c.length(cborArray)
+	if err != nil {
+		return nil, err
+	}
+	// A digest, MAC and signature are optional, and only present if requested during Pack
+	if n < 4 || n > finalisedDataMaxFields {
+		return nil, ErrInvalidDataToUnpack
+	}
+
+	finalisedData := make([]any, n)
+	for i := range n {
+		major := cborBytes
+		if i == 1 || i == 2 {
+			major = cborText
+		}
+		s, err := c.string(major)
+		if err != nil {
+			return nil, err
+		}
+		if major == cborText {
+			finalisedData[i] = string(s)
+		} else {
+			finalisedData[i] = s
+		}
+	}
+	if len(c.data) > 0 {
+		return nil, ErrInvalidCBOR
+	}
+	return finalisedData, nil
+}
+
+// encodeCBORAttrMap encodes the attribute map as a CBOR map of each name to the array of its chunk names,
+// in name order, so that identical items produce identical encodings
+func encodeCBORAttrMap(attrMap map[string][]string) []byte {
+	names := make([]string, 0, len(attrMap))
+	for k := range attrMap {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	b := cborAppendHead(nil, cborMap, uint64(len(names)))
+	for _, k := range names {
+		b = cborAppendString(b, cborText, []byte(k))
+		b = cborAppendHead(b, cborArray, uint64(len(attrMap[k])))
+		for _, chunk := range attrMap[k] {
+			b = cborAppendString(b, cborText, []byte(chunk))
+		}
+	}
+	return b
+}
+
+// decodeCBORAttrMap is the inverse of encodeCBORAttrMap, failing if there are more than maxAttributes, if not zero
+func decodeCBORAttrMap(data []byte, maxAttributes uint32) (map[string][]string, error) {
+	c := &cborDecoder{data: data}
+	n, err := c.length(cborMap)
+	if err != nil {
+		return nil, err
+	}
+	// Check before allocating, to defend against pathologically wide items
+	if maxAttributes > 0 && n > int(maxAttributes) {
+		return nil, ErrTooManyAttributes
+	}
+
+	attrMap := make(map[string][]string, n)
+	for range n {
+		name, err := c.string(cborText)
+		if err != nil {
+			return nil, err
+		}
+		count, err := c.length(cborArray)
+		if err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			return nil, ErrInvalidDataToDeserialiseAttrMap
+		}
+		chunks := make([]string, count)
+		for i := range chunks {
+			s, err := c.string(cborText)
+			if err != nil {
+				return nil, err
+			}
+			chunks[i] = string(s)
+		}
+		attrMap[string(name)] = chunks
+	}
+	if len(c.data) > 0 {
+		return nil, ErrInvalidCBOR
+	}
+	return attrMap, nil
+}
@@ -0,0 +1,99 @@
+package packer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"testing"
+)
+
+func TestCBORWireFormat(t *testing.T) {
+
+	testPack, testUnpack, provider := testCreateEnv(t)
+
+	item := &Item[Key]{
+		Key:        Key{X: "A", Y: "B"},
+		Attributes: map[string]any{},
+	}
+	for i := range 50 {
+		item.Attributes[fmt.Sprintf("attr%d", i)] = int64(i)
+	}
+
+	for _, opts := range [][]func(*Options){
+		{WithPackingVersion(V2), WithWireFormat(CBORWireFormat)},
+		{WithPackingVersion(V2), WithWireFormat(CBORWireFormat), WithAttributeNameDictionary(), WithDigest([]byte("digest key"))},
+	} {
+		b, l, err := testPack(item, opts...)
+		if err != nil {
+			t.Fatalf("Unexpected error packing: %v", err)
+		}
+		if !bytes.HasPrefix(b, []byte{0xff, 0x02, wireFormatMarker, byte(CBORWireFormat), cborArray<<5 | 4}) &&
+			!bytes.HasPrefix(b, []byte{0xff, 0x02, wireFormatMarker, byte(CBORWireFormat), cborArray<<5 | 5}) {
+			t.Fatalf("Unexpected prefix: %x", b[:5])
+		}
+
+		e, err := testUnpack(b, l)
+		if err != nil {
+			t.Fatalf("Unexpected error unpacking: %v", err)
+		}
+		values, err := e.GetAllValues(context.TODO(), provider)
+		if err != nil {
+			t.Fatalf("Unexpected error getting values: %v", err)
+		}
+		if !maps.Equal(values, item.Attributes) {
+			t.Fatalf("Mismatch: expected %v, got %v", item.Attributes, values)
+		}
+	}
+}
+
+func TestCBORAttrMap(t *testing.T) {
+
+	b := encodeCBORAttrMap(map[string][]string{"a": {"x"}})
+	if !bytes.Equal(b, []byte{0xa1, 0x61, 'a', 0x81, 0x61, 'x'}) {
+		t.Fatalf("Unexpected encoding: %x", b)
+	}
+
+	attrMap := map[string][]string{"a": {"x", "y"}, "b": {"z"}}
+	m, err := decodeCBORAttrMap(encodeCBORAttrMap(attrMap), 0)
+	if err != nil || !maps.EqualFunc(m, attrMap, slices.Equal) {
+		t.Fatalf("Mismatch: expected %v, got %v, %v", attrMap, m, err)
+	}
+
+	if _, err := decodeCBORAttrMap(encodeCBORAttrMap(attrMap), 1); !errors.Is(err, ErrTooManyAttributes) {
+		t.Fatalf("Expected ErrTooManyAttributes, got: %v", err)
+	}
+
+	for _, data := range [][]byte{{}, {0xa1, 0x61}, {0xbf}, {0xa1, 0x61, 'a', 0x80}, {0xa1, 0x61, 'a', 0x81, 0x61, 'x', 0x00}, {0xbb, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}} {
+		if _, err := decodeCBORAttrMap(data, 0); err == nil {
+			t.Fatalf("Expected an error decoding %x", data)
+		}
+	}
+}
+
+func TestCBORAppendHead(t *testing.T) {
+
+	tests := []struct {
+		n        uint64
+		expected []byte
+	}{
+		{n: 23, expected: []byte{0x57}},
+		{n: 24, expected: []byte{0x58, 24}},
+		{n: 256, expected: []byte{0x59, 0x01, 0x00}},
+		{n: 65536, expected: []byte{0x5a, 0x00, 0x01, 0x00, 0x00}},
+		{n: 1 << 32, expected: []byte{0x5b, 0, 0, 0, 1, 0, 0, 0, 0}},
+	}
+
+	for i, test := range tests {
+		b := cborAppendHead(nil, cborBytes, test.n)
+		if !bytes.Equal(b, test.expected) {
+			t.Fatalf("(%d) Expected %x, got %x", i, test.expected, b)
+		}
+		n, err := (&cborDecoder{data: b}).head(cborBytes)
+		if err != nil || n != test.n {
+			t.Fatalf("(%d) Expected %d, got %d, %v", i, test.n, n, err)
+		}
+	}
+}
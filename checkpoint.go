@@ -0,0 +1,304 @@
+package packer
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"maps"
+	"sort"
+	"sync"
+
+	"github.com/gford1000-go/serialise"
+)
+
+// PackCheckpoint records the attributes already serialised by an interrupted Pack, so that a restarted
+// process can resume without repeating their serialisation, compression and encryption.
+// The attribute values are held encrypted by the data encryption key, which is itself only held
+// in its encrypted form, so checkpoints can be persisted alongside other packed data.
+type PackCheckpoint struct {
+	// EncryptedKey is the data encryption key, as encrypted by the provider
+	EncryptedKey []byte
+	// Compression applied to the attribute values
+	Compression Compression
+	// Cipher used to encrypt the attribute values
+	Cipher CipherAlgorithm
+	// Attributes holds the serialised value of each completed attribute
+	Attributes map[string][]byte
+}
+
+// PackCheckpointFunc receives checkpoints during Pack, which should be persisted if Pack is to be resumable.
+// Calls are never concurrent; an error causes Pack to fail.
+type PackCheckpointFunc func(*PackCheckpoint) error
+
+// ErrCheckpointMismatch raised if a checkpoint is resumed with different data or options
+var ErrCheckpointMismatch = errors.New("checkpoint does not match the data or options being processed")
+
+// ErrInvalidCheckpoint raised if a checkpoint cannot be deserialised
+var ErrInvalidCheckpoint = errors.New("invalid data, cannot deserialise checkpoint")
+
+// WithPackCheckpoint passes a PackCheckpoint to save after every n attributes are serialised (a value
+// of zero checkpoints after each attribute).  If resume is not nil, Pack continues from the checkpoint,
+// reusing its data encryption key and only serialising those attributes that it does not hold.
+// The item and options must be the same as those of the Pack that created the checkpoint.
+func WithPackCheckpoint(resume *PackCheckpoint, n int, save PackCheckpointFunc) func(o *Options) {
+	return func(o *Options) {
+		o.checkpoint = &packCheckpointer{resume: resume, every: n, save: save}
+	}
+}
+
+// packCheckpointer accumulates completed attributes, serialising calls to the save function
+type packCheckpointer struct {
+	resume  *PackCheckpoint
+	every   int
+	save    PackCheckpointFunc
+	mu      sync.Mutex
+	current PackCheckpoint
+	pending int
+}
+
+// start initialises the checkpoint for the data encryption key, returning the values of any
+// attributes being resumed
+func (p *packCheckpointer) start(encryptedKey []byte, o *Options) map[string][]byte {
+	if p == nil {
+		return nil
+	}
+	p.current = PackCheckpoint{
+		EncryptedKey: encryptedKey,
+		Compression:  o.compression,
+		Cipher:       o.cipherAlgorithm,
+		Attributes:   map[string][]byte{},
+	}
+	if p.resume == nil {
+		return nil
+	}
+	maps.Copy(p.current.Attributes, p.resume.Attributes)
+	return p.resume.Attributes
+}
+
+// completed records the serialised attribute, saving a checkpoint if sufficient attributes have completed
+func (p *packCheckpointer) completed(name string, b []byte) error {
+	if p == nil || p.save == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.current.Attributes[name] = b
+	p.pending++
+	if p.pending < p.every {
+		return nil
+	}
+	p.pending = 0
+	return p.save(p.snapshot())
+}
+
+func (p *packCheckpointer) snapshot() *PackCheckpoint {
+	c := p.current
+	c.Attributes = maps.Clone(p.current.Attributes)
+	return &c
+}
+
+// resumeDataKey returns the data encryption key recorded in the checkpoint, which must have been
+// created with the same compression and cipher
+func resumeDataKey[T comparable](ctx context.Context, c *PackCheckpoint, params *PackParams[T], o *Options, names map[string]any) ([]byte, []byte, error) {
+	if c.Compression != o.compression || c.Cipher != o.cipherAlgorithm {
+		return nil, nil, ErrCheckpointMismatch
+	}
+	for name := range c.Attributes {
+		if _, ok := names[name]; !ok {
+			return nil, nil, ErrCheckpointMismatch
+		}
+	}
+
+	if len(params.EncryptionContext) > 0 {
+		ctx = ContextWithEncryptionContext(ctx, params.EncryptionContext)
+	}
+	encKey, err := params.Provider.Decrypt(ctx, c.EncryptedKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return c.EncryptedKey, encKey, nil
+}
+
+// MarshalBinary serialises the checkpoint, so that it can be persisted
+func (c *PackCheckpoint) MarshalBinary() ([]byte, error) {
+	items := []any{c.EncryptedKey, int8(c.Compression), int8(c.Cipher)}
+	items = appendSortedData(items, c.Attributes)
+	b, _, err := serialise.ToBytesMany(items, serialise.WithSerialisationApproach(serialise.NewMinDataApproachWithVersion(serialise.V1)))
+	return b, err
+}
+
+// UnmarshalBinary is the inverse of MarshalBinary
+func (c *PackCheckpoint) UnmarshalBinary(data []byte) error {
+	v, err := serialise.FromBytesMany(data, serialise.NewMinDataApproachWithVersion(serialise.V1))
+	if err != nil {
+		return err
+	}
+	if len(v) < 3 {
+		return ErrInvalidCheckpoint
+	}
+	encryptedKey, ok := v[0].([]byte)
+	if !ok {
+		return ErrInvalidCheckpoint
+	}
+	compression, ok := v[1].(int8)
+	if !ok {
+		return ErrInvalidCheckpoint
+	}
+	cipher, ok := v[2].(int8)
+	if !ok {
+		return ErrInvalidCheckpoint
+	}
+	attrs, err := sortedData(v[3:])
+	if err != nil {
+		return err
+	}
+
+	*c = PackCheckpoint{
+		EncryptedKey: encryptedKey,
+		Compression:  Compression(compression),
+		Cipher:       CipherAlgorithm(cipher),
+		Attributes:   attrs,
+	}
+	return nil
+}
+
+// UnpackCheckpoint records the elements already loaded by an interrupted Unpack, so that a restarted
+// process can resume loading rather than starting again.  The loaded data is held as it was returned
+// by the DataLoader, so remains encrypted.
+type UnpackCheckpoint struct {
+	// Pack identifies the packed data being unpacked
+	Pack []byte
+	// Elements is the number of elements loaded, in the order they are held by the packed data
+	Elements int
+	// Loaded is the combined data returned by the DataLoader for those elements
+	Loaded map[string][]byte
+}
+
+// UnpackCheckpointFunc receives checkpoints during Unpack, which should be persisted if Unpack is to be
+// resumable.  An error causes Unpack to fail.
+type UnpackCheckpointFunc func(*UnpackCheckpoint) error
+
+// UnpackCheckpointing causes Unpack to load elements in batches, passing an UnpackCheckpoint to Save after each
+type UnpackCheckpointing struct {
+	// Resume, if not nil, is a checkpoint of the same packed data from which loading continues
+	Resume *UnpackCheckpoint
+	// BatchSize is the number of elements requested from the DataLoader in each call, defaulting to 25
+	BatchSize int
+	// Save receives each checkpoint
+	Save UnpackCheckpointFunc
+}
+
+const defaultCheckpointBatchSize = 25
+
+// loadElements calls load for the n elements of the packed data, in batches if checkpointing is requested
+func (c *UnpackCheckpointing) loadElements(ctx context.Context, data []byte, n int, load func(from, to int) (map[string][]byte, error)) (map[string][]byte, error) {
+
+	if c == nil {
+		return load(0, n)
+	}
+
+	fingerprint := sha256.Sum256(data)
+	current := &UnpackCheckpoint{Pack: fingerprint[:], Loaded: map[string][]byte{}}
+
+	if c.Resume != nil {
+		if subtle.ConstantTimeCompare(c.Resume.Pack, current.Pack) != 1 || c.Resume.Elements > n {
+			return nil, ErrCheckpointMismatch
+		}
+		current.Elements = c.Resume.Elements
+		maps.Copy(current.Loaded, c.Resume.Loaded)
+	}
+
+	size := c.BatchSize
+	if size <= 0 {
+		size = defaultCheckpointBatchSize
+	}
+
+	for current.Elements < n {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		to := min(current.Elements+size, n)
+		md, err := load(current.Elements, to)
+		if err != nil {
+			return nil, err
+		}
+		maps.Copy(current.Loaded, md)
+		current.Elements = to
+
+		if c.Save != nil {
+			if err := c.Save(&UnpackCheckpoint{Pack: current.Pack, Elements: current.Elements, Loaded: maps.Clone(current.Loaded)}); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return current.Loaded, nil
+}
+
+// MarshalBinary serialises the checkpoint, so that it can be persisted
+func (c *UnpackCheckpoint) MarshalBinary() ([]byte, error) {
+	items := []any{c.Pack, int64(c.Elements)}
+	items = appendSortedData(items, c.Loaded)
+	b, _, err := serialise.ToBytesMany(items, serialise.WithSerialisationApproach(serialise.NewMinDataApproachWithVersion(serialise.V1)))
+	return b, err
+}
+
+// UnmarshalBinary is the inverse of MarshalBinary
+func (c *UnpackCheckpoint) UnmarshalBinary(data []byte) error {
+	v, err := serialise.FromBytesMany(data, serialise.NewMinDataApproachWithVersion(serialise.V1))
+	if err != nil {
+		return err
+	}
+	if len(v) < 2 {
+		return ErrInvalidCheckpoint
+	}
+	pack, ok := v[0].([]byte)
+	if !ok {
+		return ErrInvalidCheckpoint
+	}
+	elements, ok := v[1].(int64)
+	if !ok || elements < 0 {
+		return ErrInvalidCheckpoint
+	}
+	loaded, err := sortedData(v[2:])
+	if err != nil {
+		return err
+	}
+
+	*c = UnpackCheckpoint{Pack: pack, Elements: int(elements), Loaded: loaded}
+	return nil
+}
+
+// appendSortedData appends alternating names and values to the items, ordered by name
+func appendSortedData(items []any, data map[string][]byte) []any {
+	names := make([]string, 0, len(data))
+	for k := range data {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		items = append(items, name, data[name])
+	}
+	return items
+}
+
+// sortedData is the inverse of appendSortedData
+func sortedData(v []any) (map[string][]byte, error) {
+	if len(v)%2 != 0 {
+		return nil, ErrInvalidCheckpoint
+	}
+	data := make(map[string][]byte, len(v)/2)
+	for i := 0; i < len(v); i += 2 {
+		name, ok := v[i].(string)
+		if !ok {
+			return nil, ErrInvalidCheckpoint
+		}
+		b, ok := v[i+1].([]byte)
+		if !ok {
+			return nil, ErrInvalidCheckpoint
+		}
+		data[name] = b
+	}
+	return data, nil
+}
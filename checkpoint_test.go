@@ -0,0 +1,188 @@
+package packer
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync/atomic"
+	"testing"
+)
+
+func TestWithPackCheckpoint(t *testing.T) {
+
+	testPack, testUnpack, provider := testCreateEnv(t)
+
+	item := &Item[Key]{
+		Key:        Key{X: "A", Y: "B"},
+		Attributes: map[string]any{},
+	}
+	for i := range 20 {
+		item.Attributes[fmt.Sprintf("attr%02d", i)] = int64(i)
+	}
+
+	errInterrupted := errors.New("interrupted")
+
+	// Interrupt the first Pack after the second checkpoint
+	var saved []byte
+	saves := 0
+	_, _, err := testPack(item, WithCompression(FlateCompression), WithPackCheckpoint(nil, 5, func(c *PackCheckpoint) error {
+		saves++
+		if saves > 2 {
+			return errInterrupted
+		}
+		b, err := c.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		saved = b
+		return nil
+	}))
+	if !errors.Is(err, errInterrupted) {
+		t.Fatalf("Expected interruption, got: %v", err)
+	}
+
+	resume := &PackCheckpoint{}
+	if err := resume.UnmarshalBinary(saved); err != nil {
+		t.Fatalf("Unexpected error deserialising checkpoint: %v", err)
+	}
+	if len(resume.Attributes) != 10 || resume.Compression != FlateCompression {
+		t.Fatalf("Unexpected checkpoint: %d attributes, compression %v", len(resume.Attributes), resume.Compression)
+	}
+
+	// Only the attributes missing from the checkpoint are serialised on resumption
+	var serialised atomic.Int32
+	counter := func(attr string, v any) (any, error) {
+		serialised.Add(1)
+		return v, nil
+	}
+
+	b, l, err := testPack(item, WithCompression(FlateCompression), WithPrePackHooks(counter), WithPackCheckpoint(resume, 5, nil))
+	if err != nil {
+		t.Fatalf("Unexpected error resuming: %v", err)
+	}
+	if serialised.Load() != 10 {
+		t.Fatalf("Expected 10 attributes to be serialised, got %d", serialised.Load())
+	}
+
+	e, err := testUnpack(b, l)
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+
+	values, err := e.GetValues(context.TODO(), slices.Collect(maps.Keys(item.Attributes)), provider)
+	if err != nil {
+		t.Fatalf("Unexpected error getting values: %v", err)
+	}
+	for k, v := range item.Attributes {
+		if values[k] != v {
+			t.Fatalf("Mismatch for %s: expected %v, got %v", k, v, values[k])
+		}
+	}
+
+	// Resuming with different options is rejected
+	if _, _, err := testPack(item, WithPackCheckpoint(resume, 5, nil)); !errors.Is(err, ErrCheckpointMismatch) {
+		t.Fatalf("Expected ErrCheckpointMismatch, got: %v", err)
+	}
+}
+
+func TestUnpackCheckpointing(t *testing.T) {
+
+	testPack, _, provider := testCreateEnv(t)
+
+	item := &Item[Key]{
+		Key:        Key{X: "A", Y: "B"},
+		Attributes: map[string]any{},
+	}
+	for i := range 6 {
+		b := make([]byte, 8*1024)
+		if _, err := rand.Read(b); err != nil {
+			t.Fatalf("Unexpected error creating data: %v", err)
+		}
+		item.Attributes[fmt.Sprintf("attr%d", i)] = b
+	}
+
+	info, l, err := testPack(item, WithMaximumKBSize(10), WithAttributeValueMaximumKBSize(10))
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+
+	serialiser, _ := NewKeySerialiser()
+
+	errInterrupted := errors.New("interrupted")
+
+	// The loader fails after the first two batches
+	var requested []int
+	loader := func(fail int) DataLoader[Key] {
+		return func(ctx context.Context, keys []Key) (map[string][]byte, error) {
+			requested = append(requested, len(keys))
+			if len(requested) == fail {
+				return nil, errInterrupted
+			}
+			return l(ctx, keys)
+		}
+	}
+
+	var saved *UnpackCheckpoint
+	params := &UnpackParams[Key]{
+		DataLoader:  loader(3),
+		IDRetriever: func(string) (IDSerialiser[Key], error) { return serialiser, nil },
+		Provider:    provider,
+		Checkpoint: &UnpackCheckpointing{
+			BatchSize: 2,
+			Save: func(c *UnpackCheckpoint) error {
+				saved = c
+				return nil
+			},
+		},
+	}
+	if _, err := Unpack(context.TODO(), info, params); !errors.Is(err, errInterrupted) {
+		t.Fatalf("Expected interruption, got: %v", err)
+	}
+	if saved == nil || saved.Elements != 4 {
+		t.Fatalf("Unexpected checkpoint: %+v", saved)
+	}
+
+	b, err := saved.MarshalBinary()
+	if err != nil {
+		t.Fatalf("Unexpected error serialising checkpoint: %v", err)
+	}
+	resume := &UnpackCheckpoint{}
+	if err := resume.UnmarshalBinary(b); err != nil {
+		t.Fatalf("Unexpected error deserialising checkpoint: %v", err)
+	}
+
+	// Resumption only loads the remaining elements
+	requested = nil
+	params.DataLoader = loader(0)
+	params.Checkpoint.Resume = resume
+	e, err := Unpack(context.TODO(), info, params)
+	if err != nil {
+		t.Fatalf("Unexpected error resuming: %v", err)
+	}
+	if len(requested) != 1 || requested[0] != 2 {
+		t.Fatalf("Unexpected loads on resumption: %v", requested)
+	}
+
+	values, err := e.GetValues(context.TODO(), slices.Collect(maps.Keys(item.Attributes)), provider)
+	if err != nil {
+		t.Fatalf("Unexpected error getting values: %v", err)
+	}
+	for k, v := range item.Attributes {
+		if !bytes.Equal(values[k].([]byte), v.([]byte)) {
+			t.Fatalf("Mismatch for %s", k)
+		}
+	}
+
+	// A checkpoint of different packed data is rejected
+	other, _, err := testPack(item)
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+	if _, err := Unpack(context.TODO(), other, params); !errors.Is(err, ErrCheckpointMismatch) {
+		t.Fatalf("Expected ErrCheckpointMismatch, got: %v", err)
+	}
+}
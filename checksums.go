@@ -0,0 +1,137 @@
+package packer
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"sort"
+
+	"github.com/gford1000-go/serialise"
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// WithChecksums records a CRC-32C checksum for each stored attribute chunk in the envelope,
+// which Unpack verifies before any decryption is attempted.  Corruption or truncation of
+// stored data is then reported precisely as a ChunkCorruptedError, rather than as a
+// failure to decrypt.
+func WithChecksums() func(o *Options) {
+	return func(o *Options) {
+		o.checksums = true
+	}
+}
+
+// ErrChunkCorrupted is matched by ChunkCorruptedError, using errors.Is
+var ErrChunkCorrupted = errors.New("stored attribute chunk is corrupted")
+
+// ChunkCorruptedError raised by Unpack if a loaded chunk does not match its recorded checksum
+type ChunkCorruptedError struct {
+	// Attribute whose value includes the chunk
+	Attribute string
+	// Chunk is the stored attribute name of the chunk
+	Chunk string
+	// Element is the key under which the chunk was stored
+	Element any
+}
+
+func (e *ChunkCorruptedError) Error() string {
+	return fmt.Sprintf("%v: element %v, chunk '%s' of attribute '%s'", ErrChunkCorrupted, e.Element, e.Chunk, e.Attribute)
+}
+
+func (e *ChunkCorruptedError) Unwrap() error {
+	return ErrChunkCorrupted
+}
+
+// chunkChecksum records the checksum of a stored chunk, and the index of the element holding it
+type chunkChecksum struct {
+	element int
+	crc     uint32
+}
+
+func createChecksums[T comparable](elements []T, output map[T]map[string][]byte) map[string]chunkChecksum {
+	checksums := map[string]chunkChecksum{}
+	for i, t := range elements {
+		for name, v := range output[t] {
+			checksums[name] = chunkChecksum{element: i, crc: crc32.Checksum(v, castagnoli)}
+		}
+	}
+	return checksums
+}
+
+func packChecksums(checksums map[string]chunkChecksum, approach serialise.Approach) ([]byte, error) {
+
+	names := make([]string, 0, len(checksums))
+	for k := range checksums {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	items := make([]any, 0, 3*len(names))
+	for _, name := range names {
+		c := checksums[name]
+		items = append(items, name, int64(c.element), int64(c.crc))
+	}
+
+	b, _, err := serialise.ToBytesMany(items, serialise.WithSerialisationApproach(approach))
+	return b, err
+}
+
+// ErrInvalidDataToDeserialiseChecksums raised if the recorded checksums cannot be deserialised
+var ErrInvalidDataToDeserialiseChecksums = errors.New("invalid data, cannot deserialise checksums")
+
+func unpackChecksums(data []byte, approach serialise.Approach) (map[string]chunkChecksum, error) {
+
+	v, err := serialise.FromBytesMany(data, approach)
+	if err != nil {
+		return nil, err
+	}
+	if len(v)%3 != 0 {
+		return nil, ErrInvalidDataToDeserialiseChecksums
+	}
+
+	checksums := make(map[string]chunkChecksum, len(v)/3)
+	for i := 0; i < len(v); i += 3 {
+		name, ok := v[i].(string)
+		if !ok {
+			return nil, ErrInvalidDataToDeserialiseChecksums
+		}
+		element, ok := v[i+1].(int64)
+		if !ok {
+			return nil, ErrInvalidDataToDeserialiseChecksums
+		}
+		crc, ok := v[i+2].(int64)
+		if !ok {
+			return nil, ErrInvalidDataToDeserialiseChecksums
+		}
+		checksums[name] = chunkChecksum{element: int(element), crc: uint32(crc)}
+	}
+
+	return checksums, nil
+}
+
+// verifyChecksums confirms that each loaded chunk matches its recorded checksum
+func verifyChecksums[T comparable](checksums map[string]chunkChecksum, attrMap map[string][]string, elements []T, data map[string][]byte) error {
+	if len(checksums) == 0 {
+		return nil
+	}
+	for attr, chunks := range attrMap {
+		for _, chunk := range chunks {
+			c, ok := checksums[chunk]
+			if !ok {
+				continue
+			}
+			v, ok := data[chunk]
+			if !ok {
+				continue
+			}
+			if crc32.Checksum(v, castagnoli) != c.crc {
+				e := &ChunkCorruptedError{Attribute: attr, Chunk: chunk}
+				if c.element >= 0 && c.element < len(elements) {
+					e.Element = elements[c.element]
+				}
+				return e
+			}
+		}
+	}
+	return nil
+}
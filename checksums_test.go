@@ -0,0 +1,62 @@
+package packer
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestWithChecksums(t *testing.T) {
+
+	testPack, testUnpack, provider := testCreateEnv(t)
+
+	item := &Item[Key]{
+		Key: Key{X: "A", Y: "B"},
+		Attributes: map[string]any{
+			"aaa": "Hello World",
+		},
+	}
+
+	b, l, err := testPack(item, WithChecksums())
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+
+	e, err := testUnpack(b, l)
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+	m, err := e.GetValues(context.TODO(), []string{"aaa"}, provider)
+	if err != nil {
+		t.Fatalf("Unexpected error getting values: %v", err)
+	}
+	if m["aaa"].(string) != "Hello World" {
+		t.Fatalf("Unexpected value: %v", m["aaa"])
+	}
+
+	// Corrupt the stored data
+	corruptLoader := func(ctx context.Context, keys []Key) (map[string][]byte, error) {
+		m, err := l(ctx, keys)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range m {
+			c := append([]byte{}, v...)
+			c[len(c)-1] ^= 0xff
+			m[k] = c
+		}
+		return m, nil
+	}
+
+	_, err = testUnpack(b, corruptLoader)
+	if !errors.Is(err, ErrChunkCorrupted) {
+		t.Fatalf("Unexpected error: expected: %v, got: %v", ErrChunkCorrupted, err)
+	}
+	var corrupted *ChunkCorruptedError
+	if !errors.As(err, &corrupted) {
+		t.Fatalf("Unexpected error type: %T", err)
+	}
+	if corrupted.Attribute != "aaa" || corrupted.Element != item.Key {
+		t.Fatalf("Unexpected error details: %+v", *corrupted)
+	}
+}
@@ -0,0 +1,45 @@
+package packer
+
+import (
+	"context"
+	"errors"
+)
+
+// PackedItem holds the packed data returned by Pack for a child item, so that it can be stored as an
+// attribute value of a parent item.  This allows tree structured datasets to be packed, with each child
+// unpacked from its parent using UnpackChild.  The elements of the child must be stored as normal.
+type PackedItem []byte
+
+// packedItemMarker distinguishes serialised PackedItem values from serialised keys of type T
+const packedItemMarker = "packer.PackedItem"
+
+// ErrAttributeIsNotPackedItem raised if UnpackChild is called for an attribute whose value is not a PackedItem
+var ErrAttributeIsNotPackedItem = errors.New("attribute value is not a PackedItem")
+
+// UnpackChild unpacks the child item held as a PackedItem by the attribute of the parent item.  The Provider
+// of the params is used to decrypt both the attribute of the parent and the child; the parent's values are
+// subject to any quota, hooks and schema specified when the parent was unpacked.
+func UnpackChild[T comparable](ctx context.Context, parent *EncryptedItem[T], attr string, params *UnpackParams[T]) (*EncryptedItem[T], error) {
+
+	if params == nil {
+		return nil, ErrUnpackNoParams
+	}
+	if err := params.validate(); err != nil {
+		return nil, err
+	}
+
+	m, err := parent.GetValues(ctx, []string{attr}, params.Provider)
+	if err != nil {
+		return nil, err
+	}
+	v, ok := m[attr]
+	if !ok {
+		return nil, ErrAttributeNotFound
+	}
+	child, ok := v.(PackedItem)
+	if !ok {
+		return nil, ErrAttributeIsNotPackedItem
+	}
+
+	return Unpack(ctx, child, params)
+}
@@ -0,0 +1,80 @@
+package packer
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"reflect"
+	"testing"
+)
+
+func TestUnpackChild(t *testing.T) {
+
+	testPack, testUnpack, provider := testCreateEnv(t)
+
+	child := &Item[Key]{
+		Key:        Key{X: "C", Y: "D"},
+		Attributes: map[string]any{"name": "child", "size": int64(3)},
+	}
+	childInfo, childLoader, err := testPack(child)
+	if err != nil {
+		t.Fatalf("Unexpected error packing child: %v", err)
+	}
+
+	parent := &Item[Key]{
+		Key:        Key{X: "A", Y: "B"},
+		Attributes: map[string]any{"name": "parent", "child": PackedItem(childInfo)},
+	}
+	parentInfo, parentLoader, err := testPack(parent)
+	if err != nil {
+		t.Fatalf("Unexpected error packing parent: %v", err)
+	}
+
+	// Parent and child elements are held in the same store
+	loader := func(ctx context.Context, keys []Key) (map[string][]byte, error) {
+		m, err := parentLoader(ctx, keys)
+		if err != nil {
+			return nil, err
+		}
+		c, err := childLoader(ctx, keys)
+		if err != nil {
+			return nil, err
+		}
+		maps.Copy(m, c)
+		return m, nil
+	}
+
+	e, err := testUnpack(parentInfo, loader)
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking parent: %v", err)
+	}
+
+	serialiser, _ := NewKeySerialiser()
+	params := &UnpackParams[Key]{
+		DataLoader:  loader,
+		IDRetriever: func(string) (IDSerialiser[Key], error) { return serialiser, nil },
+		Provider:    provider,
+	}
+
+	c, err := UnpackChild(context.TODO(), e, "child", params)
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking child: %v", err)
+	}
+	if c.GetKey() != child.Key {
+		t.Fatalf("Unexpected child key: %v", c.GetKey())
+	}
+	values, err := c.GetValues(context.TODO(), []string{"name", "size"}, provider)
+	if err != nil {
+		t.Fatalf("Unexpected error getting child values: %v", err)
+	}
+	if !reflect.DeepEqual(values, child.Attributes) {
+		t.Fatalf("Mismatch: expected %v, got %v", child.Attributes, values)
+	}
+
+	if _, err := UnpackChild(context.TODO(), e, "name", params); !errors.Is(err, ErrAttributeIsNotPackedItem) {
+		t.Fatalf("Expected ErrAttributeIsNotPackedItem, got: %v", err)
+	}
+	if _, err := UnpackChild(context.TODO(), e, "missing", params); !errors.Is(err, ErrAttributeNotFound) {
+		t.Fatalf("Expected ErrAttributeNotFound, got: %v", err)
+	}
+}
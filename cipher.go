@@ -0,0 +1,141 @@
+package packer
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"github.com/gford1000-go/serialise"
+)
+
+// CipherAlgorithm identifies the authenticated encryption applied to attribute values.
+// The algorithm is recorded in the packed data, so that new algorithms can be added,
+// and selected by deployment policy, without requiring a new PackVersion.
+type CipherAlgorithm uint8
+
+const (
+	// AES256GCM is AES-GCM with a 256 bit key, as provided by the serialise package
+	AES256GCM CipherAlgorithm = iota
+	// AES256CTRHMACSHA256 is AES-CTR with a 256 bit key, authenticated by HMAC-SHA256 (encrypt-then-MAC)
+	AES256CTRHMACSHA256
+	cipherAlgorithmOutOfRange
+)
+
+var cipherAlgorithmNames = map[CipherAlgorithm]string{
+	AES256GCM:           "aes-256-gcm",
+	AES256CTRHMACSHA256: "aes-256-ctr-hmac-sha256",
+}
+
+// String returns the name of the CipherAlgorithm
+func (c CipherAlgorithm) String() string {
+	if n, ok := cipherAlgorithmNames[c]; ok {
+		return n
+	}
+	return fmt.Sprintf("CipherAlgorithm(%d)", uint8(c))
+}
+
+// ErrUnknownCipherAlgorithm raised if an unrecognised CipherAlgorithm is requested or found in packed data
+var ErrUnknownCipherAlgorithm = errors.New("unknown cipher algorithm")
+
+// MarshalText allows the CipherAlgorithm to be written by name in configuration
+func (c CipherAlgorithm) MarshalText() ([]byte, error) {
+	if _, ok := cipherAlgorithmNames[c]; !ok {
+		return nil, ErrUnknownCipherAlgorithm
+	}
+	return []byte(c.String()), nil
+}
+
+// UnmarshalText allows the CipherAlgorithm to be specified by name in configuration
+func (c *CipherAlgorithm) UnmarshalText(text []byte) error {
+	for k, v := range cipherAlgorithmNames {
+		if v == string(text) {
+			*c = k
+			return nil
+		}
+	}
+	return ErrUnknownCipherAlgorithm
+}
+
+// WithCipherAlgorithm selects the algorithm used to encrypt attribute values.  If not set, AES256GCM is used.
+func WithCipherAlgorithm(alg CipherAlgorithm) func(o *Options) {
+	if alg >= cipherAlgorithmOutOfRange {
+		panic("invalid CipherAlgorithm value provided")
+	}
+	return func(o *Options) {
+		o.cipherAlgorithm = alg
+	}
+}
+
+// encryptionOption returns the serialisation option that applies the algorithm with the key
+func (c CipherAlgorithm) encryptionOption(key []byte) (func(*serialise.Options), error) {
+	switch c {
+	case AES256GCM:
+		return serialise.WithAESGCMEncryption(key), nil
+	case AES256CTRHMACSHA256:
+		enc, dec, err := newCTRHMAC(key)
+		if err != nil {
+			return nil, err
+		}
+		return func(o *serialise.Options) {
+			o.Encryptor = enc
+			o.Decryptor = dec
+		}, nil
+	default:
+		return nil, ErrUnknownCipherAlgorithm
+	}
+}
+
+// ErrCipherAuthenticationFailed raised if encrypted data fails authentication during decryption
+var ErrCipherAuthenticationFailed = errors.New("cipher authentication failed - data has been altered or the key is incorrect")
+
+// newCTRHMAC returns encrypt-then-MAC functions, with independent encryption and MAC keys derived from key
+func newCTRHMAC(key []byte) (func([]byte) ([]byte, error), func([]byte) ([]byte, error), error) {
+
+	derive := func(label string) []byte {
+		h := hmac.New(sha256.New, key)
+		h.Write([]byte(label))
+		return h.Sum(nil)
+	}
+	encKey := derive("packer aes-256-ctr")
+	macKey := derive("packer hmac-sha256")
+
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tag := func(b []byte) []byte {
+		h := hmac.New(sha256.New, macKey)
+		h.Write(b)
+		return h.Sum(nil)
+	}
+
+	enc := func(plaintext []byte) ([]byte, error) {
+		out := make([]byte, aes.BlockSize+len(plaintext), aes.BlockSize+len(plaintext)+sha256.Size)
+		iv := out[:aes.BlockSize]
+		if _, err := rand.Read(iv); err != nil {
+			return nil, err
+		}
+		cipher.NewCTR(block, iv).XORKeyStream(out[aes.BlockSize:], plaintext)
+		return append(out, tag(out)...), nil
+	}
+
+	dec := func(data []byte) ([]byte, error) {
+		if len(data) < aes.BlockSize+sha256.Size {
+			return nil, ErrCipherAuthenticationFailed
+		}
+		body := data[:len(data)-sha256.Size]
+		if !hmac.Equal(tag(body), data[len(body):]) {
+			return nil, ErrCipherAuthenticationFailed
+		}
+		plaintext := make([]byte, len(body)-aes.BlockSize)
+		cipher.NewCTR(block, body[:aes.BlockSize]).XORKeyStream(plaintext, body[aes.BlockSize:])
+		return plaintext, nil
+	}
+
+	return enc, dec, nil
+}
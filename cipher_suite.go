@@ -0,0 +1,57 @@
+package packer
+
+import (
+	"errors"
+
+	"github.com/gford1000-go/serialise"
+)
+
+// CipherSuite is an authenticated encryption implementation, which can be used in place of the built-in
+// CipherAlgorithms to encrypt attribute values; for example to use a FIPS validated module.  The ID of the
+// suite is recorded in the packed data, so that Unpack can select the same implementation from the CipherSuites
+// of the UnpackParams.  The packing details themselves remain encrypted with AES-GCM.
+// Implementations must be safe for concurrent use.
+type CipherSuite interface {
+	// ID identifies the suite; it must not change once data has been packed with the suite
+	ID() string
+	// KeySize is the size of the key, in bytes, passed to Seal and Open
+	KeySize() int
+	// Seal encrypts and authenticates the plaintext with the key
+	Seal(key, plaintext []byte) ([]byte, error)
+	// Open authenticates and decrypts ciphertext returned by Seal
+	Open(key, ciphertext []byte) ([]byte, error)
+}
+
+// ErrInvalidCipherSuite raised if a CipherSuite has an empty ID or a KeySize that is not positive
+var ErrInvalidCipherSuite = errors.New("cipher suite must have an ID and a positive key size")
+
+// ErrUnknownCipherSuite raised by GetValues if the CipherSuite used to pack the item is not in the CipherSuites of the UnpackParams
+var ErrUnknownCipherSuite = errors.New("cipher suite used to pack the item is not available")
+
+// validateCipherSuite checks that the suite can be recorded in packed data and keyed
+func validateCipherSuite(suite CipherSuite) error {
+	if len(suite.ID()) == 0 || suite.KeySize() <= 0 {
+		return ErrInvalidCipherSuite
+	}
+	return nil
+}
+
+// cipherSuiteOption returns the serialisation option that applies the suite, with a key of the suite's size derived
+// from the data encryption key
+func cipherSuiteOption(suite CipherSuite, key []byte) func(*serialise.Options) {
+	suiteKey := hkdfSHA256(key, nil, []byte("packer cipher suite "+suite.ID()), suite.KeySize())
+	return func(o *serialise.Options) {
+		o.Encryptor = func(plaintext []byte) ([]byte, error) { return suite.Seal(suiteKey, plaintext) }
+		o.Decryptor = func(ciphertext []byte) ([]byte, error) { return suite.Open(suiteKey, ciphertext) }
+	}
+}
+
+// findCipherSuite returns the suite with the ID, or nil if none match
+func findCipherSuite(suites []CipherSuite, id string) CipherSuite {
+	for _, suite := range suites {
+		if suite != nil && suite.ID() == id {
+			return suite
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package packer
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"testing"
+
+	"github.com/gford1000-go/serialise"
+)
+
+// testAES128Suite is AES-GCM with a 128 bit key, counting the values it seals
+type testAES128Suite struct {
+	id     string
+	sealed int
+}
+
+func (s *testAES128Suite) ID() string   { return s.id }
+func (s *testAES128Suite) KeySize() int { return 16 }
+
+func (s *testAES128Suite) Seal(key, plaintext []byte) ([]byte, error) {
+	aead, err := newAESGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	s.sealed++
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func (s *testAES128Suite) Open(key, ciphertext []byte) ([]byte, error) {
+	aead, err := newAESGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, ErrCipherAuthenticationFailed
+	}
+	return aead.Open(nil, ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():], nil)
+}
+
+func TestCipherSuite(t *testing.T) {
+
+	_, _, provider := testCreateEnv(t)
+	serialiser, _ := NewKeySerialiser()
+
+	suite := &testAES128Suite{id: "test-aes-128-gcm"}
+
+	pParams := &PackParams[Key]{
+		Provider:    provider,
+		Creator:     NewKeyCreator(defaultLen),
+		Packer:      serialiser,
+		Approach:    serialise.NewMinDataApproachWithVersion(serialise.V1),
+		CipherSuite: suite,
+	}
+
+	item := &Item[Key]{Key: Key{X: "A", Y: "B"}, Attributes: map[string]any{"aaa": "Hello World", "bbb": int64(42)}}
+
+	info, data, err := Pack(item, pParams)
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+	if suite.sealed != 2 {
+		t.Fatalf("Expected the suite to seal each attribute, sealed %d", suite.sealed)
+	}
+
+	loader := func(ctx context.Context, keys []Key) (map[string][]byte, error) {
+		attrs := map[string][]byte{}
+		for _, key := range keys {
+			for k, v := range data[key] {
+				attrs[k] = v
+			}
+		}
+		return attrs, nil
+	}
+	uParams := &UnpackParams[Key]{
+		DataLoader:  loader,
+		IDRetriever: func(string) (IDSerialiser[Key], error) { return serialiser, nil },
+		Provider:    provider,
+	}
+
+	e, err := Unpack(context.TODO(), info, uParams)
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+	if _, err := e.GetValues(context.TODO(), []string{"aaa"}, provider); !errors.Is(err, ErrUnknownCipherSuite) {
+		t.Fatalf("Expected ErrUnknownCipherSuite, got: %v", err)
+	}
+
+	uParams.CipherSuites = []CipherSuite{&testAES128Suite{id: "other"}, suite}
+	e, err = Unpack(context.TODO(), info, uParams)
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+	values, err := e.GetValues(context.TODO(), []string{"aaa", "bbb"}, provider)
+	if err != nil {
+		t.Fatalf("Unexpected error getting values: %v", err)
+	}
+	if values["aaa"] != "Hello World" || values["bbb"] != int64(42) {
+		t.Fatalf("Unexpected values: %v", values)
+	}
+
+	pParams.CipherSuite = &testAES128Suite{}
+	if _, _, err := Pack(item, pParams); !errors.Is(err, ErrInvalidCipherSuite) {
+		t.Fatalf("Expected ErrInvalidCipherSuite, got: %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package packer
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestWithCipherAlgorithm(t *testing.T) {
+
+	testPack, testUnpack, provider := testCreateEnv(t)
+
+	item := &Item[Key]{
+		Key: Key{X: "A", Y: "B"},
+		Attributes: map[string]any{
+			"aaa": "Hello World",
+			"bbb": int64(42),
+		},
+	}
+
+	for _, opts := range [][]func(*Options){
+		{WithCipherAlgorithm(AES256GCM)},
+		{WithCipherAlgorithm(AES256CTRHMACSHA256)},
+		{WithCipherAlgorithm(AES256CTRHMACSHA256), WithCompression(FlateCompression)},
+	} {
+		b, l, err := testPack(item, opts...)
+		if err != nil {
+			t.Fatalf("Unexpected error packing: %v", err)
+		}
+
+		e, err := testUnpack(b, l)
+		if err != nil {
+			t.Fatalf("Unexpected error unpacking: %v", err)
+		}
+
+		m, err := e.GetValues(context.TODO(), []string{"aaa", "bbb"}, provider)
+		if err != nil {
+			t.Fatalf("Unexpected error getting values: %v", err)
+		}
+		if m["aaa"].(string) != "Hello World" || m["bbb"].(int64) != 42 {
+			t.Fatalf("Unexpected values: %v", m)
+		}
+	}
+}
+
+func TestCipherAlgorithm_Tampered(t *testing.T) {
+
+	enc, dec, err := newCTRHMAC(make([]byte, 32))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	b, err := enc([]byte("Hello World"))
+	if err != nil {
+		t.Fatalf("Unexpected error encrypting: %v", err)
+	}
+
+	p, err := dec(b)
+	if err != nil {
+		t.Fatalf("Unexpected error decrypting: %v", err)
+	}
+	if string(p) != "Hello World" {
+		t.Fatalf("Unexpected plaintext: %s", string(p))
+	}
+
+	b[20] ^= 0x01
+	if _, err := dec(b); !errors.Is(err, ErrCipherAuthenticationFailed) {
+		t.Fatalf("Unexpected error: expected: %v, got: %v", ErrCipherAuthenticationFailed, err)
+	}
+
+	if _, err := dec(b[:10]); !errors.Is(err, ErrCipherAuthenticationFailed) {
+		t.Fatalf("Unexpected error: expected: %v, got: %v", ErrCipherAuthenticationFailed, err)
+	}
+}
+
+func TestCipherAlgorithm_UnmarshalText(t *testing.T) {
+
+	for _, alg := range []CipherAlgorithm{AES256GCM, AES256CTRHMACSHA256} {
+		b, err := alg.MarshalText()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		var c CipherAlgorithm
+		if err := c.UnmarshalText(b); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if c != alg {
+			t.Fatalf("Unexpected algorithm: expected: %v, got: %v", alg, c)
+		}
+	}
+
+	var c CipherAlgorithm
+	if err := c.UnmarshalText([]byte("rot13")); !errors.Is(err, ErrUnknownCipherAlgorithm) {
+		t.Fatalf("Unexpected error: expected: %v, got: %v", ErrUnknownCipherAlgorithm, err)
+	}
+}
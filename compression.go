@@ -0,0 +1,132 @@
+package packer
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Compression identifies how attribute values are compressed prior to encryption
+type Compression int8
+
+const (
+	// NoCompression leaves attribute values uncompressed
+	NoCompression Compression = iota
+	// FlateCompression applies DEFLATE compression to attribute values
+	FlateCompression
+	compressionOutOfRange
+)
+
+var compressionNames = map[Compression]string{
+	NoCompression:    "none",
+	FlateCompression: "flate",
+}
+
+// String returns the name of the Compression
+func (c Compression) String() string {
+	if n, ok := compressionNames[c]; ok {
+		return n
+	}
+	return fmt.Sprintf("Compression(%d)", int8(c))
+}
+
+// ErrUnknownCompression raised if an unrecognised Compression is requested or found in packed data
+var ErrUnknownCompression = errors.New("unknown compression")
+
+// MarshalText allows the Compression to be written by name in configuration
+func (c Compression) MarshalText() ([]byte, error) {
+	if _, ok := compressionNames[c]; !ok {
+		return nil, ErrUnknownCompression
+	}
+	return []byte(c.String()), nil
+}
+
+// UnmarshalText allows the Compression to be specified by name in configuration
+func (c *Compression) UnmarshalText(text []byte) error {
+	for k, v := range compressionNames {
+		if v == string(text) {
+			*c = k
+			return nil
+		}
+	}
+	return ErrUnknownCompression
+}
+
+// WithCompression sets the compression applied to each attribute value prior to encryption.
+// The Compression is recorded in the packed data, so Unpack does not need to be told.
+func WithCompression(compression Compression) func(o *Options) {
+	if compression < NoCompression || compression >= compressionOutOfRange {
+		panic("invalid Compression value provided")
+	}
+	return func(o *Options) {
+		o.compression = compression
+	}
+}
+
+// WithMetadataCompression sets the compression applied to the attribute map and elements of the envelope,
+// which for items with thousands of chunks can otherwise exceed the size limits of the store.
+// The sections are compressed before the envelope is encrypted, and the Compression is recorded in
+// the packed data, so Unpack does not need to be told.
+func WithMetadataCompression(compression Compression) func(o *Options) {
+	if compression < NoCompression || compression >= compressionOutOfRange {
+		panic("invalid Compression value provided")
+	}
+	return func(o *Options) {
+		o.metadataCompression = compression
+	}
+}
+
+// flateWriters reuses compressors, which hold large internal buffers
+var flateWriters sync.Pool
+
+func getFlateWriter(dst io.Writer) (*flate.Writer, error) {
+	if w, ok := flateWriters.Get().(*flate.Writer); ok {
+		w.Reset(dst)
+		return w, nil
+	}
+	return flate.NewWriter(dst, flate.BestSpeed)
+}
+
+func compress(compression Compression, data []byte) ([]byte, error) {
+	return compressInto(make([]byte, 0, len(data)/2), compression, data)
+}
+
+// compressInto compresses the data, appending to dst where it has sufficient capacity
+func compressInto(dst []byte, compression Compression, data []byte) ([]byte, error) {
+	switch compression {
+	case NoCompression:
+		return data, nil
+	case FlateCompression:
+		buf := bytes.NewBuffer(dst)
+		w, err := getFlateWriter(buf)
+		if err != nil {
+			return nil, err
+		}
+		defer flateWriters.Put(w)
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return nil, ErrUnknownCompression
+	}
+}
+
+func decompress(compression Compression, data []byte) ([]byte, error) {
+	switch compression {
+	case NoCompression:
+		return data, nil
+	case FlateCompression:
+		r := flate.NewReader(bytes.NewReader(data))
+		defer r.Close()
+		return io.ReadAll(r)
+	default:
+		return nil, ErrUnknownCompression
+	}
+}
@@ -0,0 +1,110 @@
+package packer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestWithCompression(t *testing.T) {
+
+	testPack, testUnpack, provider := testCreateEnv(t)
+
+	item := &Item[Key]{
+		Key: Key{X: "A", Y: "B"},
+		Attributes: map[string]any{
+			"aaa": int64(42),
+			"bbb": strings.Repeat("Hello World;", 100000),
+			"ref": Key{X: "C", Y: "D"},
+			"refs": []Key{
+				{X: "E", Y: "F"},
+				{X: "G", Y: "H"},
+			},
+		},
+	}
+
+	for _, compression := range []Compression{NoCompression, FlateCompression} {
+
+		b, l, err := testPack(item, WithCompression(compression), WithConcurrency(4))
+		if err != nil {
+			t.Fatalf("(%v) Unexpected error packing: %v", compression, err)
+		}
+
+		e, err := testUnpack(b, l)
+		if err != nil {
+			t.Fatalf("(%v) Unexpected error unpacking: %v", compression, err)
+		}
+		if e.compression != compression {
+			t.Fatalf("(%v) Unexpected compression recorded: %v", compression, e.compression)
+		}
+
+		for k, v := range item.Attributes {
+			m, err := e.GetValues(context.TODO(), []string{k}, provider)
+			if err != nil {
+				t.Fatalf("(%v) Unexpected error during value retrieval: %v", compression, err)
+			}
+			compareValue(m[k], v, fmt.Sprintf("%T", v), t)
+		}
+	}
+}
+
+func TestWithMetadataCompression(t *testing.T) {
+
+	testPack, testUnpack, provider := testCreateEnv(t)
+
+	item := &Item[Key]{
+		Key:        Key{X: "A", Y: "B"},
+		Attributes: map[string]any{},
+	}
+	for i := range 200 {
+		item.Attributes[fmt.Sprintf("attr%d", i)] = int64(i)
+	}
+
+	b, l, err := testPack(item, WithMetadataCompression(FlateCompression), WithAttributeNameDictionary())
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+
+	e, err := testUnpack(b, l)
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+	serialiser, _ := NewKeySerialiser()
+	env, err := openEnvelope(context.TODO(), b, provider, func(string) (IDSerialiser[Key], error) { return serialiser, nil })
+	if err != nil {
+		t.Fatalf("Unexpected error opening envelope: %v", err)
+	}
+	if c, err := env.ext.metadataCompression(); err != nil || c != FlateCompression {
+		t.Fatalf("Expected FlateCompression to be recorded, got: %v, %v", c, err)
+	}
+
+	for k, v := range item.Attributes {
+		m, err := e.GetValues(context.TODO(), []string{k}, provider)
+		if err != nil {
+			t.Fatalf("Unexpected error during value retrieval: %v", err)
+		}
+		compareValue(m[k], v, fmt.Sprintf("%T", v), t)
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatal("Expected panic for an invalid Compression")
+		}
+	}()
+	WithMetadataCompression(compressionOutOfRange)
+}
+
+func TestCompression_UnmarshalText(t *testing.T) {
+
+	var c Compression
+	if err := c.UnmarshalText([]byte("flate")); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if c != FlateCompression {
+		t.Fatalf("Unexpected compression: %v", c)
+	}
+	if err := c.UnmarshalText([]byte("zip")); err == nil {
+		t.Fatal("Unexpected success when expected error")
+	}
+}
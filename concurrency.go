@@ -0,0 +1,67 @@
+package packer
+
+import (
+	"fmt"
+	"sync"
+)
+
+// runConcurrently calls f for each index in [0, n), with at most limit calls in progress
+// at any one time.  The first error encountered is returned once all calls have completed.
+// A panic within a concurrent call is returned as an error, as it cannot be recovered by the caller.
+func runConcurrently(n int, limit int, f func(i int) error) error {
+
+	if limit < 1 {
+		limit = 1
+	}
+
+	if limit == 1 || n < 2 {
+		for i := range n {
+			if err := f(i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+
+	sem := make(chan struct{}, limit)
+
+	for i := range n {
+		sem <- struct{}{}
+		wg.Add(1)
+
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			record := func(err error) {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+			}
+
+			defer func() {
+				if r := recover(); r != nil {
+					record(fmt.Errorf("%v", r))
+				}
+			}()
+
+			if err := f(i); err != nil {
+				record(err)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	return firstErr
+}
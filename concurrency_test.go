@@ -0,0 +1,76 @@
+package packer
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRunConcurrently(t *testing.T) {
+
+	errTest := errors.New("test error")
+
+	for _, limit := range []int{1, 4} {
+		var calls atomic.Int32
+		err := runConcurrently(8, limit, func(i int) error {
+			calls.Add(1)
+			if i == 3 {
+				return errTest
+			}
+			return nil
+		})
+		if !errors.Is(err, errTest) {
+			t.Fatalf("(%d) Unexpected error: expected: %v, got: %v", limit, errTest, err)
+		}
+		if limit > 1 && calls.Load() != 8 {
+			t.Fatalf("(%d) Expected all calls to complete, got: %d", limit, calls.Load())
+		}
+	}
+
+	// A panic in a concurrent call is returned, rather than terminating the process
+	err := runConcurrently(8, 4, func(i int) error {
+		if i == 5 {
+			var s []int
+			_ = s[i]
+		}
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "index out of range") {
+		t.Fatalf("Unexpected error: expected index out of range, got: %v", err)
+	}
+}
+
+func TestWithConcurrency(t *testing.T) {
+
+	testPack, testUnpack, provider := testCreateEnv(t)
+
+	item := &Item[Key]{
+		Key: Key{X: "A", Y: "B"},
+		Attributes: map[string]any{
+			"keys": []*Key{{X: "C", Y: "D"}},
+			"a":    "x",
+			"b":    int64(2),
+		},
+	}
+
+	for _, concurrency := range []uint16{1, 4} {
+		b, l, err := testPack(item, WithConcurrency(concurrency))
+		if err != nil {
+			t.Fatalf("(%d) Unexpected error packing: %v", concurrency, err)
+		}
+		e, err := testUnpack(b, l)
+		if err != nil {
+			t.Fatalf("(%d) Unexpected error unpacking: %v", concurrency, err)
+		}
+		m, err := e.GetValues(context.TODO(), []string{"keys", "a"}, provider)
+		if err != nil {
+			t.Fatalf("(%d) Unexpected error getting values: %v", concurrency, err)
+		}
+		keys, ok := m["keys"].([]*Key)
+		if !ok || len(keys) != 1 || *keys[0] != (Key{X: "C", Y: "D"}) || m["a"] != "x" {
+			t.Fatalf("(%d) Unexpected values: %v", concurrency, m)
+		}
+	}
+}
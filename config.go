@@ -0,0 +1,241 @@
+package packer
+
+import (
+	"errors"
+
+	"github.com/gford1000-go/serialise"
+)
+
+// Config is a constructible equivalent of the functional options accepted by Pack,
+// allowing the packing configuration to be built, validated and logged up front,
+// rather than bad combinations being discovered at the first call to Pack.
+// Zero values indicate that the default for that setting will be used.
+type Config struct {
+	// PackingVersion selects the packing mechanism
+	PackingVersion PackVersion `json:"packingVersion"`
+	// MaximumKBSize is the maximum size of each returned item
+	MaximumKBSize uint16 `json:"maximumKBSize"`
+	// AttributeValueMaximumKBSize is the maximum size of an individual attribute value after packing
+	AttributeValueMaximumKBSize uint16 `json:"attributeValueMaximumKBSize"`
+	// AttributeNameSize is the size of the random attribute names
+	AttributeNameSize uint8 `json:"attributeNameSize"`
+	// AttributeNameRetries is the number of retries allowed to create a unique attribute name
+	AttributeNameRetries uint8 `json:"attributeNameRetries"`
+	// AttributeNameMaximumSize is the size to which random attribute names may grow after collisions
+	AttributeNameMaximumSize uint8 `json:"attributeNameMaximumSize"`
+	// AttributeNameAlphabet is the set of characters from which attribute names are created
+	AttributeNameAlphabet string `json:"attributeNameAlphabet"`
+	// AttributeNameLeadingAlphabet is the set of characters from which the first character of attribute names is chosen
+	AttributeNameLeadingAlphabet string `json:"attributeNameLeadingAlphabet"`
+	// Concurrency is the number of attributes that may be serialised concurrently
+	Concurrency uint16 `json:"concurrency"`
+	// Compression is applied to attribute values prior to encryption
+	Compression Compression `json:"compression"`
+	// MetadataCompression is applied to the attribute map and elements of the envelope
+	MetadataCompression Compression `json:"metadataCompression"`
+	// RejectOversizeAttributes fails Pack if an attribute value exceeds AttributeValueMaximumKBSize, rather than chunking it
+	RejectOversizeAttributes bool `json:"rejectOversizeAttributes"`
+	// MaxAttributes is the maximum number of attributes an item may have
+	MaxAttributes uint32 `json:"maxAttributes"`
+	// Checksums records a checksum for each stored chunk, verified during Unpack
+	Checksums bool `json:"checksums"`
+	// ParityElements is the number of Reed-Solomon parity elements added for erasure coding
+	ParityElements uint8 `json:"parityElements"`
+	// ReplicationFactor is the number of keys under which each element is written
+	ReplicationFactor uint8 `json:"replicationFactor"`
+	// CipherAlgorithm is the algorithm used to encrypt attribute values
+	CipherAlgorithm CipherAlgorithm `json:"cipherAlgorithm"`
+	// ElementKeys encrypts the chunks of each element with a subkey derived for that element
+	ElementKeys bool `json:"elementKeys"`
+	// KeyHierarchy records the key hierarchy in the envelope
+	KeyHierarchy bool `json:"keyHierarchy"`
+	// KeyHierarchyTenant identifies the tenant whose key encryption key wraps the data encryption key
+	KeyHierarchyTenant string `json:"keyHierarchyTenant"`
+	// MemoryBudget is the approximate number of bytes of attribute values that may be in flight during Pack
+	MemoryBudget uint64 `json:"memoryBudget"`
+	// AttributeNameDictionary records the attribute map as a dictionary of names, reducing the envelope size of wide items
+	AttributeNameDictionary bool `json:"attributeNameDictionary"`
+	// AttributeNameRules, if not nil, are enforced on the names of attributes
+	AttributeNameRules *AttributeNameRules `json:"attributeNameRules,omitempty"`
+	// WireFormat selects how the outer envelope of V2 packed data is encoded
+	WireFormat WireFormat `json:"wireFormat"`
+	// ArmoredOutput returns the packed data as printable ASCII
+	ArmoredOutput bool `json:"armoredOutput"`
+	// EnvelopeMAC records a MAC over the visible part of the envelope, verified during Unpack
+	EnvelopeMAC bool `json:"envelopeMAC"`
+	// Inline embeds the chunks of all attribute values within the packed data, so that no elements are returned
+	Inline bool `json:"inline"`
+	// AttributeGroups maps attribute names to the group of attributes with which they are placed in a single element
+	AttributeGroups map[string]string `json:"attributeGroups,omitempty"`
+	// BatchEncryption encrypts attribute values using pooled cipher instances and pre-derived nonces
+	BatchEncryption bool `json:"batchEncryption"`
+	// MerkleRoot records the root of a Merkle tree over every stored attribute chunk, verified by VerifyMerkleRoot
+	MerkleRoot bool `json:"merkleRoot"`
+	// ProviderID names the EnvelopeKeyProvider to be used, which can be located using Provider()
+	ProviderID EnvelopeKeyID `json:"provider"`
+	// AttributeNamer creates the names of attribute chunks, if not random
+	AttributeNamer AttributeNamer `json:"-"`
+	// Allocator provides temporary buffers during packing, if not allocated from the heap
+	Allocator Allocator `json:"-"`
+	// Signer signs the visible part of the envelope, if not nil
+	Signer Signer `json:"-"`
+	// SerialisationOptions are applied during serialisation of attribute values
+	SerialisationOptions []func(*serialise.Options) `json:"-"`
+}
+
+// ErrConfigIsNil raised if a nil Config is validated or applied
+var ErrConfigIsNil = errors.New("config must not be nil")
+
+// ErrAttributeNameSizeTooSmall raised if the attribute name size is less than two
+var ErrAttributeNameSizeTooSmall = errors.New("attribute name size must be at least two")
+
+// Validate checks that the Config describes a combination of settings that Pack will accept.
+// An AttributeValueMaximumKBSize greater than MaximumKBSize is clamped to MaximumKBSize, as it is by Pack.
+func (c *Config) Validate() error {
+	if c == nil {
+		return ErrConfigIsNil
+	}
+	if c.PackingVersion < UnknownVersion || c.PackingVersion >= OutOfRange {
+		return ErrUnsupportedPackVersion
+	}
+	if c.MaximumKBSize != 0 && uint64(c.MaximumKBSize)*1024 < minSize {
+		return ErrMaxSizeTooSmall
+	}
+	if c.AttributeNameSize == 1 {
+		return ErrAttributeNameSizeTooSmall
+	}
+	if err := validateAlphabet(c.AttributeNameAlphabet); err != nil {
+		return err
+	}
+	if err := validateAlphabet(c.AttributeNameLeadingAlphabet); err != nil {
+		return err
+	}
+	if c.MaximumKBSize != 0 && c.AttributeValueMaximumKBSize > c.MaximumKBSize {
+		c.AttributeValueMaximumKBSize = c.MaximumKBSize
+	}
+	if c.Compression < NoCompression || c.Compression >= compressionOutOfRange {
+		return ErrUnknownCompression
+	}
+	if c.MetadataCompression < NoCompression || c.MetadataCompression >= compressionOutOfRange {
+		return ErrUnknownCompression
+	}
+	if c.CipherAlgorithm >= cipherAlgorithmOutOfRange {
+		return ErrUnknownCipherAlgorithm
+	}
+	packingVersion := c.PackingVersion
+	if packingVersion == UnknownVersion {
+		packingVersion = defaultPackingVersion
+	}
+	if err := c.WireFormat.validate(packingVersion); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ErrConfigNoProvider raised if a provider is requested from a Config that does not name one
+var ErrConfigNoProvider = errors.New("config does not specify a provider")
+
+// Provider returns the EnvelopeKeyProvider named by the Config, located using the finder
+func (c *Config) Provider(finder EnveloperKeyProviderFinder) (EnvelopeKeyProvider, error) {
+	if c == nil {
+		return nil, ErrConfigIsNil
+	}
+	if len(c.ProviderID) == 0 {
+		return nil, ErrConfigNoProvider
+	}
+	if finder == nil {
+		return nil, ErrMissingFinder
+	}
+	return finder(c.ProviderID)
+}
+
+// FromFunctional returns the Config equivalent to the functional options provided,
+// so that they can be validated or logged before use
+func FromFunctional(opts ...func(*Options)) *Config {
+	o := &Options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return &Config{
+		PackingVersion:               o.packingVersion,
+		MaximumKBSize:                uint16(o.maxSize / 1024),
+		AttributeValueMaximumKBSize:  uint16(o.maxAttrValueSize / 1024),
+		AttributeNameSize:            o.attrNameSize,
+		AttributeNameRetries:         o.attrNameRetries,
+		AttributeNameMaximumSize:     o.attrNameMaxSize,
+		AttributeNameAlphabet:        o.attrNameAlphabet,
+		AttributeNameLeadingAlphabet: o.attrNameLeadingAlphabet,
+		Concurrency:                  o.concurrency,
+		Compression:                  o.compression,
+		MetadataCompression:          o.metadataCompression,
+		RejectOversizeAttributes:     o.rejectOversize,
+		MaxAttributes:                o.maxAttributes,
+		Checksums:                    o.checksums,
+		ParityElements:               o.parityElements,
+		ReplicationFactor:            o.replicationFactor,
+		CipherAlgorithm:              o.cipherAlgorithm,
+		ElementKeys:                  o.elementKeys,
+		KeyHierarchy:                 o.keyHierarchy,
+		KeyHierarchyTenant:           o.keyHierarchyTenant,
+		MemoryBudget:                 o.memoryBudget,
+		AttributeNameDictionary:      o.attrDictionary,
+		AttributeNameRules:           o.attrNameRules,
+		WireFormat:                   o.wireFormat,
+		ArmoredOutput:                o.armored,
+		EnvelopeMAC:                  o.envelopeMAC,
+		Inline:                       o.inline,
+		AttributeGroups:              o.attrGroups,
+		BatchEncryption:              o.batchEncryption,
+		MerkleRoot:                   o.merkleRoot,
+		AttributeNamer:               o.attrNamer,
+		Allocator:                    o.allocator,
+		Signer:                       o.signer,
+		SerialisationOptions:         o.serialiseOptions,
+	}
+}
+
+// WithConfig applies all the settings of the Config as a single functional option.
+// The ProviderID is not applied, as the provider is always specified in PackParams.
+// The Config should be validated before use, as invalid settings may cause Pack to fail.
+func WithConfig(c *Config) func(o *Options) {
+	if c == nil {
+		panic(ErrConfigIsNil)
+	}
+	return func(o *Options) {
+		o.packingVersion = c.PackingVersion
+		o.maxSize = uint64(c.MaximumKBSize) * 1024
+		o.maxAttrValueSize = uint64(c.AttributeValueMaximumKBSize) * 1024
+		o.attrNameSize = c.AttributeNameSize
+		o.attrNameRetries = c.AttributeNameRetries
+		o.attrNameMaxSize = c.AttributeNameMaximumSize
+		o.attrNameAlphabet = c.AttributeNameAlphabet
+		o.attrNameLeadingAlphabet = c.AttributeNameLeadingAlphabet
+		o.concurrency = c.Concurrency
+		o.compression = c.Compression
+		o.metadataCompression = c.MetadataCompression
+		o.rejectOversize = c.RejectOversizeAttributes
+		o.maxAttributes = c.MaxAttributes
+		o.checksums = c.Checksums
+		o.parityElements = c.ParityElements
+		o.replicationFactor = c.ReplicationFactor
+		o.cipherAlgorithm = c.CipherAlgorithm
+		o.elementKeys = c.ElementKeys
+		o.keyHierarchy = c.KeyHierarchy
+		o.keyHierarchyTenant = c.KeyHierarchyTenant
+		o.memoryBudget = c.MemoryBudget
+		o.attrDictionary = c.AttributeNameDictionary
+		o.attrNameRules = c.AttributeNameRules
+		o.wireFormat = c.WireFormat
+		o.armored = c.ArmoredOutput
+		o.envelopeMAC = c.EnvelopeMAC
+		o.inline = c.Inline
+		o.attrGroups = c.AttributeGroups
+		o.batchEncryption = c.BatchEncryption
+		o.merkleRoot = c.MerkleRoot
+		o.attrNamer = c.AttributeNamer
+		o.allocator = c.Allocator
+		o.signer = c.Signer
+		o.serialiseOptions = c.SerialisationOptions
+	}
+}
@@ -0,0 +1,258 @@
+package packer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// ErrUnsupportedConfigFormat raised if LoadOptions is asked to read a file that is neither JSON nor YAML
+var ErrUnsupportedConfigFormat = errors.New("unsupported configuration format - file must have a .json, .yaml or .yml extension")
+
+// ErrInvalidYAMLConfig raised if a YAML configuration file uses features beyond nested mappings and sequences of scalars
+var ErrInvalidYAMLConfig = errors.New("invalid YAML configuration - only 'name: value' settings, nested mappings and sequences of scalars are supported")
+
+// LoadOptions reads a Config from the JSON or YAML file at path within fsys, allowing
+// operational tuning of the packer without recompilation.  The format is determined by the
+// file extension, and unrecognised settings are rejected.  The Config is validated before it
+// is returned; use WithConfig to apply it to Pack, and Config.Provider to locate the provider.
+//
+// Settings use the same names in both formats, for example:
+//
+//	packingVersion: 1
+//	maximumKBSize: 350
+//	attributeValueMaximumKBSize: 100
+//	concurrency: 4
+//	compression: flate
+//	provider: Key1
+//	attributeGroups:
+//	  street: address
+//	  city: address
+//	attributeNameRules:
+//	  maxLength: 64
+//	  reservedPrefixes:
+//	    - sys_
+//
+// YAML files are limited to 'name: value' settings, mappings nested by indentation and sequences of
+// scalars, which is sufficient for every setting of the Config.  Numbers are read as decimal, and values
+// that must be strings but look like numbers or booleans should be quoted.
+func LoadOptions(fsys fs.FS, filePath string) (*Config, error) {
+
+	data, err := fs.ReadFile(fsys, filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(path.Ext(filePath)) {
+	case ".json":
+	case ".yaml", ".yml":
+		data, err = yamlToJSON(data)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrUnsupportedConfigFormat
+	}
+
+	c := &Config{}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(c); err != nil {
+		return nil, err
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// yamlLine is a significant line of a YAML configuration file
+type yamlLine struct {
+	no      int
+	indent  int
+	content string
+}
+
+// yamlToJSON converts the block subset of YAML used for configuration files into JSON, so that both
+// formats are decoded identically.  Mappings and sequences of scalars may be nested by indentation,
+// allowing settings such as attributeGroups and attributeNameRules; anchors, multi-line strings,
+// flow collections other than [] and {}, and sequences of mappings are not supported.
+func yamlToJSON(data []byte) ([]byte, error) {
+
+	lines := []yamlLine{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+
+		trimmed := strings.TrimSpace(line)
+		if len(trimmed) == 0 || strings.HasPrefix(trimmed, "#") || trimmed == "---" {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		if line[indent] == '\t' {
+			return nil, fmt.Errorf("%w (line %d)", ErrInvalidYAMLConfig, lineNo)
+		}
+		lines = append(lines, yamlLine{no: lineNo, indent: indent, content: trimmed})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	m := map[string]any{}
+	if len(lines) > 0 {
+		if lines[0].indent != 0 || isYAMLSequenceItem(lines[0].content) {
+			return nil, fmt.Errorf("%w (line %d)", ErrInvalidYAMLConfig, lines[0].no)
+		}
+		v, next, err := yamlMapping(lines, 0)
+		if err != nil {
+			return nil, err
+		}
+		if next < len(lines) {
+			return nil, fmt.Errorf("%w (line %d)", ErrInvalidYAMLConfig, lines[next].no)
+		}
+		m = v
+	}
+
+	return json.Marshal(m)
+}
+
+func isYAMLSequenceItem(content string) bool {
+	return content == "-" || strings.HasPrefix(content, "- ")
+}
+
+// yamlMapping parses the mapping whose entries begin at lines[i], returning the index of the first line after it
+func yamlMapping(lines []yamlLine, i int) (map[string]any, int, error) {
+
+	m := map[string]any{}
+	indent := lines[i].indent
+	for i < len(lines) && lines[i].indent == indent && !isYAMLSequenceItem(lines[i].content) {
+
+		name, value, ok := strings.Cut(lines[i].content, ":")
+		if !ok {
+			return nil, 0, fmt.Errorf("%w (line %d)", ErrInvalidYAMLConfig, lines[i].no)
+		}
+		name = strings.TrimSpace(name)
+		value = strings.TrimSpace(value)
+		i++
+
+		// A setting without a value may hold a nested mapping or sequence
+		if (len(value) == 0 || strings.HasPrefix(value, "#")) && i < len(lines) &&
+			(lines[i].indent > indent || (lines[i].indent == indent && isYAMLSequenceItem(lines[i].content))) {
+			var v any
+			var err error
+			if isYAMLSequenceItem(lines[i].content) {
+				v, i, err = yamlSequence(lines, i)
+			} else {
+				v, i, err = yamlMapping(lines, i)
+			}
+			if err != nil {
+				return nil, 0, err
+			}
+			m[name] = v
+			continue
+		}
+
+		v, err := yamlScalar(value)
+		if err != nil {
+			return nil, 0, fmt.Errorf("%w (line %d)", ErrInvalidYAMLConfig, lines[i-1].no)
+		}
+		if v != nil {
+			m[name] = v
+		}
+	}
+
+	if i < len(lines) && lines[i].indent > indent {
+		return nil, 0, fmt.Errorf("%w (line %d)", ErrInvalidYAMLConfig, lines[i].no)
+	}
+	return m, i, nil
+}
+
+// yamlSequence parses the sequence of scalars whose items begin at lines[i], returning the index of the first line after it
+func yamlSequence(lines []yamlLine, i int) ([]any, int, error) {
+
+	s := []any{}
+	indent := lines[i].indent
+	for i < len(lines) && lines[i].indent == indent && isYAMLSequenceItem(lines[i].content) {
+		v, err := yamlScalar(strings.TrimSpace(strings.TrimPrefix(lines[i].content, "-")))
+		if err != nil {
+			return nil, 0, fmt.Errorf("%w (line %d)", ErrInvalidYAMLConfig, lines[i].no)
+		}
+		s = append(s, v)
+		i++
+	}
+
+	if i < len(lines) && lines[i].indent > indent {
+		return nil, 0, fmt.Errorf("%w (line %d)", ErrInvalidYAMLConfig, lines[i].no)
+	}
+	return s, i, nil
+}
+
+// yamlScalar returns the value of a scalar, which is nil if the value is absent
+func yamlScalar(value string) (any, error) {
+
+	if len(value) > 0 && (value[0] == '"' || value[0] == '\'') {
+		return yamlQuotedString(value)
+	}
+
+	// Unquoted values may carry a trailing comment
+	if strings.HasPrefix(value, "#") {
+		value = ""
+	}
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = strings.TrimSpace(value[:i])
+	}
+
+	switch {
+	case len(value) == 0, value == "~", value == "null":
+		// Absent values leave the default in place
+		return nil, nil
+	case value == "[]":
+		return []any{}, nil
+	case value == "{}":
+		return map[string]any{}, nil
+	case strings.ContainsAny(value[:1], "[{&*|>!"), strings.HasPrefix(value, "- "),
+		strings.Contains(value, ": "), strings.HasSuffix(value, ":"):
+		// Flow collections, anchors, aliases, block scalars, tags and sequences of mappings are not supported
+		return nil, ErrInvalidYAMLConfig
+	case value == "true", value == "false":
+		return value == "true", nil
+	}
+
+	// Numbers are always decimal, so that a leading zero is not read as octal
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return n, nil
+	}
+	if f, err := strconv.ParseFloat(value, 64); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+		return f, nil
+	}
+	return value, nil
+}
+
+func yamlQuotedString(value string) (string, error) {
+	quote := value[0]
+	end := strings.LastIndexByte(value, quote)
+	if end == 0 {
+		return "", ErrInvalidYAMLConfig
+	}
+	rest := strings.TrimSpace(value[end+1:])
+	if len(rest) > 0 && !strings.HasPrefix(rest, "#") {
+		return "", ErrInvalidYAMLConfig
+	}
+	if quote == '"' {
+		return strconv.Unquote(value[:end+1])
+	}
+	return strings.ReplaceAll(value[1:end], "''", "'"), nil
+}
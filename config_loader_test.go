@@ -0,0 +1,148 @@
+package packer
+
+import (
+	"errors"
+	"maps"
+	"slices"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadOptions(t *testing.T) {
+
+	fsys := fstest.MapFS{
+		"packer.json": &fstest.MapFile{Data: []byte(`{
+	"packingVersion": 1,
+	"maximumKBSize": 200,
+	"attributeValueMaximumKBSize": 50,
+	"concurrency": 4,
+	"compression": "flate",
+	"provider": "Key1"
+}`)},
+		"packer.yaml": &fstest.MapFile{Data: []byte(`# Packer settings
+---
+packingVersion: 1
+maximumKBSize: 200 # per item
+attributeValueMaximumKBSize: 50
+concurrency: 4
+compression: "flate"
+provider: 'Key1'
+`)},
+	}
+
+	for _, name := range []string{"packer.json", "packer.yaml"} {
+		c, err := LoadOptions(fsys, name)
+		if err != nil {
+			t.Fatalf("(%s) Unexpected error loading options: %v", name, err)
+		}
+
+		if c.PackingVersion != V1 || c.MaximumKBSize != 200 || c.AttributeValueMaximumKBSize != 50 ||
+			c.Concurrency != 4 || c.Compression != FlateCompression || c.ProviderID != "Key1" {
+			t.Fatalf("(%s) Unexpected config: %+v", name, *c)
+		}
+	}
+}
+
+func TestLoadOptions_1(t *testing.T) {
+
+	fsys := fstest.MapFS{
+		"packer.toml":     &fstest.MapFile{Data: []byte(`concurrency = 4`)},
+		"unknown.json":    &fstest.MapFile{Data: []byte(`{"concurency": 4}`)},
+		"invalid.json":    &fstest.MapFile{Data: []byte(`{"maximumKBSize": 5}`)},
+		"nested.yml":      &fstest.MapFile{Data: []byte("sizes:\n  max: 10\n")},
+		"indented.yml":    &fstest.MapFile{Data: []byte("concurrency: 4\n  compression: flate\n")},
+		"mappings.yml":    &fstest.MapFile{Data: []byte("attributeNameRules:\n  reservedPrefixes:\n    - a: b\n")},
+		"flow.yml":        &fstest.MapFile{Data: []byte("attributeGroups: {a: b}\n")},
+		"badcompress.yml": &fstest.MapFile{Data: []byte("compression: zip\n")},
+	}
+
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{name: "packer.toml", err: ErrUnsupportedConfigFormat},
+		{name: "invalid.json", err: ErrMaxSizeTooSmall},
+		{name: "indented.yml", err: ErrInvalidYAMLConfig},
+		{name: "mappings.yml", err: ErrInvalidYAMLConfig},
+		{name: "flow.yml", err: ErrInvalidYAMLConfig},
+		{name: "badcompress.yml", err: ErrUnknownCompression},
+	}
+
+	for _, test := range tests {
+		_, err := LoadOptions(fsys, test.name)
+		if !errors.Is(err, test.err) {
+			t.Fatalf("(%s) Unexpected error: expected: %v, got: %v", test.name, test.err, err)
+		}
+	}
+
+	for _, name := range []string{"unknown.json", "nested.yml"} {
+		if _, err := LoadOptions(fsys, name); err == nil {
+			t.Fatalf("(%s) Unexpected success when expected error for unknown setting", name)
+		}
+	}
+
+	if _, err := LoadOptions(fsys, "unknown.json"); err == nil {
+		t.Fatal("Unexpected success when expected error for unknown setting")
+	}
+
+	if _, err := LoadOptions(fsys, "missing.json"); err == nil {
+		t.Fatal("Unexpected success when expected error for missing file")
+	}
+}
+
+func TestLoadOptions_Nested(t *testing.T) {
+
+	fsys := fstest.MapFS{
+		"packer.yaml": &fstest.MapFile{Data: []byte(`concurrency: 010
+attributeGroups:
+  street: address
+  city: 'address' # quoted
+attributeNameRules:
+  maxLength: 64
+  reservedPrefixes:
+    - sys_
+    - "tmp_"
+`)},
+	}
+
+	c, err := LoadOptions(fsys, "packer.yaml")
+	if err != nil {
+		t.Fatalf("Unexpected error loading options: %v", err)
+	}
+
+	if c.Concurrency != 10 {
+		t.Fatalf("Expected numbers to be read as decimal, got %d", c.Concurrency)
+	}
+	if !maps.Equal(c.AttributeGroups, map[string]string{"street": "address", "city": "address"}) {
+		t.Fatalf("Unexpected attribute groups: %v", c.AttributeGroups)
+	}
+	if c.AttributeNameRules == nil || c.AttributeNameRules.MaxLength != 64 ||
+		!slices.Equal(c.AttributeNameRules.ReservedPrefixes, []string{"sys_", "tmp_"}) {
+		t.Fatalf("Unexpected attribute name rules: %+v", c.AttributeNameRules)
+	}
+}
+
+func TestConfig_Provider(t *testing.T) {
+
+	_, _, provider := testCreateEnv(t)
+
+	finder := func(id EnvelopeKeyID) (EnvelopeKeyProvider, error) {
+		if id == provider.ID() {
+			return provider, nil
+		}
+		return nil, errors.New("unknown provider id")
+	}
+
+	c := &Config{ProviderID: provider.ID()}
+	p, err := c.Provider(finder)
+	if err != nil {
+		t.Fatalf("Unexpected error locating provider: %v", err)
+	}
+	if p.ID() != provider.ID() {
+		t.Fatalf("Unexpected provider: expected: %v, got: %v", provider.ID(), p.ID())
+	}
+
+	if _, err := (&Config{}).Provider(finder); !errors.Is(err, ErrConfigNoProvider) {
+		t.Fatalf("Unexpected error: expected: %v, got: %v", ErrConfigNoProvider, err)
+	}
+}
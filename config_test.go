@@ -0,0 +1,188 @@
+package packer
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/gford1000-go/serialise"
+)
+
+func TestConfig_Validate(t *testing.T) {
+
+	tests := []struct {
+		c   *Config
+		err error
+	}{
+		{c: nil, err: ErrConfigIsNil},
+		{c: &Config{}, err: nil},
+		{c: &Config{PackingVersion: OutOfRange}, err: ErrUnsupportedPackVersion},
+		{c: &Config{MaximumKBSize: 9}, err: ErrMaxSizeTooSmall},
+		{c: &Config{AttributeNameSize: 1}, err: ErrAttributeNameSizeTooSmall},
+		{c: &Config{PackingVersion: V1, MaximumKBSize: 20, AttributeValueMaximumKBSize: 20, AttributeNameSize: 8, AttributeNameRetries: 3}, err: nil},
+	}
+
+	for i, test := range tests {
+		err := test.c.Validate()
+		if !errors.Is(err, test.err) {
+			t.Fatalf("(%d) Unexpected error: expected: %v, got: %v", i, test.err, err)
+		}
+	}
+
+	// The attribute value size is clamped to the maximum size, as it is by Pack
+	c := &Config{MaximumKBSize: 20, AttributeValueMaximumKBSize: 21}
+	if err := c.Validate(); err != nil || c.AttributeValueMaximumKBSize != 20 {
+		t.Fatalf("Unexpected result: expected clamped size of 20, got: %d, %v", c.AttributeValueMaximumKBSize, err)
+	}
+}
+
+func TestFromFunctional(t *testing.T) {
+
+	c := FromFunctional(
+		WithPackingVersion(V1),
+		WithMaximumKBSize(200),
+		WithAttributeValueMaximumKBSize(50),
+		WithAttributeNameSize(8),
+		WithAttributeNameRetries(4))
+
+	expected := Config{
+		PackingVersion:              V1,
+		MaximumKBSize:               200,
+		AttributeValueMaximumKBSize: 50,
+		AttributeNameSize:           8,
+		AttributeNameRetries:        4,
+	}
+
+	if c.PackingVersion != expected.PackingVersion ||
+		c.MaximumKBSize != expected.MaximumKBSize ||
+		c.AttributeValueMaximumKBSize != expected.AttributeValueMaximumKBSize ||
+		c.AttributeNameSize != expected.AttributeNameSize ||
+		c.AttributeNameRetries != expected.AttributeNameRetries {
+		t.Fatalf("Unexpected config: expected: %+v, got: %+v", expected, *c)
+	}
+
+	if err := c.Validate(); err != nil {
+		t.Fatalf("Unexpected error validating config: %v", err)
+	}
+
+	// Round trip back to the same settings
+	c1 := FromFunctional(WithConfig(c))
+	if c1.MaximumKBSize != c.MaximumKBSize || c1.AttributeNameSize != c.AttributeNameSize {
+		t.Fatalf("Unexpected mismatch after round trip: expected: %+v, got: %+v", *c, *c1)
+	}
+}
+
+func TestFromFunctional_RoundTrip(t *testing.T) {
+
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Unexpected error generating key: %v", err)
+	}
+	signer, err := NewEd25519Signer("signer", private)
+	if err != nil {
+		t.Fatalf("Unexpected error creating signer: %v", err)
+	}
+
+	c := &Config{
+		PackingVersion:               V2,
+		MaximumKBSize:                200,
+		AttributeValueMaximumKBSize:  50,
+		AttributeNameSize:            8,
+		AttributeNameRetries:         4,
+		AttributeNameMaximumSize:     12,
+		AttributeNameAlphabet:        "abcdef",
+		AttributeNameLeadingAlphabet: "abc",
+		Concurrency:                  3,
+		Compression:                  FlateCompression,
+		MetadataCompression:          FlateCompression,
+		RejectOversizeAttributes:     true,
+		MaxAttributes:                100,
+		Checksums:                    true,
+		ParityElements:               2,
+		ReplicationFactor:            2,
+		CipherAlgorithm:              AES256CTRHMACSHA256,
+		ElementKeys:                  true,
+		KeyHierarchy:                 true,
+		KeyHierarchyTenant:           "tenant",
+		MemoryBudget:                 1024 * 1024,
+		AttributeNameDictionary:      true,
+		AttributeNameRules:           &AttributeNameRules{MaxLength: 32},
+		WireFormat:                   CBORWireFormat,
+		ArmoredOutput:                true,
+		EnvelopeMAC:                  true,
+		Inline:                       true,
+		AttributeGroups:              map[string]string{"a": "g"},
+		BatchEncryption:              true,
+		MerkleRoot:                   true,
+		ProviderID:                   "Key1",
+		AttributeNamer:               NewSequenceAttributeNamer("n"),
+		Allocator:                    NewArenaAllocator(1024),
+		Signer:                       signer,
+		SerialisationOptions:         []func(*serialise.Options){serialise.WithSerialisationApproach(serialise.NewMinDataApproachWithVersion(serialise.V1))},
+	}
+
+	c1 := FromFunctional(WithConfig(c))
+
+	// Every field must be set above, so that fields added to Config are included in the round trip
+	v, v1 := reflect.ValueOf(c).Elem(), reflect.ValueOf(c1).Elem()
+	for i := range v.NumField() {
+		name := v.Type().Field(i).Name
+		if v.Field(i).IsZero() {
+			t.Fatalf("Field %s must be set to check the round trip", name)
+		}
+
+		switch name {
+		case "ProviderID":
+			// The provider is always specified in PackParams, so is not applied by WithConfig
+			if !v1.Field(i).IsZero() {
+				t.Fatalf("Unexpected ProviderID after round trip: %v", v1.Field(i))
+			}
+		case "SerialisationOptions":
+			if v1.Field(i).Len() != v.Field(i).Len() {
+				t.Fatalf("Unexpected mismatch of %s after round trip", name)
+			}
+		default:
+			if !reflect.DeepEqual(v.Field(i).Interface(), v1.Field(i).Interface()) {
+				t.Fatalf("Unexpected mismatch of %s after round trip: expected: %v, got: %v", name, v.Field(i), v1.Field(i))
+			}
+		}
+	}
+}
+
+func TestWithConfig(t *testing.T) {
+
+	testPack, testUnpack, provider := testCreateEnv(t)
+
+	c := &Config{MaximumKBSize: 20, AttributeValueMaximumKBSize: 10}
+	if err := c.Validate(); err != nil {
+		t.Fatalf("Unexpected error validating config: %v", err)
+	}
+
+	item := &Item[Key]{
+		Key: Key{X: "A", Y: "B"},
+		Attributes: map[string]any{
+			"aaa": int64(42),
+		},
+	}
+
+	b, l, err := testPack(item, WithConfig(c))
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+
+	e, err := testUnpack(b, l)
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+
+	m, err := e.GetValues(context.TODO(), []string{"aaa"}, provider)
+	if err != nil {
+		t.Fatalf("Unexpected error getting values: %v", err)
+	}
+	if m["aaa"].(int64) != 42 {
+		t.Fatalf("Unexpected value: %v", m["aaa"])
+	}
+}
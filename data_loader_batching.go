@@ -0,0 +1,79 @@
+package packer
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"runtime"
+	"sync"
+)
+
+// WithBatchingDataLoader returns a DataLoader that splits the keys into batches of at most batchSize keys,
+// calling the loader for each batch with at most concurrency calls in progress, and merging the results.
+// This suits stores that limit the keys of each request (e.g. DynamoDB's BatchGetItem allows 100 keys).
+// If concurrency is less than one, GOMAXPROCS is used.  If batchSize is less than one, or the loader is
+// nil, the loader is returned unchanged.  If any batch fails, the data of the successful batches is returned
+// with a PartialLoadError listing the keys of the failed batches, so that WithRetryingDataLoader can retry
+// only those keys.
+func WithBatchingDataLoader[T comparable](loader DataLoader[T], batchSize int, concurrency int) DataLoader[T] {
+	if loader == nil || batchSize < 1 {
+		return loader
+	}
+	if concurrency < 1 {
+		concurrency = runtime.GOMAXPROCS(0)
+	}
+
+	return func(ctx context.Context, keys []T) (map[string][]byte, error) {
+		if len(keys) <= batchSize {
+			return loader(ctx, keys)
+		}
+
+		var batches [][]T
+		for start := 0; start < len(keys); start += batchSize {
+			batches = append(batches, keys[start:min(start+batchSize, len(keys))])
+		}
+
+		var mu sync.Mutex
+		result := map[string][]byte{}
+		failed := make([][]T, len(batches))
+		errs := make([]error, len(batches))
+
+		// Failures are recorded against each batch, so runConcurrently never returns an error
+		_ = runConcurrently(len(batches), concurrency, func(i int) error {
+			errs[i] = recoverError(func() error {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				m, err := loader(ctx, batches[i])
+				mu.Lock()
+				maps.Copy(result, m)
+				mu.Unlock()
+				return err
+			})
+			if errs[i] != nil {
+				failed[i] = batches[i]
+				// A batch that was itself partially loaded need only report the keys that failed
+				var partial *PartialLoadError[T]
+				if errors.As(errs[i], &partial) {
+					failed[i] = partial.Keys
+				}
+			}
+			return nil
+		})
+
+		partial := &PartialLoadError[T]{}
+		for i, err := range errs {
+			if err == nil {
+				continue
+			}
+			if partial.Err == nil {
+				partial.Err = err
+			}
+			partial.Keys = append(partial.Keys, failed[i]...)
+		}
+		if partial.Err != nil {
+			return result, partial
+		}
+		return result, nil
+	}
+}
@@ -0,0 +1,138 @@
+package packer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWithBatchingDataLoader(t *testing.T) {
+
+	var (
+		mu      sync.Mutex
+		batches []int
+	)
+	loader := func(ctx context.Context, keys []string) (map[string][]byte, error) {
+		mu.Lock()
+		batches = append(batches, len(keys))
+		mu.Unlock()
+		m := map[string][]byte{}
+		for _, k := range keys {
+			m[k] = []byte(k)
+		}
+		return m, nil
+	}
+
+	keys := make([]string, 250)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("k%d", i)
+	}
+
+	m, err := WithBatchingDataLoader(loader, 100, 4)(context.TODO(), keys)
+	if err != nil {
+		t.Fatalf("Unexpected error loading: %v", err)
+	}
+	if len(m) != len(keys) {
+		t.Fatalf("Expected %d results, got %d", len(keys), len(m))
+	}
+	if len(batches) != 3 {
+		t.Fatalf("Expected 3 batches, got %v", batches)
+	}
+	for _, n := range batches {
+		if n > 100 {
+			t.Fatalf("Batch exceeds size: %v", batches)
+		}
+	}
+
+	failure := errors.New("failed")
+	failing := func(ctx context.Context, keys []string) (map[string][]byte, error) {
+		if keys[0] == "k100" {
+			return nil, failure
+		}
+		return loader(ctx, keys)
+	}
+	m, err = WithBatchingDataLoader(failing, 100, 0)(context.TODO(), keys)
+	var partial *PartialLoadError[string]
+	if !errors.Is(err, failure) || !errors.As(err, &partial) {
+		t.Fatalf("Expected the failure of a batch, got: %v", err)
+	}
+	// The successful batches are returned, and only the keys of the failed batch are reported
+	if len(m) != 150 || !slices.Equal(partial.Keys, keys[100:200]) {
+		t.Fatalf("Unexpected partial result: %d values, %d failed keys", len(m), len(partial.Keys))
+	}
+
+	// So that retries only request the keys of the failed batch
+	var once sync.Once
+	var retried []string
+	flaky := func(ctx context.Context, keys []string) (map[string][]byte, error) {
+		if keys[0] == "k100" {
+			failed := false
+			once.Do(func() { failed = true })
+			if failed {
+				return nil, failure
+			}
+			mu.Lock()
+			retried = append(retried, keys...)
+			mu.Unlock()
+		}
+		return loader(ctx, keys)
+	}
+	m, err = WithRetryingDataLoader(WithBatchingDataLoader(flaky, 100, 0), RetryPolicy{InitialBackoff: time.Millisecond})(context.TODO(), keys)
+	if err != nil || len(m) != len(keys) || !slices.Equal(retried, keys[100:200]) {
+		t.Fatalf("Unexpected retried result: %d values, %d keys retried, %v", len(m), len(retried), err)
+	}
+
+	if WithBatchingDataLoader[string](nil, 100, 1) != nil {
+		t.Fatal("Expected a nil loader to be returned unchanged")
+	}
+}
+
+func TestWithBatchingDataLoaderUnpack(t *testing.T) {
+
+	testPack, testUnpack, provider := testCreateEnv(t)
+
+	item := &Item[Key]{
+		Key:        Key{X: "A", Y: "B"},
+		Attributes: map[string]any{},
+	}
+	for i := range 4000 {
+		item.Attributes[fmt.Sprintf("attr%d", i)] = int64(i)
+	}
+
+	b, l, err := testPack(item, WithMaximumKBSize(16))
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+
+	calls := 0
+	var mu sync.Mutex
+	counted := func(ctx context.Context, keys []Key) (map[string][]byte, error) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		if len(keys) > 1 {
+			return nil, fmt.Errorf("too many keys: %d", len(keys))
+		}
+		return l(ctx, keys)
+	}
+
+	e, err := testUnpack(b, WithBatchingDataLoader(counted, 1, 2))
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+	if calls < 2 {
+		t.Fatalf("Expected multiple elements to be loaded, got %d calls", calls)
+	}
+	values, err := e.GetAllValues(context.TODO(), provider)
+	if err != nil {
+		t.Fatalf("Unexpected error getting values: %v", err)
+	}
+	if !maps.Equal(values, item.Attributes) {
+		t.Fatal("Mismatch in values")
+	}
+}
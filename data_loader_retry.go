@@ -0,0 +1,132 @@
+package packer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"time"
+)
+
+// RetryPolicy describes how WithRetryingDataLoader retries a DataLoader that fails
+type RetryPolicy struct {
+	// MaxAttempts is the number of calls made to the DataLoader before failing; defaults to 3
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry; defaults to 50ms
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between retries; no cap if zero
+	MaxBackoff time.Duration
+	// Multiplier increases the delay after each retry; defaults to 2
+	Multiplier float64
+	// AttemptTimeout optionally limits the duration of each call to the DataLoader
+	AttemptTimeout time.Duration
+	// Retryable optionally decides whether an error is transient.  If not set, all errors are retried,
+	// other than the cancellation or expiry of the context passed to the DataLoader.
+	Retryable func(err error) bool
+}
+
+// PartialLoadError may be returned by a DataLoader that loaded some, but not all, of the requested keys,
+// alongside the data that was loaded.  WithRetryingDataLoader then retries only the keys that failed.
+type PartialLoadError[T comparable] struct {
+	// Keys are those that could not be loaded
+	Keys []T
+	// Err is the cause of the failure
+	Err error
+}
+
+func (e *PartialLoadError[T]) Error() string {
+	return fmt.Sprintf("failed to load %d keys: %v", len(e.Keys), e.Err)
+}
+
+func (e *PartialLoadError[T]) Unwrap() error {
+	return e.Err
+}
+
+// ErrDataLoaderAttemptTimedOut raised if an attempt by WithRetryingDataLoader exceeds the AttemptTimeout
+var ErrDataLoaderAttemptTimedOut = errors.New("data loader attempt timed out")
+
+// WithRetryingDataLoader returns a DataLoader that retries the loader according to the policy, backing
+// off exponentially between attempts, so that transient storage errors do not fail Unpack.
+// Data returned alongside an error is retained and merged with that of later attempts; if the error
+// is a PartialLoadError, only its keys are requested again.  The last error is returned once the
+// attempts are exhausted.  A nil loader is returned unchanged.
+func WithRetryingDataLoader[T comparable](loader DataLoader[T], policy RetryPolicy) DataLoader[T] {
+	if loader == nil {
+		return nil
+	}
+	policy = policy.withDefaults()
+
+	return func(ctx context.Context, keys []T) (map[string][]byte, error) {
+		result := map[string][]byte{}
+		pending := keys
+		delay := policy.InitialBackoff
+
+		for attempt := 1; ; attempt++ {
+			m, err := retryAttempt(ctx, policy, loader, pending)
+			maps.Copy(result, m)
+			if err == nil {
+				return result, nil
+			}
+
+			var partial *PartialLoadError[T]
+			if errors.As(err, &partial) {
+				pending = partial.Keys
+			}
+
+			if attempt == policy.MaxAttempts || ctx.Err() != nil || !policy.Retryable(err) {
+				return nil, err
+			}
+
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(delay):
+			}
+			delay = policy.next(delay)
+		}
+	}
+}
+
+// withDefaults returns the policy with unset fields replaced by their defaults
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxAttempts < 1 {
+		p.MaxAttempts = 3
+	}
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = 50 * time.Millisecond
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = 2
+	}
+	if p.Retryable == nil {
+		p.Retryable = func(err error) bool {
+			return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+		}
+	}
+	return p
+}
+
+// retryAttempt calls the loader, limited by any AttemptTimeout of the policy.  An attempt that times out
+// is retryable, even though the context of the DataLoader itself has not expired.
+func retryAttempt[T comparable](ctx context.Context, p RetryPolicy, loader DataLoader[T], keys []T) (map[string][]byte, error) {
+	if p.AttemptTimeout <= 0 {
+		return loader(ctx, keys)
+	}
+	actx, cancel := context.WithTimeout(ctx, p.AttemptTimeout)
+	defer cancel()
+
+	m, err := loader(actx, keys)
+	if err != nil && ctx.Err() == nil && actx.Err() != nil {
+		err = fmt.Errorf("%w: %v", ErrDataLoaderAttemptTimedOut, err)
+	}
+	return m, err
+}
+
+// next returns the delay before the following retry
+func (p RetryPolicy) next(delay time.Duration) time.Duration {
+	delay = time.Duration(float64(delay) * p.Multiplier)
+	if p.MaxBackoff > 0 && delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+	return delay
+}
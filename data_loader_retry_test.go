@@ -0,0 +1,135 @@
+package packer
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestWithRetryingDataLoader(t *testing.T) {
+
+	testPack, testUnpack, provider := testCreateEnv(t)
+
+	item := &Item[Key]{
+		Key:        Key{X: "A", Y: "B"},
+		Attributes: map[string]any{"a": "x", "b": int64(2)},
+	}
+
+	b, l, err := testPack(item)
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+
+	calls := 0
+	flaky := func(ctx context.Context, keys []Key) (map[string][]byte, error) {
+		calls++
+		if calls < 3 {
+			return nil, errors.New("transient")
+		}
+		return l(ctx, keys)
+	}
+
+	policy := RetryPolicy{InitialBackoff: time.Millisecond}
+
+	e, err := testUnpack(b, WithRetryingDataLoader(flaky, policy))
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+	values, err := e.GetAllValues(context.TODO(), provider)
+	if err != nil {
+		t.Fatalf("Unexpected error getting values: %v", err)
+	}
+	if !maps.Equal(values, item.Attributes) {
+		t.Fatalf("Mismatch: expected %v, got %v", item.Attributes, values)
+	}
+
+	calls = -10
+	if _, err := testUnpack(b, WithRetryingDataLoader(flaky, policy)); err == nil {
+		t.Fatal("Expected an error once attempts are exhausted")
+	}
+	if calls != -7 {
+		t.Fatalf("Expected 3 attempts, got %d", calls+10)
+	}
+
+	calls = 0
+	permanent := errors.New("permanent")
+	policy.Retryable = func(err error) bool { return !errors.Is(err, permanent) }
+	failing := func(context.Context, []Key) (map[string][]byte, error) {
+		calls++
+		return nil, permanent
+	}
+	if _, err := WithRetryingDataLoader(failing, policy)(context.TODO(), []Key{item.Key}); !errors.Is(err, permanent) || calls != 1 {
+		t.Fatalf("Expected a single attempt for a permanent error, got %d: %v", calls, err)
+	}
+
+	if WithRetryingDataLoader[Key](nil, policy) != nil {
+		t.Fatal("Expected a nil loader to be returned unchanged")
+	}
+}
+
+func TestWithRetryingDataLoaderPartial(t *testing.T) {
+
+	var requested [][]string
+	loader := func(ctx context.Context, keys []string) (map[string][]byte, error) {
+		requested = append(requested, slices.Clone(keys))
+		m := map[string][]byte{}
+		var failed []string
+		for _, k := range keys {
+			if k == "b" && len(requested) == 1 {
+				failed = append(failed, k)
+				continue
+			}
+			m[k] = []byte(k)
+		}
+		if len(failed) > 0 {
+			return m, &PartialLoadError[string]{Keys: failed, Err: errors.New("throttled")}
+		}
+		return m, nil
+	}
+
+	m, err := WithRetryingDataLoader(loader, RetryPolicy{InitialBackoff: time.Millisecond})(context.TODO(), []string{"a", "b", "c"})
+	if err != nil {
+		t.Fatalf("Unexpected error loading: %v", err)
+	}
+	if len(m) != 3 {
+		t.Fatalf("Expected merged results for all keys, got %v", m)
+	}
+	if len(requested) != 2 || !slices.Equal(requested[1], []string{"b"}) {
+		t.Fatalf("Expected only the failed key to be retried, got %v", requested)
+	}
+}
+
+func TestWithRetryingDataLoaderAttemptTimeout(t *testing.T) {
+
+	calls := 0
+	loader := func(ctx context.Context, keys []string) (map[string][]byte, error) {
+		calls++
+		if calls == 1 {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return map[string][]byte{"a": nil}, nil
+	}
+
+	policy := RetryPolicy{InitialBackoff: time.Millisecond, AttemptTimeout: 10 * time.Millisecond}
+	if _, err := WithRetryingDataLoader(loader, policy)(context.TODO(), []string{"a"}); err != nil || calls != 2 {
+		t.Fatalf("Expected the timed out attempt to be retried, got %d: %v", calls, err)
+	}
+
+	calls = 0
+	policy.MaxAttempts = 1
+	if _, err := WithRetryingDataLoader(loader, policy)(context.TODO(), []string{"a"}); !errors.Is(err, ErrDataLoaderAttemptTimedOut) {
+		t.Fatalf("Expected ErrDataLoaderAttemptTimedOut, got: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+	calls = 0
+	policy.MaxAttempts = 3
+	if _, err := WithRetryingDataLoader(loader, policy)(ctx, []string{"a"}); !errors.Is(err, context.Canceled) || calls != 1 {
+		t.Fatalf("Expected cancellation to stop retries, got %d: %v", calls, err)
+	}
+}
@@ -0,0 +1,70 @@
+package packer
+
+import (
+	"context"
+	"errors"
+)
+
+// DataSaver writes the output of Pack to storage as a single transaction: nothing written with Put or PutInfo
+// is visible until Commit succeeds, and all of it is discarded by Rollback
+type DataSaver[T comparable] interface {
+	// Put stages the attribute data of the element with the key
+	Put(ctx context.Context, key T, data map[string][]byte) error
+	// PutInfo stages the packed data of the item with the key
+	PutInfo(ctx context.Context, key T, info []byte) error
+	// Commit makes everything staged visible
+	Commit(ctx context.Context) error
+	// Rollback discards everything staged
+	Rollback(ctx context.Context) error
+}
+
+// ErrDataSaverIsNil raised if PackAndStore is called without a DataSaver
+var ErrDataSaverIsNil = errors.New("data saver must not be nil")
+
+// PackAndStore packs the item and writes its elements and packed data with the saver, committing once all
+// have been staged, so that a failure part way through cannot leave orphaned elements in storage.
+// If packing or any write fails, the saver is rolled back and the error returned, joined with any error
+// from the rollback.  The packed data is also returned.
+func PackAndStore[T comparable](ctx context.Context, saver DataSaver[T], item *Item[T], params *PackParams[T], opts ...func(*Options)) ([]byte, error) {
+
+	if saver == nil {
+		return nil, ErrDataSaverIsNil
+	}
+
+	info, err := packAndStage(ctx, saver, item, params, opts...)
+	if err == nil {
+		err = saver.Commit(ctx)
+	}
+	if err != nil {
+		if rbErr := saver.Rollback(context.WithoutCancel(ctx)); rbErr != nil {
+			err = errors.Join(err, rbErr)
+		}
+		return nil, err
+	}
+
+	return info, nil
+}
+
+// packAndStage packs the item and stages its elements, and then its packed data, with the saver
+func packAndStage[T comparable](ctx context.Context, saver DataSaver[T], item *Item[T], params *PackParams[T], opts ...func(*Options)) ([]byte, error) {
+
+	info, data, err := PackWithContext(ctx, item, params, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, attrs := range data {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := saver.Put(ctx, key, attrs); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := saver.PutInfo(ctx, item.Key, info); err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
@@ -0,0 +1,126 @@
+package packer
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"testing"
+
+	"github.com/gford1000-go/serialise"
+)
+
+// testSaver stages writes, applying them to the committed maps only on Commit
+type testSaver struct {
+	failPut    int
+	failCommit bool
+	puts       int
+	staged     map[Key]map[string][]byte
+	info       map[Key][]byte
+	elements   map[Key]map[string][]byte
+	committed  map[Key][]byte
+	rolledBack bool
+}
+
+func newTestSaver() *testSaver {
+	return &testSaver{
+		staged:    map[Key]map[string][]byte{},
+		info:      map[Key][]byte{},
+		elements:  map[Key]map[string][]byte{},
+		committed: map[Key][]byte{},
+	}
+}
+
+func (s *testSaver) Put(_ context.Context, key Key, data map[string][]byte) error {
+	s.puts++
+	if s.puts == s.failPut {
+		return errors.New("put failed")
+	}
+	s.staged[key] = data
+	return nil
+}
+
+func (s *testSaver) PutInfo(_ context.Context, key Key, info []byte) error {
+	s.info[key] = info
+	return nil
+}
+
+func (s *testSaver) Commit(context.Context) error {
+	if s.failCommit {
+		return errors.New("commit failed")
+	}
+	maps.Copy(s.elements, s.staged)
+	maps.Copy(s.committed, s.info)
+	return nil
+}
+
+func (s *testSaver) Rollback(context.Context) error {
+	s.rolledBack = true
+	clear(s.staged)
+	clear(s.info)
+	return nil
+}
+
+func TestPackAndStore(t *testing.T) {
+
+	_, testUnpack, provider := testCreateEnv(t)
+
+	serialiser, _ := NewKeySerialiser()
+	pParams := &PackParams[Key]{
+		Provider: provider,
+		Creator:  NewKeyCreator(defaultLen),
+		Packer:   serialiser,
+		Approach: serialise.NewMinDataApproachWithVersion(serialise.V1),
+	}
+
+	item := &Item[Key]{
+		Key:        Key{X: "A", Y: "B"},
+		Attributes: map[string]any{"a": "x", "b": int64(2)},
+	}
+
+	saver := newTestSaver()
+	info, err := PackAndStore(context.TODO(), saver, item, pParams)
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+	if len(saver.committed) != 1 || len(saver.elements) == 0 {
+		t.Fatal("Expected the item to be committed")
+	}
+
+	loader := func(_ context.Context, keys []Key) (map[string][]byte, error) {
+		m := map[string][]byte{}
+		for _, k := range keys {
+			maps.Copy(m, saver.elements[k])
+		}
+		return m, nil
+	}
+	e, err := testUnpack(info, loader)
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+	values, err := e.GetAllValues(context.TODO(), provider)
+	if err != nil {
+		t.Fatalf("Unexpected error getting values: %v", err)
+	}
+	if !maps.Equal(values, item.Attributes) {
+		t.Fatalf("Mismatch: expected %v, got %v", item.Attributes, values)
+	}
+
+	saver = newTestSaver()
+	saver.failPut = 1
+	if _, err := PackAndStore(context.TODO(), saver, item, pParams); err == nil {
+		t.Fatal("Expected an error when a put fails")
+	}
+	if !saver.rolledBack || len(saver.elements) != 0 || len(saver.committed) != 0 {
+		t.Fatal("Expected the saver to be rolled back")
+	}
+
+	saver = newTestSaver()
+	saver.failCommit = true
+	if _, err := PackAndStore(context.TODO(), saver, item, pParams); err == nil || !saver.rolledBack {
+		t.Fatalf("Expected a failed commit to be rolled back, got: %v", err)
+	}
+
+	if _, err := PackAndStore[Key](context.TODO(), nil, item, pParams); !errors.Is(err, ErrDataSaverIsNil) {
+		t.Fatalf("Expected ErrDataSaverIsNil, got: %v", err)
+	}
+}
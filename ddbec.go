@@ -0,0 +1,495 @@
+package packer
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Attribute names used by the DynamoDB Encryption Client to hold the material description and signature
+const (
+	DDBECMaterialDescriptionAttribute = "*amzn-ddb-map-desc*"
+	DDBECSignatureAttribute           = "*amzn-ddb-map-sig*"
+)
+
+// DDBECAction describes how the DynamoDB Encryption Client protects an attribute
+type DDBECAction uint8
+
+const (
+	// DDBECDoNothing leaves the attribute unencrypted and unsigned
+	DDBECDoNothing DDBECAction = iota
+	// DDBECSignOnly leaves the attribute unencrypted, but includes it in the signature
+	DDBECSignOnly
+	// DDBECEncryptAndSign encrypts the attribute, and includes it in the signature
+	DDBECEncryptAndSign
+)
+
+// DDBNumber is a DynamoDB number, held in its string form to preserve precision
+type DDBNumber string
+
+// DDBECMaterials holds the symmetric keys of a DynamoDB Encryption Client wrapped materials provider
+type DDBECMaterials struct {
+	// WrappingKey is the AES key that wraps the content key of each item
+	WrappingKey []byte
+	// SigningKey is the HMAC-SHA256 key that signs each item
+	SigningKey []byte
+}
+
+const (
+	ddbecEnvelopeKey  = "amzn-ddb-env-key"
+	ddbecEnvelopeAlg  = "amzn-ddb-env-alg"
+	ddbecWrapAlg      = "amzn-ddb-wrap-alg"
+	ddbecSignatureAlg = "amzn-ddb-sig-alg"
+	ddbecSymMode      = "amzn-ddb-map-sym-mode"
+)
+
+// ErrDDBECMaterialsInvalid raised if the DDBECMaterials do not hold valid keys
+var ErrDDBECMaterialsInvalid = errors.New("DynamoDB Encryption Client materials must include an AES wrapping key and a signing key")
+
+// ErrDDBECUnsupportedValue raised if an attribute value cannot be represented as a DynamoDB attribute value
+var ErrDDBECUnsupportedValue = errors.New("attribute value is not supported by the DynamoDB Encryption Client mode")
+
+// ErrDDBECInvalidItem raised if an item is not in the DynamoDB Encryption Client layout
+var ErrDDBECInvalidItem = errors.New("item is not in the DynamoDB Encryption Client layout")
+
+// ErrDDBECSignatureMismatch raised if the signature of an item does not match its attributes
+var ErrDDBECSignatureMismatch = errors.New("DynamoDB Encryption Client item signature does not match")
+
+func (m *DDBECMaterials) validate() error {
+	if m == nil || len(m.SigningKey) == 0 {
+		return ErrDDBECMaterialsInvalid
+	}
+	switch len(m.WrappingKey) {
+	case 16, 24, 32:
+		return nil
+	}
+	return ErrDDBECMaterialsInvalid
+}
+
+// EncryptDDBECItem protects the attributes of an item of the table according to their actions, returning the item in
+// the layout written by the DynamoDB Encryption Client using wrapped symmetric materials: encrypted attributes hold
+// binary values, and the material description and signature are added as attributes.  The signature is bound to the
+// table name, as by the DynamoDB Encryption Client.  Attributes without an action are left unchanged.
+// Values may be string, DDBNumber, integers, floats, []byte, bool or nil.
+func EncryptDDBECItem(table string, attrs map[string]any, actions map[string]DDBECAction, materials *DDBECMaterials) (map[string]any, error) {
+
+	if err := materials.validate(); err != nil {
+		return nil, err
+	}
+
+	contentKey := make([]byte, 32)
+	if _, err := rand.Read(contentKey); err != nil {
+		return nil, err
+	}
+	wrapped, err := aesKeyWrap(materials.WrappingKey, contentKey)
+	if err != nil {
+		return nil, err
+	}
+
+	desc := map[string]string{
+		ddbecEnvelopeKey:  base64.StdEncoding.EncodeToString(wrapped),
+		ddbecEnvelopeAlg:  "AES/256",
+		ddbecWrapAlg:      "AESWrap",
+		ddbecSignatureAlg: "HmacSHA256/256",
+		ddbecSymMode:      "/CBC/PKCS5Padding",
+	}
+
+	out := make(map[string]any, len(attrs)+2)
+	out[DDBECMaterialDescriptionAttribute] = marshalDDBECMaterialDescription(desc)
+
+	for name, v := range attrs {
+		if actions[name] != DDBECEncryptAndSign {
+			out[name] = v
+			continue
+		}
+		b, err := marshalDDBValue(v)
+		if err != nil {
+			return nil, fmt.Errorf("%w: attribute '%s'", err, name)
+		}
+		out[name], err = ddbecEncrypt(contentKey, b)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sig, err := ddbecSignature(table, out, actions, materials.SigningKey)
+	if err != nil {
+		return nil, err
+	}
+	out[DDBECSignatureAttribute] = sig
+
+	return out, nil
+}
+
+// DecryptDDBECItem verifies the signature of an item of the table written by the DynamoDB Encryption Client using
+// wrapped symmetric materials, and decrypts its encrypted attributes, returning the plaintext attributes so that they
+// can be packed (for example, when migrating an existing table).  The material description and signature
+// attributes are not returned.  Numbers are returned as DDBNumber, in the canonical form signed by the client.
+func DecryptDDBECItem(table string, item map[string]any, actions map[string]DDBECAction, materials *DDBECMaterials) (map[string]any, error) {
+
+	if err := materials.validate(); err != nil {
+		return nil, err
+	}
+
+	bDesc, ok := item[DDBECMaterialDescriptionAttribute].([]byte)
+	if !ok {
+		return nil, ErrDDBECInvalidItem
+	}
+	sig, ok := item[DDBECSignatureAttribute].([]byte)
+	if !ok {
+		return nil, ErrDDBECInvalidItem
+	}
+
+	expected, err := ddbecSignature(table, item, actions, materials.SigningKey)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal(sig, expected) {
+		return nil, ErrDDBECSignatureMismatch
+	}
+
+	desc, err := unmarshalDDBECMaterialDescription(bDesc)
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := base64.StdEncoding.DecodeString(desc[ddbecEnvelopeKey])
+	if err != nil {
+		return nil, ErrDDBECInvalidItem
+	}
+	contentKey, err := aesKeyUnwrap(materials.WrappingKey, wrapped)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[string]any, len(item))
+	for name, v := range item {
+		if name == DDBECMaterialDescriptionAttribute || name == DDBECSignatureAttribute {
+			continue
+		}
+		if actions[name] != DDBECEncryptAndSign {
+			out[name] = v
+			continue
+		}
+		b, ok := v.([]byte)
+		if !ok {
+			return nil, ErrDDBECInvalidItem
+		}
+		pt, err := ddbecDecrypt(contentKey, b)
+		if err != nil {
+			return nil, err
+		}
+		out[name], err = unmarshalDDBValue(pt)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return out, nil
+}
+
+// ddbecSignature returns the HMAC-SHA256 of the string to sign of the item
+func ddbecSignature(table string, item map[string]any, actions map[string]DDBECAction, key []byte) ([]byte, error) {
+	b, err := ddbecStringToSign(table, item, actions)
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(b)
+	return mac.Sum(nil), nil
+}
+
+// ddbecStringToSign returns the data signed by the DynamoDB Encryption Client: the hash of the table name as
+// associated data, followed by the hashes of the name, the encryption flag and the serialised value of each
+// attribute that is not DDBECDoNothing, in name order.  The material description is signed, but not encrypted.
+func ddbecStringToSign(table string, item map[string]any, actions map[string]DDBECAction) ([]byte, error) {
+
+	names := []string{}
+	for name := range item {
+		if name == DDBECSignatureAttribute {
+			continue
+		}
+		if name == DDBECMaterialDescriptionAttribute || actions[name] != DDBECDoNothing {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	// The flags are hashed as the single bytes 0 (encrypted) and 1 (plaintext)
+	encrypted, plaintext := sha256.Sum256([]byte{0}), sha256.Sum256([]byte{1})
+
+	h := sha256.Sum256([]byte("TABLE>" + table + "<TABLE"))
+	out := append([]byte{}, h[:]...)
+
+	for _, name := range names {
+		b, err := marshalDDBValue(item[name])
+		if err != nil {
+			return nil, fmt.Errorf("%w: attribute '%s'", err, name)
+		}
+		h = sha256.Sum256([]byte(name))
+		out = append(out, h[:]...)
+		if name != DDBECMaterialDescriptionAttribute && actions[name] == DDBECEncryptAndSign {
+			out = append(out, encrypted[:]...)
+		} else {
+			out = append(out, plaintext[:]...)
+		}
+		h = sha256.Sum256(b)
+		out = append(out, h[:]...)
+	}
+	return out, nil
+}
+
+// ddbecEncrypt encrypts using AES-CBC with PKCS#5 padding, prefixing the ciphertext with the IV
+func ddbecEncrypt(key, plaintext []byte) ([]byte, error) {
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	pad := aes.BlockSize - len(plaintext)%aes.BlockSize
+	padded := append(bytes.Clone(plaintext), bytes.Repeat([]byte{byte(pad)}, pad)...)
+
+	out := make([]byte, aes.BlockSize+len(padded))
+	if _, err := rand.Read(out[:aes.BlockSize]); err != nil {
+		return nil, err
+	}
+	cipher.NewCBCEncrypter(block, out[:aes.BlockSize]).CryptBlocks(out[aes.BlockSize:], padded)
+	return out, nil
+}
+
+func ddbecDecrypt(key, data []byte) ([]byte, error) {
+
+	if len(data) < 2*aes.BlockSize || len(data)%aes.BlockSize != 0 {
+		return nil, ErrDDBECInvalidItem
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, len(data)-aes.BlockSize)
+	cipher.NewCBCDecrypter(block, data[:aes.BlockSize]).CryptBlocks(out, data[aes.BlockSize:])
+
+	pad := int(out[len(out)-1])
+	if pad == 0 || pad > aes.BlockSize || !bytes.Equal(out[len(out)-pad:], bytes.Repeat([]byte{byte(pad)}, pad)) {
+		return nil, ErrDDBECInvalidItem
+	}
+	return out[:len(out)-pad], nil
+}
+
+// marshalDDBECMaterialDescription serialises the description as a four byte version, followed by
+// length-prefixed names and values
+func marshalDDBECMaterialDescription(desc map[string]string) []byte {
+
+	names := make([]string, 0, len(desc))
+	for k := range desc {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	b := []byte{0, 0, 0, 0}
+	for _, k := range names {
+		b = binary.BigEndian.AppendUint32(b, uint32(len(k)))
+		b = append(b, k...)
+		b = binary.BigEndian.AppendUint32(b, uint32(len(desc[k])))
+		b = append(b, desc[k]...)
+	}
+	return b
+}
+
+func unmarshalDDBECMaterialDescription(b []byte) (map[string]string, error) {
+
+	if len(b) < 4 || binary.BigEndian.Uint32(b) != 0 {
+		return nil, ErrDDBECInvalidItem
+	}
+	b = b[4:]
+
+	field := func() (string, bool) {
+		if len(b) < 4 {
+			return "", false
+		}
+		n := binary.BigEndian.Uint32(b)
+		if uint64(len(b)-4) < uint64(n) {
+			return "", false
+		}
+		s := string(b[4 : 4+n])
+		b = b[4+n:]
+		return s, true
+	}
+
+	desc := map[string]string{}
+	for len(b) > 0 {
+		k, ok := field()
+		if !ok {
+			return nil, ErrDDBECInvalidItem
+		}
+		v, ok := field()
+		if !ok {
+			return nil, ErrDDBECInvalidItem
+		}
+		desc[k] = v
+	}
+	return desc, nil
+}
+
+// DynamoDB attribute value type tags, as serialised by the DynamoDB Encryption Client.  Scalar types use
+// lower case tags, as the upper case tags identify sets.
+const (
+	ddbTypeString uint16 = 's'
+	ddbTypeNumber uint16 = 'n'
+	ddbTypeBinary uint16 = 'b'
+	ddbTypeBool   uint16 = '?'
+	ddbTypeNull   uint16 = '$'
+)
+
+// marshalDDBValue serialises the value as a type tag followed by its length-prefixed content.  Numbers
+// are serialised in canonical form, so that equal numbers have the same signature.
+func marshalDDBValue(v any) ([]byte, error) {
+
+	tagged := func(tag uint16, content []byte) []byte {
+		b := binary.BigEndian.AppendUint16(nil, tag)
+		if tag == ddbTypeNumber {
+			content = []byte(canonicalDDBNumber(string(content)))
+		}
+		b = binary.BigEndian.AppendUint32(b, uint32(len(content)))
+		return append(b, content...)
+	}
+
+	switch x := v.(type) {
+	case nil:
+		return append(binary.BigEndian.AppendUint16(nil, ddbTypeNull), 1), nil
+	case bool:
+		b := binary.BigEndian.AppendUint16(nil, ddbTypeBool)
+		if x {
+			return append(b, 1), nil
+		}
+		return append(b, 0), nil
+	case string:
+		return tagged(ddbTypeString, []byte(x)), nil
+	case []byte:
+		return tagged(ddbTypeBinary, x), nil
+	case DDBNumber:
+		return tagged(ddbTypeNumber, []byte(x)), nil
+	case int:
+		return tagged(ddbTypeNumber, strconv.AppendInt(nil, int64(x), 10)), nil
+	case int8:
+		return tagged(ddbTypeNumber, strconv.AppendInt(nil, int64(x), 10)), nil
+	case int16:
+		return tagged(ddbTypeNumber, strconv.AppendInt(nil, int64(x), 10)), nil
+	case int32:
+		return tagged(ddbTypeNumber, strconv.AppendInt(nil, int64(x), 10)), nil
+	case int64:
+		return tagged(ddbTypeNumber, strconv.AppendInt(nil, x, 10)), nil
+	case uint8:
+		return tagged(ddbTypeNumber, strconv.AppendUint(nil, uint64(x), 10)), nil
+	case uint16:
+		return tagged(ddbTypeNumber, strconv.AppendUint(nil, uint64(x), 10)), nil
+	case uint32:
+		return tagged(ddbTypeNumber, strconv.AppendUint(nil, uint64(x), 10)), nil
+	case uint64:
+		return tagged(ddbTypeNumber, strconv.AppendUint(nil, x, 10)), nil
+	case float32:
+		return tagged(ddbTypeNumber, strconv.AppendFloat(nil, float64(x), 'g', -1, 32)), nil
+	case float64:
+		return tagged(ddbTypeNumber, strconv.AppendFloat(nil, x, 'g', -1, 64)), nil
+	}
+	return nil, fmt.Errorf("%w: %T", ErrDDBECUnsupportedValue, v)
+}
+
+// canonicalDDBNumber returns the number without exponent, leading zeros or trailing fractional zeros, as
+// BigDecimal.stripTrailingZeros().toPlainString() in Java.  Strings that are not numbers are returned unchanged.
+func canonicalDDBNumber(s string) string {
+
+	n := strings.TrimSpace(s)
+	negative := false
+	if len(n) > 0 && (n[0] == '-' || n[0] == '+') {
+		negative = n[0] == '-'
+		n = n[1:]
+	}
+
+	exp := 0
+	if i := strings.IndexAny(n, "eE"); i >= 0 {
+		e, err := strconv.Atoi(n[i+1:])
+		if err != nil {
+			return s
+		}
+		exp, n = e, n[:i]
+	}
+
+	whole, frac, _ := strings.Cut(n, ".")
+	digits := whole + frac
+	if len(digits) == 0 || strings.Trim(digits, "0123456789") != "" {
+		return s
+	}
+
+	// The position of the decimal point within the digits
+	point := len(whole) + exp
+	trimmed := strings.TrimLeft(digits, "0")
+	point -= len(digits) - len(trimmed)
+	digits = strings.TrimRight(trimmed, "0")
+	if len(digits) == 0 {
+		return "0"
+	}
+
+	var b strings.Builder
+	if negative {
+		b.WriteByte('-')
+	}
+	switch {
+	case point <= 0:
+		b.WriteString("0.")
+		b.WriteString(strings.Repeat("0", -point))
+		b.WriteString(digits)
+	case point >= len(digits):
+		b.WriteString(digits)
+		b.WriteString(strings.Repeat("0", point-len(digits)))
+	default:
+		b.WriteString(digits[:point])
+		b.WriteByte('.')
+		b.WriteString(digits[point:])
+	}
+	return b.String()
+}
+
+func unmarshalDDBValue(b []byte) (any, error) {
+
+	if len(b) < 3 {
+		return nil, ErrDDBECInvalidItem
+	}
+	tag, b := binary.BigEndian.Uint16(b), b[2:]
+
+	switch tag {
+	case ddbTypeNull:
+		return nil, nil
+	case ddbTypeBool:
+		if len(b) != 1 {
+			return nil, ErrDDBECInvalidItem
+		}
+		return b[0] == 1, nil
+	}
+
+	if len(b) < 4 || uint64(len(b)-4) != uint64(binary.BigEndian.Uint32(b)) {
+		return nil, ErrDDBECInvalidItem
+	}
+	content := b[4:]
+
+	switch tag {
+	case ddbTypeString:
+		return string(content), nil
+	case ddbTypeNumber:
+		return DDBNumber(content), nil
+	case ddbTypeBinary:
+		return bytes.Clone(content), nil
+	}
+	return nil, ErrDDBECInvalidItem
+}
@@ -0,0 +1,142 @@
+package packer
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func TestDDBECItem(t *testing.T) {
+
+	materials := &DDBECMaterials{
+		WrappingKey: []byte("01234567890123456789012345678912"),
+		SigningKey:  []byte("signing key"),
+	}
+
+	attrs := map[string]any{
+		"pk":      "customer#1",
+		"name":    "Alice",
+		"balance": int64(100),
+		"photo":   []byte{1, 2, 3},
+		"active":  true,
+		"updated": "2024-01-01",
+		"note":    "not protected",
+	}
+	actions := map[string]DDBECAction{
+		"pk":      DDBECSignOnly,
+		"name":    DDBECEncryptAndSign,
+		"balance": DDBECEncryptAndSign,
+		"photo":   DDBECEncryptAndSign,
+		"active":  DDBECEncryptAndSign,
+		"updated": DDBECSignOnly,
+	}
+
+	item, err := EncryptDDBECItem("Customers", attrs, actions, materials)
+	if err != nil {
+		t.Fatalf("Unexpected error encrypting: %v", err)
+	}
+	if _, ok := item[DDBECMaterialDescriptionAttribute].([]byte); !ok {
+		t.Fatal("Expected material description attribute")
+	}
+	if _, ok := item[DDBECSignatureAttribute].([]byte); !ok {
+		t.Fatal("Expected signature attribute")
+	}
+	if _, ok := item["name"].([]byte); !ok || item["pk"] != "customer#1" {
+		t.Fatalf("Unexpected layout: %v", item)
+	}
+
+	out, err := DecryptDDBECItem("Customers", item, actions, materials)
+	if err != nil {
+		t.Fatalf("Unexpected error decrypting: %v", err)
+	}
+	if len(out) != len(attrs) || out["name"] != "Alice" || out["balance"] != DDBNumber("100") || out["active"] != true || !bytes.Equal(out["photo"].([]byte), []byte{1, 2, 3}) {
+		t.Fatalf("Unexpected attributes: %v", out)
+	}
+
+	// The signature is bound to the table
+	if _, err := DecryptDDBECItem("Other", item, actions, materials); !errors.Is(err, ErrDDBECSignatureMismatch) {
+		t.Fatalf("Unexpected error: expected: %v, got: %v", ErrDDBECSignatureMismatch, err)
+	}
+
+	// Unsigned attributes may change, signed attributes may not
+	item["note"] = "changed"
+	if _, err := DecryptDDBECItem("Customers", item, actions, materials); err != nil {
+		t.Fatalf("Unexpected error decrypting: %v", err)
+	}
+	item["updated"] = "2025-01-01"
+	if _, err := DecryptDDBECItem("Customers", item, actions, materials); !errors.Is(err, ErrDDBECSignatureMismatch) {
+		t.Fatalf("Unexpected error: expected: %v, got: %v", ErrDDBECSignatureMismatch, err)
+	}
+
+	if _, err := EncryptDDBECItem("Customers", map[string]any{"x": struct{}{}}, map[string]DDBECAction{"x": DDBECEncryptAndSign}, materials); !errors.Is(err, ErrDDBECUnsupportedValue) {
+		t.Fatalf("Unexpected error: expected: %v, got: %v", ErrDDBECUnsupportedValue, err)
+	}
+	if _, err := EncryptDDBECItem("Customers", attrs, actions, &DDBECMaterials{}); !errors.Is(err, ErrDDBECMaterialsInvalid) {
+		t.Fatalf("Unexpected error: expected: %v, got: %v", ErrDDBECMaterialsInvalid, err)
+	}
+}
+
+func TestDDBECItem_1(t *testing.T) {
+
+	// Known answer computed with the string to sign and attribute serialisation of the DynamoDB Encryption
+	// Client for Python (dynamodb_encryption_sdk.internal.crypto.authentication and formatting.serialize)
+	desc, _ := hex.DecodeString("0000000000000010616d7a6e2d6464622d656e762d616c67000000074145532f32353600000010616d7a6e2d6464622d7369672d616c670000000e486d61635348413235362f323536")
+	expected, _ := hex.DecodeString("76365e5f01da968bad0152f303c682764e8101ac70b66aad826c1bf25d1e5659")
+
+	if b := marshalDDBECMaterialDescription(map[string]string{ddbecEnvelopeAlg: "AES/256", ddbecSignatureAlg: "HmacSHA256/256"}); !bytes.Equal(b, desc) {
+		t.Fatalf("Mismatch in material description: expected %x, got %x", desc, b)
+	}
+
+	balance := make([]byte, 32)
+	for i := range balance {
+		balance[i] = byte(i)
+	}
+
+	item := map[string]any{
+		DDBECMaterialDescriptionAttribute: desc,
+		"pk":                              "customer#1",
+		"balance":                         balance,
+		"count":                           DDBNumber("1.500"),
+		"active":                          true,
+		"empty":                           nil,
+		"note":                            "not signed",
+	}
+	actions := map[string]DDBECAction{
+		"pk":      DDBECSignOnly,
+		"balance": DDBECEncryptAndSign,
+		"count":   DDBECSignOnly,
+		"active":  DDBECSignOnly,
+		"empty":   DDBECSignOnly,
+	}
+
+	sig, err := ddbecSignature("TestTable", item, actions, []byte("0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		t.Fatalf("Unexpected error signing: %v", err)
+	}
+	if !bytes.Equal(sig, expected) {
+		t.Fatalf("Mismatch in signature: expected %x, got %x", expected, sig)
+	}
+}
+
+func TestCanonicalDDBNumber(t *testing.T) {
+
+	for _, test := range []struct {
+		in, out string
+	}{
+		{"100", "100"},
+		{"1.500", "1.5"},
+		{"-0.0120", "-0.012"},
+		{"0", "0"},
+		{"-0.0", "0"},
+		{"007", "7"},
+		{"1.5e3", "1500"},
+		{"1E-3", "0.001"},
+		{"+12.30e1", "123"},
+		{"abc", "abc"},
+	} {
+		if got := canonicalDDBNumber(test.in); got != test.out {
+			t.Fatalf("(%s) Unexpected canonical form: expected %s, got %s", test.in, test.out, got)
+		}
+	}
+}
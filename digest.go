@@ -0,0 +1,114 @@
+package packer
+
+import (
+	"crypto/hmac"
+	"encoding/binary"
+	"errors"
+	"sort"
+
+	"github.com/gford1000-go/serialise"
+)
+
+// WithDigest records a keyed digest (HMAC-SHA256, unless changed by WithDigestHash) of the item's key and canonical plaintext attributes
+// in the visible part of the envelope.  Packs created with the same digest key can then be compared
+// using CompareDigests, without unwrapping their data encryption keys, to determine whether they
+// contain identical data.  The digest key must be kept secret, as it allows guesses of the plaintext
+// to be confirmed.
+func WithDigest(key []byte) func(o *Options) {
+	return func(o *Options) {
+		o.digestKey = key
+	}
+}
+
+// WithDigestHash selects the hash used by the HMAC of WithDigest.  If not set, SHA256 is used.  Other
+// algorithms are recorded as the first byte of the digest, so digests created with different algorithms
+// never compare as equal.
+func WithDigestHash(h HashAlgorithm) func(o *Options) {
+	if h >= hashAlgorithmOutOfRange {
+		panic("invalid HashAlgorithm value provided")
+	}
+	return func(o *Options) {
+		o.digestHash = h
+	}
+}
+
+// ErrNoDigest raised if packed data does not include a digest
+var ErrNoDigest = errors.New("packed data does not include a digest")
+
+// canonicalAttribute returns the serialised plaintext of an attribute value, without encryption or compression
+func (d *itemPackingDetailsV1[T]) canonicalAttribute(v any) ([]byte, error) {
+	vals, err := d.attributeValues(v)
+	if err != nil {
+		return nil, err
+	}
+	b, _, err := serialise.ToBytesMany(vals, d.plainSerialiseOptions...)
+	return b, err
+}
+
+// createDigest returns the keyed digest of the serialised key and canonical attribute values, taken in name order
+func createDigest(digestKey []byte, alg HashAlgorithm, bKey []byte, canonical map[string][]byte) ([]byte, error) {
+
+	if err := alg.Available(); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(canonical))
+	for k := range canonical {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	h := hmac.New(alg.new(), digestKey)
+	write := func(b []byte) {
+		var l [8]byte
+		binary.BigEndian.PutUint64(l[:], uint64(len(b)))
+		h.Write(l[:])
+		h.Write(b)
+	}
+
+	write(bKey)
+	for _, name := range names {
+		write([]byte(name))
+		write(canonical[name])
+	}
+
+	// Digests using the original algorithm are unchanged from earlier releases
+	if alg == SHA256 {
+		return h.Sum(nil), nil
+	}
+	return h.Sum([]byte{byte(alg)}), nil
+}
+
+// PackDigest returns the digest recorded in the data returned by Pack, if WithDigest was used.
+// The envelope key is not required, but an envelope split across continuation elements must first be
+// joined (see JoinEnvelope).
+func PackDigest(data []byte) ([]byte, error) {
+
+	finalisedData, err := splitFinalisedData(data)
+	if err != nil {
+		return nil, err
+	}
+	digest, err := finalisedField(finalisedData, finalisedDigest)
+	if err != nil {
+		return nil, ErrUnpackInvalidData
+	}
+	if len(digest) == 0 {
+		return nil, ErrNoDigest
+	}
+	return digest, nil
+}
+
+// CompareDigests returns true if the two sets of data returned by Pack contain the same key and
+// attribute values, as determined by their recorded digests (see WithDigest).  The result is only
+// meaningful if both were packed using the same digest key.
+func CompareDigests(a, b []byte) (bool, error) {
+	da, err := PackDigest(a)
+	if err != nil {
+		return false, err
+	}
+	db, err := PackDigest(b)
+	if err != nil {
+		return false, err
+	}
+	return hmac.Equal(da, db), nil
+}
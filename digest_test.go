@@ -0,0 +1,79 @@
+package packer
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestWithDigest(t *testing.T) {
+
+	testPack, testUnpack, provider := testCreateEnv(t)
+
+	digestKey := []byte("digest key")
+
+	newItem := func(v string) *Item[Key] {
+		return &Item[Key]{
+			Key: Key{X: "A", Y: "B"},
+			Attributes: map[string]any{
+				"aaa": v,
+				"bbb": int64(42),
+			},
+		}
+	}
+
+	a, l, err := testPack(newItem("Hello"), WithDigest(digestKey))
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+	b, _, err := testPack(newItem("Hello"), WithDigest(digestKey), WithCompression(FlateCompression))
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+	c, _, err := testPack(newItem("World"), WithDigest(digestKey))
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+	d, _, err := testPack(newItem("Hello"), WithDigest([]byte("other key")))
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+	none, _, err := testPack(newItem("Hello"))
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+
+	tests := []struct {
+		a, b     []byte
+		expected bool
+		err      error
+	}{
+		{a: a, b: b, expected: true},
+		{a: a, b: c},
+		{a: a, b: d},
+		{a: a, b: none, err: ErrNoDigest},
+	}
+
+	for i, test := range tests {
+		same, err := CompareDigests(test.a, test.b)
+		if !errors.Is(err, test.err) {
+			t.Fatalf("(%d) Unexpected error: expected: %v, got: %v", i, test.err, err)
+		}
+		if same != test.expected {
+			t.Fatalf("(%d) Unexpected comparison: expected: %v, got: %v", i, test.expected, same)
+		}
+	}
+
+	// Data with a digest can still be unpacked
+	e, err := testUnpack(a, l)
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+	m, err := e.GetValues(context.TODO(), []string{"aaa"}, provider)
+	if err != nil {
+		t.Fatalf("Unexpected error getting values: %v", err)
+	}
+	if m["aaa"].(string) != "Hello" {
+		t.Fatalf("Unexpected value: %v", m["aaa"])
+	}
+}
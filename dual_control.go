@@ -0,0 +1,124 @@
+package packer
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+
+	"github.com/gford1000-go/serialise"
+)
+
+// ErrDualControlProvidersRequired raised if either provider is not supplied to NewDualControlProvider
+var ErrDualControlProvidersRequired = errors.New("dual control requires two independent providers")
+
+// ErrDualControlKeyMismatch raised if the key shares vended by the two providers differ in length
+var ErrDualControlKeyMismatch = errors.New("dual control providers returned key shares of different lengths")
+
+// NewDualControlProvider creates an EnvelopeKeyProvider that splits each data encryption key into two
+// shares, with the key being the XOR of the shares.  Each share is vended and wrapped by one of the providers,
+// so that GetValues can only decrypt attribute values when both providers are supplied, giving simple
+// two-party control.  The providers should be independently administered; neither alone reveals the key.
+func NewDualControlProvider(first, second EnvelopeKeyProvider) (EnvelopeKeyProvider, error) {
+	if first == nil || second == nil {
+		return nil, ErrDualControlProvidersRequired
+	}
+	return &dualControlProvider{
+		first:  first,
+		second: second,
+		id:     first.ID() + "+" + second.ID(),
+	}, nil
+}
+
+type dualControlProvider struct {
+	first  EnvelopeKeyProvider
+	second EnvelopeKeyProvider
+	id     EnvelopeKeyID
+}
+
+func (d *dualControlProvider) ID() EnvelopeKeyID {
+	return d.id
+}
+
+func (d *dualControlProvider) New() ([]byte, []byte, error) {
+	return d.NewWithContext(context.Background())
+}
+
+func (d *dualControlProvider) NewWithContext(ctx context.Context) ([]byte, []byte, error) {
+
+	firstEncrypted, firstShare, err := newKey(ctx, d.first)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	secondEncrypted, secondShare, err := newKey(ctx, d.second)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key, err := combineShares(firstShare, secondShare)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	b, _, err := serialise.ToBytesMany(
+		[]any{
+			string(d.id),
+			firstEncrypted,
+			secondEncrypted,
+		}, serialise.WithSerialisationApproach(serialise.NewMinDataApproachWithVersion(serialise.V1)))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return b, key, nil
+}
+
+func (d *dualControlProvider) Decrypt(ctx context.Context, encryptedKey []byte) ([]byte, error) {
+
+	v, err := serialise.FromBytesMany(encryptedKey, serialise.NewMinDataApproachWithVersion(serialise.V1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(v) != 3 {
+		return nil, ErrKeyDeserialisationError
+	}
+
+	id, ok := v[0].(string)
+	if !ok {
+		return nil, ErrKeyDeserialisationError
+	}
+	if EnvelopeKeyID(id) != d.id {
+		return nil, ErrKeyProviderDecryptError
+	}
+
+	firstEncrypted, ok := v[1].([]byte)
+	if !ok {
+		return nil, ErrKeyDeserialisationError
+	}
+	secondEncrypted, ok := v[2].([]byte)
+	if !ok {
+		return nil, ErrKeyDeserialisationError
+	}
+
+	firstShare, err := d.first.Decrypt(ctx, firstEncrypted)
+	if err != nil {
+		return nil, err
+	}
+	secondShare, err := d.second.Decrypt(ctx, secondEncrypted)
+	if err != nil {
+		return nil, err
+	}
+
+	return combineShares(firstShare, secondShare)
+}
+
+// combineShares returns the XOR of the two key shares
+func combineShares(first, second []byte) ([]byte, error) {
+	if len(first) != len(second) {
+		return nil, ErrDualControlKeyMismatch
+	}
+	key := make([]byte, len(first))
+	subtle.XORBytes(key, first, second)
+	return key, nil
+}
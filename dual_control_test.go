@@ -0,0 +1,100 @@
+package packer
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"testing"
+
+	"github.com/gford1000-go/serialise"
+)
+
+func TestNewDualControlProvider(t *testing.T) {
+
+	newProvider := func(id EnvelopeKeyID) EnvelopeKeyProvider {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			t.Fatalf("Unexpected error creating key: %v", err)
+		}
+		finder := func(EnvelopeKeyID) (EnvelopeKeyProvider, error) {
+			return nil, errors.New("unknown provider id")
+		}
+		p, err := NewEnvelopeKeyProvider(&EnvelopeKeyProviderInfo{ID: id, Key: key}, finder)
+		if err != nil {
+			t.Fatalf("Unexpected error creating provider: %v", err)
+		}
+		return p
+	}
+
+	first, second := newProvider("first"), newProvider("second")
+
+	if _, err := NewDualControlProvider(first, nil); !errors.Is(err, ErrDualControlProvidersRequired) {
+		t.Fatalf("Unexpected error: expected: %v, got: %v", ErrDualControlProvidersRequired, err)
+	}
+
+	provider, err := NewDualControlProvider(first, second)
+	if err != nil {
+		t.Fatalf("Unexpected error creating provider: %v", err)
+	}
+
+	serialiser, err := NewKeySerialiser()
+	if err != nil {
+		t.Fatalf("Unexpected error preparing Key serialiser: %v", err)
+	}
+
+	item := &Item[Key]{
+		Key: Key{X: "A", Y: "B"},
+		Attributes: map[string]any{
+			"aaa": "Hello World",
+		},
+	}
+
+	info, data, err := Pack(item, &PackParams[Key]{
+		Provider: provider,
+		Creator:  NewKeyCreator(defaultLen),
+		Packer:   serialiser,
+		Approach: serialise.NewMinDataApproachWithVersion(serialise.V1),
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+
+	unpack := func(p EnvelopeKeyProvider) (*EncryptedItem[Key], error) {
+		return Unpack(context.TODO(), info, &UnpackParams[Key]{
+			IDRetriever: func(string) (IDSerialiser[Key], error) { return serialiser, nil },
+			Provider:    p,
+			DataLoader: func(ctx context.Context, keys []Key) (map[string][]byte, error) {
+				m := map[string][]byte{}
+				for _, key := range keys {
+					for k, v := range data[key] {
+						m[k] = v
+					}
+				}
+				return m, nil
+			},
+		})
+	}
+
+	e, err := unpack(provider)
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+
+	m, err := e.GetValues(context.TODO(), []string{"aaa"}, provider)
+	if err != nil {
+		t.Fatalf("Unexpected error getting values: %v", err)
+	}
+	if m["aaa"].(string) != "Hello World" {
+		t.Fatalf("Unexpected value: %v", m["aaa"])
+	}
+
+	// Neither party alone can decrypt
+	for _, p := range []EnvelopeKeyProvider{first, second} {
+		if _, err := e.GetValues(context.TODO(), []string{"aaa"}, p); err == nil {
+			t.Fatalf("Unexpected success getting values with only provider %v", p.ID())
+		}
+		if _, err := unpack(p); err == nil {
+			t.Fatalf("Unexpected success unpacking with only provider %v", p.ID())
+		}
+	}
+}
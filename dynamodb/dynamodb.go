@@ -0,0 +1,287 @@
+// Package dynamodb stores packed items in a DynamoDB table, providing a DataLoader and a writer for the
+// output of Pack.  Each element, and the packed data of each item, is held as a separate DynamoDB item.
+// As Pack stores the first element of an item under the key of the item, the packed data is held under
+// that key with InfoSuffix appended to its sort key, or to its partition key if the table has no sort key.
+//
+// To avoid a dependency on the AWS SDK, the table is accessed through the Client interface, which
+// is implemented by a thin wrapper around the BatchGetItem and BatchWriteItem operations of the SDK.
+// The wrapper should hold the Data of each Item in a single map attribute, so that chunk names cannot
+// collide with the names of the key attributes.
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gford1000-go/packer"
+)
+
+// Key is the primary key of a DynamoDB item
+type Key struct {
+	// PartitionKey is the value of the partition key attribute
+	PartitionKey string
+	// SortKey is the value of the sort key attribute, if the table has one
+	SortKey string
+}
+
+// Item is a DynamoDB item holding either an element or the packed data of an item
+type Item struct {
+	Key
+	// Data holds the chunk names and values of an element, or the packed data of an item
+	Data map[string][]byte
+}
+
+// Client is the subset of the DynamoDB API used by a Table
+type Client interface {
+	// BatchGetItem returns the requested items of the table that exist, and the keys of any that were not
+	// processed.  At most MaxBatchGetItems keys are requested at once, and no key is repeated.
+	BatchGetItem(ctx context.Context, table string, keys []Key) (items []Item, unprocessed []Key, err error)
+	// BatchWriteItem puts the items into the table, returning any that were not processed.  At most
+	// MaxBatchWriteItems items are written at once.
+	BatchWriteItem(ctx context.Context, table string, items []Item) (unprocessed []Item, err error)
+}
+
+// KeyMapper returns the primary key of the DynamoDB item holding the element or packed data with the key
+type KeyMapper[T comparable] func(key T) (Key, error)
+
+const (
+	// MaxBatchGetItems is the maximum number of keys of a single BatchGetItem request
+	MaxBatchGetItems = 100
+	// MaxBatchWriteItems is the maximum number of items of a single BatchWriteItem request
+	MaxBatchWriteItems = 25
+	// InfoName is the name under which the packed data of an item is held in its Data
+	InfoName = "info"
+	// InfoSuffix distinguishes the key holding the packed data of an item from the key of its first element
+	InfoSuffix = "#info"
+)
+
+// Options adjust the behaviour of a Table
+type Options struct {
+	// Maximum number of retries of unprocessed keys or items
+	maxRetries int
+	// Delay before the first retry, doubling for each subsequent retry
+	backoff time.Duration
+}
+
+// WithRetries sets the number of times unprocessed keys or items are retried, and the delay before the first
+// retry, which doubles for each subsequent retry.  If not set, 5 retries are made starting at 50ms.
+func WithRetries(maxRetries int, backoff time.Duration) func(*Options) {
+	return func(o *Options) {
+		o.maxRetries = maxRetries
+		o.backoff = backoff
+	}
+}
+
+// PackOptions returns the packer options that keep each element, and the packed data, within the 400KB
+// limit of a DynamoDB item, leaving room for the key and attribute names
+func PackOptions() []func(*packer.Options) {
+	return []func(*packer.Options){
+		packer.WithMaximumKBSize(380),
+	}
+}
+
+// ErrClientIsNil raised if New is called without a Client
+var ErrClientIsNil = errors.New("dynamodb client must not be nil")
+
+// ErrTableNameIsEmpty raised if New is called without a table name
+var ErrTableNameIsEmpty = errors.New("dynamodb table name must be specified")
+
+// ErrKeyMapperIsNil raised if New is called without a KeyMapper
+var ErrKeyMapperIsNil = errors.New("key mapper must not be nil")
+
+// ErrUnprocessed raised if keys or items remain unprocessed once all retries are exhausted
+var ErrUnprocessed = errors.New("dynamodb request remained unprocessed after retries")
+
+// Table stores packed items in a DynamoDB table, and implements packer.Store
+type Table[T comparable] struct {
+	client Client
+	name   string
+	keyOf  KeyMapper[T]
+	opts   Options
+}
+
+var _ packer.Store[string] = &Table[string]{}
+
+// New returns a Table storing items in the named table using the client, with the key of each
+// DynamoDB item determined by the KeyMapper
+func New[T comparable](client Client, table string, keyOf KeyMapper[T], opts ...func(*Options)) (*Table[T], error) {
+	if client == nil {
+		return nil, ErrClientIsNil
+	}
+	if len(table) == 0 {
+		return nil, ErrTableNameIsEmpty
+	}
+	if keyOf == nil {
+		return nil, ErrKeyMapperIsNil
+	}
+
+	o := Options{maxRetries: 5, backoff: 50 * time.Millisecond}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return &Table[T]{client: client, name: table, keyOf: keyOf, opts: o}, nil
+}
+
+// Load is a packer.DataLoader, returning the chunks of the elements with the keys
+func (t *Table[T]) Load(ctx context.Context, keys []T) (map[string][]byte, error) {
+
+	// BatchGetItem rejects repeated keys
+	seen := map[Key]bool{}
+	var pending []Key
+	for _, k := range keys {
+		key, err := t.keyOf(k)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[key] {
+			seen[key] = true
+			pending = append(pending, key)
+		}
+	}
+
+	items, err := t.get(ctx, pending)
+	if err != nil {
+		return nil, err
+	}
+
+	m := map[string][]byte{}
+	for _, item := range items {
+		for name, v := range item.Data {
+			m[name] = v
+		}
+	}
+	return m, nil
+}
+
+// Write stores the output of Pack: the elements, and then the packed data of the item with the key,
+// so that the table never holds packed data whose elements are missing
+func (t *Table[T]) Write(ctx context.Context, key T, info []byte, data map[T]map[string][]byte) error {
+	if err := t.PutAttrs(ctx, data); err != nil {
+		return err
+	}
+	return t.PutInfo(ctx, key, info)
+}
+
+// infoKey returns the primary key of the DynamoDB item holding the packed data of the item with the key
+func (t *Table[T]) infoKey(key T) (Key, error) {
+	k, err := t.keyOf(key)
+	if err != nil {
+		return Key{}, err
+	}
+	if len(k.SortKey) == 0 {
+		k.PartitionKey += InfoSuffix
+	} else {
+		k.SortKey += InfoSuffix
+	}
+	return k, nil
+}
+
+// PutInfo stores the packed data of the item with the key
+func (t *Table[T]) PutInfo(ctx context.Context, key T, info []byte) error {
+	k, err := t.infoKey(key)
+	if err != nil {
+		return err
+	}
+	return t.put(ctx, []Item{{Key: k, Data: map[string][]byte{InfoName: info}}})
+}
+
+// PutAttrs stores the chunks of each element
+func (t *Table[T]) PutAttrs(ctx context.Context, data map[T]map[string][]byte) error {
+	items := make([]Item, 0, len(data))
+	for k, m := range data {
+		key, err := t.keyOf(k)
+		if err != nil {
+			return err
+		}
+		items = append(items, Item{Key: key, Data: m})
+	}
+	return t.put(ctx, items)
+}
+
+// GetInfo returns the packed data of the item with the key, raising packer.ErrItemNotInStore if not present
+func (t *Table[T]) GetInfo(ctx context.Context, key T) ([]byte, error) {
+	k, err := t.infoKey(key)
+	if err != nil {
+		return nil, err
+	}
+	items, err := t.get(ctx, []Key{k})
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		if info, ok := item.Data[InfoName]; ok {
+			return info, nil
+		}
+	}
+	return nil, packer.ErrItemNotInStore
+}
+
+// BatchGetAttrs is equivalent to Load
+func (t *Table[T]) BatchGetAttrs(ctx context.Context, keys []T) (map[string][]byte, error) {
+	return t.Load(ctx, keys)
+}
+
+// get requests the keys in batches, retrying any that are unprocessed
+func (t *Table[T]) get(ctx context.Context, keys []Key) ([]Item, error) {
+	var items []Item
+	for start := 0; start < len(keys); start += MaxBatchGetItems {
+		pending := keys[start:min(start+MaxBatchGetItems, len(keys))]
+		err := t.retry(ctx, func() (int, error) {
+			found, unprocessed, err := t.client.BatchGetItem(ctx, t.name, pending)
+			if err != nil {
+				return 0, err
+			}
+			items = append(items, found...)
+			pending = unprocessed
+			return len(pending), nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return items, nil
+}
+
+// put writes the items in batches, retrying any that are unprocessed
+func (t *Table[T]) put(ctx context.Context, items []Item) error {
+	for start := 0; start < len(items); start += MaxBatchWriteItems {
+		pending := items[start:min(start+MaxBatchWriteItems, len(items))]
+		err := t.retry(ctx, func() (int, error) {
+			unprocessed, err := t.client.BatchWriteItem(ctx, t.name, pending)
+			if err != nil {
+				return 0, err
+			}
+			pending = unprocessed
+			return len(pending), nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// retry calls f until nothing remains unprocessed, backing off exponentially between attempts
+func (t *Table[T]) retry(ctx context.Context, f func() (int, error)) error {
+	delay := t.opts.backoff
+	for attempt := 0; ; attempt++ {
+		remaining, err := f()
+		if err != nil {
+			return err
+		}
+		if remaining == 0 {
+			return nil
+		}
+		if attempt == t.opts.maxRetries {
+			return ErrUnprocessed
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
@@ -0,0 +1,142 @@
+package dynamodb
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gford1000-go/packer"
+	"github.com/gford1000-go/serialise"
+)
+
+// testClient is an in-memory Client that leaves the last key or item of each request unprocessed once
+type testClient struct {
+	items     map[Key]map[string][]byte
+	throttled map[Key]bool
+	gets      int
+}
+
+func newTestClient() *testClient {
+	return &testClient{items: map[Key]map[string][]byte{}, throttled: map[Key]bool{}}
+}
+
+func (c *testClient) BatchGetItem(ctx context.Context, table string, keys []Key) ([]Item, []Key, error) {
+	if len(keys) > MaxBatchGetItems {
+		return nil, nil, errors.New("too many keys")
+	}
+	c.gets++
+	var items []Item
+	var unprocessed []Key
+	for i, k := range keys {
+		if i == len(keys)-1 && !c.throttled[k] {
+			c.throttled[k] = true
+			unprocessed = append(unprocessed, k)
+			continue
+		}
+		if m, ok := c.items[k]; ok {
+			items = append(items, Item{Key: k, Data: m})
+		}
+	}
+	return items, unprocessed, nil
+}
+
+func (c *testClient) BatchWriteItem(ctx context.Context, table string, items []Item) ([]Item, error) {
+	if len(items) > MaxBatchWriteItems {
+		return nil, errors.New("too many items")
+	}
+	var unprocessed []Item
+	for i, item := range items {
+		if i == len(items)-1 && !c.throttled[item.Key] {
+			c.throttled[item.Key] = true
+			unprocessed = append(unprocessed, item)
+			continue
+		}
+		c.items[item.Key] = item.Data
+	}
+	return unprocessed, nil
+}
+
+func TestTable(t *testing.T) {
+
+	ki := &packer.EnvelopeKeyProviderInfo{ID: "Key1", Key: []byte("01234567890123456789012345678912")}
+	provider, err := packer.NewEnvelopeKeyProvider(ki, func(packer.EnvelopeKeyID) (packer.EnvelopeKeyProvider, error) { return nil, errors.New("unknown") })
+	if err != nil {
+		t.Fatalf("Unexpected error creating provider: %v", err)
+	}
+	serialiser, _ := packer.NewKeySerialiser()
+
+	client := newTestClient()
+	table, err := New(client, "items", func(k packer.Key) (Key, error) { return Key{PartitionKey: k.X, SortKey: k.Y}, nil },
+		WithRetries(2, time.Millisecond))
+	if err != nil {
+		t.Fatalf("Unexpected error creating table: %v", err)
+	}
+
+	item := &packer.Item[packer.Key]{Key: packer.Key{X: "A", Y: "B"}, Attributes: map[string]any{}}
+	for i := range 600 {
+		b := make([]byte, 1024)
+		if _, err := rand.Read(b); err != nil {
+			t.Fatalf("Unexpected error creating data: %v", err)
+		}
+		item.Attributes[fmt.Sprintf("attr%d", i)] = b
+	}
+
+	pParams := &packer.PackParams[packer.Key]{
+		Provider: provider,
+		Creator:  packer.NewKeyCreator(10),
+		Packer:   serialiser,
+		Approach: serialise.NewMinDataApproachWithVersion(serialise.V1),
+	}
+	opts := append(PackOptions(), packer.WithMaximumKBSize(16))
+	if _, err := packer.PackInto(context.TODO(), table, item, pParams, opts...); err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+	if len(client.items) <= MaxBatchWriteItems {
+		t.Fatalf("Expected more than one batch of items, got %d", len(client.items))
+	}
+
+	uParams := &packer.UnpackParams[packer.Key]{
+		IDRetriever: func(string) (packer.IDSerialiser[packer.Key], error) { return serialiser, nil },
+		Provider:    provider,
+	}
+	e, err := packer.UnpackFromStore(context.TODO(), table, item.Key, uParams)
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+	values, err := e.GetAllValues(context.TODO(), provider)
+	if err != nil {
+		t.Fatalf("Unexpected error getting values: %v", err)
+	}
+	if len(values) != len(item.Attributes) {
+		t.Fatalf("Expected %d values, got %d", len(item.Attributes), len(values))
+	}
+
+	if _, err := table.GetInfo(context.TODO(), packer.Key{X: "missing"}); !errors.Is(err, packer.ErrItemNotInStore) {
+		t.Fatalf("Expected ErrItemNotInStore, got: %v", err)
+	}
+}
+
+func TestTableRetriesExhausted(t *testing.T) {
+
+	client := newTestClient()
+	table, _ := New(client, "items", func(k string) (Key, error) { return Key{PartitionKey: k}, nil }, WithRetries(0, time.Millisecond))
+
+	if err := table.PutAttrs(context.TODO(), map[string]map[string][]byte{"a": {"x": []byte("1")}}); !errors.Is(err, ErrUnprocessed) {
+		t.Fatalf("Expected ErrUnprocessed, got: %v", err)
+	}
+
+	// Repeated keys are requested once
+	client.items[Key{PartitionKey: "b"}] = map[string][]byte{"y": []byte("2")}
+	client.throttled[Key{PartitionKey: "b"}] = true
+	m, err := table.Load(context.TODO(), []string{"b", "b"})
+	if err != nil || len(m) != 1 || string(m["y"]) != "2" {
+		t.Fatalf("Unexpected result: %v, %v", m, err)
+	}
+
+	if _, err := New[string](nil, "items", nil); !errors.Is(err, ErrClientIsNil) {
+		t.Fatalf("Expected ErrClientIsNil, got: %v", err)
+	}
+}
@@ -0,0 +1,146 @@
+package packer
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"sort"
+
+	"github.com/gford1000-go/serialise"
+)
+
+// WithElementKeys additionally encrypts the chunks stored in each element using a subkey derived
+// from the item's data encryption key and the element's key, using HKDF-SHA256.  Exposure of an
+// element's subkey is then limited to that element, and each element can be re-encrypted
+// independently of the others.
+func WithElementKeys() func(o *Options) {
+	return func(o *Options) {
+		o.elementKeys = true
+	}
+}
+
+// ErrElementDecryptionFailed raised if a stored chunk cannot be decrypted using its element's subkey
+var ErrElementDecryptionFailed = errors.New("stored chunk could not be decrypted using its element key")
+
+// ErrInvalidDataToDeserialiseElementKeys raised if the recorded element key assignments cannot be deserialised
+var ErrInvalidDataToDeserialiseElementKeys = errors.New("invalid data, cannot deserialise element key assignments")
+
+// elementKeyOverhead is the size added to each chunk by element encryption (nonce and tag)
+const elementKeyOverhead = 12 + 16
+
+// elementKeyLabel is the default HKDF info prefix used to derive element subkeys
+const elementKeyLabel = "packer element key "
+
+// elementSubkey derives the subkey of the element from the data encryption key, using the label and element key as the HKDF info
+func elementSubkey[T comparable](dek []byte, label string, t T, packer IDSerialiser[T]) (cipher.AEAD, error) {
+	b, err := packer.Pack(t)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(hkdfSHA256(dek, nil, append([]byte(label), b...), 32))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealElements encrypts the chunks of each element with its subkey, returning the index of the element holding each chunk
+func sealElements[T comparable](dek []byte, label string, elements []T, output map[T]map[string][]byte, packer IDSerialiser[T]) (map[string]int, error) {
+
+	index := map[string]int{}
+	for i, t := range elements {
+		aead, err := elementSubkey(dek, label, t, packer)
+		if err != nil {
+			return nil, err
+		}
+		for name, v := range output[t] {
+			nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(v)+aead.Overhead())
+			if _, err := rand.Read(nonce); err != nil {
+				return nil, err
+			}
+			output[t][name] = aead.Seal(nonce, nonce, v, []byte(name))
+			index[name] = i
+		}
+	}
+	return index, nil
+}
+
+// openElements decrypts each loaded chunk using the subkey of the element holding it.
+// Chunks that are not present are ignored, and are reported when the attributes are assembled.
+func openElements[T comparable](dek []byte, label string, elements []T, index map[string]int, packer IDSerialiser[T], values map[string][]byte) error {
+
+	aeads := map[int]cipher.AEAD{}
+	for name, i := range index {
+		v, ok := values[name]
+		if !ok {
+			continue
+		}
+		if i < 0 || i >= len(elements) {
+			return ErrInvalidDataToDeserialiseElementKeys
+		}
+
+		aead, ok := aeads[i]
+		if !ok {
+			var err error
+			aead, err = elementSubkey(dek, label, elements[i], packer)
+			if err != nil {
+				return err
+			}
+			aeads[i] = aead
+		}
+
+		if len(v) < aead.NonceSize() {
+			return ErrElementDecryptionFailed
+		}
+		b, err := aead.Open(nil, v[:aead.NonceSize()], v[aead.NonceSize():], []byte(name))
+		if err != nil {
+			return ErrElementDecryptionFailed
+		}
+		values[name] = b
+	}
+	return nil
+}
+
+func packElementKeys(index map[string]int, approach serialise.Approach) ([]byte, error) {
+
+	names := make([]string, 0, len(index))
+	for k := range index {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	items := make([]any, 0, 2*len(names))
+	for _, name := range names {
+		items = append(items, name, int64(index[name]))
+	}
+
+	b, _, err := serialise.ToBytesMany(items, serialise.WithSerialisationApproach(approach))
+	return b, err
+}
+
+func unpackElementKeys(data []byte, approach serialise.Approach) (map[string]int, error) {
+
+	v, err := serialise.FromBytesMany(data, approach)
+	if err != nil {
+		return nil, err
+	}
+	if len(v)%2 != 0 {
+		return nil, ErrInvalidDataToDeserialiseElementKeys
+	}
+
+	index := make(map[string]int, len(v)/2)
+	for i := 0; i < len(v); i += 2 {
+		name, ok := v[i].(string)
+		if !ok {
+			return nil, ErrInvalidDataToDeserialiseElementKeys
+		}
+		element, ok := v[i+1].(int64)
+		if !ok {
+			return nil, ErrInvalidDataToDeserialiseElementKeys
+		}
+		index[name] = int(element)
+	}
+
+	return index, nil
+}
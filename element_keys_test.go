@@ -0,0 +1,98 @@
+package packer
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestWithElementKeys(t *testing.T) {
+
+	testPack, testUnpack, provider := testCreateEnv(t)
+
+	// Random data is incompressible, so ensures multiple elements are created
+	item := &Item[Key]{
+		Key:        Key{X: "A", Y: "B"},
+		Attributes: map[string]any{},
+	}
+	for i := range 4 {
+		b := make([]byte, 15*1024)
+		if _, err := rand.Read(b); err != nil {
+			t.Fatalf("Unexpected error creating data: %v", err)
+		}
+		item.Attributes[fmt.Sprintf("attr%d", i)] = b
+	}
+
+	info, l, err := testPack(item, WithMaximumKBSize(40), WithElementKeys(), WithErasureCoding(1))
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+
+	var elements []Key
+	recorder := func(ctx context.Context, keys []Key) (map[string][]byte, error) {
+		elements = keys
+		return l(ctx, keys)
+	}
+	e, err := testUnpack(info, recorder)
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+	if len(elements) < 3 {
+		t.Fatalf("Expected at least two data elements and a parity element, got: %d", len(elements))
+	}
+
+	for k, v := range item.Attributes {
+		m, err := e.GetValues(context.TODO(), []string{k}, provider)
+		if err != nil {
+			t.Fatalf("Unexpected error getting values: %v", err)
+		}
+		compareValue(m[k], v, "[]byte", t)
+	}
+
+}
+
+func TestSealElements(t *testing.T) {
+
+	serialiser, err := NewKeySerialiser()
+	if err != nil {
+		t.Fatalf("Unexpected error creating serialiser: %v", err)
+	}
+
+	dek := make([]byte, 32)
+	if _, err := rand.Read(dek); err != nil {
+		t.Fatalf("Unexpected error creating key: %v", err)
+	}
+
+	elements := []Key{{X: "A", Y: "B"}, {X: "C", Y: "D"}}
+	output := map[Key]map[string][]byte{
+		elements[0]: {"aaa": []byte("Hello")},
+		elements[1]: {"bbb": []byte("World")},
+	}
+
+	index, err := sealElements(dek, elementKeyLabel, elements, output, serialiser)
+	if err != nil {
+		t.Fatalf("Unexpected error sealing: %v", err)
+	}
+	if index["aaa"] != 0 || index["bbb"] != 1 {
+		t.Fatalf("Unexpected index: %v", index)
+	}
+
+	load := func() map[string][]byte {
+		return map[string][]byte{"aaa": output[elements[0]]["aaa"], "bbb": output[elements[1]]["bbb"]}
+	}
+
+	values := load()
+	if err := openElements(dek, elementKeyLabel, elements, index, serialiser, values); err != nil {
+		t.Fatalf("Unexpected error opening: %v", err)
+	}
+	if string(values["aaa"]) != "Hello" || string(values["bbb"]) != "World" {
+		t.Fatalf("Unexpected values: %v", values)
+	}
+
+	// A chunk cannot be decrypted using the subkey of another element
+	if err := openElements(dek, elementKeyLabel, elements, map[string]int{"bbb": 0}, serialiser, load()); !errors.Is(err, ErrElementDecryptionFailed) {
+		t.Fatalf("Unexpected error: expected: %v, got: %v", ErrElementDecryptionFailed, err)
+	}
+}
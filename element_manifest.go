@@ -0,0 +1,66 @@
+package packer
+
+import "errors"
+
+// ElementManifest lists the keys of every element created by Pack for an item, so that storage can be
+// reconciled against live items, and elements left behind by overwritten or failed packs deleted
+// (see OrphanedElements)
+type ElementManifest[T comparable] struct {
+	// Key of the item
+	Key T
+	// Elements are the keys of all elements returned by Pack, including any parity, replica and
+	// continuation elements, in no particular order
+	Elements []T
+}
+
+// WithElementManifest populates the manifest with the keys of the elements created by each successful Pack.
+// The manifest is overwritten by each Pack, so a separate ElementManifest should be used for each concurrent call.
+// The manifest must have the key type of the item, otherwise Pack fails with ErrElementManifestKeyType.
+func WithElementManifest[T comparable](manifest *ElementManifest[T]) func(o *Options) {
+	return func(o *Options) {
+		o.manifest = manifest
+	}
+}
+
+// ErrElementManifestKeyType raised if the ElementManifest passed to WithElementManifest does not have the key type of the item
+var ErrElementManifestKeyType = errors.New("element manifest must have the key type of the packed item")
+
+// recordManifest lists the elements returned by Pack, if a manifest is requested
+func recordManifest[T comparable](manifest any, key T, itemData map[T]map[string][]byte) error {
+	if manifest == nil {
+		return nil
+	}
+	m, ok := manifest.(*ElementManifest[T])
+	if !ok {
+		return ErrElementManifestKeyType
+	}
+	if m == nil {
+		return nil
+	}
+	*m = ElementManifest[T]{Key: key, Elements: make([]T, 0, len(itemData))}
+	for t := range itemData {
+		m.Elements = append(m.Elements, t)
+	}
+	return nil
+}
+
+// OrphanedElements returns the keys of the stored elements that are not listed in any of the manifests
+// of the live items, in the order they are stored, and which can therefore be deleted
+func OrphanedElements[T comparable](stored []T, manifests ...*ElementManifest[T]) []T {
+	live := map[T]bool{}
+	for _, m := range manifests {
+		if m == nil {
+			continue
+		}
+		for _, t := range m.Elements {
+			live[t] = true
+		}
+	}
+	var orphans []T
+	for _, t := range stored {
+		if !live[t] {
+			orphans = append(orphans, t)
+		}
+	}
+	return orphans
+}
@@ -0,0 +1,55 @@
+package packer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestWithElementManifest(t *testing.T) {
+
+	testPack, _, _ := testCreateEnv(t)
+
+	item := &Item[Key]{
+		Key:        Key{X: "A", Y: "B"},
+		Attributes: map[string]any{},
+	}
+	for i := range 200 {
+		item.Attributes[fmt.Sprintf("attr%d", i)] = fmt.Sprintf("value%d", i)
+	}
+
+	var manifest ElementManifest[Key]
+	_, l, err := testPack(item, WithMaximumKBSize(10), WithElementManifest(&manifest))
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+	if manifest.Key != item.Key || len(manifest.Elements) < 2 || !slices.Contains(manifest.Elements, item.Key) {
+		t.Fatalf("Unexpected manifest: %v", manifest)
+	}
+	for _, k := range manifest.Elements {
+		if m, err := l(context.TODO(), []Key{k}); err != nil || len(m) == 0 {
+			t.Fatalf("Expected element %v to have been stored: %v", k, err)
+		}
+	}
+
+	var previous ElementManifest[Key]
+	if _, _, err := testPack(item, WithMaximumKBSize(10), WithElementManifest(&previous)); err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+	stored := append(slices.Clone(previous.Elements), manifest.Elements...)
+	orphans := OrphanedElements(stored, &manifest)
+	for _, k := range orphans {
+		if slices.Contains(manifest.Elements, k) {
+			t.Fatalf("Live element %v reported as orphaned", k)
+		}
+	}
+	if len(orphans) == 0 {
+		t.Fatal("Expected the elements of the overwritten pack to be orphaned")
+	}
+
+	if _, _, err := testPack(item, WithElementManifest(&ElementManifest[string]{})); !errors.Is(err, ErrElementManifestKeyType) {
+		t.Fatalf("Expected ErrElementManifestKeyType, got: %v", err)
+	}
+}
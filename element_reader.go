@@ -0,0 +1,389 @@
+package packer
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/gford1000-go/serialise"
+)
+
+// ReaderAtLoader returns access to the stored data of an element, as written using EncodeElement,
+// so that attribute values can be read directly from storage (e.g. using ranged reads from object
+// storage) only when they are requested from GetValues
+type ReaderAtLoader[T comparable] func(ctx context.Context, key T) (io.ReaderAt, error)
+
+// ErrInvalidElementData raised if the stored data of an element does not have the layout written by EncodeElement
+var ErrInvalidElementData = errors.New("invalid element data, cannot locate attribute chunks")
+
+// ErrReaderAtLoaderUnsupported raised if UnpackReaderAt is used with an item packed using erasure coding,
+// replication, element keys or a signature, all of which require the elements to be loaded in full, or packed inline
+var ErrReaderAtLoaderUnsupported = errors.New("item cannot be unpacked using a ReaderAtLoader")
+
+// ErrReaderAtLoaderIsNil raised if UnpackReaderAt is called without a ReaderAtLoader
+var ErrReaderAtLoaderIsNil = errors.New("reader at loader must not be nil")
+
+// elementHeaderSize is the size of the big-endian length prefix of the element index
+const elementHeaderSize = 4
+
+// EncodeElement serialises the attribute data of an element, as returned by Pack, as a single object
+// in which each chunk can be read independently.  The object begins with an index of the chunks, so
+// that a reader only needs to read the index and the chunks of the requested attributes.
+func EncodeElement(data map[string][]byte) ([]byte, error) {
+
+	names := make([]string, 0, len(data))
+	for k := range data {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	index := make([]any, 0, 2*len(names))
+	var size int
+	for _, name := range names {
+		index = append(index, name, int64(len(data[name])))
+		size += len(data[name])
+	}
+
+	h, _, err := serialise.ToBytesMany(index, serialise.WithSerialisationApproach(serialise.NewMinDataApproachWithVersion(serialise.V1)))
+	if err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, elementHeaderSize, elementHeaderSize+len(h)+size)
+	binary.BigEndian.PutUint32(b, uint32(len(h)))
+	b = append(b, h...)
+	for _, name := range names {
+		b = append(b, data[name]...)
+	}
+	return b, nil
+}
+
+// DecodeElement is the inverse of EncodeElement
+func DecodeElement(b []byte) (map[string][]byte, error) {
+	index, err := readElementIndex(bytesReaderAt(b), nil)
+	if err != nil {
+		return nil, err
+	}
+	data := make(map[string][]byte, len(index))
+	for name, loc := range index {
+		if loc.offset+loc.size > int64(len(b)) {
+			return nil, ErrInvalidElementData
+		}
+		data[name] = b[loc.offset : loc.offset+loc.size]
+	}
+	return data, nil
+}
+
+type bytesReaderAt []byte
+
+func (b bytesReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off >= int64(len(b)) {
+		return 0, io.EOF
+	}
+	n := copy(p, b[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// chunkLocation locates a chunk within the stored data of an element
+type chunkLocation struct {
+	r        io.ReaderAt
+	element  any
+	name     string
+	offset   int64
+	size     int64
+	checksum *chunkChecksum
+}
+
+// readElementIndex reads the index written by EncodeElement, returning the location of each chunk
+func readElementIndex(r io.ReaderAt, element any) (map[string]*chunkLocation, error) {
+
+	h := make([]byte, elementHeaderSize)
+	if err := readFull(r, h, 0); err != nil {
+		return nil, err
+	}
+	size := int64(binary.BigEndian.Uint32(h))
+
+	h = make([]byte, size)
+	if err := readFull(r, h, elementHeaderSize); err != nil {
+		return nil, err
+	}
+
+	v, err := serialise.FromBytesMany(h, serialise.NewMinDataApproachWithVersion(serialise.V1))
+	if err != nil {
+		return nil, ErrInvalidElementData
+	}
+	if len(v)%2 != 0 {
+		return nil, ErrInvalidElementData
+	}
+
+	index := make(map[string]*chunkLocation, len(v)/2)
+	offset := elementHeaderSize + size
+	for i := 0; i < len(v); i += 2 {
+		name, ok := v[i].(string)
+		if !ok {
+			return nil, ErrInvalidElementData
+		}
+		n, ok := v[i+1].(int64)
+		if !ok || n < 0 {
+			return nil, ErrInvalidElementData
+		}
+		index[name] = &chunkLocation{r: r, element: element, name: name, offset: offset, size: n}
+		offset += n
+	}
+	return index, nil
+}
+
+// readFull fills the buffer from the reader at the offset
+func readFull(r io.ReaderAt, b []byte, offset int64) error {
+	n, err := r.ReadAt(b, offset)
+	if n == len(b) {
+		return nil
+	}
+	if err == nil || err == io.EOF {
+		return ErrInvalidElementData
+	}
+	return err
+}
+
+// readChunks reads the chunks of an attribute into a single buffer from the allocator, verifying any checksums.
+// The buffer must be freed by the caller once no longer used.
+func readChunks(attr string, chunks []*chunkLocation, alloc Allocator) ([]byte, error) {
+
+	var size int64
+	for _, c := range chunks {
+		size += c.size
+	}
+
+	b := alloc.Alloc(int(size))
+	var offset int64
+	for _, c := range chunks {
+		part := b[offset : offset+c.size]
+		if err := readFull(c.r, part, c.offset); err != nil {
+			alloc.Free(b)
+			return nil, err
+		}
+		if c.checksum != nil && crc32.Checksum(part, castagnoli) != c.checksum.crc {
+			alloc.Free(b)
+			return nil, &ChunkCorruptedError{Attribute: attr, Chunk: c.name, Element: c.element}
+		}
+		offset += c.size
+	}
+	return b, nil
+}
+
+// UnpackReaderAt deserialises a byte slice that was prepared using Pack, as Unpack, except that only the
+// index of each element is read during unpacking.  The chunks of each attribute are read from the
+// io.ReaderAt returned by the loader when the attribute is requested from GetValues, directly into the
+// buffer that is decrypted.  The DataLoader of the params is not used, and may be nil; any continuation
+// elements holding the envelope are read in full from the loader.
+// Items packed with erasure coding, replication or element keys cannot be unpacked in this way.
+func UnpackReaderAt[T comparable](ctx context.Context, data []byte, params *UnpackParams[T], loader ReaderAtLoader[T]) (i *EncryptedItem[T], e error) {
+
+	defer func() {
+		if r := recover(); r != nil {
+			e = fmt.Errorf("%v", r)
+		}
+	}()
+
+	if len(data) == 0 {
+		return nil, ErrUnpackNoData
+	}
+	if params == nil {
+		return nil, ErrUnpackNoParams
+	}
+	if loader == nil {
+		return nil, ErrReaderAtLoaderIsNil
+	}
+	if params.IDRetriever == nil {
+		return nil, ErrIDRetrieverIsNil
+	}
+	if params.Provider == nil {
+		return nil, ErrProviderIsNil
+	}
+
+	continuation, err := continuationKeys(data, params.IDRetriever)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err = JoinEnvelope(ctx, data, readerAtDataLoader(loader), params.IDRetriever)
+	if err != nil {
+		return nil, err
+	}
+
+	packingVersion, b, err := splitPackingVersion(data)
+	if err != nil {
+		return nil, err
+	}
+	if !packingVersion.supported() {
+		return nil, ErrUnsupportedPackVersion
+	}
+
+	ctx = params.withEncryptionContext(ctx)
+
+	start := time.Now()
+	metrics := metricsOrDefault(params.Metrics)
+	provider := &measuredProvider{EnvelopeKeyProvider: params.Provider, metrics: metrics}
+
+	d := &itemPackingDetailsV1[T]{
+		version:       packingVersion,
+		progress:      newProgressTracker(OperationUnpack, params.Progress),
+		stats:         params.Stats,
+		maxAttributes: params.MaxAttributes,
+		memory:        newMemoryTracker(params.MemoryLimit),
+		allocator:     allocatorOrDefault(params.Allocator),
+		requireMAC:    params.RequireEnvelopeMAC,
+		verifiers:     params.Verifiers,
+	}
+
+	env, err := d.openEnvelope(ctx, b, provider, params.IDRetriever)
+	if err != nil {
+		return nil, err
+	}
+
+	item, err := d.unpackReaders(ctx, env, loader)
+	if err != nil {
+		return nil, err
+	}
+	item.elements = append(item.elements, continuation...)
+
+	params.apply(item, metrics)
+
+	if params.Stats != nil {
+		params.Stats.TotalDuration = time.Since(start)
+	}
+
+	metrics.Add(MetricUnpacks, 1)
+	metrics.Observe(MetricUnpackDuration, time.Since(start).Seconds())
+
+	return item, nil
+}
+
+// readerAtDataLoader returns a DataLoader reading every chunk of the elements from the loader, for elements
+// that must be read in full, such as continuation elements
+func readerAtDataLoader[T comparable](loader ReaderAtLoader[T]) DataLoader[T] {
+	return func(ctx context.Context, keys []T) (map[string][]byte, error) {
+		m := map[string][]byte{}
+		for _, t := range keys {
+			r, err := loader(ctx, t)
+			if err != nil {
+				return nil, err
+			}
+			index, err := readElementIndex(r, t)
+			if err != nil {
+				return nil, err
+			}
+			for name, loc := range index {
+				b := make([]byte, loc.size)
+				if err := readFull(r, b, loc.offset); err != nil {
+					return nil, err
+				}
+				m[name] = b
+			}
+		}
+		return m, nil
+	}
+}
+
+// unpackReaders reads the index of each element of the opened envelope, returning an EncryptedItem
+// that reads attribute chunks on demand
+func (d *itemPackingDetailsV1[T]) unpackReaders(ctx context.Context, env *envelopeV1[T], loader ReaderAtLoader[T]) (*EncryptedItem[T], error) {
+
+	for _, name := range []string{extErasure, extReplicas, extElementKeys, extInline, extChunkDigests} {
+		if _, ok := env.ext[name]; ok {
+			return nil, ErrReaderAtLoaderUnsupported
+		}
+	}
+
+	compression, err := env.ext.compression()
+	if err != nil {
+		return nil, err
+	}
+
+	cipherAlgorithm, err := env.ext.cipherAlgorithm()
+	if err != nil {
+		return nil, err
+	}
+
+	hierarchy, err := env.ext.keyHierarchy(env.approach)
+	if err != nil {
+		return nil, err
+	}
+
+	checksums, err := env.ext.checksums(env.approach)
+	if err != nil {
+		return nil, err
+	}
+
+	attrMap, err := d.unpackAttrMap(env.bAttrMap, env.approach, env.ext)
+	if err != nil {
+		return nil, err
+	}
+
+	d.progress.elements(len(env.elements))
+	d.progress.attributes(len(attrMap))
+
+	chunks := map[string]*chunkLocation{}
+	for _, t := range env.elements {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		loadStart := time.Now()
+		r, err := loader(ctx, t)
+		if err != nil {
+			return nil, err
+		}
+		index, err := readElementIndex(r, t)
+		d.stats.recordLoad(1, nil, time.Since(loadStart))
+		if err != nil {
+			return nil, err
+		}
+		for name, loc := range index {
+			if c, ok := checksums[name]; ok {
+				loc.checksum = &c
+			}
+			chunks[name] = loc
+		}
+		d.progress.elementsFlushed(1)
+	}
+
+	streamed := make(map[string][]*chunkLocation, len(attrMap))
+	for k, v := range attrMap {
+		locations := make([]*chunkLocation, len(v))
+		for i, a := range v {
+			loc, ok := chunks[a]
+			if !ok {
+				return nil, ErrInvalidDataToUnpack
+			}
+			locations[i] = loc
+		}
+		streamed[k] = locations
+	}
+
+	return &EncryptedItem[T]{
+		key:          env.key,
+		approach:     env.approach,
+		encryptedKey: env.encryptedKey,
+		attributes:   map[string][]byte{},
+		streamed:     streamed,
+		packer:       env.packer,
+		compression:  compression,
+		cipher:       cipherAlgorithm,
+		suiteID:      string(env.ext[extCipherSuite]),
+		hierarchy:    hierarchy,
+		envelope:     env.finalisedData,
+		version:      env.version,
+		wire:         env.wire,
+		signerID:     env.signerID,
+		memory:       d.memory,
+		allocator:    d.allocator,
+	}, nil
+}
@@ -0,0 +1,138 @@
+package packer
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gford1000-go/serialise"
+)
+
+// countingReaderAt records the number of bytes read from the underlying data
+type countingReaderAt struct {
+	b    []byte
+	read *atomic.Int64
+}
+
+func (c *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n, err := bytesReaderAt(c.b).ReadAt(p, off)
+	c.read.Add(int64(n))
+	return n, err
+}
+
+func TestEncodeElement(t *testing.T) {
+
+	data := map[string][]byte{"a": []byte("hello"), "b": {}, "c": []byte("world")}
+
+	b, err := EncodeElement(data)
+	if err != nil {
+		t.Fatalf("Unexpected error encoding: %v", err)
+	}
+
+	decoded, err := DecodeElement(b)
+	if err != nil {
+		t.Fatalf("Unexpected error decoding: %v", err)
+	}
+	if !maps.EqualFunc(data, decoded, bytes.Equal) {
+		t.Fatalf("Mismatch: expected %v, got %v", data, decoded)
+	}
+
+	if _, err := DecodeElement(b[:len(b)-1]); !errors.Is(err, ErrInvalidElementData) {
+		t.Fatalf("Expected ErrInvalidElementData for truncated data, got: %v", err)
+	}
+}
+
+func TestUnpackReaderAt(t *testing.T) {
+
+	_, _, provider := testCreateEnv(t)
+
+	serialiser, _ := NewKeySerialiser()
+
+	pParams := &PackParams[Key]{
+		Provider: provider,
+		Creator:  NewKeyCreator(defaultLen),
+		Packer:   serialiser,
+		Approach: serialise.NewMinDataApproachWithVersion(serialise.V1),
+	}
+
+	attrs := map[string]any{}
+	for i := range 4 {
+		b := make([]byte, 8*1024)
+		if _, err := rand.Read(b); err != nil {
+			t.Fatalf("Unexpected error creating data: %v", err)
+		}
+		attrs[fmt.Sprintf("attr%d", i)] = b
+	}
+	item := &Item[Key]{Key: Key{X: "A", Y: "B"}, Attributes: attrs}
+
+	info, data, err := Pack(item, pParams, WithMaximumKBSize(10), WithAttributeValueMaximumKBSize(10), WithChecksums())
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+
+	stored := map[Key][]byte{}
+	for k, v := range data {
+		if stored[k], err = EncodeElement(v); err != nil {
+			t.Fatalf("Unexpected error encoding element: %v", err)
+		}
+	}
+
+	var read atomic.Int64
+	loader := func(ctx context.Context, key Key) (io.ReaderAt, error) {
+		b, ok := stored[key]
+		if !ok {
+			return nil, errors.New("missing element")
+		}
+		return &countingReaderAt{b: b, read: &read}, nil
+	}
+
+	params := &UnpackParams[Key]{
+		IDRetriever: func(string) (IDSerialiser[Key], error) { return serialiser, nil },
+		Provider:    provider,
+	}
+
+	e, err := UnpackReaderAt(context.TODO(), info, params, loader)
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+
+	// Only the element indexes are read during unpacking
+	indexes := read.Load()
+	if indexes >= 8*1024 {
+		t.Fatalf("Expected only element indexes to be read, got %d bytes", indexes)
+	}
+
+	values, err := e.GetValues(context.TODO(), []string{"attr2"}, provider)
+	if err != nil {
+		t.Fatalf("Unexpected error getting values: %v", err)
+	}
+	if !bytes.Equal(values["attr2"].([]byte), attrs["attr2"].([]byte)) {
+		t.Fatal("Mismatch for attr2")
+	}
+	if n := read.Load() - indexes; n < 8*1024 || n >= 2*8*1024 {
+		t.Fatalf("Expected only attr2 to be read, got %d bytes", n)
+	}
+
+	// Corruption is detected when the attribute is read
+	for k := range stored {
+		stored[k][len(stored[k])-1] ^= 0xff
+	}
+	if _, err := e.GetValues(context.TODO(), []string{"attr0"}, provider); !errors.Is(err, ErrChunkCorrupted) {
+		t.Fatalf("Expected ErrChunkCorrupted, got: %v", err)
+	}
+
+	// Elements must be loaded in full for erasure coded items
+	info, _, err = Pack(item, pParams, WithErasureCoding(1))
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+	if _, err := UnpackReaderAt(context.TODO(), info, params, loader); !errors.Is(err, ErrReaderAtLoaderUnsupported) {
+		t.Fatalf("Expected ErrReaderAtLoaderUnsupported, got: %v", err)
+	}
+}
@@ -2,7 +2,10 @@ package packer
 
 import (
 	"context"
+	"runtime"
+	"slices"
 	"sync"
+	"time"
 
 	"github.com/gford1000-go/serialise"
 )
@@ -10,11 +13,44 @@ import (
 // EncryptedItem is a partially deserialised format, with the attribute values
 // remaining encrypted until required
 type EncryptedItem[T comparable] struct {
-	key          T
-	attributes   map[string][]byte
+	key        T
+	attributes map[string][]byte
+	// Location of the chunks of each attribute, if they are read on demand (see UnpackReaderAt)
+	streamed     map[string][]*chunkLocation
 	encryptedKey []byte
 	approach     serialise.Approach
 	packer       IDSerialiser[T]
+	compression  Compression
+	cipher       CipherAlgorithm
+	// CipherSuite used in place of the cipher, if any
+	suiteID   string
+	suite     CipherSuite
+	hierarchy []KeyDerivation
+	metrics   MetricsSink
+	quota     Quota
+	hooks     []TransformHook
+	schema    *Schema
+	repaired  []T
+	// Keys of all elements holding the data of the item
+	elements []T
+	// Encryption context bound to the data encryption key, if any
+	encryptionContext map[string]string
+	// Finalised data of the envelope, retained so that the data encryption key can be rewrapped
+	envelope []any
+	version  PackVersion
+	wire     WireFormat
+	// ID of the Signer whose signature was verified during Unpack, if any
+	signerID   string
+	autoRewrap *AutoRewrap[T]
+	rewrapOnce sync.Once
+	// Limits the memory used to decode attribute values, if requested
+	memory *memoryTracker
+	// Provides temporary buffers used to read attribute values
+	allocator Allocator
+	// Normalises requested attribute names, if requested
+	normaliser AttributeNameNormaliser
+	// Holds the decrypted data encryption key, if requested
+	keyCache *dataKeyCache
 }
 
 // GetKey returns the key of this EncryptedItem
@@ -22,21 +58,59 @@ func (e *EncryptedItem[T]) GetKey() T {
 	return e.key
 }
 
+// RepairedElements returns the keys of any elements that were reconstructed from parity
+// elements during Unpack, because they were missing from storage or corrupted
+func (e *EncryptedItem[T]) RepairedElements() []T {
+	return slices.Clone(e.repaired)
+}
+
+// ElementKeys returns the keys of all the elements holding the data of the item, including any parity,
+// replica and continuation elements, in the order data, parity, replica and continuation, so that storage
+// can be purged when the item is deleted (see also PlanDeletion)
+func (e *EncryptedItem[T]) ElementKeys() []T {
+	return slices.Clone(e.elements)
+}
+
+// SignerID returns the ID of the Signer of the packed data, if its signature was verified during Unpack
+// (see UnpackParams.Verifiers), or an empty string if signatures were not verified
+func (e *EncryptedItem[T]) SignerID() string {
+	return e.signerID
+}
+
+// KeyHierarchy returns the derivation of the keys used to encrypt the item, if it was recorded
+// during Pack (see WithKeyHierarchy)
+func (e *EncryptedItem[T]) KeyHierarchy() []KeyDerivation {
+	return slices.Clone(e.hierarchy)
+}
+
+// AttributeNames returns the names of the attributes included in this EncryptedItem, in name order,
+// which may be requested from GetValues.  Names are those of the packed item, after any aliases are applied.
+func (e *EncryptedItem[T]) AttributeNames() []string {
+	names := make([]string, 0, len(e.attributes)+len(e.streamed))
+	for attr := range e.attributes {
+		names = append(names, attr)
+	}
+	for attr := range e.streamed {
+		names = append(names, attr)
+	}
+	slices.Sort(names)
+	return names
+}
+
 // GetValues will attempt to decrypt and return the requested attributes using the provider.
 // Any attributes that are not included in this EncryptedItem are ignored.
 // Context is provided so that the caller details may be included and passed to the provider to verify access.  This is
 // an implementation detail of the EnvelopeKeyProvider; no access checks are performed in GetValues.
-func (e *EncryptedItem[T]) GetValues(ctx context.Context, attrs []string, provider EnvelopeKeyProvider) (map[string]any, error) {
+func (e *EncryptedItem[T]) GetValues(ctx context.Context, attrs []string, provider EnvelopeKeyProvider, opts ...func(*GetValuesOptions)) (map[string]any, error) {
 
 	if len(attrs) == 0 {
 		return map[string]any{}, nil
 	}
 
-	if provider == nil {
-		return nil, ErrProviderIsNil
-	}
+	start := time.Now()
+	metrics := metricsOrDefault(e.metrics)
 
-	key, err := provider.Decrypt(ctx, e.encryptedKey)
+	key, err := e.prepare(ctx, attrs, provider, metrics)
 	if err != nil {
 		return nil, err
 	}
@@ -52,118 +126,427 @@ func (e *EncryptedItem[T]) GetValues(ctx context.Context, attrs []string, provid
 	c := make(chan *resp, len(attrs))
 	defer close(c)
 
-	var wg sync.WaitGroup
+	for _, phase := range prioritise(attrs, newGetValuesOptions(opts).priority) {
+		var wg sync.WaitGroup
 
-	for i := range attrs {
-		wg.Add(1)
+		for i := range phase {
+			wg.Add(1)
 
-		go func(attr string) {
-			defer wg.Done()
+			go func(attr string) {
+				defer wg.Done()
 
-			resp := &resp{a: attr}
-			defer func() { c <- resp }()
+				resp := &resp{a: attr}
+				defer func() { c <- resp }()
 
-			b, ok := e.attributes[attr]
-			if !ok {
-				return
+				resp.v, _, resp.e = e.getValue(ctx, attr, key)
+			}(phase[i])
+		}
+
+		wg.Wait()
+	}
+
+	for range len(attrs) {
+		resp := <-c
+		if resp.e != nil {
+			if ctx.Err() == nil {
+				metrics.Add(MetricDecryptErrors, 1)
 			}
+			return nil, resp.e
+		}
+		if resp.v != nil {
+			m[resp.a] = resp.v
+		}
+	}
+
+	metrics.Observe(MetricGetValuesDuration, time.Since(start).Seconds())
+
+	return m, nil
+}
+
+// GetValue decrypts and returns a single attribute using the provider, with found false if the attribute is not
+// included in this EncryptedItem.  It behaves as GetValues for one attribute, but decrypts in the calling
+// goroutine without creating a map, which suits fetching a single field.
+func (e *EncryptedItem[T]) GetValue(ctx context.Context, attr string, provider EnvelopeKeyProvider) (v any, found bool, err error) {
+
+	start := time.Now()
+	metrics := metricsOrDefault(e.metrics)
+
+	key, err := e.prepare(ctx, []string{attr}, provider, metrics)
+	if err != nil {
+		return nil, false, err
+	}
+
+	v, found, err = e.getValue(ctx, attr, key)
+	if err != nil {
+		if ctx.Err() == nil {
+			metrics.Add(MetricDecryptErrors, 1)
+		}
+		return nil, found, err
+	}
+
+	metrics.Observe(MetricGetValuesDuration, time.Since(start).Seconds())
+
+	return v, found && v != nil, nil
+}
+
+// GetAllValues decrypts and returns every attribute of this EncryptedItem using the provider, as GetValues would
+// for the names returned by AttributeNames.  The data encryption key is decrypted once, and the attributes
+// are decrypted concurrently, up to the limit set by WithDecryptConcurrency or GOMAXPROCS if not set.
+func (e *EncryptedItem[T]) GetAllValues(ctx context.Context, provider EnvelopeKeyProvider, opts ...func(*GetValuesOptions)) (map[string]any, error) {
+
+	attrs := e.AttributeNames()
+	if len(attrs) == 0 {
+		return map[string]any{}, nil
+	}
+
+	start := time.Now()
+	metrics := metricsOrDefault(e.metrics)
+
+	key, err := e.prepare(ctx, attrs, provider, metrics)
+	if err != nil {
+		return nil, err
+	}
+
+	o := newGetValuesOptions(opts)
+	limit := o.concurrency
+	if limit < 1 {
+		limit = runtime.GOMAXPROCS(0)
+	}
 
-			v, err := serialise.FromBytesMany(b, e.approach, serialise.WithAESGCMEncryption(key))
+	m := make(map[string]any, len(attrs))
+	var mu sync.Mutex
+
+	for _, phase := range prioritise(attrs, o.priority) {
+		err := runConcurrently(len(phase), limit, func(i int) error {
+			v, _, err := e.getValue(ctx, phase[i], key)
 			if err != nil {
-				resp.e = err
-				return
+				return err
 			}
-			switch len(v) {
-			case 0:
-				resp.e = ErrInvalidDataToUnpack
-				return
-			case 1:
-				resp.v = v[0]
-				return
-			case 2:
-				flag, ok := v[0].(bool)
-				if !ok {
-					resp.e = ErrInvalidDataToUnpack
-					return
-				}
-				b, ok := v[1].([]byte)
-				if !ok {
-					resp.e = ErrInvalidDataToUnpack
-					return
-				}
-				t, err := e.packer.Unpack(b)
-				if err != nil {
-					resp.e = ErrInvalidDataToUnpack
-					return
-				}
-				if flag {
-					resp.v = t
-					return
-				} else {
-					resp.v = &t
-					return
-				}
-			default:
-				flag, ok := v[0].(bool)
-				if !ok {
-					resp.e = ErrInvalidDataToUnpack
-					return
-				}
-				size, ok := v[1].(int64)
-				if !ok {
-					resp.e = ErrInvalidDataToUnpack
-					return
+			if v != nil {
+				mu.Lock()
+				m[phase[i]] = v
+				mu.Unlock()
+			}
+			return nil
+		})
+		if err != nil {
+			if ctx.Err() == nil {
+				metrics.Add(MetricDecryptErrors, 1)
+			}
+			return nil, err
+		}
+	}
+
+	metrics.Observe(MetricGetValuesDuration, time.Since(start).Seconds())
+
+	return m, nil
+}
+
+// AttrResult is the outcome of decrypting a single attribute, returned by GetValuesStream
+type AttrResult struct {
+	// Attribute is the name of the attribute
+	Attribute string
+	// Value is the decrypted value, if Err is nil
+	Value any
+	// Err is the error encountered decrypting the attribute, if any
+	Err error
+}
+
+// GetValuesStream decrypts the requested attributes as GetValues, but emits each result on the returned
+// channel as it completes, so that processing can begin early.  At most the decrypt concurrency (see
+// WithDecryptConcurrency) attributes are decrypted or awaiting receipt at once, and the next attribute is
+// only started once a result has been received, so the rate at which results are received limits the
+// decryption work in progress.  Attributes that are not included in this EncryptedItem are ignored.  The
+// channel is closed once all results have been emitted, or the context is cancelled, and must be drained
+// or the context cancelled to release resources.
+func (e *EncryptedItem[T]) GetValuesStream(ctx context.Context, attrs []string, provider EnvelopeKeyProvider, opts ...func(*GetValuesOptions)) (<-chan AttrResult, error) {
+
+	start := time.Now()
+	metrics := metricsOrDefault(e.metrics)
+
+	c := make(chan AttrResult)
+
+	if len(attrs) == 0 {
+		close(c)
+		return c, nil
+	}
+
+	key, err := e.prepare(ctx, attrs, provider, metrics)
+	if err != nil {
+		return nil, err
+	}
+
+	o := newGetValuesOptions(opts)
+	limit := o.concurrency
+	if limit < 1 {
+		limit = runtime.GOMAXPROCS(0)
+	}
+
+	phases := prioritise(attrs, o.priority)
+
+	go func() {
+		// A slot is held from the start of a decrypt until its result is received
+		slots := make(chan struct{}, limit)
+
+	phases:
+		for _, phase := range phases {
+			var wg sync.WaitGroup
+
+			for _, attr := range phase {
+				select {
+				case slots <- struct{}{}:
+				case <-ctx.Done():
+					wg.Wait()
+					break phases
 				}
+				wg.Add(1)
+
+				go func(attr string) {
+					defer func() {
+						<-slots
+						wg.Done()
+					}()
 
-				if flag {
-					tt := make([]T, size)
-					for i := range size {
-						b, ok := v[i+2].([]byte)
-						if !ok {
-							resp.e = ErrInvalidDataToUnpack
-							return
-						}
-						tt[i], err = e.packer.Unpack(b)
-						if err != nil {
-							resp.e = ErrInvalidDataToUnpack
-							return
-						}
+					v, found, err := e.getValue(ctx, attr, key)
+					if !found && err == nil {
+						return
 					}
-					resp.v = tt
-					return
-				} else {
-					tt := make([]*T, size)
-					for i := range size {
-						b, ok := v[i+2].([]byte)
-						if !ok {
-							resp.e = ErrInvalidDataToUnpack
-							return
-						}
-						t, err := e.packer.Unpack(b)
-						if err != nil {
-							resp.e = ErrInvalidDataToUnpack
-							return
-						}
-						tt[i] = &t
+					if err != nil && ctx.Err() == nil {
+						metrics.Add(MetricDecryptErrors, 1)
 					}
-					resp.v = tt
-					return
-				}
+
+					select {
+					case c <- AttrResult{Attribute: attr, Value: v, Err: err}:
+					case <-ctx.Done():
+					}
+				}(attr)
 			}
-		}(attrs[i])
+
+			wg.Wait()
+		}
+		close(c)
+		metrics.Observe(MetricGetValuesDuration, time.Since(start).Seconds())
+	}()
+
+	return c, nil
+}
+
+// prepare checks that the request may proceed, returning the decrypted key for the attribute values
+func (e *EncryptedItem[T]) prepare(ctx context.Context, attrs []string, provider EnvelopeKeyProvider, metrics MetricsSink) ([]byte, error) {
+
+	if provider == nil {
+		return nil, ErrProviderIsNil
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
 
-	wg.Wait()
+	if err := e.acquire(ctx, attrs); err != nil {
+		return nil, err
+	}
 
-	for range len(attrs) {
-		resp := <-c
-		if resp.e != nil {
-			return nil, resp.e
+	key, err := e.cachedDecryptKey(ctx, provider)
+	if err != nil {
+		metrics.Add(MetricDecryptErrors, 1)
+		return nil, err
+	}
+
+	e.checkRewrap(ctx, provider, key)
+
+	return key, nil
+}
+
+// decryptKey returns the data encryption key of the item, supplying any encryption context to the provider
+func (e *EncryptedItem[T]) decryptKey(ctx context.Context, provider EnvelopeKeyProvider) ([]byte, error) {
+	if len(e.encryptionContext) > 0 {
+		ctx = ContextWithEncryptionContext(ctx, e.encryptionContext)
+	}
+	return provider.Decrypt(ctx, e.encryptedKey)
+}
+
+// acquire obtains quota for the request, if a Quota applies to the item
+func (e *EncryptedItem[T]) acquire(ctx context.Context, attrs []string) error {
+	if e.quota == nil {
+		return nil
+	}
+	var size uint64
+	for _, attr := range attrs {
+		attr = e.normaliseName(attr)
+		size += uint64(len(e.attributes[attr]))
+		for _, c := range e.streamed[attr] {
+			size += uint64(c.size)
 		}
-		if resp.v != nil {
-			m[resp.a] = resp.v
+	}
+	return e.quota.Acquire(ctx, TenantFromContext(ctx), 1, size)
+}
+
+// getValue decrypts the attribute, applying any hooks and schema; found is false if the item does not include the attribute
+func (e *EncryptedItem[T]) getValue(ctx context.Context, attr string, key []byte) (v any, found bool, err error) {
+
+	// Abandoned requests should not continue to decrypt
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+
+	// Values are returned against the requested name, but are held against the normalised name
+	requested := attr
+	attr = e.normaliseName(attr)
+
+	b, ok := e.attributes[attr]
+	if !ok {
+		chunks, ok := e.streamed[attr]
+		if !ok {
+			return nil, false, nil
+		}
+		var size uint64
+		for _, c := range chunks {
+			size += uint64(c.size)
+		}
+		if err := e.memory.reserve(size); err != nil {
+			return nil, true, err
+		}
+		defer e.memory.release(size)
+		alloc := allocatorOrDefault(e.allocator)
+		if b, err = readChunks(requested, chunks, alloc); err != nil {
+			return nil, true, err
 		}
+		// Decryption creates a new buffer, so the read chunks are not retained by the value
+		defer alloc.Free(b)
 	}
 
-	return m, nil
+	v, err = e.decodeValue(ctx, b, key)
+	if err != nil {
+		return nil, true, err
+	}
+	v, err = applyHooks(e.hooks, attr, v)
+	if err != nil {
+		return nil, true, err
+	}
+	if err := e.schema.checkValue(attr, v); err != nil {
+		return nil, true, err
+	}
+
+	return v, true, nil
+}
+
+// cipherOption returns the serialisation option that decrypts attribute values with the key
+func (e *EncryptedItem[T]) cipherOption(key []byte) (func(*serialise.Options), error) {
+	if len(e.suiteID) == 0 {
+		return e.cipher.encryptionOption(key)
+	}
+	if e.suite == nil {
+		return nil, ErrUnknownCipherSuite
+	}
+	return cipherSuiteOption(e.suite, key), nil
+}
+
+// decodeValue decrypts and deserialises the packed value of a single attribute
+func (e *EncryptedItem[T]) decodeValue(ctx context.Context, b []byte, key []byte) (any, error) {
+
+	cipherOption, err := e.cipherOption(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// The decrypted value is held whilst it is deserialised
+	if err := e.memory.reserve(uint64(len(b))); err != nil {
+		return nil, err
+	}
+	defer e.memory.release(uint64(len(b)))
+
+	v, err := serialise.FromBytesMany(b, e.approach, cipherOption)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.compression != NoCompression {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if len(v) != 1 {
+			return nil, ErrInvalidDataToUnpack
+		}
+		cb, ok := v[0].([]byte)
+		if !ok {
+			return nil, ErrInvalidDataToUnpack
+		}
+		pb, err := e.memory.decompressWithin(e.compression, cb)
+		if err != nil {
+			return nil, err
+		}
+		if err := e.memory.reserve(uint64(len(pb))); err != nil {
+			return nil, err
+		}
+		defer e.memory.release(uint64(len(pb)))
+		v, err = serialise.FromBytesMany(pb, e.approach)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	switch len(v) {
+	case 0:
+		return nil, ErrInvalidDataToUnpack
+	case 1:
+		return v[0], nil
+	case 2:
+		b, ok := v[1].([]byte)
+		if !ok {
+			return nil, ErrInvalidDataToUnpack
+		}
+		if marker, ok := v[0].(string); ok && marker == packedItemMarker {
+			return PackedItem(b), nil
+		}
+		flag, ok := v[0].(bool)
+		if !ok {
+			return nil, ErrInvalidDataToUnpack
+		}
+		t, err := e.packer.Unpack(b)
+		if err != nil {
+			return nil, ErrInvalidDataToUnpack
+		}
+		if flag {
+			return t, nil
+		}
+		return &t, nil
+	default:
+		flag, ok := v[0].(bool)
+		if !ok {
+			return nil, ErrInvalidDataToUnpack
+		}
+		size, ok := v[1].(int64)
+		if !ok {
+			return nil, ErrInvalidDataToUnpack
+		}
+
+		if flag {
+			tt := make([]T, size)
+			for i := range size {
+				b, ok := v[i+2].([]byte)
+				if !ok {
+					return nil, ErrInvalidDataToUnpack
+				}
+				tt[i], err = e.packer.Unpack(b)
+				if err != nil {
+					return nil, ErrInvalidDataToUnpack
+				}
+			}
+			return tt, nil
+		}
+
+		tt := make([]*T, size)
+		for i := range size {
+			b, ok := v[i+2].([]byte)
+			if !ok {
+				return nil, ErrInvalidDataToUnpack
+			}
+			t, err := e.packer.Unpack(b)
+			if err != nil {
+				return nil, ErrInvalidDataToUnpack
+			}
+			tt[i] = &t
+		}
+		return tt, nil
+	}
 }
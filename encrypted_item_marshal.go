@@ -0,0 +1,187 @@
+package packer
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/gford1000-go/serialise"
+)
+
+// marshalledItemPrefix begins the data returned by EncryptedItem.MarshalBinary, identifying its layout
+var marshalledItemPrefix = []byte{'P', 'K', 'E', 0x01}
+
+// ErrCannotMarshalStreamedItem raised if MarshalBinary is called on an item whose attribute values are
+// read on demand (see UnpackReaderAt), as the values are not held by the item
+var ErrCannotMarshalStreamedItem = errors.New("items reading attribute values on demand cannot be marshalled")
+
+// ErrInvalidDataToUnmarshalItem raised if the data passed to UnmarshalEncryptedItem was not returned by MarshalBinary
+var ErrInvalidDataToUnmarshalItem = errors.New("invalid data, cannot unmarshal encrypted item")
+
+// MarshalBinary serialises the item, with its attribute values still encrypted, so that it can be cached
+// and later restored with UnmarshalEncryptedItem without repeating Unpack and loading its elements.
+// The data encryption key is only included in its encrypted form, so the data is as sensitive as the
+// elements of the item, and any cached data encryption key (see UnpackParams.CacheDataKey) is not included.
+func (e *EncryptedItem[T]) MarshalBinary() ([]byte, error) {
+
+	if len(e.streamed) > 0 {
+		return nil, ErrCannotMarshalStreamedItem
+	}
+
+	bKey, err := e.packer.Pack(e.key)
+	if err != nil {
+		return nil, err
+	}
+
+	var hierarchy []byte
+	for _, k := range e.hierarchy {
+		hierarchy = appendFrame(hierarchy, []byte(k.Level))
+		hierarchy = appendFrame(hierarchy, []byte(k.Method))
+		hierarchy = appendFrame(hierarchy, []byte(k.Label))
+	}
+
+	var envelope []byte
+	if e.envelope != nil {
+		if envelope, err = encodeFinalisedData(e.version, e.wire, e.envelope); err != nil {
+			return nil, err
+		}
+	}
+
+	attributes, err := EncodeElement(e.attributes)
+	if err != nil {
+		return nil, err
+	}
+
+	repaired, err := packKeys(e.packer, e.repaired)
+	if err != nil {
+		return nil, err
+	}
+	elements, err := packKeys(e.packer, e.elements)
+	if err != nil {
+		return nil, err
+	}
+
+	b := bytes.Clone(marshalledItemPrefix)
+	for _, f := range [][]byte{
+		{byte(e.version)},
+		[]byte(e.packer.Name()),
+		bKey,
+		[]byte(e.approach.Name()),
+		e.encryptedKey,
+		{byte(e.compression)},
+		{byte(e.cipher)},
+		[]byte(e.suiteID),
+		hierarchy,
+		envelope,
+		attributes,
+		repaired,
+		elements,
+	} {
+		b = appendFrame(b, f)
+	}
+	return b, nil
+}
+
+// UnmarshalEncryptedItem restores an item serialised with MarshalBinary.  The params are applied to the item
+// as for Unpack, other than the DataLoader, Progress, Stats and Checkpoint, which are not used.
+// Only the IDRetriever of the params is required.
+func UnmarshalEncryptedItem[T comparable](data []byte, params *UnpackParams[T]) (*EncryptedItem[T], error) {
+
+	if params == nil {
+		return nil, ErrUnpackNoParams
+	}
+	if params.IDRetriever == nil {
+		return nil, ErrIDRetrieverIsNil
+	}
+	if !bytes.HasPrefix(data, marshalledItemPrefix) {
+		return nil, ErrInvalidDataToUnmarshalItem
+	}
+
+	frames, err := splitFrames(data[len(marshalledItemPrefix):])
+	if err != nil || len(frames) != 13 || len(frames[0]) != 1 || len(frames[5]) != 1 || len(frames[6]) != 1 {
+		return nil, ErrInvalidDataToUnmarshalItem
+	}
+
+	item := &EncryptedItem[T]{
+		version:      PackVersion(int8(frames[0][0])),
+		encryptedKey: bytes.Clone(frames[4]),
+		compression:  Compression(int8(frames[5][0])),
+		cipher:       CipherAlgorithm(frames[6][0]),
+		suiteID:      string(frames[7]),
+		memory:       newMemoryTracker(params.MemoryLimit),
+		allocator:    allocatorOrDefault(params.Allocator),
+	}
+
+	if item.packer, err = params.IDRetriever(string(frames[1])); err != nil {
+		return nil, err
+	}
+	if item.key, err = item.packer.Unpack(frames[2]); err != nil {
+		return nil, err
+	}
+	if item.approach, err = serialise.GetApproach(string(frames[3])); err != nil {
+		return nil, err
+	}
+
+	hierarchy, err := splitFrames(frames[8])
+	if err != nil || len(hierarchy)%3 != 0 {
+		return nil, ErrInvalidDataToUnmarshalItem
+	}
+	for i := 0; i < len(hierarchy); i += 3 {
+		item.hierarchy = append(item.hierarchy, KeyDerivation{Level: string(hierarchy[i]), Method: string(hierarchy[i+1]), Label: string(hierarchy[i+2])})
+	}
+
+	if len(frames[9]) > 0 {
+		if item.envelope, item.wire, err = decodeFinalisedData(item.version, bytes.Clone(frames[9])); err != nil {
+			return nil, err
+		}
+	}
+	if item.signerID, err = verifyFinalisedData(item.version, item.wire, item.envelope, params.Verifiers); err != nil {
+		return nil, err
+	}
+
+	attributes, err := DecodeElement(bytes.Clone(frames[10]))
+	if err != nil {
+		return nil, err
+	}
+	item.attributes = attributes
+
+	if item.repaired, err = unpackKeys(item.packer, frames[11]); err != nil {
+		return nil, err
+	}
+	if item.elements, err = unpackKeys(item.packer, frames[12]); err != nil {
+		return nil, err
+	}
+
+	params.apply(item, metricsOrDefault(params.Metrics))
+
+	return item, nil
+}
+
+// packKeys serialises each of the keys, as framed fields
+func packKeys[T comparable](packer IDSerialiser[T], keys []T) ([]byte, error) {
+	var b []byte
+	for _, t := range keys {
+		bKey, err := packer.Pack(t)
+		if err != nil {
+			return nil, err
+		}
+		b = appendFrame(b, bKey)
+	}
+	return b, nil
+}
+
+// unpackKeys is the inverse of packKeys
+func unpackKeys[T comparable](packer IDSerialiser[T], data []byte) ([]T, error) {
+	frames, err := splitFrames(data)
+	if err != nil {
+		return nil, err
+	}
+	var keys []T
+	for _, f := range frames {
+		t, err := packer.Unpack(f)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, t)
+	}
+	return keys, nil
+}
@@ -0,0 +1,73 @@
+package packer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestEncryptedItemMarshalBinary(t *testing.T) {
+
+	testPack, testUnpack, provider := testCreateEnv(t)
+
+	item := &Item[Key]{
+		Key:        Key{X: "A", Y: "B"},
+		Attributes: map[string]any{"a": "x", "b": int64(2), "c": []byte("bytes")},
+	}
+
+	for _, version := range []PackVersion{V1, V2} {
+
+		b, l, err := testPack(item, WithPackingVersion(version), WithKeyHierarchy("tenant1"))
+		if err != nil {
+			t.Fatalf("Unexpected error packing: %v", err)
+		}
+		e, err := testUnpack(b, l)
+		if err != nil {
+			t.Fatalf("Unexpected error unpacking: %v", err)
+		}
+
+		data, err := e.MarshalBinary()
+		if err != nil {
+			t.Fatalf("Unexpected error marshalling: %v", err)
+		}
+
+		serialiser, _ := NewKeySerialiser()
+		params := &UnpackParams[Key]{
+			IDRetriever: func(string) (IDSerialiser[Key], error) { return serialiser, nil },
+		}
+		r, err := UnmarshalEncryptedItem(data, params)
+		if err != nil {
+			t.Fatalf("Unexpected error unmarshalling: %v", err)
+		}
+
+		if r.GetKey() != e.GetKey() || !slices.Equal(r.ElementKeys(), e.ElementKeys()) || !slices.Equal(r.KeyHierarchy(), e.KeyHierarchy()) {
+			t.Fatal("Mismatch in restored item")
+		}
+		values, err := r.GetAllValues(context.TODO(), provider)
+		if err != nil {
+			t.Fatalf("Unexpected error getting values: %v", err)
+		}
+		if len(values) != len(item.Attributes) || values["a"] != "x" || values["b"] != int64(2) {
+			t.Fatalf("Mismatch: expected %v, got %v", item.Attributes, values)
+		}
+
+		// The restored item retains the envelope, so its key can be rewrapped
+		x, _ := encodeFinalisedData(e.version, e.wire, e.envelope)
+		y, _ := encodeFinalisedData(r.version, r.wire, r.envelope)
+		if r.version != version || len(x) == 0 || !bytes.Equal(x, y) {
+			t.Fatal("Mismatch in restored envelope")
+		}
+	}
+
+	if _, err := UnmarshalEncryptedItem([]byte("junk"), &UnpackParams[Key]{IDRetriever: func(string) (IDSerialiser[Key], error) { return nil, nil }}); !errors.Is(err, ErrInvalidDataToUnmarshalItem) {
+		t.Fatalf("Expected ErrInvalidDataToUnmarshalItem, got: %v", err)
+	}
+	if _, err := UnmarshalEncryptedItem[Key](nil, &UnpackParams[Key]{}); !errors.Is(err, ErrIDRetrieverIsNil) {
+		t.Fatalf("Expected ErrIDRetrieverIsNil, got: %v", err)
+	}
+	if _, err := (&EncryptedItem[Key]{streamed: map[string][]*chunkLocation{"a": nil}}).MarshalBinary(); !errors.Is(err, ErrCannotMarshalStreamedItem) {
+		t.Fatalf("Expected ErrCannotMarshalStreamedItem, got: %v", err)
+	}
+}
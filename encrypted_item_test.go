@@ -2,8 +2,14 @@ package packer
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"maps"
+	"slices"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestEncryptedItem_GetValues(t *testing.T) {
@@ -226,3 +232,306 @@ func TestEncryptedItem_GetValues_4(t *testing.T) {
 		t.Fatal("Unexpected mismatch in attribute values")
 	}
 }
+
+// cancellingProvider cancels the context once the key has been decrypted
+type cancellingProvider struct {
+	EnvelopeKeyProvider
+	cancel context.CancelFunc
+}
+
+func (c *cancellingProvider) Decrypt(ctx context.Context, encryptedKey []byte) ([]byte, error) {
+	defer c.cancel()
+	return c.EnvelopeKeyProvider.Decrypt(ctx, encryptedKey)
+}
+
+func TestEncryptedItem_GetValues_5(t *testing.T) {
+
+	packer, unpacker, provider := testCreateEnv(t)
+
+	item := &Item[Key]{
+		Key: Key{X: "A", Y: "B"},
+		Attributes: map[string]any{
+			"a": "Hello",
+			"b": "World",
+		},
+	}
+
+	b, l, err := packer(item)
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+
+	e, err := unpacker(b, l)
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+
+	if _, err := e.GetValues(ctx, []string{"a", "b"}, provider); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Unexpected error: expected: %v, got: %v", context.Canceled, err)
+	}
+
+	// Workers stop once the request is abandoned
+	ctx, cancel = context.WithCancel(context.TODO())
+	defer cancel()
+
+	if _, err := e.GetValues(ctx, []string{"a", "b"}, &cancellingProvider{EnvelopeKeyProvider: provider, cancel: cancel}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Unexpected error: expected: %v, got: %v", context.Canceled, err)
+	}
+}
+
+func TestEncryptedItem_GetValuesStream(t *testing.T) {
+
+	packer, unpacker, provider := testCreateEnv(t)
+
+	item := &Item[Key]{
+		Key: Key{X: "A", Y: "B"},
+		Attributes: map[string]any{
+			"a": "Hello",
+			"b": "World",
+			"c": int64(42),
+		},
+	}
+
+	b, l, err := packer(item)
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+
+	e, err := unpacker(b, l)
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+
+	if _, err := e.GetValuesStream(context.TODO(), []string{"a"}, nil); !errors.Is(err, ErrProviderIsNil) {
+		t.Fatalf("Unexpected error: expected: %v, got: %v", ErrProviderIsNil, err)
+	}
+
+	c, err := e.GetValuesStream(context.TODO(), []string{"a", "b", "c", "missing"}, provider)
+	if err != nil {
+		t.Fatalf("Unexpected error getting values: %v", err)
+	}
+
+	m := map[string]any{}
+	for r := range c {
+		if r.Err != nil {
+			t.Fatalf("Unexpected error for %s: %v", r.Attribute, r.Err)
+		}
+		m[r.Attribute] = r.Value
+	}
+	if len(m) != len(item.Attributes) {
+		t.Fatalf("Unexpected values: %v", m)
+	}
+	for k, v := range item.Attributes {
+		if m[k] != v {
+			t.Fatalf("Unexpected value for %s: expected: %v, got: %v", k, v, m[k])
+		}
+	}
+
+	// Cancellation closes the channel without all results being received
+	ctx, cancel := context.WithCancel(context.TODO())
+	c, err = e.GetValuesStream(ctx, []string{"a", "b", "c"}, provider)
+	if err != nil {
+		t.Fatalf("Unexpected error getting values: %v", err)
+	}
+	<-c
+	cancel()
+	for range c {
+	}
+}
+
+func TestEncryptedItem_GetValuesStream_1(t *testing.T) {
+
+	packer, unpacker, provider := testCreateEnv(t)
+
+	item := &Item[Key]{
+		Key:        Key{X: "A", Y: "B"},
+		Attributes: map[string]any{"a": int64(1), "b": int64(2), "c": int64(3), "d": int64(4)},
+	}
+
+	b, l, err := packer(item)
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+	e, err := unpacker(b, l)
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+
+	var decrypted atomic.Int32
+	e.hooks = []TransformHook{func(attr string, v any) (any, error) {
+		decrypted.Add(1)
+		return v, nil
+	}}
+
+	c, err := e.GetValuesStream(context.TODO(), []string{"a", "b", "c", "d"}, provider, WithDecryptConcurrency(2))
+	if err != nil {
+		t.Fatalf("Unexpected error getting values: %v", err)
+	}
+
+	// Decryption stops once the concurrency limit is reached, until results are received
+	time.Sleep(50 * time.Millisecond)
+	if n := decrypted.Load(); n != 2 {
+		t.Fatalf("Expected 2 attributes to be decrypted before any result is received, got %d", n)
+	}
+
+	<-c
+	time.Sleep(50 * time.Millisecond)
+	if n := decrypted.Load(); n != 3 {
+		t.Fatalf("Expected 3 attributes to be decrypted after a result is received, got %d", n)
+	}
+
+	count := 1
+	for range c {
+		count++
+	}
+	if count != 4 || decrypted.Load() != 4 {
+		t.Fatalf("Unexpected results: %d received, %d decrypted", count, decrypted.Load())
+	}
+}
+
+func TestEncryptedItem_AttributeNames(t *testing.T) {
+
+	testPack, testUnpack, _ := testCreateEnv(t)
+
+	item := &Item[Key]{
+		Key:        Key{X: "A", Y: "B"},
+		Attributes: map[string]any{"c": "x", "a": int64(2), "b": 3.5},
+	}
+
+	b, l, err := testPack(item)
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+
+	e, err := testUnpack(b, l)
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+	if names := e.AttributeNames(); !slices.Equal(names, []string{"a", "b", "c"}) {
+		t.Fatalf("Unexpected attribute names: %v", names)
+	}
+}
+
+func TestEncryptedItem_GetAllValues(t *testing.T) {
+
+	testPack, testUnpack, provider := testCreateEnv(t)
+
+	item := &Item[Key]{
+		Key:        Key{X: "A", Y: "B"},
+		Attributes: map[string]any{"c": "x", "a": int64(2), "b": 3.5},
+	}
+
+	b, l, err := testPack(item)
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+
+	e, err := testUnpack(b, l)
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+
+	for _, limit := range []int{0, 1} {
+		values, err := e.GetAllValues(context.TODO(), provider, WithDecryptConcurrency(limit))
+		if err != nil {
+			t.Fatalf("Unexpected error getting values: %v", err)
+		}
+		if !maps.Equal(values, item.Attributes) {
+			t.Fatalf("Mismatch: expected %v, got %v", item.Attributes, values)
+		}
+	}
+
+	if _, err := e.GetAllValues(context.TODO(), nil); !errors.Is(err, ErrProviderIsNil) {
+		t.Fatalf("Expected ErrProviderIsNil, got: %v", err)
+	}
+}
+
+func TestEncryptedItem_GetValue(t *testing.T) {
+
+	testPack, testUnpack, provider := testCreateEnv(t)
+
+	item := &Item[Key]{
+		Key:        Key{X: "A", Y: "B"},
+		Attributes: map[string]any{"a": int64(2), "b": "x"},
+	}
+
+	b, l, err := testPack(item)
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+
+	e, err := testUnpack(b, l)
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+
+	v, found, err := e.GetValue(context.TODO(), "a", provider)
+	if err != nil || !found || v != int64(2) {
+		t.Fatalf("Unexpected result: %v, %v, %v", v, found, err)
+	}
+
+	if v, found, err := e.GetValue(context.TODO(), "missing", provider); err != nil || found || v != nil {
+		t.Fatalf("Unexpected result for missing attribute: %v, %v, %v", v, found, err)
+	}
+
+	if _, _, err := e.GetValue(context.TODO(), "a", nil); !errors.Is(err, ErrProviderIsNil) {
+		t.Fatalf("Expected ErrProviderIsNil, got: %v", err)
+	}
+}
+
+func TestEncryptedItemElementKeys(t *testing.T) {
+
+	testPack, testUnpack, provider := testCreateEnv(t)
+
+	item := &Item[Key]{
+		Key:        Key{X: "A", Y: "B"},
+		Attributes: map[string]any{},
+	}
+	for i := range 600 {
+		b := make([]byte, 20)
+		if _, err := rand.Read(b); err != nil {
+			t.Fatalf("Unexpected error creating name: %v", err)
+		}
+		item.Attributes[hex.EncodeToString(b)] = int64(i)
+	}
+
+	var manifest ElementManifest[Key]
+	info, l, err := testPack(item, WithMaximumKBSize(10), WithElementManifest(&manifest))
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+
+	e, err := testUnpack(info, l)
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+
+	serialiser, _ := NewKeySerialiser()
+	plan, err := PlanDeletion(context.TODO(), info, &UnpackParams[Key]{
+		DataLoader:  l,
+		IDRetriever: func(string) (IDSerialiser[Key], error) { return serialiser, nil },
+		Provider:    provider,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error planning deletion: %v", err)
+	}
+	if len(plan.Elements[ElementRoleContinuation]) == 0 {
+		t.Fatal("Expected the envelope to be split across continuation elements")
+	}
+
+	keys := e.ElementKeys()
+	if !slices.Equal(keys, plan.ElementKeys()) {
+		t.Fatalf("Mismatch with the deletion plan: expected %v, got %v", plan.ElementKeys(), keys)
+	}
+	if len(keys) != len(manifest.Elements) {
+		t.Fatalf("Expected %d elements, got %d", len(manifest.Elements), len(keys))
+	}
+	for _, k := range manifest.Elements {
+		if !slices.Contains(keys, k) {
+			t.Fatalf("Element %v missing from the element keys", k)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package packer
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"maps"
+	"sort"
+)
+
+// EncryptionContextProvider is implemented by EnvelopeKeyProviders that can bind an encryption context
+// to the keys they create (for example, as a KMS EncryptionContext, or as additional authenticated data),
+// so that the key can only be decrypted if the same encryption context is supplied again.
+// Decrypt obtains the encryption context from the context.Context, using EncryptionContextFromContext.
+type EncryptionContextProvider interface {
+	// NewWithEncryptionContext returns a unique key as New(), bound to the encryption context
+	NewWithEncryptionContext(ec map[string]string) ([]byte, []byte, error)
+}
+
+// ErrProviderDoesNotSupportEncryptionContext raised if an encryption context is specified for a provider
+// that does not implement EncryptionContextProvider
+var ErrProviderDoesNotSupportEncryptionContext = errors.New("provider cannot bind an encryption context - it must implement EncryptionContextProvider")
+
+type encryptionContextKey struct{}
+
+// ContextWithEncryptionContext returns a context holding the encryption context, which is passed to the
+// provider's Decrypt.  Unpack and GetValues add the encryption context from the UnpackParams automatically.
+func ContextWithEncryptionContext(ctx context.Context, ec map[string]string) context.Context {
+	return context.WithValue(ctx, encryptionContextKey{}, maps.Clone(ec))
+}
+
+// EncryptionContextFromContext returns the encryption context added with ContextWithEncryptionContext, or nil if none
+func EncryptionContextFromContext(ctx context.Context) map[string]string {
+	if ctx == nil {
+		return nil
+	}
+	ec, _ := ctx.Value(encryptionContextKey{}).(map[string]string)
+	return ec
+}
+
+// canonicalEncryptionContext serialises the encryption context as length-prefixed names and values, in name order
+func canonicalEncryptionContext(ec map[string]string) []byte {
+
+	names := make([]string, 0, len(ec))
+	for k := range ec {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	b := binary.BigEndian.AppendUint32(nil, uint32(len(names)))
+	for _, k := range names {
+		b = binary.BigEndian.AppendUint32(b, uint32(len(k)))
+		b = append(b, k...)
+		b = binary.BigEndian.AppendUint32(b, uint32(len(ec[k])))
+		b = append(b, ec[k]...)
+	}
+	return b
+}
@@ -0,0 +1,74 @@
+package packer
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/gford1000-go/serialise"
+)
+
+func TestEncryptionContext(t *testing.T) {
+
+	_, _, provider := testCreateEnv(t)
+
+	serialiser, err := NewKeySerialiser()
+	if err != nil {
+		t.Fatalf("Unexpected error preparing Key serialiser: %v", err)
+	}
+
+	ec := map[string]string{"tenant": "acme", "item": "A"}
+
+	pParams := &PackParams[Key]{
+		Provider:          provider,
+		Creator:           NewKeyCreator(defaultLen),
+		Packer:            serialiser,
+		Approach:          serialise.NewMinDataApproachWithVersion(serialise.V1),
+		EncryptionContext: ec,
+	}
+
+	info, data, err := Pack(&Item[Key]{Key: Key{X: "A", Y: "B"}, Attributes: map[string]any{"aaa": "Hello World"}}, pParams)
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+
+	unpack := func(ec map[string]string) (*EncryptedItem[Key], error) {
+		return Unpack(context.TODO(), info, &UnpackParams[Key]{
+			IDRetriever:       func(string) (IDSerialiser[Key], error) { return serialiser, nil },
+			Provider:          provider,
+			EncryptionContext: ec,
+			DataLoader: func(ctx context.Context, keys []Key) (map[string][]byte, error) {
+				m := map[string][]byte{}
+				for _, k := range keys {
+					for n, v := range data[k] {
+						m[n] = v
+					}
+				}
+				return m, nil
+			},
+		})
+	}
+
+	e, err := unpack(map[string]string{"item": "A", "tenant": "acme"})
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+	m, err := e.GetValues(context.TODO(), []string{"aaa"}, provider)
+	if err != nil {
+		t.Fatalf("Unexpected error getting values: %v", err)
+	}
+	if m["aaa"] != "Hello World" {
+		t.Fatalf("Unexpected value: %v", m["aaa"])
+	}
+
+	for i, other := range []map[string]string{nil, {"tenant": "other", "item": "A"}, {"tenant": "acme"}} {
+		if _, err := unpack(other); !errors.Is(err, ErrKeyProviderDecryptError) {
+			t.Fatalf("(%d) Unexpected error: expected: %v, got: %v", i, ErrKeyProviderDecryptError, err)
+		}
+	}
+
+	pParams.Provider = &countingProvider{EnvelopeKeyProvider: provider}
+	if _, _, err := Pack(&Item[Key]{Key: Key{X: "A", Y: "B"}, Attributes: map[string]any{"aaa": "x"}}, pParams); !errors.Is(err, ErrProviderDoesNotSupportEncryptionContext) {
+		t.Fatalf("Unexpected error: expected: %v, got: %v", ErrProviderDoesNotSupportEncryptionContext, err)
+	}
+}
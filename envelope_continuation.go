@@ -0,0 +1,202 @@
+package packer
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gford1000-go/serialise"
+)
+
+// ErrEnvelopeContinued raised if packed data whose envelope was split across continuation elements
+// is used without first being reassembled using JoinEnvelope.  Unpack reassembles envelopes itself.
+var ErrEnvelopeContinued = errors.New("envelope is split across continuation elements, and must be joined before use")
+
+// ErrEnvelopeTooLarge raised if an envelope is too large to be split across continuation elements
+// within the maximum size
+var ErrEnvelopeTooLarge = errors.New("envelope cannot be split within the maximum size")
+
+// ErrInvalidEnvelopeContinuation raised if the continuation elements of an envelope cannot be reassembled
+var ErrInvalidEnvelopeContinuation = errors.New("invalid data, cannot reassemble envelope from continuation elements")
+
+// continuationOverhead is the allowance for serialisation within each continuation element
+const continuationOverhead uint64 = 1024
+
+// splitEnvelope stores packed data exceeding the maximum size in continuation elements, which are added
+// to the output, returning a descriptor of the continuation elements to be used in place of the data.
+// The descriptor is the packing version, the name of the IDSerialiser, and the serialised key and chunk
+// name of each continuation element in order.
+func splitEnvelope[T comparable](data []byte, output map[T]map[string][]byte, params *PackParams[T], o *Options) ([]byte, error) {
+
+	used := map[string]bool{}
+	for _, m := range output {
+		for name := range m {
+			used[name] = true
+		}
+	}
+
+	partSize := int(o.maxSize - continuationOverhead)
+	descriptor := []any{int8(o.packingVersion), params.Packer.Name()}
+
+	ids, err := createIDs(params.Creator, (len(data)+partSize-1)/partSize)
+	if err != nil {
+		return nil, err
+	}
+
+	for offset := 0; offset < len(data); offset += partSize {
+
+		name := ""
+		for range o.attrNameRetries {
+			if candidate := o.attributeName(); !used[candidate] {
+				name = candidate
+				break
+			}
+		}
+		if name == "" {
+			return nil, ErrUnableToCreateUniqueName
+		}
+		used[name] = true
+
+		t := ids[offset/partSize]
+		bKey, err := params.Packer.Pack(t)
+		if err != nil {
+			return nil, err
+		}
+
+		output[t] = map[string][]byte{name: data[offset:min(offset+partSize, len(data))]}
+		descriptor = append(descriptor, bKey, name)
+	}
+
+	b, _, err := serialise.ToBytesMany(descriptor, serialise.WithSerialisationApproach(serialise.NewMinDataApproachWithVersion(serialise.V1)))
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(b)) > o.maxSize {
+		return nil, ErrEnvelopeTooLarge
+	}
+	return b, nil
+}
+
+// JoinEnvelope returns the packed data with any envelope that was split across continuation elements by
+// Pack reassembled, loading the continuation elements with the loader.  Data whose envelope was not split
+// is returned unchanged.  Functions accepting packed data with a loader, such as Unpack, UnpackPipeline and
+// VerifyMerkleRoot, reassemble envelopes themselves; those without, such as PackDigest and MerkleRoot, raise
+// ErrEnvelopeContinued unless the data is first reassembled.
+func JoinEnvelope[T comparable](ctx context.Context, data []byte, loader DataLoader[T], idRetriever GetIDSerialiser[T]) ([]byte, error) {
+
+	v, err := continuationDescriptor(data)
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return data, nil
+	}
+	if loader == nil {
+		return nil, ErrDataLoaderIsNil
+	}
+	if idRetriever == nil {
+		return nil, ErrIDRetrieverIsNil
+	}
+
+	keys, names, err := continuationElements(v, idRetriever)
+	if err != nil {
+		return nil, err
+	}
+
+	parts, err := loader(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	var b []byte
+	for _, name := range names {
+		part, ok := parts[name]
+		if !ok {
+			return nil, ErrInvalidEnvelopeContinuation
+		}
+		b = append(b, part...)
+	}
+
+	// The reassembled data is always packed using a single packing version
+	packingVersion, _, err := splitPackingVersion(b)
+	if err != nil {
+		return nil, err
+	}
+	if int8(packingVersion) != v[0].(int8) {
+		return nil, ErrInvalidEnvelopeContinuation
+	}
+	return b, nil
+}
+
+// continuationElements returns the keys of the continuation elements in the descriptor, and the chunk name of each
+func continuationElements[T comparable](v []any, idRetriever GetIDSerialiser[T]) ([]T, []string, error) {
+
+	packer, err := idRetriever(v[1].(string))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keys := make([]T, 0, (len(v)-2)/2)
+	names := make([]string, 0, (len(v)-2)/2)
+	for i := 2; i < len(v); i += 2 {
+		bKey, ok := v[i].([]byte)
+		if !ok {
+			return nil, nil, ErrInvalidEnvelopeContinuation
+		}
+		name, ok := v[i+1].(string)
+		if !ok {
+			return nil, nil, ErrInvalidEnvelopeContinuation
+		}
+		t, err := packer.Unpack(bKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		keys = append(keys, t)
+		names = append(names, name)
+	}
+	return keys, names, nil
+}
+
+// continuationDescriptor returns the deserialised continuation descriptor, or nil if the data is not a descriptor
+func continuationDescriptor(data []byte) ([]any, error) {
+
+	data, err := Dearmor(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// Continuation descriptors are always serialised, so data in the layout of V2 is never a descriptor
+	if isV2(data) {
+		return nil, nil
+	}
+
+	v, err := serialise.FromBytesMany(data, serialise.NewMinDataApproachWithVersion(serialise.V1))
+	if err != nil {
+		return nil, err
+	}
+	if !isContinuation(v) {
+		return nil, nil
+	}
+	return v, nil
+}
+
+// continuationKeys returns the keys of the continuation elements holding the envelope, if it was split
+func continuationKeys[T comparable](data []byte, idRetriever GetIDSerialiser[T]) ([]T, error) {
+	v, err := continuationDescriptor(data)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	keys, _, err := continuationElements(v, idRetriever)
+	return keys, err
+}
+
+// isContinuation returns true if the deserialised packed data is a continuation descriptor
+func isContinuation(v []any) bool {
+	if len(v) < 4 || len(v)%2 != 0 {
+		return false
+	}
+	if _, ok := v[0].(int8); !ok {
+		return false
+	}
+	_, ok := v[1].(string)
+	return ok
+}
@@ -0,0 +1,215 @@
+package packer
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"maps"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/gford1000-go/serialise"
+)
+
+func TestEnvelopeContinuation(t *testing.T) {
+
+	testPack, testUnpack, provider := testCreateEnv(t)
+
+	item := &Item[Key]{
+		Key:        Key{X: "A", Y: "B"},
+		Attributes: map[string]any{},
+	}
+	for i := range 600 {
+		b := make([]byte, 20)
+		if _, err := rand.Read(b); err != nil {
+			t.Fatalf("Unexpected error creating name: %v", err)
+		}
+		item.Attributes[hex.EncodeToString(b)] = int64(i)
+	}
+
+	info, l, err := testPack(item, WithMaximumKBSize(10))
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+	if len(info) > 10*1024 {
+		t.Fatalf("Expected packed data within the maximum size, got %d bytes", len(info))
+	}
+	if _, _, err := splitPackingVersion(info); !errors.Is(err, ErrEnvelopeContinued) {
+		t.Fatalf("Expected ErrEnvelopeContinued, got: %v", err)
+	}
+
+	e, err := testUnpack(info, l)
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+	values, err := e.GetValues(context.TODO(), slices.Collect(maps.Keys(item.Attributes)), provider)
+	if err != nil {
+		t.Fatalf("Unexpected error getting values: %v", err)
+	}
+	if !reflect.DeepEqual(values, item.Attributes) {
+		t.Fatal("Mismatch between packed and unpacked values")
+	}
+
+	serialiser, _ := NewKeySerialiser()
+	idRetriever := func(string) (IDSerialiser[Key], error) { return serialiser, nil }
+
+	joined, err := JoinEnvelope(context.TODO(), info, l, idRetriever)
+	if err != nil {
+		t.Fatalf("Unexpected error joining: %v", err)
+	}
+	if v, _, err := splitPackingVersion(joined); err != nil || v != V1 {
+		t.Fatalf("Expected a joined envelope, got: %v, %v", v, err)
+	}
+	if b, err := JoinEnvelope(context.TODO(), joined, l, idRetriever); err != nil || !slices.Equal(b, joined) {
+		t.Fatalf("Expected a joined envelope to be unchanged, got: %v", err)
+	}
+
+	missing := func(ctx context.Context, keys []Key) (map[string][]byte, error) { return map[string][]byte{}, nil }
+	if _, err := JoinEnvelope(context.TODO(), info, missing, idRetriever); !errors.Is(err, ErrInvalidEnvelopeContinuation) {
+		t.Fatalf("Expected ErrInvalidEnvelopeContinuation, got: %v", err)
+	}
+}
+
+func TestEnvelopeContinuation_1(t *testing.T) {
+
+	_, _, provider := testCreateEnv(t)
+
+	serialiser, _ := NewKeySerialiser()
+	idRetriever := func(string) (IDSerialiser[Key], error) { return serialiser, nil }
+
+	item := &Item[Key]{
+		Key:        Key{X: "A", Y: "B"},
+		Attributes: map[string]any{},
+	}
+	for i := range 600 {
+		b := make([]byte, 20)
+		if _, err := rand.Read(b); err != nil {
+			t.Fatalf("Unexpected error creating name: %v", err)
+		}
+		item.Attributes[hex.EncodeToString(b)] = int64(i)
+	}
+
+	info, data, err := Pack(item, &PackParams[Key]{
+		Provider: provider,
+		Creator:  NewKeyCreator(defaultLen),
+		Packer:   serialiser,
+		Approach: serialise.NewMinDataApproachWithVersion(serialise.V1),
+	}, WithMaximumKBSize(10), WithMerkleRoot(), WithDigest([]byte("digest key")))
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+
+	loader := func(ctx context.Context, keys []Key) (map[string][]byte, error) {
+		m := map[string][]byte{}
+		for _, k := range keys {
+			maps.Copy(m, data[k])
+		}
+		return m, nil
+	}
+	params := &UnpackParams[Key]{
+		DataLoader:  loader,
+		IDRetriever: idRetriever,
+		Provider:    provider,
+	}
+
+	continuation, err := continuationKeys(info, idRetriever)
+	if err != nil || len(continuation) == 0 {
+		t.Fatalf("Expected continuation elements, got: %v", err)
+	}
+
+	check := func(name string, e *EncryptedItem[Key]) {
+		values, err := e.GetValues(context.TODO(), slices.Collect(maps.Keys(item.Attributes)), provider)
+		if err != nil {
+			t.Fatalf("(%s) Unexpected error getting values: %v", name, err)
+		}
+		if !reflect.DeepEqual(values, item.Attributes) {
+			t.Fatalf("(%s) Mismatch between packed and unpacked values", name)
+		}
+		for _, k := range continuation {
+			if !slices.Contains(e.ElementKeys(), k) {
+				t.Fatalf("(%s) Expected the continuation elements in the element keys", name)
+			}
+		}
+	}
+
+	// Entry points with a loader join the envelope themselves
+	envelopes := make(chan []byte, 1)
+	envelopes <- info
+	close(envelopes)
+	results, err := UnpackPipeline(context.TODO(), envelopes, params, func(ctx context.Context, keys []Key) (map[Key]map[string][]byte, error) {
+		m := map[Key]map[string][]byte{}
+		for _, k := range keys {
+			m[k] = data[k]
+		}
+		return m, nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error starting pipeline: %v", err)
+	}
+	for r := range results {
+		if r.Err != nil {
+			t.Fatalf("Unexpected error from pipeline: %v", r.Err)
+		}
+		check("UnpackPipeline", r.Item)
+	}
+
+	stored := map[Key][]byte{}
+	for k, m := range data {
+		if stored[k], err = EncodeElement(m); err != nil {
+			t.Fatalf("Unexpected error encoding element: %v", err)
+		}
+	}
+	e, err := UnpackReaderAt(context.TODO(), info, &UnpackParams[Key]{IDRetriever: idRetriever, Provider: provider}, func(ctx context.Context, key Key) (io.ReaderAt, error) {
+		b, ok := stored[key]
+		if !ok {
+			return nil, errors.New("missing element")
+		}
+		return bytes.NewReader(b), nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking with a ReaderAtLoader: %v", err)
+	}
+	check("UnpackReaderAt", e)
+
+	if err := VerifyMerkleRoot(context.TODO(), info, params); err != nil {
+		t.Fatalf("Unexpected error verifying the merkle root: %v", err)
+	}
+	attr := slices.Collect(maps.Keys(item.Attributes))[0]
+	proofs, err := GenerateProof(context.TODO(), info, params, attr)
+	if err != nil || len(proofs) == 0 {
+		t.Fatalf("Unexpected error generating proofs: %v", err)
+	}
+
+	// Entry points without a loader require the envelope to be joined first
+	if _, err := MerkleRoot(context.TODO(), info, provider, idRetriever); !errors.Is(err, ErrEnvelopeContinued) {
+		t.Fatalf("Expected ErrEnvelopeContinued, got: %v", err)
+	}
+	if _, err := PackDigest(info); !errors.Is(err, ErrEnvelopeContinued) {
+		t.Fatalf("Expected ErrEnvelopeContinued, got: %v", err)
+	}
+	if _, err := PackEncryptedKey(info); !errors.Is(err, ErrEnvelopeContinued) {
+		t.Fatalf("Expected ErrEnvelopeContinued, got: %v", err)
+	}
+
+	joined, err := JoinEnvelope(context.TODO(), info, loader, idRetriever)
+	if err != nil {
+		t.Fatalf("Unexpected error joining: %v", err)
+	}
+	root, err := MerkleRoot(context.TODO(), joined, provider, idRetriever)
+	if err != nil {
+		t.Fatalf("Unexpected error getting the merkle root: %v", err)
+	}
+	if err := VerifyProof(root, proofs[0]); err != nil {
+		t.Fatalf("Unexpected error verifying proof: %v", err)
+	}
+	if _, err := PackDigest(joined); err != nil {
+		t.Fatalf("Unexpected error getting the digest: %v", err)
+	}
+	if _, err := PackEncryptedKey(joined); err != nil {
+		t.Fatalf("Unexpected error getting the encrypted key: %v", err)
+	}
+}
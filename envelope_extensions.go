@@ -0,0 +1,162 @@
+package packer
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/gford1000-go/serialise"
+)
+
+// envelopeExtensions hold optional settings recorded within the encrypted envelope.
+// Extensions are only present when the corresponding feature is used, so that data
+// packed without them is unchanged from data packed by earlier releases.
+type envelopeExtensions map[string][]byte
+
+const (
+	extCompression  = "compression"
+	extChecksums    = "checksums"
+	extErasure      = "erasure"
+	extReplicas     = "replicas"
+	extCipher       = "cipher"
+	extElementKeys  = "elementKeys"
+	extKeyHierarchy = "keyHierarchy"
+	extMerkleRoot   = "merkleRoot"
+	// Records that the attribute map is a dictionary of names
+	extAttrDictionary = "attrDictionary"
+	// Records that the attribute map is encoded in CBOR
+	extCBORAttrMap = "cborAttrMap"
+	// Records the Compression of the attribute map and elements
+	extMetadataCompression = "metadataCompression"
+	// Records the ID of the CipherSuite that encrypted attribute values
+	extCipherSuite = "cipherSuite"
+	// Holds the chunks of all elements, if packed inline
+	extInline = "inline"
+	// Holds the SHA-256 of each stored chunk, if signed
+	extChunkDigests = "chunkDigests"
+	// Records the reuse of the data encryption key, if packed by a BulkPacker
+	extKeyReuse = "keyReuse"
+)
+
+// ErrInvalidDataToDeserialiseExtensions raised if the envelope extensions cannot be deserialised
+var ErrInvalidDataToDeserialiseExtensions = errors.New("invalid data, cannot deserialise envelope extensions")
+
+// pack serialises the extensions as alternating names and values, ordered by name
+func (e envelopeExtensions) pack(approach serialise.Approach) ([]byte, error) {
+
+	names := make([]string, 0, len(e))
+	for k := range e {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	items := make([]any, 0, 2*len(e))
+	for _, name := range names {
+		items = append(items, name, e[name])
+	}
+
+	b, _, err := serialise.ToBytesMany(items, serialise.WithSerialisationApproach(approach))
+	return b, err
+}
+
+func unpackEnvelopeExtensions(data []byte, approach serialise.Approach) (envelopeExtensions, error) {
+
+	v, err := serialise.FromBytesMany(data, approach)
+	if err != nil {
+		return nil, err
+	}
+	if len(v)%2 != 0 {
+		return nil, ErrInvalidDataToDeserialiseExtensions
+	}
+
+	e := make(envelopeExtensions, len(v)/2)
+	for i := 0; i < len(v); i += 2 {
+		name, ok := v[i].(string)
+		if !ok {
+			return nil, ErrInvalidDataToDeserialiseExtensions
+		}
+		b, ok := v[i+1].([]byte)
+		if !ok {
+			return nil, ErrInvalidDataToDeserialiseExtensions
+		}
+		e[name] = b
+	}
+
+	return e, nil
+}
+
+// compression returns the Compression recorded during packing, defaulting to NoCompression
+func (e envelopeExtensions) compression() (Compression, error) {
+	return e.compressionOf(extCompression)
+}
+
+// metadataCompression returns the Compression applied to the attribute map and elements of the envelope
+func (e envelopeExtensions) metadataCompression() (Compression, error) {
+	return e.compressionOf(extMetadataCompression)
+}
+
+func (e envelopeExtensions) compressionOf(name string) (Compression, error) {
+	b, ok := e[name]
+	if !ok {
+		return NoCompression, nil
+	}
+	if len(b) != 1 {
+		return NoCompression, ErrInvalidDataToDeserialiseExtensions
+	}
+	c := Compression(int8(b[0]))
+	if c < NoCompression || c >= compressionOutOfRange {
+		return NoCompression, ErrUnknownCompression
+	}
+	return c, nil
+}
+
+// cipherAlgorithm returns the CipherAlgorithm used to encrypt attribute values, defaulting to AES256GCM
+func (e envelopeExtensions) cipherAlgorithm() (CipherAlgorithm, error) {
+	b, ok := e[extCipher]
+	if !ok {
+		return AES256GCM, nil
+	}
+	if len(b) != 1 {
+		return AES256GCM, ErrInvalidDataToDeserialiseExtensions
+	}
+	c := CipherAlgorithm(b[0])
+	if c >= cipherAlgorithmOutOfRange {
+		return AES256GCM, ErrUnknownCipherAlgorithm
+	}
+	return c, nil
+}
+
+// keyHierarchy returns the key hierarchy recorded during packing, if any
+func (e envelopeExtensions) keyHierarchy(approach serialise.Approach) ([]KeyDerivation, error) {
+	b, ok := e[extKeyHierarchy]
+	if !ok {
+		return nil, nil
+	}
+	return unpackKeyHierarchy(b, approach)
+}
+
+// checksums returns the chunk checksums recorded during packing, if any
+func (e envelopeExtensions) checksums(approach serialise.Approach) (map[string]chunkChecksum, error) {
+	b, ok := e[extChecksums]
+	if !ok {
+		return nil, nil
+	}
+	return unpackChecksums(b, approach)
+}
+
+// chunkDigests returns the signed chunk digests recorded during packing, if any
+func (e envelopeExtensions) chunkDigests(approach serialise.Approach) (map[string][]byte, error) {
+	b, ok := e[extChunkDigests]
+	if !ok {
+		return nil, nil
+	}
+	return unpackChunkDigests(b, approach)
+}
+
+// erasure returns the erasure coding layout recorded during packing, if any
+func (e envelopeExtensions) erasure(approach serialise.Approach) (*erasureLayout, error) {
+	b, ok := e[extErasure]
+	if !ok {
+		return nil, nil
+	}
+	return unpackErasureLayout(b, approach)
+}
@@ -0,0 +1,84 @@
+package packer
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+)
+
+// envelopeMACLabel is the HKDF info from which the MAC key is derived from the data encryption key
+var envelopeMACLabel = []byte("packer envelope mac")
+
+// WithEnvelopeMAC records an HMAC-SHA256 over the visible part of the envelope, which Unpack verifies once the
+// data encryption key has been decrypted.  The encrypted key, packer name, approach name and any digest are
+// otherwise unauthenticated, so could be altered without detection.  The MAC key is derived from the data
+// encryption key, so no further key management is required.  Data packed with a MAC cannot be unpacked by
+// earlier releases.
+func WithEnvelopeMAC() func(o *Options) {
+	return func(o *Options) {
+		o.envelopeMAC = true
+	}
+}
+
+// ErrEnvelopeMACMismatch raised if the visible part of the envelope does not match its MAC
+var ErrEnvelopeMACMismatch = errors.New("envelope MAC does not match - packed data has been altered")
+
+// ErrEnvelopeMACRequired raised if the UnpackParams require a MAC, and the packed data does not include one
+var ErrEnvelopeMACRequired = errors.New("packed data does not include an envelope MAC")
+
+// envelopeMAC returns the MAC of the fields of the finalised data that precede the MAC, together with
+// the packing version and wire format, so that none can be changed independently
+func envelopeMAC(encKey []byte, packingVersion PackVersion, wire WireFormat, finalisedData []any) ([]byte, error) {
+
+	h := hmac.New(sha256.New, hkdfSHA256(encKey, nil, envelopeMACLabel, sha256.Size))
+	h.Write([]byte{byte(packingVersion), byte(wire)})
+
+	for i := range finalisedMAC {
+		b, err := finalisedField(finalisedData, i)
+		if err != nil {
+			return nil, err
+		}
+		var l [8]byte
+		binary.BigEndian.PutUint64(l[:], uint64(len(b)))
+		h.Write(l[:])
+		h.Write(b)
+	}
+	return h.Sum(nil), nil
+}
+
+// verifyEnvelopeMAC checks any MAC of the envelope, once its data encryption key is available
+func (d *itemPackingDetailsV1[T]) verifyEnvelopeMAC(env *envelopeV1[T]) error {
+
+	mac, err := finalisedField(env.finalisedData, finalisedMAC)
+	if err != nil {
+		return err
+	}
+	if len(mac) == 0 {
+		if d.requireMAC {
+			return ErrEnvelopeMACRequired
+		}
+		return nil
+	}
+
+	expected, err := envelopeMAC(env.encKey, env.version, env.wire, env.finalisedData)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(mac, expected) {
+		return ErrEnvelopeMACMismatch
+	}
+	return nil
+}
+
+// resealEnvelopeMAC replaces any MAC of the finalised data, after a field it covers has changed
+func resealEnvelopeMAC(encKey []byte, packingVersion PackVersion, wire WireFormat, finalisedData []any) error {
+
+	mac, err := finalisedField(finalisedData, finalisedMAC)
+	if err != nil || len(mac) == 0 {
+		return err
+	}
+
+	finalisedData[finalisedMAC], err = envelopeMAC(encKey, packingVersion, wire, finalisedData)
+	return err
+}
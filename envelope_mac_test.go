@@ -0,0 +1,113 @@
+package packer
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"testing"
+)
+
+func TestWithEnvelopeMAC(t *testing.T) {
+
+	testPack, testUnpack, provider := testCreateEnv(t)
+
+	item := &Item[Key]{
+		Key:        Key{X: "A", Y: "B"},
+		Attributes: map[string]any{"a": "x", "b": int64(2)},
+	}
+
+	for _, opts := range [][]func(*Options){
+		{WithEnvelopeMAC()},
+		{WithEnvelopeMAC(), WithDigest([]byte("digest key"))},
+		{WithEnvelopeMAC(), WithPackingVersion(V2)},
+		{WithEnvelopeMAC(), WithPackingVersion(V2), WithWireFormat(ProtoWireFormat)},
+		{WithEnvelopeMAC(), WithPackingVersion(V2), WithWireFormat(CBORWireFormat)},
+	} {
+		b, l, err := testPack(item, opts...)
+		if err != nil {
+			t.Fatalf("Unexpected error packing: %v", err)
+		}
+
+		e, err := testUnpack(b, l)
+		if err != nil {
+			t.Fatalf("Unexpected error unpacking: %v", err)
+		}
+		values, err := e.GetValues(context.TODO(), []string{"a", "b"}, provider)
+		if err != nil {
+			t.Fatalf("Unexpected error getting values: %v", err)
+		}
+		if !maps.Equal(values, item.Attributes) {
+			t.Fatalf("Mismatch: expected %v, got %v", item.Attributes, values)
+		}
+
+		packingVersion, vb, _ := splitPackingVersion(b)
+		finalisedData, wire, err := decodeFinalisedData(packingVersion, vb)
+		if err != nil {
+			t.Fatalf("Unexpected error decoding: %v", err)
+		}
+		if len(finalisedData) != finalisedMAC+1 {
+			t.Fatalf("Expected a MAC to be recorded, got %d fields", len(finalisedData))
+		}
+
+		// Rewrapping replaces the encrypted key, so the MAC is recreated
+		rb, err := e.ReWrap(context.TODO(), provider, provider)
+		if err != nil {
+			t.Fatalf("Unexpected error rewrapping: %v", err)
+		}
+		if _, err := testUnpack(rb, l); err != nil {
+			t.Fatalf("Unexpected error unpacking rewrapped data: %v", err)
+		}
+
+		// Swapping the packer name is detected, once the data encryption key is available
+		altered := slices.Clone(finalisedData)
+		altered[1] = "Altered"
+		ab, _ := encodeFinalisedData(packingVersion, wire, altered)
+		ab, _ = joinPackingVersion(packingVersion, ab)
+		if _, err := testUnpack(ab, l); !errors.Is(err, ErrEnvelopeMACMismatch) {
+			t.Fatalf("Expected ErrEnvelopeMACMismatch, got: %v", err)
+		}
+	}
+}
+
+func TestRequireEnvelopeMAC(t *testing.T) {
+
+	testPack, _, provider := testCreateEnv(t)
+
+	item := &Item[Key]{
+		Key:        Key{X: "A", Y: "B"},
+		Attributes: map[string]any{"a": "x"},
+	}
+
+	serialiser, _ := NewKeySerialiser()
+	unpack := func(b []byte, l DataLoader[Key]) error {
+		_, err := Unpack(context.TODO(), b, &UnpackParams[Key]{
+			DataLoader:         l,
+			IDRetriever:        func(string) (IDSerialiser[Key], error) { return serialiser, nil },
+			Provider:           provider,
+			RequireEnvelopeMAC: true,
+		})
+		return err
+	}
+
+	b, l, err := testPack(item)
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+	if err := unpack(b, l); !errors.Is(err, ErrEnvelopeMACRequired) {
+		t.Fatalf("Expected ErrEnvelopeMACRequired, got: %v", err)
+	}
+
+	b, l, err = testPack(item, WithEnvelopeMAC())
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+	if err := unpack(b, l); err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+
+	// Data packed with a MAC but without a digest reports that there is no digest
+	if _, err := PackDigest(b); !errors.Is(err, ErrNoDigest) {
+		t.Fatalf("Expected ErrNoDigest, got: %v", err)
+	}
+}
@@ -0,0 +1,427 @@
+package packer
+
+import (
+	"errors"
+	"hash/crc32"
+	"sort"
+
+	"github.com/gford1000-go/serialise"
+)
+
+// WithErasureCoding adds the specified number of Reed-Solomon parity elements to the output of Pack,
+// so that the item remains recoverable if up to that many of its elements are lost from storage.
+// Unpack reconstructs lost elements transparently; EncryptedItem.RepairedElements reports which
+// elements were repaired.  If checksums are also recorded (see WithChecksums), corrupted elements
+// are repaired in the same way.
+func WithErasureCoding(parity uint8) func(o *Options) {
+	return func(o *Options) {
+		o.parityElements = parity
+	}
+}
+
+// ErrTooManyElementsForErasureCoding raised if the data and parity elements exceed the 256 supported by the code
+var ErrTooManyElementsForErasureCoding = errors.New("erasure coding supports at most 256 data and parity elements in total")
+
+// ErrUnrecoverableElements raised if more elements are missing than the parity elements can reconstruct
+var ErrUnrecoverableElements = errors.New("too many elements are missing or corrupted to be reconstructed")
+
+// ErrInvalidDataToDeserialiseErasure raised if the recorded erasure coding layout cannot be deserialised
+var ErrInvalidDataToDeserialiseErasure = errors.New("invalid data, cannot deserialise erasure coding layout")
+
+// erasureChunk records where a chunk is located within its element's shard
+type erasureChunk struct {
+	name   string
+	length int
+}
+
+// erasureLayout describes how the data elements were encoded, so that they can be reconstructed.
+// Data elements are the first len(data) entries of the elements slice, followed by the parity elements.
+type erasureLayout struct {
+	data   [][]erasureChunk
+	parity []string
+}
+
+// shard concatenates the element's chunk values in the order recorded by the layout, into a buffer from the allocator
+func (l *erasureLayout) shard(i int, values map[string][]byte, size int, alloc Allocator) ([]byte, bool) {
+	b := alloc.Alloc(size)[:0]
+	for _, c := range l.data[i] {
+		v, ok := values[c.name]
+		if !ok || len(v) != c.length {
+			alloc.Free(b[:size])
+			return nil, false
+		}
+		b = append(b, v...)
+	}
+	n := len(b)
+	b = b[:size]
+	clear(b[n:])
+	return b, true
+}
+
+func (l *erasureLayout) shardSize() int {
+	size := 0
+	for _, chunks := range l.data {
+		n := 0
+		for _, c := range chunks {
+			n += c.length
+		}
+		size = max(size, n)
+	}
+	return size
+}
+
+// addParityElements extends the elements and output with parity elements, returning the layout used
+func addParityElements[T comparable](parity int, elements []T, output map[T]map[string][]byte, creator IDCreator[T], newName func(int) (string, error), alloc Allocator) ([]T, *erasureLayout, error) {
+
+	if len(elements)+parity > 256 {
+		return nil, nil, ErrTooManyElementsForErasureCoding
+	}
+
+	layout := &erasureLayout{data: make([][]erasureChunk, len(elements))}
+	for i, t := range elements {
+		names := make([]string, 0, len(output[t]))
+		for name := range output[t] {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			layout.data[i] = append(layout.data[i], erasureChunk{name: name, length: len(output[t][name])})
+		}
+	}
+
+	size := layout.shardSize()
+	shards := make([][]byte, len(elements))
+	for i, t := range elements {
+		shards[i], _ = layout.shard(i, output[t], size, alloc)
+	}
+
+	// Shards are only needed to calculate the parity, which is held separately
+	parityShards := rsEncode(shards, parity)
+	for _, b := range shards {
+		alloc.Free(b)
+	}
+
+	ids, err := createIDs(creator, len(parityShards))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for i, p := range parityShards {
+		name, err := newName(i)
+		if err != nil {
+			return nil, nil, err
+		}
+		t := ids[i]
+		elements = append(elements, t)
+		output[t] = map[string][]byte{name: p}
+		layout.parity = append(layout.parity, name)
+	}
+
+	return elements, layout, nil
+}
+
+// repairElements reconstructs any data elements whose chunks are missing from values, or which fail
+// their checksum, adding the recovered chunks to values.  The indices of repaired elements are returned.
+func repairElements(layout *erasureLayout, checksums map[string]chunkChecksum, values map[string][]byte) ([]int, error) {
+
+	size := layout.shardSize()
+	n := len(layout.data)
+
+	shards := make([][]byte, n+len(layout.parity))
+	var missing []int
+
+	for i := range layout.data {
+		b, ok := layout.shard(i, values, size, HeapAllocator{})
+		if ok {
+			for _, c := range layout.data[i] {
+				if cs, found := checksums[c.name]; found && crc32.Checksum(values[c.name], castagnoli) != cs.crc {
+					ok = false
+					break
+				}
+			}
+		}
+		if ok {
+			shards[i] = b
+		} else {
+			missing = append(missing, i)
+		}
+	}
+
+	if len(missing) == 0 {
+		return nil, nil
+	}
+
+	for j, name := range layout.parity {
+		v, ok := values[name]
+		if !ok || len(v) != size {
+			continue
+		}
+		if cs, found := checksums[name]; found && crc32.Checksum(v, castagnoli) != cs.crc {
+			continue
+		}
+		shards[n+j] = v
+	}
+
+	data, err := rsReconstruct(shards, n)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, i := range missing {
+		offset := 0
+		for _, c := range layout.data[i] {
+			values[c.name] = data[i][offset : offset+c.length]
+			offset += c.length
+		}
+	}
+
+	return missing, nil
+}
+
+func packErasureLayout(layout *erasureLayout, approach serialise.Approach) ([]byte, error) {
+
+	items := []any{int64(len(layout.data))}
+	for _, chunks := range layout.data {
+		items = append(items, int64(len(chunks)))
+		for _, c := range chunks {
+			items = append(items, c.name, int64(c.length))
+		}
+	}
+	items = append(items, int64(len(layout.parity)))
+	for _, name := range layout.parity {
+		items = append(items, name)
+	}
+
+	b, _, err := serialise.ToBytesMany(items, serialise.WithSerialisationApproach(approach))
+	return b, err
+}
+
+func unpackErasureLayout(data []byte, approach serialise.Approach) (*erasureLayout, error) {
+
+	v, err := serialise.FromBytesMany(data, approach)
+	if err != nil {
+		return nil, err
+	}
+
+	pos := 0
+	nextInt := func() (int, error) {
+		if pos >= len(v) {
+			return 0, ErrInvalidDataToDeserialiseErasure
+		}
+		i, ok := v[pos].(int64)
+		if !ok || i < 0 {
+			return 0, ErrInvalidDataToDeserialiseErasure
+		}
+		pos++
+		return int(i), nil
+	}
+	nextString := func() (string, error) {
+		if pos >= len(v) {
+			return "", ErrInvalidDataToDeserialiseErasure
+		}
+		s, ok := v[pos].(string)
+		if !ok {
+			return "", ErrInvalidDataToDeserialiseErasure
+		}
+		pos++
+		return s, nil
+	}
+
+	n, err := nextInt()
+	if err != nil || n > len(v) {
+		return nil, ErrInvalidDataToDeserialiseErasure
+	}
+
+	layout := &erasureLayout{data: make([][]erasureChunk, n)}
+	for i := range n {
+		m, err := nextInt()
+		if err != nil || m > len(v) {
+			return nil, ErrInvalidDataToDeserialiseErasure
+		}
+		for range m {
+			name, err := nextString()
+			if err != nil {
+				return nil, err
+			}
+			length, err := nextInt()
+			if err != nil {
+				return nil, err
+			}
+			layout.data[i] = append(layout.data[i], erasureChunk{name: name, length: length})
+		}
+	}
+
+	p, err := nextInt()
+	if err != nil || p > len(v) {
+		return nil, ErrInvalidDataToDeserialiseErasure
+	}
+	for range p {
+		name, err := nextString()
+		if err != nil {
+			return nil, err
+		}
+		layout.parity = append(layout.parity, name)
+	}
+
+	if pos != len(v) || n+p > 256 {
+		return nil, ErrInvalidDataToDeserialiseErasure
+	}
+
+	return layout, nil
+}
+
+// ---------------------------------------------------------------------
+// Systematic Reed-Solomon code over GF(2^8), using a Cauchy matrix for
+// the parity rows, so that any n of the n+p shards recover the data.
+// ---------------------------------------------------------------------
+
+var gfExp [512]byte
+var gfLog [256]byte
+
+func init() {
+	x := 1
+	for i := range 255 {
+		gfExp[i] = byte(x)
+		gfLog[x] = byte(i)
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11d
+		}
+	}
+	for i := 255; i < 512; i++ {
+		gfExp[i] = gfExp[i-255]
+	}
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+int(gfLog[b])]
+}
+
+func gfInv(a byte) byte {
+	return gfExp[255-int(gfLog[a])]
+}
+
+// rsRow returns the encoding row for shard r of a code with n data shards
+func rsRow(r, n int) []byte {
+	row := make([]byte, n)
+	if r < n {
+		row[r] = 1
+		return row
+	}
+	for i := range n {
+		// Cauchy element 1/(x_r + y_i) with x_r = r and y_i = i, which are always distinct
+		row[i] = gfInv(byte(r) ^ byte(i))
+	}
+	return row
+}
+
+func rsEncode(data [][]byte, parity int) [][]byte {
+	n := len(data)
+	size := 0
+	if n > 0 {
+		size = len(data[0])
+	}
+
+	out := make([][]byte, parity)
+	for j := range parity {
+		row := rsRow(n+j, n)
+		p := make([]byte, size)
+		for i, d := range data {
+			c := row[i]
+			for k := range size {
+				p[k] ^= gfMul(c, d[k])
+			}
+		}
+		out[j] = p
+	}
+	return out
+}
+
+// rsReconstruct returns the n data shards, given shards where unavailable entries are nil
+func rsReconstruct(shards [][]byte, n int) ([][]byte, error) {
+
+	rows := make([][]byte, 0, n)
+	avail := make([][]byte, 0, n)
+	for r, s := range shards {
+		if s != nil {
+			rows = append(rows, rsRow(r, n))
+			avail = append(avail, s)
+			if len(rows) == n {
+				break
+			}
+		}
+	}
+	if len(rows) < n {
+		return nil, ErrUnrecoverableElements
+	}
+
+	inv, err := gfInvertMatrix(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	size := len(avail[0])
+	data := make([][]byte, n)
+	for i := range n {
+		d := make([]byte, size)
+		for j := range n {
+			c := inv[i][j]
+			if c == 0 {
+				continue
+			}
+			for k := range size {
+				d[k] ^= gfMul(c, avail[j][k])
+			}
+		}
+		data[i] = d
+	}
+	return data, nil
+}
+
+func gfInvertMatrix(m [][]byte) ([][]byte, error) {
+	n := len(m)
+	a := make([][]byte, n)
+	inv := make([][]byte, n)
+	for i := range n {
+		a[i] = append([]byte{}, m[i]...)
+		inv[i] = make([]byte, n)
+		inv[i][i] = 1
+	}
+
+	for col := range n {
+		pivot := -1
+		for r := col; r < n; r++ {
+			if a[r][col] != 0 {
+				pivot = r
+				break
+			}
+		}
+		if pivot < 0 {
+			return nil, ErrUnrecoverableElements
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+		inv[col], inv[pivot] = inv[pivot], inv[col]
+
+		f := gfInv(a[col][col])
+		for k := range n {
+			a[col][k] = gfMul(a[col][k], f)
+			inv[col][k] = gfMul(inv[col][k], f)
+		}
+
+		for r := range n {
+			if r == col || a[r][col] == 0 {
+				continue
+			}
+			f := a[r][col]
+			for k := range n {
+				a[r][k] ^= gfMul(f, a[col][k])
+				inv[r][k] ^= gfMul(f, inv[col][k])
+			}
+		}
+	}
+
+	return inv, nil
+}
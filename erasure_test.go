@@ -0,0 +1,140 @@
+package packer
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestRSReconstruct(t *testing.T) {
+
+	n, p := 5, 3
+
+	data := make([][]byte, n)
+	for i := range data {
+		data[i] = make([]byte, 100)
+		if _, err := rand.Read(data[i]); err != nil {
+			t.Fatalf("Unexpected error creating data: %v", err)
+		}
+	}
+
+	parity := rsEncode(data, p)
+
+	// Every combination of up to p lost shards is recoverable
+	for mask := 0; mask < 1<<(n+p); mask++ {
+		lost := 0
+		shards := make([][]byte, n+p)
+		for i := range n + p {
+			if mask&(1<<i) != 0 {
+				lost++
+				continue
+			}
+			if i < n {
+				shards[i] = data[i]
+			} else {
+				shards[i] = parity[i-n]
+			}
+		}
+
+		recovered, err := rsReconstruct(shards, n)
+		if lost > p {
+			if !errors.Is(err, ErrUnrecoverableElements) {
+				t.Fatalf("(%b) Unexpected error: expected: %v, got: %v", mask, ErrUnrecoverableElements, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("(%b) Unexpected error reconstructing: %v", mask, err)
+		}
+		for i := range n {
+			if !bytes.Equal(recovered[i], data[i]) {
+				t.Fatalf("(%b) Mismatch in recovered shard %d", mask, i)
+			}
+		}
+	}
+}
+
+func TestWithErasureCoding(t *testing.T) {
+
+	testPack, testUnpack, provider := testCreateEnv(t)
+
+	// Random data is incompressible, so ensures multiple elements are created
+	item := &Item[Key]{
+		Key:        Key{X: "A", Y: "B"},
+		Attributes: map[string]any{},
+	}
+	for i := range 6 {
+		b := make([]byte, 15*1024)
+		if _, err := rand.Read(b); err != nil {
+			t.Fatalf("Unexpected error creating data: %v", err)
+		}
+		item.Attributes[fmt.Sprintf("attr%d", i)] = b
+	}
+
+	info, l, err := testPack(item, WithMaximumKBSize(40), WithErasureCoding(2), WithChecksums())
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+
+	var elements []Key
+	recorder := func(ctx context.Context, keys []Key) (map[string][]byte, error) {
+		elements = keys
+		return l(ctx, keys)
+	}
+	if _, err := testUnpack(info, recorder); err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+	if len(elements) < 4 {
+		t.Fatalf("Expected at least two data and two parity elements, got: %d", len(elements))
+	}
+
+	// lossyLoader loses the specified elements, and corrupts the data of others
+	lossyLoader := func(lost map[Key]bool, corrupt map[Key]bool) DataLoader[Key] {
+		return func(ctx context.Context, keys []Key) (map[string][]byte, error) {
+			m := map[string][]byte{}
+			for _, key := range keys {
+				if lost[key] {
+					continue
+				}
+				km, err := l(ctx, []Key{key})
+				if err != nil {
+					return nil, err
+				}
+				for k, v := range km {
+					if corrupt[key] {
+						v = append([]byte{}, v...)
+						v[0] ^= 0xff
+					}
+					m[k] = v
+				}
+			}
+			return m, nil
+		}
+	}
+
+	e, err := testUnpack(info, lossyLoader(map[Key]bool{elements[0]: true}, map[Key]bool{elements[1]: true}))
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking with lost elements: %v", err)
+	}
+
+	repaired := e.RepairedElements()
+	if len(repaired) != 2 || repaired[0] != elements[0] || repaired[1] != elements[1] {
+		t.Fatalf("Unexpected repaired elements: expected: %v, got: %v", elements[:2], repaired)
+	}
+
+	for k, v := range item.Attributes {
+		m, err := e.GetValues(context.TODO(), []string{k}, provider)
+		if err != nil {
+			t.Fatalf("Unexpected error getting values: %v", err)
+		}
+		compareValue(m[k], v, "[]byte", t)
+	}
+
+	_, err = testUnpack(info, lossyLoader(map[Key]bool{elements[0]: true, elements[1]: true, elements[len(elements)-1]: true}, nil))
+	if !errors.Is(err, ErrUnrecoverableElements) {
+		t.Fatalf("Unexpected error: expected: %v, got: %v", ErrUnrecoverableElements, err)
+	}
+}
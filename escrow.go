@@ -0,0 +1,388 @@
+package packer
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/gford1000-go/serialise"
+)
+
+// EscrowPolicy describes the conditions under which an escrowed key may be released
+type EscrowPolicy struct {
+	// Name identifies the policy
+	Name string
+	// Approvals is the number of distinct approvers required to release a key, which must be at least one
+	Approvals int
+	// Delay is the minimum time between a release being requested and the key being released
+	Delay time.Duration
+}
+
+// EscrowApproval records a request to release an escrowed key, and the signatures of those who approved it
+type EscrowApproval struct {
+	// EscrowedKey is the digest of the escrowed key whose release is approved (see EscrowedKeyDigest)
+	EscrowedKey []byte
+	// RequestedAt is when the release was requested
+	RequestedAt time.Time
+	// ExpiresAt is when the approval lapses, after which the key cannot be released
+	ExpiresAt time.Time
+	// Signatures of each party approving the release (see SignEscrowApproval)
+	Signatures []EscrowApprovalSignature
+}
+
+// EscrowApprovalSignature is an approver's signature of the release of keys escrowed under a policy
+type EscrowApprovalSignature struct {
+	// Approver is the ID of the Signer, which must match a registered approver's Verifier
+	Approver string
+	// Signature of the EscrowApprovalMessage
+	Signature []byte
+}
+
+// escrowApprovalLabel separates signatures of escrow approvals from any other use of the approver's key
+var escrowApprovalLabel = []byte("packer escrow approval")
+
+// EscrowApprovalMessage returns the message signed by an approver of the release of the escrowed key of the
+// approval, escrowed to the escrow provider under the named policy, for the period of the approval
+func EscrowApprovalMessage(escrow EnvelopeKeyID, policy string, approval EscrowApproval) []byte {
+	b := append([]byte{}, escrowApprovalLabel...)
+	for _, f := range [][]byte{[]byte(escrow), []byte(policy), approval.EscrowedKey} {
+		b = binary.BigEndian.AppendUint64(b, uint64(len(f)))
+		b = append(b, f...)
+	}
+	b = binary.BigEndian.AppendUint64(b, uint64(approval.RequestedAt.UnixNano()))
+	return binary.BigEndian.AppendUint64(b, uint64(approval.ExpiresAt.UnixNano()))
+}
+
+// SignEscrowApproval returns the signer's approval of the release of the escrowed key of the approval, escrowed
+// to the escrow provider under the named policy.  The Signatures of the approval are not signed.
+func SignEscrowApproval(signer Signer, escrow EnvelopeKeyID, policy string, approval EscrowApproval) (EscrowApprovalSignature, error) {
+	if signer == nil || len(signer.ID()) == 0 {
+		return EscrowApprovalSignature{}, ErrSignerIDIsEmpty
+	}
+	signature, err := signer.Sign(EscrowApprovalMessage(escrow, policy, approval))
+	if err != nil {
+		return EscrowApprovalSignature{}, err
+	}
+	return EscrowApprovalSignature{Approver: signer.ID(), Signature: signature}, nil
+}
+
+// EscrowedKeyDigest returns the digest identifying the escrowed key within an encrypted key created by a provider
+// returned by NewEscrowProvider (see PackEncryptedKey), so that its release can be approved
+func EscrowedKeyDigest(encryptedKey []byte) ([]byte, error) {
+	_, escrowedKey, _, _, err := unpackEscrowedKey(encryptedKey)
+	if err != nil {
+		return nil, err
+	}
+	return escrowedKeyDigest(escrowedKey), nil
+}
+
+func escrowedKeyDigest(escrowedKey []byte) []byte {
+	h := sha256.Sum256(escrowedKey)
+	return h[:]
+}
+
+// EscrowEventType identifies the escrow activity reported to an EscrowAuditFunc
+type EscrowEventType string
+
+const (
+	// EscrowKeyWrapped is reported when a new key is wrapped to the escrow key
+	EscrowKeyWrapped EscrowEventType = "wrapped"
+	// EscrowKeyReleased is reported when an escrowed key is released
+	EscrowKeyReleased EscrowEventType = "released"
+	// EscrowReleaseDenied is reported when an escrowed key is not released, because the policy is not satisfied
+	EscrowReleaseDenied EscrowEventType = "denied"
+)
+
+// EscrowEvent describes escrow activity, for audit
+type EscrowEvent struct {
+	// Type of activity
+	Type EscrowEventType
+	// Escrow identifies the escrow key provider
+	Escrow EnvelopeKeyID
+	// Policy that applies to the key
+	Policy EscrowPolicy
+	// Approvers of a release whose signatures were verified, if any
+	Approvers []string
+	// Time of the activity
+	Time time.Time
+	// Err is the reason a release was denied
+	Err error
+}
+
+// EscrowAuditFunc receives EscrowEvents; it must be safe for concurrent use
+type EscrowAuditFunc func(EscrowEvent)
+
+// ErrEscrowPolicyInvalid raised if an EscrowPolicy does not require at least one approval
+var ErrEscrowPolicyInvalid = errors.New("escrow policy must require at least one approval")
+
+// ErrEscrowApprovalExpired raised if an escrowed key is requested with an approval that has expired
+var ErrEscrowApprovalExpired = errors.New("escrow approval has expired - key cannot be released")
+
+// ErrEscrowPolicyNotSatisfied raised if an escrowed key is requested without meeting its policy
+var ErrEscrowPolicyNotSatisfied = errors.New("escrow policy not satisfied - key cannot be released")
+
+// ErrEscrowPolicyAltered raised if the policy recorded with an escrowed key is not the policy it was escrowed under
+var ErrEscrowPolicyAltered = errors.New("escrow policy has been altered - key cannot be released")
+
+// ErrEscrowProviderCannotCreateKeys raised if New() is called on a provider returned by NewEscrowRecoveryProvider
+var ErrEscrowProviderCannotCreateKeys = errors.New("escrow recovery provider cannot create new keys")
+
+// NewEscrowProvider creates an EnvelopeKeyProvider that uses the provider as normal, but additionally wraps
+// every data encryption key to the escrow provider, which must implement KeyWrapper.  The policy is recorded
+// with each escrowed key, bound to it by an HMAC keyed from the data encryption key, and is enforced when the
+// key is recovered using NewEscrowRecoveryProvider.
+// Each escrowed key is reported to the audit function, if provided.
+func NewEscrowProvider(provider, escrow EnvelopeKeyProvider, policy EscrowPolicy, audit EscrowAuditFunc) (EnvelopeKeyProvider, error) {
+	if provider == nil || escrow == nil {
+		return nil, ErrProviderIsNil
+	}
+	wrapper, ok := escrow.(KeyWrapper)
+	if !ok {
+		return nil, ErrProviderCannotWrap
+	}
+	if policy.Approvals < 1 {
+		return nil, ErrEscrowPolicyInvalid
+	}
+	return &escrowProvider{
+		provider: provider,
+		escrow:   escrow,
+		wrapper:  wrapper,
+		policy:   policy,
+		audit:    audit,
+	}, nil
+}
+
+type escrowProvider struct {
+	provider EnvelopeKeyProvider
+	escrow   EnvelopeKeyProvider
+	wrapper  KeyWrapper
+	policy   EscrowPolicy
+	audit    EscrowAuditFunc
+}
+
+func (e *escrowProvider) ID() EnvelopeKeyID {
+	return e.provider.ID()
+}
+
+func (e *escrowProvider) New() ([]byte, []byte, error) {
+	return e.NewWithContext(context.Background())
+}
+
+func (e *escrowProvider) NewWithContext(ctx context.Context) ([]byte, []byte, error) {
+
+	encryptedKey, key, err := newKey(ctx, e.provider)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	escrowedKey, err := e.wrapper.Wrap(ctx, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	b, err := packEscrowedKey(encryptedKey, escrowedKey, e.policy, escrowPolicyMAC(key, escrowedKey, e.policy))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if e.audit != nil {
+		e.audit(EscrowEvent{Type: EscrowKeyWrapped, Escrow: e.escrow.ID(), Policy: e.policy, Time: time.Now()})
+	}
+
+	return b, key, nil
+}
+
+func (e *escrowProvider) Decrypt(ctx context.Context, encryptedKey []byte) ([]byte, error) {
+	primary, _, _, _, err := unpackEscrowedKey(encryptedKey)
+	if err != nil {
+		return nil, err
+	}
+	return e.provider.Decrypt(ctx, primary)
+}
+
+// NewEscrowRecoveryProvider creates an EnvelopeKeyProvider that decrypts keys wrapped by NewEscrowProvider
+// using the escrow provider, provided that the approval of each key satisfies the policy recorded with it.  Only
+// signatures that are verified by the Verifier of a registered approver, with the same ID, count towards
+// the approvals required by the policy, and the delay is measured from the signed time of the request.
+// Each approval applies only to its escrowed key, and only until it expires.
+// A key is only released once the policy recorded with it is confirmed to be the policy it was escrowed under.
+// It can be used with Unpack and GetValues to recover items when the original provider is unavailable.
+// Each release, or denial, is reported to the audit function, if provided.
+func NewEscrowRecoveryProvider(escrow EnvelopeKeyProvider, approvals []EscrowApproval, approvers []Verifier, audit EscrowAuditFunc) (EnvelopeKeyProvider, error) {
+	if escrow == nil {
+		return nil, ErrProviderIsNil
+	}
+	return &escrowRecoveryProvider{
+		escrow:    escrow,
+		approvals: approvals,
+		approvers: approvers,
+		audit:     audit,
+		now:       time.Now,
+	}, nil
+}
+
+type escrowRecoveryProvider struct {
+	escrow    EnvelopeKeyProvider
+	approvals []EscrowApproval
+	approvers []Verifier
+	audit     EscrowAuditFunc
+	now       func() time.Time
+}
+
+func (e *escrowRecoveryProvider) ID() EnvelopeKeyID {
+	return e.escrow.ID()
+}
+
+func (e *escrowRecoveryProvider) New() ([]byte, []byte, error) {
+	return nil, nil, ErrEscrowProviderCannotCreateKeys
+}
+
+func (e *escrowRecoveryProvider) Decrypt(ctx context.Context, encryptedKey []byte) ([]byte, error) {
+
+	_, escrowedKey, policy, mac, err := unpackEscrowedKey(encryptedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	event := EscrowEvent{Escrow: e.escrow.ID(), Policy: policy, Time: e.now()}
+
+	deny := func(err error) ([]byte, error) {
+		event.Type, event.Err = EscrowReleaseDenied, err
+		if e.audit != nil {
+			e.audit(event)
+		}
+		return nil, err
+	}
+
+	digest := escrowedKeyDigest(escrowedKey)
+	i := slices.IndexFunc(e.approvals, func(a EscrowApproval) bool { return hmac.Equal(a.EscrowedKey, digest) })
+	if i < 0 {
+		return deny(ErrEscrowPolicyNotSatisfied)
+	}
+
+	event.Approvers, err = e.approvals[i].satisfies(policy, e.escrow.ID(), e.approvers, event.Time)
+	if err != nil {
+		return deny(err)
+	}
+
+	key, err := e.escrow.Decrypt(ctx, escrowedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// The policy is checked against the escrowed key before it is released, so it cannot be weakened
+	if !hmac.Equal(mac, escrowPolicyMAC(key, escrowedKey, policy)) {
+		clear(key)
+		return deny(ErrEscrowPolicyAltered)
+	}
+
+	event.Type = EscrowKeyReleased
+	if e.audit != nil {
+		e.audit(event)
+	}
+
+	return key, nil
+}
+
+// satisfies returns the distinct approvers whose signatures are verified by the registered approvers, or an
+// error if the approval does not meet the policy at the specified time
+func (a EscrowApproval) satisfies(policy EscrowPolicy, escrow EnvelopeKeyID, approvers []Verifier, now time.Time) ([]string, error) {
+
+	if a.ExpiresAt.IsZero() || !now.Before(a.ExpiresAt) {
+		return nil, ErrEscrowApprovalExpired
+	}
+
+	message := EscrowApprovalMessage(escrow, policy.Name, a)
+
+	verified := []string{}
+	for _, s := range a.Signatures {
+		if len(s.Approver) == 0 || slices.Contains(verified, s.Approver) {
+			continue
+		}
+		for _, v := range approvers {
+			if v != nil && v.ID() == s.Approver && v.Verify(message, s.Signature) == nil {
+				verified = append(verified, s.Approver)
+				break
+			}
+		}
+	}
+
+	if policy.Approvals < 1 || len(verified) < policy.Approvals {
+		return verified, ErrEscrowPolicyNotSatisfied
+	}
+	if a.RequestedAt.IsZero() || now.Sub(a.RequestedAt) < policy.Delay {
+		return verified, ErrEscrowPolicyNotSatisfied
+	}
+	return verified, nil
+}
+
+// escrowPolicyLabel is the HKDF info from which the key binding the policy to an escrowed key is derived
+var escrowPolicyLabel = []byte("packer escrow policy")
+
+// escrowPolicyMAC binds the policy to the escrowed key, using a key derived from the data encryption key, so
+// that the policy can only be changed by someone able to decrypt the key
+func escrowPolicyMAC(key, escrowedKey []byte, policy EscrowPolicy) []byte {
+	h := hmac.New(sha256.New, hkdfSHA256(key, nil, escrowPolicyLabel, sha256.Size))
+	for _, f := range [][]byte{escrowedKey, []byte(policy.Name)} {
+		h.Write(binary.BigEndian.AppendUint64(nil, uint64(len(f))))
+		h.Write(f)
+	}
+	h.Write(binary.BigEndian.AppendUint64(nil, uint64(policy.Approvals)))
+	h.Write(binary.BigEndian.AppendUint64(nil, uint64(policy.Delay)))
+	return h.Sum(nil)
+}
+
+func packEscrowedKey(encryptedKey, escrowedKey []byte, policy EscrowPolicy, mac []byte) ([]byte, error) {
+	b, _, err := serialise.ToBytesMany(
+		[]any{
+			encryptedKey,
+			escrowedKey,
+			policy.Name,
+			int64(policy.Approvals),
+			int64(policy.Delay),
+			mac,
+		}, serialise.WithSerialisationApproach(serialise.NewMinDataApproachWithVersion(serialise.V1)))
+	return b, err
+}
+
+func unpackEscrowedKey(data []byte) ([]byte, []byte, EscrowPolicy, []byte, error) {
+
+	v, err := serialise.FromBytesMany(data, serialise.NewMinDataApproachWithVersion(serialise.V1))
+	if err != nil {
+		return nil, nil, EscrowPolicy{}, nil, err
+	}
+	if len(v) != 6 {
+		return nil, nil, EscrowPolicy{}, nil, ErrKeyDeserialisationError
+	}
+
+	encryptedKey, ok := v[0].([]byte)
+	if !ok {
+		return nil, nil, EscrowPolicy{}, nil, ErrKeyDeserialisationError
+	}
+	escrowedKey, ok := v[1].([]byte)
+	if !ok {
+		return nil, nil, EscrowPolicy{}, nil, ErrKeyDeserialisationError
+	}
+	name, ok := v[2].(string)
+	if !ok {
+		return nil, nil, EscrowPolicy{}, nil, ErrKeyDeserialisationError
+	}
+	approvals, ok := v[3].(int64)
+	if !ok {
+		return nil, nil, EscrowPolicy{}, nil, ErrKeyDeserialisationError
+	}
+	delay, ok := v[4].(int64)
+	if !ok {
+		return nil, nil, EscrowPolicy{}, nil, ErrKeyDeserialisationError
+	}
+	mac, ok := v[5].([]byte)
+	if !ok {
+		return nil, nil, EscrowPolicy{}, nil, ErrKeyDeserialisationError
+	}
+
+	return encryptedKey, escrowedKey, EscrowPolicy{Name: name, Approvals: int(approvals), Delay: time.Duration(delay)}, mac, nil
+}
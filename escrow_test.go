@@ -0,0 +1,207 @@
+package packer
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gford1000-go/serialise"
+)
+
+func TestNewEscrowProvider(t *testing.T) {
+
+	newProvider := func(id EnvelopeKeyID) EnvelopeKeyProvider {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			t.Fatalf("Unexpected error creating key: %v", err)
+		}
+		finder := func(EnvelopeKeyID) (EnvelopeKeyProvider, error) {
+			return nil, errors.New("unknown provider id")
+		}
+		p, err := NewEnvelopeKeyProvider(&EnvelopeKeyProviderInfo{ID: id, Key: key}, finder)
+		if err != nil {
+			t.Fatalf("Unexpected error creating provider: %v", err)
+		}
+		return p
+	}
+
+	var mu sync.Mutex
+	events := []EscrowEvent{}
+	audit := func(e EscrowEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, e)
+	}
+
+	primary, escrow := newProvider("primary"), newProvider("escrow")
+
+	if _, err := NewEscrowProvider(primary, escrow, EscrowPolicy{Name: "no approval"}, audit); !errors.Is(err, ErrEscrowPolicyInvalid) {
+		t.Fatalf("Expected ErrEscrowPolicyInvalid, got: %v", err)
+	}
+
+	policy := EscrowPolicy{Name: "dual approval", Approvals: 2, Delay: time.Hour}
+
+	provider, err := NewEscrowProvider(primary, escrow, policy, audit)
+	if err != nil {
+		t.Fatalf("Unexpected error creating provider: %v", err)
+	}
+
+	serialiser, err := NewKeySerialiser()
+	if err != nil {
+		t.Fatalf("Unexpected error preparing Key serialiser: %v", err)
+	}
+
+	item := &Item[Key]{
+		Key:        Key{X: "A", Y: "B"},
+		Attributes: map[string]any{"aaa": "Hello World"},
+	}
+
+	info, data, err := Pack(item, &PackParams[Key]{
+		Provider: provider,
+		Creator:  NewKeyCreator(defaultLen),
+		Packer:   serialiser,
+		Approach: serialise.NewMinDataApproachWithVersion(serialise.V1),
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+	if len(events) != 1 || events[0].Type != EscrowKeyWrapped || events[0].Policy != policy {
+		t.Fatalf("Unexpected audit events: %v", events)
+	}
+
+	getValueFrom := func(info []byte, p EnvelopeKeyProvider) (any, error) {
+		e, err := Unpack(context.TODO(), info, &UnpackParams[Key]{
+			IDRetriever: func(string) (IDSerialiser[Key], error) { return serialiser, nil },
+			Provider:    p,
+			DataLoader: func(ctx context.Context, keys []Key) (map[string][]byte, error) {
+				m := map[string][]byte{}
+				for _, key := range keys {
+					for k, v := range data[key] {
+						m[k] = v
+					}
+				}
+				return m, nil
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		m, err := e.GetValues(context.TODO(), []string{"aaa"}, p)
+		if err != nil {
+			return nil, err
+		}
+		return m["aaa"], nil
+	}
+	getValue := func(p EnvelopeKeyProvider) (any, error) {
+		return getValueFrom(info, p)
+	}
+
+	if v, err := getValue(provider); err != nil || v != "Hello World" {
+		t.Fatalf("Unexpected result using primary provider: %v, %v", v, err)
+	}
+
+	alice, aliceVerifier := testSigner(t, "alice")
+	bob, bobVerifier := testSigner(t, "bob")
+	mallory, _ := testSigner(t, "bob")
+	approvers := []Verifier{aliceVerifier, bobVerifier}
+
+	encryptedKey, err := PackEncryptedKey(info)
+	if err != nil {
+		t.Fatalf("Unexpected error retrieving encrypted key: %v", err)
+	}
+	digest, err := EscrowedKeyDigest(encryptedKey)
+	if err != nil {
+		t.Fatalf("Unexpected error creating escrowed key digest: %v", err)
+	}
+
+	approvalFor := func(digest []byte, requestedAt, expiresAt time.Time, signers ...Signer) EscrowApproval {
+		a := EscrowApproval{EscrowedKey: digest, RequestedAt: requestedAt, ExpiresAt: expiresAt}
+		for _, signer := range signers {
+			s, err := SignEscrowApproval(signer, escrow.ID(), policy.Name, a)
+			if err != nil {
+				t.Fatalf("Unexpected error signing approval: %v", err)
+			}
+			a.Signatures = append(a.Signatures, s)
+		}
+		return a
+	}
+	approval := func(requestedAt time.Time, signers ...Signer) EscrowApproval {
+		return approvalFor(digest, requestedAt, time.Now().Add(time.Hour), signers...)
+	}
+
+	past := time.Now().Add(-2 * time.Hour)
+	valid := approval(past, alice, bob)
+
+	tests := []struct {
+		approval EscrowApproval
+		err      error
+	}{
+		{approval: approval(past, alice), err: ErrEscrowPolicyNotSatisfied},
+		{approval: approval(past, alice, alice), err: ErrEscrowPolicyNotSatisfied},
+		{approval: approval(time.Now(), alice, bob), err: ErrEscrowPolicyNotSatisfied},
+		// Signatures by unregistered keys, or for another request time, are not counted
+		{approval: approval(past, alice, mallory), err: ErrEscrowPolicyNotSatisfied},
+		{approval: EscrowApproval{EscrowedKey: digest, RequestedAt: past.Add(-time.Hour), ExpiresAt: valid.ExpiresAt, Signatures: valid.Signatures}, err: ErrEscrowPolicyNotSatisfied},
+		{approval: EscrowApproval{EscrowedKey: digest, RequestedAt: past, ExpiresAt: valid.ExpiresAt.Add(time.Hour), Signatures: valid.Signatures}, err: ErrEscrowPolicyNotSatisfied},
+		{approval: EscrowApproval{EscrowedKey: digest, RequestedAt: past, ExpiresAt: valid.ExpiresAt, Signatures: []EscrowApprovalSignature{{Approver: "alice"}, {Approver: "bob"}}}, err: ErrEscrowPolicyNotSatisfied},
+		// Approvals are bound to the escrowed key, and lapse when they expire
+		{approval: approvalFor(make([]byte, len(digest)), past, valid.ExpiresAt, alice, bob), err: ErrEscrowPolicyNotSatisfied},
+		{approval: approvalFor(digest, past, time.Now().Add(-time.Minute), alice, bob), err: ErrEscrowApprovalExpired},
+		{approval: approvalFor(digest, past, time.Time{}, alice, bob), err: ErrEscrowApprovalExpired},
+		{approval: approval(past, alice, bob)},
+	}
+
+	for i, test := range tests {
+		events = nil
+
+		recovery, err := NewEscrowRecoveryProvider(escrow, []EscrowApproval{test.approval}, approvers, audit)
+		if err != nil {
+			t.Fatalf("(%d) Unexpected error creating provider: %v", i, err)
+		}
+
+		v, err := getValue(recovery)
+		if !errors.Is(err, test.err) {
+			t.Fatalf("(%d) Unexpected error: expected: %v, got: %v", i, test.err, err)
+		}
+		if len(events) == 0 {
+			t.Fatalf("(%d) Expected audit events", i)
+		}
+		if test.err != nil {
+			if events[0].Type != EscrowReleaseDenied {
+				t.Fatalf("(%d) Unexpected audit event: %+v", i, events[0])
+			}
+			continue
+		}
+		if v != "Hello World" || events[0].Type != EscrowKeyReleased || len(events[0].Approvers) != 2 {
+			t.Fatalf("(%d) Unexpected result: %v, %+v", i, v, events[0])
+		}
+	}
+
+	// Weakening the policy recorded with the escrowed key is detected before the key is released
+	packingVersion, b, _ := splitPackingVersion(info)
+	finalisedData, wire, _ := decodeFinalisedData(packingVersion, b)
+	primaryKey, escrowedKey, _, mac, err := unpackEscrowedKey(finalisedData[0].([]byte))
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking escrowed key: %v", err)
+	}
+	if finalisedData[0], err = packEscrowedKey(primaryKey, escrowedKey, EscrowPolicy{Name: policy.Name, Approvals: 1}, mac); err != nil {
+		t.Fatalf("Unexpected error packing escrowed key: %v", err)
+	}
+	b, _ = encodeFinalisedData(packingVersion, wire, finalisedData)
+	altered, _ := joinPackingVersion(packingVersion, b)
+
+	events = nil
+	recovery, err := NewEscrowRecoveryProvider(escrow, []EscrowApproval{approval(past, alice)}, approvers, audit)
+	if err != nil {
+		t.Fatalf("Unexpected error creating provider: %v", err)
+	}
+	if _, err := getValueFrom(altered, recovery); !errors.Is(err, ErrEscrowPolicyAltered) {
+		t.Fatalf("Expected ErrEscrowPolicyAltered, got: %v", err)
+	}
+	if len(events) == 0 || events[0].Type != EscrowReleaseDenied {
+		t.Fatalf("Unexpected audit events: %+v", events)
+	}
+}
@@ -0,0 +1,313 @@
+package packer
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"math"
+	"sort"
+)
+
+// ESDKEncryptedDataKey is an encrypted data key, as held in an AWS Encryption SDK message header
+type ESDKEncryptedDataKey struct {
+	// ProviderID identifies the master key provider (e.g. "aws-kms")
+	ProviderID string
+	// ProviderInfo identifies the master key (e.g. the KMS key ARN)
+	ProviderInfo []byte
+	// Ciphertext is the encrypted data key, as returned by the master key provider
+	Ciphertext []byte
+}
+
+// ESDKKeyProvider is implemented by EnvelopeKeyProviders whose encrypted keys can be unwrapped by
+// AWS Encryption SDK master key providers, such as those backed by AWS KMS
+type ESDKKeyProvider interface {
+	EnvelopeKeyProvider
+	// ESDKDataKeys returns the encrypted data keys equivalent to the encrypted key returned by New()
+	ESDKDataKeys(encryptedKey []byte) ([]ESDKEncryptedDataKey, error)
+}
+
+// ESDKHeader is an AWS Encryption SDK message header (message format version 1)
+type ESDKHeader struct {
+	// AlgorithmID identifies the algorithm suite
+	AlgorithmID uint16
+	// MessageID uniquely identifies the message
+	MessageID []byte
+	// EncryptionContext is the authenticated, unencrypted, context of the message
+	EncryptionContext map[string]string
+	// EncryptedDataKeys hold the data key, encrypted by each master key
+	EncryptedDataKeys []ESDKEncryptedDataKey
+	// FrameLength is the length of each frame of framed content
+	FrameLength uint32
+	// IV and AuthTag authenticate the header using a key derived from the data key
+	IV      []byte
+	AuthTag []byte
+
+	body []byte
+}
+
+const (
+	esdkVersion            byte   = 0x01
+	esdkType               byte   = 0x80
+	esdkAlgorithmAES256GCM uint16 = 0x0178
+	esdkContentFramed      byte   = 0x02
+	esdkIVLength                  = 12
+	esdkTagLength                 = 16
+	esdkMessageIDLength           = 16
+	esdkFrameLength        uint32 = 4096
+)
+
+// ErrProviderNotESDKCompatible raised if the provider does not implement ESDKKeyProvider
+var ErrProviderNotESDKCompatible = errors.New("provider cannot describe its keys as ESDK encrypted data keys - it must implement ESDKKeyProvider")
+
+// ErrInvalidESDKHeader raised if data cannot be parsed as an ESDK message header
+var ErrInvalidESDKHeader = errors.New("invalid ESDK message header")
+
+// ErrESDKHeaderAuthenticationFailed raised if the ESDK message header is not authenticated by the data key
+var ErrESDKHeaderAuthenticationFailed = errors.New("ESDK message header authentication failed")
+
+// ExportESDKHeader returns the wrapped data encryption key of the packed item as an AWS Encryption SDK
+// message header, using the AES-256-GCM HKDF-SHA256 algorithm suite, so that ESDK clients and KMS-centric
+// tooling can unwrap the key.  The provider must implement ESDKKeyProvider, and is used both to decrypt the
+// data encryption key, which is needed to authenticate the header, and to describe its encrypted forms.
+// An envelope split across continuation elements must first be joined (see JoinEnvelope).
+func ExportESDKHeader[T comparable](ctx context.Context, data []byte, provider EnvelopeKeyProvider, idRetriever GetIDSerialiser[T], encryptionContext map[string]string) ([]byte, error) {
+
+	if len(data) == 0 {
+		return nil, ErrUnpackNoData
+	}
+	if provider == nil {
+		return nil, ErrProviderIsNil
+	}
+	if idRetriever == nil {
+		return nil, ErrIDRetrieverIsNil
+	}
+	esdkProvider, ok := provider.(ESDKKeyProvider)
+	if !ok {
+		return nil, ErrProviderNotESDKCompatible
+	}
+
+	env, err := openEnvelope(ctx, data, provider, idRetriever)
+	if err != nil {
+		return nil, err
+	}
+
+	edks, err := esdkProvider.ESDKDataKeys(env.encryptedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	messageID := make([]byte, esdkMessageIDLength)
+	if _, err := rand.Read(messageID); err != nil {
+		return nil, err
+	}
+
+	h := &ESDKHeader{
+		AlgorithmID:       esdkAlgorithmAES256GCM,
+		MessageID:         messageID,
+		EncryptionContext: encryptionContext,
+		EncryptedDataKeys: edks,
+		FrameLength:       esdkFrameLength,
+		IV:                make([]byte, esdkIVLength),
+	}
+
+	h.body, err = h.marshalBody()
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := h.aead(env.encKey)
+	if err != nil {
+		return nil, err
+	}
+	h.AuthTag = aead.Seal(nil, h.IV, nil, h.body)
+
+	return append(append(bytes.Clone(h.body), h.IV...), h.AuthTag...), nil
+}
+
+// ParseESDKHeader parses an AWS Encryption SDK message header, as returned by ExportESDKHeader.
+// The header is not authenticated until Verify is called with the data key.
+func ParseESDKHeader(b []byte) (*ESDKHeader, error) {
+
+	r := &esdkReader{b: b}
+
+	if r.uint8() != esdkVersion || r.uint8() != esdkType {
+		return nil, ErrInvalidESDKHeader
+	}
+
+	h := &ESDKHeader{
+		AlgorithmID: r.uint16(),
+		MessageID:   r.bytes(esdkMessageIDLength),
+	}
+	if h.AlgorithmID != esdkAlgorithmAES256GCM {
+		return nil, ErrInvalidESDKHeader
+	}
+
+	aad := &esdkReader{b: r.bytes(int(r.uint16()))}
+	if len(aad.b) > 0 {
+		h.EncryptionContext = map[string]string{}
+		for range aad.uint16() {
+			k := string(aad.bytes(int(aad.uint16())))
+			h.EncryptionContext[k] = string(aad.bytes(int(aad.uint16())))
+		}
+		if aad.err || len(aad.b) != aad.pos {
+			return nil, ErrInvalidESDKHeader
+		}
+	}
+
+	for range r.uint16() {
+		h.EncryptedDataKeys = append(h.EncryptedDataKeys, ESDKEncryptedDataKey{
+			ProviderID:   string(r.bytes(int(r.uint16()))),
+			ProviderInfo: r.bytes(int(r.uint16())),
+			Ciphertext:   r.bytes(int(r.uint16())),
+		})
+	}
+
+	if r.uint8() != esdkContentFramed {
+		return nil, ErrInvalidESDKHeader
+	}
+	if !bytes.Equal(r.bytes(4), []byte{0, 0, 0, 0}) || r.uint8() != esdkIVLength {
+		return nil, ErrInvalidESDKHeader
+	}
+	h.FrameLength = r.uint32()
+
+	bodyLen := r.pos
+	h.IV = r.bytes(esdkIVLength)
+	h.AuthTag = r.bytes(esdkTagLength)
+
+	if r.err || r.pos != len(b) {
+		return nil, ErrInvalidESDKHeader
+	}
+
+	h.body = bytes.Clone(b[:bodyLen])
+	return h, nil
+}
+
+// Verify confirms that the header is authenticated by the data key, returning ErrESDKHeaderAuthenticationFailed if not
+func (h *ESDKHeader) Verify(dataKey []byte) error {
+	aead, err := h.aead(dataKey)
+	if err != nil {
+		return err
+	}
+	if _, err := aead.Open(nil, h.IV, h.AuthTag, h.body); err != nil {
+		return ErrESDKHeaderAuthenticationFailed
+	}
+	return nil
+}
+
+// aead returns the cipher for header authentication, using the key derived from the data key for the message
+func (h *ESDKHeader) aead(dataKey []byte) (cipher.AEAD, error) {
+
+	info := binary.BigEndian.AppendUint16(nil, h.AlgorithmID)
+	info = append(info, h.MessageID...)
+
+	block, err := aes.NewCipher(hkdfSHA256(dataKey, nil, info, 32))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// marshalBody serialises the header, excluding its authentication
+func (h *ESDKHeader) marshalBody() ([]byte, error) {
+
+	w := &esdkWriter{}
+
+	w.b = append(w.b, esdkVersion, esdkType)
+	w.b = binary.BigEndian.AppendUint16(w.b, h.AlgorithmID)
+	w.b = append(w.b, h.MessageID...)
+
+	// The encryption context is serialised in key order, and omitted entirely if empty
+	aad := &esdkWriter{}
+	if len(h.EncryptionContext) > 0 {
+		keys := make([]string, 0, len(h.EncryptionContext))
+		for k := range h.EncryptionContext {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		aad.uint16(len(keys))
+		for _, k := range keys {
+			aad.field([]byte(k))
+			aad.field([]byte(h.EncryptionContext[k]))
+		}
+	}
+	w.field(aad.b)
+
+	w.uint16(len(h.EncryptedDataKeys))
+	for _, edk := range h.EncryptedDataKeys {
+		w.field([]byte(edk.ProviderID))
+		w.field(edk.ProviderInfo)
+		w.field(edk.Ciphertext)
+	}
+
+	w.b = append(w.b, esdkContentFramed, 0, 0, 0, 0, esdkIVLength)
+	w.b = binary.BigEndian.AppendUint32(w.b, h.FrameLength)
+
+	if w.err {
+		return nil, ErrInvalidESDKHeader
+	}
+	return w.b, nil
+}
+
+// esdkWriter serialises fields prefixed by their two byte length, recording any that are too long
+type esdkWriter struct {
+	b   []byte
+	err bool
+}
+
+func (w *esdkWriter) uint16(n int) {
+	if n > math.MaxUint16 {
+		w.err = true
+	}
+	w.b = binary.BigEndian.AppendUint16(w.b, uint16(n))
+}
+
+func (w *esdkWriter) field(b []byte) {
+	w.uint16(len(b))
+	w.b = append(w.b, b...)
+}
+
+// esdkReader deserialises fields, recording any attempt to read beyond the data
+type esdkReader struct {
+	b   []byte
+	pos int
+	err bool
+}
+
+func (r *esdkReader) bytes(n int) []byte {
+	if r.err || r.pos+n > len(r.b) {
+		r.err = true
+		return nil
+	}
+	b := bytes.Clone(r.b[r.pos : r.pos+n])
+	r.pos += n
+	return b
+}
+
+func (r *esdkReader) uint8() uint8 {
+	b := r.bytes(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (r *esdkReader) uint16() uint16 {
+	b := r.bytes(2)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint16(b)
+}
+
+func (r *esdkReader) uint32() uint32 {
+	b := r.bytes(4)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
@@ -0,0 +1,87 @@
+package packer
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/gford1000-go/serialise"
+)
+
+// testESDKProvider describes its encrypted keys as ESDK encrypted data keys
+type testESDKProvider struct {
+	EnvelopeKeyProvider
+}
+
+func (p *testESDKProvider) ESDKDataKeys(encryptedKey []byte) ([]ESDKEncryptedDataKey, error) {
+	return []ESDKEncryptedDataKey{{ProviderID: "packer", ProviderInfo: []byte(p.ID()), Ciphertext: encryptedKey}}, nil
+}
+
+func TestExportESDKHeader(t *testing.T) {
+
+	_, _, inner := testCreateEnv(t)
+	provider := &testESDKProvider{inner}
+
+	serialiser, err := NewKeySerialiser()
+	if err != nil {
+		t.Fatalf("Unexpected error preparing Key serialiser: %v", err)
+	}
+	idRetriever := func(string) (IDSerialiser[Key], error) { return serialiser, nil }
+
+	info, _, err := Pack(&Item[Key]{Key: Key{X: "A", Y: "B"}, Attributes: map[string]any{"aaa": "Hello World"}}, &PackParams[Key]{
+		Provider: provider,
+		Creator:  NewKeyCreator(defaultLen),
+		Packer:   serialiser,
+		Approach: serialise.NewMinDataApproachWithVersion(serialise.V1),
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+
+	if _, err := ExportESDKHeader(context.TODO(), info, inner, idRetriever, nil); !errors.Is(err, ErrProviderNotESDKCompatible) {
+		t.Fatalf("Unexpected error: expected: %v, got: %v", ErrProviderNotESDKCompatible, err)
+	}
+
+	ec := map[string]string{"purpose": "test", "aws-crypto-public-key": "none"}
+
+	b, err := ExportESDKHeader(context.TODO(), info, provider, idRetriever, ec)
+	if err != nil {
+		t.Fatalf("Unexpected error exporting header: %v", err)
+	}
+
+	h, err := ParseESDKHeader(b)
+	if err != nil {
+		t.Fatalf("Unexpected error parsing header: %v", err)
+	}
+	if h.AlgorithmID != 0x0178 || len(h.MessageID) != 16 || h.FrameLength != 4096 {
+		t.Fatalf("Unexpected header: %+v", h)
+	}
+	if len(h.EncryptionContext) != 2 || h.EncryptionContext["purpose"] != "test" {
+		t.Fatalf("Unexpected encryption context: %v", h.EncryptionContext)
+	}
+	if len(h.EncryptedDataKeys) != 1 || h.EncryptedDataKeys[0].ProviderID != "packer" {
+		t.Fatalf("Unexpected encrypted data keys: %v", h.EncryptedDataKeys)
+	}
+
+	// An ESDK client unwraps the data key using its master key provider, then authenticates the header
+	dataKey, err := inner.Decrypt(context.TODO(), h.EncryptedDataKeys[0].Ciphertext)
+	if err != nil {
+		t.Fatalf("Unexpected error decrypting data key: %v", err)
+	}
+	if err := h.Verify(dataKey); err != nil {
+		t.Fatalf("Unexpected error verifying header: %v", err)
+	}
+
+	b[len(b)-30] ^= 1
+	h, err = ParseESDKHeader(b)
+	if err != nil {
+		t.Fatalf("Unexpected error parsing header: %v", err)
+	}
+	if err := h.Verify(dataKey); !errors.Is(err, ErrESDKHeaderAuthenticationFailed) {
+		t.Fatalf("Unexpected error: expected: %v, got: %v", ErrESDKHeaderAuthenticationFailed, err)
+	}
+
+	if _, err := ParseESDKHeader(b[:len(b)-1]); !errors.Is(err, ErrInvalidESDKHeader) {
+		t.Fatalf("Unexpected error: expected: %v, got: %v", ErrInvalidESDKHeader, err)
+	}
+}
@@ -0,0 +1,137 @@
+package packer
+
+import (
+	"context"
+	"crypto/aes"
+
+	"github.com/gford1000-go/serialise"
+)
+
+// PackEstimate describes the expected result of packing an item
+type PackEstimate struct {
+	// InfoSize is the expected size of the packed data returned by Pack, excluding the encrypted data encryption
+	// key vended by the Provider, whose size depends on the Provider
+	InfoSize int
+	// Continued is true if the packed data would exceed the maximum size, so be stored in continuation elements
+	Continued bool
+	// Elements is the number of elements the attribute values are packed into, including any parity and replica elements
+	Elements int
+	// ElementSizes is the expected size of each element, as the total size of its chunk names and values
+	ElementSizes []int
+	// Chunks is the number of chunks holding the value of each attribute
+	Chunks map[string]int
+}
+
+// EstimatePackedSize returns the expected sizes of the data that Pack would return for the item, so that items can be
+// checked against the limits of a store (such as the 400KB item limit of DynamoDB) before they are written.
+// The item is serialised, compressed and arranged into elements as Pack, but no data encryption key is requested from the
+// Provider and attribute values are not encrypted; instead, space is reserved for the output of the selected cipher.
+// The estimate is exact for the attribute values; the envelope may differ by a few bytes, as some of its details are only
+// determined during encryption.
+func EstimatePackedSize[T comparable](item *Item[T], params *PackParams[T], opts ...func(*Options)) (PackEstimate, error) {
+
+	if item == nil || len(item.Attributes) == 0 {
+		return PackEstimate{}, ErrPackNoAttributes
+	}
+	if params == nil {
+		return PackEstimate{}, ErrPackNoParams
+	}
+	if err := params.validate(); err != nil {
+		return PackEstimate{}, err
+	}
+
+	o, err := newOptions(opts)
+	if err != nil {
+		return PackEstimate{}, err
+	}
+	o.metrics = metricsOrDefault(nil)
+	o.logger = loggerOrDefault(o.logger)
+
+	// Estimation has no side effects, so a signature of fixed size is reserved without calling the Signer
+	o.quota, o.checkpoint, o.progress = nil, nil, nil
+	if o.signer != nil {
+		o.signer = &sizingSigner{id: o.signer.ID()}
+	}
+
+	item, err = prepareItem(item, o)
+	if err != nil {
+		return PackEstimate{}, err
+	}
+
+	o.serialiseOptions = append(o.serialiseOptions, serialise.WithSerialisationApproach(params.Approach))
+
+	if !o.packingVersion.supported() {
+		return PackEstimate{}, ErrUnsupportedPackVersion
+	}
+
+	estimate := PackEstimate{Chunks: map[string]int{}}
+	d := &itemPackingDetailsV1[T]{
+		params:   params,
+		opts:     o,
+		progress: newProgressTracker(OperationPack, nil),
+		estimate: &estimate,
+		version:  o.packingVersion,
+	}
+
+	// The key only determines the size of the cipher output, which is measured rather than used
+	b, output, err := d.pack(context.Background(), item, nil, make([]byte, 2*aes.BlockSize))
+	if err != nil {
+		return PackEstimate{}, err
+	}
+
+	b, err = joinPackingVersion(o.packingVersion, b)
+	if err != nil {
+		return PackEstimate{}, err
+	}
+
+	estimate.InfoSize = len(b)
+	estimate.Continued = uint64(len(b)) > o.maxSize
+	estimate.Elements = len(output)
+	for _, m := range output {
+		estimate.ElementSizes = append(estimate.ElementSizes, elementSize(m))
+	}
+
+	return estimate, nil
+}
+
+// recordChunks records the number of chunks of each attribute
+func (p *PackEstimate) recordChunks(attrMap map[string][]string) {
+	for k, v := range attrMap {
+		p.Chunks[k] = len(v)
+	}
+}
+
+// sizingOption returns a serialisation option that reserves the space added by the encryption of the option,
+// without encrypting
+func sizingOption(encryption func(*serialise.Options)) (func(*serialise.Options), error) {
+	o := serialise.Options{}
+	encryption(&o)
+	b, err := o.Encryptor([]byte{})
+	if err != nil {
+		return nil, err
+	}
+	overhead := len(b)
+	return func(o *serialise.Options) {
+		o.Encryptor = func(plaintext []byte) ([]byte, error) {
+			return make([]byte, len(plaintext)+overhead), nil
+		}
+	}, nil
+}
+
+// padElements increases the size of each chunk by the overhead, as would its encryption
+func padElements[T comparable](output map[T]map[string][]byte, overhead int) {
+	for _, m := range output {
+		for k, v := range m {
+			m[k] = make([]byte, len(v)+overhead)
+		}
+	}
+}
+
+// elementSize returns the total size of the chunk names and values of an element
+func elementSize(m map[string][]byte) int {
+	n := 0
+	for k, v := range m {
+		n += len(k) + len(v)
+	}
+	return n
+}
@@ -0,0 +1,74 @@
+package packer
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/gford1000-go/serialise"
+)
+
+func TestEstimatePackedSize(t *testing.T) {
+
+	_, _, provider := testCreateEnv(t)
+	serialiser, _ := NewKeySerialiser()
+
+	item := &Item[Key]{
+		Key:        Key{X: "A", Y: "B"},
+		Attributes: map[string]any{"small": "Hello World"},
+	}
+	for i := range 3 {
+		b := make([]byte, 30*1024)
+		if _, err := rand.Read(b); err != nil {
+			t.Fatalf("Unexpected error creating data: %v", err)
+		}
+		item.Attributes[fmt.Sprintf("large%d", i)] = b
+	}
+
+	for _, opts := range [][]func(*Options){
+		{WithMaximumKBSize(40), WithAttributeValueMaximumKBSize(16)},
+		{WithMaximumKBSize(40), WithAttributeValueMaximumKBSize(16), WithCipherAlgorithm(AES256CTRHMACSHA256), WithElementKeys()},
+	} {
+		params := &PackParams[Key]{
+			Provider: provider,
+			Creator:  NewKeyCreator(defaultLen),
+			Packer:   serialiser,
+			Approach: serialise.NewMinDataApproachWithVersion(serialise.V1),
+		}
+
+		estimate, err := EstimatePackedSize(item, params, opts...)
+		if err != nil {
+			t.Fatalf("Unexpected error estimating: %v", err)
+		}
+
+		info, data, err := Pack(item, params, opts...)
+		if err != nil {
+			t.Fatalf("Unexpected error packing: %v", err)
+		}
+
+		if estimate.Elements != len(data) {
+			t.Fatalf("Expected %d elements, estimated %d", len(data), estimate.Elements)
+		}
+		sizes := []int{}
+		for _, m := range data {
+			sizes = append(sizes, elementSize(m))
+		}
+		slices.Sort(sizes)
+		slices.Sort(estimate.ElementSizes)
+		if !slices.Equal(sizes, estimate.ElementSizes) {
+			t.Fatalf("Expected element sizes %v, estimated %v", sizes, estimate.ElementSizes)
+		}
+		if estimate.Chunks["small"] != 1 || estimate.Chunks["large0"] != 2 {
+			t.Fatalf("Unexpected chunks: %v", estimate.Chunks)
+		}
+		if estimate.InfoSize == 0 || estimate.InfoSize > len(info) || estimate.Continued {
+			t.Fatalf("Unexpected info size: estimated %d, packed %d", estimate.InfoSize, len(info))
+		}
+	}
+
+	if _, err := EstimatePackedSize(&Item[Key]{}, &PackParams[Key]{}); !errors.Is(err, ErrPackNoAttributes) {
+		t.Fatalf("Expected ErrPackNoAttributes, got: %v", err)
+	}
+}
@@ -0,0 +1,223 @@
+// Package fsstore stores packed items as files within a directory, for use by command line tooling and
+// for archiving packed items where no other storage is available.  Each element is held in its own file,
+// written using packer.EncodeElement, and the packed data of each item in a file with the suffix InfoSuffix.
+package fsstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/gford1000-go/packer"
+)
+
+// FileNamer returns the name of the file holding the element or packed data with the key.  The name
+// must be a valid file name, without any path separators.
+type FileNamer[T comparable] func(key T) (string, error)
+
+// InfoSuffix is appended to the name of the file holding the packed data of an item, which would
+// otherwise share the name of its first element
+const InfoSuffix = ".info"
+
+// KeyFileNamer returns a FileNamer naming files by the hexadecimal encoding of the key, as serialised by
+// the IDSerialiser, so that the key of each file can be recovered from its name
+func KeyFileNamer[T comparable](serialiser packer.IDSerialiser[T]) FileNamer[T] {
+	return func(key T) (string, error) {
+		b, err := serialiser.Pack(key)
+		if err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(b), nil
+	}
+}
+
+// HashedFileNamer returns a FileNamer naming files by the SHA-256 hash of the key, as serialised by the
+// IDSerialiser, so that names have the same length regardless of the size of the key
+func HashedFileNamer[T comparable](serialiser packer.IDSerialiser[T]) FileNamer[T] {
+	return func(key T) (string, error) {
+		b, err := serialiser.Pack(key)
+		if err != nil {
+			return "", err
+		}
+		h := sha256.Sum256(b)
+		return hex.EncodeToString(h[:]), nil
+	}
+}
+
+// ErrDirectoryIsEmpty raised if New is called without a directory
+var ErrDirectoryIsEmpty = errors.New("directory must be specified")
+
+// ErrFileNamerIsNil raised if New is called without a FileNamer
+var ErrFileNamerIsNil = errors.New("file namer must not be nil")
+
+// ErrInvalidFileName raised if the FileNamer returns a name that is empty or includes a path separator
+var ErrInvalidFileName = errors.New("file name must not be empty, or include path separators")
+
+// Store stores packed items as files within a directory, and implements packer.Store
+type Store[T comparable] struct {
+	dir   string
+	namer FileNamer[T]
+}
+
+var _ packer.Store[string] = &Store[string]{}
+
+// New returns a Store holding files in the directory, which is created if it does not exist
+func New[T comparable](dir string, namer FileNamer[T]) (*Store[T], error) {
+	if len(dir) == 0 {
+		return nil, ErrDirectoryIsEmpty
+	}
+	if namer == nil {
+		return nil, ErrFileNamerIsNil
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &Store[T]{dir: dir, namer: namer}, nil
+}
+
+// Path returns the path of the file holding the element with the key
+func (s *Store[T]) Path(key T) (string, error) {
+	name, err := s.namer(key)
+	if err != nil {
+		return "", err
+	}
+	if len(name) == 0 || filepath.Base(name) != name || name == "." || name == ".." {
+		return "", ErrInvalidFileName
+	}
+	return filepath.Join(s.dir, name), nil
+}
+
+// Load is a packer.DataLoader, returning the chunks of the elements with the keys whose files exist
+func (s *Store[T]) Load(ctx context.Context, keys []T) (map[string][]byte, error) {
+	m := map[string][]byte{}
+	for _, k := range keys {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		p, err := s.Path(k)
+		if err != nil {
+			return nil, err
+		}
+		b, err := os.ReadFile(p)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		chunks, err := packer.DecodeElement(b)
+		if err != nil {
+			return nil, err
+		}
+		for name, v := range chunks {
+			m[name] = v
+		}
+	}
+	return m, nil
+}
+
+// Write stores the output of Pack: the elements, and then the packed data of the item with the key,
+// so that the directory never holds packed data whose elements are missing
+func (s *Store[T]) Write(ctx context.Context, key T, info []byte, data map[T]map[string][]byte) error {
+	if err := s.PutAttrs(ctx, data); err != nil {
+		return err
+	}
+	return s.PutInfo(ctx, key, info)
+}
+
+// PutInfo stores the packed data of the item with the key
+func (s *Store[T]) PutInfo(ctx context.Context, key T, info []byte) error {
+	p, err := s.Path(key)
+	if err != nil {
+		return err
+	}
+	return s.writeFile(p+InfoSuffix, info)
+}
+
+// PutAttrs stores each element in its own file, encoded using packer.EncodeElement
+func (s *Store[T]) PutAttrs(ctx context.Context, data map[T]map[string][]byte) error {
+	for k, chunks := range data {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		p, err := s.Path(k)
+		if err != nil {
+			return err
+		}
+		b, err := packer.EncodeElement(chunks)
+		if err != nil {
+			return err
+		}
+		if err := s.writeFile(p, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetInfo returns the packed data of the item with the key, raising packer.ErrItemNotInStore if not present
+func (s *Store[T]) GetInfo(ctx context.Context, key T) ([]byte, error) {
+	p, err := s.Path(key)
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(p + InfoSuffix)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, packer.ErrItemNotInStore
+	}
+	return b, err
+}
+
+// BatchGetAttrs is equivalent to Load
+func (s *Store[T]) BatchGetAttrs(ctx context.Context, keys []T) (map[string][]byte, error) {
+	return s.Load(ctx, keys)
+}
+
+// Delete removes the packed data of the item with the key, and the files of the elements with the element
+// keys (see packer.GetElementKeys).  Files that do not exist are ignored.
+func (s *Store[T]) Delete(ctx context.Context, key T, elementKeys ...T) error {
+	p, err := s.Path(key)
+	if err != nil {
+		return err
+	}
+	paths := []string{p + InfoSuffix}
+	for _, k := range elementKeys {
+		if p, err = s.Path(k); err != nil {
+			return err
+		}
+		paths = append(paths, p)
+	}
+	for _, p := range paths {
+		if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeFile writes the data to a temporary file that is renamed once complete, so that a file is never
+// observed partially written
+func (s *Store[T]) writeFile(path string, data []byte) error {
+	f, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,113 @@
+package packer
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// ErrEncryptedItemIsNil raised if a nil EncryptedItem is passed to GetValuesMany
+var ErrEncryptedItemIsNil = errors.New("encrypted item must not be nil")
+
+// WithDecryptConcurrency limits the number of attributes decrypted concurrently by GetValuesMany, GetAllValues
+// and GetValuesStream.  If not set, GOMAXPROCS is used.
+func WithDecryptConcurrency(n int) func(*GetValuesOptions) {
+	return func(o *GetValuesOptions) {
+		o.concurrency = n
+	}
+}
+
+// GetValuesMany decrypts the same attributes from each of the items, returning a map of values for each
+// item in the same order as the items.  Data encryption keys shared between items are decrypted once
+// by the provider, and the attributes of all items are decrypted using a shared pool of workers (see
+// WithDecryptConcurrency), making this suitable for decrypting a few fields of many items at once.
+// Attributes that are not included in an item are ignored, as for GetValues.
+func GetValuesMany[T comparable](ctx context.Context, items []*EncryptedItem[T], attrs []string, provider EnvelopeKeyProvider, opts ...func(*GetValuesOptions)) ([]map[string]any, error) {
+
+	if provider == nil {
+		return nil, ErrProviderIsNil
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	o := newGetValuesOptions(opts)
+
+	// Unwrap each distinct data encryption key once
+	keys := map[string][]byte{}
+	for _, e := range items {
+		if e == nil {
+			return nil, ErrEncryptedItemIsNil
+		}
+		if err := e.acquire(ctx, attrs); err != nil {
+			return nil, err
+		}
+		if _, ok := keys[string(e.encryptedKey)]; ok {
+			continue
+		}
+		key, err := e.cachedDecryptKey(ctx, provider)
+		if err != nil {
+			metricsOrDefault(e.metrics).Add(MetricDecryptErrors, 1)
+			return nil, err
+		}
+		keys[string(e.encryptedKey)] = key
+	}
+
+	type task struct {
+		item int
+		attr string
+	}
+
+	// Priority attributes of every item are started before the remainder
+	tasks := []task{}
+	for _, phase := range prioritise(attrs, o.priority) {
+		for i := range items {
+			for _, attr := range phase {
+				tasks = append(tasks, task{item: i, attr: attr})
+			}
+		}
+	}
+
+	out := make([]map[string]any, len(items))
+	for i := range out {
+		out[i] = map[string]any{}
+	}
+
+	limit := o.concurrency
+	if limit < 1 {
+		limit = runtime.GOMAXPROCS(0)
+	}
+
+	var mu sync.Mutex
+
+	err := runConcurrently(len(tasks), limit, func(i int) error {
+		e := items[tasks[i].item]
+
+		v, found, err := e.getValue(ctx, tasks[i].attr, keys[string(e.encryptedKey)])
+		if err != nil {
+			if ctx.Err() == nil {
+				metricsOrDefault(e.metrics).Add(MetricDecryptErrors, 1)
+			}
+			return err
+		}
+		if found && v != nil {
+			mu.Lock()
+			out[tasks[i].item][tasks[i].attr] = v
+			mu.Unlock()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range items {
+		metricsOrDefault(e.metrics).Observe(MetricGetValuesDuration, time.Since(start).Seconds())
+	}
+
+	return out, nil
+}
@@ -0,0 +1,85 @@
+package packer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+)
+
+// countingProvider counts the calls to Decrypt
+type countingProvider struct {
+	EnvelopeKeyProvider
+	n atomic.Int32
+}
+
+func (c *countingProvider) Decrypt(ctx context.Context, encryptedKey []byte) ([]byte, error) {
+	c.n.Add(1)
+	return c.EnvelopeKeyProvider.Decrypt(ctx, encryptedKey)
+}
+
+func TestGetValuesMany(t *testing.T) {
+
+	packer, unpacker, provider := testCreateEnv(t)
+
+	items := []*EncryptedItem[Key]{}
+	for i := range 10 {
+		item := &Item[Key]{
+			Key: Key{X: fmt.Sprintf("%d", i), Y: "B"},
+			Attributes: map[string]any{
+				"title": fmt.Sprintf("Title %d", i),
+				"body":  fmt.Sprintf("Body %d", i),
+			},
+		}
+		if i%2 == 0 {
+			item.Attributes["extra"] = int64(i)
+		}
+
+		b, l, err := packer(item)
+		if err != nil {
+			t.Fatalf("Unexpected error packing: %v", err)
+		}
+		e, err := unpacker(b, l)
+		if err != nil {
+			t.Fatalf("Unexpected error unpacking: %v", err)
+		}
+		items = append(items, e)
+	}
+
+	// Items sharing a data encryption key only require it to be decrypted once
+	items = append(items, items[0])
+
+	counter := &countingProvider{EnvelopeKeyProvider: provider}
+
+	out, err := GetValuesMany(context.TODO(), items, []string{"title", "extra"}, counter, WithDecryptConcurrency(4), WithPriority("title"))
+	if err != nil {
+		t.Fatalf("Unexpected error getting values: %v", err)
+	}
+	if n := counter.n.Load(); n != 10 {
+		t.Fatalf("Unexpected number of key decryptions: expected: 10, got: %d", n)
+	}
+	if len(out) != len(items) {
+		t.Fatalf("Unexpected number of results: %d", len(out))
+	}
+
+	for i, m := range out {
+		j := i % 10
+		if m["title"] != fmt.Sprintf("Title %d", j) {
+			t.Fatalf("(%d) Unexpected title: %v", i, m["title"])
+		}
+		if _, ok := m["body"]; ok {
+			t.Fatalf("(%d) Unexpected attribute returned: %v", i, m)
+		}
+		if j%2 == 0 && m["extra"] != int64(j) {
+			t.Fatalf("(%d) Unexpected extra: %v", i, m["extra"])
+		}
+		if j%2 != 0 && len(m) != 1 {
+			t.Fatalf("(%d) Unexpected values: %v", i, m)
+		}
+	}
+
+	if _, err := GetValuesMany(context.TODO(), []*EncryptedItem[Key]{nil}, []string{"title"}, provider); !errors.Is(err, ErrEncryptedItemIsNil) {
+		t.Fatalf("Unexpected error: expected: %v, got: %v", ErrEncryptedItemIsNil, err)
+	}
+}
@@ -0,0 +1,55 @@
+package packer
+
+// GetValuesOptions adjust the behaviour of GetValues, GetValuesStream and GetValuesMany
+type GetValuesOptions struct {
+	// Attributes decrypted before all others
+	priority []string
+	// Maximum number of attributes decrypted concurrently, if supported
+	concurrency int
+}
+
+// WithPriority marks the attributes as high priority, so that they are decrypted (and, for
+// GetValuesStream, emitted) before any other attributes in the request are started
+func WithPriority(attrs ...string) func(*GetValuesOptions) {
+	return func(o *GetValuesOptions) {
+		o.priority = append(o.priority, attrs...)
+	}
+}
+
+func newGetValuesOptions(opts []func(*GetValuesOptions)) *GetValuesOptions {
+	o := &GetValuesOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// prioritise splits the requested attributes into the phases in which they are decrypted:
+// the requested high priority attributes first, followed by the remainder
+func prioritise(attrs []string, priority []string) [][]string {
+	if len(priority) == 0 {
+		return [][]string{attrs}
+	}
+
+	high := make(map[string]bool, len(priority))
+	for _, p := range priority {
+		high[p] = true
+	}
+
+	var first, rest []string
+	for _, attr := range attrs {
+		if high[attr] {
+			first = append(first, attr)
+		} else {
+			rest = append(rest, attr)
+		}
+	}
+
+	phases := [][]string{}
+	for _, phase := range [][]string{first, rest} {
+		if len(phase) > 0 {
+			phases = append(phases, phase)
+		}
+	}
+	return phases
+}
@@ -0,0 +1,82 @@
+package packer
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestPrioritise(t *testing.T) {
+
+	tests := []struct {
+		attrs    []string
+		priority []string
+		expected [][]string
+	}{
+		{attrs: []string{"a", "b"}, expected: [][]string{{"a", "b"}}},
+		{attrs: []string{"a", "b", "c"}, priority: []string{"c"}, expected: [][]string{{"c"}, {"a", "b"}}},
+		{attrs: []string{"a", "b"}, priority: []string{"a", "b"}, expected: [][]string{{"a", "b"}}},
+		{attrs: []string{"a", "b"}, priority: []string{"x"}, expected: [][]string{{"a", "b"}}},
+	}
+
+	for i, test := range tests {
+		phases := prioritise(test.attrs, test.priority)
+		if !slices.EqualFunc(phases, test.expected, slices.Equal) {
+			t.Fatalf("(%d) Unexpected phases: expected: %v, got: %v", i, test.expected, phases)
+		}
+	}
+}
+
+func TestWithPriority(t *testing.T) {
+
+	packer, unpacker, provider := testCreateEnv(t)
+
+	item := &Item[Key]{
+		Key:        Key{X: "A", Y: "B"},
+		Attributes: map[string]any{},
+	}
+	attrs := []string{}
+	for i := range 20 {
+		name := fmt.Sprintf("body%d", i)
+		item.Attributes[name] = name
+		attrs = append(attrs, name)
+	}
+	item.Attributes["title"] = "Title"
+	attrs = append(attrs, "title")
+
+	b, l, err := packer(item)
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+
+	e, err := unpacker(b, l)
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+
+	c, err := e.GetValuesStream(context.TODO(), attrs, provider, WithPriority("title"))
+	if err != nil {
+		t.Fatalf("Unexpected error getting values: %v", err)
+	}
+
+	first := <-c
+	if first.Attribute != "title" || first.Value != "Title" {
+		t.Fatalf("Unexpected first result: %+v", first)
+	}
+	n := 1
+	for range c {
+		n++
+	}
+	if n != len(attrs) {
+		t.Fatalf("Unexpected number of results: expected: %d, got: %d", len(attrs), n)
+	}
+
+	m, err := e.GetValues(context.TODO(), attrs, provider, WithPriority("title"))
+	if err != nil {
+		t.Fatalf("Unexpected error getting values: %v", err)
+	}
+	if len(m) != len(attrs) {
+		t.Fatalf("Unexpected values: %v", m)
+	}
+}
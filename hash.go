@@ -0,0 +1,94 @@
+package packer
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"hash"
+)
+
+// HashAlgorithm identifies the hash used by keyed digests and derived names.
+// Where a digest is recorded in packed data, so is its HashAlgorithm.
+type HashAlgorithm uint8
+
+const (
+	// SHA256 is SHA-256, the default
+	SHA256 HashAlgorithm = iota
+	// SHA512_256 is SHA-512/256
+	SHA512_256
+	// BLAKE2b256 is BLAKE2b-256, which is only available if the application imports
+	// golang.org/x/crypto/blake2b, registering it with the crypto package
+	BLAKE2b256
+	hashAlgorithmOutOfRange
+)
+
+var hashAlgorithms = map[HashAlgorithm]crypto.Hash{
+	SHA256:     crypto.SHA256,
+	SHA512_256: crypto.SHA512_256,
+	BLAKE2b256: crypto.BLAKE2b_256,
+}
+
+var hashAlgorithmNames = map[HashAlgorithm]string{
+	SHA256:     "sha256",
+	SHA512_256: "sha512-256",
+	BLAKE2b256: "blake2b-256",
+}
+
+// String returns the name of the HashAlgorithm
+func (h HashAlgorithm) String() string {
+	if n, ok := hashAlgorithmNames[h]; ok {
+		return n
+	}
+	return fmt.Sprintf("HashAlgorithm(%d)", uint8(h))
+}
+
+// ErrUnknownHashAlgorithm raised if an unrecognised HashAlgorithm is requested or found in packed data
+var ErrUnknownHashAlgorithm = errors.New("unknown hash algorithm")
+
+// ErrHashAlgorithmUnavailable raised if a HashAlgorithm is requested whose implementation is not linked
+// into the application
+var ErrHashAlgorithmUnavailable = errors.New("hash algorithm is not available")
+
+// MarshalText allows the HashAlgorithm to be written by name in configuration
+func (h HashAlgorithm) MarshalText() ([]byte, error) {
+	if _, ok := hashAlgorithmNames[h]; !ok {
+		return nil, ErrUnknownHashAlgorithm
+	}
+	return []byte(h.String()), nil
+}
+
+// UnmarshalText allows the HashAlgorithm to be specified by name in configuration
+func (h *HashAlgorithm) UnmarshalText(text []byte) error {
+	for k, v := range hashAlgorithmNames {
+		if v == string(text) {
+			*h = k
+			return nil
+		}
+	}
+	return ErrUnknownHashAlgorithm
+}
+
+// Available returns an error if the HashAlgorithm is unknown, or its implementation is not linked into the application
+func (h HashAlgorithm) Available() error {
+	c, ok := hashAlgorithms[h]
+	if !ok {
+		return ErrUnknownHashAlgorithm
+	}
+	if !c.Available() {
+		return fmt.Errorf("%w: %v", ErrHashAlgorithmUnavailable, h)
+	}
+	return nil
+}
+
+// new returns the constructor of the hash; the standard library implementations are used directly
+func (h HashAlgorithm) new() func() hash.Hash {
+	switch h {
+	case SHA256:
+		return sha256.New
+	case SHA512_256:
+		return sha512.New512_256
+	}
+	return hashAlgorithms[h].New
+}
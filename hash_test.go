@@ -0,0 +1,75 @@
+package packer
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestWithDigestHash(t *testing.T) {
+
+	testPack, _, _ := testCreateEnv(t)
+
+	item := &Item[Key]{
+		Key:        Key{X: "A", Y: "B"},
+		Attributes: map[string]any{"aaa": "Hello", "bbb": int64(42)},
+	}
+	digestKey := []byte("digest key")
+
+	a, _, err := testPack(item, WithDigest(digestKey), WithDigestHash(SHA512_256))
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+	b, _, err := testPack(item, WithDigest(digestKey), WithDigestHash(SHA512_256))
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+	c, _, err := testPack(item, WithDigest(digestKey))
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+
+	if same, err := CompareDigests(a, b); err != nil || !same {
+		t.Fatalf("Expected digests using the same hash to match: %v, %v", same, err)
+	}
+	if same, err := CompareDigests(a, c); err != nil || same {
+		t.Fatalf("Expected digests using different hashes to differ: %v, %v", same, err)
+	}
+	if digest, _ := PackDigest(a); len(digest) != 33 || digest[0] != byte(SHA512_256) {
+		t.Fatalf("Expected the hash to be recorded in the digest: %x", digest)
+	}
+
+	// BLAKE2b is only available if registered by the application
+	if _, _, err := testPack(item, WithDigest(digestKey), WithDigestHash(BLAKE2b256)); !errors.Is(err, ErrHashAlgorithmUnavailable) {
+		t.Fatalf("Expected ErrHashAlgorithmUnavailable, got: %v", err)
+	}
+}
+
+func TestHashAlgorithm_UnmarshalText(t *testing.T) {
+
+	for h := range hashAlgorithmOutOfRange {
+		b, err := h.MarshalText()
+		if err != nil {
+			t.Fatalf("Unexpected error marshalling %v: %v", h, err)
+		}
+		var u HashAlgorithm
+		if err := u.UnmarshalText(b); err != nil || u != h {
+			t.Fatalf("Expected %v, got %v (%v)", h, u, err)
+		}
+	}
+
+	var u HashAlgorithm
+	if err := u.UnmarshalText([]byte("md5")); !errors.Is(err, ErrUnknownHashAlgorithm) {
+		t.Fatalf("Expected ErrUnknownHashAlgorithm, got: %v", err)
+	}
+	if err := hashAlgorithmOutOfRange.Available(); !errors.Is(err, ErrUnknownHashAlgorithm) {
+		t.Fatalf("Expected ErrUnknownHashAlgorithm, got: %v", err)
+	}
+
+	a, _ := NewHMACAttributeNamerWithHash([]byte("secret"), 10, SHA256)
+	b, _ := NewHMACAttributeNamerWithHash([]byte("secret"), 10, SHA512_256)
+	na, _ := a.NewName("attr", 0)
+	nb, _ := b.NewName("attr", 0)
+	if na == nb {
+		t.Fatal("Expected different names from different hashes")
+	}
+}
@@ -0,0 +1,27 @@
+package packer
+
+// TransformHook transforms the value of an attribute, allowing normalisation, tokenisation or unit
+// conversion to be applied uniformly.  The returned value replaces the original; an error causes
+// the operation to fail.  Hooks may be called concurrently, so must be safe for concurrent use.
+type TransformHook func(attr string, v any) (any, error)
+
+// WithPrePackHooks applies the hooks, in the order specified, to each attribute value before it is
+// serialised by Pack.  Use UnpackParams.PostUnpackHooks to apply the reverse transformations when
+// values are retrieved.
+func WithPrePackHooks(hooks ...TransformHook) func(o *Options) {
+	return func(o *Options) {
+		o.prePackHooks = append(o.prePackHooks, hooks...)
+	}
+}
+
+// applyHooks passes the value through each of the hooks in turn
+func applyHooks(hooks []TransformHook, attr string, v any) (any, error) {
+	for _, h := range hooks {
+		var err error
+		v, err = h(attr, v)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
@@ -0,0 +1,105 @@
+package packer
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestWithPrePackHooks(t *testing.T) {
+
+	testPack, testUnpack, provider := testCreateEnv(t)
+
+	item := &Item[Key]{
+		Key: Key{X: "A", Y: "B"},
+		Attributes: map[string]any{
+			"name":  "  Hello World ",
+			"count": int64(3),
+		},
+	}
+
+	trim := func(attr string, v any) (any, error) {
+		if s, ok := v.(string); ok {
+			return strings.TrimSpace(s), nil
+		}
+		return v, nil
+	}
+	upper := func(attr string, v any) (any, error) {
+		if s, ok := v.(string); ok {
+			return strings.ToUpper(s), nil
+		}
+		return v, nil
+	}
+
+	b, l, err := testPack(item, WithPrePackHooks(trim), WithPrePackHooks(upper))
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+
+	e, err := testUnpack(b, l)
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+
+	m, err := e.GetValues(context.TODO(), []string{"name", "count"}, provider)
+	if err != nil {
+		t.Fatalf("Unexpected error getting values: %v", err)
+	}
+	if m["name"].(string) != "HELLO WORLD" || m["count"].(int64) != 3 {
+		t.Fatalf("Unexpected values: %v", m)
+	}
+
+	errHook := errors.New("rejected")
+	reject := func(attr string, v any) (any, error) {
+		if attr == "count" {
+			return nil, errHook
+		}
+		return v, nil
+	}
+	if _, _, err := testPack(item, WithPrePackHooks(reject)); !errors.Is(err, errHook) {
+		t.Fatalf("Unexpected error: expected: %v, got: %v", errHook, err)
+	}
+}
+
+func TestPostUnpackHooks(t *testing.T) {
+
+	testPack, _, provider := testCreateEnv(t)
+
+	item := &Item[Key]{
+		Key: Key{X: "A", Y: "B"},
+		Attributes: map[string]any{
+			"distance": float64(1.5),
+		},
+	}
+
+	b, l, err := testPack(item)
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+
+	kmToMetres := func(attr string, v any) (any, error) {
+		if attr == "distance" {
+			return v.(float64) * 1000, nil
+		}
+		return v, nil
+	}
+
+	e, err := Unpack(context.TODO(), b, &UnpackParams[Key]{
+		IDRetriever:     func(string) (IDSerialiser[Key], error) { return NewKeySerialiser() },
+		Provider:        provider,
+		DataLoader:      l,
+		PostUnpackHooks: []TransformHook{kmToMetres},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+
+	m, err := e.GetValues(context.TODO(), []string{"distance"}, provider)
+	if err != nil {
+		t.Fatalf("Unexpected error getting values: %v", err)
+	}
+	if m["distance"].(float64) != 1500 {
+		t.Fatalf("Unexpected value: %v", m["distance"])
+	}
+}
@@ -1,5 +1,7 @@
 package packer
 
+import "errors"
+
 // IDCreator returns unique instances of T (i.e. when compared)
 type IDCreator[T comparable] interface {
 	// ID returns a unique instance of T
@@ -15,3 +17,33 @@ type IDSerialiser[T comparable] interface {
 	// Unpack recovers an instance of T from a byte slice
 	Unpack(data []byte) (T, error)
 }
+
+// BulkIDCreator is an IDCreator that can also create many instances of T with a single call.  Pack uses IDs
+// whenever more than one new key is required, so that creators backed by remote services can batch requests.
+type BulkIDCreator[T comparable] interface {
+	IDCreator[T]
+	// IDs returns n unique instances of T
+	IDs(n int) []T
+}
+
+// ErrBulkIDCreatorShort raised if a BulkIDCreator returns fewer instances than requested
+var ErrBulkIDCreatorShort = errors.New("bulk id creator returned fewer ids than requested")
+
+// createIDs returns n new instances of T from the creator, with a single call if it is a BulkIDCreator
+func createIDs[T comparable](creator IDCreator[T], n int) ([]T, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	if b, ok := creator.(BulkIDCreator[T]); ok {
+		ids := b.IDs(n)
+		if len(ids) < n {
+			return nil, ErrBulkIDCreatorShort
+		}
+		return ids[:n], nil
+	}
+	ids := make([]T, n)
+	for i := range ids {
+		ids[i] = creator.ID()
+	}
+	return ids, nil
+}
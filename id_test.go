@@ -0,0 +1,72 @@
+package packer
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/gford1000-go/serialise"
+)
+
+// testBulkCreator counts the calls made to create keys
+type testBulkCreator struct {
+	IDCreator[Key]
+	single int
+	bulk   []int
+	short  bool
+}
+
+func (c *testBulkCreator) ID() Key {
+	c.single++
+	return c.IDCreator.ID()
+}
+
+func (c *testBulkCreator) IDs(n int) []Key {
+	c.bulk = append(c.bulk, n)
+	if c.short {
+		n--
+	}
+	ids := make([]Key, n)
+	for i := range ids {
+		ids[i] = c.IDCreator.ID()
+	}
+	return ids
+}
+
+func TestBulkIDCreator(t *testing.T) {
+
+	_, _, provider := testCreateEnv(t)
+	serialiser, _ := NewKeySerialiser()
+
+	item := &Item[Key]{
+		Key:        Key{X: "A", Y: "B"},
+		Attributes: map[string]any{},
+	}
+	for i := range 200 {
+		item.Attributes[fmt.Sprintf("attr%d", i)] = fmt.Sprintf("value%d", i)
+	}
+
+	creator := &testBulkCreator{IDCreator: NewKeyCreator(defaultLen)}
+	params := &PackParams[Key]{
+		Provider: provider,
+		Creator:  creator,
+		Packer:   serialiser,
+		Approach: serialise.NewMinDataApproachWithVersion(serialise.V1),
+	}
+
+	_, data, err := Pack(item, params, WithMaximumKBSize(10), WithReplication(2))
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+	if creator.single != 0 || len(creator.bulk) != 2 {
+		t.Fatalf("Expected keys to be created in bulk, got %d single and %v bulk calls", creator.single, creator.bulk)
+	}
+	if n := creator.bulk[0] + creator.bulk[1] + 1; n != len(data) {
+		t.Fatalf("Expected %d elements, got %d", n, len(data))
+	}
+
+	creator.short = true
+	if _, _, err := Pack(item, params, WithMaximumKBSize(10)); !errors.Is(err, ErrBulkIDCreatorShort) {
+		t.Fatalf("Expected ErrBulkIDCreatorShort, got: %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package packer
+
+import (
+	"context"
+	"errors"
+	"maps"
+
+	"github.com/gford1000-go/serialise"
+)
+
+// WithInline embeds the encrypted chunks of all attribute values within the packed data, so that Pack returns no
+// elements.  This suits small items stored in a single column or object, which can then be unpacked without
+// loading any elements (see NoDataLoader).  Pack fails with ErrEnvelopeTooLarge if the packed data would exceed
+// the maximum size.
+func WithInline() func(o *Options) {
+	return func(o *Options) {
+		o.inline = true
+	}
+}
+
+// NoDataLoader is a DataLoader that loads nothing, for use with items packed using WithInline
+func NoDataLoader[T comparable](ctx context.Context, keys []T) (map[string][]byte, error) {
+	return map[string][]byte{}, nil
+}
+
+// ErrInvalidDataToDeserialiseInline raised if the inline chunks of an envelope cannot be deserialised
+var ErrInvalidDataToDeserialiseInline = errors.New("invalid data, cannot deserialise inline chunks")
+
+// packInline serialises the chunks of all the elements, in name order
+func packInline[T comparable](output map[T]map[string][]byte) ([]byte, error) {
+	chunks := map[string][]byte{}
+	for _, m := range output {
+		maps.Copy(chunks, m)
+	}
+	b, _, err := serialise.ToBytesMany(appendSortedData(nil, chunks), serialise.WithSerialisationApproach(serialise.NewMinDataApproachWithVersion(serialise.V1)))
+	return b, err
+}
+
+// unpackInline is the inverse of packInline
+func unpackInline(data []byte) (map[string][]byte, error) {
+	v, err := serialise.FromBytesMany(data, serialise.NewMinDataApproachWithVersion(serialise.V1))
+	if err != nil {
+		return nil, err
+	}
+	chunks, err := sortedData(v)
+	if err != nil {
+		return nil, ErrInvalidDataToDeserialiseInline
+	}
+	return chunks, nil
+}
+
+// inlineLoader returns a DataLoader serving the inline chunks of the envelope, or the loader if there are none
+func (env *envelopeV1[T]) inlineLoader(loader DataLoader[T]) (DataLoader[T], error) {
+	b, ok := env.ext[extInline]
+	if !ok {
+		return loader, nil
+	}
+	chunks, err := unpackInline(b)
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context, keys []T) (map[string][]byte, error) {
+		// Chunks may be modified once loaded, so each load receives a copy
+		return maps.Clone(chunks), nil
+	}, nil
+}
+
+// isInline returns true if the chunks of the envelope are held within it
+func (env *envelopeV1[T]) isInline() bool {
+	_, ok := env.ext[extInline]
+	return ok
+}
@@ -0,0 +1,67 @@
+package packer
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"maps"
+	"testing"
+)
+
+func TestWithInline(t *testing.T) {
+
+	testPack, testUnpack, provider := testCreateEnv(t)
+
+	item := &Item[Key]{
+		Key:        Key{X: "A", Y: "B"},
+		Attributes: map[string]any{"a": "x", "b": int64(2), "c": 3.5},
+	}
+
+	b, l, err := testPack(item, WithInline())
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+	if m, _ := l(context.TODO(), []Key{item.Key}); len(m) != 0 {
+		t.Fatalf("Expected no elements, got %d chunks", len(m))
+	}
+
+	e, err := testUnpack(b, NoDataLoader[Key])
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+	values, err := e.GetValues(context.TODO(), []string{"a", "b", "c"}, provider)
+	if err != nil {
+		t.Fatalf("Unexpected error getting values: %v", err)
+	}
+	if !maps.Equal(values, item.Attributes) {
+		t.Fatalf("Mismatch: expected %v, got %v", item.Attributes, values)
+	}
+
+	large := make([]byte, 64*1024)
+	if _, err := rand.Read(large); err != nil {
+		t.Fatalf("Unexpected error creating data: %v", err)
+	}
+	if _, _, err := testPack(&Item[Key]{Key: item.Key, Attributes: map[string]any{"a": large}}, WithInline(), WithMaximumKBSize(16)); !errors.Is(err, ErrEnvelopeTooLarge) {
+		t.Fatalf("Expected ErrEnvelopeTooLarge, got: %v", err)
+	}
+}
+
+func TestInlineRoundTrip(t *testing.T) {
+
+	output := map[Key]map[string][]byte{
+		{X: "A"}: {"x": []byte("1"), "y": []byte("22")},
+		{X: "B"}: {"z": []byte("333")},
+	}
+
+	b, err := packInline(output)
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+	chunks, err := unpackInline(b)
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+	if len(chunks) != 3 || string(chunks["y"]) != "22" || string(chunks["z"]) != "333" {
+		t.Fatalf("Unexpected chunks: %v", chunks)
+	}
+}
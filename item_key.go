@@ -1,6 +1,7 @@
 package packer
 
 import (
+	"bytes"
 	"context"
 	"crypto/aes"
 	"crypto/rand"
@@ -20,6 +21,30 @@ type EnvelopeKeyProvider interface {
 	Decrypt(ctx context.Context, encryptedKey []byte) ([]byte, error)
 }
 
+// KeyWrapper is implemented by EnvelopeKeyProviders that can encrypt an existing key, in the same
+// form as returned by New(), allowing packed data to be rewrapped for another provider without
+// re-encrypting attribute values
+type KeyWrapper interface {
+	// Wrap returns the encrypted form of the key, which can be decrypted by the provider's Decrypt
+	Wrap(ctx context.Context, key []byte) ([]byte, error)
+}
+
+// ContextualEnvelopeKeyProvider is implemented by EnvelopeKeyProviders that accept a context when creating keys,
+// so that providers backed by a remote service (such as a KMS) can respect deadlines and receive caller identity.
+// PackWithContext uses NewWithContext in preference to New().
+type ContextualEnvelopeKeyProvider interface {
+	// NewWithContext returns a unique key as New()
+	NewWithContext(ctx context.Context) ([]byte, []byte, error)
+}
+
+// newKey returns a key from the provider, passing the context if the provider accepts one
+func newKey(ctx context.Context, provider EnvelopeKeyProvider) ([]byte, []byte, error) {
+	if p, ok := provider.(ContextualEnvelopeKeyProvider); ok {
+		return p.NewWithContext(ctx)
+	}
+	return provider.New()
+}
+
 // EnvelopeKeyID type distinguishes envelope key identifiers from other strings
 type EnvelopeKeyID string
 
@@ -75,6 +100,7 @@ func NewEnvelopeKeyProvider(keyInfo *EnvelopeKeyProviderInfo, finder EnveloperKe
 	return &evKeyProvider{
 		dec:    o.Decryptor,
 		enc:    o.Encryptor,
+		kek:    bytes.Clone(keyInfo.Key),
 		finder: finder,
 		id:     keyInfo.ID,
 	}, nil
@@ -83,6 +109,7 @@ func NewEnvelopeKeyProvider(keyInfo *EnvelopeKeyProviderInfo, finder EnveloperKe
 type evKeyProvider struct {
 	dec    func([]byte) ([]byte, error)
 	enc    func([]byte) ([]byte, error)
+	kek    []byte
 	finder EnveloperKeyProviderFinder
 	id     EnvelopeKeyID
 }
@@ -99,15 +126,38 @@ func (e *evKeyProvider) New() ([]byte, []byte, error) {
 		return nil, nil, err
 	}
 
-	encryptedKey, err := e.enc(newKey)
+	b, err := e.Wrap(context.Background(), newKey)
 	if err != nil {
 		return nil, nil, err
 	}
 
+	return b, newKey, nil
+}
+
+// NewWithEncryptionContext creates a key as New(), with the encryption context as additional authenticated data
+func (e *evKeyProvider) NewWithEncryptionContext(ec map[string]string) ([]byte, []byte, error) {
+
+	newKey := make([]byte, 2*aes.BlockSize)
+	_, err := rand.Reader.Read(newKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	aead, err := newAESGCM(e.kek)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Reader.Read(nonce); err != nil {
+		return nil, nil, err
+	}
+
+	// The third element indicates that the key is bound to an encryption context
 	b, _, err := serialise.ToBytesMany(
 		[]any{
 			string(e.id),
-			encryptedKey,
+			aead.Seal(nonce, nonce, newKey, canonicalEncryptionContext(ec)),
+			true,
 		}, serialise.WithSerialisationApproach(serialise.NewMinDataApproachWithVersion(serialise.V1)))
 	if err != nil {
 		return nil, nil, err
@@ -116,6 +166,25 @@ func (e *evKeyProvider) New() ([]byte, []byte, error) {
 	return b, newKey, nil
 }
 
+func (e *evKeyProvider) Wrap(ctx context.Context, key []byte) ([]byte, error) {
+
+	encryptedKey, err := e.enc(key)
+	if err != nil {
+		return nil, err
+	}
+
+	b, _, err := serialise.ToBytesMany(
+		[]any{
+			string(e.id),
+			encryptedKey,
+		}, serialise.WithSerialisationApproach(serialise.NewMinDataApproachWithVersion(serialise.V1)))
+	if err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
 // ErrKeyProviderDecryptError raised if the provided encryptedKey data cannot be decrypted correctly
 var ErrKeyProviderDecryptError = errors.New("invalid encrypted key provided - failed to decrypt")
 
@@ -126,7 +195,7 @@ func (e *evKeyProvider) Decrypt(ctx context.Context, encryptedKey []byte) ([]byt
 		return nil, err
 	}
 
-	if len(v) != 2 {
+	if len(v) != 2 && len(v) != 3 {
 		return nil, ErrKeyDeserialisationError
 	}
 
@@ -148,5 +217,20 @@ func (e *evKeyProvider) Decrypt(ctx context.Context, encryptedKey []byte) ([]byt
 		return nil, ErrKeyDeserialisationError
 	}
 
-	return e.dec(key)
+	if len(v) == 2 {
+		return e.dec(key)
+	}
+
+	aead, err := newAESGCM(e.kek)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) < aead.NonceSize() {
+		return nil, ErrKeyDeserialisationError
+	}
+	dek, err := aead.Open(nil, key[:aead.NonceSize()], key[aead.NonceSize():], canonicalEncryptionContext(EncryptionContextFromContext(ctx)))
+	if err != nil {
+		return nil, ErrKeyProviderDecryptError
+	}
+	return dek, nil
 }
@@ -4,18 +4,61 @@ import (
 	"context"
 	c "crypto/rand"
 	"errors"
+	"fmt"
+	"log/slog"
 	"math/big"
+	"slices"
 	"sort"
+	"sync"
+	"time"
 
 	"github.com/gford1000-go/serialise"
 )
 
 type itemPackingDetailsV1[T comparable] struct {
-	params *PackParams[T]
-	opts   *Options
+	// Layout of the envelope, as V2 shares the packing details of V1
+	version PackVersion
+	params  *PackParams[T]
+	opts    *Options
+	// Serialisation option encrypting the packing details
+	envelopeOption func(*serialise.Options)
+	// Serialisation options without encryption, used prior to compression
+	plainSerialiseOptions []func(*serialise.Options)
+	// Serialisation options applying the selected cipher, used for attribute values
+	attrSerialiseOptions []func(*serialise.Options)
+	// Reports progress, if requested
+	progress *progressTracker
+	// Summarises unpacking, if requested
+	stats *OperationStats
+	// Limits the number of attributes accepted during unpacking
+	maxAttributes uint32
+	// Erasure coding layout of the packed elements, if requested
+	erasure *erasureLayout
+	// Replicas of the packed elements, if requested
+	replicas []elementReplicas[T]
+	// Index of the element holding each chunk, if element keys are requested
+	elementKeys map[string]int
+	// Canonical plaintext of each attribute, if a digest is requested
+	canonical map[string][]byte
+	// Serialised attributes held by a checkpoint being resumed, which are not serialised again
+	resumed map[string][]byte
+	// Loads elements in checkpointed batches during unpacking, if requested
+	checkpoint *UnpackCheckpointing
+	// Packed data being unpacked, which identifies unpack checkpoints
+	packed []byte
+	// Limits the memory held during unpacking, if requested
+	memory *memoryTracker
+	// Provides temporary buffers to the unpacked item
+	allocator Allocator
+	// Receives the chunks of each attribute, if the packed size is being estimated without encryption
+	estimate *PackEstimate
+	// Rejects envelopes without a MAC during unpacking
+	requireMAC bool
+	// Verify the signatures of envelopes during unpacking, if not empty
+	verifiers []Verifier
 }
 
-func (d *itemPackingDetailsV1[T]) pack(item *Item[T], encryptedKey, encKey []byte) ([]byte, map[T]map[string][]byte, error) {
+func (d *itemPackingDetailsV1[T]) pack(ctx context.Context, item *Item[T], encryptedKey, encKey []byte) ([]byte, map[T]map[string][]byte, error) {
 
 	if d.opts == nil {
 		d.opts = &Options{}
@@ -25,14 +68,76 @@ func (d *itemPackingDetailsV1[T]) pack(item *Item[T], encryptedKey, encKey []byt
 	} else {
 		d.opts.serialiseOptions = append(d.opts.serialiseOptions, serialise.WithSerialisationApproach(d.params.Approach))
 	}
-	d.opts.serialiseOptions = append(d.opts.serialiseOptions, serialise.WithAESGCMEncryption(encKey))
+	d.plainSerialiseOptions = slices.Clone(d.opts.serialiseOptions)
 
-	attrMap, valMap, err := d.createMaps(item.Attributes)
+	// Attribute values are encrypted using the selected cipher; the packing details always use AES-GCM
+	// so that the cipher can be recorded amongst them
+	encryptionOption := d.opts.cipherAlgorithm.encryptionOption
+	if d.opts.batchEncryption {
+		encryptionOption = d.opts.cipherAlgorithm.batchEncryptionOption
+	}
+	cipherOption, err := encryptionOption(encKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	if d.params.CipherSuite != nil {
+		cipherOption = cipherSuiteOption(d.params.CipherSuite, encKey)
+	}
+	envelopeOption := serialise.WithAESGCMEncryption(encKey)
+	if d.estimate != nil {
+		if cipherOption, err = sizingOption(cipherOption); err != nil {
+			return nil, nil, err
+		}
+		if envelopeOption, err = sizingOption(envelopeOption); err != nil {
+			return nil, nil, err
+		}
+	}
+	d.attrSerialiseOptions = append(slices.Clone(d.plainSerialiseOptions), cipherOption)
+	d.opts.serialiseOptions = append(d.opts.serialiseOptions, envelopeOption)
+	d.envelopeOption = envelopeOption
+
+	d.resumed = d.opts.checkpoint.start(encryptedKey, d.opts)
+
+	attrMap, valMap, err := d.createMaps(ctx, item.Attributes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	elements, output, err := d.createElements(item.Key, valMap, chunkGroups(attrMap, d.opts.attrGroups))
 	if err != nil {
 		return nil, nil, err
 	}
 
-	elements, output := d.createElements(item.Key, valMap)
+	if d.estimate != nil {
+		d.estimate.recordChunks(attrMap)
+	}
+
+	if d.opts.elementKeys && d.estimate != nil {
+		padElements(output, elementKeyOverhead)
+	} else if d.opts.elementKeys {
+		d.elementKeys, err = sealElements(encKey, elementKeyLabelFor(d.opts), elements, output, d.params.Packer)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if d.opts.parityElements > 0 {
+		used := make(map[string]bool, len(valMap))
+		for k := range valMap {
+			used[k] = true
+		}
+		elements, d.erasure, err = addParityElements(int(d.opts.parityElements), elements, output, d.params.Creator,
+			func(i int) (string, error) { return d.uniqueAttributeName(used, "", i) }, allocatorOrDefault(d.opts.allocator))
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if d.opts.replicationFactor > 1 {
+		if d.replicas, err = addReplicas(int(d.opts.replicationFactor), elements, output, d.params.Creator); err != nil {
+			return nil, nil, err
+		}
+	}
 
 	bKey, err := d.params.Packer.Pack(item.Key)
 	if err != nil {
@@ -49,13 +154,40 @@ func (d *itemPackingDetailsV1[T]) pack(item *Item[T], encryptedKey, encKey []byt
 		return nil, nil, err
 	}
 
+	if d.opts.metadataCompression != NoCompression {
+		if bAttrMap, err = compress(d.opts.metadataCompression, bAttrMap); err != nil {
+			return nil, nil, err
+		}
+		if bElements, err = compress(d.opts.metadataCompression, bElements); err != nil {
+			return nil, nil, err
+		}
+	}
+
 	// Encrypt these details, so they are only accessible if envelope key is available
 	packData := []any{
 		bKey,
 		bAttrMap,
 		bElements,
 	}
-	b, _, err := serialise.ToBytesMany(packData, d.opts.serialiseOptions...)
+
+	ext, err := d.createExtensions(elements, output)
+	if err != nil {
+		return nil, nil, err
+	}
+	if d.opts.inline {
+		if ext[extInline], err = packInline(output); err != nil {
+			return nil, nil, err
+		}
+		output = map[T]map[string][]byte{}
+	}
+	if len(ext) > 0 {
+		bExt, err := ext.pack(d.params.Approach)
+		if err != nil {
+			return nil, nil, err
+		}
+		packData = append(packData, bExt)
+	}
+	b, err := d.sealPackData(packData)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -68,8 +200,30 @@ func (d *itemPackingDetailsV1[T]) pack(item *Item[T], encryptedKey, encKey []byt
 		b,
 	}
 
-	// Always use V1 to guarantee we can bootstrap back to the finalised data
-	b, _, err = serialise.ToBytesMany(finalisedData, serialise.WithSerialisationApproach(serialise.NewMinDataApproachWithVersion(serialise.V1)))
+	// The digest is visible, so that packs can be compared without access to the envelope key
+	if d.opts.digestKey != nil {
+		digest, err := createDigest(d.opts.digestKey, d.opts.digestHash, bKey, d.canonical)
+		if err != nil {
+			return nil, nil, err
+		}
+		finalisedData = append(finalisedData, digest)
+	}
+
+	if d.opts.envelopeMAC {
+		mac, err := envelopeMAC(encKey, d.version, d.opts.wireFormat, finalisedData)
+		if err != nil {
+			return nil, nil, err
+		}
+		finalisedData = withFinalisedField(finalisedData, finalisedMAC, mac)
+	}
+
+	if d.opts.signer != nil {
+		if finalisedData, err = signFinalisedData(d.opts.signer, d.version, d.opts.wireFormat, finalisedData); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	b, err = encodeFinalisedData(d.version, d.opts.wireFormat, finalisedData)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -80,19 +234,45 @@ func (d *itemPackingDetailsV1[T]) pack(item *Item[T], encryptedKey, encKey []byt
 
 var ErrInvalidDataToUnpack = errors.New("the provided data cannot not be deserialised")
 
-func (d *itemPackingDetailsV1[T]) unpack(ctx context.Context, data []byte, envKeyProvider EnvelopeKeyProvider, loader DataLoader[T], idRetriever GetIDSerialiser[T]) (*EncryptedItem[T], error) {
+// envelopeV1 holds the contents of packed data, once the envelope key has been decrypted
+type envelopeV1[T comparable] struct {
+	version       PackVersion
+	wire          WireFormat
+	finalisedData []any
+	encryptedKey  []byte
+	encKey        []byte
+	packer        IDSerialiser[T]
+	approach      serialise.Approach
+	ext           envelopeExtensions
+	key           T
+	bAttrMap      []byte
+	elements      []T
+	signerID      string
+}
+
+// openEnvelope decrypts the packing details of the data, without loading any attribute values
+func (d *itemPackingDetailsV1[T]) openEnvelope(ctx context.Context, data []byte, envKeyProvider EnvelopeKeyProvider, idRetriever GetIDSerialiser[T]) (*envelopeV1[T], error) {
 
-	// Always use V1 to guarantee we can bootstrap back to the finalised data
-	finalisedData, err := serialise.FromBytesMany(data, serialise.NewMinDataApproachWithVersion(serialise.V1))
+	finalisedData, wire, err := decodeFinalisedData(d.version, data)
 	if err != nil {
 		return nil, err
 	}
 
-	if len(finalisedData) != 4 {
+	// A digest, MAC and signature are optional, and only present if requested during Pack
+	if len(finalisedData) < 4 || len(finalisedData) > finalisedDataMaxFields {
 		return nil, ErrInvalidDataToUnpack
 	}
 
-	encryptedKey, ok := finalisedData[0].([]byte)
+	env := &envelopeV1[T]{version: d.version, wire: wire, finalisedData: finalisedData}
+
+	// Signatures are checked before anything is decrypted, as they do not require the data encryption key
+	env.signerID, err = verifyFinalisedData(d.version, wire, finalisedData, d.verifiers)
+	if err != nil {
+		return nil, err
+	}
+
+	var ok bool
+	env.encryptedKey, ok = finalisedData[0].([]byte)
 	if !ok {
 		return nil, ErrInvalidDataToUnpack
 	}
@@ -101,7 +281,7 @@ func (d *itemPackingDetailsV1[T]) unpack(ctx context.Context, data []byte, envKe
 	if !ok {
 		return nil, ErrInvalidDataToUnpack
 	}
-	packer, err := idRetriever(packerName)
+	env.packer, err = idRetriever(packerName)
 	if err != nil {
 		return nil, err
 	}
@@ -110,7 +290,7 @@ func (d *itemPackingDetailsV1[T]) unpack(ctx context.Context, data []byte, envKe
 	if !ok {
 		return nil, ErrInvalidDataToUnpack
 	}
-	approach, err := serialise.GetApproach(approachName)
+	env.approach, err = serialise.GetApproach(approachName)
 	if err != nil {
 		return nil, err
 	}
@@ -120,57 +300,274 @@ func (d *itemPackingDetailsV1[T]) unpack(ctx context.Context, data []byte, envKe
 		return nil, ErrInvalidDataToUnpack
 	}
 
-	encKey, err := envKeyProvider.Decrypt(ctx, encryptedKey)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	decryptStart := time.Now()
+
+	env.encKey, err = envKeyProvider.Decrypt(ctx, env.encryptedKey)
 	if err != nil {
 		return nil, err
 	}
 
-	packData, err := serialise.FromBytesMany(b, approach, serialise.WithAESGCMEncryption(encKey))
+	if err := d.verifyEnvelopeMAC(env); err != nil {
+		return nil, err
+	}
+
+	packData, err := d.openPackData(b, env.approach, env.encKey)
 	if err != nil {
 		return nil, err
 	}
 
-	if len(packData) != 3 {
+	d.stats.recordDecrypt(time.Since(decryptStart))
+
+	// Extensions are optional, and only present if features requiring them were used during Pack
+	if len(packData) != 3 && len(packData) != 4 {
 		return nil, ErrInvalidDataToUnpack
 	}
 
+	env.ext = envelopeExtensions{}
+	if len(packData) == 4 {
+		bExt, ok := packData[3].([]byte)
+		if !ok {
+			return nil, ErrInvalidDataToUnpack
+		}
+		env.ext, err = unpackEnvelopeExtensions(bExt, env.approach)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	bKey, ok := packData[0].([]byte)
 	if !ok {
 		return nil, ErrInvalidDataToUnpack
 	}
 
-	key, err := packer.Unpack(bKey)
+	env.key, err = env.packer.Unpack(bKey)
 	if err != nil {
 		return nil, err
 	}
 
-	bAttrMap, ok := packData[1].([]byte)
+	env.bAttrMap, ok = packData[1].([]byte)
+	if !ok {
+		return nil, ErrInvalidDataToUnpack
+	}
+
+	bElements, ok := packData[2].([]byte)
 	if !ok {
 		return nil, ErrInvalidDataToUnpack
 	}
 
-	attrMap, err := d.unpackAttrMap(bAttrMap, approach)
+	metadataCompression, err := env.ext.metadataCompression()
+	if err != nil {
+		return nil, err
+	}
+	if metadataCompression != NoCompression {
+		if env.bAttrMap, err = d.memory.decompressWithin(metadataCompression, env.bAttrMap); err != nil {
+			return nil, err
+		}
+		if bElements, err = d.memory.decompressWithin(metadataCompression, bElements); err != nil {
+			return nil, err
+		}
+	}
+	env.elements, err = d.unpackElementsSlice(bElements, env.approach, env.packer)
 	if err != nil {
 		return nil, err
 	}
 
-	bElements, ok := packData[2].([]byte)
-	if !ok {
-		return nil, ErrInvalidDataToUnpack
+	return env, nil
+}
+
+// rewrap returns the packed data with the data encryption key wrapped by the wrapper, leaving attribute values unchanged
+func (env *envelopeV1[T]) rewrap(ctx context.Context, wrapper KeyWrapper) ([]byte, error) {
+	return rewrapEnvelope(ctx, env.version, env.wire, env.finalisedData, env.encKey, wrapper)
+}
+
+// rewrapEnvelope returns packed data from the finalised data, with the data encryption key wrapped by the wrapper
+func rewrapEnvelope(ctx context.Context, packingVersion PackVersion, wire WireFormat, finalisedData []any, encKey []byte, wrapper KeyWrapper) ([]byte, error) {
+
+	encryptedKey, err := wrapper.Wrap(ctx, encKey)
+	if err != nil {
+		return nil, err
+	}
+
+	finalisedData = slices.Clone(unsignedFinalisedData(finalisedData))
+	finalisedData[0] = encryptedKey
+	if err := resealEnvelopeMAC(encKey, packingVersion, wire, finalisedData); err != nil {
+		return nil, err
+	}
+
+	b, err := encodeFinalisedData(packingVersion, wire, finalisedData)
+	if err != nil {
+		return nil, err
+	}
+
+	return joinPackingVersion(packingVersion, b)
+}
+
+func (d *itemPackingDetailsV1[T]) unpack(ctx context.Context, data []byte, envKeyProvider EnvelopeKeyProvider, loader DataLoader[T], idRetriever GetIDSerialiser[T]) (*EncryptedItem[T], error) {
+
+	env, err := d.openEnvelope(ctx, data, envKeyProvider, idRetriever)
+	if err != nil {
+		return nil, err
+	}
+
+	d.packed = data
+	return d.unpackEnvelope(ctx, env, loader)
+}
+
+// unpackEnvelope loads and verifies the elements of the opened envelope, returning the EncryptedItem
+func (d *itemPackingDetailsV1[T]) unpackEnvelope(ctx context.Context, env *envelopeV1[T], loader DataLoader[T]) (*EncryptedItem[T], error) {
+
+	encryptedKey, encKey, packer, approach, ext, key, elements := env.encryptedKey, env.encKey, env.packer, env.approach, env.ext, env.key, env.elements
+
+	compression, err := ext.compression()
+	if err != nil {
+		return nil, err
+	}
+
+	cipherAlgorithm, err := ext.cipherAlgorithm()
+	if err != nil {
+		return nil, err
+	}
+
+	attrMap, err := d.unpackAttrMap(env.bAttrMap, approach, ext)
+	if err != nil {
+		return nil, err
+	}
+
+	loader, err = env.inlineLoader(loader)
+	if err != nil {
+		return nil, err
+	}
+
+	d.progress.elements(len(elements))
+	d.progress.attributes(len(attrMap))
+
+	// Loaded data is only held until the attribute values are reassembled
+	var loaded uint64
+	defer func() { d.memory.release(loaded) }()
+
+	md, err := d.checkpoint.loadElements(ctx, d.packed, len(elements), func(from, to int) (map[string][]byte, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		loadStart := time.Now()
+		md, err := loader(ctx, elements[from:to])
+		d.stats.recordLoad(to-from, md, time.Since(loadStart))
+		if err != nil {
+			return nil, err
+		}
+		// Loaders need not observe the context, so cancellation during the load is detected here
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		size := dataSize(md)
+		if err := d.memory.reserve(size); err != nil {
+			return nil, err
+		}
+		loaded += size
+		d.progress.elementsFlushed(to - from)
+		return md, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Detect corruption of stored data before any decryption is attempted
+	checksums, err := ext.checksums(approach)
+	if err != nil {
+		return nil, err
+	}
+
+	if md == nil {
+		md = map[string][]byte{}
+	}
+
+	// Fall back to replicas for any missing or corrupted elements, if replication was used
+	if b, ok := ext[extReplicas]; ok {
+		replicas, err := unpackReplicas(b, packer, approach)
+		if err != nil {
+			return nil, err
+		}
+		if err := loadFromReplicas(ctx, loader, replicas, checksums, md); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// Reconstruct any missing or corrupted elements, if erasure coding was used
+	layout, err := ext.erasure(approach)
+	if err != nil {
+		return nil, err
+	}
+	var repaired []T
+	if layout != nil {
+		indices, err := repairElements(layout, checksums, md)
+		if err != nil {
+			return nil, err
+		}
+		for _, i := range indices {
+			if i < len(elements) {
+				repaired = append(repaired, elements[i])
+			}
+		}
 	}
-	elements, err := d.unpackElementsSlice(bElements, approach, packer)
+
+	if err := verifyChecksums(checksums, attrMap, elements, md); err != nil {
+		return nil, err
+	}
+
+	// Signed chunks are checked after any repair, so that only the chunks that were signed are decrypted
+	digests, err := ext.chunkDigests(approach)
 	if err != nil {
 		return nil, err
 	}
+	if err := verifyChunkDigests(digests, attrMap, md); err != nil {
+		return nil, err
+	}
 
-	md, err := loader(ctx, elements)
+	hierarchy, err := ext.keyHierarchy(approach)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// Remove the element encryption, if element keys were used
+	if b, ok := ext[extElementKeys]; ok {
+		index, err := unpackElementKeys(b, approach)
+		if err != nil {
+			return nil, err
+		}
+		if err := openElements(encKey, elementLabel(hierarchy), elements, index, packer, md); err != nil {
+			return nil, err
+		}
+	}
+
+	var reassembled uint64
+	for _, v := range attrMap {
+		for _, a := range v {
+			reassembled += uint64(len(md[a]))
+		}
+	}
+	if err := d.memory.reserve(reassembled); err != nil {
+		return nil, err
+	}
+
 	dataMap := map[string][]byte{}
 
 	for k, v := range attrMap {
+		// Reassembly of large items is abandoned promptly if the context is cancelled
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		b := []byte{}
 		for _, a := range v {
 			if part, ok := md[a]; !ok {
@@ -180,6 +577,12 @@ func (d *itemPackingDetailsV1[T]) unpack(ctx context.Context, data []byte, envKe
 			}
 		}
 		dataMap[k] = b
+		d.progress.attributeProcessed(len(b))
+	}
+
+	stored, err := env.storedElements()
+	if err != nil {
+		return nil, err
 	}
 
 	output := &EncryptedItem[T]{
@@ -188,27 +591,75 @@ func (d *itemPackingDetailsV1[T]) unpack(ctx context.Context, data []byte, envKe
 		encryptedKey: encryptedKey,
 		attributes:   dataMap,
 		packer:       packer,
+		compression:  compression,
+		cipher:       cipherAlgorithm,
+		suiteID:      string(ext[extCipherSuite]),
+		hierarchy:    hierarchy,
+		repaired:     repaired,
+		elements:     stored,
+		envelope:     env.finalisedData,
+		version:      env.version,
+		wire:         env.wire,
+		signerID:     env.signerID,
+		memory:       d.memory,
+		allocator:    d.allocator,
 	}
 
 	return output, nil
 }
 
+// byteSort is a unit of bin packing: either a single chunk, or all the chunks of an attribute group
 type byteSort struct {
-	k string
-	v []byte
+	k      string
+	group  bool
+	chunks []string
+	size   int
 }
 
 type byteSortSet []byteSort
 
-func (b byteSortSet) Len() int           { return len(b) }
-func (b byteSortSet) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
-func (b byteSortSet) Less(i, j int) bool { return len(b[i].v) < len(b[j].v) }
+func (b byteSortSet) Len() int      { return len(b) }
+func (b byteSortSet) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b byteSortSet) Less(i, j int) bool {
+	// Ties are broken by name, so that the bin packing is deterministic
+	if b[i].size == b[j].size {
+		if b[i].k == b[j].k {
+			return !b[i].group && b[j].group
+		}
+		return b[i].k < b[j].k
+	}
+	return b[i].size < b[j].size
+}
 
-func (d *itemPackingDetailsV1[T]) createElements(key T, vals map[string][]byte) ([]T, map[T]map[string][]byte) {
+// ErrAttributeGroupTooLarge raised if the attributes of a group cannot be placed in a single element
+var ErrAttributeGroupTooLarge = errors.New("attributes of the group exceed the maximum size of an element")
+
+// createElements bin packs the chunks into elements, placing the chunks of each attribute group, identified
+// by the group of each chunk, in the same element
+func (d *itemPackingDetailsV1[T]) createElements(key T, vals map[string][]byte, groups map[string]string) ([]T, map[T]map[string][]byte, error) {
+
+	// Element encryption increases the size of each chunk once stored
+	var overhead uint64
+	if d.opts.elementKeys {
+		overhead = elementKeyOverhead
+	}
 
 	bbs := byteSortSet{}
+	grouped := map[string]int{}
 	for k, v := range vals {
-		bbs = append(bbs, byteSort{k: k, v: v})
+		g, ok := groups[k]
+		if !ok {
+			bbs = append(bbs, byteSort{k: k, chunks: []string{k}, size: len(v)})
+			continue
+		}
+		i, ok := grouped[g]
+		if !ok {
+			i = len(bbs)
+			grouped[g] = i
+			bbs = append(bbs, byteSort{k: g, group: true})
+		}
+		bbs[i].chunks = append(bbs[i].chunks, k)
+		bbs[i].size += len(v)
 	}
 
 	sort.Sort(bbs)
@@ -221,33 +672,50 @@ func (d *itemPackingDetailsV1[T]) createElements(key T, vals map[string][]byte)
 	// Basic binpack,
 	var bins []bin
 	for _, bs := range bbs {
+		var size uint64
+		for _, k := range bs.chunks {
+			size += uint64(len(k)+len(vals[k])) + overhead
+		}
+		if bs.group && size >= d.opts.maxSize {
+			return nil, nil, fmt.Errorf("%w: group %q requires %d bytes", ErrAttributeGroupTooLarge, bs.k, size)
+		}
 		placed := false
 		for i := range bins {
-			if bins[i].size+uint64(len(bs.k)+len(bs.v)) < d.opts.maxSize {
+			if bins[i].size+size < d.opts.maxSize {
 				bins[i].content = append(bins[i].content, &bs)
-				bins[i].size += uint64(len(bs.k) + len(bs.v))
+				bins[i].size += size
 				placed = true
 				break
 			}
 		}
 		if !placed {
+			if len(bins) > 0 {
+				d.opts.log().Debug("packer: bins full, overflowing to new element",
+					slog.Int("elements", len(bins)+1),
+					slog.Uint64("maxSize", d.opts.maxSize))
+			}
 			newBin := bin{
-				size:    uint64(len(bs.k) + len(bs.v)),
+				size:    size,
 				content: []*byteSort{&bs},
 			}
 			bins = append(bins, newBin)
 		}
 	}
 
+	d.progress.elements(len(bins))
+
+	ids, err := createIDs(d.params.Creator, len(bins)-1)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	outputKeys := []T{}
 	outputAttSet := map[T]map[string][]byte{}
 
 	for i := range bins {
-		var t T
-		if i == 0 {
-			t = key
-		} else {
-			t = d.params.Creator.ID()
+		t := key
+		if i > 0 {
+			t = ids[i-1]
 		}
 		outputKeys = append(outputKeys, t)
 
@@ -256,23 +724,39 @@ func (d *itemPackingDetailsV1[T]) createElements(key T, vals map[string][]byte)
 
 		bin := bins[i]
 		for _, c := range bin.content {
-			m[c.k] = c.v
+			for _, k := range c.chunks {
+				m[k] = vals[k]
+			}
 		}
+
+		d.progress.elementsFlushed(1)
 	}
 
-	return outputKeys, outputAttSet
+	return outputKeys, outputAttSet, nil
 }
 
 func (d *itemPackingDetailsV1[T]) packAttrMap(attrMap map[string][]string) ([]byte, error) {
 
+	if d.opts.attrDictionary {
+		return packAttrDictionary(attrMap, d.params.Approach)
+	}
+	if d.opts.wireFormat == CBORWireFormat {
+		return encodeCBORAttrMap(attrMap), nil
+	}
+
+	// Serialise in name order, so that identical items produce structurally identical envelopes
+	names := make([]string, 0, len(attrMap))
+	for k := range attrMap {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
 	items := make([]any, len(attrMap))
 
-	i := 0
-	for k, v := range attrMap {
+	for i, k := range names {
 		item := []string{k}
-		item = append(item, v...)
+		item = append(item, attrMap[k]...)
 		items[i] = item
-		i++
 	}
 
 	b, _, err := serialise.ToBytesMany(items, serialise.WithSerialisationApproach(d.params.Approach))
@@ -281,13 +765,25 @@ func (d *itemPackingDetailsV1[T]) packAttrMap(attrMap map[string][]string) ([]by
 
 var ErrInvalidDataToDeserialiseAttrMap = errors.New("invalid data, cannot deserialise attribute map")
 
-func (d *itemPackingDetailsV1[T]) unpackAttrMap(data []byte, approach serialise.Approach) (map[string][]string, error) {
+func (d *itemPackingDetailsV1[T]) unpackAttrMap(data []byte, approach serialise.Approach, ext envelopeExtensions) (map[string][]string, error) {
+
+	if ext.attributeDictionary() {
+		return unpackAttrDictionary(data, approach, d.maxAttributes)
+	}
+	if _, ok := ext[extCBORAttrMap]; ok {
+		return decodeCBORAttrMap(data, d.maxAttributes)
+	}
 
 	v, err := serialise.FromBytesMany(data, approach)
 	if err != nil {
 		return nil, err
 	}
 
+	// Check before allocating, to defend against pathologically wide items
+	if d.maxAttributes > 0 && len(v) > int(d.maxAttributes) {
+		return nil, ErrTooManyAttributes
+	}
+
 	attrMap := make(map[string][]string, len(v))
 
 	for i := 0; i < len(v); i++ {
@@ -348,72 +844,102 @@ func (d *itemPackingDetailsV1[T]) unpackElementsSlice(data []byte, approach seri
 	return elements, nil
 }
 
-func (d *itemPackingDetailsV1[T]) createMaps(attrs map[string]any) (map[string][]string, map[string][]byte, error) {
+func (d *itemPackingDetailsV1[T]) createMaps(ctx context.Context, attrs map[string]any) (map[string][]string, map[string][]byte, error) {
 	used := map[string]bool{}
 	attrMap := map[string][]string{}
 	valMap := map[string][]byte{}
 
-	for k, v := range attrs {
-		var b []byte
-		var err error
-		// Individual attribute values are serialised using the user options - which will include encryption
-		switch vv := v.(type) {
-		case T:
-			b, err = d.params.Packer.Pack(vv)
-			if err != nil {
-				return nil, nil, err
+	// Process in name order, so that chunk names and bins are assigned deterministically
+	names := make([]string, 0, len(attrs))
+	for k := range attrs {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	if err := d.opts.schema.checkNames(names); err != nil {
+		return nil, nil, err
+	}
+
+	d.progress.attributes(len(names))
+
+	if d.opts.digestKey != nil {
+		d.canonical = make(map[string][]byte, len(names))
+	}
+	var mu sync.Mutex
+
+	// Serialisation and encryption of each attribute is independent, so can be performed concurrently,
+	// in waves that respect any memory budget
+	serialised := make([][]byte, len(names))
+	err := runInWaves(memoryWaves(names, attrs, d.opts.memoryBudget), int(d.opts.concurrency), func(i int) error {
+		b, done := d.resumed[names[i]]
+		if done && d.canonical == nil {
+			serialised[i] = b
+			d.progress.attributeProcessed(len(b))
+			return nil
+		}
+		v, err := applyHooks(d.opts.prePackHooks, names[i], attrs[names[i]])
+		if err != nil {
+			return err
+		}
+		if err := d.opts.schema.checkValue(names[i], v); err != nil {
+			return err
+		}
+		if !done {
+			if b, err = d.serialiseAttribute(v); err != nil {
+				return err
 			}
-			b, _, err = serialise.ToBytesMany([]any{true, b}, d.opts.serialiseOptions...)
-		case *T:
-			b, err = d.params.Packer.Pack(*vv)
+		}
+		if d.canonical != nil {
+			cb, err := d.canonicalAttribute(v)
 			if err != nil {
-				return nil, nil, err
-			}
-			b, _, err = serialise.ToBytesMany([]any{false, b}, d.opts.serialiseOptions...)
-		case []T:
-			tt := make([]any, len(vv)+2)
-			tt[0] = true
-			tt[1] = int64(len(vv))
-			for i := 0; i < len(vv); i++ {
-				tt[i+2], err = d.params.Packer.Pack(vv[i])
-				if err != nil {
-					return nil, nil, err
-				}
-			}
-			b, _, err = serialise.ToBytesMany(tt, d.opts.serialiseOptions...)
-		case []*T:
-			tt := make([]any, len(vv)+2)
-			tt[0] = false
-			tt[1] = int64(len(vv))
-			for i := 0; i <= len(vv); i++ {
-				tt[i+2], err = d.params.Packer.Pack(*vv[i])
-				if err != nil {
-					return nil, nil, err
-				}
+				return err
 			}
-			b, _, err = serialise.ToBytesMany(tt, d.opts.serialiseOptions...)
-		default:
-			b, _, err = serialise.ToBytesMany([]any{v}, d.opts.serialiseOptions...)
+			mu.Lock()
+			d.canonical[names[i]] = cb
+			mu.Unlock()
 		}
-		if err != nil {
-			return nil, nil, err
+		serialised[i] = b
+		d.progress.attributeProcessed(len(b))
+		if done {
+			return nil
+		}
+		if d.opts.quota != nil {
+			if err := d.opts.quota.Acquire(ctx, d.opts.tenant, 0, uint64(len(b))); err != nil {
+				return err
+			}
 		}
+		return d.opts.checkpoint.completed(names[i], b)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for i, k := range names {
+		b := serialised[i]
 
 		// Where the serialised value exceedes the max size allowed, then
 		// split it into chunks, each with its own unique attribute name.
 		// attrMap then holds the array of attribute names in the correct
 		// order to reconstruct the overall byte size when needed.
 		attrMap[k] = []string{}
+		if len(b) > int(d.opts.maxAttrValueSize) {
+			if d.opts.rejectOversize {
+				return nil, nil, &AttributeTooLargeError{Name: k, Size: uint64(len(b)), MaxSize: d.opts.maxAttrValueSize}
+			}
+			d.opts.log().Debug("packer: attribute value chunking triggered",
+				slog.Int("size", len(b)),
+				slog.Uint64("maxAttributeValueSize", d.opts.maxAttrValueSize))
+		}
 		for len(b) > int(d.opts.maxAttrValueSize) {
-			an, err := d.uniqueAttributeName(used)
+			an, err := d.uniqueAttributeName(used, k, len(attrMap[k]))
 			if err != nil {
 				return nil, nil, err
 			}
 			valMap[an] = b[0:d.opts.maxAttrValueSize]
 			attrMap[k] = append(attrMap[k], an)
-			b = b[d.opts.maxSize:]
+			b = b[d.opts.maxAttrValueSize:]
 		}
-		an, err := d.uniqueAttributeName(used)
+		an, err := d.uniqueAttributeName(used, k, len(attrMap[k]))
 		if err != nil {
 			return nil, nil, err
 		}
@@ -424,9 +950,160 @@ func (d *itemPackingDetailsV1[T]) createMaps(attrs map[string]any) (map[string][
 	return attrMap, valMap, nil
 }
 
+// serialiseAttribute serialises an individual attribute value using the user options - which will include encryption
+func (d *itemPackingDetailsV1[T]) serialiseAttribute(v any) ([]byte, error) {
+	vals, err := d.attributeValues(v)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.opts.compression == NoCompression {
+		b, _, err := serialise.ToBytesMany(vals, d.attrSerialiseOptions...)
+		return b, err
+	}
+
+	// Compression must be applied before encryption, so serialise without encryption first
+	b, _, err := serialise.ToBytesMany(vals, d.plainSerialiseOptions...)
+	if err != nil {
+		return nil, err
+	}
+	// The compressed value is copied by the encrypting serialisation, so can be held in a temporary buffer
+	alloc := allocatorOrDefault(d.opts.allocator)
+	scratch := alloc.Alloc(len(b))
+	defer alloc.Free(scratch)
+
+	b, err = compressInto(scratch[:0], d.opts.compression, b)
+	if err != nil {
+		return nil, err
+	}
+	b, _, err = serialise.ToBytesMany([]any{b}, d.attrSerialiseOptions...)
+	return b, err
+}
+
+// attributeValues returns the values to be serialised for an attribute value, with keys of type T serialised using the Packer
+func (d *itemPackingDetailsV1[T]) attributeValues(v any) ([]any, error) {
+	var vals []any
+	var err error
+
+	switch vv := v.(type) {
+	case PackedItem:
+		vals = []any{packedItemMarker, []byte(vv)}
+	case T:
+		b, err := d.params.Packer.Pack(vv)
+		if err != nil {
+			return nil, err
+		}
+		vals = []any{true, b}
+	case *T:
+		b, err := d.params.Packer.Pack(*vv)
+		if err != nil {
+			return nil, err
+		}
+		vals = []any{false, b}
+	case []T:
+		vals = make([]any, len(vv)+2)
+		vals[0] = true
+		vals[1] = int64(len(vv))
+		for i := 0; i < len(vv); i++ {
+			vals[i+2], err = d.params.Packer.Pack(vv[i])
+			if err != nil {
+				return nil, err
+			}
+		}
+	case []*T:
+		vals = make([]any, len(vv)+2)
+		vals[0] = false
+		vals[1] = int64(len(vv))
+		for i := 0; i < len(vv); i++ {
+			vals[i+2], err = d.params.Packer.Pack(*vv[i])
+			if err != nil {
+				return nil, err
+			}
+		}
+	default:
+		vals = []any{v}
+	}
+
+	return vals, nil
+}
+
+// createExtensions records the settings used during packing that are needed to unpack successfully
+func (d *itemPackingDetailsV1[T]) createExtensions(elements []T, output map[T]map[string][]byte) (envelopeExtensions, error) {
+	ext := envelopeExtensions{}
+	if d.opts.compression != NoCompression {
+		ext[extCompression] = []byte{byte(d.opts.compression)}
+	}
+	if d.opts.cipherAlgorithm != AES256GCM {
+		ext[extCipher] = []byte{byte(d.opts.cipherAlgorithm)}
+	}
+	if d.params.CipherSuite != nil {
+		ext[extCipherSuite] = []byte(d.params.CipherSuite.ID())
+	}
+	if d.opts.attrDictionary {
+		ext[extAttrDictionary] = []byte{1}
+	} else if d.opts.wireFormat == CBORWireFormat {
+		ext[extCBORAttrMap] = []byte{1}
+	}
+	if d.opts.metadataCompression != NoCompression {
+		ext[extMetadataCompression] = []byte{byte(d.opts.metadataCompression)}
+	}
+	if d.opts.checksums {
+		b, err := packChecksums(createChecksums(elements, output), d.params.Approach)
+		if err != nil {
+			return nil, err
+		}
+		ext[extChecksums] = b
+	}
+	if d.erasure != nil {
+		b, err := packErasureLayout(d.erasure, d.params.Approach)
+		if err != nil {
+			return nil, err
+		}
+		ext[extErasure] = b
+	}
+	if d.opts.keyHierarchy {
+		b, err := packKeyHierarchy(createKeyHierarchy(d.opts, d.params.Provider.ID()), d.params.Approach)
+		if err != nil {
+			return nil, err
+		}
+		ext[extKeyHierarchy] = b
+	}
+	if d.elementKeys != nil {
+		b, err := packElementKeys(d.elementKeys, d.params.Approach)
+		if err != nil {
+			return nil, err
+		}
+		ext[extElementKeys] = b
+	}
+	if d.opts.merkleRoot {
+		ext[extMerkleRoot] = merkleRoot(merkleLeaves(elements, output))
+	}
+	if d.opts.keyReuse != nil {
+		b, err := packKeyReuse(d.opts.keyReuse, d.params.Approach)
+		if err != nil {
+			return nil, err
+		}
+		ext[extKeyReuse] = b
+	}
+	if d.opts.signer != nil {
+		b, err := packChunkDigests(createChunkDigests(elements, output), d.params.Approach)
+		if err != nil {
+			return nil, err
+		}
+		ext[extChunkDigests] = b
+	}
+	if d.replicas != nil {
+		b, err := packReplicas(d.replicas, d.params.Packer, d.params.Approach)
+		if err != nil {
+			return nil, err
+		}
+		ext[extReplicas] = b
+	}
+	return ext, nil
+}
+
 func createString(size uint8) string {
-	// Use a reduced selection so that attribute names are readable
-	return createStringFromRange("ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789", size)
+	return createStringFromRange(defaultAttributeNameAlphabet, size)
 }
 
 func createStringFromRange(choices string, size uint8) string {
@@ -451,16 +1128,35 @@ func createStringFromRange(choices string, size uint8) string {
 // ErrUnableToCreateUniqueName raised if a unique attribute name cannot be determined before running out of retries
 var ErrUnableToCreateUniqueName = errors.New("retries exceeded when creating random attribute names - increase the size of attribute names option")
 
-func (d *itemPackingDetailsV1[T]) uniqueAttributeName(existing map[string]bool) (string, error) {
+func (d *itemPackingDetailsV1[T]) uniqueAttributeName(existing map[string]bool, attr string, chunk int) (string, error) {
 
-	// Ensure don't loop forever if set of attribute names is exhaused.  Shouldn't happen though.
-	for i := 0; i < int(d.opts.attrNameRetries); i++ {
-		s := createString(d.opts.attrNameSize)
-		if _, ok := existing[s]; !ok {
-			existing[s] = true
-			return s, nil
-		}
+	namer := d.opts.attrNamer
+	if namer == nil {
+		namer = &randomNamer{opts: d.opts}
 	}
+	metrics := metricsOrDefault(d.opts.metrics)
+
+	for {
+		// Ensure don't loop forever if set of attribute names is exhaused.  Shouldn't happen though.
+		for i := 0; i < int(d.opts.attrNameRetries); i++ {
+			s, err := namer.NewName(attr, chunk)
+			if err != nil {
+				return "", err
+			}
+			if _, ok := existing[s]; !ok {
+				existing[s] = true
+				return s, nil
+			}
+			metrics.Add(MetricAttributeNameCollisions, 1)
+		}
 
-	return "", ErrUnableToCreateUniqueName
+		// Random names may be lengthened, if requested, rather than failing
+		if d.opts.attrNamer != nil || d.opts.attrNameSize >= d.opts.attrNameMaxSize {
+			return "", ErrUnableToCreateUniqueName
+		}
+		d.opts.attrNameSize++
+		metrics.Add(MetricAttributeNameGrowths, 1)
+		d.opts.log().Debug("packer: attribute name size increased after collisions",
+			slog.Int("attributeNameSize", int(d.opts.attrNameSize)))
+	}
 }
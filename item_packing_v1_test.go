@@ -1,6 +1,9 @@
 package packer
 
 import (
+	"bytes"
+	"fmt"
+	"slices"
 	"testing"
 
 	"github.com/gford1000-go/serialise"
@@ -78,3 +81,98 @@ func TestItemPackingDetailsV1_PackElementsSlice(t *testing.T) {
 		}
 	}
 }
+
+func TestItemPackingDetailsV1_PackAttrMap(t *testing.T) {
+
+	serialiser, err := NewKeySerialiser()
+	if err != nil {
+		t.Fatalf("Unexpected error creating KeySerialiser: %v", err)
+	}
+
+	i := &itemPackingDetailsV1[Key]{
+		params: &PackParams[Key]{
+			Packer:   serialiser,
+			Approach: serialise.NewMinDataApproachWithVersion(serialise.V1),
+		},
+		opts: &Options{},
+	}
+
+	attrMap := map[string][]string{}
+	for j := range 50 {
+		attrMap[fmt.Sprintf("attr%d", j)] = []string{fmt.Sprintf("a%d", j), fmt.Sprintf("b%d", j)}
+	}
+
+	expected, err := i.packAttrMap(attrMap)
+	if err != nil {
+		t.Fatalf("Unexpected error packing attribute map: %v", err)
+	}
+
+	// Map iteration order is randomised, so repeated packing would differ if not canonical
+	for j := range 10 {
+		b, err := i.packAttrMap(attrMap)
+		if err != nil {
+			t.Fatalf("Unexpected error packing attribute map: %v", err)
+		}
+		if !bytes.Equal(expected, b) {
+			t.Fatalf("(%d) Attribute map serialisation is not deterministic", j)
+		}
+	}
+
+	attrMap2, err := i.unpackAttrMap(expected, i.params.Approach, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking attribute map: %v", err)
+	}
+	if len(attrMap2) != len(attrMap) {
+		t.Fatalf("Mismatch in length between original and deserialised attribute maps")
+	}
+}
+
+func TestItemPackingDetailsV1_CreateElements(t *testing.T) {
+
+	newDetails := func() *itemPackingDetailsV1[Key] {
+		return &itemPackingDetailsV1[Key]{
+			params: &PackParams[Key]{
+				Creator: &testSequenceCreator{},
+			},
+			opts: &Options{maxSize: minSize},
+		}
+	}
+
+	vals := map[string][]byte{}
+	for j := range 20 {
+		vals[fmt.Sprintf("v%02d", j)] = make([]byte, 1024)
+	}
+
+	key := Key{X: "A", Y: "B"}
+	keys, expected, _ := newDetails().createElements(key, vals, nil)
+	if len(keys) < 2 {
+		t.Fatalf("Expected multiple elements, got: %d", len(keys))
+	}
+
+	for j := range 10 {
+		keys2, output, _ := newDetails().createElements(key, vals, nil)
+		if !slices.Equal(keys, keys2) {
+			t.Fatalf("(%d) Mismatch in elements: expected: %v, got: %v", j, keys, keys2)
+		}
+		for _, k := range keys {
+			if len(expected[k]) != len(output[k]) {
+				t.Fatalf("(%d) Element %v content differs", j, k)
+			}
+			for name := range expected[k] {
+				if _, ok := output[k][name]; !ok {
+					t.Fatalf("(%d) Element %v does not contain %s", j, k, name)
+				}
+			}
+		}
+	}
+}
+
+// testSequenceCreator creates predictable keys for testing
+type testSequenceCreator struct {
+	n int
+}
+
+func (s *testSequenceCreator) ID() Key {
+	s.n++
+	return Key{X: "S", Y: fmt.Sprintf("%d", s.n)}
+}
@@ -0,0 +1,193 @@
+package packer
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/gford1000-go/serialise"
+)
+
+// V2 shares the packing details of V1, but replaces the serialisation of the version, the finalised data and the
+// encrypted packing details with uvarint length framed fields, which substantially reduces the overhead of the
+// envelope for small items.  The attribute map, elements and extensions remain serialised as in V1.
+//
+// Data packed with V2 begins with v2Prefix, which is not a valid start of V1 data, so that both remain readable
+// by Unpack.
+var v2Prefix = []byte{0xff, 0x02}
+
+// appendFrame appends the data to b, prefixed with its length
+func appendFrame(b, data []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+// splitFrames is the inverse of appendFrame, returning each of the framed fields
+func splitFrames(data []byte) ([][]byte, error) {
+	var frames [][]byte
+	for len(data) > 0 {
+		n, i := binary.Uvarint(data)
+		if i <= 0 || n > uint64(len(data)-i) {
+			return nil, ErrInvalidDataToUnpack
+		}
+		frames = append(frames, data[i:i+int(n)])
+		data = data[i+int(n):]
+	}
+	return frames, nil
+}
+
+// Positions of the optional fields of the finalised data, which follow the encrypted packing details.
+// An absent optional field is recorded as empty if a later field is present.
+const (
+	finalisedDigest    = 4
+	finalisedMAC       = 5
+	finalisedSignerID  = 6
+	finalisedSignature = 7
+	// finalisedDataMaxFields is the number of fields of the finalised data, if all are present
+	finalisedDataMaxFields = 8
+)
+
+// finalisedField returns the optional field of the finalised data, which is empty if absent
+func finalisedField(finalisedData []any, i int) ([]byte, error) {
+	if i >= len(finalisedData) {
+		return nil, nil
+	}
+	switch v := finalisedData[i].(type) {
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	default:
+		return nil, ErrInvalidDataToUnpack
+	}
+}
+
+// withFinalisedField returns the finalised data with the optional field set, recording any absent
+// earlier fields as empty
+func withFinalisedField(finalisedData []any, i int, b []byte) []any {
+	for len(finalisedData) <= i {
+		finalisedData = append(finalisedData, []byte{})
+	}
+	finalisedData[i] = b
+	return finalisedData
+}
+
+// encodeFinalisedData serialises the finalised data in the layout of the packing version and wire format
+func encodeFinalisedData(packingVersion PackVersion, wire WireFormat, finalisedData []any) ([]byte, error) {
+
+	if packingVersion != V2 {
+		// Always use V1 to guarantee we can bootstrap back to the finalised data
+		b, _, err := serialise.ToBytesMany(finalisedData, serialise.WithSerialisationApproach(serialise.NewMinDataApproachWithVersion(serialise.V1)))
+		return b, err
+	}
+
+	if wire != NativeWireFormat {
+		return encodeWireFormat(wire, finalisedData)
+	}
+
+	var b []byte
+	for _, v := range finalisedData {
+		switch v := v.(type) {
+		case []byte:
+			b = appendFrame(b, v)
+		case string:
+			b = appendFrame(b, []byte(v))
+		default:
+			return nil, ErrInvalidDataToUnpack
+		}
+	}
+	return b, nil
+}
+
+// decodeFinalisedData is the inverse of encodeFinalisedData, also returning the wire format of the data
+func decodeFinalisedData(packingVersion PackVersion, data []byte) ([]any, WireFormat, error) {
+
+	if packingVersion != V2 {
+		// Always use V1 to guarantee we can bootstrap back to the finalised data
+		finalisedData, err := serialise.FromBytesMany(data, serialise.NewMinDataApproachWithVersion(serialise.V1))
+		return finalisedData, NativeWireFormat, err
+	}
+
+	if isWireFormat(data) {
+		return decodeWireFormat(data)
+	}
+
+	frames, err := splitFrames(data)
+	if err != nil {
+		return nil, NativeWireFormat, err
+	}
+	// A digest, MAC and signature are optional, and only present if requested during Pack
+	if len(frames) < 4 || len(frames) > finalisedDataMaxFields {
+		return nil, NativeWireFormat, ErrInvalidDataToUnpack
+	}
+
+	finalisedData := make([]any, len(frames))
+	for i, f := range frames {
+		finalisedData[i] = f
+	}
+	// Names of the packer and approach
+	finalisedData[1], finalisedData[2] = string(frames[1]), string(frames[2])
+	return finalisedData, NativeWireFormat, nil
+}
+
+// splitFinalisedData returns the finalised data from the data returned by Pack
+func splitFinalisedData(data []byte) ([]any, error) {
+
+	packingVersion, b, err := splitPackingVersion(data)
+	if err != nil {
+		return nil, err
+	}
+	if !packingVersion.supported() {
+		return nil, ErrUnsupportedPackVersion
+	}
+
+	finalisedData, _, err := decodeFinalisedData(packingVersion, b)
+	return finalisedData, err
+}
+
+// sealPackData serialises and encrypts the packing details in the layout of the packing version
+func (d *itemPackingDetailsV1[T]) sealPackData(packData []any) ([]byte, error) {
+
+	if d.version != V2 {
+		b, _, err := serialise.ToBytesMany(packData, d.opts.serialiseOptions...)
+		return b, err
+	}
+
+	var b []byte
+	for _, v := range packData {
+		b = appendFrame(b, v.([]byte))
+	}
+
+	o := serialise.Options{}
+	d.envelopeOption(&o)
+	return o.Encryptor(b)
+}
+
+// openPackData is the inverse of sealPackData
+func (d *itemPackingDetailsV1[T]) openPackData(data []byte, approach serialise.Approach, encKey []byte) ([]any, error) {
+
+	if d.version != V2 {
+		return serialise.FromBytesMany(data, approach, serialise.WithAESGCMEncryption(encKey))
+	}
+
+	o := serialise.Options{}
+	serialise.WithAESGCMEncryption(encKey)(&o)
+	b, err := o.Decryptor(data)
+	if err != nil {
+		return nil, err
+	}
+
+	frames, err := splitFrames(b)
+	if err != nil {
+		return nil, err
+	}
+	packData := make([]any, len(frames))
+	for i, f := range frames {
+		packData[i] = f
+	}
+	return packData, nil
+}
+
+// isV2 returns true if the data was packed with V2
+func isV2(data []byte) bool {
+	return bytes.HasPrefix(data, v2Prefix)
+}
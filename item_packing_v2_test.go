@@ -0,0 +1,97 @@
+package packer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"testing"
+)
+
+func TestPackVersionV2(t *testing.T) {
+
+	testPack, testUnpack, provider := testCreateEnv(t)
+
+	item := &Item[Key]{
+		Key:        Key{X: "A", Y: "B"},
+		Attributes: map[string]any{"a": "x", "b": int64(2)},
+	}
+
+	v1, _, err := testPack(item, WithPackingVersion(V1), WithDigest([]byte("digest key")))
+	if err != nil {
+		t.Fatalf("Unexpected error packing V1: %v", err)
+	}
+	v2, l, err := testPack(item, WithPackingVersion(V2), WithDigest([]byte("digest key")))
+	if err != nil {
+		t.Fatalf("Unexpected error packing V2: %v", err)
+	}
+	if !bytes.HasPrefix(v2, v2Prefix) || len(v2) >= len(v1) {
+		t.Fatalf("Expected a smaller V2 envelope: V1 %d bytes, V2 %d bytes", len(v1), len(v2))
+	}
+
+	e, err := testUnpack(v2, l)
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+	values, err := e.GetValues(context.TODO(), []string{"a", "b"}, provider)
+	if err != nil {
+		t.Fatalf("Unexpected error getting values: %v", err)
+	}
+	if !maps.Equal(values, item.Attributes) {
+		t.Fatalf("Mismatch: expected %v, got %v", item.Attributes, values)
+	}
+
+	if same, err := CompareDigests(v1, v2); err != nil || !same {
+		t.Fatalf("Expected the same digest from both versions: %v, %v", same, err)
+	}
+
+	// Rewrapping retains the version
+	rewrapped, err := e.ReWrap(context.TODO(), provider, provider)
+	if err != nil {
+		t.Fatalf("Unexpected error rewrapping: %v", err)
+	}
+	if !bytes.HasPrefix(rewrapped, v2Prefix) {
+		t.Fatal("Expected rewrapped data to remain V2")
+	}
+	if _, err := testUnpack(rewrapped, l); err != nil {
+		t.Fatalf("Unexpected error unpacking rewrapped data: %v", err)
+	}
+
+	// Envelopes too large for an element are split in the same way as V1
+	large := &Item[Key]{Key: item.Key, Attributes: map[string]any{}}
+	for i := range 4000 {
+		large.Attributes[fmt.Sprintf("attr%d", i)] = int64(i)
+	}
+	info, l, err := testPack(large, WithPackingVersion(V2), WithMaximumKBSize(16))
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+	if isV2(info) {
+		t.Fatal("Expected a continuation descriptor")
+	}
+	if _, err := testUnpack(info, l); err != nil {
+		t.Fatalf("Unexpected error unpacking split envelope: %v", err)
+	}
+
+	if _, err := testUnpack(append(bytes.Clone(v2Prefix), 0x7f), l); !errors.Is(err, ErrInvalidDataToUnpack) {
+		t.Fatalf("Expected ErrInvalidDataToUnpack, got: %v", err)
+	}
+}
+
+func TestSplitFrames(t *testing.T) {
+
+	b := appendFrame(appendFrame(nil, []byte("abc")), make([]byte, 300))
+
+	frames, err := splitFrames(b)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(frames) != 2 || string(frames[0]) != "abc" || len(frames[1]) != 300 {
+		t.Fatalf("Unexpected frames: %v", frames)
+	}
+
+	if _, err := splitFrames(b[:len(b)-1]); !errors.Is(err, ErrInvalidDataToUnpack) {
+		t.Fatalf("Expected ErrInvalidDataToUnpack, got: %v", err)
+	}
+}
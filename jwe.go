@@ -0,0 +1,197 @@
+package packer
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+// jweHeader is the protected header of the JWE compact serialisations created by NewJWEEnvelopeKeyProvider
+type jweHeader struct {
+	Alg string `json:"alg"`
+	Enc string `json:"enc"`
+	Kid string `json:"kid"`
+}
+
+const (
+	jweAlgA256KW  = "A256KW"
+	jweEncA256GCM = "A256GCM"
+)
+
+// ErrInvalidJWE raised if an encrypted key is not a JWE compact serialisation created by NewJWEEnvelopeKeyProvider
+var ErrInvalidJWE = errors.New("invalid JWE compact serialisation")
+
+// NewJWEEnvelopeKeyProvider creates an EnvelopeKeyProvider, as NewEnvelopeKeyProvider, except that each encrypted
+// key is a standard JWE compact serialisation (alg "A256KW", enc "A256GCM", with the kid set to the EnvelopeKeyID)
+// whose payload is the data encryption key.  Services using JOSE libraries can then unwrap data encryption keys
+// (see PackEncryptedKey) with the key from the keyInfo, without implementing the serialise format.
+func NewJWEEnvelopeKeyProvider(keyInfo *EnvelopeKeyProviderInfo, finder EnveloperKeyProviderFinder) (EnvelopeKeyProvider, error) {
+
+	if keyInfo == nil {
+		return nil, ErrMissingEnvelopeKeyProviderInfo
+	}
+	if err := keyInfo.validate(); err != nil {
+		return nil, err
+	}
+	if finder == nil {
+		return nil, ErrMissingFinder
+	}
+
+	return &jweKeyProvider{
+		kek:    bytes.Clone(keyInfo.Key),
+		finder: finder,
+		id:     keyInfo.ID,
+	}, nil
+}
+
+type jweKeyProvider struct {
+	kek    []byte
+	finder EnveloperKeyProviderFinder
+	id     EnvelopeKeyID
+}
+
+func (j *jweKeyProvider) ID() EnvelopeKeyID {
+	return j.id
+}
+
+func (j *jweKeyProvider) New() ([]byte, []byte, error) {
+
+	newKey := make([]byte, 2*aes.BlockSize)
+	if _, err := rand.Read(newKey); err != nil {
+		return nil, nil, err
+	}
+
+	b, err := j.Wrap(context.Background(), newKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return b, newKey, nil
+}
+
+func (j *jweKeyProvider) Wrap(ctx context.Context, key []byte) ([]byte, error) {
+
+	header, err := json.Marshal(jweHeader{Alg: jweAlgA256KW, Enc: jweEncA256GCM, Kid: string(j.id)})
+	if err != nil {
+		return nil, err
+	}
+	protected := base64.RawURLEncoding.EncodeToString(header)
+
+	cek := make([]byte, 32)
+	if _, err := rand.Read(cek); err != nil {
+		return nil, err
+	}
+	encryptedKey, err := aesKeyWrap(j.kek, cek)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newAESGCM(cek)
+	if err != nil {
+		return nil, err
+	}
+	iv := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+
+	sealed := aead.Seal(nil, iv, key, []byte(protected))
+	ct, tag := sealed[:len(sealed)-aead.Overhead()], sealed[len(sealed)-aead.Overhead():]
+
+	enc := base64.RawURLEncoding
+	parts := [][]byte{
+		[]byte(protected),
+		[]byte(enc.EncodeToString(encryptedKey)),
+		[]byte(enc.EncodeToString(iv)),
+		[]byte(enc.EncodeToString(ct)),
+		[]byte(enc.EncodeToString(tag)),
+	}
+	return bytes.Join(parts, []byte(".")), nil
+}
+
+func (j *jweKeyProvider) Decrypt(ctx context.Context, encryptedKey []byte) ([]byte, error) {
+
+	parts := bytes.Split(encryptedKey, []byte("."))
+	if len(parts) != 5 {
+		return nil, ErrInvalidJWE
+	}
+
+	enc := base64.RawURLEncoding
+	decoded := make([][]byte, 5)
+	for i, p := range parts {
+		b, err := enc.DecodeString(string(p))
+		if err != nil {
+			return nil, ErrInvalidJWE
+		}
+		decoded[i] = b
+	}
+
+	var header jweHeader
+	if err := json.Unmarshal(decoded[0], &header); err != nil {
+		return nil, ErrInvalidJWE
+	}
+	if header.Alg != jweAlgA256KW || header.Enc != jweEncA256GCM {
+		return nil, ErrInvalidJWE
+	}
+
+	if EnvelopeKeyID(header.Kid) != j.id {
+		other, err := j.finder(EnvelopeKeyID(header.Kid))
+		if err != nil {
+			return nil, err
+		}
+		return other.Decrypt(ctx, encryptedKey)
+	}
+
+	cek, err := aesKeyUnwrap(j.kek, decoded[1])
+	if err != nil {
+		return nil, ErrKeyProviderDecryptError
+	}
+
+	aead, err := newAESGCM(cek)
+	if err != nil {
+		return nil, err
+	}
+	if len(decoded[2]) != aead.NonceSize() {
+		return nil, ErrInvalidJWE
+	}
+
+	key, err := aead.Open(nil, decoded[2], append(decoded[3], decoded[4]...), parts[0])
+	if err != nil {
+		return nil, ErrKeyProviderDecryptError
+	}
+	return key, nil
+}
+
+func newAESGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// PackEncryptedKey returns the encrypted data encryption key of data returned by Pack, as created by the
+// provider's New(), without decrypting anything.  For example, the JWE created by NewJWEEnvelopeKeyProvider
+// can be stored alongside the packed data, for services that do not use this package.  An envelope split
+// across continuation elements must first be joined (see JoinEnvelope).
+func PackEncryptedKey(data []byte) ([]byte, error) {
+
+	finalisedData, err := splitFinalisedData(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(finalisedData) < 4 {
+		return nil, ErrUnpackInvalidData
+	}
+
+	encryptedKey, ok := finalisedData[0].([]byte)
+	if !ok {
+		return nil, ErrUnpackInvalidData
+	}
+	return encryptedKey, nil
+}
@@ -0,0 +1,108 @@
+package packer
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/gford1000-go/serialise"
+)
+
+func TestNewJWEEnvelopeKeyProvider(t *testing.T) {
+
+	providers := map[EnvelopeKeyID]EnvelopeKeyProvider{}
+	finder := func(id EnvelopeKeyID) (EnvelopeKeyProvider, error) {
+		p, ok := providers[id]
+		if !ok {
+			return nil, errors.New("unknown provider id")
+		}
+		return p, nil
+	}
+
+	for _, ki := range []*EnvelopeKeyProviderInfo{
+		{ID: "jwe1", Key: []byte("01234567890123456789012345678912")},
+		{ID: "jwe2", Key: []byte("21987654321098765432109876543210")},
+	} {
+		p, err := NewJWEEnvelopeKeyProvider(ki, finder)
+		if err != nil {
+			t.Fatalf("Unexpected error creating provider: %v", err)
+		}
+		providers[ki.ID] = p
+	}
+
+	if _, err := NewJWEEnvelopeKeyProvider(nil, finder); !errors.Is(err, ErrMissingEnvelopeKeyProviderInfo) {
+		t.Fatalf("Unexpected error: expected: %v, got: %v", ErrMissingEnvelopeKeyProviderInfo, err)
+	}
+
+	serialiser, err := NewKeySerialiser()
+	if err != nil {
+		t.Fatalf("Unexpected error preparing Key serialiser: %v", err)
+	}
+
+	info, data, err := Pack(&Item[Key]{Key: Key{X: "A", Y: "B"}, Attributes: map[string]any{"aaa": "Hello World"}}, &PackParams[Key]{
+		Provider: providers["jwe1"],
+		Creator:  NewKeyCreator(defaultLen),
+		Packer:   serialiser,
+		Approach: serialise.NewMinDataApproachWithVersion(serialise.V1),
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+
+	jwe, err := PackEncryptedKey(info)
+	if err != nil {
+		t.Fatalf("Unexpected error getting encrypted key: %v", err)
+	}
+	parts := bytes.Split(jwe, []byte("."))
+	if len(parts) != 5 {
+		t.Fatalf("Expected JWE compact serialisation, got: %s", jwe)
+	}
+	b, err := base64.RawURLEncoding.DecodeString(string(parts[0]))
+	if err != nil {
+		t.Fatalf("Unexpected error decoding header: %v", err)
+	}
+	var header map[string]string
+	if err := json.Unmarshal(b, &header); err != nil {
+		t.Fatalf("Unexpected error decoding header: %v", err)
+	}
+	if header["alg"] != "A256KW" || header["enc"] != "A256GCM" || header["kid"] != "jwe1" {
+		t.Fatalf("Unexpected header: %v", header)
+	}
+
+	// Decryption is delegated to the provider identified by the kid
+	e, err := Unpack(context.TODO(), info, &UnpackParams[Key]{
+		IDRetriever: func(string) (IDSerialiser[Key], error) { return serialiser, nil },
+		Provider:    providers["jwe2"],
+		DataLoader: func(ctx context.Context, keys []Key) (map[string][]byte, error) {
+			m := map[string][]byte{}
+			for _, k := range keys {
+				for n, v := range data[k] {
+					m[n] = v
+				}
+			}
+			return m, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+	m, err := e.GetValues(context.TODO(), []string{"aaa"}, providers["jwe2"])
+	if err != nil {
+		t.Fatalf("Unexpected error getting values: %v", err)
+	}
+	if m["aaa"] != "Hello World" {
+		t.Fatalf("Unexpected value: %v", m["aaa"])
+	}
+
+	tampered := bytes.Clone(jwe)
+	tampered[len(tampered)-2] ^= 1
+	if _, err := providers["jwe1"].Decrypt(context.TODO(), tampered); err == nil {
+		t.Fatal("Expected error decrypting tampered JWE")
+	}
+	if _, err := providers["jwe1"].Decrypt(context.TODO(), []byte("a.b.c")); !errors.Is(err, ErrInvalidJWE) {
+		t.Fatalf("Unexpected error: expected: %v, got: %v", ErrInvalidJWE, err)
+	}
+}
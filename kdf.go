@@ -0,0 +1,29 @@
+package packer
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+)
+
+// hkdfSHA256 derives a key of the specified length from the secret, as described in RFC 5869
+func hkdfSHA256(secret, salt, info []byte, length int) []byte {
+
+	if len(salt) == 0 {
+		salt = make([]byte, sha256.Size)
+	}
+
+	extract := hmac.New(sha256.New, salt)
+	extract.Write(secret)
+	prk := extract.Sum(nil)
+
+	var out, t []byte
+	for i := byte(1); len(out) < length; i++ {
+		expand := hmac.New(sha256.New, prk)
+		expand.Write(t)
+		expand.Write(info)
+		expand.Write([]byte{i})
+		t = expand.Sum(nil)
+		out = append(out, t...)
+	}
+	return out[:length]
+}
@@ -0,0 +1,20 @@
+package packer
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestHKDFSHA256(t *testing.T) {
+
+	// RFC 5869, Appendix A.1
+	secret, _ := hex.DecodeString("0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b")
+	salt, _ := hex.DecodeString("000102030405060708090a0b0c")
+	info, _ := hex.DecodeString("f0f1f2f3f4f5f6f7f8f9")
+	expected, _ := hex.DecodeString("3cb25f25faacd57a90434f64d0362f2a2d2d0a90cf1a5a4c5db02d56ecc4c5bf34007208d5b887185865")
+
+	if b := hkdfSHA256(secret, salt, info, 42); !bytes.Equal(b, expected) {
+		t.Fatalf("Unexpected key: expected: %x, got: %x", expected, b)
+	}
+}
@@ -0,0 +1,56 @@
+package packer
+
+import (
+	"bytes"
+	"context"
+	"sync"
+)
+
+// dataKeyCache holds the data encryption key of an EncryptedItem once decrypted, if requested (see UnpackParams)
+type dataKeyCache struct {
+	mu  sync.Mutex
+	key []byte
+}
+
+// get returns a copy of the cached key, decrypting and caching it if not yet held
+func (c *dataKeyCache) get(decrypt func() ([]byte, error)) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.key == nil {
+		key, err := decrypt()
+		if err != nil {
+			return nil, err
+		}
+		c.key = bytes.Clone(key)
+	}
+
+	// Callers receive a copy, so that Close cannot alter a key in use
+	return bytes.Clone(c.key), nil
+}
+
+// clear zeroises and discards the cached key
+func (c *dataKeyCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	clear(c.key)
+	c.key = nil
+}
+
+// Close zeroises the data encryption key cached by the EncryptedItem, if UnpackParams.CacheDataKey was set.
+// The EncryptedItem remains usable, but the key will be decrypted by the provider again if required.
+func (e *EncryptedItem[T]) Close() error {
+	if e.keyCache != nil {
+		e.keyCache.clear()
+	}
+	return nil
+}
+
+// cachedDecryptKey returns the data encryption key of the item, from the cache if requested
+func (e *EncryptedItem[T]) cachedDecryptKey(ctx context.Context, provider EnvelopeKeyProvider) ([]byte, error) {
+	if e.keyCache == nil {
+		return e.decryptKey(ctx, provider)
+	}
+	return e.keyCache.get(func() ([]byte, error) { return e.decryptKey(ctx, provider) })
+}
@@ -0,0 +1,59 @@
+package packer
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCacheDataKey(t *testing.T) {
+
+	testPack, _, provider := testCreateEnv(t)
+
+	item := &Item[Key]{Key: Key{X: "A", Y: "B"}, Attributes: map[string]any{"a": "x"}}
+
+	info, l, err := testPack(item)
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+
+	serialiser, _ := NewKeySerialiser()
+	params := &UnpackParams[Key]{
+		DataLoader:   l,
+		IDRetriever:  func(string) (IDSerialiser[Key], error) { return serialiser, nil },
+		Provider:     provider,
+		CacheDataKey: true,
+	}
+
+	e, err := Unpack(context.TODO(), info, params)
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+
+	counter := &countingProvider{EnvelopeKeyProvider: provider}
+	for range 3 {
+		values, err := e.GetValues(context.TODO(), []string{"a"}, counter)
+		if err != nil || values["a"] != "x" {
+			t.Fatalf("Unexpected result: %v, %v", values, err)
+		}
+	}
+	if n := counter.n.Load(); n != 1 {
+		t.Fatalf("Expected the key to be decrypted once, got %d", n)
+	}
+
+	cached := e.keyCache.key
+	if err := e.Close(); err != nil {
+		t.Fatalf("Unexpected error closing: %v", err)
+	}
+	for _, b := range cached {
+		if b != 0 {
+			t.Fatal("Expected the cached key to be zeroised")
+		}
+	}
+
+	if _, err := e.GetValues(context.TODO(), []string{"a"}, counter); err != nil {
+		t.Fatalf("Unexpected error after close: %v", err)
+	}
+	if n := counter.n.Load(); n != 2 {
+		t.Fatalf("Expected the key to be decrypted again after close, got %d", n)
+	}
+}
@@ -0,0 +1,118 @@
+package packer
+
+import (
+	"errors"
+
+	"github.com/gford1000-go/serialise"
+)
+
+// The key hierarchy used by Pack is:
+//
+//	tenant KEK     held by the EnvelopeKeyProvider, wraps the item DEK
+//	  item DEK     created by the EnvelopeKeyProvider for each item, encrypts attribute values and the packing details
+//	    element    derived from the item DEK using HKDF-SHA256, with the label followed by the serialised element key
+//	               as the info (only if WithElementKeys is used)
+//
+// WithKeyHierarchy records each level in the envelope, so that the derivation of every key can be verified.
+
+// Levels of the key hierarchy
+const (
+	// KeyLevelKEK is the key encryption key of the tenant, held by the EnvelopeKeyProvider
+	KeyLevelKEK = "kek"
+	// KeyLevelDEK is the data encryption key of the item
+	KeyLevelDEK = "dek"
+	// KeyLevelElement is the subkey of each element, derived from the data encryption key
+	KeyLevelElement = "element"
+)
+
+// KeyDerivation describes how a key in the hierarchy was obtained
+type KeyDerivation struct {
+	// Level of the hierarchy that the key occupies
+	Level string
+	// Method describes how the key was obtained from the level above
+	Method string
+	// Label is the context used in the derivation
+	Label string
+}
+
+// WithKeyHierarchy records the key hierarchy used to encrypt the item in the envelope, identifying
+// the tenant whose key encryption key wraps the item's data encryption key.  If element keys are
+// used (see WithElementKeys), their HKDF label includes the tenant, so that subkeys are bound to it.
+// The recorded hierarchy is available from EncryptedItem.KeyHierarchy after Unpack.
+func WithKeyHierarchy(tenant string) func(o *Options) {
+	return func(o *Options) {
+		o.keyHierarchy = true
+		o.keyHierarchyTenant = tenant
+	}
+}
+
+// ErrInvalidDataToDeserialiseKeyHierarchy raised if the recorded key hierarchy cannot be deserialised
+var ErrInvalidDataToDeserialiseKeyHierarchy = errors.New("invalid data, cannot deserialise key hierarchy")
+
+// createKeyHierarchy describes the keys used to encrypt an item with the specified options
+func createKeyHierarchy(o *Options, provider EnvelopeKeyID) []KeyDerivation {
+
+	h := []KeyDerivation{
+		{Level: KeyLevelKEK, Method: "envelope key provider " + string(provider), Label: o.keyHierarchyTenant},
+		{Level: KeyLevelDEK, Method: "created by provider, wrapped by kek, encrypts attribute values with " + o.cipherAlgorithm.String()},
+	}
+	if o.elementKeys {
+		h = append(h, KeyDerivation{Level: KeyLevelElement, Method: "hkdf-sha256 from dek, info is label followed by element key", Label: elementKeyLabelFor(o)})
+	}
+	return h
+}
+
+// elementKeyLabelFor returns the HKDF label for element subkeys under the options
+func elementKeyLabelFor(o *Options) string {
+	if o.keyHierarchy {
+		return elementKeyLabel + o.keyHierarchyTenant + " "
+	}
+	return elementKeyLabel
+}
+
+// elementLabel returns the label recorded for element subkeys, or the default if none was recorded
+func elementLabel(h []KeyDerivation) string {
+	for _, k := range h {
+		if k.Level == KeyLevelElement {
+			return k.Label
+		}
+	}
+	return elementKeyLabel
+}
+
+func packKeyHierarchy(h []KeyDerivation, approach serialise.Approach) ([]byte, error) {
+
+	items := make([]any, 0, 3*len(h))
+	for _, k := range h {
+		items = append(items, k.Level, k.Method, k.Label)
+	}
+
+	b, _, err := serialise.ToBytesMany(items, serialise.WithSerialisationApproach(approach))
+	return b, err
+}
+
+func unpackKeyHierarchy(data []byte, approach serialise.Approach) ([]KeyDerivation, error) {
+
+	v, err := serialise.FromBytesMany(data, approach)
+	if err != nil {
+		return nil, err
+	}
+	if len(v)%3 != 0 {
+		return nil, ErrInvalidDataToDeserialiseKeyHierarchy
+	}
+
+	h := make([]KeyDerivation, 0, len(v)/3)
+	for i := 0; i < len(v); i += 3 {
+		var s [3]string
+		for j := range s {
+			x, ok := v[i+j].(string)
+			if !ok {
+				return nil, ErrInvalidDataToDeserialiseKeyHierarchy
+			}
+			s[j] = x
+		}
+		h = append(h, KeyDerivation{Level: s[0], Method: s[1], Label: s[2]})
+	}
+
+	return h, nil
+}
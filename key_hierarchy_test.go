@@ -0,0 +1,63 @@
+package packer
+
+import (
+	"context"
+	"testing"
+)
+
+func TestWithKeyHierarchy(t *testing.T) {
+
+	testPack, testUnpack, provider := testCreateEnv(t)
+
+	item := &Item[Key]{
+		Key: Key{X: "A", Y: "B"},
+		Attributes: map[string]any{
+			"aaa": "Hello World",
+		},
+	}
+
+	b, l, err := testPack(item)
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+	e, err := testUnpack(b, l)
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+	if h := e.KeyHierarchy(); h != nil {
+		t.Fatalf("Unexpected key hierarchy when not requested: %v", h)
+	}
+
+	b, l, err = testPack(item, WithKeyHierarchy("tenant1"), WithElementKeys())
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+	e, err = testUnpack(b, l)
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+
+	h := e.KeyHierarchy()
+	if len(h) != 3 {
+		t.Fatalf("Unexpected key hierarchy: %v", h)
+	}
+	for i, level := range []string{KeyLevelKEK, KeyLevelDEK, KeyLevelElement} {
+		if h[i].Level != level {
+			t.Fatalf("Unexpected level %d: expected: %s, got: %s", i, level, h[i].Level)
+		}
+	}
+	if h[0].Label != "tenant1" || h[0].Method != "envelope key provider "+string(provider.ID()) {
+		t.Fatalf("Unexpected kek derivation: %+v", h[0])
+	}
+	if h[2].Label != elementKeyLabel+"tenant1 " {
+		t.Fatalf("Unexpected element derivation: %+v", h[2])
+	}
+
+	m, err := e.GetValues(context.TODO(), []string{"aaa"}, provider)
+	if err != nil {
+		t.Fatalf("Unexpected error getting values: %v", err)
+	}
+	if m["aaa"].(string) != "Hello World" {
+		t.Fatalf("Unexpected value: %v", m["aaa"])
+	}
+}
@@ -0,0 +1,204 @@
+package packer
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gford1000-go/serialise"
+)
+
+// KeyUsagePolicy limits how a data encryption key may be used after it has been created
+type KeyUsagePolicy struct {
+	// MaxDecrypts, if not zero, is the number of times each key may be decrypted by the provider
+	MaxDecrypts uint64
+	// MaxAge, if not zero, is the period after creation within which each key may be decrypted
+	MaxAge time.Duration
+}
+
+// ErrKeyUsagePolicyViolation is matched by KeyUsageError, using errors.Is
+var ErrKeyUsagePolicyViolation = errors.New("data encryption key usage policy violated")
+
+// KeyUsageError raised if a data encryption key is used in a way that violates the KeyUsagePolicy
+type KeyUsageError struct {
+	// Reason describes the violation
+	Reason string
+	// Created is when the key was created
+	Created time.Time
+	// Decrypts is the number of times the key had been decrypted
+	Decrypts uint64
+}
+
+func (e *KeyUsageError) Error() string {
+	return fmt.Sprintf("%v: %s", ErrKeyUsagePolicyViolation, e.Reason)
+}
+
+func (e *KeyUsageError) Unwrap() error {
+	return ErrKeyUsagePolicyViolation
+}
+
+// ErrKeyCreationTimeAltered raised if the creation time recorded with a key has been altered
+var ErrKeyCreationTimeAltered = errors.New("creation time recorded with data encryption key has been altered")
+
+// NewKeyUsagePolicyProvider creates an EnvelopeKeyProvider that records the creation time with each key
+// returned by the provider, and enforces the policy each time a key is decrypted, returning a KeyUsageError
+// if the policy is violated.  The creation time is authenticated with a MAC derived from the key, so it
+// cannot be changed without being able to decrypt the key.
+// Decrypt counts are held by the returned provider, so should be shared by all callers to which the policy
+// applies.  Counts are only held if MaxDecrypts is set, and are discarded once the key is older than
+// MaxAge; if MaxAge is not set then a count is held for every key decrypted for the life of the provider.
+func NewKeyUsagePolicyProvider(provider EnvelopeKeyProvider, policy KeyUsagePolicy) (EnvelopeKeyProvider, error) {
+	if provider == nil {
+		return nil, ErrProviderIsNil
+	}
+	return &keyUsageProvider{
+		provider: provider,
+		policy:   policy,
+		decrypts: map[[sha256.Size]byte]keyUsage{},
+		now:      time.Now,
+	}, nil
+}
+
+type keyUsageProvider struct {
+	provider EnvelopeKeyProvider
+	policy   KeyUsagePolicy
+	lck      sync.Mutex
+	decrypts map[[sha256.Size]byte]keyUsage
+	sweepAt  int
+	now      func() time.Time
+}
+
+// keyUsage records the decrypts of a key, and its creation time so that the record can be discarded
+type keyUsage struct {
+	decrypts uint64
+	created  time.Time
+}
+
+// minKeyUsageSweep is the number of recorded keys below which expired records are not discarded
+const minKeyUsageSweep = 64
+
+func (k *keyUsageProvider) ID() EnvelopeKeyID {
+	return k.provider.ID()
+}
+
+func (k *keyUsageProvider) New() ([]byte, []byte, error) {
+	return k.NewWithContext(context.Background())
+}
+
+func (k *keyUsageProvider) NewWithContext(ctx context.Context) ([]byte, []byte, error) {
+
+	encryptedKey, key, err := newKey(ctx, k.provider)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	created := k.now().UnixNano()
+
+	b, _, err := serialise.ToBytesMany(
+		[]any{
+			encryptedKey,
+			created,
+			keyCreationMAC(key, encryptedKey, created),
+		}, serialise.WithSerialisationApproach(serialise.NewMinDataApproachWithVersion(serialise.V1)))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return b, key, nil
+}
+
+func (k *keyUsageProvider) Decrypt(ctx context.Context, encryptedKey []byte) ([]byte, error) {
+
+	v, err := serialise.FromBytesMany(encryptedKey, serialise.NewMinDataApproachWithVersion(serialise.V1))
+	if err != nil {
+		return nil, err
+	}
+	if len(v) != 3 {
+		return nil, ErrKeyDeserialisationError
+	}
+	inner, ok := v[0].([]byte)
+	if !ok {
+		return nil, ErrKeyDeserialisationError
+	}
+	nanos, ok := v[1].(int64)
+	if !ok {
+		return nil, ErrKeyDeserialisationError
+	}
+	mac, ok := v[2].([]byte)
+	if !ok {
+		return nil, ErrKeyDeserialisationError
+	}
+
+	key, err := k.provider.Decrypt(ctx, inner)
+	if err != nil {
+		return nil, err
+	}
+
+	// The creation time is checked against the key before the policy is applied, so it cannot be extended
+	if !hmac.Equal(mac, keyCreationMAC(key, inner, nanos)) {
+		clear(key)
+		return nil, ErrKeyCreationTimeAltered
+	}
+
+	if err := k.use(sha256.Sum256(inner), time.Unix(0, nanos)); err != nil {
+		clear(key)
+		return nil, err
+	}
+
+	return key, nil
+}
+
+var keyCreationLabel = []byte("packer key creation")
+
+// keyCreationMAC binds the creation time to the encrypted key, using a key derived from the data encryption key
+func keyCreationMAC(key, encryptedKey []byte, created int64) []byte {
+	h := hmac.New(sha256.New, hkdfSHA256(key, nil, keyCreationLabel, sha256.Size))
+	h.Write(binary.BigEndian.AppendUint64(nil, uint64(len(encryptedKey))))
+	h.Write(encryptedKey)
+	h.Write(binary.BigEndian.AppendUint64(nil, uint64(created)))
+	return h.Sum(nil)
+}
+
+// use records a decrypt of the key, returning a KeyUsageError if this violates the policy
+func (k *keyUsageProvider) use(id [sha256.Size]byte, created time.Time) error {
+
+	k.lck.Lock()
+	defer k.lck.Unlock()
+
+	now := k.now()
+	decrypts := k.decrypts[id].decrypts
+
+	if k.policy.MaxAge > 0 && now.Sub(created) > k.policy.MaxAge {
+		delete(k.decrypts, id)
+		return &KeyUsageError{Reason: fmt.Sprintf("key is older than %v", k.policy.MaxAge), Created: created, Decrypts: decrypts}
+	}
+	if k.policy.MaxDecrypts == 0 {
+		return nil
+	}
+	if decrypts >= k.policy.MaxDecrypts {
+		return &KeyUsageError{Reason: fmt.Sprintf("key has been decrypted %d times", decrypts), Created: created, Decrypts: decrypts}
+	}
+
+	k.decrypts[id] = keyUsage{decrypts: decrypts + 1, created: created}
+	k.sweep(now)
+	return nil
+}
+
+// sweep discards the records of keys older than MaxAge, which can no longer be decrypted.  Sweeps are
+// made each time the number of records doubles, so that their cost is spread across the decrypts
+func (k *keyUsageProvider) sweep(now time.Time) {
+	if k.policy.MaxAge == 0 || len(k.decrypts) < max(k.sweepAt, minKeyUsageSweep) {
+		return
+	}
+	for id, u := range k.decrypts {
+		if now.Sub(u.created) > k.policy.MaxAge {
+			delete(k.decrypts, id)
+		}
+	}
+	k.sweepAt = 2 * len(k.decrypts)
+}
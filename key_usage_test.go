@@ -0,0 +1,165 @@
+package packer
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gford1000-go/serialise"
+)
+
+func TestNewKeyUsagePolicyProvider(t *testing.T) {
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatalf("Unexpected error creating key: %v", err)
+	}
+	finder := func(EnvelopeKeyID) (EnvelopeKeyProvider, error) {
+		return nil, errors.New("unknown provider id")
+	}
+	inner, err := NewEnvelopeKeyProvider(&EnvelopeKeyProviderInfo{ID: "inner", Key: key}, finder)
+	if err != nil {
+		t.Fatalf("Unexpected error creating provider: %v", err)
+	}
+
+	if _, err := NewKeyUsagePolicyProvider(nil, KeyUsagePolicy{}); !errors.Is(err, ErrProviderIsNil) {
+		t.Fatalf("Unexpected error: expected: %v, got: %v", ErrProviderIsNil, err)
+	}
+
+	p, err := NewKeyUsagePolicyProvider(inner, KeyUsagePolicy{MaxDecrypts: 2, MaxAge: time.Hour})
+	if err != nil {
+		t.Fatalf("Unexpected error creating provider: %v", err)
+	}
+
+	now := time.Now()
+	p.(*keyUsageProvider).now = func() time.Time { return now }
+
+	encryptedKey, dek, err := p.New()
+	if err != nil {
+		t.Fatalf("Unexpected error creating key: %v", err)
+	}
+
+	for i := range 2 {
+		k, err := p.Decrypt(context.TODO(), encryptedKey)
+		if err != nil {
+			t.Fatalf("(%d) Unexpected error decrypting key: %v", i, err)
+		}
+		if string(k) != string(dek) {
+			t.Fatalf("(%d) Mismatch in decrypted key", i)
+		}
+	}
+
+	var usageErr *KeyUsageError
+	if _, err := p.Decrypt(context.TODO(), encryptedKey); !errors.As(err, &usageErr) || usageErr.Decrypts != 2 {
+		t.Fatalf("Unexpected error: expected KeyUsageError after 2 decrypts, got: %v", err)
+	}
+
+	other, _, err := p.New()
+	if err != nil {
+		t.Fatalf("Unexpected error creating key: %v", err)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if _, err := p.Decrypt(context.TODO(), other); !errors.Is(err, ErrKeyUsagePolicyViolation) {
+		t.Fatalf("Unexpected error: expected: %v, got: %v", ErrKeyUsagePolicyViolation, err)
+	}
+}
+
+func testKeyUsageProvider(t *testing.T, policy KeyUsagePolicy) *keyUsageProvider {
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatalf("Unexpected error creating key: %v", err)
+	}
+	finder := func(EnvelopeKeyID) (EnvelopeKeyProvider, error) {
+		return nil, errors.New("unknown provider id")
+	}
+	inner, err := NewEnvelopeKeyProvider(&EnvelopeKeyProviderInfo{ID: "inner", Key: key}, finder)
+	if err != nil {
+		t.Fatalf("Unexpected error creating provider: %v", err)
+	}
+
+	p, err := NewKeyUsagePolicyProvider(inner, policy)
+	if err != nil {
+		t.Fatalf("Unexpected error creating provider: %v", err)
+	}
+	return p.(*keyUsageProvider)
+}
+
+func TestNewKeyUsagePolicyProvider_1(t *testing.T) {
+
+	p := testKeyUsageProvider(t, KeyUsagePolicy{MaxAge: time.Hour})
+
+	now := time.Now()
+	p.now = func() time.Time { return now }
+
+	encryptedKey, _, err := p.New()
+	if err != nil {
+		t.Fatalf("Unexpected error creating key: %v", err)
+	}
+
+	now = now.Add(2 * time.Hour)
+
+	// Moving the creation time forward must not extend the life of the key
+	v, err := serialise.FromBytesMany(encryptedKey, serialise.NewMinDataApproachWithVersion(serialise.V1))
+	if err != nil {
+		t.Fatalf("Unexpected error deserialising key: %v", err)
+	}
+	v[1] = now.UnixNano()
+	altered, _, err := serialise.ToBytesMany(v, serialise.WithSerialisationApproach(serialise.NewMinDataApproachWithVersion(serialise.V1)))
+	if err != nil {
+		t.Fatalf("Unexpected error serialising key: %v", err)
+	}
+
+	if _, err := p.Decrypt(context.TODO(), altered); !errors.Is(err, ErrKeyCreationTimeAltered) {
+		t.Fatalf("Unexpected error: expected: %v, got: %v", ErrKeyCreationTimeAltered, err)
+	}
+	if _, err := p.Decrypt(context.TODO(), encryptedKey); !errors.Is(err, ErrKeyUsagePolicyViolation) {
+		t.Fatalf("Unexpected error: expected: %v, got: %v", ErrKeyUsagePolicyViolation, err)
+	}
+}
+
+func TestNewKeyUsagePolicyProvider_2(t *testing.T) {
+
+	p := testKeyUsageProvider(t, KeyUsagePolicy{MaxDecrypts: 1, MaxAge: time.Hour})
+
+	now := time.Now()
+	p.now = func() time.Time { return now }
+
+	decrypt := func(n int) {
+		for range n {
+			encryptedKey, _, err := p.New()
+			if err != nil {
+				t.Fatalf("Unexpected error creating key: %v", err)
+			}
+			if _, err := p.Decrypt(context.TODO(), encryptedKey); err != nil {
+				t.Fatalf("Unexpected error decrypting key: %v", err)
+			}
+		}
+	}
+
+	decrypt(minKeyUsageSweep - 1)
+
+	// Records of keys that have passed MaxAge are discarded, rather than held for the life of the provider
+	now = now.Add(2 * time.Hour)
+	decrypt(1)
+
+	if len(p.decrypts) != 1 {
+		t.Fatalf("Unexpected number of keys recorded: expected: 1, got: %d", len(p.decrypts))
+	}
+
+	// Without MaxDecrypts, no records are needed
+	q := testKeyUsageProvider(t, KeyUsagePolicy{MaxAge: time.Hour})
+	encryptedKey, _, err := q.New()
+	if err != nil {
+		t.Fatalf("Unexpected error creating key: %v", err)
+	}
+	if _, err := q.Decrypt(context.TODO(), encryptedKey); err != nil {
+		t.Fatalf("Unexpected error decrypting key: %v", err)
+	}
+	if len(q.decrypts) != 0 {
+		t.Fatalf("Unexpected number of keys recorded: expected: 0, got: %d", len(q.decrypts))
+	}
+}
@@ -0,0 +1,78 @@
+package packer
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/hmac"
+	"encoding/binary"
+	"errors"
+)
+
+// ErrInvalidKeyWrapData raised if a key to be wrapped, or a wrapped key, is not a multiple of 64 bits of sufficient length
+var ErrInvalidKeyWrapData = errors.New("invalid key wrap data - must be a multiple of 64 bits")
+
+// aesKeyWrapIV is the default initial value of RFC 3394
+var aesKeyWrapIV = []byte{0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6}
+
+// aesKeyWrap wraps the key using the key encryption key, as described in RFC 3394
+func aesKeyWrap(kek, key []byte) ([]byte, error) {
+
+	if len(key)%8 != 0 || len(key) < 16 {
+		return nil, ErrInvalidKeyWrapData
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(key) / 8
+	a := bytes.Clone(aesKeyWrapIV)
+	r := bytes.Clone(key)
+	buf := make([]byte, 16)
+
+	for j := range 6 {
+		for i := range n {
+			copy(buf, a)
+			copy(buf[8:], r[8*i:8*i+8])
+			block.Encrypt(buf, buf)
+			t := uint64(n*j + i + 1)
+			binary.BigEndian.PutUint64(a, binary.BigEndian.Uint64(buf[:8])^t)
+			copy(r[8*i:], buf[8:])
+		}
+	}
+
+	return append(a, r...), nil
+}
+
+// aesKeyUnwrap is the inverse of aesKeyWrap, returning an error if the integrity check fails
+func aesKeyUnwrap(kek, wrapped []byte) ([]byte, error) {
+
+	if len(wrapped)%8 != 0 || len(wrapped) < 24 {
+		return nil, ErrInvalidKeyWrapData
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(wrapped)/8 - 1
+	a := bytes.Clone(wrapped[:8])
+	r := bytes.Clone(wrapped[8:])
+	buf := make([]byte, 16)
+
+	for j := 5; j >= 0; j-- {
+		for i := n - 1; i >= 0; i-- {
+			t := uint64(n*j + i + 1)
+			binary.BigEndian.PutUint64(buf, binary.BigEndian.Uint64(a)^t)
+			copy(buf[8:], r[8*i:8*i+8])
+			block.Decrypt(buf, buf)
+			copy(a, buf[:8])
+			copy(r[8*i:], buf[8:])
+		}
+	}
+
+	if !hmac.Equal(a, aesKeyWrapIV) {
+		return nil, ErrKeyProviderDecryptError
+	}
+	return r, nil
+}
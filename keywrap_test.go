@@ -0,0 +1,37 @@
+package packer
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func TestAESKeyWrap(t *testing.T) {
+
+	// RFC 3394, section 4.6: wrap 256 bits of key data with a 256-bit KEK
+	kek, _ := hex.DecodeString("000102030405060708090A0B0C0D0E0F101112131415161718191A1B1C1D1E1F")
+	key, _ := hex.DecodeString("00112233445566778899AABBCCDDEEFF000102030405060708090A0B0C0D0E0F")
+	expected, _ := hex.DecodeString("28C9F404C4B810F4CBCCB35CFB87F8263F5786E2D80ED326CBC7F0E71A99F43BFB988B9B7A02DD21")
+
+	wrapped, err := aesKeyWrap(kek, key)
+	if err != nil {
+		t.Fatalf("Unexpected error wrapping: %v", err)
+	}
+	if !bytes.Equal(wrapped, expected) {
+		t.Fatalf("Unexpected wrapped key: %x", wrapped)
+	}
+
+	unwrapped, err := aesKeyUnwrap(kek, wrapped)
+	if err != nil {
+		t.Fatalf("Unexpected error unwrapping: %v", err)
+	}
+	if !bytes.Equal(unwrapped, key) {
+		t.Fatalf("Unexpected unwrapped key: %x", unwrapped)
+	}
+
+	wrapped[0] ^= 1
+	if _, err := aesKeyUnwrap(kek, wrapped); !errors.Is(err, ErrKeyProviderDecryptError) {
+		t.Fatalf("Unexpected error: expected: %v, got: %v", ErrKeyProviderDecryptError, err)
+	}
+}
@@ -0,0 +1,47 @@
+package packer
+
+import (
+	"context"
+	"log/slog"
+)
+
+// WithLogger reports key lifecycle events during Pack to the logger at debug level.
+// No attribute values, attribute names or key material are ever logged.
+func WithLogger(logger *slog.Logger) func(o *Options) {
+	return func(o *Options) {
+		o.logger = logger
+	}
+}
+
+// discardHandler is used when no logger is provided, so that logging calls are always safe
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (d discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return d }
+func (d discardHandler) WithGroup(string) slog.Handler           { return d }
+
+// loggerOrDefault ensures a logger is always available
+func loggerOrDefault(logger *slog.Logger) *slog.Logger {
+	if logger == nil {
+		return slog.New(discardHandler{})
+	}
+	return logger
+}
+
+// loggedDataLoader reports each call to the DataLoader, and any failure
+func loggedDataLoader[T comparable](logger *slog.Logger, loader DataLoader[T]) DataLoader[T] {
+	return func(ctx context.Context, keys []T) (map[string][]byte, error) {
+		logger.DebugContext(ctx, "packer: loading elements", slog.Int("elements", len(keys)))
+		m, err := loader(ctx, keys)
+		if err != nil {
+			logger.DebugContext(ctx, "packer: element loading failed", slog.Int("elements", len(keys)), slog.String("error", err.Error()))
+		}
+		return m, err
+	}
+}
+
+// log returns the logger for the Options, which is always safe to use
+func (o *Options) log() *slog.Logger {
+	return loggerOrDefault(o.logger)
+}
@@ -0,0 +1,62 @@
+package packer
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestWithLogger(t *testing.T) {
+
+	testPack, _, _ := testCreateEnv(t)
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	// Random data is incompressible, so is guaranteed to be chunked
+	secret := make([]byte, 100*1024)
+	if _, err := rand.Read(secret); err != nil {
+		t.Fatalf("Unexpected error creating data: %v", err)
+	}
+
+	item := &Item[Key]{
+		Key: Key{X: "A", Y: "B"},
+		Attributes: map[string]any{
+			"secretAttribute": secret,
+		},
+	}
+
+	b, l, err := testPack(item, WithLogger(logger), WithMaximumKBSize(40), WithAttributeValueMaximumKBSize(40))
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+
+	for _, event := range []string{"data encryption key created", "chunking triggered", "overflowing to new element"} {
+		if !strings.Contains(buf.String(), event) {
+			t.Fatalf("Expected event '%s' to be logged, got: %s", event, buf.String())
+		}
+	}
+
+	serialiser, _ := NewKeySerialiser()
+	_, _, provider := testCreateEnv(t)
+
+	params := &UnpackParams[Key]{
+		DataLoader:  l,
+		IDRetriever: func(string) (IDSerialiser[Key], error) { return serialiser, nil },
+		Provider:    provider,
+		Logger:      logger,
+	}
+	if _, err := Unpack(context.TODO(), b, params); err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+	if !strings.Contains(buf.String(), "loading elements") {
+		t.Fatalf("Expected element loading to be logged, got: %s", buf.String())
+	}
+
+	if strings.Contains(buf.String(), "secretAttribute") {
+		t.Fatal("Attribute data must not be logged")
+	}
+}
@@ -0,0 +1,86 @@
+package packer
+
+import "reflect"
+
+// WithMemoryBudget limits the working memory used by Pack to serialise, compress and encrypt attributes
+// to approximately the specified number of bytes.  Attributes are processed in waves, in name order,
+// whose estimated working size fits within the budget (a single attribute larger than the budget is
+// processed alone), so that concurrency (see WithConcurrency) cannot cause all attributes to be
+// materialised at once.  The packed output is not included in the budget, as it is returned by Pack.
+// If not set, all attributes are processed in a single wave.
+func WithMemoryBudget(bytes uint64) func(o *Options) {
+	return func(o *Options) {
+		o.memoryBudget = bytes
+	}
+}
+
+// workingSizeFactor approximates the copies of a value held whilst it is serialised, compressed and encrypted
+const workingSizeFactor = 3
+
+// memoryWaves groups the indices of the names into waves whose estimated working size fits within the budget
+func memoryWaves(names []string, attrs map[string]any, budget uint64) [][]int {
+
+	if budget == 0 {
+		wave := make([]int, len(names))
+		for i := range names {
+			wave[i] = i
+		}
+		return [][]int{wave}
+	}
+
+	var waves [][]int
+	var wave []int
+	var size uint64
+	for i, name := range names {
+		s := workingSizeFactor * estimateAttributeSize(attrs[name])
+		if len(wave) > 0 && size+s > budget {
+			waves = append(waves, wave)
+			wave, size = nil, 0
+		}
+		wave = append(wave, i)
+		size += s
+	}
+	if len(wave) > 0 {
+		waves = append(waves, wave)
+	}
+	return waves
+}
+
+// estimateAttributeSize approximates the size of the value once serialised
+func estimateAttributeSize(v any) uint64 {
+	const minimum = 64
+
+	switch x := v.(type) {
+	case []byte:
+		return uint64(len(x)) + minimum
+	case string:
+		return uint64(len(x)) + minimum
+	case []string:
+		size := uint64(minimum)
+		for _, s := range x {
+			size += uint64(len(s)) + 8
+		}
+		return size
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		return uint64(rv.Len())*uint64(rv.Type().Elem().Size()) + minimum
+	}
+	return minimum
+}
+
+// runInWaves calls f for the indices of each wave in turn, with at most limit calls in progress,
+// completing each wave before the next is started
+func runInWaves(waves [][]int, limit int, f func(i int) error) error {
+	for _, wave := range waves {
+		err := runConcurrently(len(wave), limit, func(i int) error {
+			return f(wave[i])
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
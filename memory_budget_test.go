@@ -0,0 +1,67 @@
+package packer
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMemoryWaves(t *testing.T) {
+
+	attrs := map[string]any{
+		"a": strings.Repeat("a", 1000),
+		"b": strings.Repeat("b", 1000),
+		"c": strings.Repeat("c", 5000),
+		"d": int64(1),
+		"e": []float64{1, 2, 3},
+	}
+	names := []string{"a", "b", "c", "d", "e"}
+
+	tests := []struct {
+		budget   uint64
+		expected [][]int
+	}{
+		{budget: 0, expected: [][]int{{0, 1, 2, 3, 4}}},
+		{budget: 100000, expected: [][]int{{0, 1, 2, 3, 4}}},
+		{budget: 7000, expected: [][]int{{0, 1}, {2}, {3, 4}}},
+		{budget: 1, expected: [][]int{{0}, {1}, {2}, {3}, {4}}},
+	}
+
+	for i, test := range tests {
+		if waves := memoryWaves(names, attrs, test.budget); !reflect.DeepEqual(waves, test.expected) {
+			t.Fatalf("(%d) Unexpected waves: expected: %v, got: %v", i, test.expected, waves)
+		}
+	}
+}
+
+func TestWithMemoryBudget(t *testing.T) {
+
+	packer, unpacker, provider := testCreateEnv(t)
+
+	attrs := map[string]any{}
+	names := []string{}
+	for i := range 20 {
+		name := fmt.Sprintf("attr%02d", i)
+		attrs[name] = strings.Repeat(name, 1000)
+		names = append(names, name)
+	}
+
+	info, loader, err := packer(&Item[Key]{Key: Key{X: "A", Y: "B"}, Attributes: attrs}, WithMemoryBudget(30*1024), WithConcurrency(8), WithCompression(FlateCompression))
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+
+	e, err := unpacker(info, loader)
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+	m, err := e.GetValues(context.TODO(), names, provider)
+	if err != nil {
+		t.Fatalf("Unexpected error getting values: %v", err)
+	}
+	if !reflect.DeepEqual(m, attrs) {
+		t.Fatal("Mismatch in unpacked attributes")
+	}
+}
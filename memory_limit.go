@@ -0,0 +1,107 @@
+package packer
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+)
+
+// ErrMemoryLimitExceeded is matched by MemoryLimitError, using errors.Is
+var ErrMemoryLimitExceeded = errors.New("memory limit exceeded")
+
+// MemoryLimitError raised if Unpack, or GetValues on the unpacked item, would exceed the MemoryLimit
+// specified in the UnpackParams
+type MemoryLimitError struct {
+	// Limit is the MemoryLimit of the UnpackParams
+	Limit uint64
+	// Required is the memory that would have been in use had the operation continued
+	Required uint64
+}
+
+func (e *MemoryLimitError) Error() string {
+	return fmt.Sprintf("%v: %d bytes required, limit is %d bytes", ErrMemoryLimitExceeded, e.Required, e.Limit)
+}
+
+func (e *MemoryLimitError) Unwrap() error {
+	return ErrMemoryLimitExceeded
+}
+
+// memoryTracker accounts for the memory held by an item, against a limit; a nil tracker is unlimited
+type memoryTracker struct {
+	limit uint64
+	used  atomic.Uint64
+}
+
+func newMemoryTracker(limit uint64) *memoryTracker {
+	if limit == 0 {
+		return nil
+	}
+	return &memoryTracker{limit: limit}
+}
+
+// reserve accounts for n bytes, failing if the limit would be exceeded
+func (m *memoryTracker) reserve(n uint64) error {
+	if m == nil {
+		return nil
+	}
+	used := m.used.Add(n)
+	if used > m.limit {
+		m.used.Add(-n)
+		return &MemoryLimitError{Limit: m.limit, Required: used}
+	}
+	return nil
+}
+
+// release returns n bytes previously reserved
+func (m *memoryTracker) release(n uint64) {
+	if m == nil {
+		return
+	}
+	m.used.Add(-n)
+}
+
+// available returns the bytes that may still be reserved, with ok false if there is no limit
+func (m *memoryTracker) available() (n uint64, ok bool) {
+	if m == nil {
+		return 0, false
+	}
+	used := m.used.Load()
+	if used >= m.limit {
+		return 0, true
+	}
+	return m.limit - used, true
+}
+
+// decompressWithin decompresses the data, failing with a MemoryLimitError rather than exceeding the
+// memory available to the tracker, so that highly compressible hostile values cannot exhaust memory
+func (m *memoryTracker) decompressWithin(compression Compression, data []byte) ([]byte, error) {
+
+	available, ok := m.available()
+	if !ok || compression != FlateCompression {
+		return decompress(compression, data)
+	}
+
+	r := flate.NewReader(bytes.NewReader(data))
+	defer r.Close()
+
+	b, err := io.ReadAll(io.LimitReader(r, int64(available)+1))
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(b)) > available {
+		return nil, &MemoryLimitError{Limit: m.limit, Required: m.used.Load() + uint64(len(b))}
+	}
+	return b, nil
+}
+
+// dataSize returns the total size of the values of the data
+func dataSize(data map[string][]byte) uint64 {
+	var n uint64
+	for _, v := range data {
+		n += uint64(len(v))
+	}
+	return n
+}
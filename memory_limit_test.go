@@ -0,0 +1,83 @@
+package packer
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestUnpackMemoryLimit(t *testing.T) {
+
+	testPack, _, provider := testCreateEnv(t)
+
+	item := &Item[Key]{
+		Key:        Key{X: "A", Y: "B"},
+		Attributes: map[string]any{},
+	}
+	for i := range 4 {
+		b := make([]byte, 8*1024)
+		if _, err := rand.Read(b); err != nil {
+			t.Fatalf("Unexpected error creating data: %v", err)
+		}
+		item.Attributes[fmt.Sprintf("attr%d", i)] = b
+	}
+
+	info, l, err := testPack(item)
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+
+	serialiser, _ := NewKeySerialiser()
+	params := &UnpackParams[Key]{
+		DataLoader:  l,
+		IDRetriever: func(string) (IDSerialiser[Key], error) { return serialiser, nil },
+		Provider:    provider,
+		MemoryLimit: 16 * 1024,
+	}
+
+	_, err = Unpack(context.TODO(), info, params)
+	var limitErr *MemoryLimitError
+	if !errors.As(err, &limitErr) || !errors.Is(err, ErrMemoryLimitExceeded) {
+		t.Fatalf("Expected MemoryLimitError, got: %v", err)
+	}
+	if limitErr.Limit != params.MemoryLimit || limitErr.Required <= params.MemoryLimit {
+		t.Fatalf("Unexpected error details: %+v", limitErr)
+	}
+
+	// Loaded data is released once reassembled, leaving room to decode a value
+	params.MemoryLimit = 80 * 1024
+	e, err := Unpack(context.TODO(), info, params)
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+	if _, err := e.GetValues(context.TODO(), []string{"attr0"}, provider); err != nil {
+		t.Fatalf("Unexpected error getting values: %v", err)
+	}
+	if used := e.memory.used.Load(); used == 0 || used > 40*1024 {
+		t.Fatalf("Expected only reassembled values to remain reserved, got %d bytes", used)
+	}
+}
+
+func TestMemoryTrackerDecompressWithin(t *testing.T) {
+
+	data, err := compress(FlateCompression, make([]byte, 1024*1024))
+	if err != nil {
+		t.Fatalf("Unexpected error compressing: %v", err)
+	}
+
+	if _, err := newMemoryTracker(1024).decompressWithin(FlateCompression, data); !errors.Is(err, ErrMemoryLimitExceeded) {
+		t.Fatalf("Expected ErrMemoryLimitExceeded, got: %v", err)
+	}
+
+	b, err := newMemoryTracker(2*1024*1024).decompressWithin(FlateCompression, data)
+	if err != nil || len(b) != 1024*1024 {
+		t.Fatalf("Unexpected result: %d bytes, %v", len(b), err)
+	}
+
+	var unlimited *memoryTracker
+	if b, err := unlimited.decompressWithin(FlateCompression, data); err != nil || len(b) != 1024*1024 {
+		t.Fatalf("Unexpected result without a limit: %d bytes, %v", len(b), err)
+	}
+}
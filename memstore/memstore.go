@@ -0,0 +1,109 @@
+// Package memstore provides an in-memory packer.Store, for use in tests and examples
+package memstore
+
+import (
+	"bytes"
+	"context"
+	"sync"
+
+	"github.com/gford1000-go/packer"
+)
+
+// Store holds packed items in memory, and is safe for concurrent use.  Data is copied as it is
+// stored and loaded, so that callers cannot alter the stored data.
+type Store[T comparable] struct {
+	mu       sync.RWMutex
+	infos    map[T][]byte
+	elements map[T]map[string][]byte
+}
+
+var _ packer.Store[string] = &Store[string]{}
+
+// New returns an empty Store
+func New[T comparable]() *Store[T] {
+	return &Store[T]{infos: map[T][]byte{}, elements: map[T]map[string][]byte{}}
+}
+
+// Load is a packer.DataLoader, returning the chunks of the elements with the keys that are present
+func (s *Store[T]) Load(ctx context.Context, keys []T) (map[string][]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	m := map[string][]byte{}
+	for _, k := range keys {
+		for name, v := range s.elements[k] {
+			m[name] = bytes.Clone(v)
+		}
+	}
+	return m, nil
+}
+
+// Write stores the output of Pack: the elements, and the packed data of the item with the key
+func (s *Store[T]) Write(ctx context.Context, key T, info []byte, data map[T]map[string][]byte) error {
+	if err := s.PutAttrs(ctx, data); err != nil {
+		return err
+	}
+	return s.PutInfo(ctx, key, info)
+}
+
+// PutInfo stores the packed data of the item with the key
+func (s *Store[T]) PutInfo(ctx context.Context, key T, info []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.infos[key] = bytes.Clone(info)
+	return nil
+}
+
+// PutAttrs stores the chunks of each element, replacing any existing chunks of the elements
+func (s *Store[T]) PutAttrs(ctx context.Context, data map[T]map[string][]byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for k, chunks := range data {
+		m := make(map[string][]byte, len(chunks))
+		for name, v := range chunks {
+			m[name] = bytes.Clone(v)
+		}
+		s.elements[k] = m
+	}
+	return nil
+}
+
+// GetInfo returns the packed data of the item with the key, raising packer.ErrItemNotInStore if not present
+func (s *Store[T]) GetInfo(ctx context.Context, key T) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	info, ok := s.infos[key]
+	if !ok {
+		return nil, packer.ErrItemNotInStore
+	}
+	return bytes.Clone(info), nil
+}
+
+// BatchGetAttrs is equivalent to Load
+func (s *Store[T]) BatchGetAttrs(ctx context.Context, keys []T) (map[string][]byte, error) {
+	return s.Load(ctx, keys)
+}
+
+// Delete removes the packed data of the item with the key, and the elements with the element keys
+// (see packer.GetElementKeys)
+func (s *Store[T]) Delete(ctx context.Context, key T, elementKeys ...T) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.infos, key)
+	for _, k := range elementKeys {
+		delete(s.elements, k)
+	}
+	return nil
+}
+
+// Len returns the number of items and elements held
+func (s *Store[T]) Len() (items, elements int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.infos), len(s.elements)
+}
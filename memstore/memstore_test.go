@@ -0,0 +1,67 @@
+package memstore
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"testing"
+
+	"github.com/gford1000-go/packer"
+	"github.com/gford1000-go/serialise"
+)
+
+func TestStore(t *testing.T) {
+
+	ki := &packer.EnvelopeKeyProviderInfo{ID: "Key1", Key: []byte("01234567890123456789012345678912")}
+	provider, err := packer.NewEnvelopeKeyProvider(ki, func(packer.EnvelopeKeyID) (packer.EnvelopeKeyProvider, error) { return nil, errors.New("unknown") })
+	if err != nil {
+		t.Fatalf("Unexpected error creating provider: %v", err)
+	}
+	serialiser, _ := packer.NewKeySerialiser()
+
+	store := New[packer.Key]()
+
+	item := &packer.Item[packer.Key]{Key: packer.Key{X: "A", Y: "B"}, Attributes: map[string]any{"a": "x", "b": int64(2)}}
+
+	pParams := &packer.PackParams[packer.Key]{
+		Provider: provider,
+		Creator:  packer.NewKeyCreator(10),
+		Packer:   serialiser,
+		Approach: serialise.NewMinDataApproachWithVersion(serialise.V1),
+	}
+	info, err := packer.PackInto(context.TODO(), store, item, pParams)
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+
+	uParams := &packer.UnpackParams[packer.Key]{
+		DataLoader:  store.Load,
+		IDRetriever: func(string) (packer.IDSerialiser[packer.Key], error) { return serialiser, nil },
+		Provider:    provider,
+	}
+	e, err := packer.UnpackFromStore(context.TODO(), store, item.Key, uParams)
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+	values, err := e.GetAllValues(context.TODO(), provider)
+	if err != nil {
+		t.Fatalf("Unexpected error getting values: %v", err)
+	}
+	if !maps.Equal(values, item.Attributes) {
+		t.Fatalf("Mismatch: expected %v, got %v", item.Attributes, values)
+	}
+
+	keys, err := packer.GetElementKeys(context.TODO(), info, uParams)
+	if err != nil {
+		t.Fatalf("Unexpected error getting element keys: %v", err)
+	}
+	if err := store.Delete(context.TODO(), item.Key, keys...); err != nil {
+		t.Fatalf("Unexpected error deleting: %v", err)
+	}
+	if items, elements := store.Len(); items != 0 || elements != 0 {
+		t.Fatalf("Expected an empty store, got %d items and %d elements", items, elements)
+	}
+	if _, err := store.GetInfo(context.TODO(), item.Key); !errors.Is(err, packer.ErrItemNotInStore) {
+		t.Fatalf("Expected ErrItemNotInStore, got: %v", err)
+	}
+}
@@ -0,0 +1,335 @@
+package packer
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// WithMerkleRoot records the root of a Merkle tree over every stored attribute chunk in the
+// envelope, so that the complete item can be verified against the contents of storage with
+// VerifyMerkleRoot, detecting any substituted, altered, added or missing chunk.  Chunks of
+// parity elements are included; replicas are not, as they are copies of the elements.
+func WithMerkleRoot() func(o *Options) {
+	return func(o *Options) {
+		o.merkleRoot = true
+	}
+}
+
+// ErrNoMerkleRoot raised if the envelope does not record a Merkle root
+var ErrNoMerkleRoot = errors.New("packed data does not include a merkle root")
+
+// ErrMerkleRootMismatch raised if the stored chunks of an item do not match its recorded Merkle root
+var ErrMerkleRootMismatch = errors.New("stored chunks do not match the merkle root of the item")
+
+// merkleLeaf is a stored chunk, identified by its stored attribute name
+type merkleLeaf struct {
+	name  string
+	value []byte
+}
+
+// merkleLeaves returns the chunks held by the elements, ordered by name
+func merkleLeaves[T comparable](elements []T, output map[T]map[string][]byte) []merkleLeaf {
+	leaves := []merkleLeaf{}
+	for _, t := range elements {
+		for name, v := range output[t] {
+			leaves = append(leaves, merkleLeaf{name: name, value: v})
+		}
+	}
+	sort.Slice(leaves, func(i, j int) bool { return leaves[i].name < leaves[j].name })
+	return leaves
+}
+
+// merkleLeafHash hashes the chunk, with a prefix distinguishing leaves from interior nodes (RFC 6962)
+func merkleLeafHash(l merkleLeaf) []byte {
+	h := sha256.New()
+	h.Write([]byte{0})
+	var n [4]byte
+	binary.BigEndian.PutUint32(n[:], uint32(len(l.name)))
+	h.Write(n[:])
+	h.Write([]byte(l.name))
+	h.Write(l.value)
+	return h.Sum(nil)
+}
+
+func merkleNodeHash(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{1})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// merkleRoot returns the root of the tree over the leaves, constructed as in RFC 6962
+func merkleRoot(leaves []merkleLeaf) []byte {
+	hashes := make([][]byte, len(leaves))
+	for i, l := range leaves {
+		hashes[i] = merkleLeafHash(l)
+	}
+	return merkleTreeHash(hashes)
+}
+
+func merkleTreeHash(hashes [][]byte) []byte {
+	switch len(hashes) {
+	case 0:
+		h := sha256.Sum256(nil)
+		return h[:]
+	case 1:
+		return hashes[0]
+	}
+	k := merkleSplit(len(hashes))
+	return merkleNodeHash(merkleTreeHash(hashes[:k]), merkleTreeHash(hashes[k:]))
+}
+
+// merkleSplit returns the largest power of two less than n
+func merkleSplit(n int) int {
+	k := 1
+	for k<<1 < n {
+		k <<= 1
+	}
+	return k
+}
+
+// merkleAuditPath returns the hashes needed to recompute the root from the m'th leaf, ordered from the
+// leaf upwards, constructed as in RFC 6962
+func merkleAuditPath(m int, hashes [][]byte) [][]byte {
+	if len(hashes) < 2 {
+		return nil
+	}
+	k := merkleSplit(len(hashes))
+	if m < k {
+		return append(merkleAuditPath(m, hashes[:k]), merkleTreeHash(hashes[k:]))
+	}
+	return append(merkleAuditPath(m-k, hashes[k:]), merkleTreeHash(hashes[:k]))
+}
+
+// MerkleProof demonstrates that a stored chunk is part of a packed item, given only the item's Merkle root
+type MerkleProof struct {
+	// Chunk is the stored attribute name of the chunk
+	Chunk string
+	// Value is the stored (encrypted) data of the chunk
+	Value []byte
+	// Index of the chunk amongst all the stored chunks of the item
+	Index int
+	// Size is the number of stored chunks of the item
+	Size int
+	// Path holds the hashes required to recompute the Merkle root, from the chunk upwards
+	Path [][]byte
+}
+
+// ErrInvalidMerkleProof raised if a MerkleProof does not demonstrate that the chunk is part of the item
+var ErrInvalidMerkleProof = errors.New("merkle proof does not match the root")
+
+// ErrAttributeNotFound raised if the requested attribute is not part of the packed item
+var ErrAttributeNotFound = errors.New("attribute not found in packed item")
+
+// MerkleRoot returns the Merkle root recorded in the envelope of the packed item (see WithMerkleRoot),
+// which can be shared so that MerkleProofs can be verified without access to the envelope key.
+// As no elements are loaded, an envelope split across continuation elements must first be joined
+// (see JoinEnvelope), otherwise ErrEnvelopeContinued is raised.
+func MerkleRoot[T comparable](ctx context.Context, data []byte, provider EnvelopeKeyProvider, idRetriever GetIDSerialiser[T]) ([]byte, error) {
+
+	if len(data) == 0 {
+		return nil, ErrUnpackNoData
+	}
+	if provider == nil {
+		return nil, ErrProviderIsNil
+	}
+	if idRetriever == nil {
+		return nil, ErrIDRetrieverIsNil
+	}
+
+	env, err := openEnvelope(ctx, data, provider, idRetriever)
+	if err != nil {
+		return nil, err
+	}
+
+	root, ok := env.ext[extMerkleRoot]
+	if !ok {
+		return nil, ErrNoMerkleRoot
+	}
+	return root, nil
+}
+
+// GenerateProof returns a MerkleProof for each stored chunk of the attribute, which allows the holder of the
+// item's Merkle root to verify, using VerifyProof, that the encrypted chunks are genuinely part of the item
+// without access to the envelope key or to the other chunks.  The chunks are loaded with the params DataLoader
+// and checked against the recorded root before any proof is returned.
+func GenerateProof[T comparable](ctx context.Context, data []byte, params *UnpackParams[T], attr string) ([]*MerkleProof, error) {
+
+	if len(data) == 0 {
+		return nil, ErrUnpackNoData
+	}
+	if params == nil {
+		return nil, ErrUnpackNoParams
+	}
+	if err := params.validate(); err != nil {
+		return nil, err
+	}
+
+	data, err := JoinEnvelope(ctx, data, params.DataLoader, params.IDRetriever)
+	if err != nil {
+		return nil, err
+	}
+
+	env, err := openEnvelope(params.withEncryptionContext(ctx), data, params.Provider, params.IDRetriever)
+	if err != nil {
+		return nil, err
+	}
+
+	root, ok := env.ext[extMerkleRoot]
+	if !ok {
+		return nil, ErrNoMerkleRoot
+	}
+
+	d := &itemPackingDetailsV1[T]{}
+	attrMap, err := d.unpackAttrMap(env.bAttrMap, env.approach, env.ext)
+	if err != nil {
+		return nil, err
+	}
+	chunks, ok := attrMap[attr]
+	if !ok {
+		return nil, ErrAttributeNotFound
+	}
+
+	m, err := params.DataLoader(ctx, env.elements)
+	if err != nil {
+		return nil, err
+	}
+
+	leaves := make([]merkleLeaf, 0, len(m))
+	for name, v := range m {
+		leaves = append(leaves, merkleLeaf{name: name, value: v})
+	}
+	sort.Slice(leaves, func(i, j int) bool { return leaves[i].name < leaves[j].name })
+
+	hashes := make([][]byte, len(leaves))
+	index := make(map[string]int, len(leaves))
+	for i, l := range leaves {
+		hashes[i] = merkleLeafHash(l)
+		index[l.name] = i
+	}
+
+	if !hmac.Equal(root, merkleTreeHash(hashes)) {
+		return nil, fmt.Errorf("%w: item %v", ErrMerkleRootMismatch, env.key)
+	}
+
+	proofs := make([]*MerkleProof, 0, len(chunks))
+	for _, chunk := range chunks {
+		i, ok := index[chunk]
+		if !ok {
+			return nil, fmt.Errorf("%w: item %v", ErrMerkleRootMismatch, env.key)
+		}
+		proofs = append(proofs, &MerkleProof{
+			Chunk: chunk,
+			Value: leaves[i].value,
+			Index: i,
+			Size:  len(leaves),
+			Path:  merkleAuditPath(i, hashes),
+		})
+	}
+
+	return proofs, nil
+}
+
+// VerifyProof confirms that the chunk described by the proof is part of the item with the Merkle root,
+// returning ErrInvalidMerkleProof if it is not
+func VerifyProof(root []byte, proof *MerkleProof) error {
+
+	if proof == nil || proof.Index < 0 || proof.Index >= proof.Size {
+		return ErrInvalidMerkleProof
+	}
+
+	// Inclusion proof verification, as described in RFC 9162, section 2.1.3.2
+	fn, sn := proof.Index, proof.Size-1
+	r := merkleLeafHash(merkleLeaf{name: proof.Chunk, value: proof.Value})
+
+	for _, p := range proof.Path {
+		if sn == 0 {
+			return ErrInvalidMerkleProof
+		}
+		if fn&1 == 1 || fn == sn {
+			r = merkleNodeHash(p, r)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			r = merkleNodeHash(r, p)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+
+	if sn != 0 || !hmac.Equal(r, root) {
+		return ErrInvalidMerkleProof
+	}
+	return nil
+}
+
+// VerifyMerkleRoot loads the stored chunks of the packed item using the params DataLoader, and confirms
+// that they match the Merkle root recorded in the envelope (see WithMerkleRoot).  No attribute values
+// are decrypted.  Returns ErrNoMerkleRoot if no root was recorded, or ErrMerkleRootMismatch if any
+// stored chunk differs from those packed.
+func VerifyMerkleRoot[T comparable](ctx context.Context, data []byte, params *UnpackParams[T]) error {
+
+	if len(data) == 0 {
+		return ErrUnpackNoData
+	}
+	if params == nil {
+		return ErrUnpackNoParams
+	}
+	if err := params.validate(); err != nil {
+		return err
+	}
+
+	data, err := JoinEnvelope(ctx, data, params.DataLoader, params.IDRetriever)
+	if err != nil {
+		return err
+	}
+
+	env, err := openEnvelope(params.withEncryptionContext(ctx), data, params.Provider, params.IDRetriever)
+	if err != nil {
+		return err
+	}
+
+	root, ok := env.ext[extMerkleRoot]
+	if !ok {
+		return ErrNoMerkleRoot
+	}
+
+	m, err := params.DataLoader(ctx, env.elements)
+	if err != nil {
+		return err
+	}
+
+	leaves := make([]merkleLeaf, 0, len(m))
+	for name, v := range m {
+		leaves = append(leaves, merkleLeaf{name: name, value: v})
+	}
+	sort.Slice(leaves, func(i, j int) bool { return leaves[i].name < leaves[j].name })
+
+	if !hmac.Equal(root, merkleRoot(leaves)) {
+		return fmt.Errorf("%w: item %v", ErrMerkleRootMismatch, env.key)
+	}
+	return nil
+}
+
+// openEnvelope decrypts the packing details of data returned by Pack, without loading any attribute values
+func openEnvelope[T comparable](ctx context.Context, data []byte, provider EnvelopeKeyProvider, idRetriever GetIDSerialiser[T]) (*envelopeV1[T], error) {
+
+	packingVersion, b, err := splitPackingVersion(data)
+	if err != nil {
+		return nil, err
+	}
+	if !packingVersion.supported() {
+		return nil, ErrUnsupportedPackVersion
+	}
+
+	d := &itemPackingDetailsV1[T]{version: packingVersion}
+	return d.openEnvelope(ctx, b, provider, idRetriever)
+}
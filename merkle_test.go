@@ -0,0 +1,230 @@
+package packer
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"testing"
+
+	"github.com/gford1000-go/serialise"
+)
+
+func TestMerkleRoot(t *testing.T) {
+
+	leaves := []merkleLeaf{{"a", []byte("1")}, {"b", []byte("2")}, {"c", []byte("3")}}
+
+	h := func(i int) []byte { return merkleLeafHash(leaves[i]) }
+
+	expected := merkleNodeHash(merkleNodeHash(h(0), h(1)), h(2))
+	if !bytes.Equal(merkleRoot(leaves), expected) {
+		t.Fatal("Unexpected merkle root for three leaves")
+	}
+	if !bytes.Equal(merkleRoot(leaves[:1]), h(0)) {
+		t.Fatal("Unexpected merkle root for one leaf")
+	}
+}
+
+func TestVerifyMerkleRoot(t *testing.T) {
+
+	_, _, provider := testCreateEnv(t)
+
+	serialiser, err := NewKeySerialiser()
+	if err != nil {
+		t.Fatalf("Unexpected error preparing Key serialiser: %v", err)
+	}
+
+	pParams := &PackParams[Key]{
+		Provider: provider,
+		Creator:  NewKeyCreator(defaultLen),
+		Packer:   serialiser,
+		Approach: serialise.NewMinDataApproachWithVersion(serialise.V1),
+	}
+
+	attrs := map[string]any{}
+	for _, name := range []string{"aaa", "bbb", "ccc"} {
+		b := make([]byte, 8*1024)
+		if _, err := rand.Read(b); err != nil {
+			t.Fatalf("Unexpected error creating data: %v", err)
+		}
+		attrs[name] = b
+	}
+	item := &Item[Key]{Key: Key{X: "A", Y: "B"}, Attributes: attrs}
+
+	info, data, err := Pack(item, pParams, WithMaximumKBSize(10), WithAttributeValueMaximumKBSize(10), WithMerkleRoot())
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+	if len(data) < 2 {
+		t.Fatalf("Expected multiple elements, got %d", len(data))
+	}
+
+	params := func(data map[Key]map[string][]byte) *UnpackParams[Key] {
+		return &UnpackParams[Key]{
+			IDRetriever: func(string) (IDSerialiser[Key], error) { return serialiser, nil },
+			Provider:    provider,
+			DataLoader: func(ctx context.Context, keys []Key) (map[string][]byte, error) {
+				m := map[string][]byte{}
+				for _, k := range keys {
+					for n, v := range data[k] {
+						m[n] = v
+					}
+				}
+				return m, nil
+			},
+		}
+	}
+
+	if err := VerifyMerkleRoot(context.TODO(), info, params(data)); err != nil {
+		t.Fatalf("Unexpected error verifying: %v", err)
+	}
+
+	copyData := func() map[Key]map[string][]byte {
+		c := map[Key]map[string][]byte{}
+		for k, m := range data {
+			c[k] = map[string][]byte{}
+			for n, v := range m {
+				c[k][n] = bytes.Clone(v)
+			}
+		}
+		return c
+	}
+
+	// Altered chunk
+	altered := copyData()
+	for _, m := range altered {
+		for n := range m {
+			m[n][0] ^= 1
+			break
+		}
+		break
+	}
+	if err := VerifyMerkleRoot(context.TODO(), info, params(altered)); !errors.Is(err, ErrMerkleRootMismatch) {
+		t.Fatalf("Unexpected error: expected: %v, got: %v", ErrMerkleRootMismatch, err)
+	}
+
+	// Substituted element
+	other, otherData, err := Pack(item, pParams, WithMaximumKBSize(10), WithAttributeValueMaximumKBSize(10), WithMerkleRoot())
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+	if err := VerifyMerkleRoot(context.TODO(), other, params(otherData)); err != nil {
+		t.Fatalf("Unexpected error verifying: %v", err)
+	}
+	substituted := copyData()
+	for k := range substituted {
+		for ok := range otherData {
+			substituted[k] = otherData[ok]
+			break
+		}
+		break
+	}
+	if err := VerifyMerkleRoot(context.TODO(), info, params(substituted)); !errors.Is(err, ErrMerkleRootMismatch) {
+		t.Fatalf("Unexpected error: expected: %v, got: %v", ErrMerkleRootMismatch, err)
+	}
+
+	// No root recorded
+	plain, plainData, err := Pack(item, pParams)
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+	if err := VerifyMerkleRoot(context.TODO(), plain, params(plainData)); !errors.Is(err, ErrNoMerkleRoot) {
+		t.Fatalf("Unexpected error: expected: %v, got: %v", ErrNoMerkleRoot, err)
+	}
+}
+
+func TestMerkleAuditPath(t *testing.T) {
+
+	for n := 1; n <= 9; n++ {
+		leaves := make([]merkleLeaf, n)
+		hashes := make([][]byte, n)
+		for i := range leaves {
+			leaves[i] = merkleLeaf{name: string(rune('a' + i)), value: []byte{byte(i)}}
+			hashes[i] = merkleLeafHash(leaves[i])
+		}
+		root := merkleRoot(leaves)
+
+		for i := range leaves {
+			proof := &MerkleProof{Chunk: leaves[i].name, Value: leaves[i].value, Index: i, Size: n, Path: merkleAuditPath(i, hashes)}
+			if err := VerifyProof(root, proof); err != nil {
+				t.Fatalf("(%d, %d) Unexpected error verifying proof: %v", n, i, err)
+			}
+			proof.Value = []byte{0xff}
+			if err := VerifyProof(root, proof); !errors.Is(err, ErrInvalidMerkleProof) {
+				t.Fatalf("(%d, %d) Unexpected error: expected: %v, got: %v", n, i, ErrInvalidMerkleProof, err)
+			}
+		}
+	}
+}
+
+func TestGenerateProof(t *testing.T) {
+
+	_, _, provider := testCreateEnv(t)
+
+	serialiser, err := NewKeySerialiser()
+	if err != nil {
+		t.Fatalf("Unexpected error preparing Key serialiser: %v", err)
+	}
+
+	attrs := map[string]any{"small": "Hello World"}
+	for _, name := range []string{"aaa", "bbb", "ccc"} {
+		b := make([]byte, 8*1024)
+		if _, err := rand.Read(b); err != nil {
+			t.Fatalf("Unexpected error creating data: %v", err)
+		}
+		attrs[name] = b
+	}
+
+	info, data, err := Pack(&Item[Key]{Key: Key{X: "A", Y: "B"}, Attributes: attrs}, &PackParams[Key]{
+		Provider: provider,
+		Creator:  NewKeyCreator(defaultLen),
+		Packer:   serialiser,
+		Approach: serialise.NewMinDataApproachWithVersion(serialise.V1),
+	}, WithMaximumKBSize(10), WithAttributeValueMaximumKBSize(10), WithMerkleRoot())
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+
+	idRetriever := func(string) (IDSerialiser[Key], error) { return serialiser, nil }
+	params := &UnpackParams[Key]{
+		IDRetriever: idRetriever,
+		Provider:    provider,
+		DataLoader: func(ctx context.Context, keys []Key) (map[string][]byte, error) {
+			m := map[string][]byte{}
+			for _, k := range keys {
+				for n, v := range data[k] {
+					m[n] = v
+				}
+			}
+			return m, nil
+		},
+	}
+
+	root, err := MerkleRoot(context.TODO(), info, provider, idRetriever)
+	if err != nil {
+		t.Fatalf("Unexpected error getting root: %v", err)
+	}
+
+	for _, attr := range []string{"small", "bbb"} {
+		proofs, err := GenerateProof(context.TODO(), info, params, attr)
+		if err != nil {
+			t.Fatalf("(%s) Unexpected error generating proof: %v", attr, err)
+		}
+		if len(proofs) == 0 {
+			t.Fatalf("(%s) Expected proofs", attr)
+		}
+		for _, proof := range proofs {
+			if err := VerifyProof(root, proof); err != nil {
+				t.Fatalf("(%s) Unexpected error verifying proof: %v", attr, err)
+			}
+			proof.Index ^= 1
+			if err := VerifyProof(root, proof); !errors.Is(err, ErrInvalidMerkleProof) {
+				t.Fatalf("(%s) Unexpected error: expected: %v, got: %v", attr, ErrInvalidMerkleProof, err)
+			}
+		}
+	}
+
+	if _, err := GenerateProof(context.TODO(), info, params, "missing"); !errors.Is(err, ErrAttributeNotFound) {
+		t.Fatalf("Unexpected error: expected: %v, got: %v", ErrAttributeNotFound, err)
+	}
+}
@@ -0,0 +1,98 @@
+package packer
+
+import (
+	"context"
+	"time"
+)
+
+// Metric identifies a measurement reported to a MetricsSink
+type Metric string
+
+const (
+	// MetricPacks counts successful calls to Pack and PackKey
+	MetricPacks Metric = "packs"
+	// MetricUnpacks counts successful calls to Unpack
+	MetricUnpacks Metric = "unpacks"
+	// MetricBytesEncrypted counts the bytes of encrypted attribute data produced by Pack
+	MetricBytesEncrypted Metric = "bytes_encrypted"
+	// MetricElementsWritten counts the elements returned by Pack for storage
+	MetricElementsWritten Metric = "elements_written"
+	// MetricDecryptErrors counts failures to decrypt envelope keys or attribute values
+	MetricDecryptErrors Metric = "decrypt_errors"
+	// MetricPackDuration observes the duration of Pack in seconds
+	MetricPackDuration Metric = "pack_duration_seconds"
+	// MetricUnpackDuration observes the duration of Unpack in seconds
+	MetricUnpackDuration Metric = "unpack_duration_seconds"
+	// MetricGetValuesDuration observes the duration of GetValues in seconds
+	MetricGetValuesDuration Metric = "get_values_duration_seconds"
+	// MetricElementSize observes the size in bytes of each element returned by Pack
+	MetricElementSize Metric = "element_size_bytes"
+	// MetricAttributeNameCollisions counts attribute names rejected by Pack because they were already in use
+	MetricAttributeNameCollisions Metric = "attribute_name_collisions"
+	// MetricAttributeNameGrowths counts increases in the attribute name size made by Pack (see WithAdaptiveAttributeNameSize)
+	MetricAttributeNameGrowths Metric = "attribute_name_growths"
+)
+
+// MetricsSink receives measurements of packer activity, so that behaviour can be
+// observed across a fleet.  Implementations must be safe for concurrent use, and
+// should ignore any Metric they do not recognise.
+type MetricsSink interface {
+	// Add increments the counter for the Metric by delta
+	Add(m Metric, delta float64)
+	// Observe records a value for the histogram of the Metric
+	Observe(m Metric, value float64)
+}
+
+// WithMetrics reports measurements of Pack to the specified sink
+func WithMetrics(sink MetricsSink) func(o *Options) {
+	return func(o *Options) {
+		o.metrics = sink
+	}
+}
+
+type noopMetrics struct{}
+
+func (noopMetrics) Add(Metric, float64)     {}
+func (noopMetrics) Observe(Metric, float64) {}
+
+// metricsOrDefault ensures a sink is always available to report to
+func metricsOrDefault(sink MetricsSink) MetricsSink {
+	if sink == nil {
+		return noopMetrics{}
+	}
+	return sink
+}
+
+// recordPack reports the measurements of a successful Pack
+func recordPack[T comparable](sink MetricsSink, start time.Time, itemData map[T]map[string][]byte) {
+
+	sink.Add(MetricPacks, 1)
+	sink.Add(MetricElementsWritten, float64(len(itemData)))
+
+	var encrypted int
+	for _, attrs := range itemData {
+		var size int
+		for k, v := range attrs {
+			encrypted += len(v)
+			size += len(k) + len(v)
+		}
+		sink.Observe(MetricElementSize, float64(size))
+	}
+
+	sink.Add(MetricBytesEncrypted, float64(encrypted))
+	sink.Observe(MetricPackDuration, time.Since(start).Seconds())
+}
+
+// measuredProvider reports failures to decrypt envelope keys
+type measuredProvider struct {
+	EnvelopeKeyProvider
+	metrics MetricsSink
+}
+
+func (m *measuredProvider) Decrypt(ctx context.Context, encryptedKey []byte) ([]byte, error) {
+	key, err := m.EnvelopeKeyProvider.Decrypt(ctx, encryptedKey)
+	if err != nil {
+		m.metrics.Add(MetricDecryptErrors, 1)
+	}
+	return key, err
+}
@@ -0,0 +1,88 @@
+package packer
+
+import (
+	"errors"
+	"expvar"
+	"sync"
+)
+
+// PrometheusCounter is satisfied by prometheus.Counter
+type PrometheusCounter interface {
+	Add(float64)
+}
+
+// PrometheusObserver is satisfied by prometheus.Histogram and prometheus.Summary
+type PrometheusObserver interface {
+	Observe(float64)
+}
+
+// NewPrometheusSink returns a MetricsSink that reports to Prometheus collectors, which
+// the caller creates and registers as required (e.g. using prometheus.NewCounter).
+// Metrics without a corresponding collector are ignored.
+func NewPrometheusSink(counters map[Metric]PrometheusCounter, observers map[Metric]PrometheusObserver) MetricsSink {
+	p := &prometheusSink{
+		counters:  map[Metric]PrometheusCounter{},
+		observers: map[Metric]PrometheusObserver{},
+	}
+	for k, v := range counters {
+		p.counters[k] = v
+	}
+	for k, v := range observers {
+		p.observers[k] = v
+	}
+	return p
+}
+
+type prometheusSink struct {
+	counters  map[Metric]PrometheusCounter
+	observers map[Metric]PrometheusObserver
+}
+
+func (p *prometheusSink) Add(m Metric, delta float64) {
+	if c, ok := p.counters[m]; ok {
+		c.Add(delta)
+	}
+}
+
+func (p *prometheusSink) Observe(m Metric, value float64) {
+	if o, ok := p.observers[m]; ok {
+		o.Observe(value)
+	}
+}
+
+// ErrExpvarNotMap raised if the name given to NewExpvarSink is already published as a variable other than an expvar.Map
+var ErrExpvarNotMap = errors.New("expvar variable is already published and is not an expvar.Map")
+
+// NewExpvarSink returns a MetricsSink that publishes to an expvar.Map of the specified name.
+// Counters are published by Metric name; histograms are summarised by their count and sum,
+// published as "<metric>_count" and "<metric>_sum".
+// The map is created if it does not already exist, but the name must not be published as another kind of variable.
+func NewExpvarSink(name string) (MetricsSink, error) {
+	expvarMu.Lock()
+	defer expvarMu.Unlock()
+
+	v := expvar.Get(name)
+	if v == nil {
+		return &expvarSink{m: expvar.NewMap(name)}, nil
+	}
+	m, ok := v.(*expvar.Map)
+	if !ok {
+		return nil, ErrExpvarNotMap
+	}
+	return &expvarSink{m: m}, nil
+}
+
+var expvarMu sync.Mutex
+
+type expvarSink struct {
+	m *expvar.Map
+}
+
+func (e *expvarSink) Add(m Metric, delta float64) {
+	e.m.AddFloat(string(m), delta)
+}
+
+func (e *expvarSink) Observe(m Metric, value float64) {
+	e.m.AddFloat(string(m)+"_count", 1)
+	e.m.AddFloat(string(m)+"_sum", value)
+}
@@ -0,0 +1,154 @@
+package packer
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"sync"
+	"testing"
+)
+
+type testMetricsSink struct {
+	mu       sync.Mutex
+	counters map[Metric]float64
+	observed map[Metric]int
+}
+
+func newTestMetricsSink() *testMetricsSink {
+	return &testMetricsSink{
+		counters: map[Metric]float64{},
+		observed: map[Metric]int{},
+	}
+}
+
+func (s *testMetricsSink) Add(m Metric, delta float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counters[m] += delta
+}
+
+func (s *testMetricsSink) Observe(m Metric, value float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.observed[m]++
+}
+
+func TestWithMetrics(t *testing.T) {
+
+	testPack, _, provider := testCreateEnv(t)
+
+	sink := newTestMetricsSink()
+
+	item := &Item[Key]{
+		Key: Key{X: "A", Y: "B"},
+		Attributes: map[string]any{
+			"aaa": int64(42),
+		},
+	}
+
+	b, l, err := testPack(item, WithMetrics(sink))
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+
+	if sink.counters[MetricPacks] != 1 || sink.counters[MetricElementsWritten] != 1 || sink.counters[MetricBytesEncrypted] == 0 {
+		t.Fatalf("Unexpected counters after Pack: %v", sink.counters)
+	}
+	if sink.observed[MetricPackDuration] != 1 || sink.observed[MetricElementSize] != 1 {
+		t.Fatalf("Unexpected observations after Pack: %v", sink.observed)
+	}
+
+	serialiser, _ := NewKeySerialiser()
+
+	params := &UnpackParams[Key]{
+		DataLoader:  l,
+		IDRetriever: func(string) (IDSerialiser[Key], error) { return serialiser, nil },
+		Provider:    provider,
+		Metrics:     sink,
+	}
+
+	e, err := Unpack(context.TODO(), b, params)
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+	if sink.counters[MetricUnpacks] != 1 || sink.observed[MetricUnpackDuration] != 1 {
+		t.Fatalf("Unexpected metrics after Unpack: %v, %v", sink.counters, sink.observed)
+	}
+
+	if _, err := e.GetValues(context.TODO(), []string{"aaa"}, provider); err != nil {
+		t.Fatalf("Unexpected error getting values: %v", err)
+	}
+	if sink.observed[MetricGetValuesDuration] != 1 {
+		t.Fatalf("Unexpected observations after GetValues: %v", sink.observed)
+	}
+
+	// A provider with a different key cannot decrypt
+	other, err := NewEnvelopeKeyProvider(&EnvelopeKeyProviderInfo{ID: "Key1", Key: []byte("98765432109876543210987654321098")}, func(EnvelopeKeyID) (EnvelopeKeyProvider, error) { return nil, nil })
+	if err != nil {
+		t.Fatalf("Unexpected error creating provider: %v", err)
+	}
+	if _, err := e.GetValues(context.TODO(), []string{"aaa"}, other); err == nil {
+		t.Fatal("Unexpected success when expected error")
+	}
+	if sink.counters[MetricDecryptErrors] != 1 {
+		t.Fatalf("Unexpected decrypt errors: %v", sink.counters[MetricDecryptErrors])
+	}
+}
+
+type testPrometheusCollector struct {
+	total float64
+	count int
+}
+
+func (c *testPrometheusCollector) Add(v float64)     { c.total += v }
+func (c *testPrometheusCollector) Observe(v float64) { c.count++ }
+
+func TestNewPrometheusSink(t *testing.T) {
+
+	packs := &testPrometheusCollector{}
+	durations := &testPrometheusCollector{}
+
+	sink := NewPrometheusSink(
+		map[Metric]PrometheusCounter{MetricPacks: packs},
+		map[Metric]PrometheusObserver{MetricPackDuration: durations})
+
+	sink.Add(MetricPacks, 2)
+	sink.Add(MetricUnpacks, 1)
+	sink.Observe(MetricPackDuration, 0.5)
+	sink.Observe(MetricUnpackDuration, 0.5)
+
+	if packs.total != 2 || durations.count != 1 {
+		t.Fatalf("Unexpected collector values: %v, %v", packs.total, durations.count)
+	}
+}
+
+func TestNewExpvarSink(t *testing.T) {
+
+	sink, err := NewExpvarSink("packer_test")
+	if err != nil {
+		t.Fatalf("Unexpected error creating sink: %v", err)
+	}
+	sink.Add(MetricPacks, 1)
+	sink.Observe(MetricPackDuration, 0.25)
+
+	// Retrieving the same name reuses the published map
+	sink, err = NewExpvarSink("packer_test")
+	if err != nil {
+		t.Fatalf("Unexpected error creating sink: %v", err)
+	}
+	sink.Add(MetricPacks, 1)
+
+	m := expvar.Get("packer_test").(*expvar.Map)
+	if v := m.Get(string(MetricPacks)).(*expvar.Float).Value(); v != 2 {
+		t.Fatalf("Unexpected packs: %v", v)
+	}
+	if v := m.Get(string(MetricPackDuration) + "_sum").(*expvar.Float).Value(); v != 0.25 {
+		t.Fatalf("Unexpected duration sum: %v", v)
+	}
+
+	// Names published as other kinds of variable cannot be used
+	expvar.NewInt("packer_test_int")
+	if _, err := NewExpvarSink("packer_test_int"); !errors.Is(err, ErrExpvarNotMap) {
+		t.Fatalf("Expected ErrExpvarNotMap, got: %v", err)
+	}
+}
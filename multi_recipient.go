@@ -0,0 +1,122 @@
+package packer
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gford1000-go/serialise"
+)
+
+// ErrRecipientCannotWrap raised if a recipient passed to NewMultiRecipientProvider does not implement KeyWrapper
+var ErrRecipientCannotWrap = errors.New("recipient provider cannot wrap existing keys - it must implement KeyWrapper")
+
+// ErrNoRecipientCanDecrypt raised if none of the providers of a multi-recipient provider can decrypt the key
+var ErrNoRecipientCanDecrypt = errors.New("no provider available for any recipient of the encrypted key")
+
+// multiRecipientMarker identifies encrypted keys created by a multi-recipient provider
+const multiRecipientMarker = "packer:recipients"
+
+// NewMultiRecipientProvider creates an EnvelopeKeyProvider whose keys are created by the primary provider and also
+// wrapped by each recipient, which must implement KeyWrapper, so that any of them can decrypt packed items; for example
+// both a production KMS key and a break-glass recovery key.  The encrypted key lists the EnvelopeKeyID and wrapped key
+// of each provider, and Decrypt returns the key from the first entry that one of its providers can decrypt.
+// A service holding only the recovery key can create a multi-recipient provider with that provider as its primary.
+func NewMultiRecipientProvider(primary EnvelopeKeyProvider, recipients ...EnvelopeKeyProvider) (EnvelopeKeyProvider, error) {
+	if primary == nil {
+		return nil, ErrProviderIsNil
+	}
+
+	m := &multiRecipientProvider{
+		providers: []EnvelopeKeyProvider{primary},
+	}
+	for _, recipient := range recipients {
+		if recipient == nil {
+			return nil, ErrProviderIsNil
+		}
+		wrapper, ok := recipient.(KeyWrapper)
+		if !ok {
+			return nil, ErrRecipientCannotWrap
+		}
+		m.providers = append(m.providers, recipient)
+		m.wrappers = append(m.wrappers, wrapper)
+	}
+
+	return m, nil
+}
+
+type multiRecipientProvider struct {
+	providers []EnvelopeKeyProvider
+	wrappers  []KeyWrapper
+}
+
+func (m *multiRecipientProvider) ID() EnvelopeKeyID {
+	return m.providers[0].ID()
+}
+
+func (m *multiRecipientProvider) New() ([]byte, []byte, error) {
+	return m.NewWithContext(context.Background())
+}
+
+func (m *multiRecipientProvider) NewWithContext(ctx context.Context) ([]byte, []byte, error) {
+
+	encryptedKey, key, err := newKey(ctx, m.providers[0])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	entries := []any{multiRecipientMarker, string(m.providers[0].ID()), encryptedKey}
+	for i, wrapper := range m.wrappers {
+		wrapped, err := wrapper.Wrap(ctx, key)
+		if err != nil {
+			return nil, nil, err
+		}
+		entries = append(entries, string(m.providers[i+1].ID()), wrapped)
+	}
+
+	b, _, err := serialise.ToBytesMany(entries, serialise.WithSerialisationApproach(serialise.NewMinDataApproachWithVersion(serialise.V1)))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return b, key, nil
+}
+
+func (m *multiRecipientProvider) Decrypt(ctx context.Context, encryptedKey []byte) ([]byte, error) {
+
+	v, err := serialise.FromBytesMany(encryptedKey, serialise.NewMinDataApproachWithVersion(serialise.V1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(v) < 3 || len(v)%2 != 1 {
+		return nil, ErrKeyDeserialisationError
+	}
+	if marker, ok := v[0].(string); !ok || marker != multiRecipientMarker {
+		return nil, ErrKeyDeserialisationError
+	}
+
+	var lastErr error = ErrNoRecipientCanDecrypt
+	for i := 1; i < len(v); i += 2 {
+		id, ok := v[i].(string)
+		if !ok {
+			return nil, ErrKeyDeserialisationError
+		}
+		wrapped, ok := v[i+1].([]byte)
+		if !ok {
+			return nil, ErrKeyDeserialisationError
+		}
+
+		for _, provider := range m.providers {
+			if provider.ID() != EnvelopeKeyID(id) {
+				continue
+			}
+			key, err := provider.Decrypt(ctx, wrapped)
+			if err == nil {
+				return key, nil
+			}
+			lastErr = err
+		}
+	}
+
+	return nil, lastErr
+}
@@ -0,0 +1,87 @@
+package packer
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/gford1000-go/serialise"
+)
+
+func TestMultiRecipientProvider(t *testing.T) {
+
+	_, _, production := testCreateEnv(t)
+
+	notFound := func(EnvelopeKeyID) (EnvelopeKeyProvider, error) { return nil, errors.New("unknown provider id") }
+	recovery, err := NewEnvelopeKeyProvider(&EnvelopeKeyProviderInfo{ID: "recovery", Key: []byte("21987654321098765432109876543210")}, notFound)
+	if err != nil {
+		t.Fatalf("Unexpected error creating provider: %v", err)
+	}
+
+	provider, err := NewMultiRecipientProvider(production, recovery)
+	if err != nil {
+		t.Fatalf("Unexpected error creating multi-recipient provider: %v", err)
+	}
+
+	serialiser, _ := NewKeySerialiser()
+
+	info, data, err := Pack(&Item[Key]{Key: Key{X: "A", Y: "B"}, Attributes: map[string]any{"aaa": "Hello World"}}, &PackParams[Key]{
+		Provider: provider,
+		Creator:  NewKeyCreator(defaultLen),
+		Packer:   serialiser,
+		Approach: serialise.NewMinDataApproachWithVersion(serialise.V1),
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+
+	loader := func(ctx context.Context, keys []Key) (map[string][]byte, error) {
+		attrs := map[string][]byte{}
+		for _, key := range keys {
+			for k, v := range data[key] {
+				attrs[k] = v
+			}
+		}
+		return attrs, nil
+	}
+
+	// Either recipient alone can decrypt the item
+	for _, p := range []EnvelopeKeyProvider{production, recovery} {
+		holder, err := NewMultiRecipientProvider(p)
+		if err != nil {
+			t.Fatalf("Unexpected error creating multi-recipient provider: %v", err)
+		}
+		e, err := Unpack(context.TODO(), info, &UnpackParams[Key]{
+			DataLoader:  loader,
+			IDRetriever: func(string) (IDSerialiser[Key], error) { return serialiser, nil },
+			Provider:    holder,
+		})
+		if err != nil {
+			t.Fatalf("(%s) Unexpected error unpacking: %v", p.ID(), err)
+		}
+		values, err := e.GetValues(context.TODO(), []string{"aaa"}, holder)
+		if err != nil {
+			t.Fatalf("(%s) Unexpected error getting values: %v", p.ID(), err)
+		}
+		if values["aaa"] != "Hello World" {
+			t.Fatalf("(%s) Unexpected values: %v", p.ID(), values)
+		}
+	}
+
+	other, _ := NewEnvelopeKeyProvider(&EnvelopeKeyProviderInfo{ID: "other", Key: []byte("21987654321098765432109876543210")}, notFound)
+	holder, _ := NewMultiRecipientProvider(other)
+	encryptedKey, err := PackEncryptedKey(info)
+	if err != nil {
+		t.Fatalf("Unexpected error getting encrypted key: %v", err)
+	}
+	if _, err := holder.Decrypt(context.TODO(), encryptedKey); !errors.Is(err, ErrNoRecipientCanDecrypt) {
+		t.Fatalf("Expected ErrNoRecipientCanDecrypt, got: %v", err)
+	}
+
+	if _, err := NewMultiRecipientProvider(production, &decryptOnlyProvider{provider: recovery}); !errors.Is(err, ErrRecipientCannotWrap) {
+		t.Fatalf("Expected ErrRecipientCannotWrap, got: %v", err)
+	}
+	if _, err := NewMultiRecipientProvider(nil); !errors.Is(err, ErrProviderIsNil) {
+		t.Fatalf("Expected ErrProviderIsNil, got: %v", err)
+	}
+}
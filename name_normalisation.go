@@ -0,0 +1,98 @@
+package packer
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// AttributeNameNormaliser returns the canonical form of an attribute name, so that names from systems
+// that are inconsistent in their use of case or Unicode composition identify the same attribute.
+// Unicode normalisation can be applied using golang.org/x/text/unicode/norm (e.g. norm.NFC.String).
+type AttributeNameNormaliser func(name string) string
+
+// FoldCase is an AttributeNameNormaliser applying Unicode simple case folding, so that names differing
+// only in case are the same.
+func FoldCase(name string) string {
+	return strings.Map(func(r rune) rune {
+		// The smallest rune of the folding orbit is used as the canonical form
+		folded := r
+		for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+			folded = min(folded, f)
+		}
+		return folded
+	}, name)
+}
+
+// ChainAttributeNameNormalisers returns an AttributeNameNormaliser applying each of the normalisers in turn
+func ChainAttributeNameNormalisers(normalisers ...AttributeNameNormaliser) AttributeNameNormaliser {
+	return func(name string) string {
+		for _, n := range normalisers {
+			name = n(name)
+		}
+		return name
+	}
+}
+
+// WithAttributeNameNormaliser normalises the names of attributes during Pack, failing with a
+// DuplicateAttributeNameError if several attributes have the same normalised name.  The same normaliser
+// should be specified in the UnpackParams, so that GetValues finds attributes requested by any form of
+// their names.
+func WithAttributeNameNormaliser(n AttributeNameNormaliser) func(o *Options) {
+	return func(o *Options) {
+		o.attrNameNormaliser = n
+	}
+}
+
+// ErrDuplicateAttributeName is matched by DuplicateAttributeNameError, using errors.Is
+var ErrDuplicateAttributeName = errors.New("attribute names are duplicated once normalised")
+
+// DuplicateAttributeNameError raised if several attributes of an item have the same normalised name
+type DuplicateAttributeNameError struct {
+	// Name is the normalised name
+	Name string
+	// Attributes are the names of the attributes, as provided
+	Attributes []string
+}
+
+func (e *DuplicateAttributeNameError) Error() string {
+	return fmt.Sprintf("%v: %q from %q", ErrDuplicateAttributeName, e.Name, e.Attributes)
+}
+
+func (e *DuplicateAttributeNameError) Unwrap() error {
+	return ErrDuplicateAttributeName
+}
+
+// normaliseItem returns a copy of the item with normalised attribute names
+func normaliseItem[T comparable](item *Item[T], n AttributeNameNormaliser) (*Item[T], error) {
+
+	// Names are processed in order, so that any error is deterministic
+	names := make([]string, 0, len(item.Attributes))
+	for k := range item.Attributes {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	attrs := make(map[string]any, len(item.Attributes))
+	sources := make(map[string]string, len(item.Attributes))
+	for _, name := range names {
+		normalised := n(name)
+		if source, ok := sources[normalised]; ok {
+			return nil, &DuplicateAttributeNameError{Name: normalised, Attributes: []string{source, name}}
+		}
+		sources[normalised] = name
+		attrs[normalised] = item.Attributes[name]
+	}
+
+	return &Item[T]{Key: item.Key, Attributes: attrs}, nil
+}
+
+// normaliseName returns the name under which the requested attribute is held
+func (e *EncryptedItem[T]) normaliseName(attr string) string {
+	if e.normaliser == nil {
+		return attr
+	}
+	return e.normaliser(attr)
+}
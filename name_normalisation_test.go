@@ -0,0 +1,78 @@
+package packer
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFoldCase(t *testing.T) {
+
+	tests := []struct {
+		a, b string
+	}{
+		{a: "CustomerID", b: "customerid"},
+		{a: "STRASSE", b: "strasse"},
+		{a: "ΣΊΣΥΦΟΣ", b: "σίσυφος"},
+		{a: "K", b: "K"}, // Kelvin sign
+	}
+
+	for i, test := range tests {
+		if FoldCase(test.a) != FoldCase(test.b) {
+			t.Fatalf("(%d) Expected %q and %q to fold to the same name: %q, %q", i, test.a, test.b, FoldCase(test.a), FoldCase(test.b))
+		}
+	}
+
+	trim := ChainAttributeNameNormalisers(strings.TrimSpace, FoldCase)
+	if trim(" Name ") != FoldCase("name") {
+		t.Fatalf("Unexpected chained normalisation: %q", trim(" Name "))
+	}
+}
+
+func TestWithAttributeNameNormaliser(t *testing.T) {
+
+	testPack, _, provider := testCreateEnv(t)
+
+	item := &Item[Key]{
+		Key:        Key{X: "A", Y: "B"},
+		Attributes: map[string]any{"CustomerID": int64(1), "Email": "a@b.c"},
+	}
+
+	info, l, err := testPack(item, WithAttributeNameNormaliser(FoldCase))
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+
+	serialiser, _ := NewKeySerialiser()
+	params := &UnpackParams[Key]{
+		DataLoader:              l,
+		IDRetriever:             func(string) (IDSerialiser[Key], error) { return serialiser, nil },
+		Provider:                provider,
+		AttributeNameNormaliser: FoldCase,
+	}
+	e, err := Unpack(context.TODO(), info, params)
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+
+	values, err := e.GetValues(context.TODO(), []string{"customerId", "EMAIL"}, provider)
+	if err != nil {
+		t.Fatalf("Unexpected error getting values: %v", err)
+	}
+	expected := map[string]any{"customerId": int64(1), "EMAIL": "a@b.c"}
+	if !reflect.DeepEqual(values, expected) {
+		t.Fatalf("Mismatch: expected %v, got %v", expected, values)
+	}
+
+	item.Attributes["customerid"] = int64(2)
+	_, _, err = testPack(item, WithAttributeNameNormaliser(FoldCase))
+	var dupErr *DuplicateAttributeNameError
+	if !errors.As(err, &dupErr) || !errors.Is(err, ErrDuplicateAttributeName) {
+		t.Fatalf("Expected DuplicateAttributeNameError, got: %v", err)
+	}
+	if !reflect.DeepEqual(dupErr.Attributes, []string{"CustomerID", "customerid"}) {
+		t.Fatalf("Unexpected duplicated attributes: %v", dupErr.Attributes)
+	}
+}
@@ -0,0 +1,112 @@
+package packer
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// ReservedAttributeNamePrefixes are the prefixes of attribute names used internally, which are always
+// rejected when attribute names are validated (see WithAttributeNameRules)
+var ReservedAttributeNamePrefixes = []string{"*amzn-ddb-map-"}
+
+// AttributeNameRules restrict the logical attribute names that may be packed, so that names that would
+// exceed the limits of the store, or collide with names used internally, are rejected by Pack
+type AttributeNameRules struct {
+	// MaxLength, if not zero, is the maximum length of a name in bytes
+	MaxLength int `json:"maxLength,omitempty"`
+	// AllowedCharacters, if not empty, holds every character that may be used in a name
+	AllowedCharacters string `json:"allowedCharacters,omitempty"`
+	// AllowedRune, if not nil, must return true for every character of a name
+	AllowedRune func(r rune) bool `json:"-"`
+	// ReservedPrefixes are prefixes that names must not have, in addition to ReservedAttributeNamePrefixes
+	ReservedPrefixes []string `json:"reservedPrefixes,omitempty"`
+}
+
+// WithAttributeNameRules validates the name of each attribute during Pack, failing with an
+// InvalidAttributeNameError if a name is empty or breaks the rules.  Names are validated after
+// any normalisation (see WithAttributeNameNormaliser).
+func WithAttributeNameRules(rules AttributeNameRules) func(o *Options) {
+	return func(o *Options) {
+		o.attrNameRules = &rules
+	}
+}
+
+// ErrAttributeNameIsEmpty raised, as an InvalidAttributeNameError, if an attribute name is empty
+var ErrAttributeNameIsEmpty = errors.New("attribute name is empty")
+
+// ErrAttributeNameTooLong raised, as an InvalidAttributeNameError, if an attribute name exceeds the MaxLength
+var ErrAttributeNameTooLong = errors.New("attribute name is too long")
+
+// ErrAttributeNameHasInvalidCharacter raised, as an InvalidAttributeNameError, if an attribute name includes
+// a character that is not allowed, or is not valid UTF-8
+var ErrAttributeNameHasInvalidCharacter = errors.New("attribute name includes a character that is not allowed")
+
+// ErrAttributeNameIsReserved raised, as an InvalidAttributeNameError, if an attribute name has a reserved prefix
+var ErrAttributeNameIsReserved = errors.New("attribute name has a reserved prefix")
+
+// InvalidAttributeNameError raised if an attribute name breaks the AttributeNameRules
+type InvalidAttributeNameError struct {
+	// Attribute is the invalid name
+	Attribute string
+	// Err identifies the rule that was broken
+	Err error
+}
+
+func (e *InvalidAttributeNameError) Error() string {
+	return fmt.Sprintf("attribute %q: %v", e.Attribute, e.Err)
+}
+
+func (e *InvalidAttributeNameError) Unwrap() error {
+	return e.Err
+}
+
+// checkNames validates the names in order, so that any error is deterministic
+func (r *AttributeNameRules) checkNames(attrs map[string]any) error {
+	if r == nil {
+		return nil
+	}
+
+	names := make([]string, 0, len(attrs))
+	for k := range attrs {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := r.checkName(name); err != nil {
+			return &InvalidAttributeNameError{Attribute: name, Err: err}
+		}
+	}
+	return nil
+}
+
+func (r *AttributeNameRules) checkName(name string) error {
+	if len(name) == 0 {
+		return ErrAttributeNameIsEmpty
+	}
+	if r.MaxLength > 0 && len(name) > r.MaxLength {
+		return ErrAttributeNameTooLong
+	}
+	for _, c := range name {
+		if c == utf8.RuneError {
+			return ErrAttributeNameHasInvalidCharacter
+		}
+		if len(r.AllowedCharacters) > 0 && !strings.ContainsRune(r.AllowedCharacters, c) {
+			return ErrAttributeNameHasInvalidCharacter
+		}
+		if r.AllowedRune != nil && !r.AllowedRune(c) {
+			return ErrAttributeNameHasInvalidCharacter
+		}
+	}
+	for _, prefixes := range [][]string{ReservedAttributeNamePrefixes, r.ReservedPrefixes} {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(name, prefix) {
+				return ErrAttributeNameIsReserved
+			}
+		}
+	}
+	return nil
+}
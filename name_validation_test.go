@@ -0,0 +1,57 @@
+package packer
+
+import (
+	"errors"
+	"testing"
+	"unicode"
+)
+
+func TestWithAttributeNameRules(t *testing.T) {
+
+	testPack, _, _ := testCreateEnv(t)
+
+	rules := AttributeNameRules{
+		MaxLength:        10,
+		AllowedRune:      func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' },
+		ReservedPrefixes: []string{"sys_"},
+	}
+
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{name: "valid_name"},
+		{name: "", err: ErrAttributeNameIsEmpty},
+		{name: "much_too_long", err: ErrAttributeNameTooLong},
+		{name: "with space", err: ErrAttributeNameHasInvalidCharacter},
+		{name: "bad\xff", err: ErrAttributeNameHasInvalidCharacter},
+		{name: "sys_id", err: ErrAttributeNameIsReserved},
+	}
+
+	for i, test := range tests {
+		item := &Item[Key]{
+			Key:        Key{X: "A", Y: "B"},
+			Attributes: map[string]any{test.name: int64(1)},
+		}
+		_, _, err := testPack(item, WithAttributeNameRules(rules))
+		if !errors.Is(err, test.err) {
+			t.Fatalf("(%d) Expected %v, got: %v", i, test.err, err)
+		}
+		var nameErr *InvalidAttributeNameError
+		if test.err != nil && (!errors.As(err, &nameErr) || nameErr.Attribute != test.name) {
+			t.Fatalf("(%d) Expected InvalidAttributeNameError for %q, got: %v", i, test.name, err)
+		}
+	}
+
+	// Internally reserved names are always rejected
+	item := &Item[Key]{
+		Key:        Key{X: "A", Y: "B"},
+		Attributes: map[string]any{DDBECSignatureAttribute: int64(1)},
+	}
+	if _, _, err := testPack(item, WithAttributeNameRules(AttributeNameRules{AllowedCharacters: "*abcdefghijklmnopqrstuvwxyz-"})); !errors.Is(err, ErrAttributeNameIsReserved) {
+		t.Fatalf("Expected ErrAttributeNameIsReserved, got: %v", err)
+	}
+	if _, _, err := testPack(item, WithAttributeNameRules(AttributeNameRules{AllowedCharacters: "abc"})); !errors.Is(err, ErrAttributeNameHasInvalidCharacter) {
+		t.Fatalf("Expected ErrAttributeNameHasInvalidCharacter, got: %v", err)
+	}
+}
@@ -0,0 +1,316 @@
+// Package objectstore stores packed items in an object store, such as S3, GCS or Azure Blob storage.
+// Each element is written as a single object using packer.EncodeElement, and is loaded using ranged reads,
+// either in full by the DataLoader or on demand through UnpackReaderAt.
+//
+// To avoid a dependency on any SDK, the store is accessed through the Client interface, which is
+// implemented by a thin wrapper around the put, head and ranged get operations of the SDK.
+package objectstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"path"
+	"sync"
+
+	"github.com/gford1000-go/packer"
+)
+
+// Client is the subset of an object store API used by a Bucket
+type Client interface {
+	// PutObject writes the object with the name
+	PutObject(ctx context.Context, name string, data []byte) error
+	// ObjectSize returns the size of the object with the name, raising ErrObjectNotFound if it does not exist
+	ObjectSize(ctx context.Context, name string) (int64, error)
+	// GetObjectRange returns length bytes of the object with the name, starting at the offset, raising
+	// ErrObjectNotFound if it does not exist
+	GetObjectRange(ctx context.Context, name string, offset, length int64) ([]byte, error)
+}
+
+// Namer returns the name of the object holding the element or packed data with the key, before any prefix is applied
+type Namer[T comparable] func(key T) (string, error)
+
+// InfoSuffix is appended to the name of the object holding the packed data of an item, which would
+// otherwise share the name of its first element
+const InfoSuffix = ".info"
+
+// ErrObjectNotFound is raised by a Client if the requested object does not exist
+var ErrObjectNotFound = errors.New("object not found")
+
+// ErrClientIsNil raised if New is called without a Client
+var ErrClientIsNil = errors.New("object store client must not be nil")
+
+// ErrNamerIsNil raised if New is called without a Namer
+var ErrNamerIsNil = errors.New("namer must not be nil")
+
+// Options adjust the layout of objects, and how they are read
+type Options struct {
+	// Prefix of every object name
+	prefix string
+	// Number of characters of the hash of each name added to its prefix
+	hashedPrefix int
+	// Size of each ranged read
+	partSize int64
+	// Maximum number of ranged reads in progress
+	concurrency int
+}
+
+// WithPrefix sets the prefix of every object name, such as "items/"
+func WithPrefix(prefix string) func(*Options) {
+	return func(o *Options) {
+		o.prefix = prefix
+	}
+}
+
+// WithHashedPrefix adds the first n hexadecimal characters of the SHA-256 hash of each name as a path
+// segment after the prefix, spreading objects evenly across the key space of the store
+func WithHashedPrefix(n int) func(*Options) {
+	return func(o *Options) {
+		o.hashedPrefix = min(max(n, 0), 2*sha256.Size)
+	}
+}
+
+// WithPartSize sets the size of each ranged read.  If not set, 8MB is used.
+func WithPartSize(size int64) func(*Options) {
+	return func(o *Options) {
+		o.partSize = size
+	}
+}
+
+// WithConcurrency limits the number of ranged reads and writes in progress at once.  If not set, 8 are used.
+func WithConcurrency(n int) func(*Options) {
+	return func(o *Options) {
+		o.concurrency = n
+	}
+}
+
+// Bucket stores packed items as objects, and implements packer.Store
+type Bucket[T comparable] struct {
+	client Client
+	namer  Namer[T]
+	opts   Options
+}
+
+var _ packer.Store[string] = &Bucket[string]{}
+
+// New returns a Bucket storing objects using the client, named by the Namer
+func New[T comparable](client Client, namer Namer[T], opts ...func(*Options)) (*Bucket[T], error) {
+	if client == nil {
+		return nil, ErrClientIsNil
+	}
+	if namer == nil {
+		return nil, ErrNamerIsNil
+	}
+
+	o := Options{partSize: 8 * 1024 * 1024, concurrency: 8}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	o.partSize = max(o.partSize, 1)
+	o.concurrency = max(o.concurrency, 1)
+
+	return &Bucket[T]{client: client, namer: namer, opts: o}, nil
+}
+
+// ObjectName returns the full name of the object holding the element with the key
+func (b *Bucket[T]) ObjectName(key T) (string, error) {
+	name, err := b.namer(key)
+	if err != nil {
+		return "", err
+	}
+	if b.opts.hashedPrefix > 0 {
+		h := sha256.Sum256([]byte(name))
+		name = path.Join(hex.EncodeToString(h[:])[:b.opts.hashedPrefix], name)
+	}
+	return b.opts.prefix + name, nil
+}
+
+// Load is a packer.DataLoader, returning the chunks of the elements with the keys that exist
+func (b *Bucket[T]) Load(ctx context.Context, keys []T) (map[string][]byte, error) {
+
+	var mu sync.Mutex
+	m := map[string][]byte{}
+
+	err := b.forEach(len(keys), func(i int) error {
+		name, err := b.ObjectName(keys[i])
+		if err != nil {
+			return err
+		}
+		data, err := b.read(ctx, name)
+		if errors.Is(err, ErrObjectNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		chunks, err := packer.DecodeElement(data)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		for k, v := range chunks {
+			m[k] = v
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ReaderAt is a packer.ReaderAtLoader, reading the element with the key using ranged reads as required
+func (b *Bucket[T]) ReaderAt(ctx context.Context, key T) (io.ReaderAt, error) {
+	name, err := b.ObjectName(key)
+	if err != nil {
+		return nil, err
+	}
+	size, err := b.client.ObjectSize(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return &objectReader{ctx: ctx, client: b.client, name: name, size: size}, nil
+}
+
+// Write stores the output of Pack: the elements, and then the packed data of the item with the key,
+// so that the store never holds packed data whose elements are missing
+func (b *Bucket[T]) Write(ctx context.Context, key T, info []byte, data map[T]map[string][]byte) error {
+	if err := b.PutAttrs(ctx, data); err != nil {
+		return err
+	}
+	return b.PutInfo(ctx, key, info)
+}
+
+// PutInfo stores the packed data of the item with the key
+func (b *Bucket[T]) PutInfo(ctx context.Context, key T, info []byte) error {
+	name, err := b.ObjectName(key)
+	if err != nil {
+		return err
+	}
+	return b.client.PutObject(ctx, name+InfoSuffix, info)
+}
+
+// PutAttrs stores each element as an object, encoded using packer.EncodeElement
+func (b *Bucket[T]) PutAttrs(ctx context.Context, data map[T]map[string][]byte) error {
+	keys := make([]T, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	return b.forEach(len(keys), func(i int) error {
+		name, err := b.ObjectName(keys[i])
+		if err != nil {
+			return err
+		}
+		obj, err := packer.EncodeElement(data[keys[i]])
+		if err != nil {
+			return err
+		}
+		return b.client.PutObject(ctx, name, obj)
+	})
+}
+
+// GetInfo returns the packed data of the item with the key, raising packer.ErrItemNotInStore if not present
+func (b *Bucket[T]) GetInfo(ctx context.Context, key T) ([]byte, error) {
+	name, err := b.ObjectName(key)
+	if err != nil {
+		return nil, err
+	}
+	info, err := b.read(ctx, name+InfoSuffix)
+	if errors.Is(err, ErrObjectNotFound) {
+		return nil, packer.ErrItemNotInStore
+	}
+	return info, err
+}
+
+// BatchGetAttrs is equivalent to Load
+func (b *Bucket[T]) BatchGetAttrs(ctx context.Context, keys []T) (map[string][]byte, error) {
+	return b.Load(ctx, keys)
+}
+
+// read returns the whole object, reading its parts concurrently
+func (b *Bucket[T]) read(ctx context.Context, name string) ([]byte, error) {
+	size, err := b.client.ObjectSize(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, size)
+	parts := int((size + b.opts.partSize - 1) / b.opts.partSize)
+	err = b.forEach(parts, func(i int) error {
+		offset := int64(i) * b.opts.partSize
+		length := min(b.opts.partSize, size-offset)
+		part, err := b.client.GetObjectRange(ctx, name, offset, length)
+		if err != nil {
+			return err
+		}
+		if int64(len(part)) != length {
+			return io.ErrUnexpectedEOF
+		}
+		copy(data[offset:], part)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// forEach calls f for each index in [0, n), with at most the configured concurrency in progress,
+// returning the first error encountered
+func (b *Bucket[T]) forEach(n int, f func(i int) error) error {
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+
+	sem := make(chan struct{}, b.opts.concurrency)
+	for i := range n {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if err := f(i); err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	return firstErr
+}
+
+// objectReader reads an object using a ranged read for each call to ReadAt
+type objectReader struct {
+	ctx    context.Context
+	client Client
+	name   string
+	size   int64
+}
+
+func (r *objectReader) ReadAt(p []byte, off int64) (int, error) {
+	if off >= r.size {
+		return 0, io.EOF
+	}
+	length := min(int64(len(p)), r.size-off)
+	b, err := r.client.GetObjectRange(r.ctx, r.name, off, length)
+	if err != nil {
+		return 0, err
+	}
+	n := copy(p, b)
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
@@ -0,0 +1,128 @@
+package objectstore
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gford1000-go/packer"
+	"github.com/gford1000-go/serialise"
+)
+
+// testClient is an in-memory Client, recording the ranged reads made
+type testClient struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	ranges  int
+}
+
+func (c *testClient) PutObject(ctx context.Context, name string, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.objects[name] = data
+	return nil
+}
+
+func (c *testClient) ObjectSize(ctx context.Context, name string) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, ok := c.objects[name]
+	if !ok {
+		return 0, ErrObjectNotFound
+	}
+	return int64(len(b)), nil
+}
+
+func (c *testClient) GetObjectRange(ctx context.Context, name string, offset, length int64) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, ok := c.objects[name]
+	if !ok {
+		return nil, ErrObjectNotFound
+	}
+	c.ranges++
+	return b[offset : offset+length], nil
+}
+
+func TestBucket(t *testing.T) {
+
+	ki := &packer.EnvelopeKeyProviderInfo{ID: "Key1", Key: []byte("01234567890123456789012345678912")}
+	provider, err := packer.NewEnvelopeKeyProvider(ki, func(packer.EnvelopeKeyID) (packer.EnvelopeKeyProvider, error) { return nil, errors.New("unknown") })
+	if err != nil {
+		t.Fatalf("Unexpected error creating provider: %v", err)
+	}
+	serialiser, _ := packer.NewKeySerialiser()
+
+	client := &testClient{objects: map[string][]byte{}}
+	bucket, err := New(client, func(k packer.Key) (string, error) { return k.X + "/" + k.Y, nil },
+		WithPrefix("items/"), WithHashedPrefix(4), WithPartSize(1024))
+	if err != nil {
+		t.Fatalf("Unexpected error creating bucket: %v", err)
+	}
+
+	item := &packer.Item[packer.Key]{Key: packer.Key{X: "A", Y: "B"}, Attributes: map[string]any{}}
+	for i := range 20 {
+		b := make([]byte, 4*1024)
+		if _, err := rand.Read(b); err != nil {
+			t.Fatalf("Unexpected error creating data: %v", err)
+		}
+		item.Attributes[fmt.Sprintf("attr%d", i)] = b
+	}
+
+	pParams := &packer.PackParams[packer.Key]{
+		Provider: provider,
+		Creator:  packer.NewKeyCreator(10),
+		Packer:   serialiser,
+		Approach: serialise.NewMinDataApproachWithVersion(serialise.V1),
+	}
+	info, err := packer.PackInto(context.TODO(), bucket, item, pParams, packer.WithMaximumKBSize(16))
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+	for name := range client.objects {
+		if !strings.HasPrefix(name, "items/") || len(strings.Split(name, "/")) != 4 {
+			t.Fatalf("Unexpected object name: %s", name)
+		}
+	}
+
+	uParams := &packer.UnpackParams[packer.Key]{
+		IDRetriever: func(string) (packer.IDSerialiser[packer.Key], error) { return serialiser, nil },
+		Provider:    provider,
+	}
+	e, err := packer.UnpackFromStore(context.TODO(), bucket, item.Key, uParams)
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+	if client.ranges <= len(client.objects) {
+		t.Fatalf("Expected objects to be read in several parts, got %d reads of %d objects", client.ranges, len(client.objects))
+	}
+	values, err := e.GetAllValues(context.TODO(), provider)
+	if err != nil {
+		t.Fatalf("Unexpected error getting values: %v", err)
+	}
+	if len(values) != len(item.Attributes) {
+		t.Fatalf("Expected %d values, got %d", len(item.Attributes), len(values))
+	}
+
+	// Attribute values can also be read on demand
+	e, err = packer.UnpackReaderAt(context.TODO(), info, uParams, bucket.ReaderAt)
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking with ranged reads: %v", err)
+	}
+	v, found, err := e.GetValue(context.TODO(), "attr3", provider)
+	if err != nil || !found || string(v.([]byte)) != string(item.Attributes["attr3"].([]byte)) {
+		t.Fatalf("Unexpected value: %v, %v", found, err)
+	}
+
+	if _, err := bucket.GetInfo(context.TODO(), packer.Key{X: "missing"}); !errors.Is(err, packer.ErrItemNotInStore) {
+		t.Fatalf("Expected ErrItemNotInStore, got: %v", err)
+	}
+	m, err := bucket.Load(context.TODO(), []packer.Key{{X: "missing"}})
+	if err != nil || len(m) != 0 {
+		t.Fatalf("Expected missing elements to be ignored: %v, %v", len(m), err)
+	}
+}
@@ -0,0 +1,93 @@
+package packer
+
+import (
+	"cmp"
+	"slices"
+	"time"
+)
+
+// OperationStats summarise the work performed by Unpack, so that SLO dashboards can be
+// fed without callers wrapping each call in timers
+type OperationStats struct {
+	// ElementsRequested is the number of element keys passed to the DataLoader
+	ElementsRequested int
+	// ElementsLoaded is the number of element keys successfully loaded by the DataLoader
+	ElementsLoaded int
+	// ChunksLoaded is the number of encrypted attribute chunks returned by the DataLoader
+	ChunksLoaded int
+	// BytesTransferred is the size of the encrypted attribute chunks returned by the DataLoader
+	BytesTransferred uint64
+	// LoaderDuration is the time spent in the DataLoader
+	LoaderDuration time.Duration
+	// DecryptDuration is the time spent decrypting the envelope key and packing details
+	DecryptDuration time.Duration
+	// TotalDuration is the elapsed time of the Unpack
+	TotalDuration time.Duration
+}
+
+// recordLoad updates the stats with the outcome of a DataLoader call
+func (s *OperationStats) recordLoad(requested int, data map[string][]byte, duration time.Duration) {
+	if s == nil {
+		return
+	}
+	s.ElementsRequested += requested
+	s.LoaderDuration += duration
+	if data == nil {
+		return
+	}
+	s.ElementsLoaded += requested
+	s.ChunksLoaded += len(data)
+	for _, v := range data {
+		s.BytesTransferred += uint64(len(v))
+	}
+}
+
+func (s *OperationStats) recordDecrypt(duration time.Duration) {
+	if s == nil {
+		return
+	}
+	s.DecryptDuration += duration
+}
+
+// PackStats summarise the output of Pack, so that capacity planning and monitoring need not measure
+// the returned elements (see WithStatsCollector)
+type PackStats struct {
+	// CiphertextBytes is the total size of the encrypted chunks of all elements
+	CiphertextBytes uint64
+	// Elements is the number of elements returned, including any parity, replica and continuation elements
+	Elements int
+	// Chunks is the number of chunks held across all elements
+	Chunks int
+	// ElementFill is the size of each element (chunk names and values) as a fraction of the maximum size,
+	// fullest first
+	ElementFill []float64
+	// Duration is the elapsed time of the Pack
+	Duration time.Duration
+}
+
+// WithStatsCollector populates the stats with a summary of the output of each successful Pack.  The stats are
+// overwritten by each Pack, so a separate PackStats should be used for each concurrent call.
+func WithStatsCollector(stats *PackStats) func(o *Options) {
+	return func(o *Options) {
+		o.packStats = stats
+	}
+}
+
+// recordPackStats summarises the elements returned by Pack, if stats are requested
+func recordPackStats[T comparable](s *PackStats, start time.Time, itemData map[T]map[string][]byte, maxSize uint64) {
+	if s == nil {
+		return
+	}
+	*s = PackStats{Elements: len(itemData)}
+	for _, attrs := range itemData {
+		var size int
+		for k, v := range attrs {
+			s.CiphertextBytes += uint64(len(v))
+			size += len(k) + len(v)
+		}
+		s.Chunks += len(attrs)
+		s.ElementFill = append(s.ElementFill, float64(size)/float64(maxSize))
+	}
+	slices.SortFunc(s.ElementFill, func(a, b float64) int { return cmp.Compare(b, a) })
+	s.Duration = time.Since(start)
+}
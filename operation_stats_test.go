@@ -0,0 +1,75 @@
+package packer
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"testing"
+)
+
+func TestUnpack_Stats(t *testing.T) {
+
+	testPack, _, provider := testCreateEnv(t)
+
+	item := &Item[Key]{
+		Key: Key{X: "A", Y: "B"},
+		Attributes: map[string]any{
+			"aaa": int64(42),
+			"bbb": "Hello World",
+		},
+	}
+
+	b, l, err := testPack(item)
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+
+	serialiser, _ := NewKeySerialiser()
+
+	stats := &OperationStats{}
+	params := &UnpackParams[Key]{
+		DataLoader:  l,
+		IDRetriever: func(string) (IDSerialiser[Key], error) { return serialiser, nil },
+		Provider:    provider,
+		Stats:       stats,
+	}
+	if _, err := Unpack(context.TODO(), b, params); err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+
+	if stats.ElementsRequested != 1 || stats.ElementsLoaded != 1 || stats.ChunksLoaded != 2 || stats.BytesTransferred == 0 {
+		t.Fatalf("Unexpected stats: %+v", *stats)
+	}
+	if stats.DecryptDuration <= 0 || stats.TotalDuration < stats.DecryptDuration+stats.LoaderDuration {
+		t.Fatalf("Unexpected durations: %+v", *stats)
+	}
+}
+
+func TestWithStatsCollector(t *testing.T) {
+
+	testPack, _, _ := testCreateEnv(t)
+
+	item := &Item[Key]{
+		Key:        Key{X: "A", Y: "B"},
+		Attributes: map[string]any{},
+	}
+	for i := range 4 {
+		b := make([]byte, 8*1024)
+		if _, err := rand.Read(b); err != nil {
+			t.Fatalf("Unexpected error creating data: %v", err)
+		}
+		item.Attributes[fmt.Sprintf("attr%d", i)] = b
+	}
+
+	var stats PackStats
+	if _, _, err := testPack(item, WithMaximumKBSize(20), WithStatsCollector(&stats)); err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+
+	if stats.Elements < 2 || stats.Chunks != 4 || stats.CiphertextBytes < 4*8*1024 || stats.Duration <= 0 {
+		t.Fatalf("Unexpected stats: %+v", stats)
+	}
+	if len(stats.ElementFill) != stats.Elements || stats.ElementFill[0] > 1 || stats.ElementFill[0] < stats.ElementFill[len(stats.ElementFill)-1] {
+		t.Fatalf("Unexpected element fill: %v", stats.ElementFill)
+	}
+}
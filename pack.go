@@ -1,9 +1,12 @@
 package packer
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
+	"time"
 
 	"github.com/gford1000-go/serialise"
 )
@@ -30,6 +33,90 @@ type Options struct {
 	attrNameSize uint8
 	// Number of retries allowed to create unique attribute name
 	attrNameRetries uint8
+	// Maximum size to which random attribute names may grow after collisions
+	attrNameMaxSize uint8
+	// Characters from which attribute names are created, and the first character chosen
+	attrNameAlphabet        string
+	attrNameLeadingAlphabet string
+	// Creates the names of attribute chunks, if not random
+	attrNamer AttributeNamer
+	// Compression applied to attribute values prior to encryption
+	compression Compression
+	// Number of attributes serialised concurrently
+	concurrency uint16
+	// Receives measurements of packing activity
+	metrics MetricsSink
+	// Receives lifecycle events at debug level
+	logger *slog.Logger
+	// Receives progress updates
+	progress ProgressFunc
+	// Limits throughput on behalf of the tenant
+	quota  Quota
+	tenant string
+	// Fail rather than chunk attribute values exceeding maxAttrValueSize
+	rejectOversize bool
+	// Maximum number of attributes allowed in an item
+	maxAttributes uint32
+	// Record checksums of stored chunks
+	checksums bool
+	// Number of Reed-Solomon parity elements to add
+	parityElements uint8
+	// Number of keys under which each element is written
+	replicationFactor uint8
+	// Algorithm used to encrypt attribute values
+	cipherAlgorithm CipherAlgorithm
+	// Encrypt each element's chunks with a derived subkey
+	elementKeys bool
+	// Record the key hierarchy in the envelope, for the tenant
+	keyHierarchy       bool
+	keyHierarchyTenant string
+	// Transformations applied to attribute values before serialisation
+	prePackHooks []TransformHook
+	// Schema enforced on the attributes of items
+	schema *Schema
+	// Key for the digest of the item's plaintext, recorded in the envelope
+	digestKey  []byte
+	digestHash HashAlgorithm
+	// Record the Merkle root of the stored chunks in the envelope
+	merkleRoot bool
+	// Approximate limit on the working memory used to serialise attributes concurrently
+	memoryBudget uint64
+	// Encrypt attribute values using pooled ciphers and pre-derived nonces
+	batchEncryption bool
+	// Provides temporary buffers during packing
+	allocator Allocator
+	// Record the attribute map as a dictionary of names
+	attrDictionary bool
+	// Compression applied to the attribute map and elements of the envelope
+	metadataCompression Compression
+	// Normalises attribute names before packing
+	attrNameNormaliser AttributeNameNormaliser
+	// Rules that attribute names must follow, if validated
+	attrNameRules *AttributeNameRules
+	// Checkpoints serialised attributes, and resumes from an earlier checkpoint
+	checkpoint *packCheckpointer
+	// Embed the chunks of all elements within the packed data
+	inline bool
+	// Group of each attribute whose chunks must share an element
+	attrGroups map[string]string
+	// Receives a summary of the output of Pack
+	packStats *PackStats
+	// Receives the keys of the elements created by Pack, as an *ElementManifest[T]
+	manifest any
+	// Encoding of the outer envelope
+	wireFormat WireFormat
+	// Return the packed data as printable ASCII
+	armored bool
+	// Record a MAC over the visible part of the envelope
+	envelopeMAC bool
+	// Signs the visible part of the envelope
+	signer Signer
+	// Records the reuse of the data encryption key by a BulkPacker
+	keyReuse *KeyReuse
+	// Stage sizes used by PackPipeline
+	pipelinePackers uint16
+	pipelineSavers  uint16
+	pipelineBuffer  uint16
 }
 
 // WithSerialisationOptions allows options for serialisation to be applied
@@ -73,6 +160,55 @@ func WithAttributeNameRetries(retries uint8) func(o *Options) {
 	}
 }
 
+// WithRejectOversizeAttributes causes Pack to fail with an AttributeTooLargeError if the serialised
+// value of any attribute exceeds the maximum attribute value size, rather than splitting it
+// across multiple chunks
+func WithRejectOversizeAttributes() func(o *Options) {
+	return func(o *Options) {
+		o.rejectOversize = true
+	}
+}
+
+// ErrAttributeTooLarge is matched by AttributeTooLargeError, using errors.Is
+var ErrAttributeTooLarge = errors.New("attribute value exceeds the maximum attribute value size")
+
+// AttributeTooLargeError raised by Pack if WithRejectOversizeAttributes is set and an attribute is too large
+type AttributeTooLargeError struct {
+	// Name of the offending attribute
+	Name string
+	// Size of the serialised attribute value
+	Size uint64
+	// MaxSize allowed for an attribute value
+	MaxSize uint64
+}
+
+func (e *AttributeTooLargeError) Error() string {
+	return fmt.Sprintf("%v: attribute '%s' is %d bytes, maximum is %d bytes", ErrAttributeTooLarge, e.Name, e.Size, e.MaxSize)
+}
+
+func (e *AttributeTooLargeError) Unwrap() error {
+	return ErrAttributeTooLarge
+}
+
+// WithMaxAttributes causes Pack to fail with ErrTooManyAttributes if an item has more than n attributes,
+// protecting envelope size and the memory required to unpack.  If not set, any number is allowed.
+func WithMaxAttributes(n uint32) func(o *Options) {
+	return func(o *Options) {
+		o.maxAttributes = n
+	}
+}
+
+// ErrTooManyAttributes raised if an item has more attributes than the configured maximum
+var ErrTooManyAttributes = errors.New("item has more attributes than the maximum allowed")
+
+// WithConcurrency sets the number of attributes that may be serialised and encrypted
+// concurrently during Pack.  If not set, attributes are processed one at a time.
+func WithConcurrency(n uint16) func(o *Options) {
+	return func(o *Options) {
+		o.concurrency = n
+	}
+}
+
 func WithPackingVersion(version PackVersion) func(o *Options) {
 	if version < UnknownVersion || version >= OutOfRange {
 		panic("invalid PackVerion value provided")
@@ -90,9 +226,16 @@ type PackVersion int8
 const (
 	UnknownVersion PackVersion = iota
 	V1
+	// V2 frames the envelope more compactly than V1, reducing its size for small items
+	V2
 	OutOfRange
 )
 
+// supported returns true if Unpack can read data packed with the version
+func (v PackVersion) supported() bool {
+	return v == V1 || v == V2
+}
+
 // PackParams provide details on which mechanism should be used to serialise data
 type PackParams[T comparable] struct {
 	// Provider vends the encryption key for encryption and decryption
@@ -103,6 +246,12 @@ type PackParams[T comparable] struct {
 	Packer IDSerialiser[T]
 	// Approach defines which serialisation approach is used for the attribute data
 	Approach serialise.Approach
+	// EncryptionContext, if not empty, is bound to the data encryption key by the Provider, which must
+	// implement EncryptionContextProvider.  The same EncryptionContext must be supplied to Unpack.
+	EncryptionContext map[string]string
+	// CipherSuite, if not nil, encrypts attribute values in place of the CipherAlgorithm of the options.
+	// The same CipherSuite must be included in the CipherSuites of the UnpackParams.
+	CipherSuite CipherSuite
 }
 
 // ErrParamsNoProvider raised if no Provider is included in PackParms
@@ -130,6 +279,11 @@ func (p *PackParams[T]) validate() error {
 	if p.Approach == nil {
 		return ErrParamsNoApproach
 	}
+	if p.CipherSuite != nil {
+		if err := validateCipherSuite(p.CipherSuite); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -149,6 +303,7 @@ const (
 	defaultMaxSize              uint64      = 350 * 1024
 	defaultAttributeMaxSize     uint64      = 100 * 1024
 	defaultPackingVersion       PackVersion = V1
+	defaultConcurrency          uint16      = 1
 )
 
 // ErrMaxSizeTooSmall raised if the specified max size is too small to guarantee Pack will be successful
@@ -157,13 +312,21 @@ var ErrMaxSizeTooSmall = errors.New("max size must be greater than 10KB")
 // Pack will serialise the contents of the specified item, using the mechanism specified by the params, with
 // optional overrides in behaviour via the options
 // Packing will default to the selection of defaultPackingVersion for the serialisation, if not overridden.
+// If the packed data would exceed the maximum size, the envelope is instead stored in continuation elements,
+// which are returned with the other elements, and the packed data describes the continuation elements.
 func Pack[T comparable](item *Item[T], params *PackParams[T], opts ...func(*Options)) ([]byte, map[T]map[string][]byte, error) {
+	return PackWithContext(context.Background(), item, params, opts...)
+}
+
+// PackWithContext packs the item as Pack, passing the context to the Provider when creating the data encryption key
+// (see ContextualEnvelopeKeyProvider) and to any Quota.  Pack stops if the context is cancelled.
+func PackWithContext[T comparable](ctx context.Context, item *Item[T], params *PackParams[T], opts ...func(*Options)) ([]byte, map[T]map[string][]byte, error) {
 
 	if item == nil || len(item.Attributes) == 0 {
 		return nil, nil, ErrPackNoAttributes
 	}
 
-	return packItem(item, params, opts...)
+	return packItem(ctx, item, params, opts...)
 }
 
 // ErrKeyMustNotBeNil raised if the key passed to PackKey() is nil
@@ -171,11 +334,16 @@ var ErrKeyMustNotBeNil = errors.New("the key passed to PackKey() cannot be nil")
 
 // PackKey creates a packed key only
 func PackKey[T comparable](key *T, params *PackParams[T], opts ...func(*Options)) ([]byte, error) {
+	return PackKeyWithContext(context.Background(), key, params, opts...)
+}
+
+// PackKeyWithContext creates a packed key only, passing the context as PackWithContext
+func PackKeyWithContext[T comparable](ctx context.Context, key *T, params *PackParams[T], opts ...func(*Options)) ([]byte, error) {
 	if key == nil {
 		return nil, ErrKeyMustNotBeNil
 	}
 
-	info, _, err := packItem(&Item[T]{Key: *key, Attributes: map[string]any{}}, params, opts...)
+	info, _, err := packItem(ctx, &Item[T]{Key: *key, Attributes: map[string]any{}}, params, opts...)
 	return info, err
 }
 
@@ -189,7 +357,7 @@ func UnpackKey[T comparable](ctx context.Context, data []byte, params *UnpackPar
 }
 
 // packItem is used by both Pack() and PackKey(), just with different argument checks providing different behaviours
-func packItem[T comparable](item *Item[T], params *PackParams[T], opts ...func(*Options)) (info []byte, itemData map[T]map[string][]byte, e error) {
+func packItem[T comparable](ctx context.Context, item *Item[T], params *PackParams[T], opts ...func(*Options)) (info []byte, itemData map[T]map[string][]byte, e error) {
 
 	defer func() {
 		if r := recover(); r != nil {
@@ -197,6 +365,8 @@ func packItem[T comparable](item *Item[T], params *PackParams[T], opts ...func(*
 		}
 	}()
 
+	start := time.Now()
+
 	if params == nil {
 		return nil, nil, ErrPackNoParams
 	}
@@ -204,30 +374,30 @@ func packItem[T comparable](item *Item[T], params *PackParams[T], opts ...func(*
 		return nil, nil, err
 	}
 
-	o := &Options{}
-	for _, opt := range opts {
-		opt(o)
-	}
-	if o.packingVersion == UnknownVersion {
-		o.packingVersion = defaultPackingVersion
-	}
-	if o.attrNameSize < 2 {
-		o.attrNameSize = defaultAttributeNameSize
-	}
-	if o.attrNameRetries == 0 {
-		o.attrNameRetries = defaultAttributeNameRetries
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
 	}
-	if o.maxSize == 0 {
-		o.maxSize = defaultMaxSize
+
+	o, err := newOptions(opts)
+	if err != nil {
+		return nil, nil, err
 	}
-	if o.maxSize < minSize {
-		return nil, nil, ErrMaxSizeTooSmall
+	o.metrics = metricsOrDefault(o.metrics)
+	o.logger = loggerOrDefault(o.logger)
+
+	if err := o.wireFormat.validate(o.packingVersion); err != nil {
+		return nil, nil, err
 	}
-	if o.maxAttrValueSize == 0 {
-		o.maxAttrValueSize = defaultAttributeMaxSize
+
+	item, err = prepareItem(item, o)
+	if err != nil {
+		return nil, nil, err
 	}
-	if o.maxAttrValueSize > o.maxSize {
-		o.maxAttrValueSize = o.maxSize
+
+	if o.quota != nil {
+		if err := o.quota.Acquire(ctx, o.tenant, 1, 0); err != nil {
+			return nil, nil, err
+		}
 	}
 
 	// Ensure the Approach specified in the params will be used
@@ -237,25 +407,33 @@ func packItem[T comparable](item *Item[T], params *PackParams[T], opts ...func(*
 		o.serialiseOptions = append(o.serialiseOptions, serialise.WithSerialisationApproach(params.Approach))
 	}
 
-	// Retrieve the one-time key details for this packing call
-	encryptedKey, encKey, err := params.Provider.New()
+	// Retrieve the one-time key details for this packing call, unless resuming from a checkpoint
+	var encryptedKey, encKey []byte
+	if o.checkpoint != nil && o.checkpoint.resume != nil {
+		encryptedKey, encKey, err = resumeDataKey(ctx, o.checkpoint.resume, params, o, item.Attributes)
+	} else {
+		encryptedKey, encKey, err = newDataKey(ctx, params)
+	}
 	if err != nil {
 		return nil, nil, err
 	}
-	// Ensure all data is encrypted with this key during serialisation
-	o.serialiseOptions = append(o.serialiseOptions, serialise.WithAESGCMEncryption(encKey))
+	o.logger.DebugContext(ctx, "packer: data encryption key created",
+		slog.String("provider", string(params.Provider.ID())),
+		slog.Int("packingVersion", int(o.packingVersion)))
 
 	var data []byte
 	var attrData map[T]map[string][]byte
 
 	// Process using the selected packing approach
 	switch o.packingVersion {
-	case V1:
+	case V1, V2:
 		d := &itemPackingDetailsV1[T]{
-			params: params,
-			opts:   o,
+			params:   params,
+			opts:     o,
+			progress: newProgressTracker(OperationPack, o.progress),
+			version:  o.packingVersion,
 		}
-		data, attrData, err = d.pack(item, encryptedKey, encKey)
+		data, attrData, err = d.pack(ctx, item, encryptedKey, encKey)
 	default:
 		err = ErrUnsupportedPackVersion
 	}
@@ -265,14 +443,102 @@ func packItem[T comparable](item *Item[T], params *PackParams[T], opts ...func(*
 	}
 
 	// Prefix with the packingVersion selected
-	data, _, err = serialise.ToBytesMany([]any{int8(o.packingVersion), data}, serialise.WithSerialisationApproach(serialise.NewMinDataApproachWithVersion(serialise.V1)))
+	data, err = joinPackingVersion(o.packingVersion, data)
 	if err != nil {
 		return nil, nil, err
 	}
 
+	// Envelopes of very wide items may themselves exceed the maximum size, so are stored as elements
+	if uint64(len(data)) > o.maxSize {
+		if o.inline {
+			return nil, nil, ErrEnvelopeTooLarge
+		}
+		data, err = splitEnvelope(data, attrData, params, o)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	recordPack(o.metrics, start, attrData)
+	recordPackStats(o.packStats, start, attrData, o.maxSize)
+	if err := recordManifest(o.manifest, item.Key, attrData); err != nil {
+		return nil, nil, err
+	}
+
+	if o.armored {
+		data = Armor(data)
+	}
+
 	return data, attrData, nil
 }
 
+// prepareItem checks the attributes of the item against the options, returning the item with any normalised names
+func prepareItem[T comparable](item *Item[T], o *Options) (*Item[T], error) {
+
+	if o.maxAttributes > 0 && len(item.Attributes) > int(o.maxAttributes) {
+		return nil, ErrTooManyAttributes
+	}
+
+	if o.attrNameNormaliser != nil {
+		normalised, err := normaliseItem(item, o.attrNameNormaliser)
+		if err != nil {
+			return nil, err
+		}
+		item = normalised
+	}
+
+	if err := o.attrNameRules.checkNames(item.Attributes); err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+// newOptions applies the options, setting defaults for those not specified
+func newOptions(opts []func(*Options)) (*Options, error) {
+	o := &Options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.packingVersion == UnknownVersion {
+		o.packingVersion = defaultPackingVersion
+	}
+	if o.attrNameSize < 2 {
+		o.attrNameSize = defaultAttributeNameSize
+	}
+	if o.attrNameRetries == 0 {
+		o.attrNameRetries = defaultAttributeNameRetries
+	}
+	if o.maxSize == 0 {
+		o.maxSize = defaultMaxSize
+	}
+	if o.maxSize < minSize {
+		return nil, ErrMaxSizeTooSmall
+	}
+	if o.maxAttrValueSize == 0 {
+		o.maxAttrValueSize = defaultAttributeMaxSize
+	}
+	if o.maxAttrValueSize > o.maxSize {
+		o.maxAttrValueSize = o.maxSize
+	}
+	if o.concurrency == 0 {
+		o.concurrency = defaultConcurrency
+	}
+	return o, nil
+}
+
+// newDataKey returns a data encryption key from the provider, bound to any encryption context
+func newDataKey[T comparable](ctx context.Context, params *PackParams[T]) ([]byte, []byte, error) {
+	if len(params.EncryptionContext) == 0 {
+		return newKey(ctx, params.Provider)
+	}
+	p, ok := params.Provider.(EncryptionContextProvider)
+	if !ok {
+		return nil, nil, ErrProviderDoesNotSupportEncryptionContext
+	}
+	return p.NewWithEncryptionContext(params.EncryptionContext)
+}
+
 // DataLoader retrieves the data stored against the specified keys, combining into a single
 // map as the attributes are assumed to all be unuquely named.
 type DataLoader[T comparable] func(ctx context.Context, keys []T) (map[string][]byte, error)
@@ -288,6 +554,56 @@ type UnpackParams[T comparable] struct {
 	IDRetriever GetIDSerialiser[T]
 	// Provider specifies an EnvelopeKeyProvider that can decrypt the encryption key for the attribute data
 	Provider EnvelopeKeyProvider
+	// Metrics optionally receives measurements of Unpack, and of GetValues on the returned EncryptedItem
+	Metrics MetricsSink
+	// Logger optionally receives lifecycle events of Unpack at debug level
+	Logger *slog.Logger
+	// Progress optionally receives progress updates during Unpack
+	Progress ProgressFunc
+	// Quota optionally limits GetValues on the returned EncryptedItem, for the tenant identified by ContextWithTenant
+	Quota Quota
+	// Stats, if not nil, is populated with a summary of the work performed by Unpack
+	Stats *OperationStats
+	// MaxAttributes, if not zero, causes Unpack to fail with ErrTooManyAttributes for items with more attributes
+	MaxAttributes uint32
+	// MemoryLimit, if not zero, is the maximum number of bytes of loaded element data, reassembled attribute
+	// values and decode buffers that may be held for the item, by Unpack and by GetValues on the returned
+	// EncryptedItem.  Exceeding the limit fails the operation with a MemoryLimitError.
+	MemoryLimit uint64
+	// Allocator, if not nil, provides the temporary buffers used by GetValues on the returned EncryptedItem
+	Allocator Allocator
+	// AttributeNameNormaliser, if not nil, is applied to the attribute names requested from GetValues on the
+	// returned EncryptedItem.  It should be the normaliser used during Pack (see WithAttributeNameNormaliser).
+	AttributeNameNormaliser AttributeNameNormaliser
+	// PostUnpackHooks are applied, in order, to each attribute value after decryption by GetValues on the returned EncryptedItem
+	PostUnpackHooks []TransformHook
+	// Schema, if not nil, is enforced on the values returned by GetValues on the returned EncryptedItem,
+	// after any PostUnpackHooks have been applied
+	Schema *Schema
+	// EncryptionContext must match the EncryptionContext supplied to Pack, if any.  It is passed to the Provider
+	// during Unpack, and by GetValues on the returned EncryptedItem (see ContextWithEncryptionContext).
+	EncryptionContext map[string]string
+	// AutoRewrap, if not nil, causes GetValues on the returned EncryptedItem to rewrap the data encryption key
+	// in the background if the key that wraps it is close to expiry
+	AutoRewrap *AutoRewrap[T]
+	// Checkpoint, if not nil, causes Unpack to load elements in batches, checkpointing after each so that
+	// an interrupted Unpack can be resumed
+	Checkpoint *UnpackCheckpointing
+	// Aliases maps legacy attribute names to their current names, so that items packed before a rename
+	// are returned using the current names.  Renames may be chained (e.g. "a" → "b" and "b" → "c").
+	Aliases map[string]string
+	// CipherSuites are the CipherSuites that may have been used to pack items (see PackParams)
+	CipherSuites []CipherSuite
+	// CacheDataKey causes the returned EncryptedItem to retain the data encryption key once decrypted, so that
+	// repeated GetValues calls do not each call the provider, until the EncryptedItem is closed.  As the provider
+	// is then not consulted, any access checks it performs apply only to the first call.
+	CacheDataKey bool
+	// RequireEnvelopeMAC causes Unpack to fail with ErrEnvelopeMACRequired for data packed without a MAC
+	// (see WithEnvelopeMAC), so that a MAC cannot be removed to avoid its verification
+	RequireEnvelopeMAC bool
+	// Verifiers, if not empty, cause Unpack to require packed data to be signed by the Signer of one of the
+	// Verifiers (see WithSigner), failing with ErrSignatureRequired, ErrUnknownSigner or ErrInvalidSignature
+	Verifiers []Verifier
 }
 
 // ErrDataLoaderIsNil raised if no data loader is specified in the UnpackParams passed to Unpack
@@ -321,7 +637,8 @@ var ErrUnpackNoParams = errors.New("params must be provided to Unpack")
 // ErrUnpackInvalidData raised if the data does not deserialise
 var ErrUnpackInvalidData = errors.New("unable to unpack - invalid data")
 
-// Unpack deserialises a byte slice that was prepared using Pack
+// Unpack deserialises a byte slice that was prepared using Pack, loading any continuation elements
+// holding the envelope using the DataLoader of the params
 func Unpack[T comparable](ctx context.Context, data []byte, params *UnpackParams[T]) (i *EncryptedItem[T], e error) {
 
 	defer func() {
@@ -339,30 +656,135 @@ func Unpack[T comparable](ctx context.Context, data []byte, params *UnpackParams
 	if err := params.validate(); err != nil {
 		return nil, err
 	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
-	v, err := serialise.FromBytesMany(data, serialise.NewMinDataApproachWithVersion(serialise.V1))
+	ctx = params.withEncryptionContext(ctx)
+
+	start := time.Now()
+	metrics := metricsOrDefault(params.Metrics)
+	provider := &measuredProvider{EnvelopeKeyProvider: params.Provider, metrics: metrics}
+	loader := loggedDataLoader(loggerOrDefault(params.Logger), params.DataLoader)
+
+	continuation, err := continuationKeys(data, params.IDRetriever)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err = JoinEnvelope(ctx, data, loader, params.IDRetriever)
 	if err != nil {
 		return nil, err
 	}
+
+	packingVersion, b, err := splitPackingVersion(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var item *EncryptedItem[T]
+
+	switch packingVersion {
+	case V1, V2:
+		d := &itemPackingDetailsV1[T]{
+			version:       packingVersion,
+			progress:      newProgressTracker(OperationUnpack, params.Progress),
+			stats:         params.Stats,
+			maxAttributes: params.MaxAttributes,
+			checkpoint:    params.Checkpoint,
+			memory:        newMemoryTracker(params.MemoryLimit),
+			allocator:     allocatorOrDefault(params.Allocator),
+			requireMAC:    params.RequireEnvelopeMAC,
+			verifiers:     params.Verifiers,
+		}
+		item, err = d.unpack(ctx, b, provider, loader, params.IDRetriever)
+	default:
+		err = ErrUnsupportedPackVersion
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	item.elements = append(item.elements, continuation...)
+	params.apply(item, metrics)
+
+	if params.Stats != nil {
+		params.Stats.TotalDuration = time.Since(start)
+	}
+
+	metrics.Add(MetricUnpacks, 1)
+	metrics.Observe(MetricUnpackDuration, time.Since(start).Seconds())
+
+	return item, nil
+}
+
+// withEncryptionContext adds any encryption context to the context, for the provider
+func (u *UnpackParams[T]) withEncryptionContext(ctx context.Context) context.Context {
+	if len(u.EncryptionContext) == 0 {
+		return ctx
+	}
+	return ContextWithEncryptionContext(ctx, u.EncryptionContext)
+}
+
+// apply sets the behaviour requested by the params on the unpacked item
+func (u *UnpackParams[T]) apply(item *EncryptedItem[T], metrics MetricsSink) {
+	item.applyAliases(u.Aliases)
+	item.metrics = metrics
+	item.quota = u.Quota
+	item.hooks = u.PostUnpackHooks
+	item.schema = u.Schema
+	item.encryptionContext = u.EncryptionContext
+	item.autoRewrap = u.AutoRewrap
+	item.normaliser = u.AttributeNameNormaliser
+	if u.CacheDataKey {
+		item.keyCache = &dataKeyCache{}
+	}
+	if len(item.suiteID) > 0 {
+		item.suite = findCipherSuite(u.CipherSuites, item.suiteID)
+	}
+}
+
+// splitPackingVersion separates the data returned by Pack into the packing version and the versioned data
+func splitPackingVersion(data []byte) (PackVersion, []byte, error) {
+
+	data, err := Dearmor(data)
+	if err != nil {
+		return UnknownVersion, nil, err
+	}
+
+	if isV2(data) {
+		return V2, data[len(v2Prefix):], nil
+	}
+
+	v, err := serialise.FromBytesMany(data, serialise.NewMinDataApproachWithVersion(serialise.V1))
+	if err != nil {
+		return UnknownVersion, nil, err
+	}
+	if isContinuation(v) {
+		return UnknownVersion, nil, ErrEnvelopeContinued
+	}
 	if len(v) != 2 {
-		return nil, ErrUnpackInvalidData
+		return UnknownVersion, nil, ErrUnpackInvalidData
 	}
 
 	packingVersion, ok := v[0].(int8)
 	if !ok {
-		return nil, ErrUnpackInvalidData
+		return UnknownVersion, nil, ErrUnpackInvalidData
 	}
 
 	b, ok := v[1].([]byte)
 	if !ok {
-		return nil, ErrUnpackInvalidData
+		return UnknownVersion, nil, ErrUnpackInvalidData
 	}
 
-	switch PackVersion(packingVersion) {
-	case V1:
-		d := &itemPackingDetailsV1[T]{}
-		return d.unpack(ctx, b, params.Provider, params.DataLoader, params.IDRetriever)
-	default:
-		return nil, ErrUnsupportedPackVersion
+	return PackVersion(packingVersion), b, nil
+}
+
+// joinPackingVersion is the inverse of splitPackingVersion
+func joinPackingVersion(packingVersion PackVersion, data []byte) ([]byte, error) {
+	if packingVersion == V2 {
+		return append(bytes.Clone(v2Prefix), data...), nil
 	}
+	b, _, err := serialise.ToBytesMany([]any{int8(packingVersion), data}, serialise.WithSerialisationApproach(serialise.NewMinDataApproachWithVersion(serialise.V1)))
+	return b, err
 }
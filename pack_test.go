@@ -3,6 +3,7 @@ package packer
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
 	"errors"
 	"fmt"
 	"strings"
@@ -16,7 +17,7 @@ type testHandler interface {
 	Fatalf(string, ...any)
 }
 
-func testCreateEnv(t testHandler) (func(item *Item[Key]) ([]byte, DataLoader[Key], error), func(data []byte, dataLoader DataLoader[Key]) (*EncryptedItem[Key], error), EnvelopeKeyProvider) {
+func testCreateEnv(t testHandler) (func(item *Item[Key], opts ...func(*Options)) ([]byte, DataLoader[Key], error), func(data []byte, dataLoader DataLoader[Key]) (*EncryptedItem[Key], error), EnvelopeKeyProvider) {
 	getProvider := func() EnvelopeKeyProvider {
 		ki := &EnvelopeKeyProviderInfo{
 			ID:  "Key1",
@@ -52,7 +53,7 @@ func testCreateEnv(t testHandler) (func(item *Item[Key]) ([]byte, DataLoader[Key
 		return serialiser, nil
 	}
 
-	testPack := func(item *Item[Key]) ([]byte, DataLoader[Key], error) {
+	testPack := func(item *Item[Key], opts ...func(*Options)) ([]byte, DataLoader[Key], error) {
 
 		pParams := &PackParams[Key]{
 			Provider: provider,
@@ -61,7 +62,7 @@ func testCreateEnv(t testHandler) (func(item *Item[Key]) ([]byte, DataLoader[Key
 			Approach: serialise.NewMinDataApproachWithVersion(serialise.V1),
 		}
 
-		info, data, err := Pack[Key](item, pParams)
+		info, data, err := Pack[Key](item, pParams, opts...)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -914,6 +915,38 @@ func BenchmarkEncryptedItem_GetValues(b *testing.B) {
 	}
 }
 
+func BenchmarkEncryptedItem_GetValue(b *testing.B) {
+	packer, unpacker, provider := testCreateEnv(b)
+
+	item := &Item[Key]{
+		Key: Key{X: "A", Y: "B"},
+		Attributes: map[string]any{
+			"first name": string("Fred"),
+			"last name":  string("Flintstone"),
+			"title":      "Mr",
+		},
+	}
+
+	data, loader, err := packer(item)
+	if err != nil {
+		b.Fatalf("Unexpected error: %v", err)
+	}
+
+	ei, err := unpacker(data, loader)
+	if err != nil {
+		b.Fatalf("Unexpected error: %v", err)
+	}
+
+	ctx := context.TODO()
+
+	for i := 0; i < b.N; i++ {
+		_, _, err := ei.GetValue(ctx, "first name", provider)
+		if err != nil {
+			b.Fatalf("Unexpected error: %v", err)
+		}
+	}
+}
+
 var longStr = strings.Repeat("Hello World;", 10000)
 
 func BenchmarkLargeEncryptedItem_GetValues(b *testing.B) {
@@ -1277,3 +1310,203 @@ func TestPackKey_1(t *testing.T) {
 		t.Fatal("Unexpected DataLoader returned from PackKey")
 	}
 }
+
+func TestPack_12(t *testing.T) {
+
+	// Random data is incompressible, so is guaranteed to exceed the attribute value size
+	data := make([]byte, 50*1024)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatalf("Unexpected error creating data: %v", err)
+	}
+
+	item := &Item[Key]{
+		Key: Key{X: "A", Y: "B"},
+		Attributes: map[string]any{
+			"small": int64(42),
+			"large": data,
+		},
+	}
+
+	testPack, testUnpack, provider := testCreateEnv(t)
+
+	_, _, err := testPack(item, WithAttributeValueMaximumKBSize(10), WithRejectOversizeAttributes())
+	if !errors.Is(err, ErrAttributeTooLarge) {
+		t.Fatalf("Unexpected error: expected: %v, got: %v", ErrAttributeTooLarge, err)
+	}
+	var tooLarge *AttributeTooLargeError
+	if !errors.As(err, &tooLarge) {
+		t.Fatalf("Unexpected error type: %T", err)
+	}
+	if tooLarge.Name != "large" || tooLarge.MaxSize != 10*1024 || tooLarge.Size <= tooLarge.MaxSize {
+		t.Fatalf("Unexpected error details: %+v", *tooLarge)
+	}
+
+	// Without the option, the value is chunked and recovered intact
+	b, l, err := testPack(item, WithAttributeValueMaximumKBSize(10))
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+
+	e, err := testUnpack(b, l)
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+
+	m, err := e.GetValues(context.TODO(), []string{"large"}, provider)
+	if err != nil {
+		t.Fatalf("Unexpected error getting values: %v", err)
+	}
+	compareValue(m["large"], data, "[]byte", t)
+}
+
+func TestPack_13(t *testing.T) {
+
+	item := &Item[Key]{
+		Key:        Key{X: "A", Y: "B"},
+		Attributes: map[string]any{},
+	}
+	for i := range 10 {
+		item.Attributes[fmt.Sprintf("attr%d", i)] = int64(i)
+	}
+
+	testPack, _, provider := testCreateEnv(t)
+
+	if _, _, err := testPack(item, WithMaxAttributes(9)); !errors.Is(err, ErrTooManyAttributes) {
+		t.Fatalf("Unexpected error: expected: %v, got: %v", ErrTooManyAttributes, err)
+	}
+
+	b, l, err := testPack(item, WithMaxAttributes(10))
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+
+	serialiser, _ := NewKeySerialiser()
+
+	params := &UnpackParams[Key]{
+		DataLoader:    l,
+		IDRetriever:   func(string) (IDSerialiser[Key], error) { return serialiser, nil },
+		Provider:      provider,
+		MaxAttributes: 5,
+	}
+	if _, err := Unpack(context.TODO(), b, params); !errors.Is(err, ErrTooManyAttributes) {
+		t.Fatalf("Unexpected error: expected: %v, got: %v", ErrTooManyAttributes, err)
+	}
+
+	params.MaxAttributes = 10
+	if _, err := Unpack(context.TODO(), b, params); err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+}
+
+type testContextKey struct{}
+
+// contextualProvider records the value of testContextKey in the context of each key created
+type contextualProvider struct {
+	EnvelopeKeyProvider
+	seen []any
+}
+
+func (c *contextualProvider) NewWithContext(ctx context.Context) ([]byte, []byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	c.seen = append(c.seen, ctx.Value(testContextKey{}))
+	return c.EnvelopeKeyProvider.New()
+}
+
+func TestPackWithContext(t *testing.T) {
+
+	_, _, provider := testCreateEnv(t)
+
+	serialiser, _ := NewKeySerialiser()
+	contextual := &contextualProvider{EnvelopeKeyProvider: provider}
+
+	// Wrapping providers pass the context to the provider they wrap
+	encryptOnly, _ := NewEncryptOnly(contextual)
+
+	params := &PackParams[Key]{
+		Provider: encryptOnly,
+		Creator:  NewKeyCreator(defaultLen),
+		Packer:   serialiser,
+		Approach: serialise.NewMinDataApproachWithVersion(serialise.V1),
+	}
+	item := &Item[Key]{Key: Key{X: "A", Y: "B"}, Attributes: map[string]any{"aaa": "Hello World"}}
+
+	ctx := context.WithValue(context.TODO(), testContextKey{}, "caller")
+
+	info, data, err := PackWithContext(ctx, item, params)
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+	if _, err := PackKeyWithContext(ctx, &item.Key, params); err != nil {
+		t.Fatalf("Unexpected error packing key: %v", err)
+	}
+	if len(contextual.seen) != 2 || contextual.seen[0] != "caller" || contextual.seen[1] != "caller" {
+		t.Fatalf("Expected the context to reach the provider: %v", contextual.seen)
+	}
+
+	loader := func(ctx context.Context, keys []Key) (map[string][]byte, error) {
+		attrs := map[string][]byte{}
+		for _, key := range keys {
+			for k, v := range data[key] {
+				attrs[k] = v
+			}
+		}
+		return attrs, nil
+	}
+	if _, err := Unpack(context.TODO(), info, &UnpackParams[Key]{
+		DataLoader:  loader,
+		IDRetriever: func(string) (IDSerialiser[Key], error) { return serialiser, nil },
+		Provider:    provider,
+	}); err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+
+	cancelled, cancel := context.WithCancel(context.TODO())
+	cancel()
+	if _, _, err := PackWithContext(cancelled, item, params); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got: %v", err)
+	}
+}
+
+func TestUnpackCancellation(t *testing.T) {
+
+	testPack, _, provider := testCreateEnv(t)
+
+	item := &Item[Key]{Key: Key{X: "A", Y: "B"}, Attributes: map[string]any{"a": "x", "b": int64(2)}}
+
+	info, l, err := testPack(item)
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+
+	serialiser, _ := NewKeySerialiser()
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	loads := 0
+	params := &UnpackParams[Key]{
+		DataLoader: func(_ context.Context, keys []Key) (map[string][]byte, error) {
+			// The loader ignores the context, completing the load despite the cancellation
+			loads++
+			cancel()
+			return l(context.TODO(), keys)
+		},
+		IDRetriever: func(string) (IDSerialiser[Key], error) { return serialiser, nil },
+		Provider:    provider,
+	}
+
+	if _, err := Unpack(ctx, info, params); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got: %v", err)
+	}
+	if loads != 1 {
+		t.Fatalf("Expected a single load, got %d", loads)
+	}
+
+	// Already cancelled, so nothing is loaded
+	if _, err := Unpack(ctx, info, params); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got: %v", err)
+	}
+	if loads != 1 {
+		t.Fatalf("Expected no further loads, got %d", loads)
+	}
+}
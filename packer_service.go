@@ -0,0 +1,102 @@
+package packer
+
+import (
+	"context"
+)
+
+// Packer packs and unpacks items using parameters and options that are provided, and validated, once
+// when it is created, which suits long running services.  A Packer is safe for concurrent use, provided
+// that the parameters and options are.
+type Packer[T comparable] struct {
+	pack   PackParams[T]
+	unpack *UnpackParams[T]
+	opts   []func(*Options)
+}
+
+// NewPacker creates a Packer, validating the params and options.  The UnpackParams may be nil, in
+// which case the Packer can only pack items; Unpack and its related methods then raise ErrUnpackNoParams.
+func NewPacker[T comparable](pack *PackParams[T], unpack *UnpackParams[T], opts ...func(*Options)) (*Packer[T], error) {
+	if pack == nil {
+		return nil, ErrPackNoParams
+	}
+	if err := pack.validate(); err != nil {
+		return nil, err
+	}
+	if unpack != nil {
+		if err := unpack.validate(); err != nil {
+			return nil, err
+		}
+		u := *unpack
+		unpack = &u
+	}
+	if _, err := newOptions(opts); err != nil {
+		return nil, err
+	}
+
+	return &Packer[T]{
+		pack:   *pack,
+		unpack: unpack,
+		opts:   opts,
+	}, nil
+}
+
+// Pack serialises the item as Pack, applying the options of the Packer followed by any additional options
+func (p *Packer[T]) Pack(item *Item[T], opts ...func(*Options)) ([]byte, map[T]map[string][]byte, error) {
+	return Pack(item, &p.pack, p.options(opts)...)
+}
+
+// PackWithContext serialises the item as PackWithContext, applying the options as Pack
+func (p *Packer[T]) PackWithContext(ctx context.Context, item *Item[T], opts ...func(*Options)) ([]byte, map[T]map[string][]byte, error) {
+	return PackWithContext(ctx, item, &p.pack, p.options(opts)...)
+}
+
+// PackKey creates a packed key only, as PackKey
+func (p *Packer[T]) PackKey(key *T, opts ...func(*Options)) ([]byte, error) {
+	return PackKey(key, &p.pack, p.options(opts)...)
+}
+
+// Unpack deserialises the data as Unpack
+func (p *Packer[T]) Unpack(ctx context.Context, data []byte) (*EncryptedItem[T], error) {
+	if p.unpack == nil {
+		return nil, ErrUnpackNoParams
+	}
+	return Unpack(ctx, data, p.unpack)
+}
+
+// UnpackKey returns the key packed using PackKey, as UnpackKey
+func (p *Packer[T]) UnpackKey(ctx context.Context, data []byte) (*T, error) {
+	if p.unpack == nil {
+		return nil, ErrUnpackNoParams
+	}
+	return UnpackKey(ctx, data, p.unpack)
+}
+
+// GetValues unpacks the data and decrypts the requested attributes, using the Provider of the UnpackParams
+func (p *Packer[T]) GetValues(ctx context.Context, data []byte, attrs []string, opts ...func(*GetValuesOptions)) (map[string]any, error) {
+	e, err := p.Unpack(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+	return e.GetValues(ctx, attrs, p.unpack.Provider, opts...)
+}
+
+// GetValuesMany unpacks each of the packed items and decrypts the same attributes from each, as GetValuesMany
+func (p *Packer[T]) GetValuesMany(ctx context.Context, data [][]byte, attrs []string, opts ...func(*GetValuesOptions)) ([]map[string]any, error) {
+	items := make([]*EncryptedItem[T], len(data))
+	for i, b := range data {
+		e, err := p.Unpack(ctx, b)
+		if err != nil {
+			return nil, err
+		}
+		items[i] = e
+	}
+	return GetValuesMany(ctx, items, attrs, p.unpack.Provider, opts...)
+}
+
+// options returns the options of the Packer, followed by the additional options
+func (p *Packer[T]) options(opts []func(*Options)) []func(*Options) {
+	if len(opts) == 0 {
+		return p.opts
+	}
+	return append(append([]func(*Options){}, p.opts...), opts...)
+}
@@ -0,0 +1,90 @@
+package packer
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"reflect"
+	"testing"
+
+	"github.com/gford1000-go/serialise"
+)
+
+func TestNewPacker(t *testing.T) {
+
+	_, _, provider := testCreateEnv(t)
+	serialiser, _ := NewKeySerialiser()
+
+	pParams := &PackParams[Key]{
+		Provider: provider,
+		Creator:  NewKeyCreator(defaultLen),
+		Packer:   serialiser,
+		Approach: serialise.NewMinDataApproachWithVersion(serialise.V1),
+	}
+
+	stored := map[Key]map[string][]byte{}
+	uParams := &UnpackParams[Key]{
+		DataLoader: func(ctx context.Context, keys []Key) (map[string][]byte, error) {
+			m := map[string][]byte{}
+			for _, k := range keys {
+				maps.Copy(m, stored[k])
+			}
+			return m, nil
+		},
+		IDRetriever: func(string) (IDSerialiser[Key], error) { return serialiser, nil },
+		Provider:    provider,
+	}
+
+	p, err := NewPacker(pParams, uParams, WithCompression(FlateCompression))
+	if err != nil {
+		t.Fatalf("Unexpected error creating Packer: %v", err)
+	}
+
+	item := &Item[Key]{
+		Key:        Key{X: "A", Y: "B"},
+		Attributes: map[string]any{"a": "hello", "b": int64(2)},
+	}
+	info, data, err := p.Pack(item)
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+	maps.Copy(stored, data)
+
+	values, err := p.GetValues(context.TODO(), info, []string{"a", "b"})
+	if err != nil {
+		t.Fatalf("Unexpected error getting values: %v", err)
+	}
+	if !reflect.DeepEqual(values, item.Attributes) {
+		t.Fatalf("Mismatch: expected %v, got %v", item.Attributes, values)
+	}
+
+	many, err := p.GetValuesMany(context.TODO(), [][]byte{info, info}, []string{"a"})
+	if err != nil || len(many) != 2 || many[1]["a"] != "hello" {
+		t.Fatalf("Unexpected result from GetValuesMany: %v, %v", many, err)
+	}
+
+	key := Key{X: "K", Y: "L"}
+	bKey, err := p.PackKey(&key)
+	if err != nil {
+		t.Fatalf("Unexpected error packing key: %v", err)
+	}
+	if k, err := p.UnpackKey(context.TODO(), bKey); err != nil || *k != key {
+		t.Fatalf("Unexpected key: %v, %v", k, err)
+	}
+
+	// Parameters and options are validated when the Packer is created
+	if _, err := NewPacker(&PackParams[Key]{}, nil); !errors.Is(err, ErrParamsNoProvider) {
+		t.Fatalf("Expected ErrParamsNoProvider, got: %v", err)
+	}
+	if _, err := NewPacker(pParams, &UnpackParams[Key]{}); !errors.Is(err, ErrDataLoaderIsNil) {
+		t.Fatalf("Expected ErrDataLoaderIsNil, got: %v", err)
+	}
+	if _, err := NewPacker(pParams, nil, WithMaximumKBSize(1)); !errors.Is(err, ErrMaxSizeTooSmall) {
+		t.Fatalf("Expected ErrMaxSizeTooSmall, got: %v", err)
+	}
+
+	packOnly, _ := NewPacker(pParams, nil)
+	if _, err := packOnly.Unpack(context.TODO(), info); !errors.Is(err, ErrUnpackNoParams) {
+		t.Fatalf("Expected ErrUnpackNoParams, got: %v", err)
+	}
+}
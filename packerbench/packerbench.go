@@ -0,0 +1,353 @@
+// Package packerbench generates synthetic items of a configurable shape and measures Pack, Unpack
+// and GetValues against them, reporting time and allocations per operation, so that the options of
+// the packer package can be evaluated against a representative workload before deployment.
+package packerbench
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	mrand "math/rand"
+	"runtime"
+	"runtime/pprof"
+	"strings"
+	"time"
+
+	"github.com/gford1000-go/packer"
+	"github.com/gford1000-go/serialise"
+)
+
+// ValueType identifies the type of synthetic attribute values
+type ValueType string
+
+const (
+	// String values of ValueSize characters
+	String ValueType = "string"
+	// Bytes values of ValueSize random bytes
+	Bytes ValueType = "bytes"
+	// Int64 values
+	Int64 ValueType = "int64"
+	// Float64 values
+	Float64 ValueType = "float64"
+	// Time values
+	Time ValueType = "time"
+	// Strings values, holding ValueSize/16 strings of 16 characters
+	Strings ValueType = "strings"
+)
+
+// Shape describes the synthetic items to be generated
+type Shape struct {
+	// Attributes is the number of attributes of each item
+	Attributes int
+	// ValueSize is the approximate size in bytes of variable length values
+	ValueSize int
+	// Types are assigned to attributes in rotation; if empty, String is used
+	Types []ValueType
+	// Seed makes the generated values reproducible
+	Seed int64
+}
+
+// ErrInvalidShape raised if the Shape does not describe at least one attribute
+var ErrInvalidShape = errors.New("shape must have at least one attribute")
+
+// ErrUnknownValueType raised if the Shape includes an unrecognised ValueType
+var ErrUnknownValueType = errors.New("unknown value type")
+
+// Item returns a synthetic item of the Shape
+func (s Shape) Item() (*packer.Item[packer.Key], error) {
+
+	if s.Attributes < 1 {
+		return nil, ErrInvalidShape
+	}
+
+	types := s.Types
+	if len(types) == 0 {
+		types = []ValueType{String}
+	}
+
+	r := mrand.New(mrand.NewSource(s.Seed))
+
+	item := &packer.Item[packer.Key]{
+		Key:        packer.Key{X: fmt.Sprintf("bench-%d", s.Seed), Y: "item"},
+		Attributes: make(map[string]any, s.Attributes),
+	}
+	for i := range s.Attributes {
+		v, err := value(r, types[i%len(types)], s.ValueSize)
+		if err != nil {
+			return nil, err
+		}
+		item.Attributes[fmt.Sprintf("attr%06d", i)] = v
+	}
+	return item, nil
+}
+
+const letters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+func randomString(r *mrand.Rand, n int) string {
+	var sb strings.Builder
+	sb.Grow(n)
+	for range n {
+		sb.WriteByte(letters[r.Intn(len(letters))])
+	}
+	return sb.String()
+}
+
+func value(r *mrand.Rand, t ValueType, size int) (any, error) {
+	switch t {
+	case String:
+		return randomString(r, size), nil
+	case Bytes:
+		b := make([]byte, size)
+		r.Read(b)
+		return b, nil
+	case Int64:
+		return r.Int63(), nil
+	case Float64:
+		return r.Float64(), nil
+	case Time:
+		return time.Unix(r.Int63n(1<<32), 0).UTC(), nil
+	case Strings:
+		ss := make([]string, max(1, size/16))
+		for i := range ss {
+			ss[i] = randomString(r, 16)
+		}
+		return ss, nil
+	default:
+		return nil, ErrUnknownValueType
+	}
+}
+
+// Options control the measurements made by Run
+type Options struct {
+	iterations int
+	packOpts   []func(*packer.Options)
+	getValues  int
+	provider   packer.EnvelopeKeyProvider
+	cpuProfile io.Writer
+}
+
+// WithIterations sets the number of times each operation is measured; the default is 10
+func WithIterations(n int) func(*Options) {
+	return func(o *Options) {
+		o.iterations = n
+	}
+}
+
+// WithPackOptions sets the packer options evaluated by Run
+func WithPackOptions(opts ...func(*packer.Options)) func(*Options) {
+	return func(o *Options) {
+		o.packOpts = append(o.packOpts, opts...)
+	}
+}
+
+// WithGetValuesAttributes sets the number of attributes requested in each GetValues; the default is all of them
+func WithGetValuesAttributes(n int) func(*Options) {
+	return func(o *Options) {
+		o.getValues = n
+	}
+}
+
+// WithProvider sets the EnvelopeKeyProvider used, so that the cost of a remote key service can be included.
+// If not set, an in-memory provider with a random key is used.
+func WithProvider(provider packer.EnvelopeKeyProvider) func(*Options) {
+	return func(o *Options) {
+		o.provider = provider
+	}
+}
+
+// WithCPUProfile writes a CPU profile of the measured operations to w, in the format read by go tool pprof
+func WithCPUProfile(w io.Writer) func(*Options) {
+	return func(o *Options) {
+		o.cpuProfile = w
+	}
+}
+
+// Operation identifies the operation measured by a Result
+type Operation string
+
+const (
+	// OperationPack measures packer.Pack
+	OperationPack Operation = "pack"
+	// OperationUnpack measures packer.Unpack
+	OperationUnpack Operation = "unpack"
+	// OperationGetValues measures GetValues of the unpacked item
+	OperationGetValues Operation = "getValues"
+)
+
+// Result summarises the measurements of an operation
+type Result struct {
+	// Operation measured
+	Operation Operation
+	// Iterations is the number of times the operation was performed
+	Iterations int
+	// NsPerOp is the mean elapsed time of the operation
+	NsPerOp int64
+	// AllocsPerOp is the mean number of heap allocations made by the operation
+	AllocsPerOp uint64
+	// BytesPerOp is the mean number of bytes allocated by the operation
+	BytesPerOp uint64
+	// Elements is the number of elements created by Pack
+	Elements int
+	// StoredBytes is the size of the packed data and elements created by Pack
+	StoredBytes int
+}
+
+// String returns the Result in a format similar to go test -bench
+func (r Result) String() string {
+	return fmt.Sprintf("%-10s %8d %12d ns/op %10d B/op %8d allocs/op %6d elements %10d stored bytes",
+		r.Operation, r.Iterations, r.NsPerOp, r.BytesPerOp, r.AllocsPerOp, r.Elements, r.StoredBytes)
+}
+
+// Run generates an item of the Shape and measures Pack, Unpack and GetValues of it with the options,
+// returning a Result for each operation.  Allocations are measured for the whole process, so Run
+// should not be used concurrently with other work.
+func Run(ctx context.Context, shape Shape, opts ...func(*Options)) ([]Result, error) {
+
+	o := &Options{iterations: 10}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.iterations < 1 {
+		o.iterations = 1
+	}
+
+	item, err := shape.Item()
+	if err != nil {
+		return nil, err
+	}
+
+	provider := o.provider
+	if provider == nil {
+		if provider, err = newProvider(); err != nil {
+			return nil, err
+		}
+	}
+
+	serialiser, err := packer.NewKeySerialiser()
+	if err != nil {
+		return nil, err
+	}
+
+	pParams := &packer.PackParams[packer.Key]{
+		Provider: provider,
+		Creator:  packer.NewKeyCreatorFromKey(item.Key, 16),
+		Packer:   serialiser,
+		Approach: serialise.NewMinDataApproachWithVersion(serialise.V1),
+	}
+
+	if o.cpuProfile != nil {
+		if err := pprof.StartCPUProfile(o.cpuProfile); err != nil {
+			return nil, err
+		}
+		defer pprof.StopCPUProfile()
+	}
+
+	var info []byte
+	var data map[packer.Key]map[string][]byte
+	pack, err := measure(o.iterations, func() (err error) {
+		info, data, err = packer.Pack(item, pParams, o.packOpts...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	pack.Operation = OperationPack
+	pack.Elements = len(data)
+	pack.StoredBytes = len(info)
+	for _, m := range data {
+		for k, v := range m {
+			pack.StoredBytes += len(k) + len(v)
+		}
+	}
+
+	uParams := &packer.UnpackParams[packer.Key]{
+		Provider:    provider,
+		IDRetriever: func(string) (packer.IDSerialiser[packer.Key], error) { return serialiser, nil },
+		DataLoader: func(ctx context.Context, keys []packer.Key) (map[string][]byte, error) {
+			m := map[string][]byte{}
+			for _, k := range keys {
+				for n, v := range data[k] {
+					m[n] = v
+				}
+			}
+			return m, nil
+		},
+	}
+
+	var e *packer.EncryptedItem[packer.Key]
+	unpack, err := measure(o.iterations, func() (err error) {
+		e, err = packer.Unpack(ctx, info, uParams)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	unpack.Operation = OperationUnpack
+
+	attrs := make([]string, 0, len(item.Attributes))
+	for k := range item.Attributes {
+		attrs = append(attrs, k)
+	}
+	if o.getValues > 0 && o.getValues < len(attrs) {
+		attrs = attrs[:o.getValues]
+	}
+
+	getValues, err := measure(o.iterations, func() error {
+		_, err := e.GetValues(ctx, attrs, provider)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	getValues.Operation = OperationGetValues
+
+	return []Result{pack, unpack, getValues}, nil
+}
+
+// measure performs f the specified number of times, recording the mean duration and allocations
+func measure(iterations int, f func() error) (Result, error) {
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	start := time.Now()
+	for range iterations {
+		if err := f(); err != nil {
+			return Result{}, err
+		}
+	}
+	elapsed := time.Since(start)
+
+	runtime.ReadMemStats(&after)
+
+	n := uint64(iterations)
+	return Result{
+		Iterations:  iterations,
+		NsPerOp:     elapsed.Nanoseconds() / int64(iterations),
+		AllocsPerOp: (after.Mallocs - before.Mallocs) / n,
+		BytesPerOp:  (after.TotalAlloc - before.TotalAlloc) / n,
+	}, nil
+}
+
+// newProvider returns an in-memory EnvelopeKeyProvider with a random key
+func newProvider() (packer.EnvelopeKeyProvider, error) {
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+
+	var provider packer.EnvelopeKeyProvider
+	finder := func(id packer.EnvelopeKeyID) (packer.EnvelopeKeyProvider, error) {
+		if id != provider.ID() {
+			return nil, errors.New("unknown provider id")
+		}
+		return provider, nil
+	}
+
+	provider, err := packer.NewEnvelopeKeyProvider(&packer.EnvelopeKeyProviderInfo{ID: "packerbench", Key: key}, finder)
+	return provider, err
+}
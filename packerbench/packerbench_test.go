@@ -0,0 +1,72 @@
+package packerbench
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/gford1000-go/packer"
+)
+
+func TestShapeItem(t *testing.T) {
+
+	shape := Shape{Attributes: 12, ValueSize: 64, Types: []ValueType{String, Bytes, Int64, Float64, Time, Strings}, Seed: 7}
+
+	a, err := shape.Item()
+	if err != nil {
+		t.Fatalf("Unexpected error generating item: %v", err)
+	}
+	if len(a.Attributes) != 12 {
+		t.Fatalf("Expected 12 attributes, got %d", len(a.Attributes))
+	}
+	if s, ok := a.Attributes["attr000000"].(string); !ok || len(s) != 64 {
+		t.Fatalf("Unexpected string value: %v", a.Attributes["attr000000"])
+	}
+	if ss, ok := a.Attributes["attr000005"].([]string); !ok || len(ss) != 4 {
+		t.Fatalf("Unexpected strings value: %v", a.Attributes["attr000005"])
+	}
+
+	b, _ := shape.Item()
+	if !reflect.DeepEqual(a, b) {
+		t.Fatal("Expected the same seed to generate the same item")
+	}
+
+	if _, err := (Shape{}).Item(); !errors.Is(err, ErrInvalidShape) {
+		t.Fatalf("Expected ErrInvalidShape, got: %v", err)
+	}
+	if _, err := (Shape{Attributes: 1, Types: []ValueType{"unknown"}}).Item(); !errors.Is(err, ErrUnknownValueType) {
+		t.Fatalf("Expected ErrUnknownValueType, got: %v", err)
+	}
+}
+
+func TestRun(t *testing.T) {
+
+	var profile bytes.Buffer
+
+	results, err := Run(context.TODO(), Shape{Attributes: 20, ValueSize: 128, Types: []ValueType{String, Int64}},
+		WithIterations(3),
+		WithPackOptions(packer.WithCompression(packer.FlateCompression)),
+		WithGetValuesAttributes(5),
+		WithCPUProfile(&profile))
+	if err != nil {
+		t.Fatalf("Unexpected error running: %v", err)
+	}
+
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 results, got %d", len(results))
+	}
+	for i, op := range []Operation{OperationPack, OperationUnpack, OperationGetValues} {
+		r := results[i]
+		if r.Operation != op || r.Iterations != 3 || r.NsPerOp <= 0 {
+			t.Fatalf("Unexpected result: %v", r)
+		}
+	}
+	if results[0].Elements == 0 || results[0].StoredBytes == 0 {
+		t.Fatalf("Expected pack result to record stored data: %v", results[0])
+	}
+	if profile.Len() == 0 {
+		t.Fatal("Expected a CPU profile to be written")
+	}
+}
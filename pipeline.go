@@ -0,0 +1,166 @@
+package packer
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// PackSaver persists the packed data of an item, as returned by Pack
+type PackSaver[T comparable] func(ctx context.Context, key T, info []byte, data map[T]map[string][]byte) error
+
+// PipelineResult is the outcome of packing and saving a single item with PackPipeline
+type PipelineResult[T comparable] struct {
+	// Key of the item
+	Key T
+	// Info is the packed data of the item, if Err is nil
+	Info []byte
+	// Err is the error encountered packing or saving the item, if any
+	Err error
+}
+
+// ErrPackSaverIsNil raised if no PackSaver is provided to PackPipeline
+var ErrPackSaverIsNil = errors.New("saver must not be nil, to allow packed items to be persisted")
+
+const (
+	defaultPipelinePackers uint16 = 1
+	defaultPipelineSavers  uint16 = 1
+	defaultPipelineBuffer  uint16 = 16
+)
+
+// WithPipeline sets the number of items packed concurrently, the number of packed items saved
+// concurrently, and the number of items buffered between stages, by PackPipeline.  If not set,
+// each stage processes one item at a time, with a buffer of 16 items.  Pack ignores this option.
+func WithPipeline(packers, savers, buffer uint16) func(o *Options) {
+	return func(o *Options) {
+		o.pipelinePackers = packers
+		o.pipelineSavers = savers
+		o.pipelineBuffer = buffer
+	}
+}
+
+// PackPipeline packs each item received from the channel, and persists it using the saver, with packing
+// (serialisation and encryption) and persistence running as overlapping stages connected by bounded buffers,
+// so that slow storage limits the number of items held in memory.  The options are applied to every item.
+// A result is emitted for each item on the returned channel, which is closed once the items channel is closed
+// and all items have been processed, or the context is cancelled.  Results are not necessarily in the order
+// that items are received, and items in progress when the context is cancelled are abandoned.
+func PackPipeline[T comparable](ctx context.Context, items <-chan *Item[T], params *PackParams[T], saver PackSaver[T], opts ...func(*Options)) (<-chan PipelineResult[T], error) {
+
+	if params == nil {
+		return nil, ErrPackNoParams
+	}
+	if err := params.validate(); err != nil {
+		return nil, err
+	}
+	if saver == nil {
+		return nil, ErrPackSaverIsNil
+	}
+
+	o := &Options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.pipelinePackers == 0 {
+		o.pipelinePackers = defaultPipelinePackers
+	}
+	if o.pipelineSavers == 0 {
+		o.pipelineSavers = defaultPipelineSavers
+	}
+	if o.pipelineBuffer == 0 {
+		o.pipelineBuffer = defaultPipelineBuffer
+	}
+
+	type packed struct {
+		key  T
+		info []byte
+		data map[T]map[string][]byte
+	}
+
+	packedItems := make(chan packed, o.pipelineBuffer)
+	results := make(chan PipelineResult[T], o.pipelineBuffer)
+
+	emit := func(r PipelineResult[T]) bool {
+		select {
+		case results <- r:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	var packers sync.WaitGroup
+	for range o.pipelinePackers {
+		packers.Add(1)
+
+		go func() {
+			defer packers.Done()
+
+			for {
+				var item *Item[T]
+				select {
+				case <-ctx.Done():
+					return
+				case i, ok := <-items:
+					if !ok {
+						return
+					}
+					item = i
+				}
+
+				if item == nil {
+					if !emit(PipelineResult[T]{Err: ErrPackNoAttributes}) {
+						return
+					}
+					continue
+				}
+
+				info, data, err := PackWithContext(ctx, item, params, opts...)
+				if err != nil {
+					if !emit(PipelineResult[T]{Key: item.Key, Err: err}) {
+						return
+					}
+					continue
+				}
+
+				select {
+				case packedItems <- packed{key: item.Key, info: info, data: data}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+		packers.Wait()
+		close(packedItems)
+	}()
+
+	var savers sync.WaitGroup
+	for range o.pipelineSavers {
+		savers.Add(1)
+
+		go func() {
+			defer savers.Done()
+
+			for p := range packedItems {
+				if ctx.Err() != nil {
+					continue
+				}
+				r := PipelineResult[T]{Key: p.key, Info: p.info}
+				if r.Err = saver(ctx, p.key, p.info, p.data); r.Err != nil {
+					r.Info = nil
+				}
+				emit(r)
+			}
+		}()
+	}
+
+	go func() {
+		savers.Wait()
+		close(results)
+	}()
+
+	return results, nil
+}
@@ -0,0 +1,140 @@
+package packer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/gford1000-go/serialise"
+)
+
+func TestPackPipeline(t *testing.T) {
+
+	_, unpacker, provider := testCreateEnv(t)
+
+	serialiser, err := NewKeySerialiser()
+	if err != nil {
+		t.Fatalf("Unexpected error preparing Key serialiser: %v", err)
+	}
+
+	params := &PackParams[Key]{
+		Provider: provider,
+		Creator:  NewKeyCreator(defaultLen),
+		Packer:   serialiser,
+		Approach: serialise.NewMinDataApproachWithVersion(serialise.V1),
+	}
+
+	var mu sync.Mutex
+	infos := map[Key][]byte{}
+	stored := map[Key]map[string][]byte{}
+	errSave := errors.New("save failed")
+
+	saver := func(ctx context.Context, key Key, info []byte, data map[Key]map[string][]byte) error {
+		if key.X == "bad" {
+			return errSave
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		infos[key] = info
+		for k, v := range data {
+			stored[k] = v
+		}
+		return nil
+	}
+
+	if _, err := PackPipeline(context.TODO(), nil, params, nil); !errors.Is(err, ErrPackSaverIsNil) {
+		t.Fatalf("Unexpected error: expected: %v, got: %v", ErrPackSaverIsNil, err)
+	}
+
+	items := make(chan *Item[Key])
+
+	results, err := PackPipeline(context.TODO(), items, params, saver, WithPipeline(4, 2, 3))
+	if err != nil {
+		t.Fatalf("Unexpected error creating pipeline: %v", err)
+	}
+
+	go func() {
+		defer close(items)
+		for i := range 50 {
+			items <- &Item[Key]{
+				Key:        Key{X: fmt.Sprintf("%d", i), Y: "B"},
+				Attributes: map[string]any{"aaa": fmt.Sprintf("Value %d", i)},
+			}
+		}
+		items <- &Item[Key]{Key: Key{X: "bad", Y: "B"}, Attributes: map[string]any{"aaa": "x"}}
+		items <- &Item[Key]{Key: Key{X: "empty", Y: "B"}}
+	}()
+
+	n, failed := 0, map[string]error{}
+	for r := range results {
+		n++
+		if r.Err != nil {
+			failed[r.Key.X] = r.Err
+		}
+	}
+
+	if n != 52 {
+		t.Fatalf("Unexpected number of results: expected: 52, got: %d", n)
+	}
+	if len(failed) != 2 || !errors.Is(failed["bad"], errSave) || !errors.Is(failed["empty"], ErrPackNoAttributes) {
+		t.Fatalf("Unexpected failures: %v", failed)
+	}
+
+	loader := func(ctx context.Context, keys []Key) (map[string][]byte, error) {
+		m := map[string][]byte{}
+		for _, k := range keys {
+			for n, v := range stored[k] {
+				m[n] = v
+			}
+		}
+		return m, nil
+	}
+
+	for key, info := range infos {
+		e, err := unpacker(info, loader)
+		if err != nil {
+			t.Fatalf("Unexpected error unpacking %v: %v", key, err)
+		}
+		m, err := e.GetValues(context.TODO(), []string{"aaa"}, provider)
+		if err != nil {
+			t.Fatalf("Unexpected error getting values: %v", err)
+		}
+		if m["aaa"] != "Value "+key.X {
+			t.Fatalf("Unexpected value for %v: %v", key, m["aaa"])
+		}
+	}
+}
+
+func TestPackPipeline_Cancel(t *testing.T) {
+
+	_, _, provider := testCreateEnv(t)
+
+	serialiser, err := NewKeySerialiser()
+	if err != nil {
+		t.Fatalf("Unexpected error preparing Key serialiser: %v", err)
+	}
+
+	params := &PackParams[Key]{
+		Provider: provider,
+		Creator:  NewKeyCreator(defaultLen),
+		Packer:   serialiser,
+		Approach: serialise.NewMinDataApproachWithVersion(serialise.V1),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	items := make(chan *Item[Key])
+	results, err := PackPipeline(ctx, items, params, func(context.Context, Key, []byte, map[Key]map[string][]byte) error { return nil })
+	if err != nil {
+		t.Fatalf("Unexpected error creating pipeline: %v", err)
+	}
+
+	items <- &Item[Key]{Key: Key{X: "A", Y: "B"}, Attributes: map[string]any{"aaa": "x"}}
+	cancel()
+
+	// The results channel must be closed once cancelled, even though items remains open
+	for range results {
+	}
+}
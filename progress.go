@@ -0,0 +1,81 @@
+package packer
+
+import "sync"
+
+// Operation identifies the operation reporting Progress
+type Operation string
+
+const (
+	// OperationPack is reported during Pack
+	OperationPack Operation = "pack"
+	// OperationUnpack is reported during Unpack
+	OperationUnpack Operation = "unpack"
+)
+
+// Progress describes how far a Pack or Unpack has advanced
+type Progress struct {
+	// Operation reporting the progress
+	Operation Operation
+	// AttributesProcessed is the number of attributes serialised (Pack) or reassembled (Unpack)
+	AttributesProcessed int
+	// AttributesTotal is the number of attributes to be processed
+	AttributesTotal int
+	// BytesEncrypted is the size of the encrypted attribute data processed so far
+	BytesEncrypted uint64
+	// ElementsFlushed is the number of elements created (Pack) or loaded (Unpack)
+	ElementsFlushed int
+	// ElementsTotal is the number of elements, once known
+	ElementsTotal int
+}
+
+// ProgressFunc receives Progress updates.  Calls are never concurrent, but must return promptly
+// as they are made synchronously during processing.
+type ProgressFunc func(Progress)
+
+// WithProgress reports the progress of Pack to the callback, so that long-running
+// operations on large items can drive progress bars and watchdogs
+func WithProgress(f ProgressFunc) func(o *Options) {
+	return func(o *Options) {
+		o.progress = f
+	}
+}
+
+// progressTracker accumulates Progress, serialising calls to the callback
+type progressTracker struct {
+	mu sync.Mutex
+	f  ProgressFunc
+	p  Progress
+}
+
+func newProgressTracker(op Operation, f ProgressFunc) *progressTracker {
+	return &progressTracker{f: f, p: Progress{Operation: op}}
+}
+
+func (p *progressTracker) update(change func(*Progress)) {
+	if p == nil || p.f == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	change(&p.p)
+	p.f(p.p)
+}
+
+func (p *progressTracker) attributes(total int) {
+	p.update(func(pr *Progress) { pr.AttributesTotal = total })
+}
+
+func (p *progressTracker) attributeProcessed(size int) {
+	p.update(func(pr *Progress) {
+		pr.AttributesProcessed++
+		pr.BytesEncrypted += uint64(size)
+	})
+}
+
+func (p *progressTracker) elements(total int) {
+	p.update(func(pr *Progress) { pr.ElementsTotal = total })
+}
+
+func (p *progressTracker) elementsFlushed(n int) {
+	p.update(func(pr *Progress) { pr.ElementsFlushed += n })
+}
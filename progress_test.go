@@ -0,0 +1,51 @@
+package packer
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestWithProgress(t *testing.T) {
+
+	testPack, _, provider := testCreateEnv(t)
+
+	item := &Item[Key]{
+		Key:        Key{X: "A", Y: "B"},
+		Attributes: map[string]any{},
+	}
+	for i := range 20 {
+		item.Attributes[fmt.Sprintf("attr%d", i)] = int64(i)
+	}
+
+	var packUpdates []Progress
+	b, l, err := testPack(item, WithProgress(func(p Progress) { packUpdates = append(packUpdates, p) }), WithConcurrency(4))
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+
+	last := packUpdates[len(packUpdates)-1]
+	if last.Operation != OperationPack || last.AttributesProcessed != 20 || last.AttributesTotal != 20 ||
+		last.BytesEncrypted == 0 || last.ElementsFlushed != 1 || last.ElementsTotal != 1 {
+		t.Fatalf("Unexpected final pack progress: %+v", last)
+	}
+
+	serialiser, _ := NewKeySerialiser()
+
+	var unpackUpdates []Progress
+	params := &UnpackParams[Key]{
+		DataLoader:  l,
+		IDRetriever: func(string) (IDSerialiser[Key], error) { return serialiser, nil },
+		Provider:    provider,
+		Progress:    func(p Progress) { unpackUpdates = append(unpackUpdates, p) },
+	}
+	if _, err := Unpack(context.TODO(), b, params); err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+
+	last = unpackUpdates[len(unpackUpdates)-1]
+	if last.Operation != OperationUnpack || last.AttributesProcessed != 20 || last.AttributesTotal != 20 ||
+		last.ElementsFlushed != 1 || last.ElementsTotal != 1 {
+		t.Fatalf("Unexpected final unpack progress: %+v", last)
+	}
+}
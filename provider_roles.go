@@ -0,0 +1,75 @@
+package packer
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrProviderIsDecryptOnly raised if a provider created by NewDecryptOnly is asked to create a key
+var ErrProviderIsDecryptOnly = errors.New("provider is decrypt-only and cannot create new keys")
+
+// ErrProviderIsEncryptOnly raised if a provider created by NewEncryptOnly is asked to decrypt a key
+var ErrProviderIsEncryptOnly = errors.New("provider is encrypt-only and cannot decrypt keys")
+
+// NewDecryptOnly restricts the provider to decrypting existing keys, so that a consumer service
+// holding it can read packed data but cannot create new packs
+func NewDecryptOnly(provider EnvelopeKeyProvider) (EnvelopeKeyProvider, error) {
+	if provider == nil {
+		return nil, ErrProviderIsNil
+	}
+	return &decryptOnlyProvider{provider: provider}, nil
+}
+
+type decryptOnlyProvider struct {
+	provider EnvelopeKeyProvider
+}
+
+func (d *decryptOnlyProvider) ID() EnvelopeKeyID {
+	return d.provider.ID()
+}
+
+func (d *decryptOnlyProvider) New() ([]byte, []byte, error) {
+	return nil, nil, ErrProviderIsDecryptOnly
+}
+
+func (d *decryptOnlyProvider) Decrypt(ctx context.Context, encryptedKey []byte) ([]byte, error) {
+	return d.provider.Decrypt(ctx, encryptedKey)
+}
+
+// NewEncryptOnly restricts the provider to creating new keys, so that a producer service
+// holding it can create packs but cannot read packed data
+func NewEncryptOnly(provider EnvelopeKeyProvider) (EnvelopeKeyProvider, error) {
+	if provider == nil {
+		return nil, ErrProviderIsNil
+	}
+	return &encryptOnlyProvider{provider: provider}, nil
+}
+
+type encryptOnlyProvider struct {
+	provider EnvelopeKeyProvider
+}
+
+func (e *encryptOnlyProvider) ID() EnvelopeKeyID {
+	return e.provider.ID()
+}
+
+func (e *encryptOnlyProvider) New() ([]byte, []byte, error) {
+	return e.provider.New()
+}
+
+func (e *encryptOnlyProvider) NewWithContext(ctx context.Context) ([]byte, []byte, error) {
+	return newKey(ctx, e.provider)
+}
+
+// NewWithEncryptionContext is available if the underlying provider implements EncryptionContextProvider
+func (e *encryptOnlyProvider) NewWithEncryptionContext(ec map[string]string) ([]byte, []byte, error) {
+	p, ok := e.provider.(EncryptionContextProvider)
+	if !ok {
+		return nil, nil, ErrProviderDoesNotSupportEncryptionContext
+	}
+	return p.NewWithEncryptionContext(ec)
+}
+
+func (e *encryptOnlyProvider) Decrypt(ctx context.Context, encryptedKey []byte) ([]byte, error) {
+	return nil, ErrProviderIsEncryptOnly
+}
@@ -0,0 +1,84 @@
+package packer
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestProviderRoles(t *testing.T) {
+
+	packer, _, provider := testCreateEnv(t)
+
+	if _, err := NewDecryptOnly(nil); !errors.Is(err, ErrProviderIsNil) {
+		t.Fatalf("Unexpected error: expected: %v, got: %v", ErrProviderIsNil, err)
+	}
+	if _, err := NewEncryptOnly(nil); !errors.Is(err, ErrProviderIsNil) {
+		t.Fatalf("Unexpected error: expected: %v, got: %v", ErrProviderIsNil, err)
+	}
+
+	decryptOnly, err := NewDecryptOnly(provider)
+	if err != nil {
+		t.Fatalf("Unexpected error creating provider: %v", err)
+	}
+	encryptOnly, err := NewEncryptOnly(provider)
+	if err != nil {
+		t.Fatalf("Unexpected error creating provider: %v", err)
+	}
+
+	if _, _, err := decryptOnly.New(); !errors.Is(err, ErrProviderIsDecryptOnly) {
+		t.Fatalf("Unexpected error: expected: %v, got: %v", ErrProviderIsDecryptOnly, err)
+	}
+
+	encryptedKey, key, err := encryptOnly.New()
+	if err != nil {
+		t.Fatalf("Unexpected error creating key: %v", err)
+	}
+	if _, err := encryptOnly.Decrypt(context.TODO(), encryptedKey); !errors.Is(err, ErrProviderIsEncryptOnly) {
+		t.Fatalf("Unexpected error: expected: %v, got: %v", ErrProviderIsEncryptOnly, err)
+	}
+	k, err := decryptOnly.Decrypt(context.TODO(), encryptedKey)
+	if err != nil {
+		t.Fatalf("Unexpected error decrypting key: %v", err)
+	}
+	if string(k) != string(key) {
+		t.Fatal("Mismatch in decrypted key")
+	}
+
+	if _, _, err := encryptOnly.(EncryptionContextProvider).NewWithEncryptionContext(map[string]string{"a": "b"}); err != nil {
+		t.Fatalf("Unexpected error creating key: %v", err)
+	}
+
+	// Packed data can be read with a decrypt-only provider, but not an encrypt-only provider
+	info, loader, err := packer(&Item[Key]{Key: Key{X: "A", Y: "B"}, Attributes: map[string]any{"aaa": "Hello World"}})
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+
+	serialiser, err := NewKeySerialiser()
+	if err != nil {
+		t.Fatalf("Unexpected error preparing Key serialiser: %v", err)
+	}
+	unpack := func(p EnvelopeKeyProvider) (*EncryptedItem[Key], error) {
+		return Unpack(context.TODO(), info, &UnpackParams[Key]{
+			IDRetriever: func(string) (IDSerialiser[Key], error) { return serialiser, nil },
+			Provider:    p,
+			DataLoader:  loader,
+		})
+	}
+
+	if _, err := unpack(encryptOnly); !errors.Is(err, ErrProviderIsEncryptOnly) {
+		t.Fatalf("Unexpected error: expected: %v, got: %v", ErrProviderIsEncryptOnly, err)
+	}
+	e, err := unpack(decryptOnly)
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+	m, err := e.GetValues(context.TODO(), []string{"aaa"}, decryptOnly)
+	if err != nil {
+		t.Fatalf("Unexpected error getting values: %v", err)
+	}
+	if m["aaa"] != "Hello World" {
+		t.Fatalf("Unexpected value: %v", m["aaa"])
+	}
+}
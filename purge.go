@@ -0,0 +1,150 @@
+package packer
+
+import (
+	"context"
+	"maps"
+)
+
+// GetElementKeys returns the keys of all the elements holding the data of the packed item, including any
+// parity, replica and continuation elements, without loading any attribute values.  This allows storage
+// to be purged when an item is deleted.  Only the Provider and IDRetriever of the params are required;
+// the DataLoader is only used, and is then required, if the envelope was split across continuation elements.
+func GetElementKeys[T comparable](ctx context.Context, data []byte, params *UnpackParams[T]) ([]T, error) {
+	plan, err := PlanDeletion(ctx, data, params)
+	if err != nil {
+		return nil, err
+	}
+	return plan.ElementKeys(), nil
+}
+
+// ElementRole identifies why an element of a packed item is stored
+type ElementRole string
+
+const (
+	// ElementRoleData elements hold the chunks of attribute values
+	ElementRoleData ElementRole = "data"
+	// ElementRoleParity elements hold erasure coding parity (see WithErasureCoding)
+	ElementRoleParity ElementRole = "parity"
+	// ElementRoleReplica elements are copies of data or parity elements (see WithReplication)
+	ElementRoleReplica ElementRole = "replica"
+	// ElementRoleContinuation elements hold an envelope that exceeded the maximum size
+	ElementRoleContinuation ElementRole = "continuation"
+)
+
+// DeletionPlan describes the storage to be removed to delete a packed item
+type DeletionPlan[T comparable] struct {
+	// Key of the item, under which the packed data is typically stored
+	Key T
+	// Elements holds the keys of the elements to be deleted, by role
+	Elements map[ElementRole][]T
+	// Escrow is the policy of the escrowed copy of the data encryption key, if the item was packed using
+	// NewEscrowProvider.  The escrowed key is held within the packed data, so is removed with it.
+	Escrow *EscrowPolicy
+}
+
+// ElementKeys returns the keys of all elements in the plan, in the order data, parity, replica and continuation
+func (p *DeletionPlan[T]) ElementKeys() []T {
+	return flattenElements(p.Elements)
+}
+
+// flattenElements returns the keys of the elements, in the order data, parity, replica and continuation
+func flattenElements[T comparable](elements map[ElementRole][]T) []T {
+	var keys []T
+	for _, role := range []ElementRole{ElementRoleData, ElementRoleParity, ElementRoleReplica, ElementRoleContinuation} {
+		keys = append(keys, elements[role]...)
+	}
+	return keys
+}
+
+// PlanDeletion returns the DeletionPlan of the packed item, describing all of its stored elements by role,
+// without loading any attribute values, so that jobs deleting items need not determine their storage.
+// The packed data itself should be deleted once the elements have been, so that the plan can be recreated
+// if deletion is interrupted.  Only the Provider and IDRetriever of the params are required; the DataLoader
+// is only used, and is then required, if the envelope was split across continuation elements.
+func PlanDeletion[T comparable](ctx context.Context, data []byte, params *UnpackParams[T]) (*DeletionPlan[T], error) {
+
+	if len(data) == 0 {
+		return nil, ErrUnpackNoData
+	}
+	if params == nil {
+		return nil, ErrUnpackNoParams
+	}
+	if params.IDRetriever == nil {
+		return nil, ErrIDRetrieverIsNil
+	}
+	if params.Provider == nil {
+		return nil, ErrProviderIsNil
+	}
+
+	v, err := continuationDescriptor(data)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &DeletionPlan[T]{Elements: map[ElementRole][]T{}}
+
+	if v != nil {
+		if plan.Elements[ElementRoleContinuation], _, err = continuationElements(v, params.IDRetriever); err != nil {
+			return nil, err
+		}
+		if data, err = JoinEnvelope(ctx, data, params.DataLoader, params.IDRetriever); err != nil {
+			return nil, err
+		}
+	}
+
+	env, err := openEnvelope(params.withEncryptionContext(ctx), data, params.Provider, params.IDRetriever)
+	if err != nil {
+		return nil, err
+	}
+	plan.Key = env.key
+
+	elements, err := env.elementsByRole()
+	if err != nil {
+		return nil, err
+	}
+	maps.Copy(plan.Elements, elements)
+
+	if _, _, policy, _, err := unpackEscrowedKey(env.encryptedKey); err == nil {
+		plan.Escrow = &policy
+	}
+
+	return plan, nil
+}
+
+// elementsByRole returns the keys of the elements holding the data of the envelope by role, other than
+// any continuation elements holding the envelope itself
+func (env *envelopeV1[T]) elementsByRole() (map[ElementRole][]T, error) {
+
+	elements := map[ElementRole][]T{}
+
+	// Nothing is stored outside the packed data of an item packed inline
+	if env.isInline() {
+		return elements, nil
+	}
+
+	// Parity elements follow the data elements
+	layout, err := env.ext.erasure(env.approach)
+	if err != nil {
+		return nil, err
+	}
+	dataElements := len(env.elements)
+	if layout != nil {
+		dataElements = len(layout.data)
+	}
+	elements[ElementRoleData] = env.elements[:dataElements]
+	if dataElements < len(env.elements) {
+		elements[ElementRoleParity] = env.elements[dataElements:]
+	}
+
+	if b, ok := env.ext[extReplicas]; ok {
+		replicas, err := unpackReplicas(b, env.packer, env.approach)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range replicas {
+			elements[ElementRoleReplica] = append(elements[ElementRoleReplica], r.keys...)
+		}
+	}
+
+	return elements, nil
+}
@@ -0,0 +1,155 @@
+package packer
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gford1000-go/serialise"
+)
+
+func testPurgeParams(t *testing.T) (*PackParams[Key], *UnpackParams[Key]) {
+	_, _, provider := testCreateEnv(t)
+	serialiser, _ := NewKeySerialiser()
+
+	return &PackParams[Key]{
+		Provider: provider,
+		Creator:  NewKeyCreator(defaultLen),
+		Packer:   serialiser,
+		Approach: serialise.NewMinDataApproachWithVersion(serialise.V1),
+	}, &UnpackParams[Key]{
+		IDRetriever: func(string) (IDSerialiser[Key], error) { return serialiser, nil },
+		Provider:    provider,
+	}
+}
+
+func TestGetElementKeys(t *testing.T) {
+
+	pParams, uParams := testPurgeParams(t)
+
+	item := &Item[Key]{
+		Key:        Key{X: "A", Y: "B"},
+		Attributes: map[string]any{},
+	}
+	for i := range 600 {
+		b := make([]byte, 20)
+		if _, err := rand.Read(b); err != nil {
+			t.Fatalf("Unexpected error creating name: %v", err)
+		}
+		item.Attributes[hex.EncodeToString(b)] = int64(i)
+	}
+
+	tests := []struct {
+		name string
+		opts []func(*Options)
+	}{
+		{name: "plain"},
+		{name: "erasure and replicas", opts: []func(*Options){WithErasureCoding(2), WithReplication(3)}},
+		{name: "continuation", opts: []func(*Options){WithMaximumKBSize(10)}},
+	}
+
+	for _, test := range tests {
+		info, data, err := Pack(item, pParams, test.opts...)
+		if err != nil {
+			t.Fatalf("(%s) Unexpected error packing: %v", test.name, err)
+		}
+
+		uParams.DataLoader = func(ctx context.Context, keys []Key) (map[string][]byte, error) {
+			m := map[string][]byte{}
+			for _, k := range keys {
+				maps.Copy(m, data[k])
+			}
+			return m, nil
+		}
+
+		keys, err := GetElementKeys(context.TODO(), info, uParams)
+		if err != nil {
+			t.Fatalf("(%s) Unexpected error getting element keys: %v", test.name, err)
+		}
+
+		expected := slices.SortedFunc(maps.Keys(data), compareKeys)
+		if !slices.Equal(slices.SortedFunc(slices.Values(keys), compareKeys), expected) {
+			t.Fatalf("(%s) Expected %d element keys, got %d", test.name, len(expected), len(keys))
+		}
+	}
+
+	// The loader is only required for continued envelopes
+	uParams.DataLoader = nil
+	info, _, err := Pack(item, pParams)
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+	if _, err := GetElementKeys(context.TODO(), info, uParams); err != nil {
+		t.Fatalf("Unexpected error without a loader: %v", err)
+	}
+	info, _, err = Pack(item, pParams, WithMaximumKBSize(10))
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+	if _, err := GetElementKeys(context.TODO(), info, uParams); !errors.Is(err, ErrDataLoaderIsNil) {
+		t.Fatalf("Expected ErrDataLoaderIsNil, got: %v", err)
+	}
+}
+
+func compareKeys(a, b Key) int {
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+func TestPlanDeletion(t *testing.T) {
+
+	pParams, uParams := testPurgeParams(t)
+
+	escrowKey := make([]byte, 32)
+	if _, err := rand.Read(escrowKey); err != nil {
+		t.Fatalf("Unexpected error creating key: %v", err)
+	}
+	escrow, err := NewEnvelopeKeyProvider(&EnvelopeKeyProviderInfo{ID: "escrow", Key: escrowKey},
+		func(EnvelopeKeyID) (EnvelopeKeyProvider, error) { return nil, errors.New("unknown provider id") })
+	if err != nil {
+		t.Fatalf("Unexpected error creating provider: %v", err)
+	}
+	policy := EscrowPolicy{Name: "legal hold", Approvals: 2}
+
+	pParams.Provider, err = NewEscrowProvider(uParams.Provider, escrow, policy, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error creating escrow provider: %v", err)
+	}
+	uParams.Provider = pParams.Provider
+
+	item := &Item[Key]{
+		Key:        Key{X: "A", Y: "B"},
+		Attributes: map[string]any{"a": int64(1), "b": "two"},
+	}
+
+	info, data, err := Pack(item, pParams, WithErasureCoding(2), WithReplication(2))
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+
+	plan, err := PlanDeletion(context.TODO(), info, uParams)
+	if err != nil {
+		t.Fatalf("Unexpected error planning deletion: %v", err)
+	}
+
+	if plan.Key != item.Key {
+		t.Fatalf("Unexpected item key: %v", plan.Key)
+	}
+	if n := len(plan.Elements[ElementRoleParity]); n != 2 {
+		t.Fatalf("Expected 2 parity elements, got %d", n)
+	}
+	if n, m := len(plan.Elements[ElementRoleReplica]), len(plan.Elements[ElementRoleData])+len(plan.Elements[ElementRoleParity]); n != m {
+		t.Fatalf("Expected a replica of each of the %d elements, got %d", m, n)
+	}
+	if len(plan.ElementKeys()) != len(data) {
+		t.Fatalf("Expected %d element keys, got %d", len(data), len(plan.ElementKeys()))
+	}
+	if plan.Escrow == nil || plan.Escrow.Name != policy.Name {
+		t.Fatalf("Expected the escrow policy to be reported, got: %v", plan.Escrow)
+	}
+}
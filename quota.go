@@ -0,0 +1,156 @@
+package packer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Quota limits the throughput of operations and bytes encrypted or decrypted for each tenant,
+// so that a single tenant cannot starve shared encryption capacity.
+// Implementations must be safe for concurrent use.
+type Quota interface {
+	// Acquire requests capacity for the specified number of operations and bytes on behalf
+	// of the tenant, blocking until it is available or returning an error if it is refused
+	Acquire(ctx context.Context, tenant string, ops uint64, bytes uint64) error
+}
+
+type tenantContextKey struct{}
+
+// ContextWithTenant returns a context identifying the tenant on whose behalf GetValues is called
+func ContextWithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenant)
+}
+
+// TenantFromContext returns the tenant added with ContextWithTenant, or an empty string if none
+func TenantFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	tenant, _ := ctx.Value(tenantContextKey{}).(string)
+	return tenant
+}
+
+// WithQuota consults the Quota during Pack on behalf of the tenant, acquiring one operation
+// for the Pack and then the bytes of each encrypted attribute as it is produced
+func WithQuota(quota Quota, tenant string) func(o *Options) {
+	return func(o *Options) {
+		o.quota = quota
+		o.tenant = tenant
+	}
+}
+
+// ErrQuotaExceeded raised if capacity cannot be granted within the maximum wait of a Quota
+var ErrQuotaExceeded = errors.New("quota exceeded - capacity not available within the maximum wait")
+
+// TokenBucketLimits describe the rates and bursts permitted to each tenant.
+// A zero rate leaves that dimension unlimited.
+type TokenBucketLimits struct {
+	// OperationsPerSecond is the sustained rate of operations
+	OperationsPerSecond float64
+	// OperationBurst is the maximum number of operations that may accumulate
+	OperationBurst float64
+	// BytesPerSecond is the sustained rate of bytes
+	BytesPerSecond float64
+	// ByteBurst is the maximum number of bytes that may accumulate
+	ByteBurst float64
+	// MaxWait is the longest Acquire will block before returning ErrQuotaExceeded; zero waits indefinitely
+	MaxWait time.Duration
+}
+
+// NewTokenBucketQuota returns a Quota that applies the same token bucket limits to each tenant independently
+func NewTokenBucketQuota(limits TokenBucketLimits) Quota {
+	return &tokenBucketQuota{
+		limits:  limits,
+		tenants: map[string]*tenantBuckets{},
+		now:     time.Now,
+	}
+}
+
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+}
+
+// take removes n tokens, returning how long the caller must wait for the bucket to recover
+func (b *tokenBucket) take(n float64) time.Duration {
+	if b.rate <= 0 || n == 0 {
+		return 0
+	}
+	b.tokens -= n
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+func (b *tokenBucket) refill(elapsed time.Duration) {
+	if b.rate <= 0 {
+		return
+	}
+	b.tokens += elapsed.Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+}
+
+type tenantBuckets struct {
+	last  time.Time
+	ops   tokenBucket
+	bytes tokenBucket
+}
+
+type tokenBucketQuota struct {
+	mu      sync.Mutex
+	limits  TokenBucketLimits
+	tenants map[string]*tenantBuckets
+	now     func() time.Time
+}
+
+func (q *tokenBucketQuota) Acquire(ctx context.Context, tenant string, ops uint64, bytes uint64) error {
+
+	q.mu.Lock()
+
+	now := q.now()
+	t, ok := q.tenants[tenant]
+	if !ok {
+		t = &tenantBuckets{
+			last:  now,
+			ops:   tokenBucket{rate: q.limits.OperationsPerSecond, burst: q.limits.OperationBurst, tokens: q.limits.OperationBurst},
+			bytes: tokenBucket{rate: q.limits.BytesPerSecond, burst: q.limits.ByteBurst, tokens: q.limits.ByteBurst},
+		}
+		q.tenants[tenant] = t
+	}
+
+	t.ops.refill(now.Sub(t.last))
+	t.bytes.refill(now.Sub(t.last))
+	t.last = now
+
+	wait := max(t.ops.take(float64(ops)), t.bytes.take(float64(bytes)))
+
+	if q.limits.MaxWait > 0 && wait > q.limits.MaxWait {
+		// Refuse without consuming capacity
+		t.ops.tokens += float64(ops)
+		t.bytes.tokens += float64(bytes)
+		q.mu.Unlock()
+		return ErrQuotaExceeded
+	}
+
+	q.mu.Unlock()
+
+	if wait == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,112 @@
+package packer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNewTokenBucketQuota(t *testing.T) {
+
+	q := NewTokenBucketQuota(TokenBucketLimits{
+		OperationsPerSecond: 1,
+		OperationBurst:      2,
+		BytesPerSecond:      100,
+		ByteBurst:           100,
+		MaxWait:             time.Millisecond,
+	})
+
+	ctx := context.TODO()
+
+	for i := range 2 {
+		if err := q.Acquire(ctx, "A", 1, 10); err != nil {
+			t.Fatalf("(%d) Unexpected error acquiring within burst: %v", i, err)
+		}
+	}
+	if err := q.Acquire(ctx, "A", 1, 0); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("Unexpected error: expected: %v, got: %v", ErrQuotaExceeded, err)
+	}
+	if err := q.Acquire(ctx, "A", 0, 200); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("Unexpected error: expected: %v, got: %v", ErrQuotaExceeded, err)
+	}
+
+	// Other tenants are unaffected
+	if err := q.Acquire(ctx, "B", 1, 50); err != nil {
+		t.Fatalf("Unexpected error for other tenant: %v", err)
+	}
+}
+
+func TestNewTokenBucketQuota_1(t *testing.T) {
+
+	q := NewTokenBucketQuota(TokenBucketLimits{
+		OperationsPerSecond: 1,
+		OperationBurst:      1,
+	}).(*tokenBucketQuota)
+
+	now := time.Now()
+	q.now = func() time.Time { return now }
+
+	ctx := context.TODO()
+	if err := q.Acquire(ctx, "A", 1, 1000000); err != nil {
+		t.Fatalf("Unexpected error acquiring: %v", err)
+	}
+
+	// Capacity recovers as time passes
+	now = now.Add(time.Second)
+	if err := q.Acquire(ctx, "A", 1, 0); err != nil {
+		t.Fatalf("Unexpected error after refill: %v", err)
+	}
+
+	// Waiting is abandoned if the context is cancelled
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := q.Acquire(cctx, "A", 1, 0); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Unexpected error: expected: %v, got: %v", context.Canceled, err)
+	}
+}
+
+func TestWithQuota(t *testing.T) {
+
+	testPack, testUnpack, provider := testCreateEnv(t)
+
+	q := NewTokenBucketQuota(TokenBucketLimits{
+		OperationsPerSecond: 0.001,
+		OperationBurst:      2,
+		MaxWait:             time.Millisecond,
+	})
+
+	item := &Item[Key]{
+		Key: Key{X: "A", Y: "B"},
+		Attributes: map[string]any{
+			"aaa": int64(42),
+		},
+	}
+
+	b, l, err := testPack(item, WithQuota(q, "tenant1"))
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+	if _, _, err := testPack(item, WithQuota(q, "tenant1")); err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+	if _, _, err := testPack(item, WithQuota(q, "tenant1")); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("Unexpected error: expected: %v, got: %v", ErrQuotaExceeded, err)
+	}
+
+	e, err := testUnpack(b, l)
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+	e.quota = q
+
+	ctx := ContextWithTenant(context.TODO(), "tenant2")
+	for range 2 {
+		if _, err := e.GetValues(ctx, []string{"aaa"}, provider); err != nil {
+			t.Fatalf("Unexpected error getting values: %v", err)
+		}
+	}
+	if _, err := e.GetValues(ctx, []string{"aaa"}, provider); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("Unexpected error: expected: %v, got: %v", ErrQuotaExceeded, err)
+	}
+}
@@ -0,0 +1,199 @@
+package packer
+
+import (
+	"context"
+	"errors"
+	"hash/crc32"
+	"sort"
+
+	"github.com/gford1000-go/serialise"
+)
+
+// WithReplication writes each element under the specified number of distinct keys, recorded in the
+// envelope, for stores without their own redundancy guarantees.  Unpack falls back across the
+// replicas of any element whose data is missing or corrupted.  A factor of one or less disables replication.
+func WithReplication(factor uint8) func(o *Options) {
+	return func(o *Options) {
+		o.replicationFactor = factor
+	}
+}
+
+// ErrInvalidDataToDeserialiseReplicas raised if the recorded replicas cannot be deserialised
+var ErrInvalidDataToDeserialiseReplicas = errors.New("invalid data, cannot deserialise element replicas")
+
+// elementReplicas records the additional keys of an element, and the chunks it holds
+type elementReplicas[T comparable] struct {
+	keys   []T
+	chunks []string
+}
+
+// addReplicas adds copies of each element's data under new keys to the output
+func addReplicas[T comparable](factor int, elements []T, output map[T]map[string][]byte, creator IDCreator[T]) ([]elementReplicas[T], error) {
+
+	ids, err := createIDs(creator, len(elements)*(factor-1))
+	if err != nil {
+		return nil, err
+	}
+
+	replicas := make([]elementReplicas[T], len(elements))
+	for i, t := range elements {
+		for name := range output[t] {
+			replicas[i].chunks = append(replicas[i].chunks, name)
+		}
+		sort.Strings(replicas[i].chunks)
+
+		for range factor - 1 {
+			r := ids[0]
+			ids = ids[1:]
+			output[r] = output[t]
+			replicas[i].keys = append(replicas[i].keys, r)
+		}
+	}
+	return replicas, nil
+}
+
+// loadFromReplicas retrieves the chunks missing from values, or failing their checksum, from successive replicas
+func loadFromReplicas[T comparable](ctx context.Context, loader DataLoader[T], replicas []elementReplicas[T], checksums map[string]chunkChecksum, values map[string][]byte) error {
+
+	valid := func(name string) bool {
+		v, ok := values[name]
+		if !ok {
+			return false
+		}
+		if cs, found := checksums[name]; found && crc32.Checksum(v, castagnoli) != cs.crc {
+			return false
+		}
+		return true
+	}
+
+	for attempt := 0; ; attempt++ {
+		var keys []T
+		for _, r := range replicas {
+			if attempt >= len(r.keys) {
+				continue
+			}
+			for _, name := range r.chunks {
+				if !valid(name) {
+					keys = append(keys, r.keys[attempt])
+					break
+				}
+			}
+		}
+		if len(keys) == 0 {
+			return nil
+		}
+
+		m, err := loader(ctx, keys)
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		for k, v := range m {
+			if !valid(k) {
+				values[k] = v
+			}
+		}
+	}
+}
+
+func packReplicas[T comparable](replicas []elementReplicas[T], packer IDSerialiser[T], approach serialise.Approach) ([]byte, error) {
+
+	items := []any{int64(len(replicas))}
+	for _, r := range replicas {
+		items = append(items, int64(len(r.keys)))
+		for _, k := range r.keys {
+			b, err := packer.Pack(k)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, b)
+		}
+		items = append(items, int64(len(r.chunks)))
+		for _, c := range r.chunks {
+			items = append(items, c)
+		}
+	}
+
+	b, _, err := serialise.ToBytesMany(items, serialise.WithSerialisationApproach(approach))
+	return b, err
+}
+
+func unpackReplicas[T comparable](data []byte, packer IDSerialiser[T], approach serialise.Approach) ([]elementReplicas[T], error) {
+
+	v, err := serialise.FromBytesMany(data, approach)
+	if err != nil {
+		return nil, err
+	}
+
+	pos := 0
+	next := func() (any, error) {
+		if pos >= len(v) {
+			return nil, ErrInvalidDataToDeserialiseReplicas
+		}
+		pos++
+		return v[pos-1], nil
+	}
+	nextInt := func() (int, error) {
+		x, err := next()
+		if err != nil {
+			return 0, err
+		}
+		i, ok := x.(int64)
+		if !ok || i < 0 || i > int64(len(v)) {
+			return 0, ErrInvalidDataToDeserialiseReplicas
+		}
+		return int(i), nil
+	}
+
+	n, err := nextInt()
+	if err != nil {
+		return nil, err
+	}
+
+	replicas := make([]elementReplicas[T], n)
+	for i := range n {
+		nk, err := nextInt()
+		if err != nil {
+			return nil, err
+		}
+		for range nk {
+			x, err := next()
+			if err != nil {
+				return nil, err
+			}
+			b, ok := x.([]byte)
+			if !ok {
+				return nil, ErrInvalidDataToDeserialiseReplicas
+			}
+			t, err := packer.Unpack(b)
+			if err != nil {
+				return nil, err
+			}
+			replicas[i].keys = append(replicas[i].keys, t)
+		}
+
+		nc, err := nextInt()
+		if err != nil {
+			return nil, err
+		}
+		for range nc {
+			x, err := next()
+			if err != nil {
+				return nil, err
+			}
+			c, ok := x.(string)
+			if !ok {
+				return nil, ErrInvalidDataToDeserialiseReplicas
+			}
+			replicas[i].chunks = append(replicas[i].chunks, c)
+		}
+	}
+
+	if pos != len(v) {
+		return nil, ErrInvalidDataToDeserialiseReplicas
+	}
+
+	return replicas, nil
+}
@@ -0,0 +1,72 @@
+package packer
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestWithReplication(t *testing.T) {
+
+	testPack, testUnpack, provider := testCreateEnv(t)
+
+	item := &Item[Key]{
+		Key: Key{X: "A", Y: "B"},
+		Attributes: map[string]any{
+			"aaa": "Hello World",
+		},
+	}
+
+	info, l, err := testPack(item, WithReplication(3), WithChecksums())
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+
+	// Record the keys requested, and lose or corrupt the primary and first replica
+	var requested [][]Key
+	loader := func(ctx context.Context, keys []Key) (map[string][]byte, error) {
+		requested = append(requested, keys)
+		m := map[string][]byte{}
+		for _, key := range keys {
+			if key == item.Key {
+				continue
+			}
+			km, err := l(ctx, []Key{key})
+			if err != nil {
+				return nil, err
+			}
+			for k, v := range km {
+				if len(requested) == 2 {
+					v = append([]byte{}, v...)
+					v[0] ^= 0xff
+				}
+				m[k] = v
+			}
+		}
+		return m, nil
+	}
+
+	e, err := testUnpack(info, loader)
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+	if len(requested) != 3 {
+		t.Fatalf("Expected primary and two replicas to be requested, got: %v", requested)
+	}
+
+	m, err := e.GetValues(context.TODO(), []string{"aaa"}, provider)
+	if err != nil {
+		t.Fatalf("Unexpected error getting values: %v", err)
+	}
+	if m["aaa"].(string) != "Hello World" {
+		t.Fatalf("Unexpected value: %v", m["aaa"])
+	}
+
+	// Loss of all copies cannot be recovered
+	lost := func(ctx context.Context, keys []Key) (map[string][]byte, error) {
+		return map[string][]byte{}, nil
+	}
+	if _, err := testUnpack(info, lost); !errors.Is(err, ErrInvalidDataToUnpack) {
+		t.Fatalf("Unexpected error: expected: %v, got: %v", ErrInvalidDataToUnpack, err)
+	}
+}
@@ -0,0 +1,62 @@
+package packer
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrItemHasNoEnvelope raised if ReWrap is called on an EncryptedItem that was not created by unpacking data
+var ErrItemHasNoEnvelope = errors.New("item has no packed data to rewrap")
+
+// ReWrap returns the packed data of the item with its data encryption key, decrypted by the provider, encrypted by
+// the target provider instead, which must implement KeyWrapper.  Attribute values and elements are unchanged, so
+// only the returned data needs to be stored to rotate the wrapping key of the item.  Any signature is removed, as
+// it covers the encrypted data encryption key (see WithSigner).
+func (e *EncryptedItem[T]) ReWrap(ctx context.Context, provider, target EnvelopeKeyProvider) ([]byte, error) {
+
+	if provider == nil || target == nil {
+		return nil, ErrProviderIsNil
+	}
+	wrapper, ok := target.(KeyWrapper)
+	if !ok {
+		return nil, ErrProviderCannotWrap
+	}
+	if e.envelope == nil {
+		return nil, ErrItemHasNoEnvelope
+	}
+
+	key, err := e.decryptKey(ctx, provider)
+	if err != nil {
+		return nil, err
+	}
+
+	return rewrapEnvelope(ctx, e.version, e.wire, e.envelope, key, wrapper)
+}
+
+// ReWrapEnvelope returns the packed data, as returned by Pack, with its data encryption key, decrypted by the provider,
+// encrypted by the target provider instead, which must implement KeyWrapper.  Unlike ReWrap, no elements are loaded,
+// allowing the wrapping keys of many items to be rotated without the cost of Unpack.  Packed data that has been split
+// into continuation elements must be joined first (see JoinEnvelope).
+func ReWrapEnvelope[T comparable](ctx context.Context, data []byte, provider, target EnvelopeKeyProvider, idRetriever GetIDSerialiser[T]) ([]byte, error) {
+
+	if len(data) == 0 {
+		return nil, ErrUnpackNoData
+	}
+	if provider == nil || target == nil {
+		return nil, ErrProviderIsNil
+	}
+	if idRetriever == nil {
+		return nil, ErrIDRetrieverIsNil
+	}
+	wrapper, ok := target.(KeyWrapper)
+	if !ok {
+		return nil, ErrProviderCannotWrap
+	}
+
+	env, err := openEnvelope(ctx, data, provider, idRetriever)
+	if err != nil {
+		return nil, err
+	}
+
+	return env.rewrap(ctx, wrapper)
+}
@@ -0,0 +1,78 @@
+package packer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestReWrap(t *testing.T) {
+
+	testPack, testUnpack, oldProvider := testCreateEnv(t)
+
+	newProvider, err := NewEnvelopeKeyProvider(&EnvelopeKeyProviderInfo{ID: "new", Key: []byte("21987654321098765432109876543210")},
+		func(EnvelopeKeyID) (EnvelopeKeyProvider, error) { return nil, errors.New("unknown provider id") })
+	if err != nil {
+		t.Fatalf("Unexpected error creating provider: %v", err)
+	}
+
+	serialiser, _ := NewKeySerialiser()
+	idRetriever := func(string) (IDSerialiser[Key], error) { return serialiser, nil }
+
+	item := &Item[Key]{Key: Key{X: "A", Y: "B"}, Attributes: map[string]any{"aaa": "Hello World"}}
+
+	info, l, err := testPack(item)
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+
+	e, err := testUnpack(info, l)
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+
+	fromItem, err := e.ReWrap(context.TODO(), oldProvider, newProvider)
+	if err != nil {
+		t.Fatalf("Unexpected error rewrapping item: %v", err)
+	}
+	fromEnvelope, err := ReWrapEnvelope(context.TODO(), info, oldProvider, newProvider, idRetriever)
+	if err != nil {
+		t.Fatalf("Unexpected error rewrapping envelope: %v", err)
+	}
+
+	for _, rewrapped := range [][]byte{fromItem, fromEnvelope} {
+		if bytes.Equal(rewrapped, info) {
+			t.Fatal("Expected the packed data to change")
+		}
+
+		// The original elements are unpacked with the new provider only
+		params := &UnpackParams[Key]{DataLoader: l, IDRetriever: idRetriever, Provider: oldProvider}
+		if _, err := Unpack(context.TODO(), rewrapped, params); err == nil {
+			t.Fatal("Expected the old provider to be unable to unpack the rewrapped data")
+		}
+
+		params.Provider = newProvider
+		u, err := Unpack(context.TODO(), rewrapped, params)
+		if err != nil {
+			t.Fatalf("Unexpected error unpacking: %v", err)
+		}
+		values, err := u.GetValues(context.TODO(), []string{"aaa"}, newProvider)
+		if err != nil {
+			t.Fatalf("Unexpected error getting values: %v", err)
+		}
+		if values["aaa"] != "Hello World" {
+			t.Fatalf("Unexpected values: %v", values)
+		}
+	}
+
+	if _, err := e.ReWrap(context.TODO(), oldProvider, &decryptOnlyProvider{provider: newProvider}); !errors.Is(err, ErrProviderCannotWrap) {
+		t.Fatalf("Expected ErrProviderCannotWrap, got: %v", err)
+	}
+	if _, err := (&EncryptedItem[Key]{}).ReWrap(context.TODO(), oldProvider, newProvider); !errors.Is(err, ErrItemHasNoEnvelope) {
+		t.Fatalf("Expected ErrItemHasNoEnvelope, got: %v", err)
+	}
+	if _, err := ReWrapEnvelope(context.TODO(), info, nil, newProvider, idRetriever); !errors.Is(err, ErrProviderIsNil) {
+		t.Fatalf("Expected ErrProviderIsNil, got: %v", err)
+	}
+}
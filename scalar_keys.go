@@ -0,0 +1,50 @@
+package packer
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// NewStringSerialiser returns an IDSerialiser for string keys, serialising as the bytes of the string
+func NewStringSerialiser() (IDSerialiser[string], error) {
+	return stringSerialiser{}, nil
+}
+
+type stringSerialiser struct{}
+
+func (stringSerialiser) Name() string {
+	return "StringV1"
+}
+
+func (stringSerialiser) Pack(s string) ([]byte, error) {
+	return []byte(s), nil
+}
+
+func (stringSerialiser) Unpack(data []byte) (string, error) {
+	return string(data), nil
+}
+
+// NewInt64Serialiser returns an IDSerialiser for int64 keys, serialising as 8 bytes in big endian order
+func NewInt64Serialiser() (IDSerialiser[int64], error) {
+	return int64Serialiser{}, nil
+}
+
+// ErrInt64DeserialisationError is raised when data does not deserialise to an int64
+var ErrInt64DeserialisationError = errors.New("invalid data passed - cannot deserialise int64 instance")
+
+type int64Serialiser struct{}
+
+func (int64Serialiser) Name() string {
+	return "Int64V1"
+}
+
+func (int64Serialiser) Pack(i int64) ([]byte, error) {
+	return binary.BigEndian.AppendUint64(nil, uint64(i)), nil
+}
+
+func (int64Serialiser) Unpack(data []byte) (int64, error) {
+	if len(data) != 8 {
+		return 0, ErrInt64DeserialisationError
+	}
+	return int64(binary.BigEndian.Uint64(data)), nil
+}
@@ -0,0 +1,55 @@
+package packer
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestNewStringSerialiser(t *testing.T) {
+
+	s, err := NewStringSerialiser()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if s.Name() != "StringV1" {
+		t.Fatalf("Unexpected name: %s", s.Name())
+	}
+
+	for _, k := range []string{"", "a", "key/with:separators", "ключ"} {
+		b, err := s.Pack(k)
+		if err != nil {
+			t.Fatalf("Unexpected error packing: %v", err)
+		}
+		v, err := s.Unpack(b)
+		if err != nil || v != k {
+			t.Fatalf("Mismatch: expected %q, got %q, %v", k, v, err)
+		}
+	}
+}
+
+func TestNewInt64Serialiser(t *testing.T) {
+
+	s, err := NewInt64Serialiser()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if s.Name() != "Int64V1" {
+		t.Fatalf("Unexpected name: %s", s.Name())
+	}
+
+	for _, k := range []int64{0, 1, -1, math.MaxInt64, math.MinInt64} {
+		b, err := s.Pack(k)
+		if err != nil || len(b) != 8 {
+			t.Fatalf("Unexpected result packing: %x, %v", b, err)
+		}
+		v, err := s.Unpack(b)
+		if err != nil || v != k {
+			t.Fatalf("Mismatch: expected %d, got %d, %v", k, v, err)
+		}
+	}
+
+	if _, err := s.Unpack([]byte{1, 2}); !errors.Is(err, ErrInt64DeserialisationError) {
+		t.Fatalf("Expected ErrInt64DeserialisationError, got: %v", err)
+	}
+}
@@ -0,0 +1,144 @@
+package packer
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// AttributeRule describes the expected type and constraints of an attribute value
+type AttributeRule struct {
+	// Type is the expected type of the value (e.g. reflect.TypeFor[string]()); nil allows any type.
+	// If Type is an interface, the value must implement it.
+	Type reflect.Type
+	// Required attributes must be present in the item
+	Required bool
+	// Constraint optionally applies further checks to the value, returning an error if they are not met
+	Constraint func(v any) error
+}
+
+// Schema maps attribute names to the rules that their values must satisfy, so that malformed items
+// are rejected when they are packed, rather than surfacing as failed type assertions when read
+type Schema struct {
+	// Attributes holds the rule for each named attribute
+	Attributes map[string]AttributeRule
+	// AllowUnknown permits attributes that have no rule in the Schema
+	AllowUnknown bool
+}
+
+// ErrSchemaViolation is matched by SchemaViolationError, using errors.Is
+var ErrSchemaViolation = errors.New("attribute does not conform to schema")
+
+// SchemaViolationError raised if an attribute does not satisfy the Schema
+type SchemaViolationError struct {
+	// Attribute that does not conform
+	Attribute string
+	// Reason describes the violation
+	Reason string
+	// Err is the error returned by the rule's Constraint, if any
+	Err error
+}
+
+func (e *SchemaViolationError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%v: attribute '%s' %s: %v", ErrSchemaViolation, e.Attribute, e.Reason, e.Err)
+	}
+	return fmt.Sprintf("%v: attribute '%s' %s", ErrSchemaViolation, e.Attribute, e.Reason)
+}
+
+func (e *SchemaViolationError) Unwrap() []error {
+	if e.Err != nil {
+		return []error{ErrSchemaViolation, e.Err}
+	}
+	return []error{ErrSchemaViolation}
+}
+
+// WithSchema enforces the Schema on the attributes of items during Pack.
+// Values are checked after any pre-pack hooks have been applied (see WithPrePackHooks).
+func WithSchema(s *Schema) func(o *Options) {
+	return func(o *Options) {
+		o.schema = s
+	}
+}
+
+// Validate checks that the attributes satisfy the Schema
+func (s *Schema) Validate(attrs map[string]any) error {
+	names := make([]string, 0, len(attrs))
+	for k := range attrs {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	if err := s.checkNames(names); err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := s.checkValue(name, attrs[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkNames confirms that all required attributes are present, and that no unknown attributes are included
+func (s *Schema) checkNames(names []string) error {
+	if s == nil {
+		return nil
+	}
+
+	present := make(map[string]bool, len(names))
+	for _, name := range names {
+		present[name] = true
+		if _, ok := s.Attributes[name]; !ok && !s.AllowUnknown {
+			return &SchemaViolationError{Attribute: name, Reason: "is not in the schema"}
+		}
+	}
+
+	required := []string{}
+	for name, rule := range s.Attributes {
+		if rule.Required && !present[name] {
+			required = append(required, name)
+		}
+	}
+	if len(required) > 0 {
+		sort.Strings(required)
+		return &SchemaViolationError{Attribute: required[0], Reason: "is required"}
+	}
+
+	return nil
+}
+
+// checkValue confirms that the value satisfies the rule for the attribute, if there is one
+func (s *Schema) checkValue(name string, v any) error {
+	if s == nil {
+		return nil
+	}
+
+	rule, ok := s.Attributes[name]
+	if !ok {
+		return nil
+	}
+
+	if rule.Type != nil {
+		t := reflect.TypeOf(v)
+		switch {
+		case t == nil:
+			return &SchemaViolationError{Attribute: name, Reason: fmt.Sprintf("is nil, expected %v", rule.Type)}
+		case rule.Type.Kind() == reflect.Interface:
+			if !t.Implements(rule.Type) {
+				return &SchemaViolationError{Attribute: name, Reason: fmt.Sprintf("has type %v, which does not implement %v", t, rule.Type)}
+			}
+		case t != rule.Type:
+			return &SchemaViolationError{Attribute: name, Reason: fmt.Sprintf("has type %v, expected %v", t, rule.Type)}
+		}
+	}
+
+	if rule.Constraint != nil {
+		if err := rule.Constraint(v); err != nil {
+			return &SchemaViolationError{Attribute: name, Reason: "fails constraint", Err: err}
+		}
+	}
+
+	return nil
+}
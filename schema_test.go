@@ -0,0 +1,103 @@
+package packer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSchema_Validate(t *testing.T) {
+
+	errNegative := errors.New("must not be negative")
+
+	s := &Schema{
+		Attributes: map[string]AttributeRule{
+			"name": {Type: reflect.TypeFor[string](), Required: true},
+			"age": {Type: reflect.TypeFor[int64](), Constraint: func(v any) error {
+				if v.(int64) < 0 {
+					return errNegative
+				}
+				return nil
+			}},
+			"label": {Type: reflect.TypeFor[fmt.Stringer]()},
+		},
+	}
+
+	tests := []struct {
+		attrs     map[string]any
+		attribute string
+		err       error
+	}{
+		{attrs: map[string]any{"name": "Bob", "age": int64(42)}},
+		{attrs: map[string]any{"name": "Bob", "label": time.Second}},
+		{attrs: map[string]any{"age": int64(42)}, attribute: "name", err: ErrSchemaViolation},
+		{attrs: map[string]any{"name": 42}, attribute: "name", err: ErrSchemaViolation},
+		{attrs: map[string]any{"name": nil}, attribute: "name", err: ErrSchemaViolation},
+		{attrs: map[string]any{"name": "Bob", "age": int64(-1)}, attribute: "age", err: errNegative},
+		{attrs: map[string]any{"name": "Bob", "label": "A"}, attribute: "label", err: ErrSchemaViolation},
+		{attrs: map[string]any{"name": "Bob", "other": "A"}, attribute: "other", err: ErrSchemaViolation},
+	}
+
+	for i, test := range tests {
+		err := s.Validate(test.attrs)
+		if !errors.Is(err, test.err) {
+			t.Fatalf("(%d) Unexpected error: expected: %v, got: %v", i, test.err, err)
+		}
+		if test.err == nil {
+			continue
+		}
+		var v *SchemaViolationError
+		if !errors.As(err, &v) || v.Attribute != test.attribute {
+			t.Fatalf("(%d) Unexpected violation: %v", i, err)
+		}
+	}
+
+	s.AllowUnknown = true
+	if err := s.Validate(map[string]any{"name": "Bob", "other": "A"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+func TestWithSchema(t *testing.T) {
+
+	testPack, _, provider := testCreateEnv(t)
+
+	s := &Schema{
+		Attributes: map[string]AttributeRule{
+			"name": {Type: reflect.TypeFor[string](), Required: true},
+		},
+	}
+
+	item := &Item[Key]{
+		Key: Key{X: "A", Y: "B"},
+		Attributes: map[string]any{
+			"name": int64(1),
+		},
+	}
+
+	if _, _, err := testPack(item, WithSchema(s)); !errors.Is(err, ErrSchemaViolation) {
+		t.Fatalf("Unexpected error: expected: %v, got: %v", ErrSchemaViolation, err)
+	}
+
+	// Values can also be checked when they are read
+	b, l, err := testPack(item)
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+
+	e, err := Unpack(context.TODO(), b, &UnpackParams[Key]{
+		IDRetriever: func(string) (IDSerialiser[Key], error) { return NewKeySerialiser() },
+		Provider:    provider,
+		DataLoader:  l,
+		Schema:      s,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+	if _, err := e.GetValues(context.TODO(), []string{"name"}, provider); !errors.Is(err, ErrSchemaViolation) {
+		t.Fatalf("Unexpected error: expected: %v, got: %v", ErrSchemaViolation, err)
+	}
+}
@@ -0,0 +1,325 @@
+package packer
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/gford1000-go/serialise"
+)
+
+// Signer signs packed data on behalf of its producer, so that consumers can verify who packed it.
+// Implementations must be safe for concurrent use.
+type Signer interface {
+	// ID identifies the signer, and is recorded with the signature so that the matching Verifier can be selected
+	ID() string
+	// Sign returns the signature of the message
+	Sign(message []byte) ([]byte, error)
+}
+
+// Verifier checks signatures created by the Signer with the same ID.
+// Implementations must be safe for concurrent use.
+type Verifier interface {
+	// ID identifies the Signer whose signatures can be verified
+	ID() string
+	// Verify returns an error if the signature is not a valid signature of the message
+	Verify(message, signature []byte) error
+}
+
+// WithSigner signs the visible part of the envelope with the Signer, recording the signature and the ID of
+// the signer in the packed data, so that Unpack can verify the producer of the data (see UnpackParams.Verifiers).
+// The SHA-256 of each stored chunk is recorded in the signed packing details, and each loaded chunk is checked
+// against it during Unpack, so that chunks cannot be altered without invalidating the signature.
+// As the encrypted data encryption key is signed, rewrapping the data removes its signature.
+// Signed data cannot be unpacked with a ReaderAtLoader, nor by earlier releases.
+func WithSigner(signer Signer) func(o *Options) {
+	return func(o *Options) {
+		o.signer = signer
+	}
+}
+
+// ErrSignatureRequired raised if the UnpackParams include Verifiers, and the packed data is not signed
+var ErrSignatureRequired = errors.New("packed data is not signed")
+
+// ErrUnknownSigner raised if packed data is signed by a signer that is not in the Verifiers of the UnpackParams
+var ErrUnknownSigner = errors.New("packed data is signed by an unknown signer")
+
+// ErrInvalidSignature raised if the signature of packed data is not valid
+var ErrInvalidSignature = errors.New("signature is not valid - packed data has been altered or the signer is incorrect")
+
+// ErrSignerIDIsEmpty raised if a Signer or Verifier is created without an ID
+var ErrSignerIDIsEmpty = errors.New("signer ID must not be empty")
+
+// ErrInvalidEd25519Key raised if an Ed25519 Signer or Verifier is created with a key of the wrong size
+var ErrInvalidEd25519Key = errors.New("invalid Ed25519 key")
+
+// NewEd25519Signer returns a Signer creating Ed25519 signatures with the private key
+func NewEd25519Signer(id string, key ed25519.PrivateKey) (Signer, error) {
+	if len(id) == 0 {
+		return nil, ErrSignerIDIsEmpty
+	}
+	if len(key) != ed25519.PrivateKeySize {
+		return nil, ErrInvalidEd25519Key
+	}
+	return &ed25519Signer{id: id, key: key}, nil
+}
+
+type ed25519Signer struct {
+	id  string
+	key ed25519.PrivateKey
+}
+
+func (s *ed25519Signer) ID() string {
+	return s.id
+}
+
+func (s *ed25519Signer) Sign(message []byte) ([]byte, error) {
+	return ed25519.Sign(s.key, message), nil
+}
+
+// NewEd25519Verifier returns a Verifier of Ed25519 signatures created by the private key of the public key
+func NewEd25519Verifier(id string, key ed25519.PublicKey) (Verifier, error) {
+	if len(id) == 0 {
+		return nil, ErrSignerIDIsEmpty
+	}
+	if len(key) != ed25519.PublicKeySize {
+		return nil, ErrInvalidEd25519Key
+	}
+	return &ed25519Verifier{id: id, key: key}, nil
+}
+
+type ed25519Verifier struct {
+	id  string
+	key ed25519.PublicKey
+}
+
+func (v *ed25519Verifier) ID() string {
+	return v.id
+}
+
+func (v *ed25519Verifier) Verify(message, signature []byte) error {
+	if !ed25519.Verify(v.key, message, signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// signatureLabel separates signatures of packed data from any other use of the signer's key
+var signatureLabel = []byte("packer signature")
+
+// signedMessage returns the message signed for the finalised data, which includes the packing version,
+// wire format and signer ID, and all fields preceding the signature
+func signedMessage(packingVersion PackVersion, wire WireFormat, signerID string, finalisedData []any) ([]byte, error) {
+
+	b := append([]byte{}, signatureLabel...)
+	b = append(b, byte(packingVersion), byte(wire))
+	b = binary.BigEndian.AppendUint64(b, uint64(len(signerID)))
+	b = append(b, signerID...)
+
+	for i := range finalisedSignerID {
+		f, err := finalisedField(finalisedData, i)
+		if err != nil {
+			return nil, err
+		}
+		b = binary.BigEndian.AppendUint64(b, uint64(len(f)))
+		b = append(b, f...)
+	}
+	return b, nil
+}
+
+// signFinalisedData returns the finalised data with the signer ID and signature recorded
+func signFinalisedData(signer Signer, packingVersion PackVersion, wire WireFormat, finalisedData []any) ([]any, error) {
+
+	id := signer.ID()
+	if len(id) == 0 {
+		return nil, ErrSignerIDIsEmpty
+	}
+
+	message, err := signedMessage(packingVersion, wire, id, finalisedData)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := signer.Sign(message)
+	if err != nil {
+		return nil, err
+	}
+
+	finalisedData = withFinalisedField(finalisedData, finalisedSignerID, []byte(id))
+	return withFinalisedField(finalisedData, finalisedSignature, signature), nil
+}
+
+// verifyFinalisedData checks the signature of the finalised data with the Verifier of its signer, returning
+// the ID of the signer.  Signatures are only checked if there are Verifiers, in which case one is required.
+func verifyFinalisedData(packingVersion PackVersion, wire WireFormat, finalisedData []any, verifiers []Verifier) (string, error) {
+
+	if len(verifiers) == 0 {
+		return "", nil
+	}
+
+	id, err := finalisedField(finalisedData, finalisedSignerID)
+	if err != nil {
+		return "", err
+	}
+	signature, err := finalisedField(finalisedData, finalisedSignature)
+	if err != nil {
+		return "", err
+	}
+	if len(id) == 0 || len(signature) == 0 {
+		return "", ErrSignatureRequired
+	}
+
+	var verifier Verifier
+	for _, v := range verifiers {
+		if v != nil && v.ID() == string(id) {
+			verifier = v
+			break
+		}
+	}
+	if verifier == nil {
+		return "", ErrUnknownSigner
+	}
+
+	message, err := signedMessage(packingVersion, wire, string(id), finalisedData)
+	if err != nil {
+		return "", err
+	}
+	if err := verifier.Verify(message, signature); err != nil {
+		return "", ErrInvalidSignature
+	}
+	return string(id), nil
+}
+
+// VerifyPackSignature checks the signature of data returned by Pack, using the Verifier of its signer, and
+// returns the ID of the signer (see WithSigner).  The envelope key is not required, but an envelope split
+// across continuation elements must first be joined (see JoinEnvelope).
+func VerifyPackSignature(data []byte, verifiers ...Verifier) (string, error) {
+
+	if len(verifiers) == 0 {
+		return "", ErrUnknownSigner
+	}
+
+	packingVersion, b, err := splitPackingVersion(data)
+	if err != nil {
+		return "", err
+	}
+	if !packingVersion.supported() {
+		return "", ErrUnsupportedPackVersion
+	}
+
+	finalisedData, wire, err := decodeFinalisedData(packingVersion, b)
+	if err != nil {
+		return "", err
+	}
+	return verifyFinalisedData(packingVersion, wire, finalisedData, verifiers)
+}
+
+// unsignedFinalisedData returns the finalised data without its signer ID and signature, which no longer
+// apply once a signed field has been changed
+func unsignedFinalisedData(finalisedData []any) []any {
+	if len(finalisedData) > finalisedSignerID {
+		return finalisedData[:finalisedSignerID]
+	}
+	return finalisedData
+}
+
+// createChunkDigests returns the SHA-256 of each stored chunk held by the elements
+func createChunkDigests[T comparable](elements []T, output map[T]map[string][]byte) map[string][]byte {
+	digests := map[string][]byte{}
+	for _, t := range elements {
+		for name, v := range output[t] {
+			h := sha256.Sum256(v)
+			digests[name] = h[:]
+		}
+	}
+	return digests
+}
+
+func packChunkDigests(digests map[string][]byte, approach serialise.Approach) ([]byte, error) {
+
+	names := make([]string, 0, len(digests))
+	for k := range digests {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	items := make([]any, 0, 2*len(names))
+	for _, name := range names {
+		items = append(items, name, digests[name])
+	}
+
+	b, _, err := serialise.ToBytesMany(items, serialise.WithSerialisationApproach(approach))
+	return b, err
+}
+
+// ErrInvalidDataToDeserialiseChunkDigests raised if the recorded chunk digests cannot be deserialised
+var ErrInvalidDataToDeserialiseChunkDigests = errors.New("invalid data, cannot deserialise chunk digests")
+
+func unpackChunkDigests(data []byte, approach serialise.Approach) (map[string][]byte, error) {
+
+	v, err := serialise.FromBytesMany(data, approach)
+	if err != nil {
+		return nil, err
+	}
+	if len(v)%2 != 0 {
+		return nil, ErrInvalidDataToDeserialiseChunkDigests
+	}
+
+	digests := make(map[string][]byte, len(v)/2)
+	for i := 0; i < len(v); i += 2 {
+		name, ok := v[i].(string)
+		if !ok {
+			return nil, ErrInvalidDataToDeserialiseChunkDigests
+		}
+		digest, ok := v[i+1].([]byte)
+		if !ok || len(digest) != sha256.Size {
+			return nil, ErrInvalidDataToDeserialiseChunkDigests
+		}
+		digests[name] = digest
+	}
+
+	return digests, nil
+}
+
+// verifyChunkDigests confirms that each loaded chunk of the attributes matches its signed digest
+func verifyChunkDigests(digests map[string][]byte, attrMap map[string][]string, data map[string][]byte) error {
+	if digests == nil {
+		return nil
+	}
+	for attr, chunks := range attrMap {
+		for _, chunk := range chunks {
+			v, ok := data[chunk]
+			if !ok {
+				return ErrInvalidDataToUnpack
+			}
+			h := sha256.Sum256(v)
+			if !hmac.Equal(h[:], digests[chunk]) {
+				return fmt.Errorf("%w: chunk '%s' of attribute '%s' has been altered", ErrInvalidSignature, chunk, attr)
+			}
+		}
+	}
+	return nil
+}
+
+// sizingSigner stands in for the Signer when estimating the packed size, so that the Signer is not called.
+// Signatures are assumed to be the size of an Ed25519 signature, and are random as a zeroed signature
+// may be serialised more compactly than a real one.
+type sizingSigner struct {
+	id string
+}
+
+func (s *sizingSigner) ID() string {
+	return s.id
+}
+
+func (s *sizingSigner) Sign([]byte) ([]byte, error) {
+	b := make([]byte, ed25519.SignatureSize)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
@@ -0,0 +1,270 @@
+package packer
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/gford1000-go/serialise"
+)
+
+func testSigner(t *testing.T, id string) (Signer, Verifier) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Unexpected error generating key: %v", err)
+	}
+	signer, err := NewEd25519Signer(id, private)
+	if err != nil {
+		t.Fatalf("Unexpected error creating signer: %v", err)
+	}
+	verifier, err := NewEd25519Verifier(id, public)
+	if err != nil {
+		t.Fatalf("Unexpected error creating verifier: %v", err)
+	}
+	return signer, verifier
+}
+
+func TestWithSigner(t *testing.T) {
+
+	testPack, _, provider := testCreateEnv(t)
+
+	signer, verifier := testSigner(t, "producer")
+	_, other := testSigner(t, "other")
+
+	item := &Item[Key]{
+		Key:        Key{X: "A", Y: "B"},
+		Attributes: map[string]any{"a": "x", "b": int64(2)},
+	}
+
+	serialiser, _ := NewKeySerialiser()
+	unpack := func(b []byte, l DataLoader[Key], verifiers ...Verifier) (*EncryptedItem[Key], error) {
+		return Unpack(context.TODO(), b, &UnpackParams[Key]{
+			DataLoader:  l,
+			IDRetriever: func(string) (IDSerialiser[Key], error) { return serialiser, nil },
+			Provider:    provider,
+			Verifiers:   verifiers,
+		})
+	}
+
+	for _, opts := range [][]func(*Options){
+		{WithSigner(signer)},
+		{WithSigner(signer), WithEnvelopeMAC(), WithDigest([]byte("digest key"))},
+		{WithSigner(signer), WithPackingVersion(V2)},
+		{WithSigner(signer), WithPackingVersion(V2), WithWireFormat(ProtoWireFormat)},
+		{WithSigner(signer), WithEnvelopeMAC(), WithPackingVersion(V2), WithWireFormat(CBORWireFormat)},
+	} {
+		b, l, err := testPack(item, opts...)
+		if err != nil {
+			t.Fatalf("Unexpected error packing: %v", err)
+		}
+
+		e, err := unpack(b, l, other, verifier)
+		if err != nil {
+			t.Fatalf("Unexpected error unpacking: %v", err)
+		}
+		if e.SignerID() != "producer" {
+			t.Fatalf("Expected the signer to be recorded, got: %q", e.SignerID())
+		}
+		values, err := e.GetValues(context.TODO(), []string{"a", "b"}, provider)
+		if err != nil {
+			t.Fatalf("Unexpected error getting values: %v", err)
+		}
+		if !maps.Equal(values, item.Attributes) {
+			t.Fatalf("Mismatch: expected %v, got %v", item.Attributes, values)
+		}
+
+		mb, err := e.MarshalBinary()
+		if err != nil {
+			t.Fatalf("Unexpected error marshalling: %v", err)
+		}
+		restored, err := UnmarshalEncryptedItem(mb, &UnpackParams[Key]{
+			DataLoader:  l,
+			IDRetriever: func(string) (IDSerialiser[Key], error) { return serialiser, nil },
+			Provider:    provider,
+			Verifiers:   []Verifier{verifier},
+		})
+		if err != nil || restored.SignerID() != "producer" {
+			t.Fatalf("Unexpected result unmarshalling: %v", err)
+		}
+
+		if id, err := VerifyPackSignature(b, verifier); err != nil || id != "producer" {
+			t.Fatalf("Unexpected result verifying without the envelope key: %q, %v", id, err)
+		}
+		if _, err := unpack(b, l, other); !errors.Is(err, ErrUnknownSigner) {
+			t.Fatalf("Expected ErrUnknownSigner, got: %v", err)
+		}
+
+		// Rewrapping removes the signature, as the encrypted key is signed
+		rb, err := e.ReWrap(context.TODO(), provider, provider)
+		if err != nil {
+			t.Fatalf("Unexpected error rewrapping: %v", err)
+		}
+		if _, err := unpack(rb, l); err != nil {
+			t.Fatalf("Unexpected error unpacking rewrapped data: %v", err)
+		}
+		if _, err := unpack(rb, l, verifier); !errors.Is(err, ErrSignatureRequired) {
+			t.Fatalf("Expected ErrSignatureRequired, got: %v", err)
+		}
+
+		// Altering a signed field is detected before the data encryption key is decrypted
+		packingVersion, vb, _ := splitPackingVersion(b)
+		finalisedData, wire, _ := decodeFinalisedData(packingVersion, vb)
+		altered := slices.Clone(finalisedData)
+		altered[1] = "Altered"
+		ab, _ := encodeFinalisedData(packingVersion, wire, altered)
+		ab, _ = joinPackingVersion(packingVersion, ab)
+		if _, err := unpack(ab, l, verifier); !errors.Is(err, ErrInvalidSignature) {
+			t.Fatalf("Expected ErrInvalidSignature, got: %v", err)
+		}
+
+		// The encrypted key is signed, so cannot be substituted
+		altered = slices.Clone(finalisedData)
+		altered[0] = append(slices.Clone(altered[0].([]byte)), 0)
+		ab, _ = encodeFinalisedData(packingVersion, wire, altered)
+		ab, _ = joinPackingVersion(packingVersion, ab)
+		if _, err := VerifyPackSignature(ab, verifier); !errors.Is(err, ErrInvalidSignature) {
+			t.Fatalf("Expected ErrInvalidSignature, got: %v", err)
+		}
+	}
+
+	// Without verifiers, signatures are not checked; with verifiers, unsigned data is rejected
+	b, l, err := testPack(item)
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+	if e, err := unpack(b, l); err != nil || e.SignerID() != "" {
+		t.Fatalf("Unexpected result unpacking unsigned data: %v", err)
+	}
+	if _, err := unpack(b, l, verifier); !errors.Is(err, ErrSignatureRequired) {
+		t.Fatalf("Expected ErrSignatureRequired, got: %v", err)
+	}
+}
+
+func TestNewEd25519Signer(t *testing.T) {
+
+	if _, err := NewEd25519Signer("", make([]byte, ed25519.PrivateKeySize)); !errors.Is(err, ErrSignerIDIsEmpty) {
+		t.Fatalf("Expected ErrSignerIDIsEmpty, got: %v", err)
+	}
+	if _, err := NewEd25519Signer("id", make([]byte, 10)); !errors.Is(err, ErrInvalidEd25519Key) {
+		t.Fatalf("Expected ErrInvalidEd25519Key, got: %v", err)
+	}
+	if _, err := NewEd25519Verifier("", make([]byte, ed25519.PublicKeySize)); !errors.Is(err, ErrSignerIDIsEmpty) {
+		t.Fatalf("Expected ErrSignerIDIsEmpty, got: %v", err)
+	}
+	if _, err := NewEd25519Verifier("id", make([]byte, 10)); !errors.Is(err, ErrInvalidEd25519Key) {
+		t.Fatalf("Expected ErrInvalidEd25519Key, got: %v", err)
+	}
+}
+
+func TestWithSigner_1(t *testing.T) {
+
+	testPack, _, provider := testCreateEnv(t)
+
+	signer, verifier := testSigner(t, "producer")
+
+	item := &Item[Key]{
+		Key:        Key{X: "A", Y: "B"},
+		Attributes: map[string]any{"a": "x", "b": int64(2)},
+	}
+
+	b, l, err := testPack(item, WithSigner(signer))
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+
+	// Altering any stored chunk invalidates the signature, even though the envelope is unchanged
+	tampered := func(ctx context.Context, keys []Key) (map[string][]byte, error) {
+		m, err := l(ctx, keys)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range m {
+			v = slices.Clone(v)
+			v[len(v)-1] ^= 1
+			m[k] = v
+			break
+		}
+		return m, nil
+	}
+
+	serialiser, _ := NewKeySerialiser()
+	params := &UnpackParams[Key]{
+		DataLoader:  tampered,
+		IDRetriever: func(string) (IDSerialiser[Key], error) { return serialiser, nil },
+		Provider:    provider,
+		Verifiers:   []Verifier{verifier},
+	}
+
+	if _, err := VerifyPackSignature(b, verifier); err != nil {
+		t.Fatalf("Unexpected error verifying the envelope: %v", err)
+	}
+	if _, err := Unpack(context.TODO(), b, params); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("Expected ErrInvalidSignature, got: %v", err)
+	}
+
+	params.DataLoader = l
+	if _, err := Unpack(context.TODO(), b, params); err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+}
+
+type countingSigner struct {
+	Signer
+	calls int
+}
+
+func (c *countingSigner) Sign(message []byte) ([]byte, error) {
+	c.calls++
+	return c.Signer.Sign(message)
+}
+
+func TestWithSigner_2(t *testing.T) {
+
+	_, _, provider := testCreateEnv(t)
+	serialiser, _ := NewKeySerialiser()
+
+	s, _ := testSigner(t, "producer")
+	signer := &countingSigner{Signer: s}
+
+	item := &Item[Key]{
+		Key:        Key{X: "A", Y: "B"},
+		Attributes: map[string]any{"a": "x", "b": int64(2)},
+	}
+	params := &PackParams[Key]{
+		Provider: provider,
+		Creator:  NewKeyCreator(defaultLen),
+		Packer:   serialiser,
+		Approach: serialise.NewMinDataApproachWithVersion(serialise.V1),
+	}
+
+	// Estimation reserves space for the signature, without calling the Signer
+	unsigned, err := EstimatePackedSize(item, params)
+	if err != nil {
+		t.Fatalf("Unexpected error estimating: %v", err)
+	}
+	estimate, err := EstimatePackedSize(item, params, WithSigner(signer))
+	if err != nil {
+		t.Fatalf("Unexpected error estimating: %v", err)
+	}
+	if signer.calls != 0 {
+		t.Fatalf("Expected no calls to the Signer during estimation, got: %d", signer.calls)
+	}
+	if estimate.InfoSize < unsigned.InfoSize+ed25519.SignatureSize {
+		t.Fatalf("Expected the signature to be reserved: estimated %d, unsigned %d", estimate.InfoSize, unsigned.InfoSize)
+	}
+
+	info, _, err := Pack(item, params, WithSigner(signer))
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+	if signer.calls != 1 {
+		t.Fatalf("Expected one call to the Signer during packing, got: %d", signer.calls)
+	}
+	if estimate.InfoSize > len(info) {
+		t.Fatalf("Unexpected info size: estimated %d, packed %d", estimate.InfoSize, len(info))
+	}
+}
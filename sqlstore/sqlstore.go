@@ -0,0 +1,277 @@
+// Package sqlstore stores packed items in a SQL database using database/sql, with the packed data of each
+// item held in one table and the chunks of each element in another.  The Dialect selects the placeholders
+// and column types of the database; Postgres, MySQL and SQLite are provided.
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gford1000-go/packer"
+)
+
+// Dialect describes the SQL differences between databases
+type Dialect struct {
+	// Placeholder returns the placeholder of the nth (from 1) parameter of a statement
+	Placeholder func(n int) string
+	// KeyType is the column type of item and element keys, and chunk names
+	KeyType string
+	// BinaryType is the column type of packed data and chunk values
+	BinaryType string
+}
+
+var (
+	// Postgres is the Dialect of PostgreSQL
+	Postgres = Dialect{
+		Placeholder: func(n int) string { return fmt.Sprintf("$%d", n) },
+		KeyType:     "VARCHAR(255)",
+		BinaryType:  "BYTEA",
+	}
+	// MySQL is the Dialect of MySQL and MariaDB
+	MySQL = Dialect{
+		Placeholder: func(int) string { return "?" },
+		KeyType:     "VARCHAR(255)",
+		BinaryType:  "LONGBLOB",
+	}
+	// SQLite is the Dialect of SQLite
+	SQLite = Dialect{
+		Placeholder: func(int) string { return "?" },
+		KeyType:     "TEXT",
+		BinaryType:  "BLOB",
+	}
+)
+
+// KeyEncoder returns the string form of the key of an item or element, as stored in the key columns
+type KeyEncoder[T comparable] func(key T) (string, error)
+
+// Options adjust the tables used, and how elements are loaded
+type Options struct {
+	// Name of the table holding packed data
+	infoTable string
+	// Name of the table holding chunks
+	chunkTable string
+	// Maximum number of keys in each IN clause
+	batchSize int
+}
+
+// WithTables sets the names of the tables holding packed data and chunks.  If not set,
+// "packer_info" and "packer_chunks" are used.
+func WithTables(infoTable, chunkTable string) func(*Options) {
+	return func(o *Options) {
+		o.infoTable = infoTable
+		o.chunkTable = chunkTable
+	}
+}
+
+// WithBatchSize sets the maximum number of element keys loaded by a single query.  If not set, 500 are used.
+func WithBatchSize(n int) func(*Options) {
+	return func(o *Options) {
+		o.batchSize = n
+	}
+}
+
+// ErrDBIsNil raised if New is called without a database
+var ErrDBIsNil = errors.New("database must not be nil")
+
+// ErrKeyEncoderIsNil raised if New is called without a KeyEncoder
+var ErrKeyEncoderIsNil = errors.New("key encoder must not be nil")
+
+// Store stores packed items in a SQL database, and implements packer.Store
+type Store[T comparable] struct {
+	db      *sql.DB
+	dialect Dialect
+	encode  KeyEncoder[T]
+	opts    Options
+}
+
+var _ packer.Store[string] = &Store[string]{}
+
+// New returns a Store using the database, whose tables are created by Migrate
+func New[T comparable](db *sql.DB, dialect Dialect, encode KeyEncoder[T], opts ...func(*Options)) (*Store[T], error) {
+	if db == nil {
+		return nil, ErrDBIsNil
+	}
+	if encode == nil {
+		return nil, ErrKeyEncoderIsNil
+	}
+
+	o := Options{infoTable: "packer_info", chunkTable: "packer_chunks", batchSize: 500}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	o.batchSize = max(o.batchSize, 1)
+
+	return &Store[T]{db: db, dialect: dialect, encode: encode, opts: o}, nil
+}
+
+// MigrationStatements returns the statements creating the tables of the Store, for use with other
+// schema migration tools.  Each statement may be run repeatedly.
+func (s *Store[T]) MigrationStatements() []string {
+	return []string{
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (item_key %s NOT NULL PRIMARY KEY, info %s NOT NULL)",
+			s.opts.infoTable, s.dialect.KeyType, s.dialect.BinaryType),
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (element_key %s NOT NULL, chunk_name %s NOT NULL, data %s NOT NULL, PRIMARY KEY (element_key, chunk_name))",
+			s.opts.chunkTable, s.dialect.KeyType, s.dialect.KeyType, s.dialect.BinaryType),
+	}
+}
+
+// Migrate creates the tables of the Store, if they do not already exist
+func (s *Store[T]) Migrate(ctx context.Context) error {
+	for _, stmt := range s.MigrationStatements() {
+		if _, err := s.db.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Load is a packer.DataLoader, returning the chunks of the elements with the keys
+func (s *Store[T]) Load(ctx context.Context, keys []T) (map[string][]byte, error) {
+
+	encoded := make([]any, 0, len(keys))
+	for _, k := range keys {
+		key, err := s.encode(k)
+		if err != nil {
+			return nil, err
+		}
+		encoded = append(encoded, key)
+	}
+
+	m := map[string][]byte{}
+	for start := 0; start < len(encoded); start += s.opts.batchSize {
+		batch := encoded[start:min(start+s.opts.batchSize, len(encoded))]
+		if err := s.loadBatch(ctx, batch, m); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// loadBatch adds the chunks of the elements with the encoded keys to m
+func (s *Store[T]) loadBatch(ctx context.Context, keys []any, m map[string][]byte) error {
+
+	placeholders := make([]string, len(keys))
+	for i := range keys {
+		placeholders[i] = s.dialect.Placeholder(i + 1)
+	}
+	query := fmt.Sprintf("SELECT chunk_name, data FROM %s WHERE element_key IN (%s)", s.opts.chunkTable, strings.Join(placeholders, ", "))
+
+	rows, err := s.db.QueryContext(ctx, query, keys...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		var data []byte
+		if err := rows.Scan(&name, &data); err != nil {
+			return err
+		}
+		m[name] = data
+	}
+	return rows.Err()
+}
+
+// Write stores the output of Pack in a single transaction: the elements, and the packed data of the item with the key
+func (s *Store[T]) Write(ctx context.Context, key T, info []byte, data map[T]map[string][]byte) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		if err := s.putAttrs(ctx, tx, data); err != nil {
+			return err
+		}
+		return s.putInfo(ctx, tx, key, info)
+	})
+}
+
+// PutInfo stores the packed data of the item with the key, replacing any existing packed data
+func (s *Store[T]) PutInfo(ctx context.Context, key T, info []byte) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error { return s.putInfo(ctx, tx, key, info) })
+}
+
+// PutAttrs stores the chunks of each element in a single transaction, replacing any existing chunks of the elements
+func (s *Store[T]) PutAttrs(ctx context.Context, data map[T]map[string][]byte) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error { return s.putAttrs(ctx, tx, data) })
+}
+
+// GetInfo returns the packed data of the item with the key, raising packer.ErrItemNotInStore if not present
+func (s *Store[T]) GetInfo(ctx context.Context, key T) ([]byte, error) {
+	k, err := s.encode(key)
+	if err != nil {
+		return nil, err
+	}
+
+	var info []byte
+	query := fmt.Sprintf("SELECT info FROM %s WHERE item_key = %s", s.opts.infoTable, s.dialect.Placeholder(1))
+	err = s.db.QueryRowContext(ctx, query, k).Scan(&info)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, packer.ErrItemNotInStore
+	}
+	return info, err
+}
+
+// BatchGetAttrs is equivalent to Load
+func (s *Store[T]) BatchGetAttrs(ctx context.Context, keys []T) (map[string][]byte, error) {
+	return s.Load(ctx, keys)
+}
+
+func (s *Store[T]) putInfo(ctx context.Context, tx *sql.Tx, key T, info []byte) error {
+	k, err := s.encode(key)
+	if err != nil {
+		return err
+	}
+
+	// Replacing by delete and insert avoids the differing upsert syntax of each database
+	p := s.dialect.Placeholder
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE item_key = %s", s.opts.infoTable, p(1)), k); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s (item_key, info) VALUES (%s, %s)", s.opts.infoTable, p(1), p(2)), k, info)
+	return err
+}
+
+func (s *Store[T]) putAttrs(ctx context.Context, tx *sql.Tx, data map[T]map[string][]byte) error {
+
+	p := s.dialect.Placeholder
+	del, err := tx.PrepareContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE element_key = %s", s.opts.chunkTable, p(1)))
+	if err != nil {
+		return err
+	}
+	defer del.Close()
+	ins, err := tx.PrepareContext(ctx, fmt.Sprintf("INSERT INTO %s (element_key, chunk_name, data) VALUES (%s, %s, %s)", s.opts.chunkTable, p(1), p(2), p(3)))
+	if err != nil {
+		return err
+	}
+	defer ins.Close()
+
+	for key, chunks := range data {
+		k, err := s.encode(key)
+		if err != nil {
+			return err
+		}
+		if _, err := del.ExecContext(ctx, k); err != nil {
+			return err
+		}
+		for name, v := range chunks {
+			if _, err := ins.ExecContext(ctx, k, name, v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// inTx calls f within a transaction, which is committed if f succeeds and rolled back otherwise
+func (s *Store[T]) inTx(ctx context.Context, f func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := f(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
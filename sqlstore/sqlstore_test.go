@@ -0,0 +1,241 @@
+package sqlstore
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gford1000-go/packer"
+	"github.com/gford1000-go/serialise"
+)
+
+// testDriver is an in-memory database/sql driver, understanding only the statements issued by Store
+type testDriver struct {
+	mu      sync.Mutex
+	tables  map[string][]map[string]driver.Value
+	queries []string
+}
+
+func (d *testDriver) Open(string) (driver.Conn, error) { return &testConn{d: d}, nil }
+
+type testConn struct {
+	d        *testDriver
+	snapshot map[string][]map[string]driver.Value
+}
+
+func (c *testConn) Prepare(query string) (driver.Stmt, error) {
+	return &testStmt{c: c, query: query}, nil
+}
+func (c *testConn) Close() error { return nil }
+func (c *testConn) Begin() (driver.Tx, error) {
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	c.snapshot = map[string][]map[string]driver.Value{}
+	for k, v := range c.d.tables {
+		c.snapshot[k] = slices.Clone(v)
+	}
+	return c, nil
+}
+func (c *testConn) Commit() error { return nil }
+func (c *testConn) Rollback() error {
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	c.d.tables = c.snapshot
+	return nil
+}
+
+type testStmt struct {
+	c     *testConn
+	query string
+}
+
+var (
+	reCreate = regexp.MustCompile(`^CREATE TABLE IF NOT EXISTS (\w+) `)
+	reDelete = regexp.MustCompile(`^DELETE FROM (\w+) WHERE (\w+) = `)
+	reInsert = regexp.MustCompile(`^INSERT INTO (\w+) \(([^)]*)\) VALUES`)
+	reSelect = regexp.MustCompile(`^SELECT (.+) FROM (\w+) WHERE (\w+) `)
+)
+
+func (s *testStmt) Close() error  { return nil }
+func (s *testStmt) NumInput() int { return -1 }
+
+func (s *testStmt) Exec(args []driver.Value) (driver.Result, error) {
+	d := s.c.d
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.queries = append(d.queries, s.query)
+
+	if m := reCreate.FindStringSubmatch(s.query); m != nil {
+		if _, ok := d.tables[m[1]]; !ok {
+			d.tables[m[1]] = nil
+		}
+		return driver.RowsAffected(0), nil
+	}
+	if m := reDelete.FindStringSubmatch(s.query); m != nil {
+		d.tables[m[1]] = slices.DeleteFunc(d.tables[m[1]], func(row map[string]driver.Value) bool { return row[m[2]] == args[0] })
+		return driver.RowsAffected(0), nil
+	}
+	if m := reInsert.FindStringSubmatch(s.query); m != nil {
+		if strings.Contains(m[1], "fail") {
+			return nil, errors.New("insert failed")
+		}
+		row := map[string]driver.Value{}
+		for i, col := range strings.Split(m[2], ", ") {
+			row[col] = args[i]
+		}
+		d.tables[m[1]] = append(d.tables[m[1]], row)
+		return driver.RowsAffected(1), nil
+	}
+	return nil, fmt.Errorf("unsupported statement: %s", s.query)
+}
+
+func (s *testStmt) Query(args []driver.Value) (driver.Rows, error) {
+	d := s.c.d
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.queries = append(d.queries, s.query)
+
+	m := reSelect.FindStringSubmatch(s.query)
+	if m == nil {
+		return nil, fmt.Errorf("unsupported query: %s", s.query)
+	}
+	cols := strings.Split(m[1], ", ")
+	rows := &testRows{cols: cols}
+	for _, row := range d.tables[m[2]] {
+		if slices.Contains(args, row[m[3]]) {
+			values := make([]driver.Value, len(cols))
+			for i, col := range cols {
+				values[i] = row[col]
+			}
+			rows.values = append(rows.values, values)
+		}
+	}
+	return rows, nil
+}
+
+type testRows struct {
+	cols   []string
+	values [][]driver.Value
+}
+
+func (r *testRows) Columns() []string { return r.cols }
+func (r *testRows) Close() error      { return nil }
+func (r *testRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+var testDB = &testDriver{tables: map[string][]map[string]driver.Value{}}
+
+func init() {
+	sql.Register("sqlstoretest", testDB)
+}
+
+func TestStore(t *testing.T) {
+
+	db, err := sql.Open("sqlstoretest", "")
+	if err != nil {
+		t.Fatalf("Unexpected error opening database: %v", err)
+	}
+	defer db.Close()
+
+	ki := &packer.EnvelopeKeyProviderInfo{ID: "Key1", Key: []byte("01234567890123456789012345678912")}
+	provider, err := packer.NewEnvelopeKeyProvider(ki, func(packer.EnvelopeKeyID) (packer.EnvelopeKeyProvider, error) { return nil, errors.New("unknown") })
+	if err != nil {
+		t.Fatalf("Unexpected error creating provider: %v", err)
+	}
+	serialiser, _ := packer.NewKeySerialiser()
+
+	store, err := New(db, Postgres, func(k packer.Key) (string, error) { return k.X + "/" + k.Y, nil }, WithBatchSize(3))
+	if err != nil {
+		t.Fatalf("Unexpected error creating store: %v", err)
+	}
+	if err := store.Migrate(context.TODO()); err != nil {
+		t.Fatalf("Unexpected error migrating: %v", err)
+	}
+	if _, ok := testDB.tables["packer_chunks"]; !ok {
+		t.Fatal("Expected the chunk table to be created")
+	}
+
+	item := &packer.Item[packer.Key]{Key: packer.Key{X: "A", Y: "B"}, Attributes: map[string]any{}}
+	for i := range 20 {
+		b := make([]byte, 4*1024)
+		if _, err := rand.Read(b); err != nil {
+			t.Fatalf("Unexpected error creating data: %v", err)
+		}
+		item.Attributes[fmt.Sprintf("attr%d", i)] = b
+	}
+
+	pParams := &packer.PackParams[packer.Key]{
+		Provider: provider,
+		Creator:  packer.NewKeyCreator(10),
+		Packer:   serialiser,
+		Approach: serialise.NewMinDataApproachWithVersion(serialise.V1),
+	}
+	if _, err := packer.PackInto(context.TODO(), store, item, pParams, packer.WithMaximumKBSize(16)); err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+
+	uParams := &packer.UnpackParams[packer.Key]{
+		IDRetriever: func(string) (packer.IDSerialiser[packer.Key], error) { return serialiser, nil },
+		Provider:    provider,
+	}
+	e, err := packer.UnpackFromStore(context.TODO(), store, item.Key, uParams)
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+	values, err := e.GetAllValues(context.TODO(), provider)
+	if err != nil {
+		t.Fatalf("Unexpected error getting values: %v", err)
+	}
+	if len(values) != len(item.Attributes) {
+		t.Fatalf("Expected %d values, got %d", len(item.Attributes), len(values))
+	}
+
+	// Elements are loaded in batches of at most three keys
+	for _, q := range testDB.queries {
+		if strings.HasPrefix(q, "SELECT chunk_name") && strings.Count(q, "$") > 3 {
+			t.Fatalf("Unexpected batch: %s", q)
+		}
+	}
+
+	if _, err := store.GetInfo(context.TODO(), packer.Key{X: "missing"}); !errors.Is(err, packer.ErrItemNotInStore) {
+		t.Fatalf("Expected ErrItemNotInStore, got: %v", err)
+	}
+}
+
+func TestStoreRollback(t *testing.T) {
+
+	db, err := sql.Open("sqlstoretest", "")
+	if err != nil {
+		t.Fatalf("Unexpected error opening database: %v", err)
+	}
+	defer db.Close()
+
+	store, _ := New(db, SQLite, func(k string) (string, error) { return k, nil }, WithTables("fail_info", "rollback_chunks"))
+	if err := store.Migrate(context.TODO()); err != nil {
+		t.Fatalf("Unexpected error migrating: %v", err)
+	}
+
+	// The packed data cannot be written, so the chunks written before it are rolled back
+	err = store.Write(context.TODO(), "a", []byte("info"), map[string]map[string][]byte{"a": {"x": []byte("1")}})
+	if err == nil {
+		t.Fatal("Expected an error writing packed data")
+	}
+	if m, err := store.Load(context.TODO(), []string{"a"}); err != nil || len(m) != 0 {
+		t.Fatalf("Expected no chunks after rollback: %v, %v", m, err)
+	}
+}
@@ -0,0 +1,70 @@
+package packer
+
+import (
+	"context"
+	"errors"
+)
+
+// Store persists the packed data of items and the attribute data of their elements, so that items can be packed
+// into, and unpacked from, storage using PackInto and UnpackFromStore
+type Store[T comparable] interface {
+	// PutInfo stores the packed data of the item with the key
+	PutInfo(ctx context.Context, key T, info []byte) error
+	// PutAttrs stores the attribute data of each element
+	PutAttrs(ctx context.Context, data map[T]map[string][]byte) error
+	// GetInfo returns the packed data of the item with the key, raising ErrItemNotInStore if it is not present
+	GetInfo(ctx context.Context, key T) ([]byte, error)
+	// BatchGetAttrs returns the stored attribute data of each requested element that is present, as a DataLoader
+	BatchGetAttrs(ctx context.Context, keys []T) (map[string][]byte, error)
+}
+
+// ErrStoreIsNil raised if PackInto or UnpackFromStore are called without a Store
+var ErrStoreIsNil = errors.New("store must not be nil")
+
+// ErrItemNotInStore raised by a Store if the packed data of the requested item is not present
+var ErrItemNotInStore = errors.New("item not found in store")
+
+// PackInto packs the item, storing the attribute data of its elements and then its packed data in the store,
+// so that the store never holds packed data whose elements are missing.  The packed data is also returned.
+func PackInto[T comparable](ctx context.Context, store Store[T], item *Item[T], params *PackParams[T], opts ...func(*Options)) ([]byte, error) {
+
+	if store == nil {
+		return nil, ErrStoreIsNil
+	}
+
+	info, data, err := PackWithContext(ctx, item, params, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := store.PutAttrs(ctx, data); err != nil {
+		return nil, err
+	}
+	if err := store.PutInfo(ctx, item.Key, info); err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+// UnpackFromStore unpacks the item with the key, loading its packed data and elements from the store.
+// The store is used in place of any DataLoader of the params.
+func UnpackFromStore[T comparable](ctx context.Context, store Store[T], key T, params *UnpackParams[T]) (*EncryptedItem[T], error) {
+
+	if store == nil {
+		return nil, ErrStoreIsNil
+	}
+	if params == nil {
+		return nil, ErrUnpackNoParams
+	}
+
+	info, err := store.GetInfo(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	p := *params
+	p.DataLoader = store.BatchGetAttrs
+
+	return Unpack(ctx, info, &p)
+}
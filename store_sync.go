@@ -0,0 +1,195 @@
+package packer
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"errors"
+	"maps"
+)
+
+// SyncStore is a store of packed items and their elements, which Sync can copy between
+type SyncStore[T comparable] interface {
+	// Packs calls f with the key and packed data of each item held in the store, stopping if f returns an error
+	Packs(ctx context.Context, f func(key T, info []byte) error) error
+	// LoadElements returns the stored attribute data of each requested element that is present in the store
+	LoadElements(ctx context.Context, keys []T) (map[T]map[string][]byte, error)
+	// PutPack stores the packed data of the item
+	PutPack(ctx context.Context, key T, info []byte) error
+	// PutElements stores the attribute data of each element
+	PutElements(ctx context.Context, data map[T]map[string][]byte) error
+}
+
+// SyncParams specifies the stores to be synchronised, and how packed data is to be read
+type SyncParams[T comparable] struct {
+	// Source is the store to copy from
+	Source SyncStore[T]
+	// Target is the store to copy to
+	Target SyncStore[T]
+	// Provider decrypts the envelope keys of source packs, so that their elements can be determined
+	Provider EnvelopeKeyProvider
+	// IDRetriever specifies how keys can be deserialised
+	IDRetriever GetIDSerialiser[T]
+	// TargetProvider, if not nil, rewraps the data encryption key of each copied pack for the target
+	// environment.  It must implement KeyWrapper.
+	TargetProvider EnvelopeKeyProvider
+}
+
+// SyncResult summarises the work performed by Sync
+type SyncResult struct {
+	// PacksCopied is the number of packs written to the target
+	PacksCopied int
+	// PacksUnchanged is the number of source packs already present in the target
+	PacksUnchanged int
+	// ElementsCopied is the number of elements written to the target
+	ElementsCopied int
+	// ElementsUnchanged is the number of elements of copied packs already present in the target
+	ElementsUnchanged int
+}
+
+// ErrSyncStoreIsNil raised if either store is not specified in the SyncParams
+var ErrSyncStoreIsNil = errors.New("source and target stores must be specified")
+
+// ErrProviderCannotWrap raised if the target provider does not implement KeyWrapper
+var ErrProviderCannotWrap = errors.New("provider cannot wrap existing keys - it must implement KeyWrapper")
+
+// Sync copies the packs of the source store that are missing from, or differ in, the target store,
+// together with only those of their elements whose data is not already present in the target.
+// Packs are treated as unchanged if their data is identical, or if both record the same digest
+// (see WithDigest), which allows packs that have been rewrapped for the target to be recognised.
+// Elements are written before the pack that refers to them, so that the target remains readable
+// if Sync is interrupted.
+func Sync[T comparable](ctx context.Context, params *SyncParams[T]) (*SyncResult, error) {
+
+	if params == nil || params.Source == nil || params.Target == nil {
+		return nil, ErrSyncStoreIsNil
+	}
+	if params.Provider == nil {
+		return nil, ErrProviderIsNil
+	}
+	if params.IDRetriever == nil {
+		return nil, ErrIDRetrieverIsNil
+	}
+
+	var wrapper KeyWrapper
+	if params.TargetProvider != nil {
+		var ok bool
+		if wrapper, ok = params.TargetProvider.(KeyWrapper); !ok {
+			return nil, ErrProviderCannotWrap
+		}
+	}
+
+	existing := map[T][]byte{}
+	err := params.Target.Packs(ctx, func(key T, info []byte) error {
+		existing[key] = info
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SyncResult{}
+
+	err = params.Source.Packs(ctx, func(key T, info []byte) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if target, ok := existing[key]; ok && samePack(info, target) {
+			result.PacksUnchanged++
+			return nil
+		}
+
+		env, err := openEnvelope(ctx, info, params.Provider, params.IDRetriever)
+		if err != nil {
+			return err
+		}
+
+		elements, err := env.storedElements()
+		if err != nil {
+			return err
+		}
+
+		if err := syncElements(ctx, params.Source, params.Target, elements, result); err != nil {
+			return err
+		}
+
+		if wrapper != nil {
+			info, err = env.rewrap(ctx, wrapper)
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := params.Target.PutPack(ctx, key, info); err != nil {
+			return err
+		}
+		result.PacksCopied++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// samePack returns true if the packed data is identical, or records the same digest
+func samePack(a, b []byte) bool {
+	if bytes.Equal(a, b) {
+		return true
+	}
+	da, err := PackDigest(a)
+	if err != nil {
+		return false
+	}
+	db, err := PackDigest(b)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(da, db)
+}
+
+// storedElements returns the keys of all elements written for the item, including any replicas
+func (env *envelopeV1[T]) storedElements() ([]T, error) {
+	elements, err := env.elementsByRole()
+	if err != nil {
+		return nil, err
+	}
+	return flattenElements(elements), nil
+}
+
+// syncElements copies the elements whose data differs between the source and target
+func syncElements[T comparable](ctx context.Context, source, target SyncStore[T], elements []T, result *SyncResult) error {
+
+	src, err := source.LoadElements(ctx, elements)
+	if err != nil {
+		return err
+	}
+	dst, err := target.LoadElements(ctx, elements)
+	if err != nil {
+		return err
+	}
+
+	missing := map[T]map[string][]byte{}
+	for _, t := range elements {
+		s, ok := src[t]
+		if !ok {
+			continue
+		}
+		if d, ok := dst[t]; ok && maps.EqualFunc(s, d, bytes.Equal) {
+			result.ElementsUnchanged++
+			continue
+		}
+		missing[t] = s
+	}
+
+	if len(missing) == 0 {
+		return nil
+	}
+	if err := target.PutElements(ctx, missing); err != nil {
+		return err
+	}
+	result.ElementsCopied += len(missing)
+	return nil
+}
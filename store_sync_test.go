@@ -0,0 +1,194 @@
+package packer
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"maps"
+	"sync"
+	"testing"
+
+	"github.com/gford1000-go/serialise"
+)
+
+// testSyncStore is an in-memory SyncStore
+type testSyncStore struct {
+	mu       sync.Mutex
+	packs    map[Key][]byte
+	elements map[Key]map[string][]byte
+	puts     int
+}
+
+func newTestSyncStore() *testSyncStore {
+	return &testSyncStore{packs: map[Key][]byte{}, elements: map[Key]map[string][]byte{}}
+}
+
+func (s *testSyncStore) Packs(ctx context.Context, f func(key Key, info []byte) error) error {
+	s.mu.Lock()
+	packs := maps.Clone(s.packs)
+	s.mu.Unlock()
+	for k, v := range packs {
+		if err := f(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *testSyncStore) LoadElements(ctx context.Context, keys []Key) (map[Key]map[string][]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m := map[Key]map[string][]byte{}
+	for _, k := range keys {
+		if v, ok := s.elements[k]; ok {
+			m[k] = v
+		}
+	}
+	return m, nil
+}
+
+func (s *testSyncStore) PutPack(ctx context.Context, key Key, info []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.packs[key] = info
+	return nil
+}
+
+func (s *testSyncStore) PutElements(ctx context.Context, data map[Key]map[string][]byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k, v := range data {
+		s.elements[k] = v
+		s.puts++
+	}
+	return nil
+}
+
+func (s *testSyncStore) loader(ctx context.Context, keys []Key) (map[string][]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m := map[string][]byte{}
+	for _, k := range keys {
+		for n, v := range s.elements[k] {
+			m[n] = v
+		}
+	}
+	return m, nil
+}
+
+func TestSync(t *testing.T) {
+
+	providers := map[EnvelopeKeyID]EnvelopeKeyProvider{}
+	finder := func(id EnvelopeKeyID) (EnvelopeKeyProvider, error) {
+		if p, ok := providers[id]; ok {
+			return p, nil
+		}
+		return nil, errors.New("unknown provider id")
+	}
+	newProvider := func(id EnvelopeKeyID) EnvelopeKeyProvider {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			t.Fatalf("Unexpected error creating key: %v", err)
+		}
+		p, err := NewEnvelopeKeyProvider(&EnvelopeKeyProviderInfo{ID: id, Key: key}, finder)
+		if err != nil {
+			t.Fatalf("Unexpected error creating provider: %v", err)
+		}
+		return p
+	}
+
+	// Providers are only known within their own environment
+	sourceProvider, targetProvider := newProvider("source"), newProvider("target")
+
+	serialiser, err := NewKeySerialiser()
+	if err != nil {
+		t.Fatalf("Unexpected error preparing Key serialiser: %v", err)
+	}
+	idRetriever := func(string) (IDSerialiser[Key], error) { return serialiser, nil }
+
+	source, target := newTestSyncStore(), newTestSyncStore()
+
+	pack := func(i int, v string) {
+		item := &Item[Key]{
+			Key:        Key{X: fmt.Sprintf("%d", i), Y: "B"},
+			Attributes: map[string]any{"aaa": v},
+		}
+		info, data, err := Pack(item, &PackParams[Key]{
+			Provider: sourceProvider,
+			Creator:  NewKeyCreator(defaultLen),
+			Packer:   serialiser,
+			Approach: serialise.NewMinDataApproachWithVersion(serialise.V1),
+		}, WithDigest([]byte("digest key")), WithReplication(2))
+		if err != nil {
+			t.Fatalf("Unexpected error packing: %v", err)
+		}
+		source.PutElements(context.TODO(), data)
+		source.PutPack(context.TODO(), item.Key, info)
+	}
+
+	for i := range 5 {
+		pack(i, "Hello")
+	}
+
+	params := &SyncParams[Key]{
+		Source:         source,
+		Target:         target,
+		Provider:       sourceProvider,
+		IDRetriever:    idRetriever,
+		TargetProvider: targetProvider,
+	}
+
+	r, err := Sync(context.TODO(), params)
+	if err != nil {
+		t.Fatalf("Unexpected error syncing: %v", err)
+	}
+	if r.PacksCopied != 5 || r.ElementsCopied != 10 || r.PacksUnchanged != 0 {
+		t.Fatalf("Unexpected result: %+v", r)
+	}
+
+	// Copied packs are readable using the target provider only
+	delete(providers, "source")
+	providers["target"] = targetProvider
+	for k, info := range target.packs {
+		e, err := Unpack(context.TODO(), info, &UnpackParams[Key]{
+			IDRetriever: idRetriever,
+			Provider:    targetProvider,
+			DataLoader:  target.loader,
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error unpacking %v: %v", k, err)
+		}
+		m, err := e.GetValues(context.TODO(), []string{"aaa"}, targetProvider)
+		if err != nil {
+			t.Fatalf("Unexpected error getting values: %v", err)
+		}
+		if m["aaa"] != "Hello" {
+			t.Fatalf("Unexpected value: %v", m["aaa"])
+		}
+	}
+	providers["source"] = sourceProvider
+
+	// Nothing is copied if nothing has changed
+	r, err = Sync(context.TODO(), params)
+	if err != nil {
+		t.Fatalf("Unexpected error syncing: %v", err)
+	}
+	if r.PacksCopied != 0 || r.ElementsCopied != 0 || r.PacksUnchanged != 5 {
+		t.Fatalf("Unexpected result: %+v", r)
+	}
+
+	// Only changed packs are copied
+	pack(2, "World")
+	r, err = Sync(context.TODO(), params)
+	if err != nil {
+		t.Fatalf("Unexpected error syncing: %v", err)
+	}
+	if r.PacksCopied != 1 || r.ElementsCopied != 2 || r.PacksUnchanged != 4 {
+		t.Fatalf("Unexpected result: %+v", r)
+	}
+
+	if _, err := Sync(context.TODO(), &SyncParams[Key]{Source: source}); !errors.Is(err, ErrSyncStoreIsNil) {
+		t.Fatalf("Unexpected error: expected: %v, got: %v", ErrSyncStoreIsNil, err)
+	}
+}
@@ -0,0 +1,85 @@
+package packer
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"testing"
+
+	"github.com/gford1000-go/serialise"
+)
+
+// The Store methods of testSyncStore allow it to be used with PackInto and UnpackFromStore
+
+func (s *testSyncStore) PutInfo(ctx context.Context, key Key, info []byte) error {
+	return s.PutPack(ctx, key, info)
+}
+
+func (s *testSyncStore) PutAttrs(ctx context.Context, data map[Key]map[string][]byte) error {
+	return s.PutElements(ctx, data)
+}
+
+func (s *testSyncStore) GetInfo(ctx context.Context, key Key) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	info, ok := s.packs[key]
+	if !ok {
+		return nil, ErrItemNotInStore
+	}
+	return info, nil
+}
+
+func (s *testSyncStore) BatchGetAttrs(ctx context.Context, keys []Key) (map[string][]byte, error) {
+	m, err := s.LoadElements(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	attrs := map[string][]byte{}
+	for _, v := range m {
+		maps.Copy(attrs, v)
+	}
+	return attrs, nil
+}
+
+func TestPackIntoStore(t *testing.T) {
+
+	_, _, provider := testCreateEnv(t)
+	serialiser, _ := NewKeySerialiser()
+
+	store := newTestSyncStore()
+
+	item := &Item[Key]{Key: Key{X: "A", Y: "B"}, Attributes: map[string]any{"a": "x", "b": int64(2)}}
+
+	pParams := &PackParams[Key]{
+		Provider: provider,
+		Creator:  NewKeyCreator(defaultLen),
+		Packer:   serialiser,
+		Approach: serialise.NewMinDataApproachWithVersion(serialise.V1),
+	}
+	if _, err := PackInto(context.TODO(), store, item, pParams); err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+
+	uParams := &UnpackParams[Key]{
+		IDRetriever: func(string) (IDSerialiser[Key], error) { return serialiser, nil },
+		Provider:    provider,
+	}
+	e, err := UnpackFromStore(context.TODO(), store, item.Key, uParams)
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+	values, err := e.GetValues(context.TODO(), []string{"a", "b"}, provider)
+	if err != nil {
+		t.Fatalf("Unexpected error getting values: %v", err)
+	}
+	if !maps.Equal(values, item.Attributes) {
+		t.Fatalf("Mismatch: expected %v, got %v", item.Attributes, values)
+	}
+
+	if _, err := UnpackFromStore(context.TODO(), store, Key{X: "missing"}, uParams); !errors.Is(err, ErrItemNotInStore) {
+		t.Fatalf("Expected ErrItemNotInStore, got: %v", err)
+	}
+	if _, err := PackInto[Key](context.TODO(), nil, item, pParams); !errors.Is(err, ErrStoreIsNil) {
+		t.Fatalf("Expected ErrStoreIsNil, got: %v", err)
+	}
+}
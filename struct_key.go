@@ -0,0 +1,109 @@
+package packer
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/gford1000-go/serialise"
+)
+
+// ErrStructSerialiserNameIsEmpty raised if NewStructSerialiser is called without a name
+var ErrStructSerialiserNameIsEmpty = errors.New("struct serialiser must be named")
+
+// ErrStructSerialiserUnsupportedType raised if NewStructSerialiser is called for a type that is not a struct
+// whose fields are all exported strings, integers or booleans
+var ErrStructSerialiserUnsupportedType = errors.New("struct serialiser requires a struct of exported string, integer or boolean fields")
+
+// ErrStructDeserialisationError is raised when data does not deserialise to an instance of the struct
+var ErrStructDeserialisationError = errors.New("invalid data passed - cannot deserialise struct instance")
+
+// NewStructSerialiser returns an IDSerialiser for flat struct keys, whose fields are all exported strings,
+// integers or booleans (including types defined from these), serialising the fields in declaration order.
+// The name identifies the serialiser when unpacking, so should be changed if the fields of the struct change,
+// as historic data would otherwise be unrecoverable.
+func NewStructSerialiser[T comparable](name string) (IDSerialiser[T], error) {
+
+	if len(name) == 0 {
+		return nil, ErrStructSerialiserNameIsEmpty
+	}
+
+	t := reflect.TypeFor[T]()
+	if t.Kind() != reflect.Struct || t.NumField() == 0 {
+		return nil, ErrStructSerialiserUnsupportedType
+	}
+
+	kinds := make([]reflect.Type, t.NumField())
+	for i := range t.NumField() {
+		f := t.Field(i)
+		base, ok := structFieldKinds[f.Type.Kind()]
+		if !f.IsExported() || !ok {
+			return nil, fmt.Errorf("%w: field %s", ErrStructSerialiserUnsupportedType, f.Name)
+		}
+		kinds[i] = base
+	}
+
+	return &structSerialiser[T]{
+		n:     name,
+		a:     serialise.NewMinDataApproachWithVersion(serialise.V1), // Don't change or historic data is unrecoverable
+		kinds: kinds,
+	}, nil
+}
+
+// structFieldKinds are the kinds of field supported by NewStructSerialiser, with the type each is serialised as
+var structFieldKinds = map[reflect.Kind]reflect.Type{
+	reflect.String: reflect.TypeFor[string](),
+	reflect.Bool:   reflect.TypeFor[bool](),
+	reflect.Int:    reflect.TypeFor[int64](),
+	reflect.Int8:   reflect.TypeFor[int8](),
+	reflect.Int16:  reflect.TypeFor[int16](),
+	reflect.Int32:  reflect.TypeFor[int32](),
+	reflect.Int64:  reflect.TypeFor[int64](),
+	reflect.Uint:   reflect.TypeFor[uint64](),
+	reflect.Uint8:  reflect.TypeFor[uint8](),
+	reflect.Uint16: reflect.TypeFor[uint16](),
+	reflect.Uint32: reflect.TypeFor[uint32](),
+	reflect.Uint64: reflect.TypeFor[uint64](),
+}
+
+type structSerialiser[T comparable] struct {
+	n     string
+	a     serialise.Approach
+	kinds []reflect.Type
+}
+
+func (s *structSerialiser[T]) Name() string {
+	return s.n
+}
+
+func (s *structSerialiser[T]) Pack(t T) ([]byte, error) {
+	v := reflect.ValueOf(t)
+	fields := make([]any, len(s.kinds))
+	for i, k := range s.kinds {
+		fields[i] = v.Field(i).Convert(k).Interface()
+	}
+	b, _, err := serialise.ToBytesMany(fields, serialise.WithSerialisationApproach(s.a))
+	return b, err
+}
+
+func (s *structSerialiser[T]) Unpack(data []byte) (T, error) {
+	var t T
+
+	fields, err := serialise.FromBytesMany(data, s.a)
+	if err != nil {
+		return t, err
+	}
+	if len(fields) != len(s.kinds) {
+		return t, ErrStructDeserialisationError
+	}
+
+	v := reflect.ValueOf(&t).Elem()
+	for i, k := range s.kinds {
+		f := reflect.ValueOf(fields[i])
+		if !f.IsValid() || f.Type() != k {
+			return t, ErrStructDeserialisationError
+		}
+		v.Field(i).Set(f.Convert(v.Field(i).Type()))
+	}
+	return t, nil
+}
@@ -0,0 +1,63 @@
+package packer
+
+import (
+	"errors"
+	"testing"
+)
+
+type testStatus string
+
+type testStructKey struct {
+	Tenant  string
+	Shard   uint16
+	Seq     int
+	Status  testStatus
+	Deleted bool
+}
+
+func TestNewStructSerialiser(t *testing.T) {
+
+	s, err := NewStructSerialiser[testStructKey]("TestStructKeyV1")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if s.Name() != "TestStructKeyV1" {
+		t.Fatalf("Unexpected name: %s", s.Name())
+	}
+
+	for _, k := range []testStructKey{
+		{},
+		{Tenant: "a", Shard: 7, Seq: -42, Status: "active", Deleted: true},
+	} {
+		b, err := s.Pack(k)
+		if err != nil {
+			t.Fatalf("Unexpected error packing: %v", err)
+		}
+		v, err := s.Unpack(b)
+		if err != nil || v != k {
+			t.Fatalf("Mismatch: expected %v, got %v, %v", k, v, err)
+		}
+	}
+
+	other, _ := NewKeySerialiser()
+	b, _ := other.Pack(Key{X: "A", Y: "B"})
+	if _, err := s.Unpack(b); err == nil {
+		t.Fatal("Expected an error unpacking data of another serialiser")
+	}
+}
+
+func TestNewStructSerialiserUnsupported(t *testing.T) {
+
+	if _, err := NewStructSerialiser[testStructKey](""); !errors.Is(err, ErrStructSerialiserNameIsEmpty) {
+		t.Fatalf("Expected ErrStructSerialiserNameIsEmpty, got: %v", err)
+	}
+	if _, err := NewStructSerialiser[string]("s"); !errors.Is(err, ErrStructSerialiserUnsupportedType) {
+		t.Fatalf("Expected ErrStructSerialiserUnsupportedType, got: %v", err)
+	}
+	if _, err := NewStructSerialiser[struct{ F float64 }]("s"); !errors.Is(err, ErrStructSerialiserUnsupportedType) {
+		t.Fatalf("Expected ErrStructSerialiserUnsupportedType, got: %v", err)
+	}
+	if _, err := NewStructSerialiser[struct{ f string }]("s"); !errors.Is(err, ErrStructSerialiserUnsupportedType) {
+		t.Fatalf("Expected ErrStructSerialiserUnsupportedType, got: %v", err)
+	}
+}
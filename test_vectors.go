@@ -0,0 +1,224 @@
+package packer
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	mrand "math/rand"
+	"reflect"
+	"slices"
+	"sync/atomic"
+
+	"github.com/gford1000-go/serialise"
+)
+
+// TestVector is a packed item together with the fixed key material and plaintext used to create it,
+// so that forks and implementations in other languages can check that they read the packed formats
+// in the same way as this package.
+type TestVector struct {
+	// Name describes the formats exercised by the TestVector
+	Name string
+	// Version is the PackVersion of the packed data
+	Version PackVersion
+	// WireFormat is the encoding of the V2 envelope, and is NativeWireFormat for V1
+	WireFormat WireFormat
+	// ProviderID is the EnvelopeKeyID of the provider that wrapped the data encryption key
+	ProviderID EnvelopeKeyID
+	// ProviderKey is the key encryption key of the provider, as used by NewEnvelopeKeyProvider
+	ProviderKey []byte
+	// Key identifies the item
+	Key Key
+	// Attributes are the plaintext values of the item
+	Attributes map[string]any
+	// Packed is the data returned by Pack
+	Packed []byte
+	// Elements are the elements returned by Pack
+	Elements []TestVectorElement
+}
+
+// TestVectorElement is a single chunk of an element created by Pack
+type TestVectorElement struct {
+	Key  Key
+	Name string
+	Data []byte
+}
+
+// testVectorFormats are the formats for which GenerateTestVectors creates a TestVector
+var testVectorFormats = []struct {
+	name    string
+	version PackVersion
+	wire    WireFormat
+	opts    []func(*Options)
+}{
+	{name: "v1", version: V1, wire: NativeWireFormat},
+	{name: "v1-flate", version: V1, wire: NativeWireFormat, opts: []func(*Options){WithCompression(FlateCompression)}},
+	{name: "v2", version: V2, wire: NativeWireFormat},
+	{name: "v2-proto", version: V2, wire: ProtoWireFormat},
+	{name: "v2-cbor", version: V2, wire: CBORWireFormat},
+}
+
+// GenerateTestVectors returns a TestVector for each supported PackVersion and WireFormat.  The item keys,
+// attribute names and values and the provider key are derived from the seed, so the same seed always
+// yields the same inputs.  Packed data and elements differ between calls, since every encryption uses a
+// random nonce; to detect changes in behaviour, keep the TestVectors of a release and check them with
+// VerifyTestVector, rather than comparing newly packed bytes.
+func GenerateTestVectors(seed int64) ([]TestVector, error) {
+
+	r := mrand.New(mrand.NewSource(seed))
+
+	providerKey := make([]byte, 32)
+	r.Read(providerKey)
+
+	vectors := make([]TestVector, 0, len(testVectorFormats))
+	for _, f := range testVectorFormats {
+
+		v := TestVector{
+			Name:        f.name,
+			Version:     f.version,
+			WireFormat:  f.wire,
+			ProviderID:  EnvelopeKeyID(fmt.Sprintf("vector-%d", seed)),
+			ProviderKey: bytes.Clone(providerKey),
+			Key:         Key{X: fmt.Sprintf("vector-%d", seed), Y: f.name},
+			Attributes:  testVectorAttributes(r),
+		}
+
+		provider, err := v.provider()
+		if err != nil {
+			return nil, err
+		}
+		serialiser, err := NewKeySerialiser()
+		if err != nil {
+			return nil, err
+		}
+
+		params := &PackParams[Key]{
+			Provider: provider,
+			Creator:  &testVectorCreator{key: v.Key},
+			Packer:   serialiser,
+			Approach: serialise.NewMinDataApproachWithVersion(serialise.V1),
+		}
+
+		opts := append([]func(*Options){
+			WithPackingVersion(f.version),
+			WithAttributeNamer(NewSequenceAttributeNamer("a")),
+		}, f.opts...)
+		if f.version == V2 {
+			opts = append(opts, WithWireFormat(f.wire))
+		}
+
+		packed, data, err := Pack(&Item[Key]{Key: v.Key, Attributes: v.Attributes}, params, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("test vector %s: %w", f.name, err)
+		}
+
+		v.Packed = packed
+		for _, key := range slices.SortedFunc(maps.Keys(data), func(a, b Key) int {
+			return cmp.Or(cmp.Compare(a.X, b.X), cmp.Compare(a.Y, b.Y))
+		}) {
+			for _, name := range slices.Sorted(maps.Keys(data[key])) {
+				v.Elements = append(v.Elements, TestVectorElement{Key: key, Name: name, Data: data[key][name]})
+			}
+		}
+		vectors = append(vectors, v)
+	}
+	return vectors, nil
+}
+
+// ErrTestVectorMismatch raised if a TestVector does not unpack to its plaintext inputs
+var ErrTestVectorMismatch = errors.New("test vector does not unpack to its attributes")
+
+// VerifyTestVector unpacks the TestVector and checks that its key, version, wire format and attribute
+// values are those from which it was created
+func VerifyTestVector(ctx context.Context, v TestVector) error {
+
+	provider, err := v.provider()
+	if err != nil {
+		return err
+	}
+	serialiser, err := NewKeySerialiser()
+	if err != nil {
+		return err
+	}
+
+	version, _, err := splitPackingVersion(v.Packed)
+	if err != nil {
+		return err
+	}
+	if version != v.Version {
+		return fmt.Errorf("%w: %s has version %d, expected %d", ErrTestVectorMismatch, v.Name, version, v.Version)
+	}
+
+	params := &UnpackParams[Key]{
+		DataLoader: func(ctx context.Context, keys []Key) (map[string][]byte, error) {
+			m := map[string][]byte{}
+			for _, e := range v.Elements {
+				if slices.Contains(keys, e.Key) {
+					m[e.Name] = e.Data
+				}
+			}
+			return m, nil
+		},
+		IDRetriever: func(string) (IDSerialiser[Key], error) { return serialiser, nil },
+		Provider:    provider,
+	}
+
+	e, err := Unpack(ctx, v.Packed, params)
+	if err != nil {
+		return fmt.Errorf("test vector %s: %w", v.Name, err)
+	}
+	if e.GetKey() != v.Key {
+		return fmt.Errorf("%w: %s has key %v, expected %v", ErrTestVectorMismatch, v.Name, e.GetKey(), v.Key)
+	}
+	if e.wire != v.WireFormat {
+		return fmt.Errorf("%w: %s has wire format %d, expected %d", ErrTestVectorMismatch, v.Name, e.wire, v.WireFormat)
+	}
+
+	values, err := e.GetValues(ctx, slices.Collect(maps.Keys(v.Attributes)), provider)
+	if err != nil {
+		return fmt.Errorf("test vector %s: %w", v.Name, err)
+	}
+	if !reflect.DeepEqual(values, v.Attributes) {
+		return fmt.Errorf("%w: %s", ErrTestVectorMismatch, v.Name)
+	}
+	return nil
+}
+
+// provider returns the EnvelopeKeyProvider of the fixed key of the TestVector
+func (v TestVector) provider() (EnvelopeKeyProvider, error) {
+	var provider EnvelopeKeyProvider
+	provider, err := NewEnvelopeKeyProvider(&EnvelopeKeyProviderInfo{ID: v.ProviderID, Key: v.ProviderKey},
+		func(id EnvelopeKeyID) (EnvelopeKeyProvider, error) {
+			if id != v.ProviderID {
+				return nil, fmt.Errorf("%w: unknown provider %s", ErrTestVectorMismatch, id)
+			}
+			return provider, nil
+		})
+	return provider, err
+}
+
+// testVectorAttributes returns attributes with a value of each of the commonly used types
+func testVectorAttributes(r *mrand.Rand) map[string]any {
+	b := make([]byte, 1+r.Intn(64))
+	r.Read(b)
+	return map[string]any{
+		"string":  fmt.Sprintf("value-%d", r.Int63()),
+		"int64":   r.Int63(),
+		"float64": r.Float64(),
+		"bool":    r.Intn(2) == 1,
+		"bytes":   b,
+		"strings": []string{fmt.Sprintf("s%d", r.Intn(1000)), fmt.Sprintf("s%d", r.Intn(1000))},
+	}
+}
+
+// testVectorCreator creates element keys as a sequence from the item key, so that they are reproducible
+type testVectorCreator struct {
+	key  Key
+	next atomic.Uint64
+}
+
+func (c *testVectorCreator) ID() Key {
+	return Key{X: c.key.X, Y: fmt.Sprintf("%s.%d", c.key.Y, c.next.Add(1))}
+}
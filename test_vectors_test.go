@@ -0,0 +1,60 @@
+package packer
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestGenerateTestVectors(t *testing.T) {
+
+	vectors, err := GenerateTestVectors(42)
+	if err != nil {
+		t.Fatalf("Unexpected error generating test vectors: %v", err)
+	}
+	if len(vectors) != len(testVectorFormats) {
+		t.Fatalf("Expected %d test vectors, got %d", len(testVectorFormats), len(vectors))
+	}
+
+	for _, v := range vectors {
+		if len(v.Packed) == 0 || len(v.Elements) == 0 {
+			t.Fatalf("Expected packed data and elements for %s", v.Name)
+		}
+		if err := VerifyTestVector(context.TODO(), v); err != nil {
+			t.Fatalf("Unexpected error verifying %s: %v", v.Name, err)
+		}
+	}
+
+	again, err := GenerateTestVectors(42)
+	if err != nil {
+		t.Fatalf("Unexpected error generating test vectors: %v", err)
+	}
+	for i, v := range again {
+		if v.Key != vectors[i].Key || !reflect.DeepEqual(v.ProviderKey, vectors[i].ProviderKey) || !reflect.DeepEqual(v.Attributes, vectors[i].Attributes) {
+			t.Fatalf("Expected the same inputs from the same seed for %s", v.Name)
+		}
+		for j, e := range v.Elements {
+			if e.Key != vectors[i].Elements[j].Key || e.Name != vectors[i].Elements[j].Name {
+				t.Fatalf("Expected the same element keys and names from the same seed for %s", v.Name)
+			}
+		}
+	}
+
+	other, _ := GenerateTestVectors(7)
+	if reflect.DeepEqual(other[0].Attributes, vectors[0].Attributes) {
+		t.Fatal("Expected different inputs from a different seed")
+	}
+
+	v := vectors[0]
+	v.Attributes = map[string]any{"string": "altered"}
+	if err := VerifyTestVector(context.TODO(), v); !errors.Is(err, ErrTestVectorMismatch) {
+		t.Fatalf("Expected ErrTestVectorMismatch, got: %v", err)
+	}
+
+	v = vectors[2]
+	v.WireFormat = ProtoWireFormat
+	if err := VerifyTestVector(context.TODO(), v); !errors.Is(err, ErrTestVectorMismatch) {
+		t.Fatalf("Expected ErrTestVectorMismatch for the wire format, got: %v", err)
+	}
+}
@@ -1,6 +1,8 @@
 package packer
 
 import (
+	cr "crypto/rand"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"math/rand"
@@ -16,14 +18,64 @@ type Key struct {
 
 var defaultLen uint8 = 16
 
-// NewKeyCreator returns an IDCreator for type Key
-func NewKeyCreator(size uint8) IDCreator[Key] {
+// KeyCreatorOptions adjust the identifiers created by NewKeyCreator
+type KeyCreatorOptions struct {
+	alphabet    string
+	randomBytes uint8
+	prefix      string
+}
+
+// WithKeyAlphabet sets the characters from which X and Y are created, so that keys conform to the
+// naming rules of the store.  If not set, letters and digits are used.
+func WithKeyAlphabet(alphabet string) func(*KeyCreatorOptions) {
+	if err := validateAlphabet(alphabet); err != nil {
+		panic(err)
+	}
+	return func(o *KeyCreatorOptions) {
+		o.alphabet = alphabet
+	}
+}
+
+// WithKeyRandomBytes creates X and Y from n random bytes, encoded using URL-safe base64 without padding,
+// in place of characters chosen from an alphabet.  The size passed to NewKeyCreator is then ignored.
+func WithKeyRandomBytes(n uint8) func(*KeyCreatorOptions) {
+	return func(o *KeyCreatorOptions) {
+		o.randomBytes = n
+	}
+}
+
+// WithKeyPrefix prefixes both X and Y with the prefix, which is not counted in the size of the key
+func WithKeyPrefix(prefix string) func(*KeyCreatorOptions) {
+	return func(o *KeyCreatorOptions) {
+		o.prefix = prefix
+	}
+}
 
-	g := func() string { return createString(size) }
+// NewKeyCreator returns an IDCreator for type Key, whose X and Y are random strings of the specified size
+func NewKeyCreator(size uint8, opts ...func(*KeyCreatorOptions)) IDCreator[Key] {
+
+	o := &KeyCreatorOptions{alphabet: defaultAttributeNameAlphabet}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	g := func() string { return o.prefix + createStringFromRange(o.alphabet, size) }
+	if o.randomBytes > 0 {
+		g = func() string { return o.prefix + createBase64String(o.randomBytes) }
+	}
 
 	return &keyGenerator{xg: g, yg: g}
 }
 
+// createBase64String returns n random bytes, encoded using URL-safe base64 without padding
+func createBase64String(n uint8) string {
+	b := make([]byte, n)
+	if _, err := cr.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
 // NewKeyCreatorFromKey leaves X unchanged, and adds a random suffix to Y
 func NewKeyCreatorFromKey(key Key, size uint8) IDCreator[Key] {
 
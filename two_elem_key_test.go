@@ -1,6 +1,10 @@
 package packer
 
-import "testing"
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
 
 func TestNewKeyForTesting(t *testing.T) {
 
@@ -64,3 +68,28 @@ func TestNewKeySerialiser(t *testing.T) {
 		t.Fatalf("Expected identifical keys, but differ: %v, %v", k, k1)
 	}
 }
+
+func TestNewKeyCreatorOptions(t *testing.T) {
+
+	k := NewKeyCreator(8, WithKeyAlphabet("abc"), WithKeyPrefix("p-")).ID()
+	for _, s := range []string{k.X, k.Y} {
+		if len(s) != 10 || !strings.HasPrefix(s, "p-") || strings.Trim(s[2:], "abc") != "" {
+			t.Fatalf("Unexpected key part: %q", s)
+		}
+	}
+
+	k = NewKeyCreator(8, WithKeyRandomBytes(24)).ID()
+	for _, s := range []string{k.X, k.Y} {
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil || len(b) != 24 {
+			t.Fatalf("Expected 24 random bytes encoded as URL-safe base64, got %q: %v", s, err)
+		}
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatal("Expected panic for an invalid alphabet")
+		}
+	}()
+	WithKeyAlphabet("a")
+}
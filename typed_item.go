@@ -0,0 +1,124 @@
+package packer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Codec converts attribute values of type V to and from values that can be serialised by Pack
+type Codec[V any] interface {
+	// Encode returns the value to be packed
+	Encode(v V) (any, error)
+	// Decode returns the original value from the value returned by GetValues
+	Decode(v any) (V, error)
+}
+
+// Item2 is a typed form of Item, where all attribute values share the type V, providing
+// compile-time safety for homogeneous datasets such as map[string]string property bags
+type Item2[T comparable, V any] struct {
+	// Key unique identifies this item
+	Key T
+	// Attributes represent the data values of this item
+	Attributes map[string]V
+}
+
+// ErrCodecIsNil raised if no Codec is provided to Pack2 or Unpack2
+var ErrCodecIsNil = errors.New("codec must not be nil")
+
+// Pack2 encodes each attribute value of the item using the codec, and then packs the item as Pack
+func Pack2[T comparable, V any](item *Item2[T, V], codec Codec[V], params *PackParams[T], opts ...func(*Options)) ([]byte, map[T]map[string][]byte, error) {
+
+	if item == nil || len(item.Attributes) == 0 {
+		return nil, nil, ErrPackNoAttributes
+	}
+	if codec == nil {
+		return nil, nil, ErrCodecIsNil
+	}
+
+	attrs := make(map[string]any, len(item.Attributes))
+	for k, v := range item.Attributes {
+		b, err := codec.Encode(v)
+		if err != nil {
+			return nil, nil, err
+		}
+		attrs[k] = b
+	}
+
+	return Pack(&Item[T]{Key: item.Key, Attributes: attrs}, params, opts...)
+}
+
+// EncryptedItem2 is the typed form of EncryptedItem returned by Unpack2
+type EncryptedItem2[T comparable, V any] struct {
+	item  *EncryptedItem[T]
+	codec Codec[V]
+}
+
+// Unpack2 unpacks the data as Unpack, with attribute values being decoded by the codec when retrieved
+func Unpack2[T comparable, V any](ctx context.Context, data []byte, codec Codec[V], params *UnpackParams[T]) (*EncryptedItem2[T, V], error) {
+
+	if codec == nil {
+		return nil, ErrCodecIsNil
+	}
+
+	item, err := Unpack(ctx, data, params)
+	if err != nil {
+		return nil, err
+	}
+
+	return &EncryptedItem2[T, V]{item: item, codec: codec}, nil
+}
+
+// GetKey returns the key of this EncryptedItem2
+func (e *EncryptedItem2[T, V]) GetKey() T {
+	return e.item.GetKey()
+}
+
+// Item returns the underlying EncryptedItem
+func (e *EncryptedItem2[T, V]) Item() *EncryptedItem[T] {
+	return e.item
+}
+
+// GetValues decrypts the requested attributes as EncryptedItem.GetValues, returning their decoded values
+func (e *EncryptedItem2[T, V]) GetValues(ctx context.Context, attrs []string, provider EnvelopeKeyProvider, opts ...func(*GetValuesOptions)) (map[string]V, error) {
+
+	m, err := e.item.GetValues(ctx, attrs, provider, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[string]V, len(m))
+	for k, v := range m {
+		d, err := e.codec.Decode(v)
+		if err != nil {
+			return nil, err
+		}
+		out[k] = d
+	}
+
+	return out, nil
+}
+
+// ErrCodecUnexpectedType raised if a value cannot be decoded to the type of the Codec
+var ErrCodecUnexpectedType = errors.New("value does not have the type expected by the codec")
+
+// NewDirectCodec returns a Codec that packs values unchanged, for types that are serialised natively
+// (such as string, int64, float64, bool and []byte)
+func NewDirectCodec[V any]() Codec[V] {
+	return directCodec[V]{}
+}
+
+type directCodec[V any] struct{}
+
+func (directCodec[V]) Encode(v V) (any, error) {
+	return v, nil
+}
+
+func (directCodec[V]) Decode(v any) (V, error) {
+	d, ok := v.(V)
+	if !ok {
+		var zero V
+		return zero, fmt.Errorf("%w: %T", ErrCodecUnexpectedType, v)
+	}
+	return d, nil
+}
@@ -0,0 +1,121 @@
+package packer
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/gford1000-go/serialise"
+)
+
+// intCodec packs int values as strings, to test Codecs that transform values
+type intCodec struct{}
+
+func (intCodec) Encode(v int) (any, error) {
+	return strconv.Itoa(v), nil
+}
+
+func (intCodec) Decode(v any) (int, error) {
+	s, ok := v.(string)
+	if !ok {
+		return 0, ErrCodecUnexpectedType
+	}
+	return strconv.Atoi(s)
+}
+
+func TestPack2(t *testing.T) {
+
+	_, _, provider := testCreateEnv(t)
+
+	serialiser, err := NewKeySerialiser()
+	if err != nil {
+		t.Fatalf("Unexpected error preparing Key serialiser: %v", err)
+	}
+
+	pParams := &PackParams[Key]{
+		Provider: provider,
+		Creator:  NewKeyCreator(defaultLen),
+		Packer:   serialiser,
+		Approach: serialise.NewMinDataApproachWithVersion(serialise.V1),
+	}
+
+	unpackParams := func(data map[Key]map[string][]byte) *UnpackParams[Key] {
+		return &UnpackParams[Key]{
+			IDRetriever: func(string) (IDSerialiser[Key], error) { return serialiser, nil },
+			Provider:    provider,
+			DataLoader: func(ctx context.Context, keys []Key) (map[string][]byte, error) {
+				m := map[string][]byte{}
+				for _, key := range keys {
+					for k, v := range data[key] {
+						m[k] = v
+					}
+				}
+				return m, nil
+			},
+		}
+	}
+
+	// Property bag of strings
+	bag := &Item2[Key, string]{
+		Key:        Key{X: "A", Y: "B"},
+		Attributes: map[string]string{"colour": "red", "size": "large"},
+	}
+
+	info, data, err := Pack2(bag, NewDirectCodec[string](), pParams)
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+
+	e, err := Unpack2(context.TODO(), info, NewDirectCodec[string](), unpackParams(data))
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+	if e.GetKey() != bag.Key {
+		t.Fatalf("Unexpected key: %v", e.GetKey())
+	}
+
+	m, err := e.GetValues(context.TODO(), []string{"colour", "size"}, provider)
+	if err != nil {
+		t.Fatalf("Unexpected error getting values: %v", err)
+	}
+	if len(m) != 2 || m["colour"] != "red" || m["size"] != "large" {
+		t.Fatalf("Unexpected values: %v", m)
+	}
+
+	// A mismatched codec is reported
+	e2, err := Unpack2(context.TODO(), info, NewDirectCodec[int64](), unpackParams(data))
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+	if _, err := e2.GetValues(context.TODO(), []string{"colour"}, provider); !errors.Is(err, ErrCodecUnexpectedType) {
+		t.Fatalf("Unexpected error: expected: %v, got: %v", ErrCodecUnexpectedType, err)
+	}
+
+	// Transforming codec
+	counts := &Item2[Key, int]{
+		Key:        Key{X: "C", Y: "D"},
+		Attributes: map[string]int{"a": 1, "b": -2},
+	}
+
+	info, data, err = Pack2[Key, int](counts, intCodec{}, pParams)
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+
+	e3, err := Unpack2[Key, int](context.TODO(), info, intCodec{}, unpackParams(data))
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+	m3, err := e3.GetValues(context.TODO(), []string{"a", "b"}, provider)
+	if err != nil {
+		t.Fatalf("Unexpected error getting values: %v", err)
+	}
+	if m3["a"] != 1 || m3["b"] != -2 {
+		t.Fatalf("Unexpected values: %v", m3)
+	}
+
+	if _, _, err := Pack2[Key, int](counts, nil, pParams); !errors.Is(err, ErrCodecIsNil) {
+		t.Fatalf("Unexpected error: expected: %v, got: %v", ErrCodecIsNil, err)
+	}
+}
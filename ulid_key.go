@@ -0,0 +1,138 @@
+package packer
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ULID is a 128 bit identifier comprising a 48 bit millisecond timestamp followed by 80 random bits,
+// so that identifiers sort in the order they were created, both as bytes and in their string form
+type ULID [16]byte
+
+// crockfordAlphabet is the Crockford base32 alphabet used by the string form of ULIDs
+const crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// String returns the 26 character Crockford base32 form of the ULID
+func (u ULID) String() string {
+	var b [26]byte
+	// The 128 bits are encoded as 130, with two leading zero bits
+	hi := binary.BigEndian.Uint64(u[0:8])
+	lo := binary.BigEndian.Uint64(u[8:16])
+	for i := 25; i >= 0; i-- {
+		b[i] = crockfordAlphabet[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(b[:])
+}
+
+// Time returns the time at which the ULID was created, to the millisecond
+func (u ULID) Time() time.Time {
+	var b [8]byte
+	copy(b[2:], u[0:6])
+	return time.UnixMilli(int64(binary.BigEndian.Uint64(b[:])))
+}
+
+// ErrInvalidULID raised if a string or byte slice is not a valid ULID
+var ErrInvalidULID = errors.New("invalid ULID")
+
+// ParseULID returns the ULID from its string form, ignoring case
+func ParseULID(s string) (ULID, error) {
+	if len(s) != 26 {
+		return ULID{}, ErrInvalidULID
+	}
+	var hi, lo uint64
+	for i := 0; i < len(s); i++ {
+		v := crockfordValue(s[i])
+		if v < 0 || (i == 0 && v > 7) {
+			return ULID{}, ErrInvalidULID
+		}
+		hi = hi<<5 | lo>>59
+		lo = lo<<5 | uint64(v)
+	}
+	var u ULID
+	binary.BigEndian.PutUint64(u[0:8], hi)
+	binary.BigEndian.PutUint64(u[8:16], lo)
+	return u, nil
+}
+
+// crockfordValue returns the value of the base32 character, or -1 if it is not in the alphabet
+func crockfordValue(c byte) int {
+	if c >= 'a' && c <= 'z' {
+		c -= 'a' - 'A'
+	}
+	for i := 0; i < len(crockfordAlphabet); i++ {
+		if crockfordAlphabet[i] == c {
+			return i
+		}
+	}
+	return -1
+}
+
+// NewULIDCreator returns an IDCreator for type ULID.  ULIDs created within the same millisecond increment
+// the random bits of the previous ULID, so that every ULID from the IDCreator sorts after those before it.
+// As the elements created during Pack then share the timestamp of the item's key, their keys cluster near
+// the item in stores partitioned by key range.
+func NewULIDCreator() IDCreator[ULID] {
+	return &ulidCreator{now: time.Now}
+}
+
+type ulidCreator struct {
+	mu   sync.Mutex
+	now  func() time.Time
+	last ULID
+}
+
+// ID returns a ULID that sorts after all ULIDs previously returned
+func (c *ulidCreator) ID() ULID {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var ms [8]byte
+	binary.BigEndian.PutUint64(ms[:], uint64(c.now().UnixMilli()))
+
+	var u ULID
+	copy(u[0:6], ms[2:])
+
+	if u.Time().After(c.last.Time()) {
+		if _, err := rand.Read(u[6:]); err != nil {
+			panic(err)
+		}
+	} else {
+		// Increment the random bits of the last ULID, carrying into the timestamp if exhausted
+		u = c.last
+		for i := len(u) - 1; i >= 0; i-- {
+			u[i]++
+			if u[i] != 0 {
+				break
+			}
+		}
+	}
+	c.last = u
+	return u
+}
+
+// NewULIDSerialiser returns an IDSerialiser for type ULID, serialising as the 16 bytes of the ULID
+func NewULIDSerialiser() (IDSerialiser[ULID], error) {
+	return ulidSerialiser{}, nil
+}
+
+type ulidSerialiser struct{}
+
+func (ulidSerialiser) Name() string {
+	return "ULIDV1"
+}
+
+func (ulidSerialiser) Pack(u ULID) ([]byte, error) {
+	return u[:], nil
+}
+
+func (ulidSerialiser) Unpack(data []byte) (ULID, error) {
+	if len(data) != len(ULID{}) {
+		return ULID{}, ErrInvalidULID
+	}
+	return ULID(data), nil
+}
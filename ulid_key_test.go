@@ -0,0 +1,77 @@
+package packer
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewULIDCreator(t *testing.T) {
+
+	c := NewULIDCreator()
+	start := time.Now().Truncate(time.Millisecond)
+
+	prev := c.ID()
+	for range 10000 {
+		u := c.ID()
+		if bytes.Compare(prev[:], u[:]) >= 0 || prev.String() >= u.String() {
+			t.Fatalf("Expected %s to sort after %s", u, prev)
+		}
+		prev = u
+	}
+	if prev.Time().Before(start) || prev.Time().After(time.Now()) {
+		t.Fatalf("Unexpected time: %v", prev.Time())
+	}
+
+	p, err := ParseULID(strings.ToLower(prev.String()))
+	if err != nil || p != prev {
+		t.Fatalf("Mismatch parsing %s: got %s, %v", prev, p, err)
+	}
+
+	for _, s := range []string{"", "01ARZ3NDEKTSV4RRFFQ69G5FA", "81ARZ3NDEKTSV4RRFFQ69G5FAV", "01ARZ3NDEKTSV4RRFFQ69G5FAU"} {
+		if _, err := ParseULID(s); !errors.Is(err, ErrInvalidULID) {
+			t.Fatalf("Expected ErrInvalidULID for %q, got: %v", s, err)
+		}
+	}
+}
+
+func TestULIDCreatorSameMillisecond(t *testing.T) {
+
+	now := time.UnixMilli(1469918176385)
+	c := &ulidCreator{now: func() time.Time { return now }}
+
+	a := c.ID()
+	a[14], a[15] = 0x10, 0xff
+	c.last = a
+
+	b := c.ID()
+	if b[15] != 0 || b[14] != 0x11 || b.Time() != a.Time() {
+		t.Fatalf("Expected the random bits to be incremented: %x, %x", a, b)
+	}
+	if !strings.HasPrefix(b.String(), "01ARYZ6S41") {
+		t.Fatalf("Unexpected timestamp encoding: %s", b)
+	}
+}
+
+func TestNewULIDSerialiser(t *testing.T) {
+
+	s, err := NewULIDSerialiser()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	u := NewULIDCreator().ID()
+	b, err := s.Pack(u)
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+	v, err := s.Unpack(b)
+	if err != nil || v != u {
+		t.Fatalf("Mismatch: expected %s, got %s, %v", u, v, err)
+	}
+	if _, err := s.Unpack(nil); !errors.Is(err, ErrInvalidULID) {
+		t.Fatalf("Expected ErrInvalidULID, got: %v", err)
+	}
+}
@@ -0,0 +1,213 @@
+package packer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"time"
+)
+
+// UnpackMany unpacks each of the packed items, as Unpack, but loads the elements of all the items with a
+// single call to the DataLoader of the params, avoiding a round trip to the store for each item.  Items are
+// returned in the same order as the data.  As the DataLoader combines the chunks of all the elements it
+// loads, and chunk names are only unique within an item, any item with a chunk name also used by another
+// item of the batch is unpacked by itself (as happens with the same AttributeNamer deriving names for each item).  The Progress, Stats and Checkpoint of the params are ignored.
+// If any item cannot be unpacked, an error identifying the item is returned.
+func UnpackMany[T comparable](ctx context.Context, data [][]byte, params *UnpackParams[T]) ([]*EncryptedItem[T], error) {
+
+	if params == nil {
+		return nil, ErrUnpackNoParams
+	}
+	if err := params.validate(); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	metrics := metricsOrDefault(params.Metrics)
+	provider := &measuredProvider{EnvelopeKeyProvider: params.Provider, metrics: metrics}
+	loader := loggedDataLoader(loggerOrDefault(params.Logger), params.DataLoader)
+	ctx = params.withEncryptionContext(ctx)
+
+	details := make([]*itemPackingDetailsV1[T], len(data))
+	envs := make([]*envelopeV1[T], len(data))
+	names := make([]map[string]bool, len(data))
+	continuations := make([][]T, len(data))
+
+	err := runConcurrently(len(data), runtime.GOMAXPROCS(0), func(i int) error {
+		err := recoverError(func() error {
+			if len(data[i]) == 0 {
+				return ErrUnpackNoData
+			}
+			var err error
+			if continuations[i], err = continuationKeys(data[i], params.IDRetriever); err != nil {
+				return err
+			}
+			b, err := JoinEnvelope(ctx, data[i], loader, params.IDRetriever)
+			if err != nil {
+				return err
+			}
+			packingVersion, b, err := splitPackingVersion(b)
+			if err != nil {
+				return err
+			}
+			if !packingVersion.supported() {
+				return ErrUnsupportedPackVersion
+			}
+			details[i] = &itemPackingDetailsV1[T]{
+				version:       packingVersion,
+				maxAttributes: params.MaxAttributes,
+				memory:        newMemoryTracker(params.MemoryLimit),
+				allocator:     allocatorOrDefault(params.Allocator),
+				requireMAC:    params.RequireEnvelopeMAC,
+				verifiers:     params.Verifiers,
+			}
+			envs[i], err = details[i].openEnvelope(ctx, b, provider, params.IDRetriever)
+			if err != nil {
+				return err
+			}
+			names[i], err = envelopeChunkNames(details[i], envs[i])
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("item %d: %w", i, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Chunk names are only unique within an item, so items sharing a name with another item are unpacked by themselves
+	owners := map[string]int{}
+	for i, m := range names {
+		if envs[i].isInline() {
+			continue
+		}
+		for name := range m {
+			owners[name]++
+		}
+	}
+	shared := make([]bool, len(data))
+	for i, m := range names {
+		for name := range m {
+			if owners[name] > 1 {
+				shared[i] = true
+				break
+			}
+		}
+	}
+
+	requested := map[T]bool{}
+	keys := []T{}
+	for i, env := range envs {
+		if shared[i] || env.isInline() {
+			continue
+		}
+		for _, t := range env.elements {
+			if !requested[t] {
+				requested[t] = true
+				keys = append(keys, t)
+			}
+		}
+	}
+
+	combined := map[string][]byte{}
+	if len(keys) > 0 {
+		loggerOrDefault(params.Logger).DebugContext(ctx, "packer: loading elements", slog.Int("elements", len(keys)), slog.Int("items", len(data)))
+		combined, err = loader(ctx, keys)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	items := make([]*EncryptedItem[T], len(data))
+
+	err = runConcurrently(len(data), runtime.GOMAXPROCS(0), func(i int) error {
+
+		if shared[i] {
+			item, err := Unpack(ctx, data[i], params)
+			if err != nil {
+				return fmt.Errorf("item %d: %w", i, err)
+			}
+			items[i] = item
+			return nil
+		}
+
+		// Each item receives only its own chunks, with any other elements (such as replicas) loaded on demand
+		itemLoader := func(ctx context.Context, keys []T) (map[string][]byte, error) {
+			m := map[string][]byte{}
+			missing := []T{}
+			for _, t := range keys {
+				if !requested[t] {
+					missing = append(missing, t)
+				}
+			}
+			for name := range names[i] {
+				if v, ok := combined[name]; ok {
+					m[name] = v
+				}
+			}
+			if len(missing) > 0 {
+				extra, err := loader(ctx, missing)
+				if err != nil {
+					return nil, err
+				}
+				for k, v := range extra {
+					m[k] = v
+				}
+			}
+			return m, nil
+		}
+
+		err := recoverError(func() error {
+			item, err := details[i].unpackEnvelope(ctx, envs[i], itemLoader)
+			if err != nil {
+				return err
+			}
+			item.elements = append(item.elements, continuations[i]...)
+			params.apply(item, metrics)
+			items[i] = item
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("item %d: %w", i, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	metrics.Add(MetricUnpacks, float64(len(data)))
+	metrics.Observe(MetricUnpackDuration, time.Since(start).Seconds())
+
+	return items, nil
+}
+
+// envelopeChunkNames returns the names of the chunks held by the elements of the envelope
+func envelopeChunkNames[T comparable](d *itemPackingDetailsV1[T], env *envelopeV1[T]) (map[string]bool, error) {
+
+	attrMap, err := d.unpackAttrMap(env.bAttrMap, env.approach, env.ext)
+	if err != nil {
+		return nil, err
+	}
+
+	names := map[string]bool{}
+	for _, chunks := range attrMap {
+		for _, name := range chunks {
+			names[name] = true
+		}
+	}
+
+	layout, err := env.ext.erasure(env.approach)
+	if err != nil {
+		return nil, err
+	}
+	if layout != nil {
+		for _, name := range layout.parity {
+			names[name] = true
+		}
+	}
+	return names, nil
+}
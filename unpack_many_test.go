@@ -0,0 +1,102 @@
+package packer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gford1000-go/serialise"
+)
+
+func TestUnpackMany(t *testing.T) {
+
+	_, _, provider := testCreateEnv(t)
+
+	serialiser, err := NewKeySerialiser()
+	if err != nil {
+		t.Fatalf("Unexpected error preparing Key serialiser: %v", err)
+	}
+
+	pParams := &PackParams[Key]{
+		Provider: provider,
+		Creator:  NewKeyCreator(defaultLen),
+		Packer:   serialiser,
+		Approach: serialise.NewMinDataApproachWithVersion(serialise.V1),
+	}
+
+	stored := map[Key]map[string][]byte{}
+	var calls atomic.Int32
+	loader := func(ctx context.Context, keys []Key) (map[string][]byte, error) {
+		calls.Add(1)
+		m := map[string][]byte{}
+		for _, k := range keys {
+			for name, v := range stored[k] {
+				m[name] = v
+			}
+		}
+		return m, nil
+	}
+
+	pack := func(opts func() []func(*Options)) [][]byte {
+		data := [][]byte{}
+		for i := range 10 {
+			info, elements, err := Pack(&Item[Key]{
+				Key:        Key{X: fmt.Sprintf("%d", i), Y: "B"},
+				Attributes: map[string]any{"aaa": fmt.Sprintf("Value %d", i), "bbb": int64(i)},
+			}, pParams, opts()...)
+			if err != nil {
+				t.Fatalf("(%d) Unexpected error packing: %v", i, err)
+			}
+			for k, v := range elements {
+				stored[k] = v
+			}
+			data = append(data, info)
+		}
+		return data
+	}
+	noOpts := func() []func(*Options) { return nil }
+
+	params := &UnpackParams[Key]{
+		DataLoader:  loader,
+		IDRetriever: func(string) (IDSerialiser[Key], error) { return serialiser, nil },
+		Provider:    provider,
+	}
+
+	check := func(data [][]byte, expectedCalls int32) {
+		calls.Store(0)
+		items, err := UnpackMany(context.TODO(), data, params)
+		if err != nil {
+			t.Fatalf("Unexpected error unpacking: %v", err)
+		}
+		if calls.Load() != expectedCalls {
+			t.Fatalf("Expected %d DataLoader calls, got %d", expectedCalls, calls.Load())
+		}
+		for i, item := range items {
+			values, err := item.GetValues(context.TODO(), []string{"aaa", "bbb"}, provider)
+			if err != nil {
+				t.Fatalf("(%d) Unexpected error getting values: %v", i, err)
+			}
+			if values["aaa"] != fmt.Sprintf("Value %d", i) || values["bbb"] != int64(i) {
+				t.Fatalf("(%d) Unexpected values: %v", i, values)
+			}
+		}
+	}
+
+	check(pack(noOpts), 1)
+
+	// Every item names its chunks the same, so each is unpacked by itself
+	check(pack(func() []func(*Options) {
+		return []func(*Options){WithAttributeNamer(NewSequenceAttributeNamer("c"))}
+	}), 10)
+
+	data := pack(noOpts)
+	data[3] = []byte("not an envelope")
+	if _, err := UnpackMany(context.TODO(), data, params); err == nil {
+		t.Fatal("Expected error for invalid data")
+	}
+	if _, err := UnpackMany[Key](context.TODO(), data, nil); !errors.Is(err, ErrUnpackNoParams) {
+		t.Fatalf("Expected ErrUnpackNoParams, got: %v", err)
+	}
+}
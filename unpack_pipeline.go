@@ -0,0 +1,299 @@
+package packer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"time"
+)
+
+// BatchDataLoader retrieves the data stored against each of the specified keys, keeping the
+// attributes of each element separate, so that the elements of many items can be loaded at once
+type BatchDataLoader[T comparable] func(ctx context.Context, keys []T) (map[T]map[string][]byte, error)
+
+// UnpackResult is the outcome of unpacking a single envelope with UnpackPipeline
+type UnpackResult[T comparable] struct {
+	// Data is the envelope, as returned by Pack
+	Data []byte
+	// Item is the unpacked item, if Err is nil
+	Item *EncryptedItem[T]
+	// Err is the error encountered unpacking the envelope, if any
+	Err error
+}
+
+// UnpackPipelineOptions adjust the behaviour of UnpackPipeline
+type UnpackPipelineOptions struct {
+	// Maximum number of envelopes whose elements are loaded together
+	batchSize int
+	// Maximum number of envelopes unpacked concurrently within a batch
+	concurrency int
+}
+
+// WithUnpackBatchSize sets the maximum number of envelopes whose elements are loaded with a single call
+// to the BatchDataLoader.  If not set, batches of up to 25 envelopes are used.
+func WithUnpackBatchSize(n int) func(*UnpackPipelineOptions) {
+	return func(o *UnpackPipelineOptions) {
+		o.batchSize = n
+	}
+}
+
+// WithUnpackConcurrency limits the number of envelopes unpacked concurrently by UnpackPipeline.
+// If not set, GOMAXPROCS is used.
+func WithUnpackConcurrency(n int) func(*UnpackPipelineOptions) {
+	return func(o *UnpackPipelineOptions) {
+		o.concurrency = n
+	}
+}
+
+const defaultUnpackBatchSize = 25
+
+// ErrBatchDataLoaderIsNil raised if no BatchDataLoader is provided to UnpackPipeline
+var ErrBatchDataLoaderIsNil = errors.New("batch data loader must not be nil, to allow attribute values to be retrieved")
+
+// UnpackPipeline unpacks each envelope received from the channel, emitting the EncryptedItems on the returned
+// channel as they are ready.  Envelopes that are immediately available are gathered into batches (see
+// WithUnpackBatchSize), the elements of each batch are loaded with a single call to the loader, and the
+// envelopes of a batch are unpacked concurrently (see WithUnpackConcurrency).  The returned channel is
+// unbuffered, so the rate at which results are received limits the work in progress.
+// The params are applied as for Unpack, except that the DataLoader, Progress and Stats are ignored.
+// The channel is closed once the envelopes channel is closed and all results have been emitted, or the
+// context is cancelled.
+func UnpackPipeline[T comparable](ctx context.Context, envelopes <-chan []byte, params *UnpackParams[T], loader BatchDataLoader[T], opts ...func(*UnpackPipelineOptions)) (<-chan UnpackResult[T], error) {
+
+	if params == nil {
+		return nil, ErrUnpackNoParams
+	}
+	if params.IDRetriever == nil {
+		return nil, ErrIDRetrieverIsNil
+	}
+	if params.Provider == nil {
+		return nil, ErrProviderIsNil
+	}
+	if loader == nil {
+		return nil, ErrBatchDataLoaderIsNil
+	}
+
+	o := &UnpackPipelineOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.batchSize < 1 {
+		o.batchSize = defaultUnpackBatchSize
+	}
+	if o.concurrency < 1 {
+		o.concurrency = runtime.GOMAXPROCS(0)
+	}
+
+	batches := make(chan [][]byte, 1)
+	results := make(chan UnpackResult[T])
+
+	// Gather the envelopes that are available into batches, whilst the previous batch is unpacked
+	go func() {
+		defer close(batches)
+
+		for {
+			var batch [][]byte
+			select {
+			case <-ctx.Done():
+				return
+			case data, ok := <-envelopes:
+				if !ok {
+					return
+				}
+				batch = append(batch, data)
+			}
+
+		gather:
+			for len(batch) < o.batchSize {
+				select {
+				case data, ok := <-envelopes:
+					if !ok {
+						break gather
+					}
+					batch = append(batch, data)
+				default:
+					break gather
+				}
+			}
+
+			select {
+			case batches <- batch:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	go func() {
+		defer close(results)
+
+		for batch := range batches {
+			for _, r := range unpackBatch(ctx, batch, params, loader, o.concurrency) {
+				select {
+				case results <- r:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return results, nil
+}
+
+// unpackBatch unpacks the envelopes, loading all of their elements with a single call to the loader
+func unpackBatch[T comparable](ctx context.Context, batch [][]byte, params *UnpackParams[T], loader BatchDataLoader[T], concurrency int) []UnpackResult[T] {
+
+	start := time.Now()
+	metrics := metricsOrDefault(params.Metrics)
+	provider := &measuredProvider{EnvelopeKeyProvider: params.Provider, metrics: metrics}
+	logger := loggerOrDefault(params.Logger)
+
+	results := make([]UnpackResult[T], len(batch))
+	details := make([]*itemPackingDetailsV1[T], len(batch))
+	envs := make([]*envelopeV1[T], len(batch))
+	continuations := make([][]T, len(batch))
+
+	// Continuation elements holding an envelope are loaded for each envelope, as they must be joined before opening
+	joinLoader := func(ctx context.Context, keys []T) (map[string][]byte, error) {
+		data, err := loader(ctx, keys)
+		if err != nil {
+			return nil, err
+		}
+		m := map[string][]byte{}
+		for _, attrs := range data {
+			for k, v := range attrs {
+				m[k] = v
+			}
+		}
+		return m, nil
+	}
+
+	// Failures are recorded against each envelope, so runConcurrently never returns an error
+	_ = runConcurrently(len(batch), concurrency, func(i int) error {
+		results[i].Data = batch[i]
+		results[i].Err = recoverError(func() error {
+			if len(batch[i]) == 0 {
+				return ErrUnpackNoData
+			}
+			var err error
+			if continuations[i], err = continuationKeys(batch[i], params.IDRetriever); err != nil {
+				return err
+			}
+			b, err := JoinEnvelope(ctx, batch[i], joinLoader, params.IDRetriever)
+			if err != nil {
+				return err
+			}
+			packingVersion, b, err := splitPackingVersion(b)
+			if err != nil {
+				return err
+			}
+			if !packingVersion.supported() {
+				return ErrUnsupportedPackVersion
+			}
+			details[i] = &itemPackingDetailsV1[T]{
+				version:       packingVersion,
+				maxAttributes: params.MaxAttributes,
+				memory:        newMemoryTracker(params.MemoryLimit),
+				allocator:     allocatorOrDefault(params.Allocator),
+				requireMAC:    params.RequireEnvelopeMAC,
+				verifiers:     params.Verifiers,
+			}
+			envs[i], err = details[i].openEnvelope(params.withEncryptionContext(ctx), b, provider, params.IDRetriever)
+			return err
+		})
+		return nil
+	})
+
+	requested := map[T]bool{}
+	keys := []T{}
+	for i, env := range envs {
+		if results[i].Err != nil {
+			continue
+		}
+		for _, t := range env.elements {
+			if !requested[t] {
+				requested[t] = true
+				keys = append(keys, t)
+			}
+		}
+	}
+
+	if len(keys) == 0 {
+		return results
+	}
+
+	logger.DebugContext(ctx, "packer: loading elements", slog.Int("elements", len(keys)), slog.Int("items", len(batch)))
+	data, err := loader(ctx, keys)
+	if err != nil {
+		logger.DebugContext(ctx, "packer: element loading failed", slog.Int("elements", len(keys)), slog.String("error", err.Error()))
+		for i := range results {
+			if results[i].Err == nil {
+				results[i].Err = err
+			}
+		}
+		return results
+	}
+
+	// Elements are served from the batch, with any others (such as replicas) loaded on demand
+	itemLoader := func(ctx context.Context, keys []T) (map[string][]byte, error) {
+		m := map[string][]byte{}
+		missing := []T{}
+		for _, t := range keys {
+			if !requested[t] {
+				missing = append(missing, t)
+				continue
+			}
+			for k, v := range data[t] {
+				m[k] = v
+			}
+		}
+		if len(missing) > 0 {
+			extra, err := loader(ctx, missing)
+			if err != nil {
+				return nil, err
+			}
+			for _, attrs := range extra {
+				for k, v := range attrs {
+					m[k] = v
+				}
+			}
+		}
+		return m, nil
+	}
+
+	_ = runConcurrently(len(batch), concurrency, func(i int) error {
+		if results[i].Err != nil {
+			return nil
+		}
+		results[i].Err = recoverError(func() error {
+			item, err := details[i].unpackEnvelope(ctx, envs[i], itemLoader)
+			if err != nil {
+				return err
+			}
+			item.elements = append(item.elements, continuations[i]...)
+			params.apply(item, metrics)
+			results[i].Item = item
+			return nil
+		})
+		if results[i].Err == nil {
+			metrics.Add(MetricUnpacks, 1)
+			metrics.Observe(MetricUnpackDuration, time.Since(start).Seconds())
+		}
+		return nil
+	})
+
+	return results
+}
+
+// recoverError calls f, returning any panic as an error, in the same manner as Pack and Unpack
+func recoverError(f func() error) (e error) {
+	defer func() {
+		if r := recover(); r != nil {
+			e = fmt.Errorf("%v", r)
+		}
+	}()
+	return f()
+}
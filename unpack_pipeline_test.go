@@ -0,0 +1,101 @@
+package packer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gford1000-go/serialise"
+)
+
+func TestUnpackPipeline(t *testing.T) {
+
+	_, _, provider := testCreateEnv(t)
+
+	serialiser, err := NewKeySerialiser()
+	if err != nil {
+		t.Fatalf("Unexpected error preparing Key serialiser: %v", err)
+	}
+
+	pParams := &PackParams[Key]{
+		Provider: provider,
+		Creator:  NewKeyCreator(defaultLen),
+		Packer:   serialiser,
+		Approach: serialise.NewMinDataApproachWithVersion(serialise.V1),
+	}
+
+	stored := map[Key]map[string][]byte{}
+	envelopes := make(chan []byte, 31)
+	for i := range 30 {
+		info, data, err := Pack(&Item[Key]{
+			Key:        Key{X: fmt.Sprintf("%d", i), Y: "B"},
+			Attributes: map[string]any{"aaa": fmt.Sprintf("Value %d", i)},
+		}, pParams)
+		if err != nil {
+			t.Fatalf("(%d) Unexpected error packing: %v", i, err)
+		}
+		for k, v := range data {
+			stored[k] = v
+		}
+		envelopes <- info
+	}
+	envelopes <- []byte("not an envelope")
+	close(envelopes)
+
+	var calls atomic.Int32
+	loader := func(ctx context.Context, keys []Key) (map[Key]map[string][]byte, error) {
+		calls.Add(1)
+		m := map[Key]map[string][]byte{}
+		for _, k := range keys {
+			if v, ok := stored[k]; ok {
+				m[k] = v
+			}
+		}
+		return m, nil
+	}
+
+	alloc := &countingAllocator{outstanding: map[*byte]bool{}}
+
+	params := &UnpackParams[Key]{
+		IDRetriever: func(string) (IDSerialiser[Key], error) { return serialiser, nil },
+		Provider:    provider,
+		Allocator:   alloc,
+	}
+
+	if _, err := UnpackPipeline(context.TODO(), envelopes, params, nil); !errors.Is(err, ErrBatchDataLoaderIsNil) {
+		t.Fatalf("Unexpected error: expected: %v, got: %v", ErrBatchDataLoaderIsNil, err)
+	}
+
+	results, err := UnpackPipeline(context.TODO(), envelopes, params, loader, WithUnpackBatchSize(10), WithUnpackConcurrency(4))
+	if err != nil {
+		t.Fatalf("Unexpected error creating pipeline: %v", err)
+	}
+
+	n, failed := 0, 0
+	for r := range results {
+		n++
+		if r.Err != nil {
+			failed++
+			continue
+		}
+		if r.Item.allocator != alloc {
+			t.Fatalf("Expected the Allocator to be applied to %v", r.Item.GetKey())
+		}
+		m, err := r.Item.GetValues(context.TODO(), []string{"aaa"}, provider)
+		if err != nil {
+			t.Fatalf("Unexpected error getting values: %v", err)
+		}
+		if m["aaa"] != "Value "+r.Item.GetKey().X {
+			t.Fatalf("Unexpected value for %v: %v", r.Item.GetKey(), m["aaa"])
+		}
+	}
+
+	if n != 31 || failed != 1 {
+		t.Fatalf("Unexpected results: expected 31 with 1 failure, got %d with %d failures", n, failed)
+	}
+	if c := calls.Load(); c > 4 {
+		t.Fatalf("Unexpected number of loader calls: expected no more than 4, got: %d", c)
+	}
+}
@@ -0,0 +1,81 @@
+package packer
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+)
+
+// UUID is a 128 bit identifier, as described by RFC 9562
+type UUID [16]byte
+
+// String returns the canonical form of the UUID, e.g. "f81d4fae-7dec-41d0-a765-00a0c91e6bf6"
+func (u UUID) String() string {
+	var b [36]byte
+	hex.Encode(b[0:8], u[0:4])
+	b[8] = '-'
+	hex.Encode(b[9:13], u[4:6])
+	b[13] = '-'
+	hex.Encode(b[14:18], u[6:8])
+	b[18] = '-'
+	hex.Encode(b[19:23], u[8:10])
+	b[23] = '-'
+	hex.Encode(b[24:], u[10:])
+	return string(b[:])
+}
+
+// ErrInvalidUUID raised if a string or byte slice is not a valid UUID
+var ErrInvalidUUID = errors.New("invalid UUID")
+
+// ParseUUID returns the UUID from its canonical form
+func ParseUUID(s string) (UUID, error) {
+	var u UUID
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return u, ErrInvalidUUID
+	}
+	b := []byte(s[0:8] + s[9:13] + s[14:18] + s[19:23] + s[24:])
+	if _, err := hex.Decode(u[:], b); err != nil {
+		return UUID{}, ErrInvalidUUID
+	}
+	return u, nil
+}
+
+// NewUUIDCreator returns an IDCreator for type UUID, creating random (version 4) UUIDs
+func NewUUIDCreator() IDCreator[UUID] {
+	return uuidCreator{}
+}
+
+type uuidCreator struct{}
+
+// ID returns a random UUID
+func (uuidCreator) ID() UUID {
+	var u UUID
+	if _, err := rand.Read(u[:]); err != nil {
+		panic(err)
+	}
+	u[6] = (u[6] & 0x0f) | 0x40 // Version 4
+	u[8] = (u[8] & 0x3f) | 0x80 // Variant RFC 9562
+	return u
+}
+
+// NewUUIDSerialiser returns an IDSerialiser for type UUID, serialising as the 16 bytes of the UUID
+func NewUUIDSerialiser() (IDSerialiser[UUID], error) {
+	return uuidSerialiser{}, nil
+}
+
+type uuidSerialiser struct{}
+
+func (uuidSerialiser) Name() string {
+	return "UUIDV1"
+}
+
+func (uuidSerialiser) Pack(u UUID) ([]byte, error) {
+	return u[:], nil
+}
+
+func (uuidSerialiser) Unpack(data []byte) (UUID, error) {
+	if len(data) != len(UUID{}) {
+		return UUID{}, ErrInvalidUUID
+	}
+	return UUID(data), nil
+}
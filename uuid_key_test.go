@@ -0,0 +1,98 @@
+package packer
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"testing"
+
+	"github.com/gford1000-go/serialise"
+)
+
+func TestNewUUIDCreator(t *testing.T) {
+
+	c := NewUUIDCreator()
+	m := map[UUID]bool{}
+
+	for range 10000 {
+		u := c.ID()
+		if m[u] {
+			t.Fatal("Repeated UUID generation detected - very surprising!")
+		}
+		m[u] = true
+
+		if u[6]>>4 != 4 || u[8]>>6 != 2 {
+			t.Fatalf("Expected a version 4 UUID, got %s", u)
+		}
+
+		p, err := ParseUUID(u.String())
+		if err != nil || p != u {
+			t.Fatalf("Mismatch parsing %s: got %s, %v", u, p, err)
+		}
+	}
+
+	for _, s := range []string{"", "f81d4fae-7dec-41d0-a765-00a0c91e6bf", "f81d4fae7dec-41d0-a765-00a0c91e6bf6x", "g81d4fae-7dec-41d0-a765-00a0c91e6bf6"} {
+		if _, err := ParseUUID(s); !errors.Is(err, ErrInvalidUUID) {
+			t.Fatalf("Expected ErrInvalidUUID for %q, got: %v", s, err)
+		}
+	}
+}
+
+func TestNewUUIDSerialiser(t *testing.T) {
+
+	s, err := NewUUIDSerialiser()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	u := NewUUIDCreator().ID()
+	b, err := s.Pack(u)
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+	v, err := s.Unpack(b)
+	if err != nil || v != u {
+		t.Fatalf("Mismatch: expected %s, got %s, %v", u, v, err)
+	}
+	if _, err := s.Unpack(b[1:]); !errors.Is(err, ErrInvalidUUID) {
+		t.Fatalf("Expected ErrInvalidUUID, got: %v", err)
+	}
+
+	_, _, provider := testCreateEnv(t)
+
+	item := &Item[UUID]{
+		Key:        u,
+		Attributes: map[string]any{"a": "x", "b": int64(2)},
+	}
+	info, data, err := Pack(item, &PackParams[UUID]{
+		Provider: provider,
+		Creator:  NewUUIDCreator(),
+		Packer:   s,
+		Approach: serialise.NewMinDataApproachWithVersion(serialise.V1),
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error packing item: %v", err)
+	}
+
+	e, err := Unpack(context.TODO(), info, &UnpackParams[UUID]{
+		DataLoader: func(_ context.Context, keys []UUID) (map[string][]byte, error) {
+			m := map[string][]byte{}
+			for _, k := range keys {
+				maps.Copy(m, data[k])
+			}
+			return m, nil
+		},
+		IDRetriever: func(string) (IDSerialiser[UUID], error) { return s, nil },
+		Provider:    provider,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking item: %v", err)
+	}
+	values, err := e.GetAllValues(context.TODO(), provider)
+	if err != nil {
+		t.Fatalf("Unexpected error getting values: %v", err)
+	}
+	if e.GetKey() != u || !maps.Equal(values, item.Attributes) {
+		t.Fatalf("Mismatch: expected %v, got %v", item.Attributes, values)
+	}
+}
@@ -0,0 +1,171 @@
+package packer
+
+import (
+	_ "embed"
+	"encoding/binary"
+	"errors"
+)
+
+// WireFormat selects how the outer envelope of data packed with V2 is encoded.  The envelope holds the
+// encrypted data encryption key, the names of the IDSerialiser and serialise.Approach, the encrypted
+// packing details and any digest.  Unpack detects the wire format, so it need not be specified.
+type WireFormat int8
+
+const (
+	// NativeWireFormat frames each field of the envelope with its length; this is the default
+	NativeWireFormat WireFormat = iota
+	// ProtoWireFormat encodes the envelope as the Envelope protobuf message of EnvelopeProto, so that
+	// implementations in other languages can read it
+	ProtoWireFormat
+)
+
+// wireFormatOutOfRange follows the last supported wire format
+const wireFormatOutOfRange = CBORWireFormat + 1
+
+// EnvelopeProto is the protobuf definition of the envelope encoded using ProtoWireFormat
+//
+//go:embed envelope.proto
+var EnvelopeProto string
+
+// ErrUnknownWireFormat raised if the wire format is not recognised
+var ErrUnknownWireFormat = errors.New("unknown wire format")
+
+// ErrWireFormatRequiresV2 raised if a wire format other than NativeWireFormat is requested for a packing version other than V2
+var ErrWireFormatRequiresV2 = errors.New("wire formats other than native require packing version V2")
+
+// WithWireFormat selects how the outer envelope is encoded.  Only V2 supports wire formats other than
+// NativeWireFormat (see WithPackingVersion).
+func WithWireFormat(wire WireFormat) func(o *Options) {
+	return func(o *Options) {
+		o.wireFormat = wire
+	}
+}
+
+// validate checks the wire format can be used with the packing version
+func (w WireFormat) validate(packingVersion PackVersion) error {
+	if w < NativeWireFormat || w >= wireFormatOutOfRange {
+		return ErrUnknownWireFormat
+	}
+	if w != NativeWireFormat && packingVersion != V2 {
+		return ErrWireFormatRequiresV2
+	}
+	return nil
+}
+
+// wireFormatMarker begins V2 envelopes that are not in the NativeWireFormat, and is followed by the wire format.
+// Native envelopes begin with the length of the encrypted data encryption key, which is never zero.
+const wireFormatMarker = 0x00
+
+// isWireFormat returns true if the V2 envelope is not in the NativeWireFormat
+func isWireFormat(data []byte) bool {
+	return len(data) > 1 && data[0] == wireFormatMarker
+}
+
+// encodeWireFormat encodes the finalised data using the wire format, prefixed by the marker and wire format
+func encodeWireFormat(wire WireFormat, finalisedData []any) ([]byte, error) {
+	switch wire {
+	case ProtoWireFormat:
+		return encodeProtoEnvelope([]byte{wireFormatMarker, byte(wire)}, finalisedData)
+	case CBORWireFormat:
+		return encodeCBOREnvelope([]byte{wireFormatMarker, byte(wire)}, finalisedData)
+	default:
+		return nil, ErrUnknownWireFormat
+	}
+}
+
+// decodeWireFormat is the inverse of encodeWireFormat
+func decodeWireFormat(data []byte) ([]any, WireFormat, error) {
+	wire := WireFormat(data[1])
+	switch wire {
+	case ProtoWireFormat:
+		finalisedData, err := decodeProtoEnvelope(data[2:])
+		return finalisedData, wire, err
+	case CBORWireFormat:
+		finalisedData, err := decodeCBOREnvelope(data[2:])
+		return finalisedData, wire, err
+	default:
+		return nil, wire, ErrUnknownWireFormat
+	}
+}
+
+// protoWireBytes is the protobuf wire type of length delimited fields
+const protoWireBytes = 2
+
+// encodeProtoEnvelope appends each field of the finalised data to b as the numbered field of the Envelope message
+func encodeProtoEnvelope(b []byte, finalisedData []any) ([]byte, error) {
+	for i, v := range finalisedData {
+		var f []byte
+		switch v := v.(type) {
+		case []byte:
+			f = v
+		case string:
+			f = []byte(v)
+		default:
+			return nil, ErrInvalidDataToUnpack
+		}
+		b = binary.AppendUvarint(b, uint64(i+1)<<3|protoWireBytes)
+		b = appendFrame(b, f)
+	}
+	return b, nil
+}
+
+// decodeProtoEnvelope is the inverse of encodeProtoEnvelope.  Unknown fields are skipped, so that fields
+// may be added to the Envelope message without breaking existing readers.
+func decodeProtoEnvelope(data []byte) ([]any, error) {
+
+	fields := make([][]byte, finalisedDataMaxFields)
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, ErrInvalidDataToUnpack
+		}
+		data = data[n:]
+
+		var f []byte
+		switch tag & 0x7 {
+		case 0: // varint
+			if _, n = binary.Uvarint(data); n <= 0 {
+				return nil, ErrInvalidDataToUnpack
+			}
+			data = data[n:]
+		case 1: // fixed64
+			if len(data) < 8 {
+				return nil, ErrInvalidDataToUnpack
+			}
+			data = data[8:]
+		case 5: // fixed32
+			if len(data) < 4 {
+				return nil, ErrInvalidDataToUnpack
+			}
+			data = data[4:]
+		case protoWireBytes:
+			size, n := binary.Uvarint(data)
+			if n <= 0 || size > uint64(len(data)-n) {
+				return nil, ErrInvalidDataToUnpack
+			}
+			f = data[n : n+int(size)]
+			data = data[n+int(size):]
+		default:
+			return nil, ErrInvalidDataToUnpack
+		}
+
+		if field := tag >> 3; field >= 1 && field <= uint64(len(fields)) && f != nil {
+			fields[field-1] = f
+		}
+	}
+
+	// The encrypted key and packing details are required, and later fields are optional
+	if fields[0] == nil || fields[3] == nil {
+		return nil, ErrInvalidDataToUnpack
+	}
+	for len(fields) > 4 && fields[len(fields)-1] == nil {
+		fields = fields[:len(fields)-1]
+	}
+
+	finalisedData := make([]any, len(fields))
+	for i, f := range fields {
+		finalisedData[i] = f
+	}
+	finalisedData[1], finalisedData[2] = string(fields[1]), string(fields[2])
+	return finalisedData, nil
+}
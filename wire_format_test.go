@@ -0,0 +1,95 @@
+package packer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"maps"
+	"strings"
+	"testing"
+)
+
+func TestWithWireFormat(t *testing.T) {
+
+	testPack, testUnpack, provider := testCreateEnv(t)
+
+	item := &Item[Key]{
+		Key:        Key{X: "A", Y: "B"},
+		Attributes: map[string]any{"a": "x", "b": int64(2)},
+	}
+
+	b, l, err := testPack(item, WithPackingVersion(V2), WithWireFormat(ProtoWireFormat), WithDigest([]byte("digest key")))
+	if err != nil {
+		t.Fatalf("Unexpected error packing: %v", err)
+	}
+	if !bytes.HasPrefix(b, []byte{0xff, 0x02, wireFormatMarker, byte(ProtoWireFormat)}) {
+		t.Fatalf("Unexpected prefix: %x", b[:4])
+	}
+
+	e, err := testUnpack(b, l)
+	if err != nil {
+		t.Fatalf("Unexpected error unpacking: %v", err)
+	}
+	values, err := e.GetAllValues(context.TODO(), provider)
+	if err != nil {
+		t.Fatalf("Unexpected error getting values: %v", err)
+	}
+	if !maps.Equal(values, item.Attributes) {
+		t.Fatalf("Mismatch: expected %v, got %v", item.Attributes, values)
+	}
+
+	if d, err := PackDigest(b); err != nil || len(d) == 0 {
+		t.Fatalf("Expected the digest to be readable, got %x: %v", d, err)
+	}
+
+	// Rewrapping preserves the wire format
+	r, err := e.ReWrap(context.TODO(), provider, provider)
+	if err != nil {
+		t.Fatalf("Unexpected error rewrapping: %v", err)
+	}
+	if !bytes.HasPrefix(r, b[:4]) {
+		t.Fatalf("Expected the wire format to be preserved, got prefix: %x", r[:4])
+	}
+
+	if _, _, err := testPack(item, WithWireFormat(ProtoWireFormat)); !errors.Is(err, ErrWireFormatRequiresV2) {
+		t.Fatalf("Expected ErrWireFormatRequiresV2, got: %v", err)
+	}
+	if _, _, err := testPack(item, WithPackingVersion(V2), WithWireFormat(wireFormatOutOfRange)); !errors.Is(err, ErrUnknownWireFormat) {
+		t.Fatalf("Expected ErrUnknownWireFormat, got: %v", err)
+	}
+	if err := (&Config{WireFormat: ProtoWireFormat}).Validate(); !errors.Is(err, ErrWireFormatRequiresV2) {
+		t.Fatalf("Expected ErrWireFormatRequiresV2 from Config, got: %v", err)
+	}
+
+	if !strings.Contains(EnvelopeProto, "message Envelope") {
+		t.Fatal("Expected the protobuf definition of the envelope")
+	}
+}
+
+func TestDecodeProtoEnvelope(t *testing.T) {
+
+	finalisedData := []any{[]byte("key"), "packer", "approach", []byte("details")}
+
+	b, err := encodeProtoEnvelope(nil, finalisedData)
+	if err != nil {
+		t.Fatalf("Unexpected error encoding: %v", err)
+	}
+
+	// Fields unknown to this version are skipped
+	b = append(b, 9<<3|0, 0x96, 0x01)
+	b = append(b, 10<<3|2, 2, 'h', 'i')
+
+	v, err := decodeProtoEnvelope(b)
+	if err != nil {
+		t.Fatalf("Unexpected error decoding: %v", err)
+	}
+	if len(v) != 4 || v[1] != "packer" || v[2] != "approach" || !bytes.Equal(v[3].([]byte), []byte("details")) {
+		t.Fatalf("Unexpected result: %v", v)
+	}
+
+	for _, data := range [][]byte{{1<<3 | 2, 10, 'x'}, {1<<3 | 3}, {2<<3 | 2, 1, 'p'}} {
+		if _, err := decodeProtoEnvelope(data); !errors.Is(err, ErrInvalidDataToUnpack) {
+			t.Fatalf("Expected ErrInvalidDataToUnpack for %x, got: %v", data, err)
+		}
+	}
+}